@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/archive"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// errStopReading stops reading an archive once enough messages were found
+var errStopReading = errors.New("stop reading")
+
+func archiveCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "archive",
+		Usage: "Lists, queries and restores the messages archived by the archive maintenance job",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "Lists the runs of the archive job in the archive.s3 bucket",
+				Action: func(c *cli.Context) error {
+					if err := validateOutput(c.String("output")); err != nil {
+						return err
+					}
+					a, err := openArchive(c)
+					if err != nil {
+						return err
+					}
+					runs, err := a.Runs(c.Context)
+					if err != nil {
+						return err
+					}
+					if c.String("output") == outputJSON {
+						return printJSON(runs)
+					}
+
+					w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+					fmt.Fprintln(w, "RUN\tCUTOFF\tMESSAGES\tPARTS\tCOMPLETE")
+					for _, run := range runs {
+						fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%t\n",
+							run.Run, run.Cutoff.Format(time.RFC3339), run.Messages, len(run.Parts), run.Complete)
+					}
+					return w.Flush()
+				},
+				Flags: []cli.Flag{
+					configFlag(),
+					outputFlag(),
+				},
+			},
+			{
+				Name:  "query",
+				Usage: "Prints the archived messages of a run or a file written to maintenance.archive.dir matching the filters",
+				Action: func(c *cli.Context) error {
+					if err := validateOutput(c.String("output")); err != nil {
+						return err
+					}
+					filter, err := archiveFilter(c)
+					if err != nil {
+						return err
+					}
+
+					limit := c.Int("limit")
+					var messages []dto.MessageResponse
+					err = readArchive(c, func(msg dto.MessageResponse) error {
+						if !matchArchived(filter, c.String("phone"), msg) {
+							return nil
+						}
+						messages = append(messages, msg)
+						if limit > 0 && len(messages) >= limit {
+							return errStopReading
+						}
+						return nil
+					})
+					if err != nil && !errors.Is(err, errStopReading) {
+						return err
+					}
+					return printMessages(c.String("output"), messages)
+				},
+				Flags: append(archiveSourceFlags(),
+					outputFlag(),
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Maximum number of messages to print, 0 prints every match",
+						Value: 100,
+					},
+				),
+			},
+			{
+				Name:  "restore",
+				Usage: "Inserts the archived messages of a run or a file matching the filters back into the database",
+				Description: "Messages are restored with their IDs, statuses and timestamps, those still in the database are skipped.\n" +
+					"Restored pending messages are sent again by the scheduler, use --status to restore only settled ones.",
+				Action: func(c *cli.Context) error {
+					filter, err := archiveFilter(c)
+					if err != nil {
+						return err
+					}
+
+					_, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+					messageService := service.NewMessageService(dbc)
+
+					dryRun := c.Bool("dry-run")
+					var batch []dto.MessageResponse
+					var matched, restored int
+					flush := func() error {
+						if dryRun || len(batch) == 0 {
+							batch = batch[:0]
+							return nil
+						}
+						n, err := messageService.RestoreMessages(c.Context, batch)
+						if err != nil {
+							return err
+						}
+						restored += n
+						batch = batch[:0]
+						return nil
+					}
+
+					err = readArchive(c, func(msg dto.MessageResponse) error {
+						if !matchArchived(filter, c.String("phone"), msg) {
+							return nil
+						}
+						matched++
+						batch = append(batch, msg)
+						if len(batch) >= c.Int("batch-size") {
+							return flush()
+						}
+						return nil
+					})
+					if err == nil {
+						err = flush()
+					}
+					if err != nil {
+						return fmt.Errorf("restore stopped after %d messages: %w", restored, err)
+					}
+
+					if dryRun {
+						fmt.Printf("Dry run: %d archived message(s) would be restored\n", matched)
+						return nil
+					}
+					fmt.Printf("Restored %d of %d archived message(s), %d exist already\n", restored, matched, matched-restored)
+					return nil
+				},
+				Flags: append(archiveSourceFlags(),
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of messages inserted per statement",
+						Value: 500,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only count the messages that would be restored",
+					},
+				),
+			},
+		},
+	}
+}
+
+// archiveSourceFlags are the flags choosing the archived messages of the query and restore commands
+func archiveSourceFlags() []cli.Flag {
+	return []cli.Flag{
+		configFlag(),
+		&cli.StringFlag{
+			Name:  "run",
+			Usage: "Run of the archive job in the archive.s3 bucket, see archive list",
+		},
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "JSON lines file written by the archive job to maintenance.archive.dir",
+		},
+		&cli.StringFlag{
+			Name:  "phone",
+			Usage: "Only include messages to this phone number",
+		},
+		statusFlag(),
+		fromFlag(),
+		toFlag(),
+		tagFlag(),
+		metadataFlag("Only include messages with this metadata value"),
+	}
+}
+
+// openArchive opens the archive of the archive.s3 bucket
+func openArchive(c *cli.Context) (*archive.Archive, error) {
+	cfg, err := config.NewConfig(c.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	a, err := archive.FromConfig(cfg.Archive)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, errors.New("archive.s3.bucket is not set")
+	}
+	return a, nil
+}
+
+// readArchive calls fn with the messages of the run of --run or the file of --file
+func readArchive(c *cli.Context, fn func(dto.MessageResponse) error) error {
+	run, path := c.String("run"), c.String("file")
+	if (run == "") == (path == "") {
+		return errors.New("exactly one of --run and --file is required")
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return archive.DecodeMessages(file, fn)
+	}
+
+	a, err := openArchive(c)
+	if err != nil {
+		return err
+	}
+	manifest, err := a.Manifest(c.Context, run)
+	if errors.Is(err, archive.ErrNotFound) {
+		return fmt.Errorf("archive run %q not found", run)
+	}
+	if err != nil {
+		return err
+	}
+	if !manifest.Complete {
+		fmt.Fprintf(os.Stderr, "Run %s was interrupted, reading the %d message(s) it archived\n", run, manifest.Messages)
+	}
+	return a.Read(c.Context, manifest, fn)
+}
+
+// archiveFilter builds the filter of the archived messages from the --status, --from, --to, --tag and --metadata flags
+func archiveFilter(c *cli.Context) (db.MessageFilter, error) {
+	filter, err := messageFilter(c)
+	if err != nil {
+		return filter, err
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, fmt.Errorf("invalid status %q", filter.Status)
+	}
+	return filter, nil
+}
+
+// matchArchived reports whether an archived message matches the filter and phone number
+func matchArchived(filter db.MessageFilter, phone string, msg dto.MessageResponse) bool {
+	if phone != "" && msg.To != phone {
+		return false
+	}
+	if filter.Status != "" && msg.Status != string(filter.Status) {
+		return false
+	}
+	if filter.From != nil && msg.CreatedAt.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !msg.CreatedAt.Before(*filter.To) {
+		return false
+	}
+	metadata := db.Metadata(msg.Metadata)
+	if filter.Tag != "" && !slices.Contains(metadata.Tags(), filter.Tag) {
+		return false
+	}
+	for key, want := range filter.Metadata {
+		if value, ok := metadata[key].(string); !ok || value != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/alert"
+	"github.com/boratanrikulu/sendpulse/internal/archive"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/db/migrator"
+	"github.com/boratanrikulu/sendpulse/internal/db/migrator/migrations"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/links"
+	"github.com/boratanrikulu/sendpulse/internal/policy"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/report"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+	"github.com/urfave/cli/v2"
+)
+
+// configFlag is the --config flag shared by every command that needs configuration
+func configFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "config.yaml file location",
+		Value:   "./configs/sendpulse.yaml",
+	}
+}
+
+// storageFlag overrides database.storage, memory runs without a database server
+func storageFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:  "storage",
+		Usage: "Message storage, postgres or memory (no DSN needed, data is lost on exit)",
+	}
+}
+
+// withStorage applies --storage to the config
+func withStorage(c *cli.Context) func(*config.Cfg) {
+	return func(cfg *config.Cfg) {
+		if c.IsSet("storage") {
+			cfg.Database.Storage = c.String("storage")
+		}
+	}
+}
+
+// connect loads the config pointed to by --config and opens the database connection
+func connect(c *cli.Context) (*config.Cfg, *bun.DB, error) {
+	cfg, err := config.NewConfig(c.String("config"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Database.Storage == config.StorageMemory {
+		return nil, nil, errors.New("the memory storage lives in the server process, use the API or the --remote commands")
+	}
+
+	dbc, err := db.Connect(cfg.Database.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.SetDB(dbc)
+	db.SetMaxSegments(cfg.Messaging.MaxSegments)
+
+	return cfg, dbc, nil
+}
+
+// connectWithRetry opens the database connection of a long running command, waiting up to
+// database.connect_timeout for the database to accept connections
+func connectWithRetry(ctx context.Context, cfg *config.Cfg) (*bun.DB, error) {
+	db.SetMaxSegments(cfg.Messaging.MaxSegments)
+	if cfg.Database.Storage == config.StorageMemory {
+		dbc, err := db.ConnectMemory(ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.Log().Warn("Storing messages in memory, they are lost when the process exits")
+		cfg.SetDB(dbc)
+		return dbc, nil
+	}
+
+	dbc, err := db.ConnectWithRetry(ctx, cfg.Database.DSN, cfg.Database.ConnectTimeout, func(attempt int, err error, wait time.Duration) {
+		config.Log().WithError(err).Warnf("Database unreachable (attempt %d), retrying in %s", attempt, wait)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.MaxOpenConns > 0 {
+		dbc.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	}
+	cfg.SetDB(dbc)
+	return dbc, nil
+}
+
+// checkSchema compares the applied migrations with the ones of this binary following database.schema_check.
+// A mismatch fails unless the check is off, or read_only and readOnlyAllowed, then readOnly is returned true.
+// The memory storage creates its tables from the models and is not checked.
+func checkSchema(ctx context.Context, cfg *config.Cfg, dbc *bun.DB, readOnlyAllowed bool) (readOnly bool, err error) {
+	if cfg.Database.Storage == config.StorageMemory || cfg.Database.SchemaCheck == config.SchemaCheckOff {
+		return false, nil
+	}
+
+	err = migrator.CheckSchema(ctx, migrate.NewMigrator(dbc, migrations.Migrations))
+	if err == nil {
+		return false, nil
+	}
+	mismatch := errors.Is(err, migrator.ErrSchemaBehind) || errors.Is(err, migrator.ErrSchemaAhead)
+	if mismatch && readOnlyAllowed && cfg.Database.SchemaCheck == config.SchemaCheckReadOnly {
+		config.Log().WithError(err).Error("Database schema does not match this binary, starting read-only")
+		return true, nil
+	}
+	return false, fmt.Errorf("schema check failed (set database.schema_check to override): %w", err)
+}
+
+// connectReplica opens the connection of database.replica like connectWithRetry, it returns nil without a replica
+func connectReplica(ctx context.Context, cfg *config.Cfg) (*bun.DB, error) {
+	if cfg.Database.Replica.DSN == "" {
+		return nil, nil
+	}
+	return db.ConnectWithRetry(ctx, cfg.Database.Replica.DSN, cfg.Database.ConnectTimeout, func(attempt int, err error, wait time.Duration) {
+		config.Log().WithError(err).Warnf("Database replica unreachable (attempt %d), retrying in %s", attempt, wait)
+	})
+}
+
+// watchDatabase tracks the availability of the database every database.health_interval until ctx is cancelled
+func watchDatabase(ctx context.Context, cfg *config.Cfg, dbc *bun.DB) *service.DatabaseAvailability {
+	availability := service.NewDatabaseAvailability(dbc)
+	go availability.Watch(ctx, cfg.Database.HealthInterval)
+	return availability
+}
+
+// newIngestQueue returns the queue new messages are enqueued to: Postgres behind the content policy and
+// link tracking, publishing created events onto publisher. The policy sees the original links.
+func newIngestQueue(cfg *config.Cfg, dbc *bun.DB, publisher events.Publisher) (queue.Queue, error) {
+	engine, err := policy.New(cfg.ContentPolicy)
+	if err != nil {
+		return nil, err
+	}
+	postgres := queue.NewPostgres(dbc)
+	postgres.SetIDStrategy(db.IDStrategy(cfg.Database.IDStrategy))
+	tracked := links.NewQueue(postgres, dbc, cfg.LinkTracking)
+	return events.NewQueue(policy.NewQueue(tracked, engine), publisher), nil
+}
+
+// logStartup logs a one-line summary of the build and the settings the command starts with, the ones looked at
+// first in an incident. GET /api/v1/admin/runtime reports them in full.
+func logStartup(cfg *config.Cfg, command string) {
+	build := service.Build()
+	config.Log().WithFields(logrus.Fields{
+		"command":     command,
+		"version":     build.Version,
+		"commit":      build.Commit,
+		"go_version":  build.GoVersion,
+		"pid":         os.Getpid(),
+		"gomaxprocs":  runtime.GOMAXPROCS(0),
+		"mode":        cfg.Server.Mode,
+		"listen":      strings.Join(cfg.Server.ListenAddresses(), ","),
+		"storage":     cfg.Database.Storage,
+		"queue":       cfg.Queue.Backend,
+		"messaging":   cfg.Messaging.Enabled,
+		"interval":    cfg.Messaging.Interval.String(),
+		"batch_size":  cfg.Messaging.BatchSize,
+		"region":      cfg.Region.Name,
+		"region_role": cfg.Region.Role,
+	}).Info("SendPulse starting")
+}
+
+// shutdownScheduler stops the scheduler and hands sending over once the in-flight batch finished,
+// waiting at most messaging.shutdown_timeout
+func shutdownScheduler(cfg *config.Cfg, scheduler *service.Scheduler) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Messaging.ShutdownTimeout)
+	defer cancel()
+
+	if err := scheduler.Handoff(ctx); err != nil {
+		config.Log().Errorf("Scheduler handoff error: %v", err)
+	}
+	config.Log().Info("Scheduler stopped")
+}
+
+// startAlerts runs the alert monitor in the background until ctx is cancelled, when alerts are enabled
+func startAlerts(ctx context.Context, cfg *config.Cfg, stats alert.StatsSource, scheduler *service.Scheduler, bus *events.Bus) {
+	if !cfg.Alerts.Enabled {
+		return
+	}
+
+	notifiers := alert.NewNotifiers(cfg.Alerts)
+	if len(notifiers) == 0 {
+		config.Log().Warn("Alerts are enabled but no notifier is configured")
+		return
+	}
+	monitor := alert.NewMonitor(cfg, stats, scheduler, notifiers)
+	go monitor.Run(ctx)
+	// the failures on the bus check the rules without waiting for the interval
+	sub := bus.Subscribe("alerts", events.DefaultBuffer, alert.WatchTypes...)
+	go monitor.Watch(ctx, sub)
+}
+
+// notifyQuotaWarnings sends the quota warnings to the alert notifiers, when alerts and quota warnings are enabled
+func notifyQuotaWarnings(cfg *config.Cfg, quotas *quota.Quotas) {
+	if !cfg.Alerts.Enabled || !cfg.Alerts.QuotaWarnings {
+		return
+	}
+
+	notifiers := alert.NewNotifiers(cfg.Alerts)
+	if len(notifiers) == 0 {
+		return
+	}
+	quotas.OnWarning(alert.NewQuotaWarner(cfg, notifiers).Warn)
+}
+
+// startReports sends the daily report in the background until ctx is cancelled, when reports are enabled
+func startReports(ctx context.Context, cfg *config.Cfg, dbc *bun.DB) {
+	if !cfg.Reports.Enabled {
+		return
+	}
+
+	notifiers := alert.NewChannelNotifiers(cfg.Reports.SlackWebhookURL, cfg.Reports.HTTPURL, cfg.Reports.Email)
+	if len(notifiers) == 0 {
+		config.Log().Warn("Reports are enabled but no notifier is configured")
+		return
+	}
+	reporter, err := report.NewReporter(cfg, dbc, notifiers)
+	if err != nil {
+		config.Log().Errorf("Failed to start the daily report: %v", err)
+		return
+	}
+	go reporter.Run(ctx)
+}
+
+// startMaintenance schedules the enabled maintenance jobs, they run in the background until ctx is cancelled
+func startMaintenance(ctx context.Context, cfg *config.Cfg, dbc *bun.DB, messages *service.MessageService, counts *service.CountCache, publisher events.Publisher) (*service.Maintenance, error) {
+	maintenance := service.NewMaintenance(dbc)
+	jobs := cfg.Maintenance
+	add := func(name string, job config.MaintenanceJob, run func() (service.MaintenanceFunc, error)) error {
+		if !job.Enabled {
+			return nil
+		}
+		fn, err := run()
+		if err != nil {
+			return fmt.Errorf("maintenance job %s: %w", name, err)
+		}
+		return maintenance.Add(name, job.Schedule, fn)
+	}
+
+	if err := errors.Join(
+		add(service.JobStuckReaper, jobs.StuckReaper, func() (service.MaintenanceFunc, error) {
+			return service.StuckReaperJob(dbc, jobs.StuckReaper.OlderThan), nil
+		}),
+		add(service.JobRetention, jobs.Retention, func() (service.MaintenanceFunc, error) {
+			return service.RetentionJob(messages, jobs.Retention.OlderThan), nil
+		}),
+		add(service.JobArchive, jobs.Archive, func() (service.MaintenanceFunc, error) {
+			if cfg.Archive.S3.Bucket != "" {
+				a, err := archive.FromConfig(cfg.Archive)
+				if err != nil {
+					return nil, err
+				}
+				return service.ArchiveStoreJob(messages, jobs.Archive.OlderThan, a, cfg.Archive.PartSize), nil
+			}
+			return service.ArchiveJob(messages, jobs.Archive.OlderThan, jobs.Archive.Dir), nil
+		}),
+		add(service.JobFailureRequeue, jobs.FailureRequeue, func() (service.MaintenanceFunc, error) {
+			return service.FailureRequeueJob(dbc, jobs.FailureRequeue, publisher), nil
+		}),
+		add(service.JobStatsRefresh, jobs.StatsRefresh, func() (service.MaintenanceFunc, error) {
+			return service.StatsRefreshJob(counts), nil
+		}),
+		add(service.JobReport, jobs.Report, func() (service.MaintenanceFunc, error) {
+			notifiers := alert.NewChannelNotifiers(cfg.Reports.SlackWebhookURL, cfg.Reports.HTTPURL, cfg.Reports.Email)
+			if len(notifiers) == 0 {
+				return nil, errors.New("no reports notifier is configured")
+			}
+			reporter, err := report.NewReporter(cfg, dbc, notifiers)
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context) (string, error) {
+				day := time.Now().AddDate(0, 0, -1)
+				if err := reporter.Send(ctx, day); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("sent the daily report of %s", day.Format(time.DateOnly)), nil
+			}, nil
+		}),
+	); err != nil {
+		return nil, err
+	}
+
+	go maintenance.Run(ctx)
+	return maintenance, nil
+}
+
+// newEventBus creates the lifecycle event bus the queues and services publish onto, with the metrics, the status
+// notices when they are enabled and, when nats events are enabled, the JetStream publisher subscribed. The returned
+// function closes the bus and flushes the JetStream publisher.
+func newEventBus(ctx context.Context, cfg *config.Cfg) (*events.Bus, func(), error) {
+	bus := events.NewBus()
+	var subscribers sync.WaitGroup
+	// the metrics drop the events they have no room for, the subscribers delivering them elsewhere slow the
+	// publishers down for up to events.DefaultWait first
+	subscribe := func(name string, wait time.Duration, run func(*events.Subscription), types ...events.Type) {
+		sub := bus.SubscribeWaiting(name, events.DefaultBuffer, wait, types...)
+		subscribers.Add(1)
+		go func() {
+			defer subscribers.Done()
+			run(sub)
+		}()
+	}
+	subscribe("metrics", 0, events.Count)
+	var notifier *events.Notifier
+	if cfg.StatusNotices.Enabled {
+		notifier = events.NewNotifier(cfg.StatusNotices)
+		subscribe("notices", events.DefaultWait, notifier.Run, events.NoticeTypes...)
+	}
+
+	closeBus := func() {
+		bus.Close()
+		if notifier == nil {
+			subscribers.Wait()
+			return
+		}
+		// the queued notices are posted until status_notices.drain_timeout, the ones left are dropped
+		drained := make(chan struct{})
+		go func() {
+			subscribers.Wait()
+			close(drained)
+		}()
+		timer := time.NewTimer(cfg.StatusNotices.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-drained:
+		case <-timer.C:
+			config.Log().Warnf("Status notices still queued after %s, dropping them", cfg.StatusNotices.DrainTimeout)
+			notifier.Stop()
+			<-drained
+		}
+	}
+	if !cfg.NATS.Events.Enabled {
+		return bus, closeBus, nil
+	}
+
+	publisher, err := events.NewJetStream(ctx, cfg.NATS.URL, cfg.NATS.Events)
+	if err != nil {
+		closeBus()
+		return nil, nil, err
+	}
+	subscribe("nats", events.DefaultWait, func(sub *events.Subscription) { events.Forward(sub, publisher) })
+	config.Log().Infof("Publishing message events to %s.*", cfg.NATS.Events.Subject)
+	return bus, func() {
+		closeBus()
+		publisher.Close()
+	}, nil
+}
+
+// flushTracing exports the remaining spans before the process exits
+func flushTracing(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		config.Log().Errorf("Tracing shutdown error: %v", err)
+	}
+}
+
+// confirm asks the operator to type expected before a destructive action
+func confirm(prompt, expected string) error {
+	fmt.Printf("%s\nType %q to continue: ", prompt, expected)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("confirmation aborted: %w", err)
+	}
+	if strings.TrimSpace(answer) != expected {
+		return fmt.Errorf("confirmation did not match, aborting")
+	}
+	return nil
+}
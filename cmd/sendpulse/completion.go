@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// bashCompletion asks the binary for candidates through --generate-bash-completion,
+// so subcommands and flag names are always in sync with the command tree
+const bashCompletion = `# bash completion for %[1]s
+_%[1]s_bash_autocomplete() {
+  local cur words cword
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  words=("${COMP_WORDS[@]:0:$COMP_CWORD}")
+  local opts
+  if [[ "$cur" == "-"* ]]; then
+    opts=$("${words[@]}" "$cur" --generate-bash-completion 2>/dev/null)
+  else
+    opts=$("${words[@]}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "${opts}" -- "${cur}"))
+  return 0
+}
+
+complete -o bashdefault -o default -F _%[1]s_bash_autocomplete %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+
+_%[1]s_zsh_autocomplete() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _%[1]s_zsh_autocomplete %[1]s
+`
+
+func completionCMD() *cli.Command {
+	return &cli.Command{
+		Name:      "completion",
+		Usage:     "Prints the shell completion script for bash, zsh or fish",
+		ArgsUsage: "<bash|zsh|fish>",
+		Description: "Load completions for the current shell session:\n" +
+			"  bash: source <(sendpulse completion bash)\n" +
+			"  zsh:  source <(sendpulse completion zsh)\n" +
+			"  fish: sendpulse completion fish | source",
+		BashComplete: func(c *cli.Context) {
+			fmt.Fprintln(c.App.Writer, "bash\nzsh\nfish")
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("expected exactly one shell: bash, zsh or fish")
+			}
+
+			name := c.App.Name
+			switch strings.ToLower(c.Args().First()) {
+			case "bash":
+				fmt.Fprintf(c.App.Writer, bashCompletion, name)
+			case "zsh":
+				fmt.Fprintf(c.App.Writer, zshCompletion, name)
+			case "fish":
+				script, err := c.App.ToFishCompletion()
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(c.App.Writer, script)
+			default:
+				return fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", c.Args().First())
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+
+	"github.com/urfave/cli/v2"
+)
+
+func configCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "configuration utilities",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "validate",
+				Usage: "Validates a config file with its environment overrides, exits non-zero on problems",
+				Description: "Loads the config file, applies the SENDPULSE_* environment overrides and reports every problem found.\n" +
+					"--check-db and --check-webhook additionally check that the database and the webhook are reachable.",
+				Action: func(c *cli.Context) error {
+					path := c.String("config")
+					cfg, err := config.LoadConfig(path)
+					if err != nil {
+						return err
+					}
+
+					problems := cfg.Validate()
+
+					ctx, cancel := context.WithTimeout(c.Context, c.Duration("timeout"))
+					defer cancel()
+
+					if c.Bool("check-db") && cfg.Database.DSN != "" {
+						if err := pingDatabase(ctx, cfg.Database.DSN); err != nil {
+							problems = append(problems, fmt.Errorf("database is not reachable: %w", err))
+						}
+					}
+					if c.Bool("check-webhook") && cfg.Webhook.URL != "" {
+						if err := pingWebhook(ctx, cfg.Webhook.URL); err != nil {
+							problems = append(problems, fmt.Errorf("webhook is not reachable: %w", err))
+						}
+					}
+
+					if len(problems) == 0 {
+						fmt.Printf("%s: ok\n", path)
+						return nil
+					}
+
+					fmt.Printf("%s: %d problem(s) found\n", path, len(problems))
+					for _, problem := range problems {
+						fmt.Printf("  - %v\n", problem)
+					}
+					return cli.Exit("", 1)
+				},
+				Flags: []cli.Flag{
+					configFlag(),
+					&cli.BoolFlag{
+						Name:  "check-db",
+						Usage: "Also connect to and ping the database",
+					},
+					&cli.BoolFlag{
+						Name:  "check-webhook",
+						Usage: "Also check that the webhook URL is reachable",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Maximum time for the live checks",
+						Value: 10 * time.Second,
+					},
+				},
+			},
+		},
+	}
+}
+
+func pingDatabase(ctx context.Context, dsn string) error {
+	dbc, err := db.Connect(dsn)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	return dbc.PingContext(ctx)
+}
+
+// pingWebhook only checks that the endpoint answers, any HTTP response below 500 counts as reachable
+func pingWebhook(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
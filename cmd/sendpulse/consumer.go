@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/consumer"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/urfave/cli/v2"
+)
+
+func consumerCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "consumer",
+		Usage: "Consumes message create events from the configured streams (Kafka, AMQP, NATS) into the queue",
+		Action: func(c *cli.Context) error {
+			cfg, dbc, err := connect(c)
+			if err != nil {
+				return err
+			}
+			defer dbc.Close()
+
+			bus, closeBus, err := newEventBus(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeBus()
+
+			ingestQueue, err := newIngestQueue(cfg, dbc, bus)
+			if err != nil {
+				return err
+			}
+			consumers := newConsumers(cfg, ingestQueue, false)
+			if len(consumers) == 0 {
+				return errors.New("no consumer is enabled, enable kafka, amqp or nats.consumer in the config")
+			}
+
+			shutdownTracing, err := telemetry.SetupTracing(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer flushTracing(shutdownTracing)
+
+			flushSentry, err := telemetry.SetupSentry(cfg)
+			if err != nil {
+				return err
+			}
+			defer flushSentry()
+
+			stopStatsD, err := telemetry.SetupStatsD(cfg)
+			if err != nil {
+				return err
+			}
+			defer stopStatsD()
+
+			err = consumer.Run(c.Context, consumers...)
+			config.Log().Info("SendPulse consumer stopped")
+			return err
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+		},
+	}
+}
+
+// newConsumers returns the enabled consumers, withServer only returns those configured to run inside the server
+func newConsumers(cfg *config.Cfg, q queue.Queue, withServer bool) []consumer.Consumer {
+	processor := consumer.NewProcessor(q)
+
+	var consumers []consumer.Consumer
+	if cfg.Kafka.Enabled && (!withServer || cfg.Kafka.WithServer) {
+		consumers = append(consumers, consumer.NewKafka(cfg.Kafka, processor))
+	}
+	if cfg.AMQP.Enabled && (!withServer || cfg.AMQP.WithServer) {
+		consumers = append(consumers, consumer.NewAMQP(cfg.AMQP, processor))
+	}
+	if cfg.NATS.Consumer.Enabled && (!withServer || cfg.NATS.Consumer.WithServer) {
+		consumers = append(consumers, consumer.NewJetStream(cfg.NATS, processor))
+	}
+	return consumers
+}
+
+// startConsumers runs the consumers configured to run with the server in the background
+func startConsumers(ctx context.Context, cfg *config.Cfg, q queue.Queue) {
+	consumers := newConsumers(cfg, q, true)
+	if len(consumers) == 0 {
+		return
+	}
+
+	go func() {
+		if err := consumer.Run(ctx, consumers...); err != nil {
+			config.Log().Errorf("Consumer error: %v", err)
+		}
+	}()
+}
@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/boratanrikulu/sendpulse/internal/db"
 	"github.com/boratanrikulu/sendpulse/internal/db/migrator"
 	"github.com/boratanrikulu/sendpulse/internal/db/migrator/migrations"
+	"github.com/boratanrikulu/sendpulse/internal/export"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
 
 	"github.com/uptrace/bun/migrate"
 	"github.com/urfave/cli/v2"
@@ -18,6 +29,68 @@ func databaseCMD() *cli.Command {
 		Aliases: []string{"db", "d"},
 		Usage:   "database migrations",
 		Subcommands: []*cli.Command{
+			{
+				Name:  "createdb",
+				Usage: "Creates the database of the configured DSN",
+				Action: func(c *cli.Context) error {
+					cfg, err := config.NewConfig(c.String("config"))
+					if err != nil {
+						return err
+					}
+
+					created, err := db.CreateDatabase(c.Context, cfg.Database.DSN)
+					if err != nil {
+						return err
+					}
+
+					name := db.DatabaseName(cfg.Database.DSN)
+					if !created {
+						config.Log().Infof("database %s already exists", name)
+						return nil
+					}
+					config.Log().Infof("created database %s", name)
+					return nil
+				},
+			},
+			{
+				Name:  "dropdb",
+				Usage: "Drops the database of the configured DSN",
+				Action: func(c *cli.Context) error {
+					cfg, err := config.NewConfig(c.String("config"))
+					if err != nil {
+						return err
+					}
+
+					name := db.DatabaseName(cfg.Database.DSN)
+					if cfg.Server.Mode == config.ModeProd && !c.Bool("yes") {
+						if err := confirm(fmt.Sprintf("This will drop the %s database in prod mode.", name), name); err != nil {
+							return err
+						}
+					}
+
+					dropped, err := db.DropDatabase(c.Context, cfg.Database.DSN, c.Bool("force"))
+					if err != nil {
+						return err
+					}
+
+					if !dropped {
+						config.Log().Infof("database %s does not exist", name)
+						return nil
+					}
+					config.Log().Infof("dropped database %s", name)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Terminate open connections to the database before dropping it",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt in prod mode",
+					},
+				},
+			},
 			{
 				Name:  "init",
 				Usage: "Creates migration tables",
@@ -53,27 +126,135 @@ func databaseCMD() *cli.Command {
 					}
 					cfg.SetDB(dbc)
 
-					return migrator.Migrate(
-						context.Background(), migrate.NewMigrator(dbc, migrations.Migrations))
+					m := migrate.NewMigrator(dbc, migrations.Migrations)
+					if c.Bool("dry-run") {
+						return printMigrationPlan(context.Background(), m)
+					}
+					return migrator.Migrate(context.Background(), m)
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print the migrations and statements that would run",
+					},
 				},
 			},
 			{
 				Name:  "rollback",
-				Usage: "Rollbacks db the latest migration",
+				Usage: "Rollbacks db the latest migration group, or to a specific migration",
+				Description: "Without options the last migration group is rolled back.\n" +
+					"--steps N rolls back the last N migrations, --to <migration> rolls back everything applied after it\n" +
+					"(use --to 0 to roll back all migrations).",
 				Action: func(c *cli.Context) error {
+					if c.IsSet("to") && c.IsSet("steps") {
+						return fmt.Errorf("--to and --steps cannot be used together")
+					}
+
 					path := c.String("config")
 					cfg, err := config.NewConfig(path)
 					if err != nil {
 						return err
 					}
+
+					if cfg.Server.Mode == config.ModeProd && !c.Bool("yes") {
+						if err := confirm("This will roll back migrations in prod mode.", "rollback"); err != nil {
+							return err
+						}
+					}
+
 					dbc, err := db.Connect(cfg.Database.DSN)
 					if err != nil {
 						return err
 					}
 					cfg.SetDB(dbc)
 
-					return migrator.Rollback(
-						context.Background(), migrate.NewMigrator(dbc, migrations.Migrations))
+					m := migrate.NewMigrator(dbc, migrations.Migrations)
+					switch {
+					case c.IsSet("to"):
+						return migrator.RollbackTo(context.Background(), m, c.String("to"))
+					case c.IsSet("steps"):
+						return migrator.RollbackSteps(context.Background(), m, c.Int("steps"))
+					}
+					return migrator.Rollback(context.Background(), m)
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Roll back every migration applied after this one (e.g. 20241118000001), 0 for all",
+					},
+					&cli.IntFlag{
+						Name:  "steps",
+						Usage: "Number of migrations to roll back",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt in prod mode",
+					},
+				},
+			},
+			{
+				Name:  "reset",
+				Usage: "Rolls back all migrations and migrates again, optionally re-seeding",
+				Description: "Every table created by the migrations is dropped, so all messages are lost.\n" +
+					"In prod mode the command refuses to run unless --force is given and the database name is typed.",
+				Action: func(c *cli.Context) error {
+					opts := seedOptions{
+						Count:          c.Int("seed"),
+						BatchSize:      500,
+						Workers:        defaultSeedWorkers,
+						PendingPercent: 100,
+					}
+					if err := opts.validate(); err != nil {
+						return err
+					}
+
+					cfg, err := config.NewConfig(c.String("config"))
+					if err != nil {
+						return err
+					}
+
+					if cfg.Server.Mode == config.ModeProd {
+						name := db.DatabaseName(cfg.Database.DSN)
+						if !c.Bool("force") {
+							return fmt.Errorf("refusing to reset the %s database in prod mode without --force", name)
+						}
+						if err := confirm(fmt.Sprintf("This will delete all data of the %s database in prod mode.", name), name); err != nil {
+							return err
+						}
+					}
+
+					dbc, err := db.Connect(cfg.Database.DSN)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+					cfg.SetDB(dbc)
+
+					m := migrate.NewMigrator(dbc, migrations.Migrations)
+					if err := migrator.InitMigrator(c.Context, m); err != nil {
+						return err
+					}
+					if err := migrator.RollbackTo(c.Context, m, migrator.RollbackAll); err != nil {
+						return err
+					}
+					if err := migrator.Migrate(c.Context, m); err != nil {
+						return err
+					}
+
+					if opts.Count > 0 {
+						return seedMessages(c.Context, dbc, opts)
+					}
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "seed",
+						Usage: "Number of pending messages to seed after migrating",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Allow resetting in prod mode (a typed confirmation is still required)",
+					},
 				},
 			},
 			{
@@ -95,13 +276,371 @@ func databaseCMD() *cli.Command {
 						context.Background(), migrate.NewMigrator(dbc, migrations.Migrations))
 				},
 			},
+			{
+				Name:  "purge",
+				Usage: "Deletes (or archives and deletes) messages older than a given age in batches",
+				Action: func(c *cli.Context) error {
+					olderThan, err := parseAge(c.String("older-than"))
+					if err != nil {
+						return err
+					}
+					status := db.MessageStatus(c.String("status"))
+					if status != "" && !status.IsValid() {
+						return fmt.Errorf("invalid status %q", status)
+					}
+					archiveFormat := c.String("archive-format")
+					if archiveFormat != export.FormatCSV && archiveFormat != export.FormatJSONL {
+						return fmt.Errorf("unsupported archive format %q, expected %s or %s", archiveFormat, export.FormatCSV, export.FormatJSONL)
+					}
+
+					cfg, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					cutoff := time.Now().Add(-olderThan)
+					filter := db.MessageFilter{Status: status, To: &cutoff}
+					opts := service.PurgeOptions{
+						BatchSize: c.Int("batch-size"),
+						Pause:     c.Duration("pause"),
+						DryRun:    c.Bool("dry-run"),
+						OnProgress: func(deleted int) {
+							fmt.Printf("\rDeleted %d messages", deleted)
+						},
+					}
+					messageService := service.NewMessageService(dbc)
+
+					if opts.DryRun {
+						count, err := messageService.PurgeMessages(c.Context, filter, opts)
+						if err != nil {
+							return err
+						}
+						fmt.Printf("Dry run: %d message(s) created before %s would be deleted\n", count, cutoff.Format(time.RFC3339))
+						return nil
+					}
+
+					if cfg.Server.Mode == config.ModeProd && !c.Bool("yes") {
+						if err := confirm("This will permanently delete messages in prod mode.", "purge"); err != nil {
+							return err
+						}
+					}
+
+					if path := c.String("archive"); path != "" {
+						file, err := os.Create(path)
+						if err != nil {
+							return err
+						}
+						defer file.Close()
+
+						buffered := bufio.NewWriter(file)
+						writer := export.NewWriter(archiveFormat, buffered)
+						// every batch is flushed to disk before it is deleted
+						opts.Archive = func(batch []dto.MessageResponse) error {
+							for _, msg := range batch {
+								if err := writer.Write(msg); err != nil {
+									return err
+								}
+							}
+							if err := writer.Flush(); err != nil {
+								return err
+							}
+							if err := buffered.Flush(); err != nil {
+								return err
+							}
+							return file.Sync()
+						}
+					}
+
+					deleted, err := messageService.PurgeMessages(c.Context, filter, opts)
+					if deleted > 0 {
+						fmt.Println()
+					}
+					if err != nil {
+						return fmt.Errorf("purge stopped after %d messages: %w", deleted, err)
+					}
+					fmt.Printf("Purged %d message(s) created before %s\n", deleted, cutoff.Format(time.RFC3339))
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "older-than",
+						Usage:    "Only purge messages created longer ago than this (e.g. 90d or 36h)",
+						Required: true,
+					},
+					statusFlag(),
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of messages deleted per batch",
+						Value: service.ExportBatchSize,
+					},
+					&cli.DurationFlag{
+						Name:  "pause",
+						Usage: "Pause between batches to limit the load on the database",
+						Value: 100 * time.Millisecond,
+					},
+					&cli.StringFlag{
+						Name:  "archive",
+						Usage: "Write purged messages to this file before deleting them",
+					},
+					&cli.StringFlag{
+						Name:  "archive-format",
+						Usage: "Archive format: csv or jsonl",
+						Value: export.FormatJSONL,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print how many messages would be purged",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt in prod mode",
+					},
+				},
+			},
+			{
+				Name:  "normalize-phones",
+				Usage: "Rewrites the suppressed numbers stored before numbers were validated to E.164 in resumable batches",
+				Description: "Numbers are normalized like imported opt-out lists: separators, tel: and a 00 prefix are removed and\n" +
+					"MSISDNs get a +. A suppression whose E.164 number is suppressed already is dropped, numbers that\n" +
+					"cannot be normalized are listed. Every batch is committed on its own, a stopped run prints the\n" +
+					"--after to resume with. Once no unfixable number is left --validate validates the\n" +
+					"check_suppression_phone_format constraint, which only checks new suppressions until then.\n" +
+					"Messages are checked since the first migration, they need no backfill.",
+				Action: func(c *cli.Context) error {
+					_, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					opts := service.PhoneNormalization{
+						BatchSize:       c.Int("batch-size"),
+						Pause:           c.Duration("pause"),
+						After:           c.String("after"),
+						DryRun:          c.Bool("dry-run"),
+						DeleteUnfixable: c.Bool("delete-unfixable"),
+						OnBatch: func(result *service.PhoneNormalizationResult) {
+							fmt.Printf("\rScanned %d suppressions, up to %q", result.Scanned, result.Cursor)
+						},
+					}
+					result, err := service.NewSuppressionService(dbc, config.Suppression{}).NormalizePhones(c.Context, opts)
+					if result.Scanned > 0 {
+						fmt.Println()
+					}
+					if err != nil {
+						return fmt.Errorf("stopped after %d suppressions, resume with --after %q: %w", result.Scanned, result.Cursor, err)
+					}
+
+					verb, deleted := "Normalized", "kept"
+					if opts.DryRun {
+						verb = "Dry run: would normalize"
+					} else if opts.DeleteUnfixable {
+						deleted = "deleted"
+					}
+					fmt.Printf("%s %d of %d suppressed numbers (%d merged into an existing suppression, %d unfixable %s)\n",
+						verb, result.Normalized, result.Scanned, result.Merged, len(result.Unfixable), deleted)
+					for _, phone := range result.Unfixable {
+						fmt.Printf("  %q is not a phone number\n", phone)
+					}
+
+					if !c.Bool("validate") || opts.DryRun {
+						return nil
+					}
+					if len(result.Unfixable) > 0 && !opts.DeleteUnfixable {
+						return fmt.Errorf("not validating %s, %d unfixable numbers are left", db.SuppressionPhoneConstraint, len(result.Unfixable))
+					}
+					if err := db.ValidateSuppressionPhones(c.Context, dbc); err != nil {
+						return fmt.Errorf("failed to validate %s: %w", db.SuppressionPhoneConstraint, err)
+					}
+					fmt.Printf("Validated %s\n", db.SuppressionPhoneConstraint)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of suppressions scanned per batch",
+						Value: service.SuppressionImportBatchSize,
+					},
+					&cli.DurationFlag{
+						Name:  "pause",
+						Usage: "Pause between batches to limit the load on the database",
+						Value: 100 * time.Millisecond,
+					},
+					&cli.StringFlag{
+						Name:  "after",
+						Usage: "Resume a stopped run after this number, as printed when it stopped",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report what would be normalized",
+					},
+					&cli.BoolFlag{
+						Name:  "delete-unfixable",
+						Usage: "Delete the suppressions of numbers that cannot be normalized, they never match a message",
+					},
+					&cli.BoolFlag{
+						Name:  "validate",
+						Usage: "Validate the suppression phone constraint once no unfixable number is left",
+					},
+				},
+			},
+			{
+				Name:  "backup",
+				Usage: "Writes the messages, suppressions, links, usage counters and erasure records to a portable backup file",
+				Description: "The backup is taken with COPY from a single snapshot while SendPulse keeps running. It records the\n" +
+					"schema version, restore only accepts it on a database migrated to the same version.\n" +
+					"Files ending in .gz are compressed.",
+				Action: func(c *cli.Context) error {
+					cfg, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					version, err := migrator.SchemaVersion(c.Context, migrate.NewMigrator(dbc, migrations.Migrations))
+					if err != nil {
+						return err
+					}
+					if version == "" {
+						return fmt.Errorf("the %s database is not migrated", db.DatabaseName(cfg.Database.DSN))
+					}
+
+					path := c.String("output")
+					if path == "" {
+						path = fmt.Sprintf("sendpulse-%s.backup.gz", time.Now().UTC().Format("20060102-150405"))
+					}
+					file, err := os.Create(path)
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+
+					writer := newBackupWriter(path, file)
+					rows, err := db.Backup(c.Context, dbc, writer, version)
+					if err == nil {
+						err = writer.Close()
+					}
+					if err == nil {
+						err = file.Sync()
+					}
+					if err != nil {
+						os.Remove(path)
+						return fmt.Errorf("backup failed: %w", err)
+					}
+
+					fmt.Printf("Backed up %s (schema %s) to %s\n", formatRows(rows), version, path)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Backup file (default: sendpulse-<timestamp>.backup.gz)",
+					},
+				},
+			},
+			{
+				Name:  "restore",
+				Usage: "Restores a backup written by db backup",
+				Description: "The backup is validated and restored in a single transaction, nothing is restored when its checksum,\n" +
+					"row counts or schema version do not match. The tables must be empty unless --replace is given.\n" +
+					"Stop the servers and workers first.",
+				Action: func(c *cli.Context) error {
+					cfg, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					name := db.DatabaseName(cfg.Database.DSN)
+					if c.Bool("replace") && cfg.Server.Mode == config.ModeProd && !c.Bool("yes") {
+						if err := confirm(fmt.Sprintf("This will replace all messages of the %s database in prod mode.", name), name); err != nil {
+							return err
+						}
+					}
+
+					version, err := migrator.SchemaVersion(c.Context, migrate.NewMigrator(dbc, migrations.Migrations))
+					if err != nil {
+						return err
+					}
+
+					path := c.String("input")
+					file, err := os.Open(path)
+					if err != nil {
+						return err
+					}
+					defer file.Close()
+
+					reader, err := newBackupReader(path, file)
+					if err != nil {
+						return err
+					}
+					header, rows, err := db.Restore(c.Context, dbc, reader, db.RestoreOptions{SchemaVersion: version, Replace: c.Bool("replace")})
+					if err != nil {
+						return fmt.Errorf("restore failed, nothing was restored: %w", err)
+					}
+
+					fmt.Printf("Restored %s from the backup of %s into %s\n", formatRows(rows), header.CreatedAt.Format(time.RFC3339), name)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "input",
+						Aliases:  []string{"i"},
+						Usage:    "Backup file, .gz files are decompressed",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "replace",
+						Usage: "Delete the existing rows of the restored tables first",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt in prod mode",
+					},
+				},
+			},
 			{
 				Name:  "seed",
 				Usage: "Generate random message data for testing",
 				Action: func(c *cli.Context) error {
-					count := c.Int("count")
 					path := c.String("config")
 
+					from, err := query.ParseDate("from", c.String("from"))
+					if err != nil {
+						return err
+					}
+					to, err := query.ParseDate("to", c.String("to"))
+					if err != nil {
+						return err
+					}
+					var profile *seedProfile
+					if c.IsSet("profile") {
+						if c.IsSet("pending") || c.IsSet("sent") || c.IsSet("failed") {
+							return fmt.Errorf("--profile sets the status mix, it cannot be combined with --pending, --sent or --failed")
+						}
+						if profile, err = findSeedProfile(c.String("profile")); err != nil {
+							return err
+						}
+					}
+					opts := seedOptions{
+						Count:          c.Int("count"),
+						BatchSize:      c.Int("batch-size"),
+						Workers:        c.Int("workers"),
+						Seed:           c.Int64("seed"),
+						PendingPercent: c.Int("pending"),
+						SentPercent:    c.Int("sent"),
+						FailedPercent:  c.Int("failed"),
+						From:           from,
+						To:             to,
+						PhonePrefix:    c.String("phone-prefix"),
+						Profile:        profile,
+					}
+					if err := opts.validate(); err != nil {
+						return err
+					}
+
 					cfg, err := config.NewConfig(path)
 					if err != nil {
 						return err
@@ -113,7 +652,7 @@ func databaseCMD() *cli.Command {
 					}
 					cfg.SetDB(dbc)
 
-					return seedMessages(context.Background(), dbc, count)
+					return seedMessages(c.Context, dbc, opts)
 				},
 				Flags: []cli.Flag{
 					&cli.IntFlag{
@@ -122,6 +661,51 @@ func databaseCMD() *cli.Command {
 						Usage:   "Number of random messages to generate",
 						Value:   10,
 					},
+					&cli.IntFlag{
+						Name:  "pending",
+						Usage: "Percentage of pending messages",
+						Value: 100,
+					},
+					&cli.IntFlag{
+						Name:  "sent",
+						Usage: "Percentage of sent messages",
+						Value: 0,
+					},
+					&cli.IntFlag{
+						Name:  "failed",
+						Usage: "Percentage of failed messages",
+						Value: 0,
+					},
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "Spread creation dates starting from this date (YYYY-MM-DD or RFC3339)",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Spread creation dates up to this date (default: now)",
+					},
+					&cli.StringFlag{
+						Name:  "profile",
+						Usage: "Generate production shaped contents, statuses, timestamps and webhook responses: " + seedProfileNames(),
+					},
+					&cli.StringFlag{
+						Name:  "phone-prefix",
+						Usage: "Generate random numbers with this prefix, e.g. +4915 (default: sample Turkish numbers)",
+					},
+					&cli.Int64Flag{
+						Name:  "seed",
+						Usage: "Random seed for reproducible data (default: current time)",
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Number of messages inserted per statement",
+						Value: 500,
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Usage: "Number of batches inserted concurrently",
+						Value: defaultSeedWorkers,
+					},
 				},
 			},
 		},
@@ -135,3 +719,60 @@ func databaseCMD() *cli.Command {
 		},
 	}
 }
+
+// printMigrationPlan prints the pending migrations with their statements
+func printMigrationPlan(ctx context.Context, m *migrate.Migrator) error {
+	plan, err := migrator.Plan(ctx, m)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Println("There are no new migrations to run (database is up to date)")
+		return nil
+	}
+
+	fmt.Printf("%d migration(s) would run:\n", len(plan))
+	for _, p := range plan {
+		fmt.Printf("\n-- %s\n", p.Name)
+		for _, stmt := range p.Statements {
+			fmt.Printf("%s;\n", strings.TrimRight(strings.TrimSpace(stmt), ";"))
+		}
+		if p.Err != nil {
+			fmt.Printf("-- statements could not be fully captured: %v\n", p.Err)
+		}
+	}
+	return nil
+}
+
+// newBackupWriter compresses the backup when path ends in .gz
+func newBackupWriter(path string, w io.Writer) io.WriteCloser {
+	if strings.HasSuffix(path, ".gz") {
+		return gzip.NewWriter(w)
+	}
+	return nopWriteCloser{bufio.NewWriter(w)}
+}
+
+type nopWriteCloser struct {
+	*bufio.Writer
+}
+
+func (n nopWriteCloser) Close() error { return n.Flush() }
+
+// newBackupReader decompresses the backup when path ends in .gz
+func newBackupReader(path string, r io.Reader) (io.Reader, error) {
+	if strings.HasSuffix(path, ".gz") {
+		return gzip.NewReader(r)
+	}
+	return r, nil
+}
+
+// formatRows lists the rows per backed up table, e.g. "120 messages, 3 suppressions"
+func formatRows(rows map[string]int64) string {
+	parts := make([]string, 0, len(rows))
+	for _, table := range db.BackupTables {
+		if count, ok := rows[table]; ok {
+			parts = append(parts, fmt.Sprintf("%d %s", count, table))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
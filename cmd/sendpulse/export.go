@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/boratanrikulu/sendpulse/internal/export"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func exportCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Streams messages matching the filters to a CSV or JSON lines file",
+		Action: func(c *cli.Context) error {
+			format := c.String("format")
+			if err := export.ValidFormat(format); err != nil {
+				return err
+			}
+
+			filter, err := messageFilter(c)
+			if err != nil {
+				return err
+			}
+
+			_, dbc, err := connect(c)
+			if err != nil {
+				return err
+			}
+			defer dbc.Close()
+
+			var out io.Writer = os.Stdout
+			if path := c.String("out"); path != "" && path != "-" {
+				file, err := os.Create(path)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+
+			buffered := bufio.NewWriter(out)
+			writer := export.NewWriter(format, buffered)
+
+			var count int
+			err = service.NewMessageService(dbc).ExportMessages(c.Context, filter, func(msg dto.MessageResponse) error {
+				count++
+				return writer.Write(msg)
+			})
+			if err != nil {
+				return err
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Exported %d messages\n", count)
+			return nil
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+			statusFlag(),
+			fromFlag(),
+			toFlag(),
+			tagFlag(),
+			metadataFlag("Only include messages with this metadata value"),
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Export format: csv or jsonl",
+				Value: export.FormatCSV,
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Output file (default: stdout)",
+			},
+		},
+	}
+}
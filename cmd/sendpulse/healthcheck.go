@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func healthcheckCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "healthcheck",
+		Usage: "Checks the readiness of a running server, exits non-zero on failure",
+		Description: "Calls the /readyz endpoint of the local server, or pings the database directly with --db.\n" +
+			"Meant for Docker HEALTHCHECK and Kubernetes exec probes.",
+		Action: func(c *cli.Context) error {
+			ctx, cancel := context.WithTimeout(c.Context, c.Duration("timeout"))
+			defer cancel()
+
+			if c.Bool("db") {
+				return checkDatabase(ctx, c.String("config"))
+			}
+
+			url := c.String("url")
+			if url == "" {
+				cfg, err := config.NewConfig(c.String("config"))
+				if err != nil {
+					return err
+				}
+				url = localURL(cfg.Server.Address) + "/readyz"
+			}
+			return checkReadiness(ctx, url)
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+			&cli.StringFlag{
+				Name:  "url",
+				Usage: "Readiness endpoint to call (default: /readyz on the configured server address)",
+			},
+			&cli.BoolFlag{
+				Name:  "db",
+				Usage: "Ping the database directly instead of calling the server",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Maximum time to wait for the check",
+				Value: 5 * time.Second,
+			},
+		},
+	}
+}
+
+func checkReadiness(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("readiness check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var readiness dto.ReadinessResponse
+		if err := json.NewDecoder(resp.Body).Decode(&readiness); err == nil && len(readiness.Checks) > 0 {
+			return fmt.Errorf("not ready (status %d): %v", resp.StatusCode, readiness.Checks)
+		}
+		return fmt.Errorf("not ready (status %d)", resp.StatusCode)
+	}
+
+	fmt.Println("ok")
+	return nil
+}
+
+func checkDatabase(ctx context.Context, path string) error {
+	cfg, err := config.NewConfig(path)
+	if err != nil {
+		return err
+	}
+
+	dbc, err := db.Connect(cfg.Database.DSN)
+	if err != nil {
+		return fmt.Errorf("database check failed: %w", err)
+	}
+	defer dbc.Close()
+
+	if err := dbc.PingContext(ctx); err != nil {
+		return fmt.Errorf("database check failed: %w", err)
+	}
+
+	fmt.Println("ok")
+	return nil
+}
+
+// localURL turns a listen address like ":8080" or "0.0.0.0:8080" into a loopback base URL
+func localURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
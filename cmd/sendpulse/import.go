@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+
+	"github.com/urfave/cli/v2"
+)
+
+func importCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "import",
+		Usage: "Bulk enqueues messages from a CSV or JSON file",
+		Description: "CSV files need a header with to and content columns (priority and send_at are optional).\n" +
+			"JSON files contain either an array of messages or one message object per line.\n" +
+			"Rejected and duplicate rows are written to the error report instead of stopping the import.",
+		Action: func(c *cli.Context) error {
+			path := c.String("file")
+			format := c.String("format")
+			if format == "" {
+				format = formatFromExtension(path)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			info, err := file.Stat()
+			if err != nil {
+				return err
+			}
+			progress := &progressReader{reader: file, total: info.Size()}
+
+			reader, err := ingest.NewReader(format, progress)
+			if err != nil {
+				return err
+			}
+
+			cfg, dbc, err := connect(c)
+			if err != nil {
+				return err
+			}
+			defer dbc.Close()
+
+			report := &rejectionReport{path: c.String("errors")}
+			if report.path == "" {
+				report.path = strings.TrimSuffix(path, filepath.Ext(path)) + ".rejected.csv"
+			}
+			defer report.Close()
+
+			importer := ingest.NewImporter(dbc, c.Int("batch-size"))
+			importer.SetIDStrategy(db.IDStrategy(cfg.Database.IDStrategy))
+			importer.OnRejected = report.Write
+			importer.OnProgress = func(r ingest.Result) {
+				progress.print(r)
+			}
+
+			result, err := importer.Import(c.Context, reader)
+			progress.print(*result)
+			fmt.Fprintln(os.Stderr)
+			report.Close()
+			if err != nil {
+				return fmt.Errorf("import stopped after %d messages: %w", result.Imported, err)
+			}
+			if report.err != nil {
+				return fmt.Errorf("failed to write error report: %w", report.err)
+			}
+
+			fmt.Printf("Imported %d of %d messages (%d rejected, %d duplicates)\n",
+				result.Imported, result.Read, result.Rejected, result.Duplicates)
+			if result.Rejected > 0 {
+				fmt.Printf("Rejected rows written to %s\n", report.path)
+			}
+			return nil
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Usage:    "File to import",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Input format: csv or json (default: detected from the file extension)",
+			},
+			&cli.IntFlag{
+				Name:  "batch-size",
+				Usage: "Number of messages inserted per statement",
+				Value: ingest.DefaultBatchSize,
+			},
+			&cli.StringFlag{
+				Name:  "errors",
+				Usage: "Error report location (default: <file>.rejected.csv)",
+			},
+		},
+	}
+}
+
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson":
+		return ingest.FormatJSON
+	}
+	return ingest.FormatCSV
+}
+
+// progressReader counts the bytes read from the input to render a progress bar
+type progressReader struct {
+	reader io.Reader
+	total  int64
+	read   int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+	return n, err
+}
+
+func (p *progressReader) print(r ingest.Result) {
+	const width = 30
+
+	percent := 100
+	if p.total > 0 {
+		percent = int(p.read * 100 / p.total)
+	}
+	filled := percent * width / 100
+
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3d%% %d imported, %d rejected",
+		strings.Repeat("=", filled), strings.Repeat(" ", width-filled), percent, r.Imported, r.Rejected)
+}
+
+// rejectionReport writes rejected rows to a CSV file, created on the first rejection
+type rejectionReport struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	err    error
+}
+
+func (r *rejectionReport) Write(rejection ingest.Rejection) {
+	if r.err != nil {
+		return
+	}
+
+	if r.file == nil {
+		r.file, r.err = os.Create(r.path)
+		if r.err != nil {
+			return
+		}
+		r.writer = csv.NewWriter(r.file)
+		r.err = r.writer.Write([]string{"line", "to", "content", "reason"})
+	}
+
+	if r.err == nil {
+		r.err = r.writer.Write([]string{
+			strconv.Itoa(rejection.Line), rejection.To, rejection.Content, rejection.Reason,
+		})
+	}
+}
+
+func (r *rejectionReport) Close() {
+	if r.writer == nil {
+		return
+	}
+	r.writer.Flush()
+	if err := r.writer.Error(); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.file.Close()
+	r.writer = nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+
+	"github.com/uptrace/bun"
+	"github.com/urfave/cli/v2"
+)
+
+func loadtestCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "loadtest",
+		Usage: "Seeds messages and sends them through the scheduler to a built-in mock webhook, reporting throughput and latencies",
+		Description: "The scheduler runs in this process against the configured database, the webhook URL of the config is replaced\n" +
+			"by a local mock server. Seeded messages are deleted afterwards unless --keep is given. Refuses to run in prod mode.",
+		Action: func(c *cli.Context) error {
+			cfg, dbc, err := connect(c)
+			if err != nil {
+				return err
+			}
+			defer dbc.Close()
+
+			if cfg.Server.Mode == config.ModeProd {
+				return fmt.Errorf("loadtest cannot run in prod mode")
+			}
+			if c.Int("count") < 1 {
+				return fmt.Errorf("--count must be at least 1")
+			}
+			if rate := c.Float64("error-rate"); rate < 0 || rate > 1 {
+				return fmt.Errorf("--error-rate must be between 0 and 1")
+			}
+
+			pending, err := db.CountMessages(c.Context, dbc, db.MessageFilter{Status: db.MessageStatusPending})
+			if err != nil {
+				return err
+			}
+			if pending > 0 && !c.Bool("allow-pending") {
+				return fmt.Errorf("there are %d pending messages which would be sent to the mock webhook, pass --allow-pending to include them", pending)
+			}
+
+			mock := webhook.NewMockHandler(webhook.MockOptions{
+				Latency:   c.Duration("latency"),
+				Jitter:    c.Duration("jitter"),
+				ErrorRate: c.Float64("error-rate"),
+			})
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				return err
+			}
+			mockServer := &http.Server{Handler: mock}
+			defer mockServer.Close()
+
+			// a mock webhook that stops serving fails the run instead of leaving every send failing
+			ctx, cancel := context.WithCancelCause(c.Context)
+			defer cancel(nil)
+			go func() {
+				if err := mockServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					config.Log().WithError(err).Error("Mock webhook stopped serving")
+					cancel(fmt.Errorf("mock webhook: %w", err))
+				}
+			}()
+
+			cfg.Webhook.URL = "http://" + listener.Addr().String()
+			cfg.Messaging.Enabled = true
+			cfg.Messaging.Interval = c.Duration("interval")
+			cfg.Messaging.BatchSize = c.Int("batch-size")
+			cfg.Messaging.MaxRetries = c.Int("max-retries")
+			cfg.Messaging.RetryDelay = c.Duration("retry-delay")
+
+			var lastID int64
+			err = dbc.NewSelect().Model((*db.Message)(nil)).ColumnExpr("COALESCE(MAX(id), 0)").Scan(ctx, &lastID)
+			if err != nil {
+				return err
+			}
+
+			if !c.Bool("keep") {
+				defer cleanupLoadtest(dbc, lastID)
+			}
+			count := c.Int("count")
+			if err := seedMessages(ctx, dbc, seedOptions{Count: count, BatchSize: 1000, Workers: defaultSeedWorkers, PendingPercent: 100}); err != nil {
+				return err
+			}
+
+			scheduler := service.NewScheduler(dbc, cfg)
+			start := time.Now()
+			if _, err := scheduler.Start(ctx); err != nil {
+				return err
+			}
+
+			done, err := waitForLoadtest(ctx, dbc, lastID, count, c.Duration("timeout"))
+			elapsed := time.Since(start)
+			shutdownScheduler(cfg, scheduler)
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				return cmp.Or(context.Cause(ctx), err)
+			}
+
+			return printLoadtestReport(c.Context, dbc, lastID, count, done, elapsed, mock.Stats())
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+			&cli.IntFlag{
+				Name:  "count",
+				Usage: "Number of messages to seed and send",
+				Value: 1000,
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Scheduler interval used during the test",
+				Value: time.Second,
+			},
+			&cli.IntFlag{
+				Name:  "batch-size",
+				Usage: "Scheduler batch size used during the test",
+				Value: 100,
+			},
+			&cli.IntFlag{
+				Name:  "max-retries",
+				Usage: "Webhook retries per message used during the test",
+				Value: 0,
+			},
+			&cli.DurationFlag{
+				Name:  "retry-delay",
+				Usage: "Delay between webhook retries used during the test",
+				Value: 100 * time.Millisecond,
+			},
+			&cli.DurationFlag{
+				Name:  "latency",
+				Usage: "Base response latency of the mock webhook",
+				Value: 50 * time.Millisecond,
+			},
+			&cli.DurationFlag{
+				Name:  "jitter",
+				Usage: "Random latency added on top of --latency",
+			},
+			&cli.Float64Flag{
+				Name:  "error-rate",
+				Usage: "Share of mock webhook requests answered with a 500, between 0 and 1",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Stop the test after this long even if messages are left",
+				Value: 10 * time.Minute,
+			},
+			&cli.BoolFlag{
+				Name:  "allow-pending",
+				Usage: "Run even if the queue already has pending messages (they are sent to the mock too)",
+			},
+			&cli.BoolFlag{
+				Name:  "keep",
+				Usage: "Keep the seeded messages instead of deleting them afterwards",
+			},
+		},
+	}
+}
+
+// loadtestQuery selects the messages seeded by the current run
+func loadtestQuery(dbc *bun.DB, lastID int64) *bun.SelectQuery {
+	return dbc.NewSelect().Model((*db.Message)(nil)).Where("id > ?", lastID)
+}
+
+// waitForLoadtest polls until every seeded message is sent or failed and returns how many are done
+func waitForLoadtest(ctx context.Context, dbc *bun.DB, lastID int64, count int, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var done int
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return done, ctx.Err()
+		case <-ticker.C:
+		}
+
+		n, err := loadtestQuery(dbc, lastID).
+			Where("status IN (?)", bun.In(append([]db.MessageStatus{db.MessageStatusFailed}, db.SentStatuses...))).
+			Count(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return done, err
+		}
+		done = n
+
+		fmt.Printf("\rProcessed %d/%d messages", done, count)
+		if done >= count {
+			fmt.Println()
+			return done, nil
+		}
+	}
+}
+
+func printLoadtestReport(ctx context.Context, dbc *bun.DB, lastID int64, count, done int, elapsed time.Duration, stats webhook.MockStats) error {
+	var sent []db.Message
+	err := loadtestQuery(dbc, lastID).
+		Column("created_at", "sent_at").
+		Where("status IN (?)", bun.In(db.SentStatuses)).
+		Scan(ctx, &sent)
+	if err != nil {
+		return err
+	}
+
+	latencies := make([]time.Duration, 0, len(sent))
+	for _, msg := range sent {
+		if msg.SentAt != nil {
+			latencies = append(latencies, msg.SentAt.Sub(msg.CreatedAt))
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("\nMessages:      %d seeded, %d sent, %d failed, %d left\n", count, len(sent), done-len(sent), count-done)
+	fmt.Printf("Duration:      %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("Throughput:    %.1f messages/s\n", float64(done)/elapsed.Seconds())
+	fmt.Printf("Webhook:       %d requests, %d injected errors\n", stats.Requests, stats.Errors)
+	fmt.Printf("Latency (created to sent): p50 %s, p95 %s, p99 %s, max %s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.95), percentile(latencies, 0.99), percentile(latencies, 1))
+	return nil
+}
+
+// percentile returns the p-th percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Millisecond)
+}
+
+// cleanupLoadtest deletes the messages seeded by the current run
+func cleanupLoadtest(dbc *bun.DB, lastID int64) {
+	res, err := dbc.NewDelete().Model((*db.Message)(nil)).Where("id > ?", lastID).Exec(context.Background())
+	if err != nil {
+		config.Log().Errorf("Failed to delete load test messages: %v", err)
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		fmt.Printf("Deleted %d load test messages\n", n)
+	}
+}
@@ -1,25 +1,53 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // recipient and report timezones on hosts without a zoneinfo database
 
 	_ "github.com/boratanrikulu/sendpulse/docs" // Swagger docs
 
 	"github.com/urfave/cli/v2"
 )
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description Required on /api/v1 endpoints (except health) when server.api_key or server.api_keys are set
 func main() {
 	app := &cli.App{
-		Name:  "sendpulse",
-		Usage: "Robust messaging automation system",
+		Name:                 "sendpulse",
+		Usage:                "Robust messaging automation system",
+		EnableBashCompletion: true,
 		Commands: []*cli.Command{
 			serverCMD(),
+			workerCMD(),
+			consumerCMD(),
 			databaseCMD(),
+			messageCMD(),
+			messagingCMD(),
+			importCMD(),
+			suppressionCMD(),
+			exportCMD(),
+			archiveCMD(),
+			statsCMD(),
+			loadtestCMD(),
+			mockWebhookCMD(),
+			configCMD(),
+			topCMD(),
+			healthcheckCMD(),
+			completionCMD(),
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	// Commands receive a context that is cancelled on SIGINT/SIGTERM for graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
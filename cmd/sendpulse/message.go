@@ -0,0 +1,824 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/client"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func messageCMD() *cli.Command {
+	return &cli.Command{
+		Name:    "message",
+		Aliases: []string{"messages", "m"},
+		Usage:   "message queue operations",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "send",
+				Usage: "Enqueues a single message",
+				Action: func(c *cli.Context) error {
+					req := &dto.CreateMessageRequest{
+						To:          c.String("to"),
+						Content:     c.String("content"),
+						Priority:    c.Int("priority"),
+						From:        c.String("from"),
+						SendAtLocal: c.String("send-at-local"),
+						Timezone:    c.String("timezone"),
+					}
+					if sendAt := c.String("send-at"); sendAt != "" {
+						t, err := time.Parse(time.RFC3339, sendAt)
+						if err != nil {
+							return fmt.Errorf("invalid --send-at value, expected RFC3339: %w", err)
+						}
+						req.SendAt = &t
+					}
+					metadata, err := query.ParseMetadata(c.StringSlice("metadata"))
+					if err != nil {
+						return err
+					}
+					if len(metadata) > 0 || len(c.StringSlice("tag")) > 0 {
+						req.Metadata = make(map[string]any, len(metadata)+1)
+						for key, value := range metadata {
+							req.Metadata[key] = value
+						}
+						if tags := c.StringSlice("tag"); len(tags) > 0 {
+							req.Metadata[db.MetadataTags] = tags
+						}
+					}
+
+					var response *dto.SingleMessageResponse
+					if c.Bool("remote") {
+						if response, err = newRemoteClient(c).CreateMessage(c.Context, req); err != nil {
+							return err
+						}
+					} else {
+						cfg, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						q, err := newIngestQueue(cfg, dbc, nil)
+						if err != nil {
+							return err
+						}
+						response, err = service.NewMessageServiceWithQueue(dbc, q).CreateMessage(c.Context, req)
+						if err != nil {
+							return err
+						}
+					}
+
+					fmt.Printf("Message %d queued for %s (priority: %d, %d %s segments)\n",
+						response.Message.ID, response.Message.To, response.Message.Priority, response.Message.Segments, response.Message.Encoding)
+					if response.Message.ScheduledAt != nil {
+						fmt.Printf("Scheduled for %s\n", response.Message.ScheduledAt.Format(time.RFC3339))
+					}
+					return nil
+				},
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "Recipient phone number in E.164 format",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "content",
+						Usage:    "Message content",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "priority",
+						Usage: "Message priority, higher values are sent first",
+						Value: 0,
+					},
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "Registered sender ID or number to send from instead of the sender ID of the route",
+					},
+					&cli.StringFlag{
+						Name:  "send-at",
+						Usage: "Earliest time to send the message (RFC3339)",
+					},
+					&cli.StringFlag{
+						Name:  "send-at-local",
+						Usage: "Send time in the recipient's --timezone, HH:MM for its next occurrence or YYYY-MM-DDTHH:MM",
+					},
+					&cli.StringFlag{
+						Name:  "timezone",
+						Usage: "IANA timezone of the recipient, e.g. Europe/Istanbul",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "Tag of the message (repeatable)",
+					},
+					metadataFlag("Metadata of the message"),
+				}, remoteFlags()...),
+			},
+			{
+				Name:  "list",
+				Usage: "Lists messages matching the given filters, newest first unless --sort is created_at",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+
+					filter, err := messageFilter(c)
+					if err != nil {
+						return err
+					}
+
+					page := query.Page{
+						Number: c.Int("page"),
+						Size:   c.Int("page-size"),
+						Sort:   c.String("sort"),
+						Cursor: c.String("cursor"),
+					}
+					if _, err := page.Normalize(); err != nil {
+						return err
+					}
+
+					var response *dto.MessagesListResponse
+					if c.Bool("remote") {
+						// the API only lists sent messages when no filter, sort or cursor is given
+						response, err = newRemoteClient(c).ListMessages(c.Context, client.ListOptions{
+							Status:       string(filter.Status),
+							From:         filter.From,
+							To:           filter.To,
+							Tag:          filter.Tag,
+							Metadata:     filter.Metadata,
+							DeadLettered: filter.DeadLettered,
+							Acknowledged: filter.Acknowledged,
+							Page:         page.Number,
+							PageSize:     page.Size,
+							Sort:         page.Sort,
+							Cursor:       page.Cursor,
+						})
+						if err != nil {
+							return err
+						}
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).ListMessages(c.Context, filter, page)
+						if err != nil {
+							return err
+						}
+					}
+
+					if format == outputJSON {
+						return printJSON(response)
+					}
+					if err := printMessages(format, response.Messages); err != nil {
+						return err
+					}
+					if page.Cursor != "" {
+						fmt.Printf("\nShowing %d of %d messages\n", len(response.Messages), response.Total)
+					} else {
+						fmt.Printf("\nPage %d, showing %d of %d messages\n", response.Page, len(response.Messages), response.Total)
+					}
+					if response.NextCursor != "" {
+						fmt.Printf("Next page: --cursor %s\n", response.NextCursor)
+					}
+					return nil
+				},
+				Flags: append([]cli.Flag{
+					statusFlag(),
+					fromFlag(),
+					toFlag(),
+					tagFlag(),
+					metadataFlag("Only include messages with this metadata value"),
+					deadLetteredFlag(),
+					acknowledgedFlag(),
+					&cli.IntFlag{
+						Name:  "page",
+						Usage: "Page number",
+						Value: query.MinPage,
+					},
+					&cli.IntFlag{
+						Name:  "page-size",
+						Usage: fmt.Sprintf("Number of messages per page (max: %d)", query.MaxPageSize),
+						Value: query.DefaultPageSize,
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: fmt.Sprintf("Order of the messages, %s (newest first) or %s (oldest first)", query.SortNewest, query.SortOldest),
+						Value: query.SortNewest,
+					},
+					&cli.StringFlag{
+						Name:  "cursor",
+						Usage: "Continue after the page printing this cursor instead of --page",
+					},
+					outputFlag(),
+				}, remoteFlags()...),
+			},
+			{
+				Name:  "duplicates",
+				Usage: "Reports recipients given the same content more than once, likely double-sends",
+				Description: "Messages to the same recipient with the same content are grouped when each was created within\n" +
+					"--window of the one before. Without --from the last 24 hours are examined.",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+					from, err := query.ParseDate("from", c.String("from"))
+					if err != nil {
+						return err
+					}
+					to, err := query.ParseDate("to", c.String("to"))
+					if err != nil {
+						return err
+					}
+
+					var response *dto.DuplicatesResponse
+					if c.Bool("remote") {
+						response, err = newRemoteClient(c).Duplicates(c.Context, from, to, c.Duration("window"), c.Int("limit"))
+					} else {
+						_, dbc, connectErr := connect(c)
+						if connectErr != nil {
+							return connectErr
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).Duplicates(c.Context, from, to, c.Duration("window"), c.Int("limit"))
+					}
+					if err != nil {
+						return err
+					}
+
+					if format == outputJSON {
+						return printJSON(response)
+					}
+					return printDuplicates(response)
+				},
+				Flags: append([]cli.Flag{
+					fromFlag(),
+					toFlag(),
+					&cli.DurationFlag{
+						Name:  "window",
+						Usage: "Longest gap between two messages counted as a double-send",
+						Value: service.DefaultDuplicateWindow,
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: fmt.Sprintf("Recipient and content pairs examined, the ones repeated last first (max: %d)", service.MaxDuplicateLimit),
+						Value: service.DefaultDuplicateLimit,
+					},
+					outputFlag(),
+				}, remoteFlags()...),
+			},
+			{
+				Name:      "recipient",
+				Usage:     "Shows the delivery statistics of a phone number",
+				ArgsUsage: "<phone>",
+				Description: "Counts the messages to the number by status and shows the last success and failure, the failures\n" +
+					"in a row since the last success and whether the number is suppressed.",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected exactly one phone number")
+					}
+
+					var response *dto.RecipientStatsResponse
+					var err error
+					if c.Bool("remote") {
+						response, err = newRemoteClient(c).RecipientStats(c.Context, c.Args().First())
+					} else {
+						_, dbc, connectErr := connect(c)
+						if connectErr != nil {
+							return connectErr
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).RecipientStats(c.Context, c.Args().First())
+					}
+					if err != nil {
+						return err
+					}
+
+					if format == outputJSON {
+						return printJSON(response)
+					}
+					return printRecipientStats(response)
+				},
+				Flags: append([]cli.Flag{
+					outputFlag(),
+				}, remoteFlags()...),
+			},
+			{
+				Name:      "get",
+				Usage:     "Shows a single message",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected exactly one message ID")
+					}
+
+					var response *dto.SingleMessageResponse
+					if c.Bool("remote") {
+						id, err := strconv.ParseInt(c.Args().First(), 10, 64)
+						if err != nil {
+							return fmt.Errorf("%w: %s", service.ErrInvalidMessageID, err.Error())
+						}
+						if response, err = newRemoteClient(c).GetMessage(c.Context, id); err != nil {
+							return err
+						}
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).GetMessageByID(c.Context, c.Args().First())
+						if err != nil {
+							return err
+						}
+					}
+
+					return printMessage(format, response.Message)
+				},
+				Flags: append([]cli.Flag{
+					outputFlag(),
+				}, remoteFlags()...),
+			},
+			{
+				Name:      "retry",
+				Usage:     "Requeues a failed message, or all failed messages matching the filters",
+				ArgsUsage: "[id]",
+				Action: func(c *cli.Context) error {
+					if c.NArg() > 1 {
+						return fmt.Errorf("expected at most one message ID")
+					}
+					if c.NArg() == 0 && !c.Bool("all") {
+						return fmt.Errorf("pass a message ID or --all to retry every failed message matching the filters")
+					}
+
+					filter, err := messageFilter(c)
+					if err != nil {
+						return err
+					}
+
+					_, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					messageService := service.NewMessageService(dbc)
+					dryRun := c.Bool("dry-run")
+
+					var count int
+					if c.NArg() == 1 {
+						count, err = messageService.RetryMessage(c.Context, c.Args().First(), dryRun)
+					} else {
+						count, err = messageService.RetryFailedMessages(c.Context, filter, dryRun)
+					}
+					if err != nil {
+						return err
+					}
+
+					if dryRun {
+						fmt.Printf("Dry run: %d failed message(s) would be requeued\n", count)
+						return nil
+					}
+					fmt.Printf("Requeued %d failed message(s)\n", count)
+					return nil
+				},
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Retry all failed messages matching the filters",
+					},
+					fromFlag(),
+					toFlag(),
+					deadLetteredFlag(),
+					acknowledgedFlag(),
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print how many messages would be requeued",
+					},
+				},
+			},
+			{
+				Name:      "release",
+				Usage:     "Releases a message quarantined by the content policy so it is sent",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a message ID")
+					}
+
+					_, dbc, err := connect(c)
+					if err != nil {
+						return err
+					}
+					defer dbc.Close()
+
+					response, err := service.NewMessageService(dbc).ReleaseMessage(c.Context, c.Args().First())
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Released message %d\n", response.Message.ID)
+					return nil
+				},
+			},
+			{
+				Name:      "prioritize",
+				Usage:     "Moves a pending message to the front of the queue so it is sent next",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a message ID")
+					}
+
+					var response *dto.SingleMessageResponse
+					if c.Bool("remote") {
+						id, err := strconv.ParseInt(c.Args().First(), 10, 64)
+						if err != nil {
+							return fmt.Errorf("%w: %s", service.ErrInvalidMessageID, err.Error())
+						}
+						if response, err = newRemoteClient(c).PrioritizeMessage(c.Context, id); err != nil {
+							return err
+						}
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).PrioritizeMessage(c.Context, c.Args().First())
+						if err != nil {
+							return err
+						}
+					}
+					fmt.Printf("Prioritized message %d (priority: %d)\n", response.Message.ID, response.Message.Priority)
+					return nil
+				},
+				Flags: remoteFlags(),
+			},
+			{
+				Name:      "cancel",
+				Usage:     "Cancels a pending message, or with --remote a sending one whose webhook call was not issued yet",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a message ID")
+					}
+
+					var response *dto.SingleMessageResponse
+					if c.Bool("remote") {
+						id, err := strconv.ParseInt(c.Args().First(), 10, 64)
+						if err != nil {
+							return fmt.Errorf("%w: %s", service.ErrInvalidMessageID, err.Error())
+						}
+						if response, err = newRemoteClient(c).CancelMessage(c.Context, id); err != nil {
+							return err
+						}
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						// only the server sending a message can cancel its send
+						response, err = service.NewMessageService(dbc).CancelMessage(c.Context, c.Args().First())
+						if err != nil {
+							return err
+						}
+					}
+					fmt.Printf("Cancelled message %d\n", response.Message.ID)
+					return nil
+				},
+				Flags: remoteFlags(),
+			},
+			{
+				Name:      "triage",
+				Usage:     "Sets the note of a failed message and acknowledges its failure, so the next on-call knows it was investigated",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a message ID")
+					}
+
+					req := &dto.TriageMessageRequest{}
+					if c.IsSet("note") {
+						note := c.String("note")
+						req.Note = &note
+					}
+					if c.IsSet("acknowledged") {
+						acknowledged := c.Bool("acknowledged")
+						req.Acknowledged = &acknowledged
+					}
+
+					var response *dto.SingleMessageResponse
+					if c.Bool("remote") {
+						id, err := strconv.ParseInt(c.Args().First(), 10, 64)
+						if err != nil {
+							return fmt.Errorf("%w: %s", service.ErrInvalidMessageID, err.Error())
+						}
+						if response, err = newRemoteClient(c).TriageMessage(c.Context, id, req); err != nil {
+							return err
+						}
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						response, err = service.NewMessageService(dbc).TriageMessage(c.Context, c.Args().First(), req)
+						if err != nil {
+							return err
+						}
+					}
+					return printMessage(outputTable, response.Message)
+				},
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "note",
+						Usage: "Operator note of the failure, an empty note clears it",
+					},
+					&cli.BoolFlag{
+						Name:  "acknowledged",
+						Usage: "Acknowledge the failure, --acknowledged=false withdraws the acknowledgment",
+					},
+				}, remoteFlags()...),
+			},
+			{
+				Name:  "replay",
+				Usage: "Clones and re-enqueues the messages sent within a window, e.g. after a provider delivery blackout",
+				Action: func(c *cli.Context) error {
+					from, err := query.ParseDate("from", c.String("from"))
+					if err != nil {
+						return err
+					}
+					to, err := query.ParseDate("to", c.String("to"))
+					if err != nil {
+						return err
+					}
+					req := &dto.ReplayRequest{
+						From:     *from,
+						To:       *to,
+						Provider: c.String("provider"),
+						Tenant:   c.String("tenant"),
+						Campaign: c.String("campaign"),
+						DryRun:   true,
+					}
+
+					var replay func(context.Context, *dto.ReplayRequest) (*dto.ReplayResponse, error)
+					if c.Bool("remote") {
+						replay = newRemoteClient(c).ReplayMessages
+					} else {
+						cfg, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						q, err := newIngestQueue(cfg, dbc, nil)
+						if err != nil {
+							return err
+						}
+						replay = service.NewReplayService(dbc, q, cfg.Replay).Replay
+					}
+
+					// the dry run count has to be confirmed, the replay is refused when it changed in between
+					response, err := replay(c.Context, req)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%d message(s) sent between %s and %s match\n", response.Matched,
+						req.From.Format(time.RFC3339), req.To.Format(time.RFC3339))
+					if c.Bool("dry-run") || response.Matched == 0 {
+						return nil
+					}
+					if !c.Bool("yes") {
+						if err := confirm(fmt.Sprintf("This will send %d message(s) again.", response.Matched), "replay"); err != nil {
+							return err
+						}
+					}
+
+					req.DryRun = false
+					req.ExpectedCount = response.Matched
+					if response, err = replay(c.Context, req); err != nil {
+						return err
+					}
+					fmt.Printf("Replayed %d message(s)\n", response.Replayed)
+					return nil
+				},
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Replay messages sent at or after this time (YYYY-MM-DD or RFC3339)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "Replay messages sent before this time (YYYY-MM-DD or RFC3339)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "provider",
+						Usage: "Only replay messages sent through this provider",
+					},
+					&cli.StringFlag{
+						Name:  "tenant",
+						Usage: "Only replay messages of this tenant",
+					},
+					&cli.StringFlag{
+						Name:  "campaign",
+						Usage: "Only replay messages of this campaign",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print how many messages would be replayed",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt",
+					},
+				}, remoteFlags()...),
+			},
+			{
+				Name:  "erase",
+				Usage: "Deletes or anonymizes the messages to a phone number, e.g. for a right to be forgotten request",
+				Description: "anonymize keeps the messages for statistics and costs without the number, content, provider responses\n" +
+					"and metadata, delete removes them. The tracked links in the messages are deleted in both modes. The\n" +
+					"suppression of the number is kept unless --include-suppression is given. An audit record is stored.",
+				Action: func(c *cli.Context) error {
+					req := &dto.ErasureRequest{
+						Phone:              c.String("phone"),
+						Mode:               c.String("mode"),
+						IncludeSuppression: c.Bool("include-suppression"),
+						Reference:          c.String("reference"),
+						DryRun:             true,
+					}
+
+					var erase func(context.Context, *dto.ErasureRequest) (*dto.ErasureResponse, error)
+					if c.Bool("remote") {
+						erase = newRemoteClient(c).Erase
+					} else {
+						_, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						erasures := service.NewErasureService(dbc)
+						erase = func(ctx context.Context, req *dto.ErasureRequest) (*dto.ErasureResponse, error) {
+							return erasures.Erase(ctx, req, service.ErasureRequestedByCLI)
+						}
+					}
+
+					response, err := erase(c.Context, req)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%d message(s) and %d tracked link(s) of %s match\n", response.Messages, response.Links, req.Phone)
+					if c.Bool("dry-run") {
+						return nil
+					}
+					if !c.Bool("yes") {
+						if err := confirm(fmt.Sprintf("This will %s the messages of %s, it cannot be undone.", req.Mode, req.Phone), req.Phone); err != nil {
+							return err
+						}
+					}
+
+					req.DryRun = false
+					if response, err = erase(c.Context, req); err != nil {
+						return err
+					}
+					fmt.Printf("Erased %d message(s) and %d tracked link(s) (%s), audit record %d\n",
+						response.Messages, response.Links, req.Mode, response.Erasure.ID)
+					if response.Suppressed {
+						fmt.Println("The number stays suppressed")
+					}
+					return nil
+				},
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "phone",
+						Usage:    "Phone number to erase (E.164)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "mode",
+						Usage: "anonymize or delete",
+						Value: string(db.ErasureModeAnonymize),
+					},
+					&cli.BoolFlag{
+						Name:  "include-suppression",
+						Usage: "Also lift the suppression of the number",
+					},
+					&cli.StringFlag{
+						Name:  "reference",
+						Usage: "Reference stored with the audit record, e.g. the ticket of the request",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print how many messages and links would be erased",
+					},
+					&cli.BoolFlag{
+						Name:  "yes",
+						Usage: "Skip the confirmation prompt",
+					},
+				}, remoteFlags()...),
+			},
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+		},
+	}
+}
+
+func statusFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "status",
+		Aliases: []string{"s"},
+		Usage:   "Only include messages with this status (pending, sending, accepted, sent, delivered, unconfirmed, accepted_without_id, failed, blocked, quarantined, cancelled)",
+	}
+}
+
+func fromFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "from",
+		Usage: "Only include messages created at or after this date (YYYY-MM-DD or RFC3339)",
+	}
+}
+
+func toFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "to",
+		Usage: "Only include messages created before this date (YYYY-MM-DD or RFC3339)",
+	}
+}
+
+func tagFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "tag",
+		Usage: "Only include messages with this tag in their metadata",
+	}
+}
+
+func deadLetteredFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "dead-lettered",
+		Usage: "Only include the failed messages in the dead-letter queue",
+	}
+}
+
+func acknowledgedFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "acknowledged",
+		Usage: "Only include the messages whose failure was acknowledged (true) or not acknowledged yet (false)",
+	}
+}
+
+func metadataFlag(usage string) cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:  "metadata",
+		Usage: usage + " (key=value, repeatable)",
+	}
+}
+
+// messageFilter builds a message filter from the --status, --from, --to, --tag, --metadata, --dead-lettered and
+// --acknowledged flags
+func messageFilter(c *cli.Context) (db.MessageFilter, error) {
+	metadata, err := query.ParseMetadata(c.StringSlice("metadata"))
+	if err != nil {
+		return db.MessageFilter{}, err
+	}
+
+	return query.Filters{
+		Status:       c.String("status"),
+		From:         c.String("from"),
+		To:           c.String("to"),
+		Tag:          c.String("tag"),
+		Metadata:     metadata,
+		DeadLettered: c.Bool("dead-lettered"),
+		Acknowledged: c.String("acknowledged"),
+	}.MessageFilter()
+}
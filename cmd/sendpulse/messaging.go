@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boratanrikulu/sendpulse/pkg/client"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// messagingCMD controls the scheduler of a running server, it always goes through the REST API
+func messagingCMD() *cli.Command {
+	control := func(action func(*client.Client, context.Context) (*dto.MessagingControlResponse, error)) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			response, err := action(newRemoteClient(c), c.Context)
+			if err != nil {
+				return err
+			}
+			fmt.Println(response.Message)
+			return nil
+		}
+	}
+
+	return &cli.Command{
+		Name:  "messaging",
+		Usage: "controls automatic message sending of a running server",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "start",
+				Usage:  "Starts automatic message sending",
+				Action: control((*client.Client).StartMessaging),
+			},
+			{
+				Name:   "stop",
+				Usage:  "Stops automatic message sending",
+				Action: control((*client.Client).StopMessaging),
+			},
+			{
+				Name:  "status",
+				Usage: "Shows the scheduler status",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+
+					response, err := newRemoteClient(c).MessagingStatus(c.Context)
+					if err != nil {
+						return err
+					}
+
+					if format == outputJSON {
+						return printJSON(response)
+					}
+					state := "stopped"
+					if response.Enabled {
+						state = "running"
+					}
+					fmt.Printf("Scheduler:   %s\n", state)
+					fmt.Printf("Interval:    %s\n", response.Interval)
+					fmt.Printf("Batch size:  %d\n", response.BatchSize)
+					fmt.Printf("Max retries: %d\n", response.MaxRetries)
+					fmt.Printf("Retry delay: %s\n", response.RetryDelay)
+					return nil
+				},
+				Flags: []cli.Flag{
+					outputFlag(),
+				},
+			},
+			{
+				Name:  "forecast",
+				Usage: "Estimates when the pending messages of every campaign are sent",
+				Description: "Follows the batches of the scheduler with its interval, batch size, route rate limits and throttle\n" +
+					"profiles, or with --interval, --batch-size and --rate-limit to see the effect of a change before making it.",
+				Action: func(c *cli.Context) error {
+					format := c.String("output")
+					if err := validateOutput(format); err != nil {
+						return err
+					}
+
+					opts := client.ForecastOptions{
+						Interval:  c.Duration("interval"),
+						BatchSize: c.Int("batch-size"),
+					}
+					if c.IsSet("rate-limit") {
+						rateLimit := c.Float64("rate-limit")
+						opts.RateLimit = &rateLimit
+					}
+					response, err := newRemoteClient(c).Forecast(c.Context, opts)
+					if err != nil {
+						return err
+					}
+
+					if format == outputJSON {
+						return printJSON(response)
+					}
+					return printForecast(response)
+				},
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "interval",
+						Usage: "Interval between batches, the server's messaging.interval when not set",
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Usage: "Messages claimed per batch, the server's messaging.batch_size when not set",
+					},
+					&cli.Float64Flag{
+						Name:  "rate-limit",
+						Usage: "Messages per second of every route, 0 is unlimited, the route rate limits when not set",
+					},
+					outputFlag(),
+				},
+			},
+		},
+		Flags: []cli.Flag{
+			apiURLFlag("Base URL of the SendPulse REST API"),
+			apiKeyFlag(),
+		},
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/rest"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+
+	"github.com/urfave/cli/v2"
+)
+
+func mockWebhookCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "mock-webhook",
+		Usage: "Runs a standalone mock of the webhook provider for local end to end testing",
+		Description: "Accepts messages like the provider does, with configurable latency and error rate. With --callback-url\n" +
+			"every accepted message is reported delivered or undelivered to that URL after --report-delay, signed with\n" +
+			"--callback-secret or authenticated with --api-key. Point webhook.url at the printed address.",
+		Action: func(c *cli.Context) error {
+			for _, name := range []string{"error-rate", "undelivered-rate"} {
+				if rate := c.Float64(name); rate < 0 || rate > 1 {
+					return fmt.Errorf("--%s must be between 0 and 1", name)
+				}
+			}
+
+			opts := webhook.MockOptions{
+				Latency:         c.Duration("latency"),
+				Jitter:          c.Duration("jitter"),
+				ErrorRate:       c.Float64("error-rate"),
+				ReportDelay:     c.Duration("report-delay"),
+				UndeliveredRate: c.Float64("undelivered-rate"),
+			}
+			if url := c.String("callback-url"); url != "" {
+				poster := &reportPoster{
+					url:    url,
+					apiKey: c.String("api-key"),
+					secret: c.String("callback-secret"),
+					client: &http.Client{Timeout: 5 * time.Second},
+				}
+				opts.Report = poster.post
+			}
+			mock := webhook.NewMockHandler(opts)
+
+			listener, err := net.Listen("tcp", c.String("address"))
+			if err != nil {
+				return err
+			}
+			server := &http.Server{Handler: mock}
+			go func() {
+				<-c.Context.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				server.Shutdown(ctx)
+			}()
+
+			fmt.Printf("Mock webhook listening on http://%s\n", listener.Addr())
+			if c.String("callback-url") != "" {
+				fmt.Printf("Delivery reports are posted to %s after %s\n", c.String("callback-url"), opts.ReportDelay)
+			}
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+
+			stats := mock.Stats()
+			fmt.Printf("\nRequests:      %d (%d failed)\n", stats.Requests, stats.Errors)
+			fmt.Printf("Reports:       %d (%d undelivered)\n", stats.Reports, stats.Undelivered)
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "address",
+				Usage: "Address to listen on",
+				Value: "127.0.0.1:9090",
+			},
+			&cli.DurationFlag{
+				Name:  "latency",
+				Usage: "Base response latency",
+				Value: 50 * time.Millisecond,
+			},
+			&cli.DurationFlag{
+				Name:  "jitter",
+				Usage: "Random latency added on top of --latency",
+			},
+			&cli.Float64Flag{
+				Name:  "error-rate",
+				Usage: "Share of requests answered with a 500, between 0 and 1",
+			},
+			&cli.StringFlag{
+				Name:  "callback-url",
+				Usage: "Delivery report endpoint to post the reports to, e.g. http://localhost:8080/api/v1/delivery-reports (no reports when empty)",
+			},
+			&cli.DurationFlag{
+				Name:  "report-delay",
+				Usage: "Delay between accepting a message and posting its delivery report",
+				Value: 2 * time.Second,
+			},
+			&cli.Float64Flag{
+				Name:  "undelivered-rate",
+				Usage: "Share of accepted messages reported undelivered, between 0 and 1",
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "API key with the callbacks scope sent with the reports",
+				EnvVars: []string{"SENDPULSE_API_KEY"},
+			},
+			&cli.StringFlag{
+				Name:  "callback-secret",
+				Usage: "Secret of an hmac callback provider (callbacks.providers) to sign the reports with",
+			},
+		},
+	}
+}
+
+// reportPoster posts the delivery reports of the mock webhook like a provider does
+type reportPoster struct {
+	url    string
+	apiKey string
+	secret string
+	client *http.Client
+}
+
+func (p *reportPoster) post(ctx context.Context, report webhook.SandboxReport) error {
+	at := time.Now().UTC()
+	body, err := json.Marshal(dto.DeliveryReportRequest{
+		MessageID: report.MessageID,
+		Status:    report.Status,
+		Error:     report.Error,
+		At:        &at,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set(rest.APIKeyHeader, p.apiKey)
+	}
+	if p.secret != "" {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(p.secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set(rest.SignatureTimeHeader, timestamp)
+		req.Header.Set(rest.SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		config.Log().Warnf("Failed to post the delivery report of %s: %v", report.MessageID, err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// the message may not be stored as sent yet, the report is retried
+		config.Log().Warnf("Delivery report of %s was answered with %d", report.MessageID, resp.StatusCode)
+		return fmt.Errorf("delivery report answered with %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// outputFlag is the --output flag used by commands that print records
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Output format: table or json",
+		Value:   outputTable,
+	}
+}
+
+// validateOutput makes sure the requested output format is supported
+func validateOutput(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("unsupported output format %q, expected %s or %s", format, outputTable, outputJSON)
+	}
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printMessages writes messages to stdout in the requested format
+func printMessages(format string, messages []dto.MessageResponse) error {
+	if format == outputJSON {
+		return printJSON(messages)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTO\tSTATUS\tPRIORITY\tCREATED AT\tSENT AT\tCONTENT")
+	for _, msg := range messages {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			msg.ID, msg.To, msg.Status, msg.Priority,
+			msg.CreatedAt.Format(time.RFC3339), formatTime(msg.SentAt), truncate(msg.Content, 40))
+	}
+	return w.Flush()
+}
+
+// printDuplicates writes the duplicate groups to stdout as a table with the links of their messages
+func printDuplicates(report *dto.DuplicatesResponse) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TO\tCOUNT\tFIRST AT\tLAST AT\tCONTENT\tMESSAGES")
+	for _, group := range report.Groups {
+		links := make([]string, len(group.Messages))
+		for i, msg := range group.Messages {
+			links[i] = msg.Link
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			group.To, group.Count, group.FirstAt.Format(time.RFC3339), group.LastAt.Format(time.RFC3339),
+			truncate(group.Content, 40), strings.Join(links, " "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d duplicate messages in %d groups between %s and %s (window: %s)\n", report.Duplicates, len(report.Groups),
+		report.From.Format(time.RFC3339), report.To.Format(time.RFC3339), time.Duration(report.WindowSeconds)*time.Second)
+	if report.Truncated {
+		fmt.Println("More recipients were given the same content than examined, raise --limit or narrow --from and --to")
+	}
+	return nil
+}
+
+// printRecipientStats writes the statistics of a recipient to stdout
+func printRecipientStats(stats *dto.RecipientStatsResponse) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Phone:\t%s\n", stats.Phone)
+	statuses := make([]string, 0, len(stats.Counts))
+	for status, count := range stats.Counts {
+		statuses = append(statuses, fmt.Sprintf("%s %d", status, count))
+	}
+	slices.Sort(statuses)
+	fmt.Fprintf(w, "Messages:\t%d (%s)\n", stats.Total, strings.Join(statuses, ", "))
+	fmt.Fprintf(w, "First Message At:\t%s\n", formatTime(stats.FirstMessageAt))
+	fmt.Fprintf(w, "Last Message At:\t%s\n", formatTime(stats.LastMessageAt))
+	fmt.Fprintf(w, "Last Success At:\t%s\n", formatTime(stats.LastSuccessAt))
+	if failure := stats.LastFailure; failure != nil {
+		fmt.Fprintf(w, "Last Failure:\t%s %s %s\n", failure.FailedAt.Format(time.RFC3339), failure.Link, failure.Error)
+	}
+	streak := fmt.Sprintf("%d (longest: %d)", stats.FailureStreak, stats.LongestFailureStreak)
+	if stats.OutcomesTruncated {
+		streak += ", counted over the latest messages only"
+	}
+	fmt.Fprintf(w, "Failure Streak:\t%s\n", streak)
+	suppressed := "no"
+	if s := stats.Suppression; s != nil {
+		suppressed = fmt.Sprintf("yes, by %s since %s %s", s.Source, s.CreatedAt.Format(time.RFC3339), s.Reason)
+	}
+	fmt.Fprintf(w, "Suppressed:\t%s\n", suppressed)
+	return w.Flush()
+}
+
+// printForecast writes the forecast of every campaign to stdout as a table followed by the forecast of the backlog
+func printForecast(forecast *dto.ForecastResponse) error {
+	if forecast.Pending == 0 {
+		fmt.Println("No pending messages")
+		return nil
+	}
+	drain := func(at *time.Time, seconds *int64) (string, string) {
+		if at == nil {
+			return "after " + forecast.Horizon.Format(time.RFC3339), "-"
+		}
+		return at.Format(time.RFC3339), (time.Duration(*seconds) * time.Second).String()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CAMPAIGN\tPENDING\tSCHEDULED\tDRAINED AT\tIN")
+	for _, campaign := range forecast.Campaigns {
+		name := campaign.Campaign
+		if name == "" {
+			name = "(none)"
+		}
+		at, in := drain(campaign.DrainAt, campaign.DrainSeconds)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", name, campaign.Pending, campaign.Scheduled, at, in)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	rateLimit := "route rate limits"
+	if forecast.RateLimit != nil {
+		rateLimit = fmt.Sprintf("%g/s per route", *forecast.RateLimit)
+	}
+	at, in := drain(forecast.DrainAt, forecast.DrainSeconds)
+	fmt.Printf("\n%d pending messages (%d scheduled) drained at %s (in %s), %.2f messages/s\n",
+		forecast.Pending, forecast.Scheduled, at, in, forecast.MessagesPerSecond)
+	fmt.Printf("Interval: %s, batch size: %d, %s\n", forecast.Interval, forecast.BatchSize, rateLimit)
+	return nil
+}
+
+// printMessage writes every field of a single message to stdout in the requested format
+func printMessage(format string, msg dto.MessageResponse) error {
+	if format == outputJSON {
+		return printJSON(msg)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", msg.ID)
+	fmt.Fprintf(w, "To:\t%s\n", msg.To)
+	fmt.Fprintf(w, "Status:\t%s\n", msg.Status)
+	fmt.Fprintf(w, "Priority:\t%d\n", msg.Priority)
+	if msg.Tenant != "" {
+		fmt.Fprintf(w, "Tenant:\t%s\n", msg.Tenant)
+	}
+	if msg.Campaign != "" {
+		fmt.Fprintf(w, "Campaign:\t%s\n", msg.Campaign)
+	}
+	if msg.From != "" {
+		fmt.Fprintf(w, "From:\t%s\n", msg.From)
+	}
+	if msg.SenderID != "" {
+		fmt.Fprintf(w, "Sender ID:\t%s\n", msg.SenderID)
+	}
+	fmt.Fprintf(w, "Created At:\t%s\n", msg.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Scheduled At:\t%s\n", formatTime(msg.ScheduledAt))
+	fmt.Fprintf(w, "Sent At:\t%s\n", formatTime(msg.SentAt))
+	if msg.MessageID != nil {
+		fmt.Fprintf(w, "Webhook Message ID:\t%s\n", *msg.MessageID)
+	}
+	fmt.Fprintf(w, "Content:\t%s\n", msg.Content)
+	if msg.RenderedContent != "" {
+		fmt.Fprintf(w, "Rendered Content:\t%s\n", msg.RenderedContent)
+	}
+	fmt.Fprintf(w, "Encoding:\t%s (%d segments)\n", msg.Encoding, msg.Segments)
+	if msg.FailureError != "" {
+		fmt.Fprintf(w, "Failure:\t%s %s\n", msg.FailureClass, msg.FailureError)
+	}
+	if msg.Note != "" {
+		fmt.Fprintf(w, "Note:\t%s\n", msg.Note)
+	}
+	if msg.AcknowledgedAt != nil {
+		fmt.Fprintf(w, "Acknowledged:\t%s %s\n", msg.AcknowledgedAt.Format(time.RFC3339), msg.AcknowledgedBy)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if msg.WebhookResponse != nil {
+		fmt.Println("Webhook Response:")
+		return writeIndentedJSON(os.Stdout, msg.WebhookResponse)
+	}
+	return nil
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("  ", "  ")
+	return encoder.Encode(v)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// parseAge accepts a Go duration (36h) or a number of days (90d)
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q, expected e.g. 90d or 36h", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, expected e.g. 90d or 36h", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/boratanrikulu/sendpulse/pkg/client"
+
+	"github.com/urfave/cli/v2"
+)
+
+// remoteFlags are the flags used by commands that can talk to a running server instead of the database
+func remoteFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "remote",
+			Usage: "Call the REST API of a running server instead of the database",
+		},
+		apiURLFlag("Base URL of the SendPulse REST API used with --remote"),
+		apiKeyFlag(),
+	}
+}
+
+func apiURLFlag(usage string) cli.Flag {
+	return &cli.StringFlag{
+		Name:    "api-url",
+		Usage:   usage,
+		Value:   "http://localhost:8080",
+		EnvVars: []string{"SENDPULSE_API_URL"},
+	}
+}
+
+func apiKeyFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "api-key",
+		Usage:   "API key of the SendPulse REST API (server.api_key)",
+		EnvVars: []string{"SENDPULSE_API_KEY"},
+	}
+}
+
+// newRemoteClient creates a client for the REST API of the --api-url server
+func newRemoteClient(c *cli.Context) *client.Client {
+	return client.New(c.String("api-url"), client.WithAPIKey(c.String("api-key")))
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
 
 	"github.com/uptrace/bun"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -39,26 +43,249 @@ var (
 	}
 )
 
-func seedMessages(ctx context.Context, dbc bun.IDB, count int) error {
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+const (
+	// seedPhoneLength is the length of generated phone numbers including the leading +
+	seedPhoneLength = 13
+	// minSeedRandomDigits is the minimum number of random digits after a custom prefix
+	minSeedRandomDigits = 4
+	// defaultSeedWorkers is the number of concurrent inserts of commands without a --workers flag
+	defaultSeedWorkers = 4
+)
 
-	fmt.Printf("Generating %d random messages...\n", count)
+// seedOptions controls the shape of generated messages
+type seedOptions struct {
+	Count     int
+	BatchSize int
+	// Workers is the number of batches inserted concurrently
+	Workers int
+	// Seed makes generation deterministic, 0 uses the current time
+	Seed int64
+	// PendingPercent, SentPercent and FailedPercent must add up to 100
+	PendingPercent int
+	SentPercent    int
+	FailedPercent  int
+	// From and To spread created_at uniformly over the range, both nil means now
+	From *time.Time
+	To   *time.Time
+	// PhonePrefix generates random numbers with this prefix instead of the sample numbers
+	PhonePrefix string
+	// Profile generates production shaped messages, it replaces the status percentages
+	Profile *seedProfile
+}
 
-	for i := 0; i < count; i++ {
-		message := &db.Message{
-			To:      turkishPhoneNumbers[rng.Intn(len(turkishPhoneNumbers))],
-			Content: sampleMessages[rng.Intn(len(sampleMessages))],
+func (o seedOptions) validate() error {
+	if o.Count < 0 {
+		return fmt.Errorf("count cannot be negative")
+	}
+	if o.BatchSize < 1 {
+		return fmt.Errorf("batch size must be at least 1")
+	}
+	if o.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	if o.Profile == nil {
+		for _, p := range []int{o.PendingPercent, o.SentPercent, o.FailedPercent} {
+			if p < 0 || p > 100 {
+				return fmt.Errorf("status percentages must be between 0 and 100")
+			}
 		}
-
-		if err := db.CreateMessage(ctx, dbc, message); err != nil {
-			return fmt.Errorf("failed to create message %d: %w", i+1, err)
+		if sum := o.PendingPercent + o.SentPercent + o.FailedPercent; sum != 100 {
+			return fmt.Errorf("status percentages must add up to 100, got %d", sum)
+		}
+	}
+	if o.From != nil && o.To != nil && !o.From.Before(*o.To) {
+		return fmt.Errorf("--from must be before --to")
+	}
+	if o.PhonePrefix != "" {
+		probe := &db.Message{To: o.PhonePrefix + "0000", Content: "probe"}
+		if err := db.ValidateMessage(probe); err != nil {
+			return fmt.Errorf("invalid phone prefix %q: %w", o.PhonePrefix, err)
 		}
+	}
+	return nil
+}
+
+// seedBatch is a batch of generated messages, first is the index of its first message
+type seedBatch struct {
+	first    int
+	messages []*db.Message
+}
 
-		if (i+1)%10 == 0 {
-			fmt.Printf("Generated %d messages...\n", i+1)
+// seedMessages inserts the generated messages in batches with opts.Workers concurrent inserts, reporting the
+// insert rate every second. The messages are generated in order on a single goroutine, so a seed reproduces the
+// same messages, but concurrent batches may get their IDs in any order.
+func seedMessages(ctx context.Context, dbc bun.IDB, opts seedOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	if opts.Profile != nil {
+		fmt.Printf("Generating %d %s messages, %s (seed: %d, workers: %d)...\n", opts.Count, opts.Profile.Name, opts.Profile.Description, seed, opts.Workers)
+	} else {
+		fmt.Printf("Generating %d random messages (seed: %d, workers: %d)...\n", opts.Count, seed, opts.Workers)
+	}
+
+	start := time.Now()
+	var inserted atomic.Int64
+	stopReporting := reportSeedProgress(opts.Count, start, &inserted)
+
+	group, ctx := errgroup.WithContext(ctx)
+	batches := make(chan seedBatch, opts.Workers)
+	group.Go(func() error {
+		defer close(batches)
+		for first := 0; first < opts.Count; first += opts.BatchSize {
+			batch := seedBatch{first: first, messages: make([]*db.Message, 0, min(opts.BatchSize, opts.Count-first))}
+			for len(batch.messages) < cap(batch.messages) {
+				batch.messages = append(batch.messages, opts.generate(rng))
+			}
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
+		return nil
+	})
+	for range opts.Workers {
+		group.Go(func() error {
+			for batch := range batches {
+				if _, err := dbc.NewInsert().Model(&batch.messages).Exec(ctx); err != nil {
+					return fmt.Errorf("failed to insert messages %d-%d: %w", batch.first+1, batch.first+len(batch.messages), err)
+				}
+				inserted.Add(int64(len(batch.messages)))
+			}
+			return nil
+		})
+	}
+	err := group.Wait()
+	stopReporting()
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Successfully generated %d random messages!\n", count)
+	elapsed := time.Since(start)
+	fmt.Printf("Successfully generated %d random messages in %s (%.0f rows/sec)!\n",
+		opts.Count, elapsed.Round(time.Millisecond), seedRate(int64(opts.Count), elapsed))
 	return nil
 }
+
+// reportSeedProgress prints the number of inserted messages and the insert rate every second until the
+// returned function is called
+func reportSeedProgress(count int, start time.Time, inserted *atomic.Int64) func() {
+	ticker := time.NewTicker(time.Second)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				rows := inserted.Load()
+				fmt.Printf("Generated %d/%d messages (%.0f rows/sec)...\n", rows, count, seedRate(rows, time.Since(start)))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
+// seedRate returns the rows inserted per second
+func seedRate(rows int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(rows) / elapsed.Seconds()
+}
+
+// generate creates a single random message following the options
+func (o seedOptions) generate(rng *rand.Rand) *db.Message {
+	if o.Profile != nil {
+		var createdAt *time.Time
+		if o.From != nil || o.To != nil {
+			spread := o.createdAt(rng)
+			createdAt = &spread
+		}
+		return o.Profile.generate(rng, o.phoneNumber(rng), createdAt)
+	}
+
+	createdAt := o.createdAt(rng)
+	message := &db.Message{
+		To:        o.phoneNumber(rng),
+		Content:   sampleMessages[rng.Intn(len(sampleMessages))],
+		Status:    db.MessageStatusPending,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	message.Encoding, message.Segments, _ = sms.Encode(message.Content)
+
+	roll := rng.Intn(100)
+	switch {
+	case roll < o.SentPercent:
+		sentAt := createdAt.Add(time.Duration(rng.Intn(300)) * time.Second)
+		messageID := fmt.Sprintf("seed-%016x", rng.Uint64())
+		response, _ := json.Marshal(map[string]any{
+			"status_code": 202,
+			"message":     "Accepted",
+			"message_id":  messageID,
+			"timestamp":   sentAt,
+		})
+		webhookResponse := string(response)
+
+		message.Status = db.MessageStatusSent
+		message.SentAt = &sentAt
+		message.MessageID = &messageID
+		message.WebhookResponse = &webhookResponse
+		message.UpdatedAt = sentAt
+	case roll < o.SentPercent+o.FailedPercent:
+		message.Status = db.MessageStatusFailed
+		message.UpdatedAt = createdAt.Add(time.Duration(rng.Intn(300)) * time.Second)
+	}
+
+	return message
+}
+
+func (o seedOptions) createdAt(rng *rand.Rand) time.Time {
+	now := time.Now().UTC()
+	if o.From == nil && o.To == nil {
+		return now
+	}
+
+	from, to := now, now
+	if o.From != nil {
+		from = *o.From
+	}
+	if o.To != nil {
+		to = *o.To
+	}
+	if !from.Before(to) {
+		return from
+	}
+	return from.Add(time.Duration(rng.Int63n(int64(to.Sub(from)))))
+}
+
+func (o seedOptions) phoneNumber(rng *rand.Rand) string {
+	if o.PhonePrefix == "" {
+		return turkishPhoneNumbers[rng.Intn(len(turkishPhoneNumbers))]
+	}
+
+	digits := seedPhoneLength - len(o.PhonePrefix)
+	if digits < minSeedRandomDigits {
+		digits = minSeedRandomDigits
+	}
+
+	number := []byte(o.PhonePrefix)
+	for i := 0; i < digits; i++ {
+		number = append(number, byte('0'+rng.Intn(10)))
+	}
+	return string(number)
+}
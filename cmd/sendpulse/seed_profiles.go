@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+)
+
+// seedProfile generates messages shaped like a kind of production traffic: its contents, statuses,
+// send times and provider responses
+type seedProfile struct {
+	Name        string
+	Description string
+	// Statuses are the weighted statuses of the generated messages
+	Statuses []seedStatus
+	// Content returns the content of a message
+	Content func(rng *rand.Rand) string
+	// Campaigns and Tenants label the messages, an empty string leaves a message unlabeled
+	Campaigns []string
+	Tenants   []string
+	// Spread is how far back created_at goes when neither --from nor --to is given
+	Spread time.Duration
+	// Hours are the UTC hours of day messages are created in, every hour when empty
+	Hours []int
+	// SendDelay is the longest time between creating and sending a message
+	SendDelay time.Duration
+	// Errors are the delivery errors of failed messages
+	Errors []string
+}
+
+// seedStatus is a status of a profile and its weight among the profile's statuses
+type seedStatus struct {
+	Status db.MessageStatus
+	Weight int
+}
+
+// seedProfiles are the profiles selectable with --profile
+var seedProfiles = []seedProfile{
+	{
+		Name:        "otp-heavy",
+		Description: "short one-time passwords around the clock, nearly all delivered within seconds",
+		Statuses: []seedStatus{
+			{db.MessageStatusDelivered, 88}, {db.MessageStatusSent, 6}, {db.MessageStatusFailed, 3},
+			{db.MessageStatusUnconfirmed, 1}, {db.MessageStatusPending, 2},
+		},
+		Content:   otpContent,
+		Campaigns: []string{""},
+		Tenants:   []string{"auth", "auth", "auth", "payments"},
+		Spread:    14 * 24 * time.Hour,
+		SendDelay: 3 * time.Second,
+		Errors:    []string{"Absent subscriber", "Unknown subscriber", "Network timeout"},
+	},
+	{
+		Name:        "marketing",
+		Description: "long multi-segment campaign texts with Unicode and opt-out footers, sent in business hours",
+		Statuses: []seedStatus{
+			{db.MessageStatusDelivered, 55}, {db.MessageStatusSent, 15}, {db.MessageStatusAccepted, 5},
+			{db.MessageStatusFailed, 8}, {db.MessageStatusUnconfirmed, 4}, {db.MessageStatusPending, 13},
+		},
+		Content:   marketingContent,
+		Campaigns: []string{"spring-sale", "black-friday", "newsletter-weekly", "winback", "app-launch"},
+		Tenants:   []string{"retail", "retail", "media"},
+		Spread:    6 * 7 * 24 * time.Hour,
+		Hours:     []int{7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17},
+		SendDelay: 30 * time.Minute,
+		Errors:    []string{"Recipient opted out", "Blocked by carrier spam filter", "Absent subscriber", "Message expired"},
+	},
+	{
+		Name:        "mixed-failures",
+		Description: "transactional and campaign traffic through a degraded provider, a third fails",
+		Statuses: []seedStatus{
+			{db.MessageStatusDelivered, 35}, {db.MessageStatusSent, 10}, {db.MessageStatusFailed, 33},
+			{db.MessageStatusUnconfirmed, 10}, {db.MessageStatusBlocked, 2}, {db.MessageStatusPending, 10},
+		},
+		Content: func(rng *rand.Rand) string {
+			if rng.Intn(2) == 0 {
+				return otpContent(rng)
+			}
+			return marketingContent(rng)
+		},
+		Campaigns: []string{"", "", "spring-sale", "winback"},
+		Tenants:   []string{"auth", "retail", ""},
+		Spread:    3 * 7 * 24 * time.Hour,
+		SendDelay: 10 * time.Minute,
+		Errors: []string{
+			"Provider returned 503 Service Unavailable", "Network timeout", "Throttled by provider",
+			"Absent subscriber", "Invalid destination number", "Message expired",
+		},
+	},
+}
+
+// seedProfileNames lists the profile names for the flag usage and errors
+func seedProfileNames() string {
+	names := make([]string, len(seedProfiles))
+	for i, profile := range seedProfiles {
+		names[i] = profile.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// findSeedProfile returns the profile named name
+func findSeedProfile(name string) (*seedProfile, error) {
+	i := slices.IndexFunc(seedProfiles, func(p seedProfile) bool { return p.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown seed profile %q, expected one of %s", name, seedProfileNames())
+	}
+	return &seedProfiles[i], nil
+}
+
+var (
+	otpTemplates = []string{
+		"Your verification code is %s. It expires in 5 minutes.",
+		"%s is your login code. Do not share it with anyone.",
+		"Doğrulama kodunuz: %s. Kodu kimseyle paylaşmayın.",
+		"Use %s to confirm your payment of %d.%02d TRY.",
+		"Your one-time password is %s",
+	}
+	marketingOpeners = []string{
+		"Spring sale is here! Up to 50% off everything in store and online.",
+		"Büyük indirim başladı! Tüm ürünlerde %40'a varan fırsatlar sizi bekliyor.",
+		"🎉 Our new app is live, download it today and get free shipping.",
+		"We miss you! Come back this week and enjoy 20% off your next order.",
+		"Black Friday deals end at midnight 🛍️ don't miss out.",
+	}
+	marketingLinks = []string{
+		"https://shop.example.com/sale", "https://example.com/app", "https://ex.co/s/8f2k",
+	}
+	marketingFooters = []string{
+		"Reply STOP to opt out.", "İptal için RET yazın.", "STOP to unsubscribe.",
+	}
+	seedNetworks = []string{"Turkcell", "Vodafone TR", "Türk Telekom"}
+)
+
+// otpContent returns a short one-time password text
+func otpContent(rng *rand.Rand) string {
+	template := otpTemplates[rng.Intn(len(otpTemplates))]
+	code := fmt.Sprintf("%06d", rng.Intn(1000000))
+	if strings.Count(template, "%") == 3 {
+		return fmt.Sprintf(template, code, 10+rng.Intn(990), rng.Intn(100))
+	}
+	return fmt.Sprintf(template, code)
+}
+
+// marketingContent returns a campaign text of a single GSM-7 segment: an opener, a link when it fits and an
+// opt-out footer
+func marketingContent(rng *rand.Rand) string {
+	parts := []string{marketingOpeners[rng.Intn(len(marketingOpeners))]}
+	footer := marketingFooters[rng.Intn(len(marketingFooters))]
+	if link := marketingLinks[rng.Intn(len(marketingLinks))]; len(parts[0])+len(link)+len(footer)+2 <= sms.GSM7SingleSegment {
+		parts = append(parts, link)
+	}
+	if len(strings.Join(parts, " "))+len(footer)+1 <= sms.GSM7SingleSegment {
+		parts = append(parts, footer)
+	}
+	return strings.Join(parts, " ")
+}
+
+// status picks a status by the weights of the profile
+func (p *seedProfile) status(rng *rand.Rand) db.MessageStatus {
+	total := 0
+	for _, status := range p.Statuses {
+		total += status.Weight
+	}
+	roll := rng.Intn(total)
+	for _, status := range p.Statuses {
+		if roll < status.Weight {
+			return status.Status
+		}
+		roll -= status.Weight
+	}
+	return db.MessageStatusPending
+}
+
+// createdAt spreads the creation times over the last Spread, in the profile's hours of day
+func (p *seedProfile) createdAt(rng *rand.Rand, now time.Time) time.Time {
+	createdAt := now.Add(-time.Duration(rng.Int63n(int64(p.Spread))))
+	if len(p.Hours) == 0 {
+		return createdAt
+	}
+	hour := p.Hours[rng.Intn(len(p.Hours))]
+	createdAt = time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), hour,
+		rng.Intn(60), rng.Intn(60), 0, time.UTC)
+	if createdAt.After(now) {
+		createdAt = createdAt.AddDate(0, 0, -1)
+	}
+	return createdAt
+}
+
+// generate creates a message of the profile to to. createdAt is used when --from or --to spread the creation times.
+func (p *seedProfile) generate(rng *rand.Rand, to string, createdAt *time.Time) *db.Message {
+	now := time.Now().UTC()
+	created := p.createdAt(rng, now)
+	if createdAt != nil {
+		created = *createdAt
+	}
+
+	message := &db.Message{
+		To:        to,
+		Content:   p.Content(rng),
+		Status:    p.status(rng),
+		Campaign:  p.Campaigns[rng.Intn(len(p.Campaigns))],
+		Tenant:    p.Tenants[rng.Intn(len(p.Tenants))],
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	message.Encoding, message.Segments, _ = sms.Encode(message.Content)
+
+	// messages still pending are the backlog of the last minutes, not weeks old
+	if message.Status == db.MessageStatusPending {
+		if createdAt == nil {
+			message.CreatedAt = now.Add(-time.Duration(rng.Int63n(int64(p.SendDelay)) + 1))
+			message.UpdatedAt = message.CreatedAt
+		}
+		return message
+	}
+
+	sentAt := created.Add(time.Duration(rng.Int63n(int64(p.SendDelay)) + 1))
+	message.UpdatedAt = sentAt
+	if message.Status == db.MessageStatusBlocked {
+		return message
+	}
+
+	messageID := fmt.Sprintf("seed-%016x", rng.Uint64())
+	statusCode, text := 202, "Accepted"
+	if message.Status == db.MessageStatusFailed && rng.Intn(2) == 0 {
+		// failed at the webhook, it never accepted the message
+		statusCode, text = []int{500, 502, 503, 429}[rng.Intn(4)], p.Errors[rng.Intn(len(p.Errors))]
+		response := seedWebhookResponse(rng, statusCode, text, "", sentAt, message.Segments)
+		message.WebhookResponse = &response
+		return message
+	}
+	response := seedWebhookResponse(rng, statusCode, text, messageID, sentAt, message.Segments)
+	message.SentAt = &sentAt
+	message.MessageID = &messageID
+	message.WebhookResponse = &response
+	message.Provider = "webhook"
+	message.UnitPrice = 0.0075
+
+	switch message.Status {
+	case db.MessageStatusDelivered:
+		deliveredAt := sentAt.Add(time.Duration(rng.Intn(20000)+500) * time.Millisecond)
+		message.DeliveredAt = &deliveredAt
+		message.UpdatedAt = deliveredAt
+	case db.MessageStatusFailed:
+		message.DeliveryError = p.Errors[rng.Intn(len(p.Errors))]
+		message.UpdatedAt = sentAt.Add(time.Duration(rng.Intn(120)+1) * time.Second)
+	case db.MessageStatusUnconfirmed:
+		message.UpdatedAt = sentAt.Add(24 * time.Hour)
+	}
+	if message.UpdatedAt.After(now) {
+		message.UpdatedAt = now
+	}
+	return message
+}
+
+// seedWebhookResponse returns a stored webhook response shaped like the ones of real providers
+func seedWebhookResponse(rng *rand.Rand, statusCode int, message, messageID string, at time.Time, segments int) string {
+	response, _ := json.Marshal(map[string]any{
+		"status_code": statusCode,
+		"message":     message,
+		"message_id":  messageID,
+		"timestamp":   at,
+		"segments":    segments,
+		"price":       map[string]any{"amount": 0.0075 * float64(segments), "currency": "USD"},
+		"network":     seedNetworks[rng.Intn(len(seedNetworks))],
+	})
+	return string(response)
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"github.com/boratanrikulu/sendpulse/internal/config"
-	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
 	"github.com/boratanrikulu/sendpulse/internal/rest"
 	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
 
 	"github.com/urfave/cli/v2"
 )
@@ -17,31 +20,163 @@ func serverCMD() *cli.Command {
 		Action: func(c *cli.Context) error {
 			path := c.String("config")
 
-			cfg, err := config.NewConfig(path)
+			cfg, err := config.NewConfig(path, withStorage(c))
 			if err != nil {
 				return err
 			}
+			logStartup(cfg, "server")
 
-			// Connect to database
-			dbc, err := db.Connect(cfg.Database.DSN)
+			shutdownTracing, err := telemetry.SetupTracing(c.Context, cfg)
 			if err != nil {
 				return err
 			}
-			cfg.SetDB(dbc)
+			defer flushTracing(shutdownTracing)
 
-			// Initialize services
-			messageService := service.NewMessageService(dbc)
-			scheduler := service.NewScheduler(dbc, cfg)
+			flushSentry, err := telemetry.SetupSentry(cfg)
+			if err != nil {
+				return err
+			}
+			defer flushSentry()
 
-			// Auto-start messaging if enabled
-			if cfg.Messaging.Enabled {
-				if _, err := scheduler.Start(c.Context); err != nil {
+			telemetry.SetMaxLabelValues(cfg.Metrics.MaxLabelValues)
+			stopStatsD, err := telemetry.SetupStatsD(cfg)
+			if err != nil {
+				return err
+			}
+			defer stopStatsD()
+
+			// Connect to database, waiting for it to come up
+			dbc, err := connectWithRetry(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			// A read-only server serves the reads of an old or new schema, nothing writing to it is started
+			readOnly, err := checkSchema(c.Context, cfg, dbc, true)
+			if err != nil {
+				return err
+			}
+			availability := watchDatabase(c.Context, cfg, dbc)
+
+			bus, closeBus, err := newEventBus(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeBus()
+
+			// Initialize services, messages are always enqueued to Postgres, the scheduler claims from the configured backend.
+			// Messages created through the API count against the quotas of their API key and tenant.
+			ingestQueue, err := newIngestQueue(cfg, dbc, bus)
+			if err != nil {
+				return err
+			}
+			quotas := quota.New(dbc, cfg)
+			notifyQuotaWarnings(cfg, quotas)
+			messageService := service.NewMessageServiceWithQueue(dbc, quota.NewQueue(ingestQueue, quotas))
+			q, closeQueue, err := queue.New(c.Context, cfg, dbc)
+			if err != nil {
+				return err
+			}
+			defer closeQueue()
+			scheduler := service.NewSchedulerWithQueue(dbc, events.NewQueue(q, bus), cfg)
+			deliveryReports := service.NewDeliveryReportService(dbc, cfg.DeliveryReports, bus)
+			scheduler.SetDeliveryReports(deliveryReports)
+			scheduler.SetAvailability(availability)
+			// the read-only mode of server.read_only and the admin API pauses messaging along with the writes
+			readOnlyMode := service.NewReadOnlyMode(cfg.Server.ReadOnly)
+			scheduler.SetReadOnlyMode(readOnlyMode)
+			// the scheduler of a passive region stays idle until the region is promoted through the admin API
+			region := service.NewRegionFailover(cfg.Region)
+			scheduler.SetRegionFailover(region)
+			// sending messages are cancelled through the scheduler of the instance that claimed them
+			messageService.SetSendCanceller(scheduler)
+			healthService := service.NewHealthService(dbc)
+			healthService.SetAvailability(availability)
+			healthService.SetScheduler(scheduler)
+
+			// Auto-start messaging if enabled and not stopped across the cluster, signals stop it through the
+			// handoff so in-flight sends finish
+			if !readOnly {
+				if err := scheduler.Run(c.Context); err != nil {
+					return err
+				}
+			}
+
+			// Stats and list totals are served from the count cache when stats.count_cache_interval is set, the
+			// stats_refresh maintenance job refreshes it on a single instance instead of every one
+			counts := service.NewCountCache(dbc, cfg.Stats.CountCacheInterval)
+			messageService.SetCountCache(counts)
+			if !cfg.Maintenance.StatsRefresh.Enabled && !readOnly {
+				go counts.Run(c.Context)
+			}
+			if cfg.Stats.Coalesce {
+				messageService.CoalesceReads()
+			}
+			// List reads go to the replica when one is configured, hedged to the primary with database.replica.hedge
+			replica, err := connectReplica(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			if replica != nil {
+				defer replica.Close()
+				messageService.ReadFromReplica(replica, cfg.Database.Replica)
+			}
+
+			go scheduler.ReportQueueMetrics(c.Context)
+			startAlerts(c.Context, cfg, messageService, scheduler, bus)
+			startReports(c.Context, cfg, dbc)
+
+			// Async ingestion jobs enqueue like the API, counting against the quotas of the submitting API key
+			ingestion := service.NewIngestionService(dbc, quota.NewQueue(ingestQueue, quotas), cfg.Ingestion)
+			// Exports and other long-running operations run as background jobs followed at /api/v1/jobs/{id}
+			jobs := service.NewJobService(dbc, messageService, cfg.Jobs)
+			var maintenance *service.Maintenance
+			if !readOnly {
+				go deliveryReports.WatchUnconfirmed(c.Context)
+				startConsumers(c.Context, cfg, ingestQueue)
+				if maintenance, err = startMaintenance(c.Context, cfg, dbc, messageService, counts, bus); err != nil {
 					return err
 				}
+				go ingestion.Run(c.Context)
+				go jobs.Run(c.Context)
 			}
 
-			// Create and start server
-			server := rest.NewServer(cfg, messageService, scheduler)
+			// Create and start server, the scheduler is stopped once the server shuts down
+			services := rest.Services{
+				Messages:        messageService,
+				Scheduler:       scheduler,
+				Health:          healthService,
+				Usage:           service.NewUsageService(quotas),
+				Suppression:     service.NewSuppressionService(dbc, cfg.Suppression),
+				DeliveryReports: deliveryReports,
+				Links:           service.NewLinkService(dbc, cfg.LinkTracking),
+				Costs:           service.NewCostService(dbc, cfg.Routing),
+				Replays:         service.NewReplayService(dbc, ingestQueue, cfg.Replay),
+				Erasures:        service.NewErasureService(dbc),
+				Ingestion:       ingestion,
+				Webhooks:        service.NewWebhookOverrideService(dbc, cfg.Webhook),
+				Pauses:          service.NewPauseService(dbc, cfg.Messaging),
+				Campaigns:       service.NewCampaignService(dbc, cfg.Campaigns),
+				DeadLetters:     service.NewDeadLetterService(dbc),
+				Jobs:            jobs,
+				SLA:             service.NewSLAService(dbc, cfg.Routing),
+				Runtime:         service.NewRuntimeService(cfg, scheduler),
+			}
+			// a nil *service.Maintenance would not be a nil interface, read-only servers run no maintenance
+			if maintenance != nil {
+				services.Maintenance = maintenance
+			}
+			server := rest.NewServer(cfg, services)
+			if readOnly {
+				server.SetReadOnly()
+			}
+			server.SetReadOnlyMode(readOnlyMode)
+			server.SetRegionFailover(region)
+			if cfg.Server.LoadShedding.Enabled {
+				shedder := service.NewLoadShedder(dbc, cfg.Server.LoadShedding)
+				go shedder.Watch(c.Context)
+				server.SetLoadShedder(shedder)
+			}
+			defer shutdownScheduler(cfg, scheduler)
 			return server.Start(c.Context)
 		},
 		Flags: []cli.Flag{
@@ -51,6 +186,7 @@ func serverCMD() *cli.Command {
 				Usage:   "config.yaml file location",
 				Value:   "./configs/sendpulse.yaml",
 			},
+			storageFlag(),
 		},
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func statsCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "stats",
+		Usage: "Prints message counts per status, today's throughput, failure rate and oldest pending message age",
+		Action: func(c *cli.Context) error {
+			format := c.String("output")
+			if err := validateOutput(format); err != nil {
+				return err
+			}
+
+			var response *dto.StatsResponse
+			if c.Bool("remote") {
+				var err error
+				if response, err = newRemoteClient(c).Stats(c.Context); err != nil {
+					return err
+				}
+			} else {
+				_, dbc, err := connect(c)
+				if err != nil {
+					return err
+				}
+				defer dbc.Close()
+
+				response, err = service.NewMessageService(dbc).Stats(c.Context)
+				if err != nil {
+					return err
+				}
+			}
+
+			if format == outputJSON {
+				return printJSON(response)
+			}
+			return printStats(response)
+		},
+		Flags: append([]cli.Flag{
+			configFlag(),
+			outputFlag(),
+		}, remoteFlags()...),
+	}
+}
+
+// printStats writes the statistics to stdout as a compact table
+func printStats(stats *dto.StatsResponse) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PENDING\tSENDING\tACCEPTED\tSENT\tDELIVERED\tUNCONFIRMED\tFAILED\tBLOCKED\tQUARANTINED\tCANCELLED\tEXPIRED\tWITHOUT ID\tTOTAL")
+	fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n\n",
+		stats.Counts["pending"], stats.Counts["sending"], stats.Counts["accepted"], stats.Counts["sent"], stats.Counts["delivered"],
+		stats.Counts["unconfirmed"], stats.Counts["failed"], stats.Counts["blocked"], stats.Counts["quarantined"], stats.Counts["cancelled"],
+		stats.Counts["expired"], stats.Counts["accepted_without_id"], stats.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	oldest := "-"
+	if stats.OldestPendingAt != nil {
+		oldest = fmt.Sprintf("%s (since %s)",
+			(time.Duration(stats.OldestPendingAgeSeconds) * time.Second).String(), formatTime(stats.OldestPendingAt))
+	}
+
+	fmt.Fprintf(w, "Sent today\t%d\n", stats.SentToday)
+	fmt.Fprintf(w, "Failed today\t%d\n", stats.FailedToday)
+	fmt.Fprintf(w, "Failure rate\t%.1f%%\n", stats.FailureRate*100)
+	fmt.Fprintf(w, "Oldest pending\t%s\n", oldest)
+	return w.Flush()
+}
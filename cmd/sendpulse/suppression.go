@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/client"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/urfave/cli/v2"
+)
+
+func suppressionCMD() *cli.Command {
+	return &cli.Command{
+		Name:    "suppression",
+		Aliases: []string{"suppressions"},
+		Usage:   "suppression list operations",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "import",
+				Usage: "Suppresses the numbers of an opt-out list, e.g. the do-not-contact list of a previous vendor",
+				Description: "CSV lists name their number column phone, phone_number, number, msisdn, mobile, recipient or to, as\n" +
+					"provider exports do, with an optional reason column, or have the number in their first column without\n" +
+					"a header. Text lists hold one number per line, lines starting with # are comments. Numbers are\n" +
+					"normalized to E.164, MSISDNs without a + included. Repeated and already suppressed numbers are\n" +
+					"skipped, invalid rows are reported without stopping the import.",
+				Action: func(c *cli.Context) error {
+					path := c.String("file")
+					opts := service.SuppressionImport{
+						Format: c.String("format"),
+						Reason: c.String("reason"),
+						DryRun: c.Bool("dry-run"),
+					}
+					if opts.Format == "" {
+						opts.Format = suppressionFormatFromExtension(path)
+					}
+
+					var response *dto.SuppressionImportResponse
+					if c.Bool("remote") {
+						list, err := os.ReadFile(path)
+						if err != nil {
+							return err
+						}
+						response, err = newRemoteClient(c).ImportSuppressions(c.Context, list, client.ImportOptions{
+							Format: opts.Format,
+							Reason: opts.Reason,
+							DryRun: opts.DryRun,
+						})
+						if err != nil {
+							return err
+						}
+					} else {
+						file, err := os.Open(path)
+						if err != nil {
+							return err
+						}
+						defer file.Close()
+
+						cfg, dbc, err := connect(c)
+						if err != nil {
+							return err
+						}
+						defer dbc.Close()
+
+						response, err = service.NewSuppressionService(dbc, cfg.Suppression).ImportSuppressions(c.Context, file, opts)
+						if err != nil {
+							return err
+						}
+					}
+
+					verb := "Suppressed"
+					if response.DryRun {
+						verb = "Would suppress"
+					}
+					fmt.Printf("%s %d of %d numbers (%d already suppressed, %d duplicates, %d invalid)\n",
+						verb, response.Imported, response.Read, response.Existing, response.Duplicates, response.Invalid)
+					for _, rejection := range response.Rejections {
+						fmt.Printf("  line %d: %q %s\n", rejection.Line, rejection.Value, rejection.Reason)
+					}
+					if more := response.Invalid - len(response.Rejections); more > 0 {
+						fmt.Printf("  ... and %d more invalid rows\n", more)
+					}
+					return nil
+				},
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "Opt-out list to import",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "List format: csv or text (default: text for .txt files, csv otherwise)",
+					},
+					&cli.StringFlag{
+						Name:  "reason",
+						Usage: "Reason of the numbers without one of their own",
+						Value: service.DefaultSuppressionImportReason,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report what the import would do",
+					},
+				}, remoteFlags()...),
+			},
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+		},
+	}
+}
+
+func suppressionFormatFromExtension(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		return ingest.FormatText
+	}
+	return ingest.FormatCSV
+}
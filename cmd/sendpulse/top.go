@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/client"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/urfave/cli/v2"
+)
+
+func topCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "top",
+		Usage: "Shows a live dashboard of the queue and the scheduler of a running server",
+		Action: func(c *cli.Context) error {
+			interval := c.Duration("interval")
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			m := &topModel{
+				ctx:      c.Context,
+				client:   newRemoteClient(c),
+				apiURL:   c.String("api-url"),
+				interval: interval,
+				failures: c.Int("failures"),
+			}
+			_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(c.Context)).Run()
+			if errors.Is(err, tea.ErrProgramKilled) && c.Context.Err() != nil {
+				return nil
+			}
+			return err
+		},
+		Flags: []cli.Flag{
+			apiURLFlag("Base URL of the SendPulse REST API"),
+			apiKeyFlag(),
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Refresh interval",
+				Value: 2 * time.Second,
+			},
+			&cli.IntFlag{
+				Name:  "failures",
+				Usage: "Number of recent failures to show",
+				Value: 5,
+			},
+		},
+	}
+}
+
+// topSnapshot is the state of the server at one poll
+type topSnapshot struct {
+	at       time.Time
+	status   dto.MessagingStatusResponse
+	counts   map[db.MessageStatus]int
+	failures []dto.MessageResponse
+}
+
+// sent returns the number of messages the webhook accepted, whatever their delivery state
+func (s *topSnapshot) sent() int {
+	var sent int
+	for _, status := range db.SentStatuses {
+		sent += s.counts[status]
+	}
+	return sent
+}
+
+type topSnapshotMsg struct {
+	snapshot *topSnapshot
+	err      error
+}
+
+type topTickMsg struct{}
+
+// topModel is the bubbletea model of the top command
+type topModel struct {
+	ctx      context.Context
+	client   *client.Client
+	apiURL   string
+	interval time.Duration
+	failures int
+
+	current  *topSnapshot
+	previous *topSnapshot
+	err      error
+}
+
+func (m *topModel) Init() tea.Cmd {
+	return m.poll
+}
+
+func (m *topModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "r":
+			return m, m.poll
+		}
+	case topSnapshotMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.previous, m.current = m.current, msg.snapshot
+		}
+		return m, tea.Tick(m.interval, func(time.Time) tea.Msg { return topTickMsg{} })
+	case topTickMsg:
+		return m, m.poll
+	}
+	return m, nil
+}
+
+// poll fetches the scheduler status, per status counts and the recent failures from the API
+func (m *topModel) poll() tea.Msg {
+	ctx, cancel := context.WithTimeout(m.ctx, m.interval+5*time.Second)
+	defer cancel()
+
+	snapshot := &topSnapshot{
+		at:     time.Now(),
+		counts: make(map[db.MessageStatus]int),
+	}
+	schedulerStatus, err := m.client.MessagingStatus(ctx)
+	if err != nil {
+		return topSnapshotMsg{err: err}
+	}
+	snapshot.status = *schedulerStatus
+
+	statuses := append([]db.MessageStatus{db.MessageStatusPending, db.MessageStatusSending, db.MessageStatusFailed}, db.SentStatuses...)
+	for _, status := range statuses {
+		pageSize := 1
+		if status == db.MessageStatusFailed && m.failures > 0 {
+			pageSize = m.failures
+		}
+
+		list, err := m.client.ListMessages(ctx, client.ListOptions{Status: string(status), PageSize: pageSize})
+		if err != nil {
+			return topSnapshotMsg{err: err}
+		}
+		snapshot.counts[status] = list.Total
+		if status == db.MessageStatusFailed && m.failures > 0 {
+			snapshot.failures = list.Messages
+		}
+	}
+
+	return topSnapshotMsg{snapshot: snapshot}
+}
+
+// sendRate returns the sent messages per minute between the last two polls
+func (m *topModel) sendRate() (float64, bool) {
+	if m.previous == nil || m.current == nil {
+		return 0, false
+	}
+	elapsed := m.current.at.Sub(m.previous.at)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	sent := m.current.sent() - m.previous.sent()
+	return float64(sent) / elapsed.Minutes(), true
+}
+
+func (m *topModel) View() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "sendpulse top - %s (every %s, r: refresh, q: quit)\n\n", m.apiURL, m.interval)
+	if m.err != nil {
+		fmt.Fprintf(&b, "error: %v\n\n", m.err)
+	}
+	if m.current == nil {
+		if m.err == nil {
+			b.WriteString("loading...\n")
+		}
+		return b.String()
+	}
+
+	s := m.current
+	state := "stopped"
+	if s.status.Enabled {
+		state = "running"
+	}
+	fmt.Fprintf(&b, "Scheduler   %s (interval: %s, batch size: %d, max retries: %d)\n",
+		state, s.status.Interval, s.status.BatchSize, s.status.MaxRetries)
+	fmt.Fprintf(&b, "Updated     %s\n\n", s.at.Format(time.TimeOnly))
+
+	fmt.Fprintf(&b, "Queue depth %d pending, %d sending\n", s.counts[db.MessageStatusPending], s.counts[db.MessageStatusSending])
+	fmt.Fprintf(&b, "Totals      %d sent, %d failed\n", s.sent(), s.counts[db.MessageStatusFailed])
+	if rate, ok := m.sendRate(); ok {
+		fmt.Fprintf(&b, "Send rate   %.1f/min\n", rate)
+	} else {
+		b.WriteString("Send rate   -\n")
+	}
+
+	if m.failures > 0 {
+		b.WriteString("\nRecent failures\n")
+		if len(s.failures) == 0 {
+			b.WriteString("  none\n")
+		}
+		for _, msg := range s.failures {
+			fmt.Fprintf(&b, "  %-8d %-16s %s  %s\n", msg.ID, msg.To, formatTime(&msg.CreatedAt), truncate(msg.Content, 40))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/rest"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+
+	"github.com/urfave/cli/v2"
+)
+
+func workerCMD() *cli.Command {
+	return &cli.Command{
+		Name:    "worker",
+		Aliases: []string{"w"},
+		Usage:   "Runs only the message scheduler, without the REST API",
+		Action: func(c *cli.Context) error {
+			cfg, err := config.NewConfig(c.String("config"))
+			if err != nil {
+				return err
+			}
+			logStartup(cfg, "worker")
+			dbc, err := connectWithRetry(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer dbc.Close()
+			// a worker only writes, it cannot run read-only
+			if _, err := checkSchema(c.Context, cfg, dbc, false); err != nil {
+				return err
+			}
+
+			if !cfg.Messaging.Enabled {
+				return errors.New("messaging is disabled, set messaging.enabled to run a worker")
+			}
+
+			shutdownTracing, err := telemetry.SetupTracing(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer flushTracing(shutdownTracing)
+
+			flushSentry, err := telemetry.SetupSentry(cfg)
+			if err != nil {
+				return err
+			}
+			defer flushSentry()
+
+			telemetry.SetMaxLabelValues(cfg.Metrics.MaxLabelValues)
+			stopStatsD, err := telemetry.SetupStatsD(cfg)
+			if err != nil {
+				return err
+			}
+			defer stopStatsD()
+
+			bus, closeBus, err := newEventBus(c.Context, cfg)
+			if err != nil {
+				return err
+			}
+			defer closeBus()
+
+			q, closeQueue, err := queue.New(c.Context, cfg, dbc)
+			if err != nil {
+				return err
+			}
+			defer closeQueue()
+			scheduler := service.NewSchedulerWithQueue(dbc, events.NewQueue(q, bus), cfg)
+			scheduler.SetDeliveryReports(service.NewDeliveryReportService(dbc, cfg.DeliveryReports, bus))
+			availability := watchDatabase(c.Context, cfg, dbc)
+			scheduler.SetAvailability(availability)
+			// a worker has no admin API, the workers of a passive region are promoted by restarting them with
+			// region.role active
+			scheduler.SetRegionFailover(service.NewRegionFailover(cfg.Region))
+			// metrics and probes are served without the REST API, a taken address fails before sending starts
+			if cfg.Metrics.WorkerAddress != "" {
+				healthService := service.NewHealthService(dbc)
+				healthService.SetAvailability(availability)
+				if err := rest.NewProbeServer(cfg.Metrics.WorkerAddress, healthService).Start(c.Context); err != nil {
+					return err
+				}
+			}
+			// the worker follows the cluster control, signals stop it through the handoff so in-flight sends finish
+			if err := scheduler.Run(c.Context); err != nil {
+				return err
+			}
+			go scheduler.ReportQueueMetrics(c.Context)
+			startAlerts(c.Context, cfg, service.NewMessageService(dbc), scheduler, bus)
+			startReports(c.Context, cfg, dbc)
+			config.Log().Infof("SendPulse worker started (interval: %s, batch size: %d)",
+				cfg.Messaging.Interval, cfg.Messaging.BatchSize)
+
+			<-c.Context.Done()
+			config.Log().Info("Shutting down SendPulse worker...")
+			shutdownScheduler(cfg, scheduler)
+
+			return nil
+		},
+		Flags: []cli.Flag{
+			configFlag(),
+		},
+	}
+}
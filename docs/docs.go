@@ -15,64 +15,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/health": {
+        "/api/v1/admin/egress": {
             "get": {
-                "description": "Check if the service is running",
+                "description": "The local addresses the webhook client sends from, set by webhook.local_address or webhook.interface, and the public egress IPs of webhook.egress_ips, for providers allowing known source IPs only",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "admin"
                 ],
-                "summary": "Health Check",
+                "summary": "Egress Addresses",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.HealthResponse"
+                            "$ref": "#/definitions/dto.EgressResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/api/v1/messages": {
+        "/api/v1/admin/jobs": {
             "get": {
-                "description": "Get a paginated list of sent messages",
+                "description": "The maintenance jobs scheduled on the instance with their next run, and their last run on any instance: its status, result or error, and the run and failure counts",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
-                ],
-                "summary": "List Sent Messages",
-                "parameters": [
-                    {
-                        "minimum": 1,
-                        "type": "integer",
-                        "description": "Page number (default: 1)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "maximum": 100,
-                        "minimum": 1,
-                        "type": "integer",
-                        "description": "Page size (default: 20, max: 100)",
-                        "name": "page_size",
-                        "in": "query"
-                    }
+                    "admin"
                 ],
+                "summary": "Maintenance Jobs",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagesListResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponse"
+                            "$ref": "#/definitions/dto.MaintenanceJobsResponse"
                         }
                     },
                     "500": {
@@ -81,33 +69,66 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/api/v1/messages/{id}": {
+        "/api/v1/admin/read-only": {
             "get": {
-                "description": "Get details of a specific message by its ID",
+                "description": "Whether the instance is read-only: in the read-only mode toggled by operators, or because the database schema does not match the binary",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
+                    "admin"
                 ],
-                "summary": "Get Message by ID",
+                "summary": "Read-Only Mode",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReadOnlyResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Enable or disable the read-only mode of the instance, e.g. while a corrupted database is restored. While it is enabled only reads are answered, other requests like creating messages, retries and messaging control are 503 with code read_only, and the scheduler claims no messages. Both resume once it is disabled. Start every instance with server.read_only to make the whole cluster read-only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set Read-Only Mode",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Message ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Whether the mode is enabled",
+                        "name": "mode",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReadOnlyRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                            "$ref": "#/definitions/dto.ReadOnlyResponse"
                         }
                     },
                     "400": {
@@ -115,95 +136,207 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
-                    },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/region": {
+            "get": {
+                "description": "The region of the instance and whether it is active or passive. The scheduler of a passive region claims no messages until the region is promoted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Region",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponse"
+                            "$ref": "#/definitions/dto.RegionResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/api/v1/messaging/start": {
+        "/api/v1/admin/region/demote": {
             "post": {
-                "description": "Start the automatic message sending process",
+                "description": "Demote the region of the instance to passive, its scheduler stops claiming messages after the running batch. Every instance of the region is demoted on its own, a demotion is not kept across restarts.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messaging"
+                    "admin"
                 ],
-                "summary": "Start Messaging Service",
+                "summary": "Demote Region",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                            "$ref": "#/definitions/dto.RegionResponse"
                         }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/region/promote": {
+            "post": {
+                "description": "Promote the region of the instance to active so its scheduler claims messages, e.g. once the active region failed. Demote the other region first, or make sure it is down, so the regions never send the same message. Every instance of the region is promoted on its own, a promotion is not kept across restarts.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Promote Region",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                            "$ref": "#/definitions/dto.RegionResponse"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/admin/routing": {
+            "get": {
+                "description": "The routes of the scheduler of the instance and the providers their messages are split between, with the weight, health score, current share and routing decisions of every provider. Scores follow the success rate and latency of the sends of the instance within routing.health.window, every instance reports its own.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Routing",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponse"
+                            "$ref": "#/definitions/dto.RoutingResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/api/v1/messaging/status": {
+        "/api/v1/admin/runtime": {
             "get": {
-                "description": "Get the current status of the automatic message sending service",
+                "description": "The build, GOMAXPROCS, goroutine count, memory, uptime and scheduler internals of the instance and its effective configuration with the secrets redacted, for incident triage. Every instance reports its own runtime.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messaging"
+                    "admin"
                 ],
-                "summary": "Get Messaging Service Status",
+                "summary": "Runtime",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagingStatusResponse"
+                            "$ref": "#/definitions/dto.RuntimeResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/api/v1/messaging/stop": {
+        "/api/v1/admin/warmup": {
             "post": {
-                "description": "Stop the automatic message sending process",
+                "description": "Prepare a freshly started instance before it is added to the load balancer rotation: open the database connections, load the webhook overrides and connect to the webhook of every provider, so the first requests do not pay for them. Every step is reported in checks, the status is 503 when one failed. It is answered while the instance is read-only.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messaging"
+                    "admin"
                 ],
-                "summary": "Stop Messaging Service",
+                "summary": "Warmup",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                            "$ref": "#/definitions/dto.WarmupResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.WarmupResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns": {
+            "get": {
+                "description": "Get the campaigns of the approval workflow ordered by name, with the number of their pending messages",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "List Campaigns",
+                "parameters": [
+                    {
+                        "enum": [
+                            "draft",
+                            "pending_approval",
+                            "approved",
+                            "launched",
+                            "paused"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CampaignsResponse"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                            "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     },
                     "500": {
@@ -212,87 +345,5578 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.ErrorResponse"
                         }
                     }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "dto.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
                 },
-                "status": {
-                    "type": "string"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Register a campaign as a draft. Its pending messages are held, deferred and checked again every campaigns.recheck, until the campaign is approved by another API key and launched.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Create Campaign",
+                "parameters": [
+                    {
+                        "description": "Campaign to register",
+                        "name": "campaign",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CampaignRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
                 },
-                "timestamp": {
-                    "type": "string"
-                }
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "dto.HealthResponse": {
-            "type": "object",
-            "properties": {
-                "mode": {
-                    "type": "string"
-                },
-                "service": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "string"
-                },
-                "timestamp": {
+        "/api/v1/campaigns/{name}": {
+            "get": {
+                "description": "Get a campaign of the approval workflow by name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Get Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/approve": {
+            "post": {
+                "description": "Approve a campaign pending approval, the API key that submitted it cannot approve it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Approve Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/launch": {
+            "post": {
+                "description": "Launch an approved campaign, its held messages are sent within campaigns.recheck",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Launch Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/pause": {
+            "post": {
+                "description": "Pause a launched campaign, its pending messages are held from now on and the sends in flight finish. Its throttle profile, transform pipeline or pending messages can be changed before it is resumed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Pause Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/reject": {
+            "post": {
+                "description": "Reject a campaign pending approval, it goes back to draft with the reason of the rejection",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Reject Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason of the rejection",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CampaignReviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/resume": {
+            "post": {
+                "description": "Resume a paused campaign from its progress cursor within campaigns.recheck, its messages to recipients the campaign sent to already are cancelled instead of sent",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Resume Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/campaigns/{name}/submit": {
+            "post": {
+                "description": "Submit a draft campaign for approval, another API key has to approve it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Submit Campaign",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleCampaignResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/clicks": {
+            "get": {
+                "description": "Get the clicks on tracked short links per campaign, most clicked first. Messages without a campaign are counted under an empty campaign.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Campaign Clicks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only this campaign",
+                        "name": "campaign",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CampaignClicksResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/costs": {
+            "get": {
+                "description": "Sum the cost of the messages sent in a window per UTC day, campaign or tenant. A message costs its segments times the unit price of the route it was sent through, captured when it was sent.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "costs"
+                ],
+                "summary": "Cost Report",
+                "parameters": [
+                    {
+                        "enum": [
+                            "day",
+                            "campaign",
+                            "tenant"
+                        ],
+                        "type": "string",
+                        "description": "Group by (default: day)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent at or after (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent before (YYYY-MM-DD or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CostReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/dead-letters/heatmap": {
+            "get": {
+                "description": "Count the messages dead-lettered in a window by UTC weekday and hour, failures piling up at the same hours point at batch jobs or provider maintenance windows.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letters"
+                ],
+                "summary": "Dead Letter Heatmap",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dead-lettered at or after (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dead-lettered before (YYYY-MM-DD or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeadLetterHeatmapResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/dead-letters/top": {
+            "get": {
+                "description": "Count the messages dead-lettered in a window by the error of their last failed send, their failure class, the leading digits of their recipient or their provider, most first, to find recurring root causes before requeueing them. Messages without a reason, class or provider are counted under an empty key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "dead-letters"
+                ],
+                "summary": "Top Dead Letters",
+                "parameters": [
+                    {
+                        "enum": [
+                            "reason",
+                            "class",
+                            "prefix",
+                            "provider"
+                        ],
+                        "type": "string",
+                        "description": "Group by (default: reason)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Number of groups (default: 10, max: 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 15,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Digits of the recipient prefixes after the + (default: 3)",
+                        "name": "prefix_digits",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dead-lettered at or after (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Dead-lettered before (YYYY-MM-DD or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeadLetterTopResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/delivery-reports": {
+            "post": {
+                "description": "Called by the SMS provider when the state of an accepted message changes. The message is looked up by the message ID the webhook returned and moved to sent, delivered or failed (undelivered is the same as failed). Reports older than the current status, like sent after delivered, are ignored. With callbacks.providers configured the request is authenticated by its provider signature instead of an API key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Delivery Report",
+                "parameters": [
+                    {
+                        "description": "Delivery report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeliveryReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeliveryReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/erasures": {
+            "get": {
+                "description": "Get a paginated list of the erasure audit records, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "erasures"
+                ],
+                "summary": "List Erasures",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only the records of this phone number",
+                        "name": "phone",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page number (default: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page size (default: 20, max: 100)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErasuresListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Delete or anonymize the messages to a phone number and delete the tracked links in them, e.g. for a right to be forgotten request. Anonymized messages keep their status, timestamps, campaign and cost without the number, content, provider responses and metadata, the pending ones are cancelled. The suppression of the number is kept unless include_suppression is set. An audit record identifying the number by its SHA-256 is stored with the erasure. Run it with dry_run to count the data first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "erasures"
+                ],
+                "summary": "Erase Recipient Data",
+                "parameters": [
+                    {
+                        "description": "Phone number and mode of the erasure",
+                        "name": "erasure",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErasureRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErasureResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/errors": {
+            "get": {
+                "description": "The codes of the error responses with their names, HTTP statuses and descriptions. Codes are stable, clients should match the code of an error response instead of its message. Requires no API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Error Codes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorCodesResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/health": {
+            "get": {
+                "description": "Check if the service is running, the status is degraded while the database is unreachable",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/inbound": {
+            "post": {
+                "description": "Called by the SMS provider for every received message. Stop keywords (STOP, UNSUBSCRIBE, ...) suppress the sender, start keywords (START, UNSTOP) lift a suppression they created. With callbacks.providers configured the request is authenticated by its provider signature instead of an API key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Inbound Message",
+                "parameters": [
+                    {
+                        "description": "Received message",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.InboundMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.InboundMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/jobs/{id}": {
+            "get": {
+                "description": "Get the state and progress of a background job, an export or an asynchronous ingestion, with the URL downloading its output once it succeeded. Finished jobs are kept for jobs.result_ttl.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.JobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/jobs/{id}/result": {
+            "get": {
+                "description": "Download the output of a succeeded background job, e.g. the CSV or JSON lines file of an export, until the job expires",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Job Result",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/limits": {
+            "get": {
+                "description": "The daily and monthly quotas of the calling API key with the messages used and remaining and when they reset, empty for keys without quotas. Requires no scope.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "API Key Limits",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.LimitsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages": {
+            "get": {
+                "description": "Get a paginated list of sent messages, or of messages of any status matching the status, creation date, tag and metadata filters, sorting or a cursor",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List Messages",
+                "parameters": [
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page number (default: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page size (default: 20, max: 100)",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "-created_at",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "description": "Order of the messages of any status, newest (default) or oldest first",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The next_cursor of the page before, continues the list instead of page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "sending",
+                            "accepted",
+                            "sent",
+                            "delivered",
+                            "unconfirmed",
+                            "accepted_without_id",
+                            "failed",
+                            "blocked",
+                            "quarantined",
+                            "cancelled",
+                            "expired"
+                        ],
+                        "type": "string",
+                        "description": "Only messages with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created at or after this date (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages created before this date (YYYY-MM-DD or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages with this tag in their metadata",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages with this metadata value, any metadata.\u003ckey\u003e parameter filters by that key",
+                        "name": "metadata.order_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only failed messages in the dead-letter queue, no longer requeued automatically",
+                        "name": "dead_lettered",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only messages whose failure an operator acknowledged (true) or did not acknowledge yet (false)",
+                        "name": "acknowledged",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagesListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Enqueue a new message, optionally prioritized or scheduled for a later time. Messages violating a reject rule of the content policy are refused with 422, those violating a quarantine rule are enqueued as quarantined. A message with expires_at is expired instead of sent once it passed, a status notice is posted to its callback_url, or the one of the API key, when it expires, is blocked or is dead-lettered.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Create Message",
+                "parameters": [
+                    {
+                        "description": "Message to enqueue",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/async": {
+            "post": {
+                "description": "Accept a JSON array, or one JSON object per line, of up to ingestion.max_messages messages and answer right away with the ID of the ingestion job validating and enqueueing them in the background. Invalid and duplicate messages are rejected without stopping the job, messages violating a reject rule of the content policy too. A job fails when enqueueing fails, e.g. on an exceeded quota, the messages enqueued before stay enqueued. Follow the job with GET /api/v1/messages/async/{id}. Payloads over ingestion.max_messages are refused with 413, with every worker busy and ingestion.max_queued jobs waiting with 429.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Ingest Messages Asynchronously",
+                "parameters": [
+                    {
+                        "description": "Messages to enqueue",
+                        "name": "messages",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.CreateMessageRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestionJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/async/{id}": {
+            "get": {
+                "description": "Get the state of an asynchronous ingestion job with the messages read, enqueued, duplicate and rejected so far and the first rejected rows",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Ingestion Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ingestion job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestionJobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/duplicates": {
+            "get": {
+                "description": "Report the recipients given the same content more than once in a window of creation times, each message created within the window of the one before, with the API links of the messages. The groups created last come first; at most limit recipient and content pairs are examined.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Duplicate Messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Created at or after (YYYY-MM-DD or RFC3339, default: 24h before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (YYYY-MM-DD or RFC3339, default: now)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Longest gap between two messages of a group (default: 10m)",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Recipient and content pairs examined (default: 100, max: 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DuplicatesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/export": {
+            "post": {
+                "description": "Accept an export of the messages matching the filters to a CSV or JSON lines file and answer right away with the job writing it in the background. The messages are read page by page, jobs.export.page_size at a time and no faster than jobs.export.rows_per_second, so large exports do not compete with the scheduler for the database. Follow the job with GET /api/v1/jobs/{id} and download the file from its result_url once it succeeded. Exports matching more than jobs.export.max_messages messages are refused with 422, with every worker busy and jobs.max_queued jobs waiting with 429.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Export Messages",
+                "parameters": [
+                    {
+                        "description": "Format and filters of the export",
+                        "name": "export",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ExportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/replay": {
+            "post": {
+                "description": "Clone the messages sent (accepted, sent, delivered, unconfirmed or accepted_without_id) within a window and enqueue the clones, e.g. after a delivery blackout of the provider. Run it with dry_run first and pass the matched count as expected_count, a different count is refused with 409. Windows longer than replay.max_window are refused with 400, more matches than replay.max_messages with 422. Messages replayed before are skipped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Replay Messages",
+                "parameters": [
+                    {
+                        "description": "Window and filters of the replay",
+                        "name": "replay",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReplayRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReplayResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/stats/timeseries": {
+            "get": {
+                "description": "Messages created, sent and failed per UTC minute, hour, day or week, with zero counts for the buckets without messages. Sent messages are counted when they were sent, failed ones when they failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Message Time Series",
+                "parameters": [
+                    {
+                        "enum": [
+                            "1m",
+                            "1h",
+                            "1d",
+                            "1w"
+                        ],
+                        "type": "string",
+                        "description": "Bucket width (default: 1h)",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "From (YYYY-MM-DD or RFC3339, default: 24 buckets before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "To, exclusive (YYYY-MM-DD or RFC3339, default: now)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TimeseriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/status": {
+            "patch": {
+                "description": "Cancel pending messages (action cancel) or move failed messages back to the queue (action requeue) in one transaction, at most 1000 IDs per request. Messages in another status are skipped, the results report updated, skipped or not_found per ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Bulk Status Change",
+                "parameters": [
+                    {
+                        "description": "Message IDs and action",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BulkStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BulkStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/validate": {
+            "post": {
+                "description": "Validate a message like Create Message without enqueueing it, and return the encoding (gsm7 or ucs2) and the number of SMS segments it is sent and billed as",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Validate Message",
+                "parameters": [
+                    {
+                        "description": "Message to validate",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ValidateMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}": {
+            "get": {
+                "description": "Get details of a specific message by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get Message by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/attempts": {
+            "get": {
+                "description": "Get every webhook request of the sends of a message with its provider, timings, status code and error, oldest first, e.g. to see that a message took 4 tries over 90 seconds. Recorded with messaging.send_attempts.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Send Attempts",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SendAttemptsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/cancel": {
+            "post": {
+                "description": "Cancel a pending message, or a sending one whose webhook call was not issued yet, e.g. to stop a message sent by mistake. A cancelled message is never sent. Once the webhook call was issued the cancel fails with 409 send_already_issued and the message ends sent or failed as usual. A message sending on another instance fails with 409 send_not_local, only the instance that claimed it can cancel the send.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Cancel Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/events": {
+            "get": {
+                "description": "Get why the scheduler skipped a claimed message instead of sending it, oldest first: suppressed recipient, failed suppression check, throttled, rate limited, failed route, stopped scheduler, cancelled send or expiry. Recorded with messaging.skip_events.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Message Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessageEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/links": {
+            "get": {
+                "description": "Get the tracked short links of a message with their click counts",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Message Links",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessageLinksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/prioritize": {
+            "post": {
+                "description": "Raise the priority of a pending message above every other pending message and clear its scheduled time, so it is claimed next",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Prioritize Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/release": {
+            "post": {
+                "description": "Move a message quarantined by the content policy to pending so it is sent",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Release Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messages/{id}/triage": {
+            "patch": {
+                "description": "Set the operator note of a failed or dead-lettered message and acknowledge its failure, so the next on-call knows which failures were investigated already. The acknowledgment records the API key, acknowledging again keeps the first one and false withdraws it. It is cleared when the message fails again, the note is kept. List the open failures with status=failed\u0026acknowledged=false.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Triage Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID, or its ULID or UUID public ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Note and acknowledgment, fields left out are kept",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.TriageMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/forecast": {
+            "get": {
+                "description": "Estimate when the pending messages of every campaign are sent, following the batches of the scheduler with its interval, batch size, route rate limits and throttle profiles, or with the settings given to answer what-if questions before changing them. Assumes messaging runs from now, sends succeed and no more messages arrive. The campaigns draining last come first, drain_at is unset for those not drained within the horizon.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Messaging Forecast",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Interval between batches (default: messaging.interval)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Messages claimed per batch (default: messaging.batch_size)",
+                        "name": "batch_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Messages per second of every route, 0 is unlimited (default: the route rate limits)",
+                        "name": "rate_limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/pauses": {
+            "get": {
+                "description": "Get the active pauses of phone numbers and prefix ranges ordered by prefix, expired pauses are not listed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "List Recipient Pauses",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.RecipientPausesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Pause sending to a phone number or to every number starting with a prefix, e.g. +9055 during the outage of a carrier. Their pending messages stay queued, deferred until the pause is lifted or expires and checked again every messaging.pause_recheck. Pausing a paused prefix replaces its reason and expiry.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Pause Recipients",
+                "parameters": [
+                    {
+                        "description": "Phone number or prefix to pause",
+                        "name": "pause",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RecipientPauseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleRecipientPauseResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/pauses/{prefix}": {
+            "delete": {
+                "description": "Lift the pause of a phone number or prefix, its messages are sent again within messaging.pause_recheck",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Resume Recipients",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Paused phone number or prefix (+ may be encoded as %2B)",
+                        "name": "prefix",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/start": {
+            "post": {
+                "description": "Start the automatic message sending process, on every instance when messaging.control_interval is set. Starting a running service is 409 with code already_running, or 200 with idempotent=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Start Messaging Service",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Answer 200 when the service is already running",
+                        "name": "idempotent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/status": {
+            "get": {
+                "description": "Get the current status of the automatic message sending service",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Get Messaging Service Status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagingStatusResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/messaging/stop": {
+            "post": {
+                "description": "Stop the automatic message sending process, on every instance when messaging.control_interval is set. Stopping a stopped service is 409 with code not_running, or 200 with idempotent=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messaging"
+                ],
+                "summary": "Stop Messaging Service",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Answer 200 when the service is not running",
+                        "name": "idempotent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.MessagingControlResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/providers/{name}/sla": {
+            "get": {
+                "description": "Report how a provider served the attempts sent to it in a window: the share of attempts that succeeded, the average and p50/p95/p99 latency, and the downtime windows of consecutive minutes in which every attempt failed. Without bounds the window is the last 30 days, without to it ends now and without from it starts 30 days before to.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "providers"
+                ],
+                "summary": "Provider SLA",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name, webhook for webhook.url",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Attempts started at or after (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Attempts started before (YYYY-MM-DD or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ProviderSLAResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/recipients/{phone}/stats": {
+            "get": {
+                "description": "Summarize the messages to a phone number for support cases like a customer never receiving texts: the counts by status, when the last one was delivered (or sent without a delivery report), the last failure with its provider error, the failures in a row since the last success and the longest such run, and whether the number is suppressed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Recipient Stats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Phone number (E.164, + may be encoded as %2B)",
+                        "name": "phone",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.RecipientStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/schemas": {
+            "get": {
+                "description": "The JSON Schemas (draft 2020-12) of the request and response bodies of this API version, to generate clients from and validate payloads against. They are generated from the DTOs of the running version. Requires no API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "JSON Schemas",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SchemasResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/schemas/{name}": {
+            "get": {
+                "description": "The JSON Schema of a request or response body, the structs it refers to are bundled in $defs. Fields without omitempty are required in responses. Requires no API key.",
+                "produces": [
+                    "application/schema+json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "JSON Schema",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Schema name, e.g. CreateMessageRequest, with or without .json",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats": {
+            "get": {
+                "description": "Message counts per status, today's throughput and failure rate and the age of the oldest pending message",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Message Statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.StatsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/suppressions": {
+            "get": {
+                "description": "Get a paginated list of suppressed recipients, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "List Suppressions",
+                "parameters": [
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page number (default: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "description": "Page size (default: 20, max: 100)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SuppressionsListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Suppress a recipient, their pending and new messages are blocked instead of sent",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Suppress Recipient",
+                "parameters": [
+                    {
+                        "description": "Recipient to suppress",
+                        "name": "suppression",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateSuppressionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleSuppressionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/suppressions/import": {
+            "post": {
+                "description": "Suppress every number of an opt-out list posted as the request body, e.g. the do-not-contact list of a previous vendor. CSV lists name their number column phone, phone_number, number, msisdn, mobile, recipient or to (an optional reason column is kept), or have the number in their first column without a header; text lists hold one number per line. Numbers are normalized to E.164, MSISDNs without a + included. Repeated and already suppressed numbers are counted and skipped, invalid rows are reported without stopping the import. With dry_run nothing is suppressed.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Import Suppressions",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "text"
+                        ],
+                        "type": "string",
+                        "description": "List format (default: text for text/plain bodies, csv otherwise)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reason of the numbers without one of their own",
+                        "name": "reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Report what the import would do without suppressing any number",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Opt-out list",
+                        "name": "list",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SuppressionImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/suppressions/{phone}": {
+            "delete": {
+                "description": "Lift the suppression of a recipient, messages blocked in the meantime stay blocked",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Unsuppress Recipient",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Suppressed phone number (E.164, + may be encoded as %2B)",
+                        "name": "phone",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/usage": {
+            "get": {
+                "description": "Messages created per API key and tenant in the current UTC day and month, with their quotas",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Quota Usage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.UsageResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/webhooks/overrides": {
+            "get": {
+                "description": "Get the webhooks the messages of tenants and campaigns are sent to instead of webhook.url, ordered by scope and name. Credentials are not returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List Webhook Overrides",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.WebhookOverridesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/api/v1/webhooks/overrides/{scope}/{name}": {
+            "put": {
+                "description": "Send the messages of a tenant or campaign routed to webhook.url to their own webhook, e.g. the gateway of a white-label customer. The override of a message's campaign wins over its tenant's, routes to named providers are not overridden. A token is sent as a bearer token, a username and password with basic authentication, both are encrypted with webhook.encryption_key and never returned. Messages whose credentials cannot be decrypted, e.g. after the key changed, are requeued instead of sent to webhook.url.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Set Webhook Override",
+                "parameters": [
+                    {
+                        "enum": [
+                            "tenant",
+                            "campaign"
+                        ],
+                        "type": "string",
+                        "description": "Scope",
+                        "name": "scope",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant or campaign",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook of the tenant or campaign",
+                        "name": "override",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.WebhookOverrideRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SingleWebhookOverrideResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Remove the webhook of a tenant or campaign with its credentials, their messages are sent to webhook.url again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete Webhook Override",
+                "parameters": [
+                    {
+                        "enum": [
+                            "tenant",
+                            "campaign"
+                        ],
+                        "type": "string",
+                        "description": "Scope",
+                        "name": "scope",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant or campaign",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/l/{code}": {
+            "get": {
+                "description": "Count a click on a tracked short link and redirect to its URL. Public, recipients open it from their messages.",
+                "tags": [
+                    "links"
+                ],
+                "summary": "Follow Short Link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Link code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check if the service and its dependencies are ready to serve traffic",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.BuildInfo": {
+            "type": "object",
+            "properties": {
+                "commit": {
+                    "type": "string",
+                    "example": "019c44c7a3e1f5b2d8c6e4a9b0f1d2c3e4a5b6c7"
+                },
+                "commit_time": {
+                    "type": "string",
+                    "example": "2026-10-17T09:30:00Z"
+                },
+                "go_version": {
+                    "type": "string",
+                    "example": "go1.24.0"
+                },
+                "modified": {
+                    "description": "Modified is whether the checkout had uncommitted changes",
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "0.1.0"
+                }
+            }
+        },
+        "dto.BulkStatusRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is cancel (pending to cancelled) or requeue (failed to pending)",
+                    "type": "string",
+                    "example": "cancel"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        41,
+                        42
+                    ]
+                }
+            }
+        },
+        "dto.BulkStatusResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "cancel"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BulkStatusResult"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "updated": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "dto.BulkStatusResult": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "result": {
+                    "description": "Result is updated, skipped when the message is not in the status the action applies to, or not_found",
+                    "type": "string",
+                    "example": "updated"
+                },
+                "status": {
+                    "description": "Status is the status of the message after the change, empty when it was not found",
+                    "type": "string",
+                    "example": "cancelled"
+                }
+            }
+        },
+        "dto.CampaignClicks": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "type": "string",
+                    "example": "spring-sale"
+                },
+                "click_rate": {
+                    "description": "ClickRate is clicked messages / messages, between 0 and 1",
+                    "type": "number"
+                },
+                "clicked_messages": {
+                    "description": "ClickedMessages is the number of messages with at least one clicked link",
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "links": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "description": "Messages is the number of messages with at least one tracked link",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.CampaignClicksResponse": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.CampaignClicks"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CampaignCursor": {
+            "type": "object",
+            "properties": {
+                "last_message_id": {
+                    "type": "integer",
+                    "example": 48211
+                },
+                "last_sent_at": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "integer",
+                    "example": 12800
+                },
+                "recipients": {
+                    "description": "Recipients is the number of recipients the campaign sent a message to, Messages the number of messages",
+                    "type": "integer",
+                    "example": 12500
+                }
+            }
+        },
+        "dto.CampaignForecast": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "description": "Campaign is empty for the messages without a campaign",
+                    "type": "string",
+                    "example": "spring-sale"
+                },
+                "drain_at": {
+                    "type": "string"
+                },
+                "drain_seconds": {
+                    "description": "DrainSeconds and DrainAt are when the last message is expected to be sent, unset when it is past the horizon",
+                    "type": "integer",
+                    "example": 3600
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "scheduled": {
+                    "description": "Scheduled counts the pending messages scheduled for later",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.CampaignRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Spring sale to every opted-in customer"
+                },
+                "name": {
+                    "description": "Name is the campaign of the messages, at most 64 letters, digits, '.', '_' or '-'",
+                    "type": "string",
+                    "example": "spring-sale"
+                }
+            }
+        },
+        "dto.CampaignResponse": {
+            "type": "object",
+            "properties": {
+                "approved_at": {
+                    "type": "string"
+                },
+                "approved_by": {
+                    "type": "string",
+                    "example": "ops"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "cursor": {
+                    "description": "Cursor is how far the launched campaign got, omitted until it sent a message",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dto.CampaignCursor"
+                        }
+                    ]
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Spring sale to every opted-in customer"
+                },
+                "launched_at": {
+                    "type": "string"
+                },
+                "launched_by": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "spring-sale"
+                },
+                "paused_at": {
+                    "type": "string"
+                },
+                "paused_by": {
+                    "description": "PausedBy and ResumedBy are the last pause and resume of the launched campaign",
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "pending": {
+                    "description": "Pending is the number of pending messages of the campaign, the size of the blast while it is held",
+                    "type": "integer",
+                    "example": 25000
+                },
+                "rejected_by": {
+                    "description": "RejectedBy and Rejection are the last rejection, which sent the campaign back to draft",
+                    "type": "string",
+                    "example": "ops"
+                },
+                "rejection": {
+                    "type": "string",
+                    "example": "Link points to the staging shop"
+                },
+                "resumed_at": {
+                    "type": "string"
+                },
+                "resumed_by": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "status": {
+                    "description": "Status is draft, pending_approval, approved, launched or paused, the messages of the campaign are held until\nlaunched and while paused",
+                    "type": "string",
+                    "example": "pending_approval"
+                },
+                "submitted_at": {
+                    "type": "string"
+                },
+                "submitted_by": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CampaignReviewRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Link points to the staging shop"
+                }
+            }
+        },
+        "dto.CampaignsResponse": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.CampaignResponse"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CostReportResponse": {
+            "type": "object",
+            "properties": {
+                "costs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.CostSummary"
+                    }
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "USD"
+                },
+                "group_by": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/dto.CostSummary"
+                }
+            }
+        },
+        "dto.CostSummary": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "type": "number",
+                    "example": 1.25
+                },
+                "key": {
+                    "description": "Key is the UTC day, campaign or tenant, empty for messages without a campaign or tenant",
+                    "type": "string",
+                    "example": "2026-10-16"
+                },
+                "messages": {
+                    "type": "integer"
+                },
+                "segments": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.CreateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "description": "CallbackURL receives a status notice once the message expires, is blocked or is dead-lettered, the\ncallback_url of the API key when empty",
+                    "type": "string",
+                    "example": "https://app.example.com/sms/notices"
+                },
+                "campaign": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the message stops being worth sending, e.g. the end of a one-time password's validity.\nA message claimed later is expired instead of sent.",
+                    "type": "string",
+                    "example": "2026-10-17T09:05:00Z"
+                },
+                "from": {
+                    "description": "From is the registered sender ID or number to send from instead of the sender ID of the route, at most\n11 characters unless it is an E.164 number",
+                    "type": "string",
+                    "example": "ACME"
+                },
+                "metadata": {
+                    "description": "Metadata are string values like order_id or customer_id, \"tags\" is a list of tags",
+                    "type": "object"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "send_at": {
+                    "type": "string"
+                },
+                "send_at_local": {
+                    "description": "SendAtLocal is the send time in Timezone instead of SendAt: HH:MM is sent at its next occurrence,\nYYYY-MM-DDTHH:MM at that date. It is converted to UTC when the message is enqueued.",
+                    "type": "string",
+                    "example": "09:00"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "Timezone is the IANA timezone of the recipient",
+                    "type": "string",
+                    "example": "Europe/Istanbul"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateSuppressionRequest": {
+            "type": "object",
+            "properties": {
+                "phone": {
+                    "type": "string",
+                    "example": "+905551234567"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Complaint"
+                }
+            }
+        },
+        "dto.DeadLetterCount": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "description": "Key is the reason, class, prefix or provider, empty for messages without one",
+                    "type": "string",
+                    "example": "provider returned status 503"
+                },
+                "messages": {
+                    "type": "integer",
+                    "example": 412
+                },
+                "share": {
+                    "description": "Share is Messages as a share of every dead-lettered message in the window, between 0 and 1",
+                    "type": "number",
+                    "example": 0.37
+                }
+            }
+        },
+        "dto.DeadLetterHeatmapResponse": {
+            "type": "object",
+            "properties": {
+                "counts": {
+                    "description": "Counts are the messages dead-lettered by weekday (0 is Sunday) and hour, Counts[1][9] is Mondays 09:00-10:00 UTC",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer",
+                            "format": "int64"
+                        }
+                    }
+                },
+                "peak_hour": {
+                    "type": "integer",
+                    "example": 9
+                },
+                "peak_weekday": {
+                    "description": "PeakWeekday and PeakHour are the hour with the most dead-lettered messages",
+                    "type": "integer",
+                    "example": 1
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 1113
+                }
+            }
+        },
+        "dto.DeadLetterTopResponse": {
+            "type": "object",
+            "properties": {
+                "group_by": {
+                    "type": "string",
+                    "example": "reason"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DeadLetterCount"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total is the number of messages dead-lettered in the window, Groups only lists the top ones",
+                    "type": "integer",
+                    "example": 1113
+                }
+            }
+        },
+        "dto.DeliveryReportRequest": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At is when the provider observed the state, the time the report was received when empty",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Handset unreachable"
+                },
+                "message_id": {
+                    "description": "MessageID is the message ID the webhook returned when it accepted the message",
+                    "type": "string",
+                    "example": "67f2f8a8-ea58-4ed0-a6f9-ff217df4d849"
+                },
+                "status": {
+                    "description": "Status is sent, delivered, failed or undelivered (same as failed)",
+                    "type": "string",
+                    "example": "delivered"
+                }
+            }
+        },
+        "dto.DeliveryReportResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied is false when the message already reached a later status, e.g. a sent report after delivered",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message_status": {
+                    "type": "string",
+                    "example": "delivered"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.DowntimeWindow": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 96
+                },
+                "duration_seconds": {
+                    "type": "integer",
+                    "example": 840
+                },
+                "end": {
+                    "description": "End is the end of the last down minute",
+                    "type": "string",
+                    "example": "2026-09-14T02:24:00Z"
+                },
+                "start": {
+                    "type": "string",
+                    "example": "2026-09-14T02:10:00Z"
+                }
+            }
+        },
+        "dto.DuplicateGroup": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "first_at": {
+                    "type": "string"
+                },
+                "last_at": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DuplicateMessage"
+                    }
+                },
+                "to": {
+                    "type": "string",
+                    "example": "+905551234567"
+                }
+            }
+        },
+        "dto.DuplicateMessage": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is left out like the one of MessageResponse",
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string",
+                    "example": "/api/v1/messages/42"
+                },
+                "public_id": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "sent"
+                }
+            }
+        },
+        "dto.DuplicatesResponse": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "description": "Duplicates counts the messages of the groups after their first one",
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DuplicateGroup"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "description": "Truncated is true when more recipient and content pairs repeated than the limit",
+                    "type": "boolean"
+                },
+                "window_seconds": {
+                    "type": "integer",
+                    "example": 600
+                }
+            }
+        },
+        "dto.EgressResponse": {
+            "type": "object",
+            "properties": {
+                "egress_ips": {
+                    "description": "EgressIPs are the public IPs providers see the requests from, as configured in webhook.egress_ips",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "interface": {
+                    "description": "Interface is the network interface the webhook client is bound to",
+                    "type": "string",
+                    "example": "eth1"
+                },
+                "local_addresses": {
+                    "description": "LocalAddresses are the addresses the webhook client is bound to, empty when the system picks them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ErasureRecord": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "links": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "messages": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "mode": {
+                    "type": "string",
+                    "example": "anonymize"
+                },
+                "phone_hash": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "DSR-1042"
+                },
+                "requested_by": {
+                    "type": "string",
+                    "example": "default"
+                },
+                "suppression": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.ErasureRequest": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "description": "DryRun only counts the messages and links that would be erased",
+                    "type": "boolean"
+                },
+                "include_suppression": {
+                    "description": "IncludeSuppression also lifts the suppression of the number, by default the opt-out is kept so the\nnumber is not messaged again",
+                    "type": "boolean"
+                },
+                "mode": {
+                    "description": "Mode is delete (the messages are deleted) or anonymize (the messages are kept for statistics without\nthe number, content, provider responses and metadata)",
+                    "type": "string",
+                    "example": "anonymize"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+905551234567"
+                },
+                "reference": {
+                    "description": "Reference is stored with the audit record, e.g. the ticket of the request",
+                    "type": "string",
+                    "example": "DSR-1042"
+                }
+            }
+        },
+        "dto.ErasureResponse": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "erasure": {
+                    "description": "Erasure is the audit record, unset for a dry run",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dto.ErasureRecord"
+                        }
+                    ]
+                },
+                "links": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "messages": {
+                    "description": "Messages and Links are the numbers of messages and tracked links that match, or that were erased",
+                    "type": "integer",
+                    "example": 12
+                },
+                "status": {
+                    "type": "string"
+                },
+                "suppressed": {
+                    "description": "Suppressed is whether the number is suppressed, it stays suppressed unless include_suppression is set",
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ErasuresListResponse": {
+            "type": "object",
+            "properties": {
+                "erasures": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ErasureRecord"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ErrorCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "SP2004"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "No message has the ID"
+                },
+                "http_status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "name": {
+                    "description": "Name is a readable alias of the code, as stable as the code itself",
+                    "type": "string",
+                    "example": "message_not_found"
+                }
+            }
+        },
+        "dto.ErrorCodesResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ErrorCode"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is the stable code of the error from the ErrorCodes catalog, match it instead of the message",
+                    "type": "string",
+                    "example": "SP2004"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ExportRequest": {
+            "type": "object",
+            "properties": {
+                "acknowledged": {
+                    "description": "Acknowledged exports only the messages whose failure was acknowledged when true, the others when false",
+                    "type": "boolean"
+                },
+                "dead_lettered": {
+                    "description": "DeadLettered exports only the failed messages in the dead-letter queue",
+                    "type": "boolean"
+                },
+                "format": {
+                    "description": "Format is csv (the default) or jsonl",
+                    "type": "string",
+                    "example": "csv"
+                },
+                "from": {
+                    "description": "From and To bound the creation time of the messages, as YYYY-MM-DD or RFC3339",
+                    "type": "string",
+                    "example": "2026-10-01"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "failed"
+                },
+                "tag": {
+                    "description": "Tag and Metadata match the metadata of the messages",
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2026-11-01"
+                }
+            }
+        },
+        "dto.ForecastResponse": {
+            "type": "object",
+            "properties": {
+                "batch_size": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.CampaignForecast"
+                    }
+                },
+                "drain_at": {
+                    "type": "string"
+                },
+                "drain_seconds": {
+                    "description": "DrainSeconds and DrainAt are when the last pending message is expected to be sent, unset when it is past\nthe horizon",
+                    "type": "integer",
+                    "example": 3600
+                },
+                "horizon": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "5s"
+                },
+                "messages_per_second": {
+                    "description": "MessagesPerSecond is the average send rate until the backlog is drained",
+                    "type": "number",
+                    "example": 0.4
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "rate_limit": {
+                    "description": "RateLimit is the messages per second every route was limited to, unset for the configured route limits",
+                    "type": "number"
+                },
+                "scheduled": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "database": {
+                    "description": "Database is up or down, the status is degraded while it is down",
+                    "type": "string",
+                    "example": "up"
+                },
+                "listen": {
+                    "description": "Listen are the addresses the server listens on",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        ":8080",
+                        "[::]:8080"
+                    ]
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.InboundMessageRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string",
+                    "example": "STOP"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "+905551234567"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.InboundMessageResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is suppressed or unsuppressed when the message was a keyword, none otherwise",
+                    "type": "string",
+                    "example": "suppressed"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.IngestionJobResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "enqueued": {
+                    "type": "integer",
+                    "example": 119950
+                },
+                "error": {
+                    "description": "Error is why a failed job stopped, the messages enqueued before stay enqueued",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01J9ZQ4K8M3V6X2T5R7N0B1C4D"
+                },
+                "read": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "rejected": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "rejections": {
+                    "description": "Rejections are the first 100 rejected rows",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.IngestionRejection"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "State is queued, running, succeeded or failed",
+                    "type": "string",
+                    "example": "running"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total is the number of messages in the payload, Read the ones processed so far",
+                    "type": "integer",
+                    "example": 500000
+                }
+            }
+        },
+        "dto.IngestionRejection": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer",
+                    "example": 17
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "duplicate of an earlier row"
+                }
+            }
+        },
+        "dto.JobResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "done": {
+                    "type": "integer",
+                    "example": 20000
+                },
+                "error": {
+                    "description": "Error is why a failed job stopped",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the job and its output are deleted",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "01J9ZQ4K8M3V6X2T5R7N0B1C4D"
+                },
+                "kind": {
+                    "description": "Kind is export or ingestion",
+                    "type": "string",
+                    "example": "export"
+                },
+                "progress": {
+                    "description": "Progress is the share of the job done in percent, Done of Total units of work, e.g. messages",
+                    "type": "integer",
+                    "example": 40
+                },
+                "result": {
+                    "description": "Result summarizes what a succeeded job did",
+                    "type": "string",
+                    "example": "Exported 50000 messages"
+                },
+                "result_url": {
+                    "description": "ResultURL downloads the output of a succeeded job until ExpiresAt",
+                    "type": "string",
+                    "example": "/api/v1/jobs/01J9ZQ4K8M3V6X2T5R7N0B1C4D/result"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "State is queued, running, succeeded or failed",
+                    "type": "string",
+                    "example": "running"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 50000
+                }
+            }
+        },
+        "dto.LimitsResponse": {
+            "type": "object",
+            "properties": {
+                "api_key": {
+                    "type": "string",
+                    "example": "reporting"
+                },
+                "quotas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.QuotaLimit"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.LinkResponse": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "code": {
+                    "type": "string",
+                    "example": "aZ3kP9qR"
+                },
+                "last_clicked_at": {
+                    "type": "string"
+                },
+                "short_url": {
+                    "type": "string",
+                    "example": "https://sp.example.com/l/aZ3kP9qR"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/spring-sale"
+                }
+            }
+        },
+        "dto.MaintenanceJobResponse": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_finished_at": {
+                    "type": "string"
+                },
+                "last_holder": {
+                    "type": "string"
+                },
+                "last_result": {
+                    "type": "string",
+                    "example": "reset 3 stuck messages to pending"
+                },
+                "last_started_at": {
+                    "type": "string"
+                },
+                "last_status": {
+                    "description": "LastStatus is running, succeeded or failed, empty when the job never ran",
+                    "type": "string",
+                    "example": "succeeded"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "stuck_reaper"
+                },
+                "next_run_at": {
+                    "description": "NextRunAt is when the instance runs the job next, unless another instance takes the run",
+                    "type": "string"
+                },
+                "runs": {
+                    "type": "integer"
+                },
+                "schedule": {
+                    "type": "string",
+                    "example": "@every 1m"
+                }
+            }
+        },
+        "dto.MaintenanceJobsResponse": {
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.MaintenanceJobResponse"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MessageEventResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "deferred until 2026-10-16T09:30:00Z"
+                },
+                "reason": {
+                    "description": "Reason is suppressed, suppression_check_failed, throttled, rate_limited, route_failed, transform_failed, held or stopped",
+                    "type": "string",
+                    "example": "throttled"
+                }
+            }
+        },
+        "dto.MessageEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.MessageEventResponse"
+                    }
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MessageLinksResponse": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.LinkResponse"
+                    }
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MessageResponse": {
+            "type": "object",
+            "properties": {
+                "acknowledged_at": {
+                    "description": "AcknowledgedAt and AcknowledgedBy, the API key name, are set once an operator acknowledged the failure.\nThey are cleared when the message fails again.",
+                    "type": "string"
+                },
+                "acknowledged_by": {
+                    "type": "string",
+                    "example": "oncall"
+                },
+                "callback_url": {
+                    "description": "CallbackURL receives the status notices of the message",
+                    "type": "string"
+                },
+                "campaign": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Cost is the segments times the unit price of the route, in routing.currency, once the message was sent",
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "dead_lettered_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "delivery_error": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "type": "string",
+                    "example": "gsm7"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the message stops being worth sending, it is expired instead of sent later",
+                    "type": "string"
+                },
+                "failure_class": {
+                    "description": "FailureClass and FailureError are the class and error of the last failed send. The transient failures,\nnetwork and provider_temporary, are requeued automatically until DeadLetteredAt is set.",
+                    "type": "string",
+                    "example": "provider_temporary"
+                },
+                "failure_error": {
+                    "type": "string"
+                },
+                "from": {
+                    "description": "From is the sender requested when the message was created",
+                    "type": "string",
+                    "example": "ACME"
+                },
+                "id": {
+                    "description": "ID is left out for messages with a public ID when database.id_strategy is ulid or uuid",
+                    "type": "integer"
+                },
+                "message_id": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "Metadata are the caller defined fields set when the message was created",
+                    "type": "object"
+                },
+                "note": {
+                    "description": "Note is the operator note of a failed message",
+                    "type": "string",
+                    "example": "Carrier outage, retry after 14:00 UTC"
+                },
+                "policy_violations": {
+                    "description": "PolicyViolations are the content policy rules the message violated without being rejected",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "provider": {
+                    "description": "Provider and SenderID are those of the route the message was last sent through, SenderID is From when set",
+                    "type": "string",
+                    "example": "webhook"
+                },
+                "public_id": {
+                    "description": "PublicID is the ULID or UUID of the message with database.id_strategy ulid or uuid, the ID to share\nwith external clients and the only one the message is fetched by with those strategies.",
+                    "type": "string",
+                    "example": "01J9ZQ4K8M3V6X2T5R7N0B1C4D"
+                },
+                "region": {
+                    "description": "Region is the region.name of the instance that last claimed the message",
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "rendered_content": {
+                    "description": "RenderedContent is the content sent after the transform pipeline of the campaign or tenant rewrote it,\nEncoding and Segments are the ones of the sent content then",
+                    "type": "string",
+                    "example": "Your order shipped https://sp.example.com/l/aB3xY9kQ Reply STOP to opt out"
+                },
+                "replay_of": {
+                    "description": "ReplayOf is the ID of the message this one was cloned from by a replay, left out like ID",
+                    "type": "integer"
+                },
+                "requeues": {
+                    "type": "integer"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "segments": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "sender_id": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "Timezone is the IANA timezone of the recipient, set when the message was created",
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "webhook_response": {
+                    "description": "WebhookResponse is the stored webhook response. Its raw body is the body field, or referenced by payload_ref\nwhen stored outside the message and only read back by the single message endpoint.",
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "dto.MessagesListResponse": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.MessageResponse"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor continues the list after this page, set when the page is full on lists of any status",
+                    "type": "string"
+                },
+                "page": {
+                    "description": "Page is 0 for a page continued from a cursor",
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.MessagingControlResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code tells what the request did, see the Control codes",
+                    "type": "string",
+                    "example": "started"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MessagingStatusResponse": {
+            "type": "object",
+            "properties": {
+                "batch_size": {
+                    "type": "integer"
+                },
+                "cluster_enabled": {
+                    "description": "ClusterEnabled is whether messaging is started across the cluster, set when messaging.control_interval is",
+                    "type": "boolean"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "max_retries": {
+                    "type": "integer"
+                },
+                "paused": {
+                    "description": "Paused is set while the database is unreachable, the server is read-only or its region is passive and no\nmessages are claimed",
+                    "type": "boolean"
+                },
+                "region": {
+                    "description": "Region and RegionRole are the region.name of the instance and whether the region is active or passive",
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "region_role": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "retry_delay": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "Role is leader or standby when messaging.leader_lease is set",
+                    "type": "string",
+                    "example": "leader"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ProviderSLAResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 182340
+                },
+                "availability": {
+                    "description": "Availability is the share of the window outside of Downtime, between 0 and 1",
+                    "type": "number",
+                    "example": 0.9997
+                },
+                "avg_latency_ms": {
+                    "description": "The latencies of the attempts in milliseconds, percentiles are nearest-rank",
+                    "type": "number",
+                    "example": 212.4
+                },
+                "downtime": {
+                    "description": "Downtime are the windows in which every attempt failed, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.DowntimeWindow"
+                    }
+                },
+                "downtime_seconds": {
+                    "type": "integer",
+                    "example": 840
+                },
+                "from": {
+                    "type": "string",
+                    "example": "2026-08-18T00:00:00Z"
+                },
+                "p50_latency_ms": {
+                    "type": "number",
+                    "example": 180
+                },
+                "p95_latency_ms": {
+                    "type": "number",
+                    "example": 410
+                },
+                "p99_latency_ms": {
+                    "type": "number",
+                    "example": 1250
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "twilio"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "succeeded": {
+                    "type": "integer",
+                    "example": 181902
+                },
+                "success_rate": {
+                    "description": "SuccessRate is Succeeded as a share of Attempts between 0 and 1, 1 without attempts",
+                    "type": "number",
+                    "example": 0.9976
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2026-09-17T00:00:00Z"
+                }
+            }
+        },
+        "dto.QuotaLimit": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "period": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "remaining": {
+                    "type": "integer",
+                    "example": 750
+                },
+                "reset": {
+                    "description": "Reset is when the period ends and the quota is available again",
+                    "type": "string"
+                },
+                "used": {
+                    "type": "integer",
+                    "example": 250
+                }
+            }
+        },
+        "dto.ReadOnlyRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "dto.ReadOnlyResponse": {
+            "type": "object",
+            "properties": {
+                "changed_by": {
+                    "type": "string",
+                    "example": "ops"
+                },
+                "enabled": {
+                    "description": "Enabled is whether the read-only mode is enabled, messaging is paused while it is",
+                    "type": "boolean"
+                },
+                "schema": {
+                    "description": "Schema is set when the server runs read-only because the database schema does not match the binary,\ndisabling the mode does not lift it",
+                    "type": "boolean"
+                },
+                "since": {
+                    "description": "Since is when the mode was last enabled or disabled, ChangedBy the API key that did it or config",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.RecipientFailure": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "absent subscriber"
+                },
+                "failed_at": {
+                    "description": "FailedAt is when the message was marked failed, Error the failure the provider reported if any",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID is left out like the one of MessageResponse",
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string",
+                    "example": "/api/v1/messages/42"
+                },
+                "public_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.RecipientPauseRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "ExpiresAt lifts the pause by itself, without it the pause lasts until it is removed",
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "+9055"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Carrier outage"
+                }
+            }
+        },
+        "dto.RecipientPauseResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "+9055"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Carrier outage"
+                }
+            }
+        },
+        "dto.RecipientPausesResponse": {
+            "type": "object",
+            "properties": {
+                "pauses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.RecipientPauseResponse"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.RecipientStatsResponse": {
+            "type": "object",
+            "properties": {
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "failure_streak": {
+                    "description": "FailureStreak is the number of messages failed in a row since the last sent or delivered one,\nLongestFailureStreak the longest such run. Both count the latest outcomes only, see OutcomesTruncated.",
+                    "type": "integer"
+                },
+                "first_message_at": {
+                    "description": "FirstMessageAt and LastMessageAt are the creation times of the first and last message to the recipient",
+                    "type": "string"
+                },
+                "last_failure": {
+                    "$ref": "#/definitions/dto.RecipientFailure"
+                },
+                "last_message_at": {
+                    "type": "string"
+                },
+                "last_success_at": {
+                    "description": "LastSuccessAt is when the last message was delivered, or sent when no delivery report arrived for it",
+                    "type": "string"
+                },
+                "longest_failure_streak": {
+                    "type": "integer"
+                },
+                "outcomes_truncated": {
+                    "description": "OutcomesTruncated is true when the recipient has more sent, delivered and failed messages than the\nstreaks are counted over",
+                    "type": "boolean"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+905551234567"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "suppressed": {
+                    "description": "Suppressed is set while the recipient is suppressed and their messages are blocked",
+                    "type": "boolean"
+                },
+                "suppression": {
+                    "$ref": "#/definitions/dto.SuppressionResponse"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.RegionResponse": {
+            "type": "object",
+            "properties": {
+                "changed_by": {
+                    "type": "string",
+                    "example": "ops"
+                },
+                "region": {
+                    "description": "Region is the region.name of the instance",
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "role": {
+                    "description": "Role is active or passive",
+                    "type": "string",
+                    "example": "passive"
+                },
+                "since": {
+                    "description": "Since is when the region got the role, ChangedBy the API key that promoted or demoted it or config",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ReplayRequest": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "type": "string"
+                },
+                "dry_run": {
+                    "description": "DryRun only counts the messages that would be replayed",
+                    "type": "boolean"
+                },
+                "expected_count": {
+                    "description": "ExpectedCount must be the count a dry run returned, the replay is refused when the count changed",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "from": {
+                    "description": "From and To bound the window the messages were sent in, To is exclusive",
+                    "type": "string",
+                    "example": "2026-10-16T09:00:00Z"
+                },
+                "provider": {
+                    "description": "Provider, Tenant and Campaign narrow the replay when set",
+                    "type": "string",
+                    "example": "provider-b"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2026-10-16T11:00:00Z"
+                }
+            }
+        },
+        "dto.ReplayResponse": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "matched": {
+                    "description": "Matched is the number of messages sent within the window that were not replayed before",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "replayed": {
+                    "description": "Replayed is the number of clones enqueued, 0 for a dry run",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.RouteProvider": {
+            "type": "object",
+            "properties": {
+                "decisions": {
+                    "description": "Decisions is the number of messages routed to the provider through the route since the instance started",
+                    "type": "integer",
+                    "example": 48211
+                },
+                "failures": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "latency_ms": {
+                    "type": "integer",
+                    "example": 180
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "provider-b"
+                },
+                "score": {
+                    "description": "Score is the health score of the provider, from the success rate and latency of its sends within the window",
+                    "type": "number",
+                    "example": 0.92
+                },
+                "sends": {
+                    "description": "Sends and Failures are the sends of the provider within the window, only transient failures count",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "share": {
+                    "description": "Share is the fraction of the messages of the route routed to the provider now, its weight times its score\nover the sum of the route",
+                    "type": "number",
+                    "example": 0.75
+                },
+                "weight": {
+                    "type": "number",
+                    "example": 3
+                }
+            }
+        },
+        "dto.RouteRouting": {
+            "type": "object",
+            "properties": {
+                "prefix": {
+                    "description": "Prefix is empty for the default route",
+                    "type": "string",
+                    "example": "+90"
+                },
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.RouteProvider"
+                    }
+                }
+            }
+        },
+        "dto.RoutingResponse": {
+            "type": "object",
+            "properties": {
+                "routes": {
+                    "description": "Routes are sorted by descending prefix length, the default route last",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.RouteRouting"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "window_seconds": {
+                    "description": "WindowSeconds is how far back the sends of a provider count towards its score",
+                    "type": "integer",
+                    "example": 300
+                }
+            }
+        },
+        "dto.RuntimeResponse": {
+            "type": "object",
+            "properties": {
+                "build": {
+                    "$ref": "#/definitions/dto.BuildInfo"
+                },
+                "config": {
+                    "description": "Config is the effective configuration keyed as in the config file, with its secrets redacted",
+                    "type": "object"
+                },
+                "gc_cycles": {
+                    "type": "integer",
+                    "example": 17
+                },
+                "gomaxprocs": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "goroutines": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "heap_alloc_bytes": {
+                    "description": "HeapAllocBytes is the memory of the live heap objects, GCCycles the garbage collections since the start",
+                    "type": "integer",
+                    "example": 12582912
+                },
+                "hostname": {
+                    "description": "Hostname and PID identify the instance among the ones sharing the database",
+                    "type": "string",
+                    "example": "sendpulse-7d9f4c-x2k8q"
+                },
+                "num_cpu": {
+                    "type": "integer",
+                    "example": 4
+                },
+                "pid": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "scheduler": {
+                    "description": "Scheduler is the scheduler of the instance, omitted when it runs none",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dto.SchedulerRuntime"
+                        }
+                    ]
+                },
+                "started_at": {
+                    "description": "StartedAt is when the process started, UptimeSeconds how long it has been running since",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "uptime_seconds": {
+                    "type": "integer",
+                    "example": 86400
+                }
+            }
+        },
+        "dto.SchedulerRuntime": {
+            "type": "object",
+            "properties": {
+                "batch_queued": {
+                    "type": "boolean"
+                },
+                "batches": {
+                    "description": "Batches is the number of running batches, BatchQueued whether a batch waits for them to finish",
+                    "type": "integer",
+                    "example": 1
+                },
+                "following": {
+                    "type": "boolean"
+                },
+                "holder": {
+                    "description": "Holder identifies the scheduler as the holder of the leader lease, Role is leader or standby while the\nlease is enabled",
+                    "type": "string",
+                    "example": "sendpulse-7d9f4c-x2k8q-1"
+                },
+                "in_flight": {
+                    "description": "InFlight is the number of messages claimed and not settled yet",
+                    "type": "integer",
+                    "example": 25
+                },
+                "paused": {
+                    "description": "Paused is whether claiming is paused, see MessagingStatusResponse",
+                    "type": "boolean"
+                },
+                "region": {
+                    "type": "string",
+                    "example": "eu-west"
+                },
+                "region_role": {
+                    "type": "string",
+                    "example": "active"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "leader"
+                },
+                "running": {
+                    "description": "Running is whether the scheduler processes batches, Following whether it follows the cluster control",
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.SchemaRef": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "CreateMessageRequest"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/api/v1/schemas/CreateMessageRequest.json"
+                }
+            }
+        },
+        "dto.SchemasResponse": {
+            "type": "object",
+            "properties": {
+                "schemas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SchemaRef"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1"
+                }
+            }
+        },
+        "dto.SendAttemptResponse": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "description": "Attempt numbers the attempts of every send of the message, starting from 1",
+                    "type": "integer",
+                    "example": 2
+                },
+                "duration_ms": {
+                    "type": "integer",
+                    "example": 212
+                },
+                "error": {
+                    "type": "string",
+                    "example": "webhook returned status: 503"
+                },
+                "finished_at": {
                     "type": "string"
                 },
-                "version": {
+                "provider": {
+                    "type": "string",
+                    "example": "webhook"
+                },
+                "started_at": {
                     "type": "string"
+                },
+                "status_code": {
+                    "description": "StatusCode is the status of the provider response, omitted when none was received",
+                    "type": "integer",
+                    "example": 503
                 }
             }
         },
-        "dto.MessageResponse": {
+        "dto.SendAttemptsResponse": {
             "type": "object",
             "properties": {
-                "content": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SendAttemptResponse"
+                    }
+                },
+                "first_attempt_at": {
+                    "description": "FirstAttemptAt and LastAttemptAt are when the first attempt started and the last one finished",
                     "type": "string"
                 },
-                "created_at": {
+                "last_attempt_at": {
                     "type": "string"
                 },
-                "id": {
+                "message_id": {
                     "type": "integer"
                 },
-                "message_id": {
+                "status": {
                     "type": "string"
                 },
-                "sent_at": {
+                "timestamp": {
                     "type": "string"
+                }
+            }
+        },
+        "dto.SingleCampaignResponse": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "$ref": "#/definitions/dto.CampaignResponse"
                 },
                 "status": {
                     "type": "string"
                 },
-                "to": {
+                "timestamp": {
                     "type": "string"
+                }
+            }
+        },
+        "dto.SingleMessageResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "$ref": "#/definitions/dto.MessageResponse"
                 },
-                "webhook_response": {
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SingleRecipientPauseResponse": {
+            "type": "object",
+            "properties": {
+                "deferred": {
+                    "description": "Deferred is the number of pending messages the pause deferred right away",
+                    "type": "integer",
+                    "example": 120
+                },
+                "pause": {
+                    "$ref": "#/definitions/dto.RecipientPauseResponse"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SingleSuppressionResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "suppression": {
+                    "$ref": "#/definitions/dto.SuppressionResponse"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SingleWebhookOverrideResponse": {
+            "type": "object",
+            "properties": {
+                "override": {
+                    "$ref": "#/definitions/dto.WebhookOverrideResponse"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "counts": {
                     "type": "object",
-                    "additionalProperties": {}
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "counts_cached_at": {
+                    "description": "CountsCachedAt is when Counts and Total were counted when they are served from the count cache",
+                    "type": "string"
+                },
+                "failed_today": {
+                    "type": "integer"
+                },
+                "failure_rate": {
+                    "description": "FailureRate is failed / (sent + failed) of today, between 0 and 1",
+                    "type": "number"
+                },
+                "oldest_pending_age_seconds": {
+                    "type": "integer"
+                },
+                "oldest_pending_at": {
+                    "type": "string"
+                },
+                "sent_today": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
-        "dto.MessagesListResponse": {
+        "dto.SuppressionImportRejection": {
             "type": "object",
             "properties": {
-                "messages": {
+                "line": {
+                    "type": "integer",
+                    "example": 17
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "not an E.164 phone number"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "n/a"
+                }
+            }
+        },
+        "dto.SuppressionImportResponse": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "description": "DryRun is true when no number was suppressed, the counts are what the import would do",
+                    "type": "boolean"
+                },
+                "duplicates": {
+                    "description": "Duplicates is the number of rows repeating the number of an earlier row",
+                    "type": "integer",
+                    "example": 3350
+                },
+                "existing": {
+                    "description": "Existing is the number of numbers that were suppressed already, they keep their suppression",
+                    "type": "integer",
+                    "example": 12408
+                },
+                "imported": {
+                    "description": "Imported is the number of numbers the import suppressed",
+                    "type": "integer",
+                    "example": 184211
+                },
+                "invalid": {
+                    "description": "Invalid is the number of rows without a phone number",
+                    "type": "integer",
+                    "example": 31
+                },
+                "read": {
+                    "description": "Read is the number of rows of the list, blank lines and comments aside",
+                    "type": "integer",
+                    "example": 200000
+                },
+                "rejections": {
+                    "description": "Rejections are the first invalid rows",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dto.MessageResponse"
+                        "$ref": "#/definitions/dto.SuppressionImportRejection"
                     }
                 },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.SuppressionResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "inbound"
+                }
+            }
+        },
+        "dto.SuppressionsListResponse": {
+            "type": "object",
+            "properties": {
                 "page": {
                     "type": "integer"
                 },
@@ -302,6 +5926,12 @@ const docTemplate = `{
                 "status": {
                     "type": "string"
                 },
+                "suppressions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.SuppressionResponse"
+                    }
+                },
                 "timestamp": {
                     "type": "string"
                 },
@@ -310,38 +5940,139 @@ const docTemplate = `{
                 }
             }
         },
-        "dto.MessagingControlResponse": {
+        "dto.TimeseriesPoint": {
             "type": "object",
             "properties": {
-                "message": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.TimeseriesResponse": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string",
+                    "example": "1h"
+                },
+                "from": {
                     "type": "string"
                 },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.TimeseriesPoint"
+                    }
+                },
                 "status": {
                     "type": "string"
                 },
                 "timestamp": {
                     "type": "string"
+                },
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "dto.MessagingStatusResponse": {
+        "dto.TriageMessageRequest": {
             "type": "object",
             "properties": {
-                "batch_size": {
+                "acknowledged": {
+                    "description": "Acknowledged marks the failure as investigated when true, false withdraws the acknowledgment",
+                    "type": "boolean",
+                    "example": true
+                },
+                "note": {
+                    "description": "Note replaces the operator note, empty clears it",
+                    "type": "string",
+                    "example": "Carrier outage, retry after 14:00 UTC"
+                }
+            }
+        },
+        "dto.UsageCounter": {
+            "type": "object",
+            "properties": {
+                "count": {
                     "type": "integer"
                 },
-                "enabled": {
-                    "type": "boolean"
+                "limit": {
+                    "description": "Limit and Remaining are omitted when the subject has no quota in the period",
+                    "type": "integer"
                 },
-                "interval": {
+                "period": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "period_start": {
                     "type": "string"
                 },
-                "max_retries": {
+                "remaining": {
                     "type": "integer"
                 },
-                "retry_delay": {
+                "scope": {
+                    "type": "string",
+                    "example": "api_key"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "reporting"
+                },
+                "used_ratio": {
+                    "description": "UsedRatio is the share of the limit used, WarningThreshold the highest quotas.warning_thresholds share it\nreached. Both are omitted without a quota.",
+                    "type": "number",
+                    "example": 0.83
+                },
+                "warning_threshold": {
+                    "type": "number",
+                    "example": 0.8
+                }
+            }
+        },
+        "dto.UsageResponse": {
+            "type": "object",
+            "properties": {
+                "counters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.UsageCounter"
+                    }
+                },
+                "status": {
                     "type": "string"
                 },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ValidateMessageResponse": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "description": "Encoding is gsm7, or ucs2 when the content has a character outside the GSM 03.38 alphabet",
+                    "type": "string",
+                    "example": "gsm7"
+                },
+                "length": {
+                    "description": "Length is the content length in septets (gsm7) or UTF-16 code units (ucs2)",
+                    "type": "integer",
+                    "example": 42
+                },
+                "segments": {
+                    "description": "Segments is the number of SMS the content is sent and billed as",
+                    "type": "integer",
+                    "example": 1
+                },
                 "status": {
                     "type": "string"
                 },
@@ -350,11 +6081,82 @@ const docTemplate = `{
                 }
             }
         },
-        "dto.SingleMessageResponse": {
+        "dto.WarmupResponse": {
             "type": "object",
             "properties": {
-                "message": {
-                    "$ref": "#/definitions/dto.MessageResponse"
+                "checks": {
+                    "description": "Checks are database, webhook_overrides and provider:\u003cname\u003e per provider, \"ok\" or the error of the step",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "duration_ms": {
+                    "type": "integer",
+                    "example": 84
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.WebhookOverrideRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://sms.acme.example/send"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.WebhookOverrideResponse": {
+            "type": "object",
+            "properties": {
+                "auth": {
+                    "description": "Auth is none, basic or bearer",
+                    "type": "string",
+                    "example": "bearer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "acme"
+                },
+                "scope": {
+                    "type": "string",
+                    "example": "tenant"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://sms.acme.example/send"
+                }
+            }
+        },
+        "dto.WebhookOverridesResponse": {
+            "type": "object",
+            "properties": {
+                "overrides": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.WebhookOverrideResponse"
+                    }
                 },
                 "status": {
                     "type": "string"
@@ -364,6 +6166,14 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "Required on /api/v1 endpoints (except health) when server.api_key or server.api_keys are set",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        }
     }
 }`
 
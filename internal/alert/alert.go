@@ -0,0 +1,211 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Rule names
+const (
+	RuleFailureRate      = "failure_rate"
+	RulePendingBacklog   = "pending_backlog"
+	RuleSchedulerStopped = "scheduler_stopped"
+	RuleQuotaWarning     = "quota_warning"
+)
+
+// WatchTypes are the lifecycle events Watch checks the rules on
+var WatchTypes = []events.Type{events.TypeFailed, events.TypeDeadLettered}
+
+// watchGap is the least time between two checks of Watch, a burst of failures runs a single one
+const watchGap = 10 * time.Second
+
+// Alert is a rule firing or resolving
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Resolved  bool      `json:"resolved"`
+	Message   string    `json:"message"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	App       string    `json:"app"`
+	Mode      string    `json:"mode"`
+	At        time.Time `json:"at"`
+}
+
+// Subject is a one line summary of the alert
+func (a Alert) Subject() string {
+	state := "FIRING"
+	if a.Resolved {
+		state = "RESOLVED"
+	}
+	return fmt.Sprintf("[%s] %s (%s): %s", state, a.App, a.Mode, a.Rule)
+}
+
+// Text is the human readable alert used by chat and email notifiers
+func (a Alert) Text() string {
+	return fmt.Sprintf("%s\n%s", a.Subject(), a.Message)
+}
+
+// StatsSource provides the queue statistics the rules are evaluated on
+type StatsSource interface {
+	Stats(ctx context.Context) (*dto.StatsResponse, error)
+}
+
+// SchedulerState reports whether the scheduler is running
+type SchedulerState interface {
+	IsRunning() bool
+}
+
+// Monitor periodically evaluates the alert rules and notifies when a rule starts or stops firing
+type Monitor struct {
+	cfg       *config.Cfg
+	stats     StatsSource
+	scheduler SchedulerState
+	notifiers []Notifier
+
+	mu     sync.Mutex
+	firing map[string]bool
+}
+
+func NewMonitor(cfg *config.Cfg, stats StatsSource, scheduler SchedulerState, notifiers []Notifier) *Monitor {
+	return &Monitor{
+		cfg:       cfg,
+		stats:     stats,
+		scheduler: scheduler,
+		notifiers: notifiers,
+		firing:    make(map[string]bool),
+	}
+}
+
+// Run evaluates the rules every configured interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Alerts.Interval)
+	defer ticker.Stop()
+
+	config.Log().Infof("Alert monitor started (interval: %s, notifiers: %d)", m.cfg.Alerts.Interval, len(m.notifiers))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Watch checks the rules once a failure event of sub arrives, so a failure rate firing is noticed right away
+// instead of at the next interval, until the subscription is closed. Events arriving within 10 seconds of a
+// check are only counted by that check.
+func (m *Monitor) Watch(ctx context.Context, sub *events.Subscription) {
+	var checked time.Time
+	for range sub.Events() {
+		if time.Since(checked) < watchGap {
+			continue
+		}
+		checked = time.Now()
+		m.Check(ctx)
+	}
+}
+
+// Check evaluates every rule once and sends notifications for state changes
+func (m *Monitor) Check(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := m.cfg.Alerts
+
+	if rules.SchedulerStopped && m.cfg.Messaging.Enabled && m.scheduler != nil {
+		m.evaluate(ctx, RuleSchedulerStopped, !m.scheduler.IsRunning(), 0, 0,
+			"the scheduler is not running although messaging is enabled")
+	}
+
+	if rules.FailureRate <= 0 && rules.PendingBacklog <= 0 {
+		return
+	}
+
+	stats, err := m.stats.Stats(ctx)
+	if err != nil {
+		config.Log().Errorf("Alert monitor failed to load stats: %v", err)
+		return
+	}
+
+	if rules.FailureRate > 0 {
+		volume := stats.SentToday + stats.FailedToday
+		firing := volume >= rules.MinVolume && stats.FailureRate > rules.FailureRate
+		m.evaluate(ctx, RuleFailureRate, firing, stats.FailureRate, rules.FailureRate,
+			fmt.Sprintf("failure rate today is %.1f%% (%d failed of %d), threshold %.1f%%",
+				stats.FailureRate*100, stats.FailedToday, volume, rules.FailureRate*100))
+	}
+
+	if rules.PendingBacklog > 0 {
+		pending := stats.Counts["pending"]
+		m.evaluate(ctx, RulePendingBacklog, pending > rules.PendingBacklog, float64(pending), float64(rules.PendingBacklog),
+			fmt.Sprintf("%d messages are pending, threshold %d", pending, rules.PendingBacklog))
+	}
+}
+
+// evaluate notifies when the rule changes between firing and resolved
+func (m *Monitor) evaluate(ctx context.Context, rule string, firing bool, value, threshold float64, message string) {
+	if firing == m.firing[rule] {
+		return
+	}
+	m.firing[rule] = firing
+
+	alert := Alert{
+		Rule:      rule,
+		Resolved:  !firing,
+		Message:   message,
+		Value:     value,
+		Threshold: threshold,
+		App:       m.cfg.AppName,
+		Mode:      string(m.cfg.Server.Mode),
+		At:        time.Now().UTC(),
+	}
+	if alert.Resolved {
+		alert.Message = "resolved: " + message
+	}
+
+	config.Log().WithField("rule", rule).Warn(alert.Subject())
+	notify(ctx, m.notifiers, alert)
+}
+
+// QuotaWarner sends the quota warnings to the alert notifiers, see quota.Quotas.OnWarning
+type QuotaWarner struct {
+	cfg       *config.Cfg
+	notifiers []Notifier
+}
+
+func NewQuotaWarner(cfg *config.Cfg, notifiers []Notifier) *QuotaWarner {
+	return &QuotaWarner{cfg: cfg, notifiers: notifiers}
+}
+
+// Warn notifies in the background, the warning is raised on the path of a request creating messages
+func (w *QuotaWarner) Warn(ctx context.Context, warning quota.Warning) {
+	alert := Alert{
+		Rule:      RuleQuotaWarning,
+		Message:   warning.String(),
+		Value:     float64(warning.Used) / float64(warning.Limit),
+		Threshold: warning.Threshold,
+		App:       w.cfg.AppName,
+		Mode:      string(w.cfg.Server.Mode),
+		At:        time.Now().UTC(),
+	}
+	go notify(context.WithoutCancel(ctx), w.notifiers, alert)
+}
+
+// notify sends alert through every notifier, each bounded by notifyTimeout
+func notify(ctx context.Context, notifiers []Notifier, alert Alert) {
+	for _, notifier := range notifiers {
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := notifier.Notify(nctx, alert); err != nil {
+			config.Log().Errorf("Failed to send %s alert via %s: %v", alert.Rule, notifier.Name(), err)
+		}
+		cancel()
+	}
+}
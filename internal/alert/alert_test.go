@@ -0,0 +1,205 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStats struct {
+	stats *dto.StatsResponse
+}
+
+func (f *fakeStats) Stats(_ context.Context) (*dto.StatsResponse, error) {
+	return f.stats, nil
+}
+
+type fakeScheduler struct {
+	running bool
+}
+
+func (f *fakeScheduler) IsRunning() bool { return f.running }
+
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(_ context.Context, notification Notification) error {
+	r.alerts = append(r.alerts, notification.(Alert))
+	return nil
+}
+
+func testConfig() *config.Cfg {
+	cfg := &config.Cfg{AppName: "sendpulse"}
+	cfg.Server.Mode = config.ModeProd
+	cfg.Messaging.Enabled = true
+	cfg.Alerts = config.Alerts{
+		Enabled:          true,
+		Interval:         time.Minute,
+		FailureRate:      0.2,
+		MinVolume:        10,
+		PendingBacklog:   100,
+		SchedulerStopped: true,
+	}
+	return cfg
+}
+
+func TestMonitor_Check(t *testing.T) {
+	stats := &fakeStats{stats: &dto.StatsResponse{
+		Counts:      map[string]int{"pending": 10},
+		SentToday:   90,
+		FailedToday: 10,
+		FailureRate: 0.1,
+	}}
+	scheduler := &fakeScheduler{running: true}
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(testConfig(), stats, scheduler, []Notifier{notifier})
+
+	t.Run("healthy pipeline does not notify", func(t *testing.T) {
+		monitor.Check(context.Background())
+		assert.Empty(t, notifier.alerts)
+	})
+
+	t.Run("fires once per rule while the condition holds", func(t *testing.T) {
+		stats.stats.Counts["pending"] = 500
+		stats.stats.FailureRate = 0.5
+		scheduler.running = false
+
+		monitor.Check(context.Background())
+		monitor.Check(context.Background())
+
+		require.Len(t, notifier.alerts, 3)
+		rules := []string{notifier.alerts[0].Rule, notifier.alerts[1].Rule, notifier.alerts[2].Rule}
+		assert.ElementsMatch(t, []string{RuleSchedulerStopped, RuleFailureRate, RulePendingBacklog}, rules)
+		for _, alert := range notifier.alerts {
+			assert.False(t, alert.Resolved)
+			assert.Equal(t, "prod", alert.Mode)
+		}
+	})
+
+	t.Run("notifies when a rule resolves", func(t *testing.T) {
+		notifier.alerts = nil
+		stats.stats.Counts["pending"] = 0
+
+		monitor.Check(context.Background())
+
+		require.Len(t, notifier.alerts, 1)
+		assert.Equal(t, RulePendingBacklog, notifier.alerts[0].Rule)
+		assert.True(t, notifier.alerts[0].Resolved)
+	})
+}
+
+func TestMonitor_Check_FailureRateMinVolume(t *testing.T) {
+	stats := &fakeStats{stats: &dto.StatsResponse{
+		Counts:      map[string]int{},
+		SentToday:   1,
+		FailedToday: 1,
+		FailureRate: 0.5,
+	}}
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(testConfig(), stats, &fakeScheduler{running: true}, []Notifier{notifier})
+
+	monitor.Check(context.Background())
+
+	assert.Empty(t, notifier.alerts)
+}
+
+func TestMonitor_Watch(t *testing.T) {
+	stats := &fakeStats{stats: &dto.StatsResponse{
+		Counts:      map[string]int{},
+		SentToday:   50,
+		FailedToday: 50,
+		FailureRate: 0.5,
+	}}
+	notifier := &recordingNotifier{}
+	monitor := NewMonitor(testConfig(), stats, &fakeScheduler{running: true}, []Notifier{notifier})
+	bus := events.NewBus()
+	sub := bus.Subscribe("alerts", 10, WatchTypes...)
+
+	// a burst of failures runs a single check, the sent events are not watched
+	require.NoError(t, bus.Publish(context.Background(), events.Event{Type: events.TypeSent, MessageID: 1}))
+	for id := int64(2); id <= 4; id++ {
+		require.NoError(t, bus.Publish(context.Background(), events.Event{Type: events.TypeFailed, MessageID: id}))
+	}
+	bus.Close()
+	monitor.Watch(context.Background(), sub)
+
+	require.Len(t, notifier.alerts, 1)
+	assert.Equal(t, RuleFailureRate, notifier.alerts[0].Rule)
+}
+
+func TestNotifiers(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifiers := NewNotifiers(config.Alerts{SlackWebhookURL: server.URL, HTTPURL: server.URL})
+	require.Len(t, notifiers, 2)
+
+	alert := Alert{Rule: RulePendingBacklog, Message: "500 messages are pending, threshold 100", App: "sendpulse", Mode: "prod"}
+	for _, notifier := range notifiers {
+		require.NoError(t, notifier.Notify(context.Background(), alert))
+	}
+
+	require.Len(t, bodies, 2)
+	assert.Equal(t, "[FIRING] sendpulse (prod): pending_backlog\n500 messages are pending, threshold 100", bodies[0]["text"])
+	assert.Equal(t, RulePendingBacklog, bodies[1]["rule"])
+	assert.Equal(t, false, bodies[1]["resolved"])
+}
+
+// channelNotifier hands the notifications sent in the background to the test
+type channelNotifier chan Notification
+
+func (c channelNotifier) Name() string { return "channel" }
+
+func (c channelNotifier) Notify(_ context.Context, notification Notification) error {
+	c <- notification
+	return nil
+}
+
+func TestQuotaWarner_Warn(t *testing.T) {
+	notifier := make(channelNotifier, 1)
+	warner := NewQuotaWarner(testConfig(), []Notifier{notifier})
+
+	warner.Warn(context.Background(), quota.Warning{
+		Scope:     db.UsageScopeTenant,
+		Subject:   "billing",
+		Period:    db.UsagePeriodMonth,
+		Threshold: 0.8,
+		Used:      8100,
+		Limit:     10000,
+		Reset:     time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	select {
+	case notification := <-notifier:
+		alert := notification.(Alert)
+		assert.Equal(t, RuleQuotaWarning, alert.Rule)
+		assert.InDelta(t, 0.81, alert.Value, 0.001)
+		assert.Equal(t, 0.8, alert.Threshold)
+		assert.Equal(t, `tenant "billing" used 80% of its month quota (8100 of 10000 messages, resets 2026-11-01T00:00:00Z)`, alert.Message)
+	case <-time.After(time.Second):
+		t.Fatal("the warning was not notified")
+	}
+}
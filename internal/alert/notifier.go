@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+)
+
+// notifyTimeout is the maximum time a single notification may take
+const notifyTimeout = 10 * time.Second
+
+// Notification is sent by a Notifier, chat and email use the subject and text, HTTP posts it as JSON
+type Notification interface {
+	Subject() string
+	Text() string
+}
+
+// Notifier delivers alerts and reports to one channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NewNotifiers returns a notifier for every channel configured in cfg
+func NewNotifiers(cfg config.Alerts) []Notifier {
+	return NewChannelNotifiers(cfg.SlackWebhookURL, cfg.HTTPURL, cfg.Email)
+}
+
+// NewChannelNotifiers returns a notifier for every channel that is set
+func NewChannelNotifiers(slackWebhookURL, httpURL string, email config.AlertEmail) []Notifier {
+	client := &http.Client{Timeout: notifyTimeout}
+
+	var notifiers []Notifier
+	if slackWebhookURL != "" {
+		notifiers = append(notifiers, &SlackNotifier{url: slackWebhookURL, client: client})
+	}
+	if httpURL != "" {
+		notifiers = append(notifiers, &HTTPNotifier{url: httpURL, client: client})
+	}
+	if email.SMTPAddress != "" && len(email.To) > 0 {
+		notifiers = append(notifiers, &EmailNotifier{cfg: email})
+	}
+	return notifiers
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *SlackNotifier) Name() string { return "slack" }
+
+func (s *SlackNotifier) Notify(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": notification.Text()})
+}
+
+// HTTPNotifier posts alerts as JSON to a generic endpoint
+type HTTPNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (h *HTTPNotifier) Name() string { return "http" }
+
+func (h *HTTPNotifier) Notify(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, h.client, h.url, notification)
+}
+
+// EmailNotifier sends alerts as plain text emails over SMTP
+type EmailNotifier struct {
+	cfg config.AlertEmail
+}
+
+func (e *EmailNotifier) Name() string { return "email" }
+
+func (e *EmailNotifier) Notify(_ context.Context, notification Notification) error {
+	host, _, err := net.SplitHostPort(e.cfg.SMTPAddress)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n",
+		e.cfg.From, strings.Join(e.cfg.To, ", "), notification.Subject(), notification.Text())
+	return smtp.SendMail(e.cfg.SMTPAddress, auth, e.cfg.From, e.cfg.To, []byte(msg))
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notification request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
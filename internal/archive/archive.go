@@ -0,0 +1,288 @@
+// Package archive writes aged-out messages to an S3 compatible bucket as gzip compressed JSONL objects
+// encrypted with AES-256-GCM, and reads them back.
+//
+// Every run of the archive job writes its messages in parts with a manifest next to them:
+//
+//	<prefix>messages/<run>/manifest.json
+//	<prefix>messages/<run>/part-00001.jsonl.gz.enc
+//
+// The manifest is rewritten after every part, so the parts of an interrupted run are listed as well.
+// It holds no message data and is stored unencrypted.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// ManifestVersion is the version of the manifests written by this release
+const ManifestVersion = 1
+
+const (
+	runsDir      = "messages/"
+	manifestName = "manifest.json"
+)
+
+// ErrCorrupt is returned for parts whose checksum does not match their manifest
+var ErrCorrupt = errors.New("archive part is corrupt")
+
+// Manifest describes a run of the archive job
+type Manifest struct {
+	Version int    `json:"version"`
+	Run     string `json:"run"`
+	// Cutoff is the creation time the archived messages are older than
+	Cutoff    time.Time `json:"cutoff"`
+	CreatedAt time.Time `json:"created_at"`
+	// Complete is set once every message of the run was archived
+	Complete    bool   `json:"complete"`
+	Format      string `json:"format"`
+	Compression string `json:"compression"`
+	Encryption  string `json:"encryption"`
+	Messages    int    `json:"messages"`
+	Parts       []Part `json:"parts"`
+}
+
+// Part is an archived object of a run
+type Part struct {
+	Key      string `json:"key"`
+	Messages int    `json:"messages"`
+	Size     int    `json:"size"`
+	// SHA256 is the checksum of the stored, encrypted object
+	SHA256 string `json:"sha256"`
+	// FirstCreatedAt and LastCreatedAt are the creation times of the oldest and newest message of the part
+	FirstCreatedAt time.Time `json:"first_created_at"`
+	LastCreatedAt  time.Time `json:"last_created_at"`
+}
+
+// Archive reads and writes the runs under a prefix of a store
+type Archive struct {
+	store  Store
+	box    *secrets.Box
+	prefix string
+}
+
+// New creates an archive of the runs under prefix in store, encrypted with box
+func New(store Store, box *secrets.Box, prefix string) *Archive {
+	return &Archive{store: store, box: box, prefix: prefix}
+}
+
+// FromConfig returns the archive of the archive.s3 bucket encrypted with archive.encryption_key, nil without a bucket
+func FromConfig(cfg config.Archive) (*Archive, error) {
+	if cfg.S3.Bucket == "" {
+		return nil, nil
+	}
+	box, err := secrets.NewBox(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("archive.encryption_key: %w", err)
+	}
+	store, err := NewS3(cfg.S3)
+	if err != nil {
+		return nil, err
+	}
+	return New(store, box, cfg.S3.Prefix), nil
+}
+
+// Run is a run of the archive job being written
+type Run struct {
+	archive  *Archive
+	manifest Manifest
+}
+
+// NewRun starts a run archiving the messages created before cutoff, it is named after now
+func (a *Archive) NewRun(now, cutoff time.Time) *Run {
+	return &Run{
+		archive: a,
+		manifest: Manifest{
+			Version:     ManifestVersion,
+			Run:         now.UTC().Format("20060102T150405.000Z"),
+			Cutoff:      cutoff.UTC(),
+			CreatedAt:   now.UTC(),
+			Format:      "jsonl",
+			Compression: "gzip",
+			Encryption:  "aes-256-gcm",
+		},
+	}
+}
+
+// Location returns where the run is written, e.g. for logs
+func (r *Run) Location() string {
+	return r.archive.runPrefix(r.manifest.Run)
+}
+
+// WritePart stores messages as the next part of the run and rewrites the manifest. The messages are safe to
+// delete once it returns.
+func (r *Run) WritePart(ctx context.Context, messages []dto.MessageResponse) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var plaintext bytes.Buffer
+	compressed := gzip.NewWriter(&plaintext)
+	encoder := json.NewEncoder(compressed)
+	for _, msg := range messages {
+		if err := encoder.Encode(msg); err != nil {
+			return err
+		}
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	sealed, err := r.archive.box.Seal(plaintext.Bytes())
+	if err != nil {
+		return err
+	}
+
+	part := Part{
+		Key:            fmt.Sprintf("%spart-%05d.jsonl.gz.enc", r.Location(), len(r.manifest.Parts)+1),
+		Messages:       len(messages),
+		Size:           len(sealed),
+		SHA256:         sha256Hex(sealed),
+		FirstCreatedAt: messages[0].CreatedAt,
+		LastCreatedAt:  messages[0].CreatedAt,
+	}
+	for _, msg := range messages[1:] {
+		if msg.CreatedAt.Before(part.FirstCreatedAt) {
+			part.FirstCreatedAt = msg.CreatedAt
+		}
+		if msg.CreatedAt.After(part.LastCreatedAt) {
+			part.LastCreatedAt = msg.CreatedAt
+		}
+	}
+	if err := r.archive.store.Put(ctx, part.Key, sealed); err != nil {
+		return fmt.Errorf("writing %s: %w", part.Key, err)
+	}
+
+	r.manifest.Parts = append(r.manifest.Parts, part)
+	r.manifest.Messages += part.Messages
+	return r.writeManifest(ctx)
+}
+
+// Finish marks the run complete, runs without parts write nothing
+func (r *Run) Finish(ctx context.Context) error {
+	if len(r.manifest.Parts) == 0 {
+		return nil
+	}
+	r.manifest.Complete = true
+	return r.writeManifest(ctx)
+}
+
+func (r *Run) writeManifest(ctx context.Context) error {
+	data, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	key := r.Location() + manifestName
+	if err := r.archive.store.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (a *Archive) runPrefix(run string) string {
+	return a.prefix + runsDir + run + "/"
+}
+
+// Runs returns the manifests of every run, oldest first
+func (a *Archive) Runs(ctx context.Context) ([]*Manifest, error) {
+	keys, err := a.store.List(ctx, a.prefix+runsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/"+manifestName) {
+			continue
+		}
+		manifest, err := a.readManifest(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// Manifest returns the manifest of run, ErrNotFound when there is none
+func (a *Archive) Manifest(ctx context.Context, run string) (*Manifest, error) {
+	return a.readManifest(ctx, a.runPrefix(run)+manifestName)
+}
+
+func (a *Archive) readManifest(ctx context.Context, key string) (*Manifest, error) {
+	data, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	if manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("%s has version %d, this release reads up to %d", key, manifest.Version, ManifestVersion)
+	}
+	return manifest, nil
+}
+
+// Read calls fn with every message of the run in the order they were archived, a part that does not match its
+// checksum or cannot be decrypted stops the read
+func (a *Archive) Read(ctx context.Context, manifest *Manifest, fn func(dto.MessageResponse) error) error {
+	for _, part := range manifest.Parts {
+		sealed, err := a.store.Get(ctx, part.Key)
+		if err != nil {
+			return err
+		}
+		if sha256Hex(sealed) != part.SHA256 {
+			return fmt.Errorf("%w: %s does not match its checksum", ErrCorrupt, part.Key)
+		}
+		plaintext, err := a.box.Open(sealed)
+		if err != nil {
+			return fmt.Errorf("%s: %w", part.Key, err)
+		}
+		if err := readMessages(plaintext, fn); err != nil {
+			return fmt.Errorf("%s: %w", part.Key, err)
+		}
+	}
+	return nil
+}
+
+// readMessages decodes the gzip compressed JSONL of a part
+func readMessages(compressed []byte, fn func(dto.MessageResponse) error) error {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return DecodeMessages(reader, fn)
+}
+
+// DecodeMessages calls fn with every message of JSONL written by the archive job, also the files it writes to
+// maintenance.archive.dir
+func DecodeMessages(r io.Reader, fn func(dto.MessageResponse) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var msg dto.MessageResponse
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return err
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
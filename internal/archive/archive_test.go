@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a Store keeping the objects in a map
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memoryStore) Put(_ context.Context, key string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[key] = slices.Clone(body)
+	return nil
+}
+
+func (m *memoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(body), nil
+}
+
+func (m *memoryStore) List(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func testBox(t *testing.T, fill string) *secrets.Box {
+	box, err := secrets.NewBox(base64.StdEncoding.EncodeToString([]byte(strings.Repeat(fill, secrets.KeySize))))
+	require.NoError(t, err)
+	return box
+}
+
+func TestArchive(t *testing.T) {
+	store := &memoryStore{}
+	a := New(store, testBox(t, "k"), "sendpulse/")
+	ctx := context.Background()
+
+	now := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	run := a.NewRun(now, now.Add(-90*24*time.Hour))
+	assert.Equal(t, "sendpulse/messages/20261016T030000.000Z/", run.Location())
+
+	older, newer := now.Add(-100*24*time.Hour), now.Add(-95*24*time.Hour)
+	require.NoError(t, run.WritePart(ctx, []dto.MessageResponse{
+		{ID: 2, To: "+905552222222", Content: "Second", Status: "sent", CreatedAt: newer},
+		{ID: 1, To: "+905551111111", Content: "First", Status: "delivered", CreatedAt: older},
+	}))
+	require.NoError(t, run.WritePart(ctx, []dto.MessageResponse{
+		{ID: 3, To: "+905553333333", Content: "Third", Status: "failed", CreatedAt: newer},
+	}))
+
+	for key, body := range store.objects {
+		if strings.HasSuffix(key, ".enc") {
+			assert.NotContains(t, string(body), "+90555", "parts are stored encrypted")
+		}
+	}
+
+	manifest, err := a.Manifest(ctx, "20261016T030000.000Z")
+	require.NoError(t, err)
+	assert.False(t, manifest.Complete, "the manifest lists the parts before the run is finished")
+	require.Len(t, manifest.Parts, 2)
+	assert.Equal(t, 3, manifest.Messages)
+	assert.Equal(t, "sendpulse/messages/20261016T030000.000Z/part-00001.jsonl.gz.enc", manifest.Parts[0].Key)
+	assert.Equal(t, older, manifest.Parts[0].FirstCreatedAt)
+	assert.Equal(t, newer, manifest.Parts[0].LastCreatedAt)
+
+	require.NoError(t, run.Finish(ctx))
+	runs, err := a.Runs(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].Complete)
+
+	var ids []int64
+	require.NoError(t, a.Read(ctx, runs[0], func(msg dto.MessageResponse) error {
+		ids = append(ids, msg.ID)
+		return nil
+	}))
+	assert.Equal(t, []int64{2, 1, 3}, ids)
+
+	t.Run("wrong key", func(t *testing.T) {
+		err := New(store, testBox(t, "o"), "sendpulse/").Read(ctx, runs[0], func(dto.MessageResponse) error { return nil })
+		assert.True(t, errors.Is(err, secrets.ErrDecrypt), "%v", err)
+	})
+
+	t.Run("corrupt part", func(t *testing.T) {
+		key := runs[0].Parts[1].Key
+		store.objects[key][0] ^= 0xff
+		err := a.Read(ctx, runs[0], func(dto.MessageResponse) error { return nil })
+		assert.True(t, errors.Is(err, ErrCorrupt), "%v", err)
+	})
+
+	t.Run("missing run", func(t *testing.T) {
+		_, err := a.Manifest(ctx, "20260101T000000.000Z")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("empty run", func(t *testing.T) {
+		empty := a.NewRun(now.Add(time.Hour), now)
+		require.NoError(t, empty.Finish(ctx))
+		runs, err := a.Runs(ctx)
+		require.NoError(t, err)
+		assert.Len(t, runs, 1, "runs without messages write no manifest")
+	})
+}
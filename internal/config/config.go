@@ -1,10 +1,20 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/boratanrikulu/sendpulse/internal/cron"
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
 	"github.com/onrik/logrus/filename"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -13,21 +23,245 @@ import (
 
 var defaultAppName = "sendpulse"
 
-var Logger *logrus.Logger
+var (
+	Logger  *logrus.Logger
+	logOnce sync.Once
+)
 
 var Version string = "0.1.0"
 
 type Cfg struct {
-	AppName   string    `mapstructure:"app_name"`
-	Server    Server    `mapstructure:"server"`
-	Database  Database  `mapstructure:"database"`
-	Messaging Messaging `mapstructure:"messaging"`
-	Webhook   Webhook   `mapstructure:"webhook"`
+	AppName         string          `mapstructure:"app_name"`
+	Server          Server          `mapstructure:"server"`
+	Database        Database        `mapstructure:"database"`
+	Messaging       Messaging       `mapstructure:"messaging"`
+	Webhook         Webhook         `mapstructure:"webhook"`
+	Tracing         Tracing         `mapstructure:"tracing"`
+	Sentry          Sentry          `mapstructure:"sentry"`
+	Alerts          Alerts          `mapstructure:"alerts"`
+	Reports         Reports         `mapstructure:"reports"`
+	StatsD          StatsD          `mapstructure:"statsd"`
+	Metrics         Metrics         `mapstructure:"metrics"`
+	Kafka           Kafka           `mapstructure:"kafka"`
+	AMQP            AMQP            `mapstructure:"amqp"`
+	NATS            NATS            `mapstructure:"nats"`
+	Queue           Queue           `mapstructure:"queue"`
+	Quotas          Quotas          `mapstructure:"quotas"`
+	Suppression     Suppression     `mapstructure:"suppression"`
+	DeliveryReports DeliveryReports `mapstructure:"delivery_reports"`
+	Callbacks       Callbacks       `mapstructure:"callbacks"`
+	ContentPolicy   ContentPolicy   `mapstructure:"content_policy"`
+	LinkTracking    LinkTracking    `mapstructure:"link_tracking"`
+	Routing         Routing         `mapstructure:"routing"`
+	Throttling      Throttling      `mapstructure:"throttling"`
+	Transforms      Transforms      `mapstructure:"transforms"`
+	Replay          Replay          `mapstructure:"replay"`
+	Ingestion       Ingestion       `mapstructure:"ingestion"`
+	Jobs            Jobs            `mapstructure:"jobs"`
+	Stats           Stats           `mapstructure:"stats"`
+	Maintenance     Maintenance     `mapstructure:"maintenance"`
+	Archive         Archive         `mapstructure:"archive"`
+	Region          Region          `mapstructure:"region"`
+	Campaigns       Campaigns       `mapstructure:"campaigns"`
+	StatusNotices   StatusNotices   `mapstructure:"status_notices"`
 }
 
 type Server struct {
 	Address string `mapstructure:"address"`
-	Mode    Mode   `mapstructure:"mode"`
+	// Addresses are more addresses the server listens on, e.g. "[::]:8080" next to ":8080" for dual-stack.
+	// An IPv6 host like "[::]" listens on IPv6 only, any other host on IPv4 only.
+	Addresses []string `mapstructure:"addresses"`
+	Mode      Mode     `mapstructure:"mode"`
+	// APIKey protects the /api/v1 endpoints (except health) when set, it is named "default"
+	APIKey string `mapstructure:"api_key"`
+	// APIKeys are additional named keys, the endpoints are protected when any key is configured
+	APIKeys   []APIKey  `mapstructure:"api_keys"`
+	AccessLog AccessLog `mapstructure:"access_log"`
+	Timeouts  Timeouts  `mapstructure:"timeouts"`
+	// RateLimitHeaders adds X-RateLimit-Limit, -Remaining and -Reset headers of the tightest quota of the
+	// API key to its responses, at the cost of a usage read per request of keys with a quota
+	RateLimitHeaders bool `mapstructure:"rate_limit_headers"`
+	// ReadOnly starts the server in the read-only mode, e.g. while the database is restored: only reads are
+	// answered and messaging is paused until the mode is disabled through the admin API
+	ReadOnly bool `mapstructure:"read_only"`
+	// LoadShedding answers stats and export requests with 503 while the database is slow or its pool saturated
+	LoadShedding LoadShedding `mapstructure:"load_shedding"`
+}
+
+// LoadShedding sheds the low-priority reads, stats and exports, while the database struggles so message creation
+// and the writes of the scheduler keep their connections. The database is checked every interval, shedding starts
+// once its ping latency or pool saturation crosses a threshold and stops once both are below them again.
+type LoadShedding struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	// Latency is the ping latency shedding starts at, 0 disables the check
+	Latency time.Duration `mapstructure:"latency"`
+	// PoolSaturation is the share of database.max_open_conns in use shedding starts at, between 0 and 1, 0
+	// disables the check. Pools without a limit are never saturated.
+	PoolSaturation float64 `mapstructure:"pool_saturation"`
+	// RetryAfter is sent in the Retry-After header of shed requests
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+}
+
+// DefaultAPIKeyName is the name of server.api_key
+const DefaultAPIKeyName = "default"
+
+// APIKey is a named API key, its quotas limit the messages created with it (0 is unlimited)
+type APIKey struct {
+	Name         string `mapstructure:"name"`
+	Key          string `mapstructure:"key"`
+	DailyQuota   int64  `mapstructure:"daily_quota"`
+	MonthlyQuota int64  `mapstructure:"monthly_quota"`
+	// WarningThresholds override quotas.warning_thresholds for the key
+	WarningThresholds []float64 `mapstructure:"warning_thresholds"`
+	// Scopes are the endpoints the key may use, see APIScopes. A key without scopes may use every endpoint.
+	Scopes []string `mapstructure:"scopes"`
+	// CallbackURL receives the status notices of the messages created with the key without a callback_url
+	CallbackURL string `mapstructure:"callback_url"`
+}
+
+// API key scopes, every endpoint behind the API key check except /limits requires one of them
+const (
+	ScopeMessagesRead      = "messages:read"
+	ScopeMessagesWrite     = "messages:write"
+	ScopeMessagingControl  = "messaging:control"
+	ScopeStatsRead         = "stats:read"
+	ScopeSuppressionsRead  = "suppressions:read"
+	ScopeSuppressionsWrite = "suppressions:write"
+	ScopeErasuresRead      = "erasures:read"
+	ScopeErasuresWrite     = "erasures:write"
+	// ScopeCallbacks posts delivery reports and inbound messages like the SMS provider does
+	ScopeCallbacks = "callbacks"
+	// ScopeAdminRead reads the admin endpoints, ScopeAdminWrite toggles the read-only mode
+	ScopeAdminRead  = "admin:read"
+	ScopeAdminWrite = "admin:write"
+	// ScopeWebhooksRead and ScopeWebhooksWrite list and change the webhook overrides of tenants and campaigns
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+	// ScopeCampaignsRead and ScopeCampaignsWrite list, create, submit and launch campaigns, ScopeCampaignsApprove
+	// approves and rejects the submitted ones
+	ScopeCampaignsRead    = "campaigns:read"
+	ScopeCampaignsWrite   = "campaigns:write"
+	ScopeCampaignsApprove = "campaigns:approve"
+)
+
+// APIScopes are the scopes API keys can be given
+var APIScopes = []string{
+	ScopeMessagesRead, ScopeMessagesWrite, ScopeMessagingControl, ScopeStatsRead,
+	ScopeSuppressionsRead, ScopeSuppressionsWrite, ScopeErasuresRead, ScopeErasuresWrite, ScopeCallbacks, ScopeAdminRead,
+	ScopeAdminWrite, ScopeWebhooksRead, ScopeWebhooksWrite, ScopeCampaignsRead, ScopeCampaignsWrite, ScopeCampaignsApprove,
+}
+
+// HasScope reports whether the key may use the endpoints of scope
+func (k APIKey) HasScope(scope string) bool {
+	return len(k.Scopes) == 0 || slices.Contains(k.Scopes, scope)
+}
+
+// Keys returns every configured API key, server.api_key first
+func (s Server) Keys() []APIKey {
+	var keys []APIKey
+	if s.APIKey != "" {
+		keys = append(keys, APIKey{Name: DefaultAPIKeyName, Key: s.APIKey})
+	}
+	return append(keys, s.APIKeys...)
+}
+
+// ListenAddresses returns Address followed by Addresses, without duplicates
+func (s Server) ListenAddresses() []string {
+	var addresses []string
+	for _, address := range append([]string{s.Address}, s.Addresses...) {
+		if address != "" && !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// ListenNetwork returns the network to listen on address with, tcp6 for an IPv6 host and tcp4 otherwise
+func ListenNetwork(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err == nil && strings.Contains(host, ":") {
+		return "tcp6"
+	}
+	return "tcp4"
+}
+
+// AccessLog configures the per request access log
+type AccessLog struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SampleRate is the share of requests logged (0-1), server errors are always logged
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// Routes override the sample rate per route, 0 excludes a route.
+	// Health probes and metrics scrapes are excluded when no routes are configured.
+	Routes []AccessLogRoute `mapstructure:"routes"`
+}
+
+// AccessLogRoute is the sample rate of a single route pattern, e.g. /api/v1/messages/:id
+type AccessLogRoute struct {
+	Path       string  `mapstructure:"path"`
+	SampleRate float64 `mapstructure:"sample_rate"`
+}
+
+// defaultAccessLogRoutes keeps the frequent probe and scrape requests out of the access log
+func defaultAccessLogRoutes() []AccessLogRoute {
+	return []AccessLogRoute{
+		{Path: "/readyz", SampleRate: 0},
+		{Path: "/api/v1/health", SampleRate: 0},
+		{Path: "/metrics", SampleRate: 0},
+	}
+}
+
+// Timeouts bound how long /api/v1 requests run. The context of a request is cancelled once its timeout passed,
+// so the service and database calls return, and the request is answered with 504.
+type Timeouts struct {
+	// Default applies to the routes without a timeout of their own, 0 disables it
+	Default time.Duration `mapstructure:"default"`
+	// Routes override the default per route pattern, 0 disables the timeout of a route
+	Routes []RouteTimeout `mapstructure:"routes"`
+}
+
+// RouteTimeout is the timeout of a route pattern, e.g. /api/v1/messages/:id
+type RouteTimeout struct {
+	Path string `mapstructure:"path"`
+	// Method limits the timeout to GET, POST etc., every method when empty
+	Method  string        `mapstructure:"method"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// For returns the timeout of a request of method to path. The route matching with the fewest parameters wins,
+// so /api/v1/messages/stats/timeseries overrides /api/v1/messages/:id.
+func (t Timeouts) For(method, path string) time.Duration {
+	timeout, params := t.Default, -1
+	for _, route := range t.Routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, method) {
+			continue
+		}
+		if n, ok := matchRoute(route.Path, path); ok && (params < 0 || n < params) {
+			timeout, params = route.Timeout, n
+		}
+	}
+	return timeout
+}
+
+// matchRoute reports whether path matches the route pattern, :name segments match any segment. It returns
+// the number of parameters of pattern.
+func matchRoute(pattern, path string) (int, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return 0, false
+	}
+	var params int
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, ":") && pathParts[i] != "" {
+			params++
+			continue
+		}
+		if part != pathParts[i] {
+			return 0, false
+		}
+	}
+	return params, true
 }
 
 type Mode string
@@ -37,9 +271,58 @@ const (
 	ModeProd Mode = "prod"
 )
 
+// Storages of the messages
+const (
+	StoragePostgres = "postgres"
+	// StorageMemory keeps everything in memory of the server process, no DSN is needed
+	StorageMemory = "memory"
+)
+
+// Schema checks of the server and worker at startup, see Database.SchemaCheck
+const (
+	SchemaCheckStrict = "strict"
+	// SchemaCheckReadOnly starts the server without sending and rejecting writes, the worker refuses to start
+	SchemaCheckReadOnly = "read_only"
+	SchemaCheckOff      = "off"
+)
+
+// ID strategies of the messages, see Database.IDStrategy
+const (
+	IDStrategyBigint = "bigint"
+	IDStrategyULID   = "ulid"
+	IDStrategyUUID   = "uuid"
+)
+
 type Database struct {
-	DSN string  `mapstructure:"dsn"`
-	DB  *bun.DB `mapstructure:"-"`
+	// Storage is postgres or memory, memory is meant for demos and integration tests
+	Storage string `mapstructure:"storage"`
+	DSN     string `mapstructure:"dsn"`
+	// ConnectTimeout is how long the server and worker retry connecting at startup, 0 fails right away
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	// HealthInterval is how often the database is pinged to notice an outage and its end
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	// MaxOpenConns limits the connections of the pool, 0 is unlimited
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+	// IDStrategy gives new messages a ULID or UUID public ID next to their bigint ID, so external clients
+	// are not shown sequential IDs revealing the message volume: the API leaves the bigint IDs of those
+	// messages out of its responses and accepts both. bigint gives them none.
+	IDStrategy string `mapstructure:"id_strategy"`
+	// SchemaCheck is what the server and worker do when the applied migrations are not the ones of the binary:
+	// strict refuses to start, read_only serves reads only and off starts anyway
+	SchemaCheck string `mapstructure:"schema_check"`
+	// Replica is a read replica the message list reads are sent to, unused when its DSN is empty
+	Replica Replica `mapstructure:"replica"`
+	DB      *bun.DB `mapstructure:"-"`
+}
+
+// Replica configures the reads of the message list endpoints from a read replica. A failed replica read is
+// retried on the primary.
+type Replica struct {
+	DSN string `mapstructure:"dsn"`
+	// Hedge also sends a read the replica has not answered within HedgeDelay to the primary and uses the first
+	// answer, trading a little primary load for the p99 latency during replica hiccups
+	Hedge      bool          `mapstructure:"hedge"`
+	HedgeDelay time.Duration `mapstructure:"hedge_delay"`
 }
 
 type Messaging struct {
@@ -48,13 +331,785 @@ type Messaging struct {
 	MaxRetries int           `mapstructure:"max_retries"`
 	RetryDelay time.Duration `mapstructure:"retry_delay"`
 	Enabled    bool          `mapstructure:"enabled"`
+	// MetricsInterval is how often the queue gauges are refreshed, 0 disables the refresh
+	MetricsInterval time.Duration `mapstructure:"metrics_interval"`
+	// LeaderLease lets only the scheduler holding the lease claim messages, the others stand by and take over
+	// once it is released or expires. It is renewed every interval, 0 lets every scheduler claim.
+	LeaderLease time.Duration `mapstructure:"leader_lease"`
+	// ControlInterval is how often the schedulers poll the cluster wide messaging control, so starting or
+	// stopping messaging through the API applies to every instance. 0 makes start and stop local.
+	ControlInterval time.Duration `mapstructure:"control_interval"`
+	// ShutdownTimeout is how long a stopping scheduler waits for its in-flight sends before handing over
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// PersistTimeout bounds the status writes of a claimed message, e.g. its result once sent. They are detached
+	// from the batch, so a stop or shutdown cancelling it does not drop the result of a send that went out.
+	PersistTimeout time.Duration `mapstructure:"persist_timeout"`
+	// ShutdownReapAfter is how soon the stuck reaper sends the messages still in flight after the shutdown timeout
+	// again, instead of once they are sending for maintenance.stuck_reaper.older_than. Keep it above the 5s send
+	// timeout, a shorter one may send a message whose send was still running twice.
+	ShutdownReapAfter time.Duration `mapstructure:"shutdown_reap_after"`
+	// OverlapPolicy is what a tick does while the previous batch is still running: skip it, queue a single
+	// batch to run once the running one finished, or run concurrently up to MaxConcurrentBatches batches
+	OverlapPolicy string `mapstructure:"overlap_policy"`
+	// MaxConcurrentBatches is the number of batches running at once with the concurrent overlap policy
+	MaxConcurrentBatches int `mapstructure:"max_concurrent_batches"`
+	// SkipEvents records why the scheduler skipped a claimed message, like a suppressed recipient or a throttled
+	// campaign, in the message_events table listed by GET /api/v1/messages/{id}/events
+	SkipEvents bool `mapstructure:"skip_events"`
+	// SendAttempts records every webhook request of a send with its timings, status code and error in the
+	// send_attempts table listed by GET /api/v1/messages/{id}/attempts
+	SendAttempts bool `mapstructure:"send_attempts"`
+	// PauseRecheck is how long the messages to a paused recipient are deferred before they are checked again,
+	// the messages of a lifted pause are sent within it. A pause expiring sooner resumes them at its expiry.
+	PauseRecheck time.Duration `mapstructure:"pause_recheck"`
+	// MaxSegments is the most SMS segments the content of a message may take in its encoding, longer content is
+	// rejected when the message is created
+	MaxSegments int `mapstructure:"max_segments"`
 }
 
+// Overlap policies of the scheduler
+const (
+	OverlapSkip       = "skip"
+	OverlapQueue      = "queue"
+	OverlapConcurrent = "concurrent"
+)
+
+// DefaultPersistTimeout is the messaging.persist_timeout of a config without one
+const DefaultPersistTimeout = 10 * time.Second
+
+// DefaultMaxSegments is the messaging.max_segments of a config without one
+const DefaultMaxSegments = 10
+
 type Webhook struct {
 	URL string `mapstructure:"url"`
+	// MaxResponseSize is the largest provider response body read in bytes, larger bodies are discarded unread.
+	// 0 reads any size.
+	MaxResponseSize int64 `mapstructure:"max_response_size"`
+	// ResponseContentTypes are the media types of the provider responses that are decoded, the bodies of
+	// others are discarded. Responses without a content type are decoded, empty accepts any.
+	ResponseContentTypes []string `mapstructure:"response_content_types"`
+	// MaxStoredLength truncates the message and message ID of a provider response before it is stored with
+	// the message, 0 stores them whole
+	MaxStoredLength int `mapstructure:"max_stored_length"`
+	// LocalAddress is the IP address requests to providers are sent from, for providers allowing known source
+	// IPs only. The system picks it when empty.
+	LocalAddress string `mapstructure:"local_address"`
+	// Interface sends requests to providers from the addresses of a network interface, e.g. eth1, instead of
+	// LocalAddress
+	Interface string `mapstructure:"interface"`
+	// EgressIPs are the public IPs providers see the requests from, e.g. of a NAT gateway in front of the
+	// local addresses. They are listed by the egress endpoint for provider allowlists.
+	EgressIPs []string `mapstructure:"egress_ips"`
+	// EncryptionKey is the base64 encoded 32 byte key the credentials of the webhook overrides of tenants
+	// and campaigns are encrypted with. Overrides with credentials cannot be stored or used without it.
+	EncryptionKey string `mapstructure:"encryption_key"`
+	// Payloads keeps the raw provider response bodies with the messages for debugging
+	Payloads WebhookPayloads `mapstructure:"payloads"`
+	// Connections keeps connections to the providers open ahead of the sends and caches their DNS lookups
+	Connections WebhookConnections `mapstructure:"connections"`
+}
+
+// WebhookConnections prepares the connections to the providers before the sends of a batch need them, so a
+// flapping provider DNS or a slow handshake does not fail the first sends of every batch
+type WebhookConnections struct {
+	// Prewarm is the number of connections opened to the webhook of every provider at the start of a batch and
+	// kept idle for its sends, 0 opens them on demand
+	Prewarm int `mapstructure:"prewarm"`
+	// DNSCacheTTL keeps the resolved addresses of the provider hosts this long whatever the TTL of their
+	// records, 0 resolves them per connection. A failed lookup serves the expired addresses and the host is
+	// resolved again when none of its addresses could be dialed.
+	DNSCacheTTL time.Duration `mapstructure:"dns_cache_ttl"`
+}
+
+// MaxPrewarmedConnections bounds webhook.connections.prewarm
+const MaxPrewarmedConnections = 100
+
+// WebhookPayloads configures where the raw provider response bodies are kept. Bodies up to Threshold bytes are
+// stored inline in the webhook_response of the message, larger ones in Storage, keeping the messages table narrow.
+type WebhookPayloads struct {
+	// Storage is none (default) to drop the bodies above the threshold, or table to store them in the
+	// message_payloads table, referenced by the payload_ref of the webhook response
+	Storage string `mapstructure:"storage"`
+	// Threshold is the largest body in bytes stored inline, 0 stores none inline. A compressed body is
+	// measured after its compression.
+	Threshold int `mapstructure:"threshold"`
+	// Compression is none (default), gzip or zstd to compress the bodies of at least CompressAbove bytes before
+	// they are stored, inline or in Storage. Bodies are decompressed when the messages are read, a body the
+	// compression would not shrink is stored as is.
+	Compression   string `mapstructure:"compression"`
+	CompressAbove int    `mapstructure:"compress_above"`
+}
+
+// Payload storages
+const (
+	PayloadStorageNone  = "none"
+	PayloadStorageTable = "table"
+)
+
+// Payload compressions
+const (
+	PayloadCompressionNone = "none"
+	PayloadCompressionGzip = "gzip"
+	PayloadCompressionZstd = "zstd"
+)
+
+// Tracing configures the OpenTelemetry trace export over OTLP/HTTP
+type Tracing struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string `mapstructure:"endpoint"`
+	// Insecure sends traces over plain HTTP instead of HTTPS
+	Insecure bool `mapstructure:"insecure"`
+	// SampleRatio is the share of traces recorded, between 0 and 1
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// Alerts configures the pipeline alert rules and where notifications are sent
+type Alerts struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	// FailureRate fires when today's failure rate is above it (0-1), 0 disables the rule
+	FailureRate float64 `mapstructure:"failure_rate"`
+	// MinVolume is the number of sent and failed messages today needed before the failure rate is evaluated
+	MinVolume int `mapstructure:"min_volume"`
+	// PendingBacklog fires when more messages are pending, 0 disables the rule
+	PendingBacklog int `mapstructure:"pending_backlog"`
+	// SchedulerStopped fires when messaging is enabled but the scheduler is not running
+	SchedulerStopped bool `mapstructure:"scheduler_stopped"`
+	// QuotaWarnings notifies when an API key or tenant crosses a quotas.warning_thresholds share of its quota
+	QuotaWarnings bool `mapstructure:"quota_warnings"`
+
+	SlackWebhookURL string     `mapstructure:"slack_webhook_url"`
+	HTTPURL         string     `mapstructure:"http_url"`
+	Email           AlertEmail `mapstructure:"email"`
+}
+
+// AlertEmail configures alert emails, SMTPAddress and To are required
+type AlertEmail struct {
+	SMTPAddress string   `mapstructure:"smtp_address"`
+	Username    string   `mapstructure:"username"`
+	Password    string   `mapstructure:"password"`
+	From        string   `mapstructure:"from"`
+	To          []string `mapstructure:"to"`
+}
+
+// Reports configures the daily summary report of the previous day and where it is sent
+type Reports struct {
+	Enabled bool `mapstructure:"enabled"`
+	// At is the time of day the report is sent, HH:MM in Timezone
+	At string `mapstructure:"at"`
+	// Timezone is the IANA name of the timezone the reported days start in
+	Timezone string `mapstructure:"timezone"`
+	// TopErrors is the number of most frequent failure reasons listed
+	TopErrors int `mapstructure:"top_errors"`
+
+	SlackWebhookURL string     `mapstructure:"slack_webhook_url"`
+	HTTPURL         string     `mapstructure:"http_url"`
+	Email           AlertEmail `mapstructure:"email"`
+}
+
+// Queue selects the backend the scheduler claims messages from
+type Queue struct {
+	// Backend is postgres (default) or redis
+	Backend string     `mapstructure:"backend"`
+	Redis   QueueRedis `mapstructure:"redis"`
+	// PriorityAging raises the priority of the due messages the longer they wait, so bulk traffic drains under
+	// constant high priority load
+	PriorityAging PriorityAging `mapstructure:"priority_aging"`
+}
+
+// PriorityAging configures the priority the claims give the due messages while they wait
+type PriorityAging struct {
+	// Interval is how long a message waits to gain a priority level, 0 (default) claims by priority and age only
+	Interval time.Duration `mapstructure:"interval"`
+	// Curve is linear, a level per interval, or quadratic, (waited / interval)² levels gaining faster over time
+	Curve string `mapstructure:"curve"`
+	// MaxBoost bounds the levels a message gains, 0 is unlimited
+	MaxBoost int `mapstructure:"max_boost"`
+}
+
+// QueueRedis configures the Redis Streams queue backend
+type QueueRedis struct {
+	Address  string `mapstructure:"address"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Stream   string `mapstructure:"stream"`
+	Group    string `mapstructure:"group"`
+	// Consumer names this process in the group, it must be unique per scheduler
+	Consumer string `mapstructure:"consumer"`
+	// ClaimTimeout is how long an entry may stay unacked before another consumer reclaims it
+	ClaimTimeout time.Duration `mapstructure:"claim_timeout"`
+	// RefillSize is the number of due messages moved from Postgres to the stream at once
+	RefillSize int `mapstructure:"refill_size"`
+}
+
+// Quotas limits the messages created through the API per tenant, API key quotas are set on server.api_keys
+type Quotas struct {
+	Tenants []TenantQuota `mapstructure:"tenants"`
+	// WarningThresholds are the shares of a quota (0-1) at which a warning is logged, counted and sent to the alert
+	// notifiers, once per period and threshold. Empty disables the warnings.
+	WarningThresholds []float64 `mapstructure:"warning_thresholds"`
+}
+
+// TenantQuota limits the messages of a tenant per UTC day and month (0 is unlimited)
+type TenantQuota struct {
+	Tenant       string `mapstructure:"tenant"`
+	DailyQuota   int64  `mapstructure:"daily_quota"`
+	MonthlyQuota int64  `mapstructure:"monthly_quota"`
+	// WarningThresholds override quotas.warning_thresholds for the tenant
+	WarningThresholds []float64 `mapstructure:"warning_thresholds"`
+}
+
+// DefaultQuotaWarningThresholds are the quotas.warning_thresholds of a config without them
+var DefaultQuotaWarningThresholds = []float64{0.8, 0.95}
+
+// Suppression configures the keywords of inbound messages that suppress or unsuppress their sender.
+// Keywords are matched case-insensitively against the whole trimmed message.
+type Suppression struct {
+	StopKeywords  []string `mapstructure:"stop_keywords"`
+	StartKeywords []string `mapstructure:"start_keywords"`
+}
+
+// ContentPolicy configures the rules messages are checked against when they are enqueued through the API or a consumer
+type ContentPolicy struct {
+	Rules []ContentRule `mapstructure:"rules"`
+}
+
+// Content rule types
+const (
+	// ContentRuleBannedTerms matches messages containing one of the terms as a whole word, case-insensitively
+	ContentRuleBannedTerms = "banned_terms"
+	// ContentRuleURLAllowlist matches messages linking to a domain outside the allowed domains and their subdomains
+	ContentRuleURLAllowlist = "url_allowlist"
+	// ContentRuleOptOutText matches messages containing none of the terms, e.g. "Reply STOP to opt out"
+	ContentRuleOptOutText = "opt_out_text"
+)
+
+// Content rule actions
+const (
+	// ContentActionReject refuses to enqueue the message
+	ContentActionReject = "reject"
+	// ContentActionQuarantine enqueues the message as quarantined, it is only sent once it is released
+	ContentActionQuarantine = "quarantine"
+	// ContentActionWarn enqueues the message as usual and records the violation on it
+	ContentActionWarn = "warn"
+)
+
+// ContentRule is a single content policy rule
+type ContentRule struct {
+	Name   string `mapstructure:"name"`
+	Type   string `mapstructure:"type"`
+	Action string `mapstructure:"action"`
+	// Terms are the banned terms of banned_terms and the accepted opt-out texts of opt_out_text
+	Terms []string `mapstructure:"terms"`
+	// AllowedDomains are the domains url_allowlist accepts links to
+	AllowedDomains []string `mapstructure:"allowed_domains"`
+	// CampaignsOnly applies the rule only to messages with a campaign
+	CampaignsOnly bool `mapstructure:"campaigns_only"`
+}
+
+// WebhookProvider is the name of the provider sending to webhook.url
+const WebhookProvider = "webhook"
+
+// SandboxProvider is the name of the built-in sandbox provider, it answers in-process with deterministic
+// outcomes per recipient instead of sending to a gateway
+const SandboxProvider = "sandbox"
+
+// Routing picks the provider and sender ID of a message when it is claimed. The route with the longest
+// prefix matching the recipient is used, the default route applies to every other recipient.
+type Routing struct {
+	Providers []Provider `mapstructure:"providers"`
+	Routes    []Route    `mapstructure:"routes"`
+	Default   Route      `mapstructure:"default"`
+	// Currency is the currency of the unit prices, it is only used to label cost reports
+	Currency string  `mapstructure:"currency"`
+	Sandbox  Sandbox `mapstructure:"sandbox"`
+	// Health scores the providers of the routes with several providers
+	Health ProviderHealth `mapstructure:"health"`
+}
+
+// ProviderHealth scores every provider by the success rate and latency of its recent sends. Routes with several
+// providers shift their messages away from the providers whose score drops, and back as they recover.
+type ProviderHealth struct {
+	// Window is how far back the sends of a provider count towards its score
+	Window time.Duration `mapstructure:"window"`
+	// LatencyTarget is the average send latency above which the score of a provider drops with its latency
+	LatencyTarget time.Duration `mapstructure:"latency_target"`
+	// MinSamples is the number of sends within the window a score needs, providers with fewer score 1
+	MinSamples int `mapstructure:"min_samples"`
+	// MinScore is the lowest score, a degraded provider keeps a trickle of messages so its recovery is noticed
+	MinScore float64 `mapstructure:"min_score"`
+}
+
+// Sandbox configures the delivery reports of the sandbox provider
+type Sandbox struct {
+	// ReportDelay is how long after a message is accepted its delivery report arrives
+	ReportDelay time.Duration `mapstructure:"report_delay"`
+	// DelayedReportDelay is the report delay of the delayed report magic number
+	DelayedReportDelay time.Duration `mapstructure:"delayed_report_delay"`
+}
+
+// Provider is a webhook messages can be routed to
+type Provider struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+}
+
+// Route sends the messages to recipients starting with Prefix through Provider
+type Route struct {
+	// Prefix is the E.164 country prefix of the recipients, e.g. "+90", it is not used by the default route
+	Prefix string `mapstructure:"prefix"`
+	// Provider is the name of one of the providers, webhook.url when empty
+	Provider string `mapstructure:"provider"`
+	// Providers split the messages of the route between several providers by their weight and health score,
+	// instead of Provider
+	Providers []WeightedProvider `mapstructure:"providers"`
+	// SenderID is sent to the provider as the sender of the messages when set
+	SenderID string `mapstructure:"sender_id"`
+	// RateLimit is the number of messages per second a scheduler sends through the route, 0 is unlimited
+	RateLimit float64 `mapstructure:"rate_limit"`
+	// UnitPrice is the price of a segment sent through the route, it is stored on the message when it is sent
+	UnitPrice float64 `mapstructure:"unit_price"`
+}
+
+// WeightedProvider is one of the providers of a route and its share of the messages
+type WeightedProvider struct {
+	Name string `mapstructure:"name"`
+	// Weight is the share of the provider relative to the other providers of the route while all are healthy
+	Weight float64 `mapstructure:"weight"`
+}
+
+// ProviderNames returns the providers of the route, Provider alone when it has no weighted providers
+func (r Route) ProviderNames() []string {
+	if len(r.Providers) == 0 {
+		return []string{r.Provider}
+	}
+	names := make([]string, len(r.Providers))
+	for i, provider := range r.Providers {
+		names[i] = provider.Name
+	}
+	return names
+}
+
+// Throttling limits the send rate of campaigns and tenants by named profiles, e.g. a gentle profile for
+// marketing blasts, so bulk sends and transactional traffic share a deployment without starving each other.
+// Every campaign and tenant is limited separately at the rate of its profile, per scheduler like route rate limits.
+type Throttling struct {
+	Profiles    []ThrottleProfile    `mapstructure:"profiles"`
+	Assignments []ThrottleAssignment `mapstructure:"assignments"`
+	// DefaultCampaignProfile throttles the campaigns without an assignment, empty leaves them unthrottled
+	DefaultCampaignProfile string `mapstructure:"default_campaign_profile"`
+}
+
+// ThrottleProfile is a named send rate
+type ThrottleProfile struct {
+	Name string `mapstructure:"name"`
+	// RateLimit is the number of messages per second sent of each campaign or tenant assigned to the profile
+	RateLimit float64 `mapstructure:"rate_limit"`
+}
+
+// ThrottleAssignment assigns the messages of a campaign or of a tenant to a profile, a campaign's assignment
+// wins over its tenant's
+type ThrottleAssignment struct {
+	Campaign string `mapstructure:"campaign"`
+	Tenant   string `mapstructure:"tenant"`
+	Profile  string `mapstructure:"profile"`
+}
+
+// Transform step types
+const (
+	// TransformTrim removes the leading and trailing white space
+	TransformTrim = "trim"
+	// TransformStripEmoji removes emoji, so a message sent as UCS-2 only for them fits GSM-7 segments
+	TransformStripEmoji = "strip_emoji"
+	// TransformShortenLinks replaces the links with tracked short links under link_tracking.base_url
+	TransformShortenLinks = "shorten_links"
+	// TransformFooter appends Text, e.g. a signature or "Reply STOP to opt out", unless the content has it
+	TransformFooter = "footer"
+)
+
+// Transforms rewrites the content of messages when they are claimed by named pipelines of steps applied in
+// order, e.g. appending the opt-out footer to marketing campaigns. The rendered content is sent and stored next
+// to the original content, which is kept as enqueued.
+type Transforms struct {
+	Pipelines   []TransformPipeline   `mapstructure:"pipelines"`
+	Assignments []TransformAssignment `mapstructure:"assignments"`
+	// DefaultPipeline renders the messages without an assignment, empty sends them as enqueued
+	DefaultPipeline string `mapstructure:"default_pipeline"`
+}
+
+// TransformPipeline is a named chain of transform steps
+type TransformPipeline struct {
+	Name  string          `mapstructure:"name"`
+	Steps []TransformStep `mapstructure:"steps"`
+}
+
+// TransformStep is a single step of a pipeline
+type TransformStep struct {
+	Type string `mapstructure:"type"`
+	// Text is the footer appended by footer, Separator goes between the content and the footer (a space when empty)
+	Text      string `mapstructure:"text"`
+	Separator string `mapstructure:"separator"`
+}
+
+// TransformAssignment assigns the messages of a campaign or of a tenant to a pipeline, a campaign's assignment
+// wins over its tenant's
+type TransformAssignment struct {
+	Campaign string `mapstructure:"campaign"`
+	Tenant   string `mapstructure:"tenant"`
+	Pipeline string `mapstructure:"pipeline"`
+}
+
+// routePrefixPattern matches the E.164 prefixes of routes
+var routePrefixPattern = regexp.MustCompile(`^\+\d{1,15}$`)
+
+// allRoutes returns the routes followed by the default route
+func (r Routing) allRoutes() []Route {
+	return append(slices.Clone(r.Routes), r.Default)
+}
+
+// Replay limits the replays of sent messages, which clone and re-enqueue every message sent within a window
+type Replay struct {
+	// MaxWindow is the longest window a single replay may cover
+	MaxWindow time.Duration `mapstructure:"max_window"`
+	// MaxMessages is the most messages a single replay may clone
+	MaxMessages int `mapstructure:"max_messages"`
+}
+
+// Ingestion bounds the asynchronous ingestion jobs of POST /api/v1/messages/async, which validate and enqueue
+// a large payload of messages in the background
+type Ingestion struct {
+	// MaxMessages is the most messages a single job may carry
+	MaxMessages int `mapstructure:"max_messages"`
+	// MaxBodySize is the largest request body the server accepts, in bytes. It applies to every endpoint,
+	// payloads of MaxMessages messages must fit.
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// Workers is the number of jobs an instance processes at once
+	Workers int `mapstructure:"workers"`
+	// MaxQueued is the number of accepted jobs an instance holds waiting for a worker, more are refused.
+	// Their payloads are kept in memory until they are processed.
+	MaxQueued int `mapstructure:"max_queued"`
+	// BatchSize is the number of messages enqueued per statement
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// Jobs bounds the background jobs followed with GET /api/v1/jobs/{id}, e.g. the message exports of
+// POST /api/v1/messages/export. Jobs are run by the instance that accepted them, their state and results are
+// kept in the jobs and job_results tables so any instance reports them.
+type Jobs struct {
+	// Workers is the number of jobs an instance runs at once
+	Workers int `mapstructure:"workers"`
+	// MaxQueued is the number of accepted jobs an instance holds waiting for a worker, more are refused
+	MaxQueued int `mapstructure:"max_queued"`
+	// ResultTTL is how long the result of a finished job can be downloaded, expired jobs are deleted when the
+	// next job is accepted
+	ResultTTL time.Duration `mapstructure:"result_ttl"`
+	Export    ExportJobs    `mapstructure:"export"`
+}
+
+// ExportJobs bounds the message exports
+type ExportJobs struct {
+	// MaxMessages is the most messages an export may match, larger ones are refused
+	MaxMessages int `mapstructure:"max_messages"`
+	// PageSize is the number of messages read per query
+	PageSize int `mapstructure:"page_size"`
+	// RowsPerSecond paces the pages so an export does not compete with the scheduler for the database,
+	// 0 reads them back to back
+	RowsPerSecond float64 `mapstructure:"rows_per_second"`
+}
+
+// Maintenance schedules the housekeeping jobs of the server. With several instances every run is taken by one
+// of them, the last run of every job is kept in the maintenance_jobs table.
+type Maintenance struct {
+	// StuckReaper moves messages sending for longer than OlderThan back to pending, e.g. claimed by a crashed instance
+	StuckReaper MaintenanceJob `mapstructure:"stuck_reaper"`
+	// Retention deletes the messages created longer than OlderThan ago
+	Retention MaintenanceJob `mapstructure:"retention"`
+	// Archive writes the messages created longer than OlderThan ago to a JSONL file in Dir, then deletes them
+	Archive MaintenanceJob `mapstructure:"archive"`
+	// StatsRefresh refreshes the count cache of stats.count_cache_interval on one instance instead of every one
+	StatsRefresh MaintenanceJob `mapstructure:"stats_refresh"`
+	// Report sends the daily report of the previous day to the reports notifiers
+	Report MaintenanceJob `mapstructure:"report"`
+	// FailureRequeue requeues the messages failed with a transient failure with backoff, MaxRequeues times
+	// before moving them to the dead-letter queue
+	FailureRequeue MaintenanceJob `mapstructure:"failure_requeue"`
+}
+
+// jobs returns the jobs by their config names
+func (m Maintenance) jobs() map[string]MaintenanceJob {
+	return map[string]MaintenanceJob{
+		"stuck_reaper":    m.StuckReaper,
+		"retention":       m.Retention,
+		"archive":         m.Archive,
+		"stats_refresh":   m.StatsRefresh,
+		"report":          m.Report,
+		"failure_requeue": m.FailureRequeue,
+	}
+}
+
+// MaintenanceJob is a housekeeping job run on its schedule while enabled
+type MaintenanceJob struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Schedule is a cron expression in UTC (minute hour day-of-month month day-of-week), or @every <duration>,
+	// @hourly, @daily, @weekly or @monthly
+	Schedule string `mapstructure:"schedule"`
+	// OlderThan is the age of the messages the stuck reaper, retention and archive jobs handle
+	OlderThan time.Duration `mapstructure:"older_than"`
+	// Dir is the directory the archive job writes to
+	Dir string `mapstructure:"dir"`
+	// Backoff is how long a transient failure waits for its first requeue by the failure_requeue job, doubled
+	// for every further requeue up to MaxBackoff
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// MaxRequeues is how often the failure_requeue job requeues a message before it is dead-lettered
+	MaxRequeues int `mapstructure:"max_requeues"`
+}
+
+// Archive configures the S3 compatible storage the archive job writes to instead of maintenance.archive.dir.
+// The messages are written as gzip compressed JSONL objects of PartSize messages encrypted with EncryptionKey,
+// with a manifest per run.
+type Archive struct {
+	S3 ArchiveS3 `mapstructure:"s3"`
+	// EncryptionKey is the base64 encoded 32 byte AES-256 key of the archived objects, e.g. generated with
+	// `openssl rand -base64 32`. The archives cannot be read without it.
+	EncryptionKey string `mapstructure:"encryption_key"`
+	PartSize      int    `mapstructure:"part_size"`
+}
+
+// ArchiveS3 is an S3 compatible bucket, unused when Bucket is empty
+type ArchiveS3 struct {
+	// Endpoint defaults to the AWS endpoint of Region, e.g. https://s3.eu-central-1.amazonaws.com
+	Endpoint string `mapstructure:"endpoint"`
+	Region   string `mapstructure:"region"`
+	Bucket   string `mapstructure:"bucket"`
+	// Prefix is prepended to the object keys, e.g. sendpulse/
+	Prefix          string `mapstructure:"prefix"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	// PathStyle addresses the bucket in the path instead of the host name, for MinIO and most other
+	// S3 compatible storages
+	PathStyle bool `mapstructure:"path_style"`
+}
+
+// Region is the region of the instance in an active/passive deployment across regions, e.g. two datacenters
+// sharing a replicated database. Only the scheduler of the active region claims messages, so a message is never
+// sent by both.
+type Region struct {
+	// Name is stamped on the messages the instances of the region process, e.g. eu-west
+	Name string `mapstructure:"name"`
+	// Role is RegionActive or RegionPassive. The scheduler of a passive region stays idle until the region is
+	// promoted through the admin API.
+	Role string `mapstructure:"role"`
+}
+
+// Roles of a region
+const (
+	RegionActive  = "active"
+	RegionPassive = "passive"
+)
+
+// Campaigns configures the approval workflow of campaigns. The messages of a registered campaign are held until it
+// was submitted, approved by another API key and launched, so a large blast is never sent on a single decision.
+type Campaigns struct {
+	// RequireApproval holds the messages of every campaign until it is launched, the first message of a campaign
+	// that is not registered registers it as a draft. Without it only the campaigns registered through the API
+	// are held.
+	RequireApproval bool `mapstructure:"require_approval"`
+	// Recheck is how long the messages of a held campaign are deferred before they are checked again, the
+	// messages of a launched campaign are sent within it
+	Recheck time.Duration `mapstructure:"recheck"`
+}
+
+// Stats configures how the message counts of the stats and list totals are computed
+type Stats struct {
+	// CountCacheInterval refreshes the message counts per status into a table at this interval, stats and
+	// unfiltered list totals are served from it instead of counting the messages on every request.
+	// Counts older than three intervals are not used. 0 counts on every request.
+	CountCacheInterval time.Duration `mapstructure:"count_cache_interval"`
+	// Coalesce makes identical concurrent stats, list and time series requests share a single query,
+	// e.g. the widgets of a dashboard refreshing at the same time
+	Coalesce bool `mapstructure:"coalesce"`
+}
+
+// LinkTracking replaces the links in the content of enqueued messages with short links redirecting
+// through the server, so clicks are counted per link
+type LinkTracking struct {
+	Enabled bool `mapstructure:"enabled"`
+	// BaseURL is the public URL the link codes are appended to, it must reach the /l/ path of the server
+	// (e.g. https://sp.example.com/l)
+	BaseURL string `mapstructure:"base_url"`
+}
+
+// DeliveryReports configures two-phase delivery. When enabled a message the webhook accepted is stored as
+// accepted, and only the delivery report of the provider moves it to sent, delivered or failed.
+type DeliveryReports struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Timeout is how long a message may stay accepted before it is marked unconfirmed
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CheckInterval is how often accepted messages are checked against the timeout
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// Callback signature methods
+const (
+	// CallbackHMAC signs the timestamp and the body with HMAC-SHA256
+	CallbackHMAC = "hmac"
+	// CallbackToken sends a shared token
+	CallbackToken = "token"
+)
+
+// Callbacks configures the verification of the delivery reports and inbound messages posted by providers.
+// Without providers the callback endpoints are protected by the API keys like every other endpoint.
+type Callbacks struct {
+	Providers []CallbackProvider `mapstructure:"providers"`
+	// Tolerance is how old the timestamp of a signed callback may be, a signature is accepted once within it
+	Tolerance time.Duration `mapstructure:"tolerance"`
+}
+
+// CallbackProvider is the secret a provider signs its callbacks with
+type CallbackProvider struct {
+	Name string `mapstructure:"name"`
+	// Method is hmac or token
+	Method string `mapstructure:"method"`
+	Secret string `mapstructure:"secret"`
+	// Header carries the signature or the token, X-Signature or X-Callback-Token by default
+	Header string `mapstructure:"header"`
+	// TimestampHeader carries the unix time an hmac signature covers, X-Timestamp by default
+	TimestampHeader string `mapstructure:"timestamp_header"`
+}
+
+// StatusNotices posts a notice to the callback URL of a message, given when it was created or by its API key, once
+// it expired, was blocked because its recipient was suppressed or was moved to the dead-letter queue, so the caller
+// learns it was not delivered instead of assuming it was
+type StatusNotices struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Timeout bounds every post of a notice
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts is how often a notice is posted before it is dropped, the wait doubles between the attempts
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Secret signs the notices like the callbacks of providers are signed, an X-Signature of sha256= and the
+	// hex HMAC-SHA256 of the X-Timestamp, a '.' and the body. Notices are not signed when empty.
+	Secret string `mapstructure:"secret"`
+	// Workers is the number of notices posted at once, a slow callback URL only holds up one of them
+	Workers int `mapstructure:"workers"`
+	// DrainTimeout is how long the shutdown waits for the queued notices to be posted, the ones left are dropped
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// AllowedHosts are the hosts, and their subdomains, notices are posted to, any host when empty. Notices are
+	// never posted to private, loopback or link-local addresses and redirects are not followed.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+}
+
+// Kafka configures consuming message create events from a Kafka topic
+type Kafka struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	GroupID string   `mapstructure:"group_id"`
+	// DLQTopic receives malformed events, they are only logged and skipped when empty
+	DLQTopic string `mapstructure:"dlq_topic"`
+	// WithServer also runs the consumer inside `sendpulse server`, not only in `sendpulse consumer`
+	WithServer bool `mapstructure:"with_server"`
+}
+
+// AMQP configures consuming message create events from a RabbitMQ queue
+type AMQP struct {
+	Enabled bool   `mapstructure:"enabled"`
+	URL     string `mapstructure:"url"`
+	Queue   string `mapstructure:"queue"`
+	// Exchange and RoutingKey bind the queue when the exchange is set
+	Exchange   string `mapstructure:"exchange"`
+	RoutingKey string `mapstructure:"routing_key"`
+	// DeadLetterExchange receives malformed events, they are dropped by the broker when empty
+	DeadLetterExchange string `mapstructure:"dead_letter_exchange"`
+	// Prefetch is the number of unacked deliveries the broker sends ahead
+	Prefetch    int    `mapstructure:"prefetch"`
+	ConsumerTag string `mapstructure:"consumer_tag"`
+	// MaxReconnects is how many times in a row a lost connection is reestablished before the consumer fails
+	MaxReconnects int `mapstructure:"max_reconnects"`
+	// WithServer also runs the consumer inside `sendpulse server`, not only in `sendpulse consumer`
+	WithServer bool `mapstructure:"with_server"`
+}
+
+// NATS configures the JetStream integration, publishing message lifecycle events and consuming
+// message create events are enabled separately
+type NATS struct {
+	URL      string       `mapstructure:"url"`
+	Events   NATSEvents   `mapstructure:"events"`
+	Consumer NATSConsumer `mapstructure:"consumer"`
+}
+
+// NATSEvents configures publishing message lifecycle events to <subject>.<event type>
+type NATSEvents struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Stream captures <subject>.>, it is created when it does not exist yet
+	Stream  string `mapstructure:"stream"`
+	Subject string `mapstructure:"subject"`
 }
 
-func NewConfig(filepath string) (*Cfg, error) {
+// NATSConsumer configures consuming message create events from an existing JetStream stream
+type NATSConsumer struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Stream  string `mapstructure:"stream"`
+	Subject string `mapstructure:"subject"`
+	Durable string `mapstructure:"durable"`
+	// DeadLetterSubject receives malformed events, they are only terminated when empty
+	DeadLetterSubject string `mapstructure:"dead_letter_subject"`
+	// MaxAckPending is the number of unacked messages the server sends ahead
+	MaxAckPending int `mapstructure:"max_ack_pending"`
+	// WithServer also runs the consumer inside `sendpulse server`, not only in `sendpulse consumer`
+	WithServer bool `mapstructure:"with_server"`
+}
+
+// Metrics configures the exported metrics
+type Metrics struct {
+	// MaxLabelValues is the number of distinct tenants and campaigns labeled, the rest are recorded as "other"
+	MaxLabelValues int `mapstructure:"max_label_values"`
+	// WorkerAddress is where `sendpulse worker` serves /metrics and /readyz without the REST API, empty disables it
+	WorkerAddress string `mapstructure:"worker_address"`
+}
+
+// StatsD configures pushing metrics to a StatsD or DogStatsD agent, an empty address disables it
+type StatsD struct {
+	Address string `mapstructure:"address"`
+	// Prefix is prepended to every metric name
+	Prefix string `mapstructure:"prefix"`
+	// Tags are DogStatsD tags (key:value) added to every metric
+	Tags []string `mapstructure:"tags"`
+}
+
+// Sentry configures error reporting, an empty DSN disables it
+type Sentry struct {
+	DSN string `mapstructure:"dsn"`
+	// Environment defaults to the server mode
+	Environment string `mapstructure:"environment"`
+}
+
+// NewConfig loads and validates the config, overrides like command flags are applied before the validation
+func NewConfig(filepath string, overrides ...func(*Cfg)) (*Cfg, error) {
+	cfg, err := load(filepath, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		override(cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	configureLog(cfg.Server.Mode)
+
+	return cfg, nil
+}
+
+// LoadConfig reads the config file and applies the environment overrides without validating the result.
+// Unlike NewConfig a missing or unreadable file is an error.
+func LoadConfig(filepath string) (*Cfg, error) {
+	return load(filepath, true)
+}
+
+func load(filepath string, strict bool) (*Cfg, error) {
 	cfg := &Cfg{}
 
 	// Set defaults first
@@ -66,6 +1121,9 @@ func NewConfig(filepath string) (*Cfg, error) {
 		v.SetConfigType("yaml")
 		v.SetConfigFile(filepath)
 		if err := v.ReadInConfig(); err != nil {
+			if strict {
+				return nil, fmt.Errorf("reading config file: %w", err)
+			}
 			Log().Warnf("reading config file: %v", err)
 		} else {
 			if err := v.Unmarshal(cfg); err != nil {
@@ -74,13 +1132,23 @@ func NewConfig(filepath string) (*Cfg, error) {
 		}
 	}
 
+	// slices are not merged with their defaults, so the default routes are only set when none are configured
+	if cfg.Server.AccessLog.Routes == nil {
+		cfg.Server.AccessLog.Routes = defaultAccessLogRoutes()
+	}
+	if cfg.Suppression.StopKeywords == nil {
+		cfg.Suppression.StopKeywords = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	}
+	if cfg.Suppression.StartKeywords == nil {
+		cfg.Suppression.StartKeywords = []string{"START", "UNSTOP"}
+	}
+	if cfg.Quotas.WarningThresholds == nil {
+		cfg.Quotas.WarningThresholds = slices.Clone(DefaultQuotaWarningThresholds)
+	}
+
 	// Override config with environment variables
 	cfg.loadFromEnv()
 
-	if err := cfg.validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
-	}
-
 	return cfg, nil
 }
 
@@ -88,11 +1156,118 @@ func (cfg *Cfg) setDefaults() {
 	cfg.AppName = defaultAppName
 	cfg.Server.Address = ":8080"
 	cfg.Server.Mode = ModeDev
+	cfg.Server.AccessLog.Enabled = true
+	cfg.Server.AccessLog.SampleRate = 1
+	cfg.Server.Timeouts.Default = 30 * time.Second
+	cfg.Server.RateLimitHeaders = true
+	cfg.Server.LoadShedding.Interval = 5 * time.Second
+	cfg.Server.LoadShedding.Latency = 500 * time.Millisecond
+	cfg.Server.LoadShedding.PoolSaturation = 0.9
+	cfg.Server.LoadShedding.RetryAfter = 30 * time.Second
+	cfg.Stats.Coalesce = true
+	cfg.Database.Storage = StoragePostgres
+	cfg.Database.ConnectTimeout = time.Minute
+	cfg.Database.IDStrategy = IDStrategyBigint
+	cfg.Database.SchemaCheck = SchemaCheckStrict
+	cfg.Database.HealthInterval = 5 * time.Second
+	cfg.Database.Replica.HedgeDelay = 50 * time.Millisecond
 	cfg.Messaging.Interval = 2 * time.Minute
 	cfg.Messaging.BatchSize = 2
 	cfg.Messaging.MaxRetries = 3
 	cfg.Messaging.RetryDelay = 2 * time.Second
 	cfg.Messaging.Enabled = false
+	cfg.Messaging.MetricsInterval = 15 * time.Second
+	cfg.Messaging.ControlInterval = 5 * time.Second
+	cfg.Messaging.ShutdownTimeout = 30 * time.Second
+	cfg.Messaging.PersistTimeout = DefaultPersistTimeout
+	cfg.Messaging.ShutdownReapAfter = time.Minute
+	cfg.Messaging.OverlapPolicy = OverlapSkip
+	cfg.Messaging.MaxConcurrentBatches = 2
+	cfg.Messaging.SkipEvents = true
+	cfg.Messaging.SendAttempts = true
+	cfg.Messaging.PauseRecheck = 5 * time.Minute
+	cfg.Messaging.MaxSegments = DefaultMaxSegments
+	cfg.Webhook.MaxResponseSize = 64 << 10
+	cfg.Webhook.ResponseContentTypes = []string{"application/json", "text/plain"}
+	cfg.Webhook.MaxStoredLength = 1024
+	cfg.Webhook.Payloads.Storage = PayloadStorageNone
+	cfg.Webhook.Payloads.Compression = PayloadCompressionNone
+	cfg.Webhook.Payloads.CompressAbove = 1024
+	cfg.Tracing.Endpoint = "localhost:4318"
+	cfg.Tracing.SampleRatio = 1
+	cfg.Metrics.MaxLabelValues = 100
+	cfg.Metrics.WorkerAddress = ":9091"
+	cfg.Routing.Currency = "USD"
+	cfg.Routing.Sandbox.ReportDelay = time.Second
+	cfg.Routing.Sandbox.DelayedReportDelay = time.Minute
+	cfg.Routing.Health.Window = 5 * time.Minute
+	cfg.Routing.Health.LatencyTarget = 2 * time.Second
+	cfg.Routing.Health.MinSamples = 10
+	cfg.Routing.Health.MinScore = 0.05
+	cfg.Replay.MaxWindow = 24 * time.Hour
+	cfg.Replay.MaxMessages = 10000
+	cfg.Ingestion.MaxMessages = 500000
+	cfg.Ingestion.MaxBodySize = 256 << 20
+	cfg.Ingestion.Workers = 1
+	cfg.Ingestion.MaxQueued = 4
+	cfg.Ingestion.BatchSize = 1000
+	cfg.Jobs.Workers = 1
+	cfg.Jobs.MaxQueued = 4
+	cfg.Jobs.ResultTTL = 24 * time.Hour
+	cfg.Jobs.Export.MaxMessages = 1000000
+	cfg.Jobs.Export.PageSize = 1000
+	cfg.Jobs.Export.RowsPerSecond = 5000
+	cfg.Maintenance.StuckReaper = MaintenanceJob{Schedule: "@every 1m", OlderThan: 10 * time.Minute}
+	cfg.Maintenance.Retention = MaintenanceJob{Schedule: "0 3 * * *", OlderThan: 90 * 24 * time.Hour}
+	cfg.Maintenance.Archive = MaintenanceJob{Schedule: "0 2 * * *", OlderThan: 30 * 24 * time.Hour, Dir: "./archive"}
+	cfg.Maintenance.StatsRefresh = MaintenanceJob{Schedule: "@every 15s"}
+	cfg.Maintenance.Report = MaintenanceJob{Schedule: "0 8 * * *"}
+	cfg.Maintenance.FailureRequeue = MaintenanceJob{Schedule: "@every 30s", Backoff: time.Minute, MaxBackoff: time.Hour, MaxRequeues: 5}
+	cfg.Archive.S3.Region = "us-east-1"
+	cfg.Archive.PartSize = 10000
+	cfg.Region.Role = RegionActive
+	cfg.Campaigns.Recheck = time.Minute
+	cfg.Queue.Backend = "postgres"
+	cfg.Queue.Redis.Address = "localhost:6379"
+	cfg.Queue.Redis.Stream = "sendpulse:messages"
+	cfg.Queue.Redis.Group = "sendpulse"
+	cfg.Queue.Redis.Consumer = defaultConsumerName()
+	cfg.Queue.Redis.ClaimTimeout = time.Minute
+	cfg.Queue.Redis.RefillSize = 500
+	cfg.Queue.PriorityAging.Curve = "linear"
+	cfg.DeliveryReports.Timeout = 24 * time.Hour
+	cfg.DeliveryReports.CheckInterval = 5 * time.Minute
+	cfg.Callbacks.Tolerance = 5 * time.Minute
+	cfg.Kafka.GroupID = "sendpulse"
+	cfg.AMQP.Prefetch = 50
+	cfg.AMQP.ConsumerTag = "sendpulse"
+	cfg.AMQP.MaxReconnects = 10
+	cfg.NATS.URL = "nats://localhost:4222"
+	cfg.NATS.Events.Stream = "SENDPULSE_EVENTS"
+	cfg.NATS.Events.Subject = "sendpulse.events"
+	cfg.NATS.Consumer.Durable = "sendpulse"
+	cfg.NATS.Consumer.MaxAckPending = 50
+	cfg.StatsD.Prefix = "sendpulse."
+	cfg.Alerts.Interval = time.Minute
+	cfg.StatusNotices.Timeout = 5 * time.Second
+	cfg.StatusNotices.MaxAttempts = 3
+	cfg.StatusNotices.Workers = 4
+	cfg.StatusNotices.DrainTimeout = 10 * time.Second
+	cfg.Alerts.MinVolume = 20
+	cfg.Alerts.SchedulerStopped = true
+	cfg.Alerts.QuotaWarnings = true
+	cfg.Reports.At = "08:00"
+	cfg.Reports.Timezone = "UTC"
+	cfg.Reports.TopErrors = 5
+}
+
+// defaultConsumerName identifies this process in a consumer group
+func defaultConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = defaultAppName
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
 }
 
 // loadFromEnv overrides config values with environment variables if they exist
@@ -108,31 +1283,219 @@ func (cfg *Cfg) loadFromEnv() {
 	if envAddress := os.Getenv(envPrefix + "SERVER_ADDRESS"); envAddress != "" {
 		cfg.Server.Address = envAddress
 	}
+	if envAddresses := os.Getenv(envPrefix + "SERVER_ADDRESSES"); envAddresses != "" {
+		cfg.Server.Addresses = strings.Split(envAddresses, ",")
+	}
 	if envMode := os.Getenv(envPrefix + "SERVER_MODE"); envMode != "" {
 		cfg.Server.Mode = Mode(envMode)
 	}
+	if envAPIKey := os.Getenv(envPrefix + "SERVER_API_KEY"); envAPIKey != "" {
+		cfg.Server.APIKey = envAPIKey
+	}
+	if envEnabled := os.Getenv(envPrefix + "SERVER_ACCESS_LOG_ENABLED"); envEnabled != "" {
+		cfg.Server.AccessLog.Enabled = envEnabled == "true"
+	}
+	if envSampleRate := os.Getenv(envPrefix + "SERVER_ACCESS_LOG_SAMPLE_RATE"); envSampleRate != "" {
+		fmt.Sscanf(envSampleRate, "%g", &cfg.Server.AccessLog.SampleRate)
+	}
+	if envTimeout := os.Getenv(envPrefix + "SERVER_TIMEOUTS_DEFAULT"); envTimeout != "" {
+		if duration, err := time.ParseDuration(envTimeout); err == nil {
+			cfg.Server.Timeouts.Default = duration
+		}
+	}
+	if envHeaders := os.Getenv(envPrefix + "SERVER_RATE_LIMIT_HEADERS"); envHeaders != "" {
+		cfg.Server.RateLimitHeaders = envHeaders == "true"
+	}
+	if envEnabled := os.Getenv(envPrefix + "SERVER_LOAD_SHEDDING_ENABLED"); envEnabled != "" {
+		cfg.Server.LoadShedding.Enabled = envEnabled == "true"
+	}
+	if envLatency := os.Getenv(envPrefix + "SERVER_LOAD_SHEDDING_LATENCY"); envLatency != "" {
+		if duration, err := time.ParseDuration(envLatency); err == nil {
+			cfg.Server.LoadShedding.Latency = duration
+		}
+	}
+	if envSaturation := os.Getenv(envPrefix + "SERVER_LOAD_SHEDDING_POOL_SATURATION"); envSaturation != "" {
+		fmt.Sscanf(envSaturation, "%g", &cfg.Server.LoadShedding.PoolSaturation)
+	}
 
 	// Database config
+	if envStorage := os.Getenv(envPrefix + "DATABASE_STORAGE"); envStorage != "" {
+		cfg.Database.Storage = envStorage
+	}
 	if envDSN := os.Getenv(envPrefix + "DATABASE_DSN"); envDSN != "" {
 		cfg.Database.DSN = envDSN
 	}
-
-	// Webhook config
-	if envURL := os.Getenv(envPrefix + "WEBHOOK_URL"); envURL != "" {
-		cfg.Webhook.URL = envURL
+	if envTimeout := os.Getenv(envPrefix + "DATABASE_CONNECT_TIMEOUT"); envTimeout != "" {
+		if duration, err := time.ParseDuration(envTimeout); err == nil {
+			cfg.Database.ConnectTimeout = duration
+		}
 	}
-
-	// Messaging config
-	if envEnabled := os.Getenv(envPrefix + "MESSAGING_ENABLED"); envEnabled != "" {
-		cfg.Messaging.Enabled = envEnabled == "true"
+	if envStrategy := os.Getenv(envPrefix + "DATABASE_ID_STRATEGY"); envStrategy != "" {
+		cfg.Database.IDStrategy = envStrategy
 	}
-	if envInterval := os.Getenv(envPrefix + "MESSAGING_INTERVAL"); envInterval != "" {
+	if envMaxOpen := os.Getenv(envPrefix + "DATABASE_MAX_OPEN_CONNS"); envMaxOpen != "" {
+		fmt.Sscanf(envMaxOpen, "%d", &cfg.Database.MaxOpenConns)
+	}
+	if envSchemaCheck := os.Getenv(envPrefix + "DATABASE_SCHEMA_CHECK"); envSchemaCheck != "" {
+		cfg.Database.SchemaCheck = envSchemaCheck
+	}
+	if envReplicaDSN := os.Getenv(envPrefix + "DATABASE_REPLICA_DSN"); envReplicaDSN != "" {
+		cfg.Database.Replica.DSN = envReplicaDSN
+	}
+	if envHedge := os.Getenv(envPrefix + "DATABASE_REPLICA_HEDGE"); envHedge != "" {
+		cfg.Database.Replica.Hedge = envHedge == "true"
+	}
+
+	// Stats config
+	if envInterval := os.Getenv(envPrefix + "STATS_COUNT_CACHE_INTERVAL"); envInterval != "" {
 		if duration, err := time.ParseDuration(envInterval); err == nil {
-			cfg.Messaging.Interval = duration
+			cfg.Stats.CountCacheInterval = duration
 		}
 	}
-	if envBatchSize := os.Getenv(envPrefix + "MESSAGING_BATCH_SIZE"); envBatchSize != "" {
-		fmt.Sscanf(envBatchSize, "%d", &cfg.Messaging.BatchSize)
+	if envCoalesce := os.Getenv(envPrefix + "STATS_COALESCE"); envCoalesce != "" {
+		cfg.Stats.Coalesce = envCoalesce == "true"
+	}
+
+	// Maintenance config, e.g. SENDPULSE_MAINTENANCE_STUCK_REAPER_ENABLED
+	for name, job := range map[string]*MaintenanceJob{
+		"STUCK_REAPER": &cfg.Maintenance.StuckReaper, "RETENTION": &cfg.Maintenance.Retention,
+		"ARCHIVE": &cfg.Maintenance.Archive, "STATS_REFRESH": &cfg.Maintenance.StatsRefresh, "REPORT": &cfg.Maintenance.Report,
+		"FAILURE_REQUEUE": &cfg.Maintenance.FailureRequeue,
+	} {
+		prefix := envPrefix + "MAINTENANCE_" + name + "_"
+		if envEnabled := os.Getenv(prefix + "ENABLED"); envEnabled != "" {
+			job.Enabled = envEnabled == "true"
+		}
+		if envSchedule := os.Getenv(prefix + "SCHEDULE"); envSchedule != "" {
+			job.Schedule = envSchedule
+		}
+		if envOlderThan := os.Getenv(prefix + "OLDER_THAN"); envOlderThan != "" {
+			if duration, err := time.ParseDuration(envOlderThan); err == nil {
+				job.OlderThan = duration
+			}
+		}
+	}
+	if envBackoff := os.Getenv(envPrefix + "MAINTENANCE_FAILURE_REQUEUE_BACKOFF"); envBackoff != "" {
+		if duration, err := time.ParseDuration(envBackoff); err == nil {
+			cfg.Maintenance.FailureRequeue.Backoff = duration
+		}
+	}
+	if envMaxBackoff := os.Getenv(envPrefix + "MAINTENANCE_FAILURE_REQUEUE_MAX_BACKOFF"); envMaxBackoff != "" {
+		if duration, err := time.ParseDuration(envMaxBackoff); err == nil {
+			cfg.Maintenance.FailureRequeue.MaxBackoff = duration
+		}
+	}
+	if envMaxRequeues := os.Getenv(envPrefix + "MAINTENANCE_FAILURE_REQUEUE_MAX_REQUEUES"); envMaxRequeues != "" {
+		fmt.Sscanf(envMaxRequeues, "%d", &cfg.Maintenance.FailureRequeue.MaxRequeues)
+	}
+
+	// Archive config
+	if envBucket := os.Getenv(envPrefix + "ARCHIVE_S3_BUCKET"); envBucket != "" {
+		cfg.Archive.S3.Bucket = envBucket
+	}
+	if envEndpoint := os.Getenv(envPrefix + "ARCHIVE_S3_ENDPOINT"); envEndpoint != "" {
+		cfg.Archive.S3.Endpoint = envEndpoint
+	}
+	if envRegion := os.Getenv(envPrefix + "ARCHIVE_S3_REGION"); envRegion != "" {
+		cfg.Archive.S3.Region = envRegion
+	}
+	if envAccessKeyID := os.Getenv(envPrefix + "ARCHIVE_S3_ACCESS_KEY_ID"); envAccessKeyID != "" {
+		cfg.Archive.S3.AccessKeyID = envAccessKeyID
+	}
+	if envSecretAccessKey := os.Getenv(envPrefix + "ARCHIVE_S3_SECRET_ACCESS_KEY"); envSecretAccessKey != "" {
+		cfg.Archive.S3.SecretAccessKey = envSecretAccessKey
+	}
+	if envKey := os.Getenv(envPrefix + "ARCHIVE_ENCRYPTION_KEY"); envKey != "" {
+		cfg.Archive.EncryptionKey = envKey
+	}
+
+	// Region config
+	if envName := os.Getenv(envPrefix + "REGION_NAME"); envName != "" {
+		cfg.Region.Name = envName
+	}
+	if envRole := os.Getenv(envPrefix + "REGION_ROLE"); envRole != "" {
+		cfg.Region.Role = envRole
+	}
+
+	// Campaigns config
+	if envRequire := os.Getenv(envPrefix + "CAMPAIGNS_REQUIRE_APPROVAL"); envRequire != "" {
+		cfg.Campaigns.RequireApproval = envRequire == "true"
+	}
+	if envRecheck := os.Getenv(envPrefix + "CAMPAIGNS_RECHECK"); envRecheck != "" {
+		if duration, err := time.ParseDuration(envRecheck); err == nil {
+			cfg.Campaigns.Recheck = duration
+		}
+	}
+
+	// Routing config
+	if envWindow := os.Getenv(envPrefix + "ROUTING_HEALTH_WINDOW"); envWindow != "" {
+		if duration, err := time.ParseDuration(envWindow); err == nil {
+			cfg.Routing.Health.Window = duration
+		}
+	}
+	if envLatency := os.Getenv(envPrefix + "ROUTING_HEALTH_LATENCY_TARGET"); envLatency != "" {
+		if duration, err := time.ParseDuration(envLatency); err == nil {
+			cfg.Routing.Health.LatencyTarget = duration
+		}
+	}
+
+	// Webhook config
+	if envURL := os.Getenv(envPrefix + "WEBHOOK_URL"); envURL != "" {
+		cfg.Webhook.URL = envURL
+	}
+	if envMaxResponseSize := os.Getenv(envPrefix + "WEBHOOK_MAX_RESPONSE_SIZE"); envMaxResponseSize != "" {
+		fmt.Sscanf(envMaxResponseSize, "%d", &cfg.Webhook.MaxResponseSize)
+	}
+	if envContentTypes := os.Getenv(envPrefix + "WEBHOOK_RESPONSE_CONTENT_TYPES"); envContentTypes != "" {
+		cfg.Webhook.ResponseContentTypes = strings.Split(envContentTypes, ",")
+	}
+	if envMaxStoredLength := os.Getenv(envPrefix + "WEBHOOK_MAX_STORED_LENGTH"); envMaxStoredLength != "" {
+		fmt.Sscanf(envMaxStoredLength, "%d", &cfg.Webhook.MaxStoredLength)
+	}
+	if envLocalAddress := os.Getenv(envPrefix + "WEBHOOK_LOCAL_ADDRESS"); envLocalAddress != "" {
+		cfg.Webhook.LocalAddress = envLocalAddress
+	}
+	if envInterface := os.Getenv(envPrefix + "WEBHOOK_INTERFACE"); envInterface != "" {
+		cfg.Webhook.Interface = envInterface
+	}
+	if envEgressIPs := os.Getenv(envPrefix + "WEBHOOK_EGRESS_IPS"); envEgressIPs != "" {
+		cfg.Webhook.EgressIPs = strings.Split(envEgressIPs, ",")
+	}
+	if envEncryptionKey := os.Getenv(envPrefix + "WEBHOOK_ENCRYPTION_KEY"); envEncryptionKey != "" {
+		cfg.Webhook.EncryptionKey = envEncryptionKey
+	}
+	if envPayloadStorage := os.Getenv(envPrefix + "WEBHOOK_PAYLOADS_STORAGE"); envPayloadStorage != "" {
+		cfg.Webhook.Payloads.Storage = envPayloadStorage
+	}
+	if envPayloadThreshold := os.Getenv(envPrefix + "WEBHOOK_PAYLOADS_THRESHOLD"); envPayloadThreshold != "" {
+		fmt.Sscanf(envPayloadThreshold, "%d", &cfg.Webhook.Payloads.Threshold)
+	}
+	if envPayloadCompression := os.Getenv(envPrefix + "WEBHOOK_PAYLOADS_COMPRESSION"); envPayloadCompression != "" {
+		cfg.Webhook.Payloads.Compression = envPayloadCompression
+	}
+	if envCompressAbove := os.Getenv(envPrefix + "WEBHOOK_PAYLOADS_COMPRESS_ABOVE"); envCompressAbove != "" {
+		fmt.Sscanf(envCompressAbove, "%d", &cfg.Webhook.Payloads.CompressAbove)
+	}
+	if envPrewarm := os.Getenv(envPrefix + "WEBHOOK_CONNECTIONS_PREWARM"); envPrewarm != "" {
+		fmt.Sscanf(envPrewarm, "%d", &cfg.Webhook.Connections.Prewarm)
+	}
+	if envDNSCacheTTL := os.Getenv(envPrefix + "WEBHOOK_CONNECTIONS_DNS_CACHE_TTL"); envDNSCacheTTL != "" {
+		if duration, err := time.ParseDuration(envDNSCacheTTL); err == nil {
+			cfg.Webhook.Connections.DNSCacheTTL = duration
+		}
+	}
+
+	// Messaging config
+	if envEnabled := os.Getenv(envPrefix + "MESSAGING_ENABLED"); envEnabled != "" {
+		cfg.Messaging.Enabled = envEnabled == "true"
+	}
+	if envInterval := os.Getenv(envPrefix + "MESSAGING_INTERVAL"); envInterval != "" {
+		if duration, err := time.ParseDuration(envInterval); err == nil {
+			cfg.Messaging.Interval = duration
+		}
+	}
+	if envBatchSize := os.Getenv(envPrefix + "MESSAGING_BATCH_SIZE"); envBatchSize != "" {
+		fmt.Sscanf(envBatchSize, "%d", &cfg.Messaging.BatchSize)
 	}
 	if envMaxRetries := os.Getenv(envPrefix + "MESSAGING_MAX_RETRIES"); envMaxRetries != "" {
 		fmt.Sscanf(envMaxRetries, "%d", &cfg.Messaging.MaxRetries)
@@ -142,6 +1505,216 @@ func (cfg *Cfg) loadFromEnv() {
 			cfg.Messaging.RetryDelay = duration
 		}
 	}
+	if envMetricsInterval := os.Getenv(envPrefix + "MESSAGING_METRICS_INTERVAL"); envMetricsInterval != "" {
+		if duration, err := time.ParseDuration(envMetricsInterval); err == nil {
+			cfg.Messaging.MetricsInterval = duration
+		}
+	}
+	if envLeaderLease := os.Getenv(envPrefix + "MESSAGING_LEADER_LEASE"); envLeaderLease != "" {
+		if duration, err := time.ParseDuration(envLeaderLease); err == nil {
+			cfg.Messaging.LeaderLease = duration
+		}
+	}
+	if envControlInterval := os.Getenv(envPrefix + "MESSAGING_CONTROL_INTERVAL"); envControlInterval != "" {
+		if duration, err := time.ParseDuration(envControlInterval); err == nil {
+			cfg.Messaging.ControlInterval = duration
+		}
+	}
+	if envShutdownTimeout := os.Getenv(envPrefix + "MESSAGING_SHUTDOWN_TIMEOUT"); envShutdownTimeout != "" {
+		if duration, err := time.ParseDuration(envShutdownTimeout); err == nil {
+			cfg.Messaging.ShutdownTimeout = duration
+		}
+	}
+	if envPersistTimeout := os.Getenv(envPrefix + "MESSAGING_PERSIST_TIMEOUT"); envPersistTimeout != "" {
+		if duration, err := time.ParseDuration(envPersistTimeout); err == nil {
+			cfg.Messaging.PersistTimeout = duration
+		}
+	}
+	if envShutdownReapAfter := os.Getenv(envPrefix + "MESSAGING_SHUTDOWN_REAP_AFTER"); envShutdownReapAfter != "" {
+		if duration, err := time.ParseDuration(envShutdownReapAfter); err == nil {
+			cfg.Messaging.ShutdownReapAfter = duration
+		}
+	}
+	if envOverlapPolicy := os.Getenv(envPrefix + "MESSAGING_OVERLAP_POLICY"); envOverlapPolicy != "" {
+		cfg.Messaging.OverlapPolicy = envOverlapPolicy
+	}
+	if envMaxBatches := os.Getenv(envPrefix + "MESSAGING_MAX_CONCURRENT_BATCHES"); envMaxBatches != "" {
+		fmt.Sscanf(envMaxBatches, "%d", &cfg.Messaging.MaxConcurrentBatches)
+	}
+	if envSkipEvents := os.Getenv(envPrefix + "MESSAGING_SKIP_EVENTS"); envSkipEvents != "" {
+		cfg.Messaging.SkipEvents = envSkipEvents == "true"
+	}
+	if envSendAttempts := os.Getenv(envPrefix + "MESSAGING_SEND_ATTEMPTS"); envSendAttempts != "" {
+		cfg.Messaging.SendAttempts = envSendAttempts == "true"
+	}
+	if envPauseRecheck := os.Getenv(envPrefix + "MESSAGING_PAUSE_RECHECK"); envPauseRecheck != "" {
+		if duration, err := time.ParseDuration(envPauseRecheck); err == nil {
+			cfg.Messaging.PauseRecheck = duration
+		}
+	}
+	if envMaxSegments := os.Getenv(envPrefix + "MESSAGING_MAX_SEGMENTS"); envMaxSegments != "" {
+		fmt.Sscanf(envMaxSegments, "%d", &cfg.Messaging.MaxSegments)
+	}
+
+	// Tracing config
+	if envEnabled := os.Getenv(envPrefix + "TRACING_ENABLED"); envEnabled != "" {
+		cfg.Tracing.Enabled = envEnabled == "true"
+	}
+	if envEndpoint := os.Getenv(envPrefix + "TRACING_ENDPOINT"); envEndpoint != "" {
+		cfg.Tracing.Endpoint = envEndpoint
+	}
+	if envInsecure := os.Getenv(envPrefix + "TRACING_INSECURE"); envInsecure != "" {
+		cfg.Tracing.Insecure = envInsecure == "true"
+	}
+	if envRatio := os.Getenv(envPrefix + "TRACING_SAMPLE_RATIO"); envRatio != "" {
+		fmt.Sscanf(envRatio, "%g", &cfg.Tracing.SampleRatio)
+	}
+
+	// Sentry config
+	if envDSN := os.Getenv(envPrefix + "SENTRY_DSN"); envDSN != "" {
+		cfg.Sentry.DSN = envDSN
+	}
+	if envEnvironment := os.Getenv(envPrefix + "SENTRY_ENVIRONMENT"); envEnvironment != "" {
+		cfg.Sentry.Environment = envEnvironment
+	}
+
+	// Metrics config
+	if envAddress := os.Getenv(envPrefix + "METRICS_WORKER_ADDRESS"); envAddress != "" {
+		cfg.Metrics.WorkerAddress = envAddress
+	}
+
+	// StatsD config
+	if envAddress := os.Getenv(envPrefix + "STATSD_ADDRESS"); envAddress != "" {
+		cfg.StatsD.Address = envAddress
+	}
+
+	// Queue config
+	if envBackend := os.Getenv(envPrefix + "QUEUE_BACKEND"); envBackend != "" {
+		cfg.Queue.Backend = envBackend
+	}
+	if envAddress := os.Getenv(envPrefix + "QUEUE_REDIS_ADDRESS"); envAddress != "" {
+		cfg.Queue.Redis.Address = envAddress
+	}
+	if envPassword := os.Getenv(envPrefix + "QUEUE_REDIS_PASSWORD"); envPassword != "" {
+		cfg.Queue.Redis.Password = envPassword
+	}
+	if envConsumer := os.Getenv(envPrefix + "QUEUE_REDIS_CONSUMER"); envConsumer != "" {
+		cfg.Queue.Redis.Consumer = envConsumer
+	}
+	if envInterval := os.Getenv(envPrefix + "QUEUE_PRIORITY_AGING_INTERVAL"); envInterval != "" {
+		if duration, err := time.ParseDuration(envInterval); err == nil {
+			cfg.Queue.PriorityAging.Interval = duration
+		}
+	}
+	if envCurve := os.Getenv(envPrefix + "QUEUE_PRIORITY_AGING_CURVE"); envCurve != "" {
+		cfg.Queue.PriorityAging.Curve = envCurve
+	}
+	if envMaxBoost := os.Getenv(envPrefix + "QUEUE_PRIORITY_AGING_MAX_BOOST"); envMaxBoost != "" {
+		fmt.Sscanf(envMaxBoost, "%d", &cfg.Queue.PriorityAging.MaxBoost)
+	}
+
+	// Delivery reports config
+	if envEnabled := os.Getenv(envPrefix + "DELIVERY_REPORTS_ENABLED"); envEnabled != "" {
+		cfg.DeliveryReports.Enabled = envEnabled == "true"
+	}
+	if envTimeout := os.Getenv(envPrefix + "DELIVERY_REPORTS_TIMEOUT"); envTimeout != "" {
+		if duration, err := time.ParseDuration(envTimeout); err == nil {
+			cfg.DeliveryReports.Timeout = duration
+		}
+	}
+
+	// Callbacks config
+	if envTolerance := os.Getenv(envPrefix + "CALLBACKS_TOLERANCE"); envTolerance != "" {
+		if duration, err := time.ParseDuration(envTolerance); err == nil {
+			cfg.Callbacks.Tolerance = duration
+		}
+	}
+
+	// Link tracking config
+	if envEnabled := os.Getenv(envPrefix + "LINK_TRACKING_ENABLED"); envEnabled != "" {
+		cfg.LinkTracking.Enabled = envEnabled == "true"
+	}
+	if envBaseURL := os.Getenv(envPrefix + "LINK_TRACKING_BASE_URL"); envBaseURL != "" {
+		cfg.LinkTracking.BaseURL = envBaseURL
+	}
+
+	// Kafka config
+	if envEnabled := os.Getenv(envPrefix + "KAFKA_ENABLED"); envEnabled != "" {
+		cfg.Kafka.Enabled = envEnabled == "true"
+	}
+	if envBrokers := os.Getenv(envPrefix + "KAFKA_BROKERS"); envBrokers != "" {
+		cfg.Kafka.Brokers = strings.Split(envBrokers, ",")
+	}
+	if envTopic := os.Getenv(envPrefix + "KAFKA_TOPIC"); envTopic != "" {
+		cfg.Kafka.Topic = envTopic
+	}
+	if envGroupID := os.Getenv(envPrefix + "KAFKA_GROUP_ID"); envGroupID != "" {
+		cfg.Kafka.GroupID = envGroupID
+	}
+	if envDLQTopic := os.Getenv(envPrefix + "KAFKA_DLQ_TOPIC"); envDLQTopic != "" {
+		cfg.Kafka.DLQTopic = envDLQTopic
+	}
+
+	// AMQP config
+	if envEnabled := os.Getenv(envPrefix + "AMQP_ENABLED"); envEnabled != "" {
+		cfg.AMQP.Enabled = envEnabled == "true"
+	}
+	if envURL := os.Getenv(envPrefix + "AMQP_URL"); envURL != "" {
+		cfg.AMQP.URL = envURL
+	}
+	if envQueue := os.Getenv(envPrefix + "AMQP_QUEUE"); envQueue != "" {
+		cfg.AMQP.Queue = envQueue
+	}
+
+	// NATS config
+	if envURL := os.Getenv(envPrefix + "NATS_URL"); envURL != "" {
+		cfg.NATS.URL = envURL
+	}
+	if envEnabled := os.Getenv(envPrefix + "NATS_EVENTS_ENABLED"); envEnabled != "" {
+		cfg.NATS.Events.Enabled = envEnabled == "true"
+	}
+	if envEnabled := os.Getenv(envPrefix + "NATS_CONSUMER_ENABLED"); envEnabled != "" {
+		cfg.NATS.Consumer.Enabled = envEnabled == "true"
+	}
+
+	// Status notices config
+	if envEnabled := os.Getenv(envPrefix + "STATUS_NOTICES_ENABLED"); envEnabled != "" {
+		cfg.StatusNotices.Enabled = envEnabled == "true"
+	}
+	if envSecret := os.Getenv(envPrefix + "STATUS_NOTICES_SECRET"); envSecret != "" {
+		cfg.StatusNotices.Secret = envSecret
+	}
+	if envHosts := os.Getenv(envPrefix + "STATUS_NOTICES_ALLOWED_HOSTS"); envHosts != "" {
+		cfg.StatusNotices.AllowedHosts = strings.Split(envHosts, ",")
+	}
+
+	// Alerts config
+	if envEnabled := os.Getenv(envPrefix + "ALERTS_ENABLED"); envEnabled != "" {
+		cfg.Alerts.Enabled = envEnabled == "true"
+	}
+	if envSlackURL := os.Getenv(envPrefix + "ALERTS_SLACK_WEBHOOK_URL"); envSlackURL != "" {
+		cfg.Alerts.SlackWebhookURL = envSlackURL
+	}
+	if envHTTPURL := os.Getenv(envPrefix + "ALERTS_HTTP_URL"); envHTTPURL != "" {
+		cfg.Alerts.HTTPURL = envHTTPURL
+	}
+	if envPassword := os.Getenv(envPrefix + "ALERTS_EMAIL_PASSWORD"); envPassword != "" {
+		cfg.Alerts.Email.Password = envPassword
+	}
+
+	// Reports config
+	if envEnabled := os.Getenv(envPrefix + "REPORTS_ENABLED"); envEnabled != "" {
+		cfg.Reports.Enabled = envEnabled == "true"
+	}
+	if envSlackURL := os.Getenv(envPrefix + "REPORTS_SLACK_WEBHOOK_URL"); envSlackURL != "" {
+		cfg.Reports.SlackWebhookURL = envSlackURL
+	}
+	if envHTTPURL := os.Getenv(envPrefix + "REPORTS_HTTP_URL"); envHTTPURL != "" {
+		cfg.Reports.HTTPURL = envHTTPURL
+	}
+	if envPassword := os.Getenv(envPrefix + "REPORTS_EMAIL_PASSWORD"); envPassword != "" {
+		cfg.Reports.Email.Password = envPassword
+	}
 }
 
 func (cfg *Cfg) SetDB(db *bun.DB) *Cfg {
@@ -149,15 +1722,40 @@ func (cfg *Cfg) SetDB(db *bun.DB) *Cfg {
 	return cfg
 }
 
+// Log returns the global logger, created on the first call that may come from any goroutine
 func Log() *logrus.Logger {
-	if Logger != nil {
-		return Logger
+	logOnce.Do(func() {
+		if Logger != nil {
+			return
+		}
+		Logger = logrus.New()
+		Logger.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+		Logger.Hooks.Add(filename.NewHook())
+	})
+	return Logger
+}
+
+// configureLog switches the logger to JSON in prod mode so log lines can be queried by their fields
+func configureLog(mode Mode) {
+	if mode == ModeProd {
+		Log().Formatter = &logrus.JSONFormatter{}
 	}
+}
 
-	Logger = logrus.New()
-	Logger.Formatter = &logrus.TextFormatter{FullTimestamp: true}
-	Logger.Hooks.Add(filename.NewHook())
-	return Logger
+type logContextKey struct{}
+
+// ContextWithLog returns a copy of ctx carrying the logger, see LogFrom
+func ContextWithLog(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, logContextKey{}, entry)
+}
+
+// LogFrom returns the request or message scoped logger stored in ctx,
+// falling back to the global logger when there is none
+func LogFrom(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(logContextKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(Log())
 }
 
 func (cfg *Cfg) validate() error {
@@ -165,9 +1763,730 @@ func (cfg *Cfg) validate() error {
 		return fmt.Errorf("server mode is required: %s is not a valid mode", cfg.Server.Mode)
 	}
 
-	if cfg.Database.DSN == "" {
-		return fmt.Errorf("database DSN is required")
+	switch cfg.Database.Storage {
+	case StorageMemory:
+	case StoragePostgres, "":
+		if cfg.Database.DSN == "" {
+			return fmt.Errorf("database DSN is required")
+		}
+	default:
+		return fmt.Errorf("unknown database storage %q, expected %s or %s", cfg.Database.Storage, StoragePostgres, StorageMemory)
+	}
+	if !slices.Contains([]string{IDStrategyBigint, IDStrategyULID, IDStrategyUUID, ""}, cfg.Database.IDStrategy) {
+		return fmt.Errorf("unknown database ID strategy %q, expected %s, %s or %s", cfg.Database.IDStrategy, IDStrategyBigint, IDStrategyULID, IDStrategyUUID)
+	}
+
+	// tickers panic on intervals that are not positive, so the ones started by the enabled features are
+	// rejected here as well and not only reported by Validate
+	for _, interval := range []struct {
+		name    string
+		value   time.Duration
+		enabled bool
+	}{
+		{"messaging.interval", cfg.Messaging.Interval, true},
+		{"messaging.shutdown_timeout", cfg.Messaging.ShutdownTimeout, true},
+		{"messaging.persist_timeout", cfg.Messaging.PersistTimeout, true},
+		{"database.health_interval", cfg.Database.HealthInterval, true},
+		{"alerts.interval", cfg.Alerts.Interval, cfg.Alerts.Enabled},
+		{"delivery_reports.check_interval", cfg.DeliveryReports.CheckInterval, cfg.DeliveryReports.Enabled},
+		{"server.load_shedding.interval", cfg.Server.LoadShedding.Interval, cfg.Server.LoadShedding.Enabled},
+	} {
+		if interval.enabled && interval.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", interval.name, interval.value)
+		}
+	}
+
+	// the scheduler cannot send the messages of a route without its provider
+	for _, route := range cfg.Routing.allRoutes() {
+		for _, provider := range route.ProviderNames() {
+			if provider != "" && provider != WebhookProvider && provider != SandboxProvider && !slices.ContainsFunc(cfg.Routing.Providers, func(p Provider) bool { return p.Name == provider }) {
+				return fmt.Errorf("routing: unknown provider %q", provider)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate runs the full validation and returns every problem found.
+// NewConfig only rejects configs the application cannot start with, Validate also reports
+// values that would fail at runtime, like a missing webhook URL while messaging is enabled.
+func (cfg *Cfg) Validate() []error {
+	var errs []error
+
+	if cfg.Server.Mode != ModeProd && cfg.Server.Mode != ModeDev {
+		errs = append(errs, fmt.Errorf("server.mode: %q is not a valid mode, expected %s or %s", cfg.Server.Mode, ModeDev, ModeProd))
+	}
+	if cfg.Server.Address == "" {
+		errs = append(errs, fmt.Errorf("server.address is required"))
+	} else if _, _, err := net.SplitHostPort(cfg.Server.Address); err != nil {
+		errs = append(errs, fmt.Errorf("server.address: %w", err))
+	}
+	for i, address := range cfg.Server.Addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			errs = append(errs, fmt.Errorf("server.addresses[%d]: %w", i, err))
+		}
+	}
+
+	switch cfg.Database.Storage {
+	case StorageMemory:
+		// kept in the server process, no DSN needed
+	case StoragePostgres, "":
+		if cfg.Database.DSN == "" {
+			errs = append(errs, fmt.Errorf("database.dsn is required"))
+		} else if u, err := url.Parse(cfg.Database.DSN); err != nil {
+			errs = append(errs, fmt.Errorf("database.dsn is not a valid URL"))
+		} else if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			errs = append(errs, fmt.Errorf("database.dsn: unsupported scheme %q, expected postgres", u.Scheme))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.storage: unknown storage %q, expected %s or %s", cfg.Database.Storage, StoragePostgres, StorageMemory))
+	}
+	if !slices.Contains([]string{IDStrategyBigint, IDStrategyULID, IDStrategyUUID, ""}, cfg.Database.IDStrategy) {
+		errs = append(errs, fmt.Errorf("database.id_strategy: unknown strategy %q, expected %s, %s or %s", cfg.Database.IDStrategy, IDStrategyBigint, IDStrategyULID, IDStrategyUUID))
+	}
+	if !slices.Contains([]string{SchemaCheckStrict, SchemaCheckReadOnly, SchemaCheckOff}, cfg.Database.SchemaCheck) {
+		errs = append(errs, fmt.Errorf("database.schema_check: unknown check %q, expected %s, %s or %s", cfg.Database.SchemaCheck, SchemaCheckStrict, SchemaCheckReadOnly, SchemaCheckOff))
+	}
+	if cfg.Database.ConnectTimeout < 0 {
+		errs = append(errs, fmt.Errorf("database.connect_timeout cannot be negative"))
+	}
+	if cfg.Database.HealthInterval <= 0 {
+		errs = append(errs, fmt.Errorf("database.health_interval must be positive"))
+	}
+	if cfg.Database.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns cannot be negative"))
+	}
+	if replica := cfg.Database.Replica; replica.DSN != "" {
+		if cfg.Database.Storage == StorageMemory {
+			errs = append(errs, fmt.Errorf("database.replica cannot be used with the memory storage"))
+		} else if u, err := url.Parse(replica.DSN); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			errs = append(errs, fmt.Errorf("database.replica.dsn is not a valid postgres URL"))
+		}
+		if replica.Hedge && replica.HedgeDelay <= 0 {
+			errs = append(errs, fmt.Errorf("database.replica.hedge_delay must be positive"))
+		}
+	}
+
+	if cfg.Messaging.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("messaging.interval must be positive"))
+	}
+	if cfg.Messaging.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("messaging.batch_size must be at least 1"))
+	}
+	if cfg.Messaging.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("messaging.max_retries cannot be negative"))
+	}
+	if cfg.Messaging.MaxSegments < 1 {
+		errs = append(errs, fmt.Errorf("messaging.max_segments must be at least 1"))
+	}
+	if cfg.Messaging.MetricsInterval < 0 {
+		errs = append(errs, fmt.Errorf("messaging.metrics_interval cannot be negative"))
+	}
+	if cfg.Messaging.LeaderLease < 0 {
+		errs = append(errs, fmt.Errorf("messaging.leader_lease cannot be negative"))
+	} else if cfg.Messaging.LeaderLease > 0 && cfg.Messaging.LeaderLease <= cfg.Messaging.Interval {
+		errs = append(errs, fmt.Errorf("messaging.leader_lease must be longer than messaging.interval, the lease is renewed every interval"))
+	}
+	if cfg.Messaging.ControlInterval < 0 {
+		errs = append(errs, fmt.Errorf("messaging.control_interval cannot be negative"))
+	}
+	if cfg.Messaging.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("messaging.shutdown_timeout must be positive"))
+	}
+	if cfg.Messaging.PersistTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("messaging.persist_timeout must be positive"))
+	}
+	if cfg.Messaging.ShutdownReapAfter <= 0 {
+		errs = append(errs, fmt.Errorf("messaging.shutdown_reap_after must be positive"))
+	}
+	if cfg.Messaging.RetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("messaging.retry_delay cannot be negative"))
+	}
+	if cfg.Messaging.PauseRecheck <= 0 {
+		errs = append(errs, fmt.Errorf("messaging.pause_recheck must be positive"))
+	}
+	switch cfg.Messaging.OverlapPolicy {
+	case OverlapSkip, OverlapQueue, OverlapConcurrent, "":
+	default:
+		errs = append(errs, fmt.Errorf("messaging.overlap_policy: unknown policy %q, expected %s, %s or %s",
+			cfg.Messaging.OverlapPolicy, OverlapSkip, OverlapQueue, OverlapConcurrent))
+	}
+	if cfg.Messaging.OverlapPolicy == OverlapConcurrent && cfg.Messaging.MaxConcurrentBatches < 1 {
+		errs = append(errs, fmt.Errorf("messaging.max_concurrent_batches must be at least 1"))
+	}
+
+	if cfg.Tracing.Enabled && cfg.Tracing.Endpoint == "" {
+		errs = append(errs, fmt.Errorf("tracing.endpoint is required when tracing is enabled"))
+	}
+	if cfg.Server.AccessLog.SampleRate < 0 || cfg.Server.AccessLog.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("server.access_log.sample_rate must be between 0 and 1"))
+	}
+	for _, route := range cfg.Server.AccessLog.Routes {
+		if route.Path == "" {
+			errs = append(errs, fmt.Errorf("server.access_log.routes: path is required"))
+		}
+		if route.SampleRate < 0 || route.SampleRate > 1 {
+			errs = append(errs, fmt.Errorf("server.access_log.routes: sample_rate of %s must be between 0 and 1", route.Path))
+		}
+	}
+	if cfg.Server.Timeouts.Default < 0 {
+		errs = append(errs, fmt.Errorf("server.timeouts.default cannot be negative"))
+	}
+	for _, route := range cfg.Server.Timeouts.Routes {
+		if route.Path == "" {
+			errs = append(errs, fmt.Errorf("server.timeouts.routes: path is required"))
+		}
+		if route.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("server.timeouts.routes: timeout of %s cannot be negative", route.Path))
+		}
+	}
+	if shedding := cfg.Server.LoadShedding; shedding.Enabled {
+		if shedding.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("server.load_shedding.interval must be positive"))
+		}
+		if shedding.Latency < 0 {
+			errs = append(errs, fmt.Errorf("server.load_shedding.latency cannot be negative"))
+		}
+		if shedding.PoolSaturation < 0 || shedding.PoolSaturation > 1 {
+			errs = append(errs, fmt.Errorf("server.load_shedding.pool_saturation must be between 0 and 1"))
+		}
+		if shedding.Latency == 0 && shedding.PoolSaturation == 0 {
+			errs = append(errs, fmt.Errorf("server.load_shedding needs a latency or pool_saturation threshold"))
+		}
+		if shedding.RetryAfter < time.Second {
+			errs = append(errs, fmt.Errorf("server.load_shedding.retry_after must be at least 1s"))
+		}
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	if cfg.Metrics.MaxLabelValues < 1 {
+		errs = append(errs, fmt.Errorf("metrics.max_label_values must be at least 1"))
+	}
+	if cfg.StatsD.Address != "" {
+		if _, _, err := net.SplitHostPort(cfg.StatsD.Address); err != nil {
+			errs = append(errs, fmt.Errorf("statsd.address must be host:port: %w", err))
+		}
+	}
+
+	keyNames := make(map[string]bool)
+	for _, key := range cfg.Server.Keys() {
+		if key.Name == "" || key.Key == "" {
+			errs = append(errs, fmt.Errorf("server.api_keys entries require name and key"))
+		} else if keyNames[key.Name] {
+			errs = append(errs, fmt.Errorf("server.api_keys name %q is used more than once (%q is server.api_key)", key.Name, DefaultAPIKeyName))
+		}
+		keyNames[key.Name] = true
+		if key.DailyQuota < 0 || key.MonthlyQuota < 0 {
+			errs = append(errs, fmt.Errorf("quotas of api key %q cannot be negative", key.Name))
+		}
+		if err := validateWarningThresholds(key.WarningThresholds); err != nil {
+			errs = append(errs, fmt.Errorf("api key %q: %w", key.Name, err))
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(APIScopes, scope) {
+				errs = append(errs, fmt.Errorf("api key %q: unknown scope %q, expected one of %s", key.Name, scope, strings.Join(APIScopes, ", ")))
+			}
+		}
+	}
+	for _, quota := range cfg.Quotas.Tenants {
+		if quota.Tenant == "" {
+			errs = append(errs, fmt.Errorf("quotas.tenants entries require a tenant"))
+		}
+		if quota.DailyQuota < 0 || quota.MonthlyQuota < 0 {
+			errs = append(errs, fmt.Errorf("quotas of tenant %q cannot be negative", quota.Tenant))
+		}
+		if err := validateWarningThresholds(quota.WarningThresholds); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %q: %w", quota.Tenant, err))
+		}
+	}
+	if err := validateWarningThresholds(cfg.Quotas.WarningThresholds); err != nil {
+		errs = append(errs, fmt.Errorf("quotas: %w", err))
+	}
+
+	ruleNames := make(map[string]bool)
+	for _, rule := range cfg.ContentPolicy.Rules {
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("content_policy.rules entries require a name"))
+		} else if ruleNames[rule.Name] {
+			errs = append(errs, fmt.Errorf("content_policy.rules name %q is used more than once", rule.Name))
+		}
+		ruleNames[rule.Name] = true
+
+		switch rule.Type {
+		case ContentRuleBannedTerms, ContentRuleOptOutText:
+			if len(rule.Terms) == 0 {
+				errs = append(errs, fmt.Errorf("content_policy rule %q requires terms", rule.Name))
+			}
+		case ContentRuleURLAllowlist:
+		default:
+			errs = append(errs, fmt.Errorf("content_policy rule %q: type must be %s, %s or %s, got %q", rule.Name,
+				ContentRuleBannedTerms, ContentRuleURLAllowlist, ContentRuleOptOutText, rule.Type))
+		}
+		switch rule.Action {
+		case ContentActionReject, ContentActionQuarantine, ContentActionWarn:
+		default:
+			errs = append(errs, fmt.Errorf("content_policy rule %q: action must be %s, %s or %s, got %q", rule.Name,
+				ContentActionReject, ContentActionQuarantine, ContentActionWarn, rule.Action))
+		}
+	}
+
+	if cfg.Callbacks.Tolerance <= 0 {
+		errs = append(errs, fmt.Errorf("callbacks.tolerance must be positive"))
+	}
+	callbackProviders := make(map[string]bool)
+	for _, provider := range cfg.Callbacks.Providers {
+		if provider.Name == "" || provider.Secret == "" {
+			errs = append(errs, fmt.Errorf("callbacks.providers entries require name and secret"))
+		} else if callbackProviders[provider.Name] {
+			errs = append(errs, fmt.Errorf("callbacks.providers name %q is used more than once", provider.Name))
+		}
+		callbackProviders[provider.Name] = true
+		if provider.Method != CallbackHMAC && provider.Method != CallbackToken {
+			errs = append(errs, fmt.Errorf("callbacks provider %q: method must be %s or %s, got %q", provider.Name,
+				CallbackHMAC, CallbackToken, provider.Method))
+		}
+	}
+
+	if cfg.DeliveryReports.Enabled {
+		if cfg.DeliveryReports.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("delivery_reports.timeout must be positive"))
+		}
+		if cfg.DeliveryReports.CheckInterval <= 0 {
+			errs = append(errs, fmt.Errorf("delivery_reports.check_interval must be positive"))
+		}
+	}
+
+	providerNames := map[string]bool{WebhookProvider: true, SandboxProvider: true}
+	for _, provider := range cfg.Routing.Providers {
+		if provider.Name == "" {
+			errs = append(errs, fmt.Errorf("routing.providers entries require a name"))
+		} else if providerNames[provider.Name] {
+			errs = append(errs, fmt.Errorf("routing.providers name %q is reserved or used more than once", provider.Name))
+		}
+		providerNames[provider.Name] = true
+
+		if u, err := url.Parse(provider.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("routing provider %q: %q is not a valid http(s) URL", provider.Name, provider.URL))
+		}
+	}
+	prefixes := make(map[string]bool)
+	for _, route := range cfg.Routing.Routes {
+		if !routePrefixPattern.MatchString(route.Prefix) {
+			errs = append(errs, fmt.Errorf("routing.routes prefix %q must be + followed by up to 15 digits", route.Prefix))
+		} else if prefixes[route.Prefix] {
+			errs = append(errs, fmt.Errorf("routing.routes prefix %q is used more than once", route.Prefix))
+		}
+		prefixes[route.Prefix] = true
+	}
+	for _, route := range cfg.Routing.allRoutes() {
+		if route.Provider != "" && !providerNames[route.Provider] {
+			errs = append(errs, fmt.Errorf("routing route %q: unknown provider %q", route.Prefix, route.Provider))
+		}
+		if route.Provider != "" && len(route.Providers) > 0 {
+			errs = append(errs, fmt.Errorf("routing route %q: provider and providers cannot both be set", route.Prefix))
+		}
+		weighted := make(map[string]bool)
+		for _, provider := range route.Providers {
+			if !providerNames[provider.Name] {
+				errs = append(errs, fmt.Errorf("routing route %q: unknown provider %q", route.Prefix, provider.Name))
+			} else if weighted[provider.Name] {
+				errs = append(errs, fmt.Errorf("routing route %q: provider %q is listed more than once", route.Prefix, provider.Name))
+			}
+			weighted[provider.Name] = true
+			if provider.Weight <= 0 {
+				errs = append(errs, fmt.Errorf("routing route %q: weight of provider %q must be positive", route.Prefix, provider.Name))
+			}
+		}
+		if route.RateLimit < 0 {
+			errs = append(errs, fmt.Errorf("routing route %q: rate_limit cannot be negative", route.Prefix))
+		}
+		if route.UnitPrice < 0 {
+			errs = append(errs, fmt.Errorf("routing route %q: unit_price cannot be negative", route.Prefix))
+		}
+	}
+	if cfg.Routing.Default.Prefix != "" {
+		errs = append(errs, fmt.Errorf("routing.default cannot have a prefix"))
+	}
+	profiles := make(map[string]bool)
+	for _, profile := range cfg.Throttling.Profiles {
+		if profile.Name == "" {
+			errs = append(errs, fmt.Errorf("throttling.profiles entries require a name"))
+		} else if profiles[profile.Name] {
+			errs = append(errs, fmt.Errorf("throttling.profiles name %q is used more than once", profile.Name))
+		}
+		profiles[profile.Name] = true
+		if profile.RateLimit <= 0 {
+			errs = append(errs, fmt.Errorf("throttle profile %q: rate_limit must be positive", profile.Name))
+		}
+	}
+	for _, assignment := range cfg.Throttling.Assignments {
+		if (assignment.Campaign == "") == (assignment.Tenant == "") {
+			errs = append(errs, fmt.Errorf("throttling.assignments entries require either a campaign or a tenant"))
+		}
+		if !profiles[assignment.Profile] {
+			errs = append(errs, fmt.Errorf("throttling.assignments: unknown profile %q", assignment.Profile))
+		}
+	}
+	if cfg.Throttling.DefaultCampaignProfile != "" && !profiles[cfg.Throttling.DefaultCampaignProfile] {
+		errs = append(errs, fmt.Errorf("throttling.default_campaign_profile: unknown profile %q", cfg.Throttling.DefaultCampaignProfile))
+	}
+
+	pipelines := make(map[string]bool)
+	for _, pipeline := range cfg.Transforms.Pipelines {
+		if pipeline.Name == "" {
+			errs = append(errs, fmt.Errorf("transforms.pipelines entries require a name"))
+		} else if pipelines[pipeline.Name] {
+			errs = append(errs, fmt.Errorf("transforms.pipelines name %q is used more than once", pipeline.Name))
+		}
+		pipelines[pipeline.Name] = true
+		for _, step := range pipeline.Steps {
+			switch step.Type {
+			case TransformTrim, TransformStripEmoji:
+			case TransformShortenLinks:
+				if cfg.LinkTracking.BaseURL == "" {
+					errs = append(errs, fmt.Errorf("transform pipeline %q: shorten_links requires link_tracking.base_url", pipeline.Name))
+				}
+			case TransformFooter:
+				if strings.TrimSpace(step.Text) == "" {
+					errs = append(errs, fmt.Errorf("transform pipeline %q: footer requires a text", pipeline.Name))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("transform pipeline %q: unknown step type %q, expected %s, %s, %s or %s", pipeline.Name,
+					step.Type, TransformTrim, TransformStripEmoji, TransformShortenLinks, TransformFooter))
+			}
+		}
+	}
+	for _, assignment := range cfg.Transforms.Assignments {
+		if (assignment.Campaign == "") == (assignment.Tenant == "") {
+			errs = append(errs, fmt.Errorf("transforms.assignments entries require either a campaign or a tenant"))
+		}
+		if !pipelines[assignment.Pipeline] {
+			errs = append(errs, fmt.Errorf("transforms.assignments: unknown pipeline %q", assignment.Pipeline))
+		}
+	}
+	if cfg.Transforms.DefaultPipeline != "" && !pipelines[cfg.Transforms.DefaultPipeline] {
+		errs = append(errs, fmt.Errorf("transforms.default_pipeline: unknown pipeline %q", cfg.Transforms.DefaultPipeline))
+	}
+
+	if cfg.Routing.Sandbox.ReportDelay < 0 || cfg.Routing.Sandbox.DelayedReportDelay < 0 {
+		errs = append(errs, fmt.Errorf("routing.sandbox delays cannot be negative"))
+	}
+	if cfg.Routing.Health.Window <= 0 || cfg.Routing.Health.LatencyTarget <= 0 {
+		errs = append(errs, fmt.Errorf("routing.health window and latency_target must be positive"))
+	}
+	if cfg.Routing.Health.MinSamples < 0 {
+		errs = append(errs, fmt.Errorf("routing.health.min_samples cannot be negative"))
+	}
+	if cfg.Routing.Health.MinScore <= 0 || cfg.Routing.Health.MinScore > 1 {
+		errs = append(errs, fmt.Errorf("routing.health.min_score must be greater than 0 and at most 1"))
+	}
+
+	if cfg.Replay.MaxWindow <= 0 {
+		errs = append(errs, fmt.Errorf("replay.max_window must be positive"))
+	}
+	if cfg.Replay.MaxMessages < 1 {
+		errs = append(errs, fmt.Errorf("replay.max_messages must be at least 1"))
+	}
+
+	if cfg.Ingestion.MaxMessages < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.max_messages must be at least 1"))
+	}
+	if cfg.Ingestion.MaxBodySize < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.max_body_size must be positive"))
+	}
+	if cfg.Ingestion.Workers < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.workers must be at least 1"))
+	}
+	if cfg.Ingestion.MaxQueued < 0 {
+		errs = append(errs, fmt.Errorf("ingestion.max_queued cannot be negative"))
+	}
+	if cfg.Ingestion.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.batch_size must be at least 1"))
+	}
+	if cfg.Jobs.Workers < 1 {
+		errs = append(errs, fmt.Errorf("jobs.workers must be at least 1"))
+	}
+	if cfg.Jobs.MaxQueued < 0 {
+		errs = append(errs, fmt.Errorf("jobs.max_queued cannot be negative"))
+	}
+	if cfg.Jobs.ResultTTL <= 0 {
+		errs = append(errs, fmt.Errorf("jobs.result_ttl must be positive"))
+	}
+	if cfg.Jobs.Export.MaxMessages < 1 {
+		errs = append(errs, fmt.Errorf("jobs.export.max_messages must be at least 1"))
+	}
+	if cfg.Jobs.Export.PageSize < 1 {
+		errs = append(errs, fmt.Errorf("jobs.export.page_size must be at least 1"))
+	}
+	if cfg.Jobs.Export.RowsPerSecond < 0 {
+		errs = append(errs, fmt.Errorf("jobs.export.rows_per_second cannot be negative"))
+	}
+
+	if cfg.Stats.CountCacheInterval < 0 {
+		errs = append(errs, fmt.Errorf("stats.count_cache_interval cannot be negative"))
+	}
+
+	jobs := cfg.Maintenance.jobs()
+	for _, name := range slices.Sorted(maps.Keys(jobs)) {
+		job := jobs[name]
+		if !job.Enabled {
+			continue
+		}
+		if _, err := cron.Parse(job.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance.%s.schedule: %w", name, err))
+		}
+		if (name == "stuck_reaper" || name == "retention" || name == "archive") && job.OlderThan <= 0 {
+			errs = append(errs, fmt.Errorf("maintenance.%s.older_than must be positive", name))
+		}
+	}
+	if cfg.Maintenance.Archive.Enabled && cfg.Maintenance.Archive.Dir == "" && cfg.Archive.S3.Bucket == "" {
+		errs = append(errs, fmt.Errorf("maintenance.archive.dir or archive.s3.bucket is required when the archive job is enabled"))
+	}
+	if s3 := cfg.Archive.S3; s3.Bucket != "" {
+		if s3.Region == "" {
+			errs = append(errs, fmt.Errorf("archive.s3.region is required"))
+		}
+		if s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			errs = append(errs, fmt.Errorf("archive.s3.access_key_id and secret_access_key are required"))
+		}
+		if s3.Endpoint != "" {
+			if u, err := url.Parse(s3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("archive.s3.endpoint is not a valid http(s) URL"))
+			}
+		}
+		if cfg.Archive.EncryptionKey == "" {
+			errs = append(errs, fmt.Errorf("archive.encryption_key is required with archive.s3, archives are stored encrypted"))
+		}
+		if cfg.Archive.PartSize < 1 {
+			errs = append(errs, fmt.Errorf("archive.part_size must be at least 1"))
+		}
+	}
+	if cfg.Archive.EncryptionKey != "" {
+		if _, err := secrets.NewBox(cfg.Archive.EncryptionKey); err != nil {
+			errs = append(errs, fmt.Errorf("archive.encryption_key: %w", err))
+		}
+	}
+	if requeue := cfg.Maintenance.FailureRequeue; requeue.Enabled {
+		if requeue.Backoff <= 0 || requeue.MaxBackoff < requeue.Backoff {
+			errs = append(errs, fmt.Errorf("maintenance.failure_requeue.backoff must be positive and at most max_backoff"))
+		}
+		if requeue.MaxRequeues < 1 {
+			errs = append(errs, fmt.Errorf("maintenance.failure_requeue.max_requeues must be at least 1"))
+		}
+	}
+	switch cfg.Region.Role {
+	case RegionActive, RegionPassive:
+	default:
+		errs = append(errs, fmt.Errorf("region.role: unknown role %q, expected %s or %s", cfg.Region.Role, RegionActive, RegionPassive))
+	}
+	if len(cfg.Region.Name) > 64 {
+		errs = append(errs, fmt.Errorf("region.name cannot exceed 64 characters"))
+	}
+	if cfg.Campaigns.Recheck <= 0 {
+		errs = append(errs, fmt.Errorf("campaigns.recheck must be positive"))
+	}
+	if cfg.Maintenance.StatsRefresh.Enabled && cfg.Stats.CountCacheInterval <= 0 {
+		errs = append(errs, fmt.Errorf("maintenance.stats_refresh requires stats.count_cache_interval, the age cached counts are used up to"))
+	}
+
+	if cfg.LinkTracking.Enabled {
+		if cfg.LinkTracking.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("link_tracking.base_url is required when link tracking is enabled"))
+		} else if u, err := url.Parse(cfg.LinkTracking.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("link_tracking.base_url: %q is not a valid http(s) URL", cfg.LinkTracking.BaseURL))
+		}
+	}
+
+	switch cfg.Queue.Backend {
+	case "postgres":
+	case "redis":
+		if cfg.Queue.Redis.Address == "" || cfg.Queue.Redis.Stream == "" || cfg.Queue.Redis.Group == "" || cfg.Queue.Redis.Consumer == "" {
+			errs = append(errs, fmt.Errorf("queue.redis requires address, stream, group and consumer"))
+		}
+		if cfg.Queue.Redis.ClaimTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("queue.redis.claim_timeout must be positive"))
+		}
+		if cfg.Queue.Redis.RefillSize < 1 {
+			errs = append(errs, fmt.Errorf("queue.redis.refill_size must be at least 1"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("queue.backend must be postgres or redis, got %q", cfg.Queue.Backend))
+	}
+	if cfg.Queue.PriorityAging.Interval < 0 {
+		errs = append(errs, fmt.Errorf("queue.priority_aging.interval cannot be negative"))
+	}
+	switch cfg.Queue.PriorityAging.Curve {
+	case "linear", "quadratic", "":
+	default:
+		errs = append(errs, fmt.Errorf("queue.priority_aging.curve must be linear or quadratic, got %q", cfg.Queue.PriorityAging.Curve))
+	}
+	if cfg.Queue.PriorityAging.MaxBoost < 0 {
+		errs = append(errs, fmt.Errorf("queue.priority_aging.max_boost cannot be negative"))
+	}
+
+	if cfg.Kafka.Enabled {
+		if len(cfg.Kafka.Brokers) == 0 {
+			errs = append(errs, fmt.Errorf("kafka.brokers is required when kafka is enabled"))
+		}
+		if cfg.Kafka.Topic == "" {
+			errs = append(errs, fmt.Errorf("kafka.topic is required when kafka is enabled"))
+		}
+		if cfg.Kafka.GroupID == "" {
+			errs = append(errs, fmt.Errorf("kafka.group_id is required when kafka is enabled"))
+		}
+	}
+
+	if cfg.AMQP.Enabled {
+		if cfg.AMQP.URL == "" {
+			errs = append(errs, fmt.Errorf("amqp.url is required when amqp is enabled"))
+		}
+		if cfg.AMQP.Queue == "" {
+			errs = append(errs, fmt.Errorf("amqp.queue is required when amqp is enabled"))
+		}
+		if cfg.AMQP.Prefetch < 1 {
+			errs = append(errs, fmt.Errorf("amqp.prefetch must be at least 1"))
+		}
+		if cfg.AMQP.MaxReconnects < 0 {
+			errs = append(errs, fmt.Errorf("amqp.max_reconnects cannot be negative"))
+		}
+	}
+
+	if cfg.StatusNotices.Enabled {
+		if cfg.StatusNotices.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("status_notices.timeout must be positive"))
+		}
+		if cfg.StatusNotices.MaxAttempts < 1 {
+			errs = append(errs, fmt.Errorf("status_notices.max_attempts must be at least 1"))
+		}
+		if cfg.StatusNotices.Workers < 1 {
+			errs = append(errs, fmt.Errorf("status_notices.workers must be at least 1"))
+		}
+		if cfg.StatusNotices.DrainTimeout < 0 {
+			errs = append(errs, fmt.Errorf("status_notices.drain_timeout cannot be negative"))
+		}
+	}
+	for _, key := range cfg.Server.APIKeys {
+		if key.CallbackURL == "" {
+			continue
+		}
+		if u, err := url.Parse(key.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("server.api_keys: callback_url of %s is not an http or https URL", key.Name))
+		}
+	}
+	if (cfg.NATS.Events.Enabled || cfg.NATS.Consumer.Enabled) && cfg.NATS.URL == "" {
+		errs = append(errs, fmt.Errorf("nats.url is required when nats events or consumer are enabled"))
+	}
+	if cfg.NATS.Events.Enabled && (cfg.NATS.Events.Stream == "" || cfg.NATS.Events.Subject == "") {
+		errs = append(errs, fmt.Errorf("nats.events requires stream and subject"))
+	}
+	if cfg.NATS.Consumer.Enabled {
+		if cfg.NATS.Consumer.Stream == "" || cfg.NATS.Consumer.Subject == "" || cfg.NATS.Consumer.Durable == "" {
+			errs = append(errs, fmt.Errorf("nats.consumer requires stream, subject and durable"))
+		}
+		if cfg.NATS.Consumer.MaxAckPending < 1 {
+			errs = append(errs, fmt.Errorf("nats.consumer.max_ack_pending must be at least 1"))
+		}
+	}
+
+	if cfg.Alerts.Enabled {
+		if cfg.Alerts.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("alerts.interval must be positive"))
+		}
+		if cfg.Alerts.FailureRate < 0 || cfg.Alerts.FailureRate > 1 {
+			errs = append(errs, fmt.Errorf("alerts.failure_rate must be between 0 and 1"))
+		}
+		if cfg.Alerts.SlackWebhookURL == "" && cfg.Alerts.HTTPURL == "" && cfg.Alerts.Email.SMTPAddress == "" {
+			errs = append(errs, fmt.Errorf("alerts are enabled but no slack_webhook_url, http_url or email is configured"))
+		}
+		if cfg.Alerts.Email.SMTPAddress != "" && (cfg.Alerts.Email.From == "" || len(cfg.Alerts.Email.To) == 0) {
+			errs = append(errs, fmt.Errorf("alerts.email requires from and to"))
+		}
 	}
 
+	if cfg.Reports.Enabled {
+		if _, err := time.Parse("15:04", cfg.Reports.At); err != nil {
+			errs = append(errs, fmt.Errorf("reports.at must be a time of day like 08:00"))
+		}
+		if _, err := time.LoadLocation(cfg.Reports.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("reports.timezone is not a valid timezone: %v", err))
+		}
+		if cfg.Reports.TopErrors < 0 {
+			errs = append(errs, fmt.Errorf("reports.top_errors must not be negative"))
+		}
+		if cfg.Reports.SlackWebhookURL == "" && cfg.Reports.HTTPURL == "" && cfg.Reports.Email.SMTPAddress == "" {
+			errs = append(errs, fmt.Errorf("reports are enabled but no slack_webhook_url, http_url or email is configured"))
+		}
+		if cfg.Reports.Email.SMTPAddress != "" && (cfg.Reports.Email.From == "" || len(cfg.Reports.Email.To) == 0) {
+			errs = append(errs, fmt.Errorf("reports.email requires from and to"))
+		}
+	}
+
+	if cfg.Webhook.URL == "" {
+		if cfg.Messaging.Enabled {
+			errs = append(errs, fmt.Errorf("webhook.url is required when messaging is enabled"))
+		}
+	} else if u, err := url.Parse(cfg.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("webhook.url: %q is not a valid http(s) URL", cfg.Webhook.URL))
+	}
+	if cfg.Webhook.MaxResponseSize < 0 {
+		errs = append(errs, fmt.Errorf("webhook.max_response_size cannot be negative"))
+	}
+	if cfg.Webhook.MaxStoredLength < 0 {
+		errs = append(errs, fmt.Errorf("webhook.max_stored_length cannot be negative"))
+	}
+	switch cfg.Webhook.Payloads.Storage {
+	case "", PayloadStorageNone, PayloadStorageTable:
+	default:
+		errs = append(errs, fmt.Errorf("webhook.payloads.storage must be none or table, got %q", cfg.Webhook.Payloads.Storage))
+	}
+	if cfg.Webhook.Payloads.Threshold < 0 {
+		errs = append(errs, fmt.Errorf("webhook.payloads.threshold cannot be negative"))
+	}
+	switch cfg.Webhook.Payloads.Compression {
+	case "", PayloadCompressionNone, PayloadCompressionGzip, PayloadCompressionZstd:
+	default:
+		errs = append(errs, fmt.Errorf("webhook.payloads.compression must be none, gzip or zstd, got %q", cfg.Webhook.Payloads.Compression))
+	}
+	if cfg.Webhook.Payloads.CompressAbove < 0 {
+		errs = append(errs, fmt.Errorf("webhook.payloads.compress_above cannot be negative"))
+	}
+	if prewarm := cfg.Webhook.Connections.Prewarm; prewarm < 0 || prewarm > MaxPrewarmedConnections {
+		errs = append(errs, fmt.Errorf("webhook.connections.prewarm must be between 0 and %d", MaxPrewarmedConnections))
+	}
+	if cfg.Webhook.Connections.DNSCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("webhook.connections.dns_cache_ttl cannot be negative"))
+	}
+	if cfg.Webhook.LocalAddress != "" {
+		if cfg.Webhook.Interface != "" {
+			errs = append(errs, fmt.Errorf("webhook.local_address and webhook.interface cannot be used together"))
+		}
+		if net.ParseIP(cfg.Webhook.LocalAddress) == nil {
+			errs = append(errs, fmt.Errorf("webhook.local_address: %q is not an IP address", cfg.Webhook.LocalAddress))
+		}
+	}
+	for _, ip := range cfg.Webhook.EgressIPs {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("webhook.egress_ips: %q is not an IP address", ip))
+		}
+	}
+	if cfg.Webhook.EncryptionKey != "" {
+		if _, err := secrets.NewBox(cfg.Webhook.EncryptionKey); err != nil {
+			errs = append(errs, fmt.Errorf("webhook.encryption_key: %w", err))
+		}
+	}
+
+	return errs
+}
+
+// validateWarningThresholds checks that quota warning thresholds are shares of a quota
+func validateWarningThresholds(thresholds []float64) error {
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("warning_thresholds must be between 0 and 1 (exclusive), got %v", threshold)
+		}
+	}
 	return nil
 }
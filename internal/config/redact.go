@@ -0,0 +1,102 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// RedactedValue replaces the secrets of the redacted configuration
+const RedactedValue = "[redacted]"
+
+// secretFields are the mapstructure names of the settings holding secrets, their values are never reported
+var secretFields = map[string]bool{
+	"api_key":           true,
+	"key":               true,
+	"password":          true,
+	"secret":            true,
+	"encryption_key":    true,
+	"access_key_id":     true,
+	"secret_access_key": true,
+	"slack_webhook_url": true,
+}
+
+// dsnPassword matches the password of a key=value DSN, e.g. "host=db password=secret"
+var dsnPassword = regexp.MustCompile(`(?i)(password=)('[^']*'|\S*)`)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Redacted returns the configuration keyed by the mapstructure names of its settings, as in the config file,
+// with the secrets replaced by RedactedValue and the passwords of URLs and DSNs masked. Durations are strings.
+func (cfg *Cfg) Redacted() map[string]any {
+	redacted, _ := redact(reflect.ValueOf(*cfg)).(map[string]any)
+	return redacted
+}
+
+func redact(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name := field.Tag.Get("mapstructure")
+			if !field.IsExported() || name == "-" || name == "" {
+				continue
+			}
+			value := v.Field(i)
+			if secretFields[name] {
+				fields[name] = redactSecret(value)
+				continue
+			}
+			fields[name] = redact(value)
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any{}
+		}
+		values := make([]any, v.Len())
+		for i := range v.Len() {
+			values[i] = redact(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		values := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			values[iter.Key().String()] = redact(iter.Value())
+		}
+		return values
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	case reflect.String:
+		return redactURL(v.String())
+	default:
+		return v.Interface()
+	}
+}
+
+// redactSecret hides a secret setting, an empty one is reported as empty so a missing secret still shows
+func redactSecret(v reflect.Value) any {
+	if v.IsZero() {
+		return ""
+	}
+	return RedactedValue
+}
+
+// redactURL masks the password of a URL or key=value DSN, other values are returned as they are
+func redactURL(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return dsnPassword.ReplaceAllString(value, "${1}"+RedactedValue)
+}
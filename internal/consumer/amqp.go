@@ -0,0 +1,187 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP consumes message create events from a RabbitMQ queue. Deliveries are acked after the event
+// was enqueued, malformed events are rejected without requeue so the queue's dead letter exchange
+// receives them. A lost connection or channel is reestablished with backoff up to amqp.max_reconnects times in
+// a row before the consumer fails.
+type AMQP struct {
+	cfg       config.AMQP
+	processor *Processor
+	// backoff is the wait before the first reconnect, doubled up to maxRetryDelay for every further one
+	backoff time.Duration
+}
+
+func NewAMQP(cfg config.AMQP, processor *Processor) *AMQP {
+	return &AMQP{cfg: cfg, processor: processor, backoff: minRetryDelay}
+}
+
+func (a *AMQP) Name() string { return "amqp" }
+
+func (a *AMQP) Run(ctx context.Context) error {
+	return a.reconnect(ctx, a.session)
+}
+
+// reconnect runs session until ctx is cancelled, starting it again with backoff when it fails. The failures
+// are counted in a row, a session that delivered resets the count, so only a broker that stays unreachable
+// fails the consumer.
+func (a *AMQP) reconnect(ctx context.Context, session func(ctx context.Context) (bool, error)) error {
+	failures := 0
+	delay := a.backoff
+	for {
+		delivered, err := session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if delivered {
+			failures, delay = 0, a.backoff
+		}
+		failures++
+		if failures > a.cfg.MaxReconnects {
+			return fmt.Errorf("giving up after %d reconnects: %w", a.cfg.MaxReconnects, err)
+		}
+		config.Log().WithError(err).Warnf("AMQP consumer lost its connection, reconnecting in %s (%d/%d)", delay, failures, a.cfg.MaxReconnects)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// session connects, consumes the queue until the connection or channel is closed and reports whether any
+// delivery was received
+func (a *AMQP) session(ctx context.Context) (bool, error) {
+	conn, err := amqp.Dial(a.cfg.URL)
+	if err != nil {
+		return false, fmt.Errorf("connecting to amqp: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("opening amqp channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := a.setup(ch); err != nil {
+		return false, err
+	}
+
+	// a closed connection or channel closes the deliveries as well, closed tells why. Both close their notify
+	// channel, so they get one each.
+	closed := make(chan *amqp.Error, 1)
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		select {
+		case reason := <-connClosed:
+			closed <- reason
+		case reason := <-chClosed:
+			closed <- reason
+		}
+	}()
+
+	deliveries, err := ch.ConsumeWithContext(ctx, a.cfg.Queue, a.cfg.ConsumerTag, false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("consuming amqp queue: %w", err)
+	}
+	return a.consume(ctx, deliveries, closed)
+}
+
+// setup applies the prefetch, declares the queue and binds it to the exchange when one is configured
+func (a *AMQP) setup(ch *amqp.Channel) error {
+	if err := ch.Qos(a.cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("setting amqp prefetch: %w", err)
+	}
+
+	var args amqp.Table
+	if a.cfg.DeadLetterExchange != "" {
+		args = amqp.Table{"x-dead-letter-exchange": a.cfg.DeadLetterExchange}
+	}
+	if _, err := ch.QueueDeclare(a.cfg.Queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("declaring amqp queue: %w", err)
+	}
+
+	if a.cfg.Exchange != "" {
+		if err := ch.QueueBind(a.cfg.Queue, a.cfg.RoutingKey, a.cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("binding amqp queue: %w", err)
+		}
+	}
+	return nil
+}
+
+// consume handles deliveries until ctx is cancelled or they stop, closed tells why they stopped. It reports
+// whether any delivery was received.
+func (a *AMQP) consume(ctx context.Context, deliveries <-chan amqp.Delivery, closed <-chan *amqp.Error) (bool, error) {
+	delivered := false
+	for {
+		select {
+		case <-ctx.Done():
+			return delivered, nil
+		case reason := <-closed:
+			return delivered, closedError(reason)
+		case delivery, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return delivered, nil
+				}
+				select {
+				case reason := <-closed:
+					return delivered, closedError(reason)
+				default:
+					return delivered, errors.New("amqp delivery channel closed")
+				}
+			}
+			delivered = true
+			if err := a.handleDelivery(ctx, delivery); err != nil {
+				return delivered, err
+			}
+		}
+	}
+}
+
+func closedError(reason *amqp.Error) error {
+	if reason == nil {
+		return errors.New("amqp connection closed")
+	}
+	return fmt.Errorf("amqp connection closed: %w", reason)
+}
+
+func (a *AMQP) handleDelivery(ctx context.Context, delivery amqp.Delivery) error {
+	deadLettered := false
+	err := handle(ctx, "amqp", a.processor, delivery.Body, func(error) error {
+		deadLettered = true
+		// a failed nack means the channel is gone, the broker redelivers the event then
+		if err := delivery.Nack(false, false); err != nil {
+			config.Log().Errorf("Failed to reject amqp delivery: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// cancelled while retrying, hand the event back to the broker for another consumer
+		if err := delivery.Nack(false, true); err != nil {
+			config.Log().Errorf("Failed to requeue amqp delivery: %v", err)
+		}
+		return nil
+	}
+	if deadLettered {
+		return nil
+	}
+
+	if err := delivery.Ack(false); err != nil {
+		return fmt.Errorf("acking amqp delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAcknowledger records how every delivery was settled by its tag
+type fakeAcknowledger struct {
+	acked    []uint64
+	rejected []uint64
+	requeued []uint64
+}
+
+func (f *fakeAcknowledger) Ack(tag uint64, _ bool) error {
+	f.acked = append(f.acked, tag)
+	return nil
+}
+
+func (f *fakeAcknowledger) Nack(tag uint64, _ bool, requeue bool) error {
+	if requeue {
+		f.requeued = append(f.requeued, tag)
+	} else {
+		f.rejected = append(f.rejected, tag)
+	}
+	return nil
+}
+
+func (f *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return f.Nack(tag, false, requeue)
+}
+
+func TestAMQP_Consume(t *testing.T) {
+	q := &recordingQueue{}
+	ack := &fakeAcknowledger{}
+	consumer := NewAMQP(config.AMQP{}, NewProcessor(q))
+
+	deliveries := make(chan amqp.Delivery, 2)
+	deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(`{"to": "+905551111111", "content": "Hello"}`)}
+	deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 2, Body: []byte(`{"to": "+905551111111"}`)}
+	close(deliveries)
+
+	delivered, err := consumer.consume(context.Background(), deliveries, nil)
+
+	assert.True(t, delivered)
+	assert.EqualError(t, err, "amqp delivery channel closed")
+	assert.Equal(t, []uint64{1}, ack.acked)
+	assert.Equal(t, []uint64{2}, ack.rejected)
+	assert.Empty(t, ack.requeued)
+	require.Len(t, q.messages, 1)
+	assert.Equal(t, "Hello", q.messages[0].Content)
+}
+
+func TestAMQP_Consume_RequeuesOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// the queue keeps failing, so the event is still being retried when the consumer stops
+	q := &recordingQueue{failures: 100}
+	ack := &fakeAcknowledger{}
+	consumer := NewAMQP(config.AMQP{}, NewProcessor(q))
+
+	deliveries := make(chan amqp.Delivery, 1)
+	deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(`{"to": "+905551111111", "content": "Hello"}`)}
+	cancel()
+
+	require.NoError(t, consumer.handleDelivery(ctx, <-deliveries))
+
+	assert.Empty(t, ack.acked)
+	assert.Equal(t, []uint64{1}, ack.requeued)
+}
+
+func TestAMQP_Consume_Closed(t *testing.T) {
+	consumer := NewAMQP(config.AMQP{}, NewProcessor(&recordingQueue{}))
+
+	deliveries := make(chan amqp.Delivery)
+	close(deliveries)
+	closed := make(chan *amqp.Error, 1)
+	closed <- &amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED - broker forced connection closure"}
+
+	delivered, err := consumer.consume(context.Background(), deliveries, closed)
+
+	assert.False(t, delivered)
+	assert.ErrorContains(t, err, "CONNECTION_FORCED", "the reason of the closed connection is returned")
+}
+
+func TestAMQP_Reconnect(t *testing.T) {
+	lost := errors.New("amqp connection closed")
+
+	t.Run("gives up after max_reconnects failures in a row", func(t *testing.T) {
+		consumer := NewAMQP(config.AMQP{MaxReconnects: 2}, NewProcessor(&recordingQueue{}))
+		consumer.backoff = time.Millisecond
+
+		sessions := 0
+		err := consumer.reconnect(context.Background(), func(context.Context) (bool, error) {
+			sessions++
+			return false, lost
+		})
+
+		assert.ErrorIs(t, err, lost)
+		assert.ErrorContains(t, err, "giving up after 2 reconnects")
+		assert.Equal(t, 3, sessions, "the first session and two reconnects")
+	})
+
+	t.Run("a session that delivered resets the failures", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		consumer := NewAMQP(config.AMQP{MaxReconnects: 1}, NewProcessor(&recordingQueue{}))
+		consumer.backoff = time.Millisecond
+
+		sessions := 0
+		err := consumer.reconnect(ctx, func(context.Context) (bool, error) {
+			sessions++
+			if sessions == 5 {
+				cancel()
+				return false, nil
+			}
+			// every session delivered before it lost the connection
+			return true, lost
+		})
+
+		assert.NoError(t, err, "a cancelled consumer stops without an error")
+		assert.Equal(t, 5, sessions)
+	})
+}
@@ -0,0 +1,140 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/policy"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// ErrMalformedEvent marks events that can never be enqueued, they are dead-lettered instead of retried
+var ErrMalformedEvent = errors.New("malformed event")
+
+const (
+	// minRetryDelay and maxRetryDelay bound the backoff between attempts of a failing event
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// Consumer reads message create events from an external system until ctx is cancelled
+type Consumer interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Processor turns a message create event into a queued message.
+// Events are JSON encoded dto.CreateMessageRequest objects.
+type Processor struct {
+	queue queue.Queue
+}
+
+func NewProcessor(q queue.Queue) *Processor {
+	return &Processor{queue: q}
+}
+
+// Process validates and enqueues a single event, validation errors and content policy rejections wrap ErrMalformedEvent
+func (p *Processor) Process(ctx context.Context, payload []byte) error {
+	var req dto.CreateMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedEvent, err)
+	}
+
+	message, err := ingest.NewMessage(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedEvent, err)
+	}
+
+	if err := p.queue.Enqueue(ctx, message); err != nil {
+		if errors.Is(err, policy.ErrRejected) {
+			return fmt.Errorf("%w: %s", ErrMalformedEvent, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Run runs every consumer until ctx is cancelled or one of them fails, the others are stopped then
+func Run(ctx context.Context, consumers ...Consumer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, c := range consumers {
+		wg.Add(1)
+		go func(c Consumer) {
+			defer wg.Done()
+
+			config.Log().Infof("Starting %s consumer", c.Name())
+			if err := c.Run(ctx); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("%s consumer: %w", c.Name(), err)
+					cancel()
+				})
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// retry calls fn until it succeeds or ctx is cancelled, waiting with exponential backoff in between
+func retry(ctx context.Context, what string, fn func() error) error {
+	delay := minRetryDelay
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		config.Log().Warnf("Failed to %s, retrying in %s: %v", what, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// handle processes an event, retrying transient failures and dead-lettering malformed events
+// with deadLetter. It only fails when ctx is cancelled.
+func handle(ctx context.Context, source string, processor *Processor, payload []byte, deadLetter func(reason error) error) error {
+	var malformed error
+	err := retry(ctx, "enqueue "+source+" event", func() error {
+		err := processor.Process(ctx, payload)
+		if errors.Is(err, ErrMalformedEvent) {
+			malformed = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if malformed == nil {
+		telemetry.ObserveIngest(source, telemetry.IngestEnqueued)
+		return nil
+	}
+
+	config.Log().WithField("source", source).Warnf("Dead-lettering event: %v", malformed)
+	if err := retry(ctx, "dead-letter "+source+" event", func() error { return deadLetter(malformed) }); err != nil {
+		return err
+	}
+	telemetry.ObserveIngest(source, telemetry.IngestDeadLettered)
+	return nil
+}
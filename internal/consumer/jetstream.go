@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// jetStreamMsg is the part of jetstream.Msg used to settle a message
+type jetStreamMsg interface {
+	Data() []byte
+	Subject() string
+	Ack() error
+	Nak() error
+	Term() error
+}
+
+// JetStream consumes message create events from a JetStream stream through a durable pull consumer.
+// Messages are acked after the event was enqueued, malformed events are published to the dead letter
+// subject when one is configured and terminated so they are never redelivered.
+type JetStream struct {
+	url       string
+	cfg       config.NATSConsumer
+	processor *Processor
+	// publish sends the dead-lettered events, set once connected
+	publish func(msg *nats.Msg) error
+}
+
+func NewJetStream(cfg config.NATS, processor *Processor) *JetStream {
+	return &JetStream{url: cfg.URL, cfg: cfg.Consumer, processor: processor}
+}
+
+func (j *JetStream) Name() string { return "nats" }
+
+func (j *JetStream) Run(ctx context.Context) error {
+	conn, err := nats.Connect(j.url, nats.Name("sendpulse-consumer"), nats.MaxReconnects(-1))
+	if err != nil {
+		return fmt.Errorf("connecting to nats: %w", err)
+	}
+	defer conn.Close()
+	j.publish = conn.PublishMsg
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return fmt.Errorf("creating jetstream context: %w", err)
+	}
+	stream, err := js.Stream(ctx, j.cfg.Stream)
+	if err != nil {
+		return fmt.Errorf("looking up jetstream stream %s: %w", j.cfg.Stream, err)
+	}
+	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       j.cfg.Durable,
+		FilterSubject: j.cfg.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxAckPending: j.cfg.MaxAckPending,
+	})
+	if err != nil {
+		return fmt.Errorf("creating jetstream consumer %s: %w", j.cfg.Durable, err)
+	}
+
+	messages, err := cons.Messages()
+	if err != nil {
+		return fmt.Errorf("consuming jetstream stream: %w", err)
+	}
+	// Next blocks without a context, stopping the iterator unblocks it
+	stop := context.AfterFunc(ctx, messages.Stop)
+	defer stop()
+	defer messages.Stop()
+
+	for {
+		msg, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) && ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading jetstream message: %w", err)
+		}
+		if err := j.handleMsg(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (j *JetStream) handleMsg(ctx context.Context, msg jetStreamMsg) error {
+	deadLettered := false
+	err := handle(ctx, "nats", j.processor, msg.Data(), func(reason error) error {
+		if j.cfg.DeadLetterSubject != "" {
+			if err := j.deadLetter(msg, reason); err != nil {
+				return err
+			}
+		}
+		deadLettered = true
+		// a failed term only means the event is redelivered and dead-lettered again
+		if err := msg.Term(); err != nil {
+			config.Log().Errorf("Failed to terminate jetstream message: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// cancelled while retrying, hand the event back to the server for another consumer
+		if err := msg.Nak(); err != nil {
+			config.Log().Errorf("Failed to nak jetstream message: %v", err)
+		}
+		return nil
+	}
+	if deadLettered {
+		return nil
+	}
+
+	if err := msg.Ack(); err != nil {
+		return fmt.Errorf("acking jetstream message: %w", err)
+	}
+	return nil
+}
+
+// deadLetter publishes a malformed event to the dead letter subject with the rejection reason
+func (j *JetStream) deadLetter(msg jetStreamMsg, reason error) error {
+	dlq := nats.NewMsg(j.cfg.DeadLetterSubject)
+	dlq.Data = msg.Data()
+	dlq.Header.Set("sendpulse-error", reason.Error())
+	dlq.Header.Set("sendpulse-source-subject", msg.Subject())
+	return j.publish(dlq)
+}
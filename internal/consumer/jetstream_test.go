@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJetStreamMsg records how it was settled
+type fakeJetStreamMsg struct {
+	data    string
+	settled string
+}
+
+func (f *fakeJetStreamMsg) Data() []byte    { return []byte(f.data) }
+func (f *fakeJetStreamMsg) Subject() string { return "sendpulse.messages.create" }
+func (f *fakeJetStreamMsg) Ack() error      { f.settled = "ack"; return nil }
+func (f *fakeJetStreamMsg) Nak() error      { f.settled = "nak"; return nil }
+func (f *fakeJetStreamMsg) Term() error     { f.settled = "term"; return nil }
+
+func TestJetStream_HandleMsg(t *testing.T) {
+	q := &recordingQueue{}
+	var deadLettered []*nats.Msg
+	consumer := NewJetStream(config.NATS{Consumer: config.NATSConsumer{DeadLetterSubject: "sendpulse.messages.dlq"}}, NewProcessor(q))
+	consumer.publish = func(msg *nats.Msg) error {
+		deadLettered = append(deadLettered, msg)
+		return nil
+	}
+
+	valid := &fakeJetStreamMsg{data: `{"to": "+905551111111", "content": "Hello"}`}
+	require.NoError(t, consumer.handleMsg(context.Background(), valid))
+	assert.Equal(t, "ack", valid.settled)
+	require.Len(t, q.messages, 1)
+	assert.Equal(t, "Hello", q.messages[0].Content)
+
+	malformed := &fakeJetStreamMsg{data: `{"to": "+905551111111"}`}
+	require.NoError(t, consumer.handleMsg(context.Background(), malformed))
+	assert.Equal(t, "term", malformed.settled)
+	require.Len(t, deadLettered, 1)
+	assert.Equal(t, "sendpulse.messages.dlq", deadLettered[0].Subject)
+	assert.Equal(t, malformed.data, string(deadLettered[0].Data))
+	assert.Equal(t, "sendpulse.messages.create", deadLettered[0].Header.Get("sendpulse-source-subject"))
+	assert.Contains(t, deadLettered[0].Header.Get("sendpulse-error"), "malformed event")
+}
+
+func TestJetStream_HandleMsg_NaksOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	consumer := NewJetStream(config.NATS{}, NewProcessor(&recordingQueue{failures: 100}))
+
+	msg := &fakeJetStreamMsg{data: `{"to": "+905551111111", "content": "Hello"}`}
+	require.NoError(t, consumer.handleMsg(ctx, msg))
+	assert.Equal(t, "nak", msg.settled)
+}
@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaReader is the part of kafka.Reader the consumer uses
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaWriter is the part of kafka.Writer the consumer uses
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Kafka consumes message create events from a topic as part of a consumer group. Offsets are
+// committed only after an event was enqueued or dead-lettered, so delivery is at least once.
+type Kafka struct {
+	processor *Processor
+	reader    kafkaReader
+	dlq       kafkaWriter
+}
+
+func NewKafka(cfg config.Kafka, processor *Processor) *Kafka {
+	k := &Kafka{
+		processor: processor,
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.Brokers,
+			Topic:   cfg.Topic,
+			GroupID: cfg.GroupID,
+		}),
+	}
+	if cfg.DLQTopic != "" {
+		k.dlq = &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.DLQTopic,
+			RequiredAcks: kafka.RequireAll,
+		}
+	}
+	return k
+}
+
+func (k *Kafka) Name() string { return "kafka" }
+
+func (k *Kafka) Run(ctx context.Context) error {
+	defer k.close()
+
+	for {
+		msg, err := k.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := handle(ctx, "kafka", k.processor, msg.Value, func(reason error) error {
+			return k.deadLetter(ctx, msg, reason)
+		}); err != nil {
+			// cancelled while retrying, the event is consumed again after a restart
+			return nil
+		}
+
+		if err := k.reader.CommitMessages(ctx, msg); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// deadLetter publishes a malformed event to the DLQ topic with the reason and its origin in the headers,
+// without a DLQ topic the event is only logged and skipped
+func (k *Kafka) deadLetter(ctx context.Context, msg kafka.Message, reason error) error {
+	if k.dlq == nil {
+		return nil
+	}
+
+	return k.dlq.WriteMessages(ctx, kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: append(msg.Headers,
+			kafka.Header{Key: "sendpulse-error", Value: []byte(reason.Error())},
+			kafka.Header{Key: "sendpulse-source-topic", Value: []byte(msg.Topic)},
+			kafka.Header{Key: "sendpulse-source-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: "sendpulse-source-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		),
+	})
+}
+
+func (k *Kafka) close() {
+	if err := k.reader.Close(); err != nil {
+		config.Log().Errorf("Kafka reader close error: %v", err)
+	}
+	if k.dlq != nil {
+		if err := k.dlq.Close(); err != nil {
+			config.Log().Errorf("Kafka DLQ writer close error: %v", err)
+		}
+	}
+}
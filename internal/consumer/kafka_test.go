@@ -0,0 +1,134 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingQueue stores enqueued messages, failing the first failures calls
+type recordingQueue struct {
+	queue.Queue
+	mu       sync.Mutex
+	failures int
+	messages []*db.Message
+}
+
+func (r *recordingQueue) Enqueue(_ context.Context, messages ...*db.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("database unavailable")
+	}
+	r.messages = append(r.messages, messages...)
+	return nil
+}
+
+// fakeReader returns its messages in order and then blocks until ctx is cancelled
+type fakeReader struct {
+	messages  []kafka.Message
+	committed []int64
+	cancel    context.CancelFunc
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(f.messages) == 0 {
+		f.cancel()
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+
+func (f *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		f.committed = append(f.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (f *fakeReader) Close() error { return nil }
+
+type fakeWriter struct {
+	messages []kafka.Message
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func (f *fakeWriter) Close() error { return nil }
+
+func TestProcessor_Process(t *testing.T) {
+	q := &recordingQueue{}
+	processor := NewProcessor(q)
+
+	tests := []struct {
+		name      string
+		payload   string
+		malformed bool
+	}{
+		{name: "valid event", payload: `{"to": "+905551111111", "content": "Hello", "campaign": "spring-sale"}`},
+		{name: "invalid json", payload: `{"to": `, malformed: true},
+		{name: "invalid recipient", payload: `{"to": "0555", "content": "Hello"}`, malformed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := processor.Process(context.Background(), []byte(tt.payload))
+			if tt.malformed {
+				assert.ErrorIs(t, err, ErrMalformedEvent)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	require.Len(t, q.messages, 1)
+	assert.Equal(t, "spring-sale", q.messages[0].Campaign)
+}
+
+func TestKafka_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first enqueue fails, the event must be retried before its offset is committed
+	q := &recordingQueue{failures: 1}
+	reader := &fakeReader{
+		cancel: cancel,
+		messages: []kafka.Message{
+			{Topic: "messages", Offset: 1, Value: []byte(`{"to": "+905551111111", "content": "Hello"}`)},
+			{Topic: "messages", Offset: 2, Value: []byte(`not json`)},
+			{Topic: "messages", Offset: 3, Value: []byte(`{"to": "+905552222222", "content": "World"}`)},
+		},
+	}
+	dlq := &fakeWriter{}
+	consumer := &Kafka{processor: NewProcessor(q), reader: reader, dlq: dlq}
+
+	require.NoError(t, consumer.Run(ctx))
+
+	assert.Equal(t, []int64{1, 2, 3}, reader.committed)
+	require.Len(t, q.messages, 2)
+	assert.Equal(t, "Hello", q.messages[0].Content)
+	assert.Equal(t, "World", q.messages[1].Content)
+
+	require.Len(t, dlq.messages, 1)
+	assert.Equal(t, "not json", string(dlq.messages[0].Value))
+	headers := make(map[string]string)
+	for _, header := range dlq.messages[0].Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Contains(t, headers["sendpulse-error"], "malformed event")
+	assert.Equal(t, "2", headers["sendpulse-source-offset"])
+}
@@ -0,0 +1,163 @@
+// Package cron parses the schedules of the maintenance jobs: five field cron expressions and a few shorthands
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the run times of a job
+type Schedule interface {
+	// Next returns the first run time after t
+	Next(t time.Time) time.Time
+}
+
+// every runs a job at a fixed interval
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// fields is a cron expression, a bit per allowed value of every field. Times are matched in UTC.
+type fields struct {
+	minute, hour, dom, month, dow uint64
+	// anyDom and anyDow are set when the day of month or the day of week is *, the other one alone restricts the day
+	anyDom, anyDow bool
+}
+
+// shorthands are the @ names of common expressions
+var shorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Parse parses spec: minute, hour, day of month, month and day of week in UTC, each *, a value, a range a-b,
+// a step */n or a-b/n, or a list of them. @every <duration>, @hourly, @daily, @weekly and @monthly are accepted too.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a positive duration", spec)
+		}
+		return every(duration), nil
+	}
+	if expression, ok := shorthands[spec]; ok {
+		spec = expression
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day-of-month month day-of-week)", spec)
+	}
+	var f fields
+	var err error
+	if f.minute, err = parseField(parts[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %w", spec, err)
+	}
+	if f.hour, err = parseField(parts[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %w", spec, err)
+	}
+	if f.dom, err = parseField(parts[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of month: %w", spec, err)
+	}
+	if f.month, err = parseField(parts[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %w", spec, err)
+	}
+	if f.dow, err = parseField(parts[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of week: %w", spec, err)
+	}
+	// 7 is Sunday too
+	if f.dow&(1<<7) != 0 {
+		f.dow |= 1
+	}
+	f.anyDom, f.anyDow = parts[2] == "*", parts[4] == "*"
+	return &f, nil
+}
+
+// parseField returns the bits of the values field allows between min and max
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", from)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first minute after t matching the expression, the zero time when none does within 5 years
+func (f *fields) Next(t time.Time) time.Time {
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	end := next.AddDate(5, 0, 0)
+	for next.Before(end) {
+		if f.month&(1<<uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !f.matchDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if f.hour&(1<<uint(next.Hour())) == 0 {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if f.minute&(1<<uint(next.Minute())) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+// matchDay follows cron: with both day fields restricted a day matching either of them runs
+func (f *fields) matchDay(t time.Time) bool {
+	dom := f.dom&(1<<uint(t.Day())) != 0
+	dow := f.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case f.anyDom && f.anyDow:
+		return true
+	case f.anyDom:
+		return dow
+	case f.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,51 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	at := time.Date(2026, 10, 16, 10, 7, 30, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 16, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 16, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// with both days restricted either of them matches
+		{"0 0 20 * 6", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"5,10 10 * * *", time.Date(2026, 10, 16, 10, 10, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", at.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(at))
+		})
+	}
+
+	// February 30 never comes
+	schedule, err := Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(at).IsZero())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every", "@every -1m", "@yearly"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
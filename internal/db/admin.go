@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// MaintenanceDatabase is connected to while creating or dropping the target database
+const MaintenanceDatabase = "postgres"
+
+var ErrNoDatabaseName = errors.New("DSN does not contain a database name")
+
+// DatabaseName returns the name of the database a DSN points to
+func DatabaseName(dsn string) string {
+	return pgdriver.NewConnector(pgdriver.WithDSN(dsn)).Config().Database
+}
+
+// CreateDatabase creates the database of the DSN
+// Returns false without error if the database already exists
+func CreateDatabase(ctx context.Context, dsn string) (bool, error) {
+	name, maintenance, err := connectMaintenance(dsn)
+	if err != nil {
+		return false, err
+	}
+	defer maintenance.Close()
+
+	exists, err := databaseExists(ctx, maintenance, name)
+	if err != nil || exists {
+		return false, err
+	}
+
+	if _, err := maintenance.ExecContext(ctx, "CREATE DATABASE ?", bun.Ident(name)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DropDatabase drops the database of the DSN, force terminates its open connections first
+// Returns false without error if the database does not exist
+func DropDatabase(ctx context.Context, dsn string, force bool) (bool, error) {
+	name, maintenance, err := connectMaintenance(dsn)
+	if err != nil {
+		return false, err
+	}
+	defer maintenance.Close()
+
+	exists, err := databaseExists(ctx, maintenance, name)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	query := "DROP DATABASE ?"
+	if force {
+		query += " WITH (FORCE)"
+	}
+	if _, err := maintenance.ExecContext(ctx, query, bun.Ident(name)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// connectMaintenance connects to the maintenance database of the server the DSN points to
+func connectMaintenance(dsn string) (string, *bun.DB, error) {
+	name := DatabaseName(dsn)
+	if name == "" {
+		return "", nil, ErrNoDatabaseName
+	}
+	if name == MaintenanceDatabase {
+		return "", nil, fmt.Errorf("refusing to manage the %s maintenance database", MaintenanceDatabase)
+	}
+
+	sqldb := sql.OpenDB(pgdriver.NewConnector(
+		pgdriver.WithDSN(dsn),
+		pgdriver.WithDatabase(MaintenanceDatabase),
+	))
+	if err := sqldb.Ping(); err != nil {
+		sqldb.Close()
+		return "", nil, fmt.Errorf("connecting to %s database: %w", MaintenanceDatabase, err)
+	}
+
+	return name, bun.NewDB(sqldb, pgdialect.New()), nil
+}
+
+func databaseExists(ctx context.Context, db bun.IDB, name string) (bool, error) {
+	var exists bool
+	err := db.NewRaw("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = ?)", name).Scan(ctx, &exists)
+	return exists, err
+}
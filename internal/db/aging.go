@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Priority aging curves
+const (
+	// AgingLinear gains a priority level per interval waited
+	AgingLinear = "linear"
+	// AgingQuadratic gains slowly at first and faster the longer a message waits, (waited / interval)²
+	AgingQuadratic = "quadratic"
+)
+
+// PriorityAging raises the effective priority of due messages the longer they wait, so low priority messages are
+// claimed eventually under constant high priority load. A message waits from its scheduled time, or from its
+// creation when it has none. The zero value claims by priority and age only.
+type PriorityAging struct {
+	// Interval is the wait per priority level gained, 0 disables the aging
+	Interval time.Duration
+	// Curve is AgingLinear or AgingQuadratic, empty is linear
+	Curve string
+	// MaxBoost is the most levels a message gains, 0 is unlimited
+	MaxBoost int
+}
+
+// Boost returns the priority levels a message gains after waiting for wait
+func (a PriorityAging) Boost(wait time.Duration) float64 {
+	if a.Interval <= 0 || wait <= 0 {
+		return 0
+	}
+	boost := float64(wait) / float64(a.Interval)
+	if a.Curve == AgingQuadratic {
+		boost *= boost
+	}
+	if a.MaxBoost > 0 {
+		boost = min(boost, float64(a.MaxBoost))
+	}
+	return boost
+}
+
+// claimOrder returns the order the claims at now take the due messages in: the highest effective priority first,
+// then the oldest. It computes the same boost as Boost.
+func (a PriorityAging) claimOrder(db bun.IDB, now time.Time) bun.Safe {
+	if a.Interval <= 0 {
+		return "priority DESC, created_at ASC"
+	}
+
+	at := string(db.Dialect().AppendTime(nil, now))
+	wait := fmt.Sprintf("EXTRACT(EPOCH FROM (CAST(%s AS TIMESTAMPTZ) - COALESCE(scheduled_at, created_at)))", at)
+	least := "LEAST"
+	if db.Dialect().Name() == dialect.SQLite {
+		wait = fmt.Sprintf("((julianday(%s) - julianday(COALESCE(scheduled_at, created_at))) * 86400)", at)
+		least = "MIN"
+	}
+
+	boost := fmt.Sprintf("(%s / %g)", wait, a.Interval.Seconds())
+	if a.Curve == AgingQuadratic {
+		boost = fmt.Sprintf("(%s * %s)", boost, boost)
+	}
+	if a.MaxBoost > 0 {
+		boost = fmt.Sprintf("%s(%s, %d)", least, boost, a.MaxBoost)
+	}
+	return bun.Safe(fmt.Sprintf("priority + %s DESC, created_at ASC", boost))
+}
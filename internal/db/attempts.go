@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// SendAttempt is a webhook request of a message send, a send is retried up to messaging.max_retries times
+type SendAttempt struct {
+	bun.BaseModel `bun:"table:send_attempts"`
+
+	ID        int64  `bun:"id,pk,autoincrement" json:"id"`
+	MessageID int64  `bun:"message_id,notnull" json:"message_id"`
+	Provider  string `bun:"provider,notnull" json:"provider"`
+	// StatusCode is the status of the provider response, 0 when none was received
+	StatusCode int       `bun:"status_code,nullzero" json:"status_code,omitempty"`
+	Error      string    `bun:"error,nullzero" json:"error,omitempty"`
+	StartedAt  time.Time `bun:"started_at,notnull" json:"started_at"`
+	FinishedAt time.Time `bun:"finished_at,notnull" json:"finished_at"`
+}
+
+// CreateSendAttempts stores the attempts of a send
+func CreateSendAttempts(ctx context.Context, db bun.IDB, attempts []*SendAttempt) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().Model(&attempts).Exec(ctx)
+	return err
+}
+
+// GetSendAttempts returns the attempts of every send of a message, oldest first
+func GetSendAttempts(ctx context.Context, db bun.IDB, messageID int64) ([]*SendAttempt, error) {
+	var attempts []*SendAttempt
+	err := db.NewSelect().
+		Model(&attempts).
+		Where("message_id = ?", messageID).
+		Order("started_at ASC", "id ASC").
+		Scan(ctx)
+	return attempts, err
+}
+
+// DeleteSendAttempts deletes the attempts of the messages with the given IDs
+func DeleteSendAttempts(ctx context.Context, db bun.IDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.NewDelete().
+		Model((*SendAttempt)(nil)).
+		Where("message_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
+
+// ProviderSLA aggregates the attempts sent to a provider in a window. Latencies are in milliseconds and zero
+// without attempts, percentiles are nearest-rank.
+type ProviderSLA struct {
+	Attempts     int64   `bun:"attempts"`
+	Succeeded    int64   `bun:"succeeded"`
+	AvgLatencyMs float64 `bun:"avg_latency_ms"`
+	P50LatencyMs float64
+	P95LatencyMs float64
+	P99LatencyMs float64
+	// DownMinutes are the UTC minutes with attempts of which none succeeded, oldest first
+	DownMinutes []DownMinute
+}
+
+// DownMinute is a UTC minute in which every attempt sent to a provider failed
+type DownMinute struct {
+	Time     time.Time
+	Attempts int64
+}
+
+// slaPercentiles are the latency percentiles of ProviderSLA
+var slaPercentiles = []float64{0.5, 0.95, 0.99}
+
+// GetProviderSLA aggregates the attempts sent to provider that started in [from, to). An attempt succeeded
+// when it ended without an error.
+func GetProviderSLA(ctx context.Context, db bun.IDB, provider string, from, to time.Time) (*ProviderSLA, error) {
+	latency := bun.Safe("CAST(EXTRACT(EPOCH FROM finished_at - started_at) * 1000 AS DOUBLE PRECISION)")
+	if db.Dialect().Name() == dialect.SQLite {
+		latency = "(julianday(finished_at) - julianday(started_at)) * 86400000"
+	}
+	attempts := func() *bun.SelectQuery {
+		return db.NewSelect().
+			Model((*SendAttempt)(nil)).
+			Where("provider = ?", provider).
+			Where("started_at >= ?", from).
+			Where("started_at < ?", to)
+	}
+
+	sla := new(ProviderSLA)
+	err := attempts().
+		ColumnExpr("COUNT(*) AS attempts").
+		ColumnExpr("COUNT(*) FILTER (WHERE error IS NULL) AS succeeded").
+		ColumnExpr("COALESCE(AVG(?), 0.0) AS avg_latency_ms", latency).
+		Scan(ctx, sla)
+	if err != nil || sla.Attempts == 0 {
+		return sla, err
+	}
+
+	percentiles := []*float64{&sla.P50LatencyMs, &sla.P95LatencyMs, &sla.P99LatencyMs}
+	for i, percentile := range slaPercentiles {
+		rank := int(math.Ceil(percentile * float64(sla.Attempts)))
+		err := attempts().
+			ColumnExpr("? AS latency", latency).
+			OrderExpr("latency ASC").
+			Offset(rank-1).
+			Limit(1).
+			Scan(ctx, percentiles[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := timeseriesKey(db, BucketMinute, "started_at")
+	var minutes []timeseriesCount
+	err = attempts().
+		ColumnExpr("? AS bucket", key).
+		ColumnExpr("COUNT(*) AS count").
+		GroupExpr("?", key).
+		Having("COUNT(*) FILTER (WHERE error IS NULL) = 0").
+		OrderExpr("bucket ASC").
+		Scan(ctx, &minutes)
+	if err != nil {
+		return nil, err
+	}
+	for _, minute := range minutes {
+		t, err := time.Parse(time.RFC3339, minute.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		sla.DownMinutes = append(sla.DownMinutes, DownMinute{Time: t, Attempts: int64(minute.Count)})
+	}
+	return sla, nil
+}
+
+// ProviderAttempted reports whether any attempt was ever sent to provider
+func ProviderAttempted(ctx context.Context, db bun.IDB, provider string) (bool, error) {
+	return db.NewSelect().
+		Model((*SendAttempt)(nil)).
+		Where("provider = ?", provider).
+		Exists(ctx)
+}
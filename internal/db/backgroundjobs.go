@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Background job kinds, ingestion jobs are kept in the ingestion_jobs table
+const (
+	JobKindExport    = "export"
+	JobKindIngestion = "ingestion"
+)
+
+// Job is a long-running operation run in the background by the instance that accepted it
+type Job struct {
+	bun.BaseModel `bun:"table:jobs"`
+
+	// ID is a ULID, returned when the job is accepted
+	ID   string `bun:"id,pk" json:"id"`
+	Kind string `bun:"kind,notnull" json:"kind"`
+	// Status is queued, running, succeeded or failed
+	Status string `bun:"status,notnull" json:"status"`
+	// Holder is the instance running the job, APIKey the key it was submitted with
+	Holder string `bun:"holder,notnull" json:"holder"`
+	APIKey string `bun:"api_key,nullzero" json:"api_key,omitempty"`
+	// Params are the JSON parameters of the job by its kind, e.g. the filters and format of an export
+	Params *string `bun:"params,type:jsonb,nullzero" json:"params,omitempty"`
+	// Total is the units of work of the job, e.g. the messages to export, Done the ones done so far
+	Total int `bun:"total,notnull,default:0" json:"total"`
+	Done  int `bun:"done,notnull,default:0" json:"done"`
+	// Result summarizes what a succeeded job did, Error is why a failed one stopped
+	Result string `bun:"result,nullzero" json:"result,omitempty"`
+	Error  string `bun:"error,nullzero" json:"error,omitempty"`
+	// ResultType is the content type of the output of a succeeded job in job_results, empty without one
+	ResultType string     `bun:"result_type,nullzero" json:"result_type,omitempty"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	StartedAt  *time.Time `bun:"started_at,nullzero" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+	// ExpiresAt is when the job and its result are deleted, set once it finished
+	ExpiresAt *time.Time `bun:"expires_at,nullzero" json:"expires_at,omitempty"`
+}
+
+// Progress returns the share of the job done in percent, 100 once it succeeded
+func (j *Job) Progress() int {
+	switch {
+	case j.Status == JobStatusSucceeded:
+		return 100
+	case j.Total <= 0:
+		return 0
+	}
+	return min(j.Done*100/j.Total, 99)
+}
+
+// JobResult is the downloadable output of a succeeded job, e.g. the file of an export
+type JobResult struct {
+	bun.BaseModel `bun:"table:job_results"`
+
+	JobID       string    `bun:"job_id,pk" json:"job_id"`
+	ContentType string    `bun:"content_type,notnull" json:"content_type"`
+	Body        []byte    `bun:"body,notnull" json:"-"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// CreateJob stores a new queued job with a new ULID
+func CreateJob(ctx context.Context, db bun.IDB, job *Job) error {
+	job.CreatedAt = time.Now()
+	job.ID = newULID(job.CreatedAt)
+	job.Status = JobStatusQueued
+	_, err := db.NewInsert().Model(job).Exec(ctx)
+	return err
+}
+
+// UpdateJob stores the status, progress, result, error, result type and times of job
+func UpdateJob(ctx context.Context, db bun.IDB, job *Job) error {
+	_, err := db.NewUpdate().
+		Model(job).
+		Column("status", "total", "done", "result", "error", "result_type", "started_at", "finished_at", "expires_at").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+// GetJob returns the job with id.
+// Returns sql.ErrNoRows if there is none.
+func GetJob(ctx context.Context, db bun.IDB, id string) (*Job, error) {
+	job := new(Job)
+	err := db.NewSelect().
+		Model(job).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SaveJobResult stores the result of a job
+func SaveJobResult(ctx context.Context, db bun.IDB, result *JobResult) error {
+	result.CreatedAt = time.Now()
+	_, err := db.NewInsert().Model(result).Exec(ctx)
+	return err
+}
+
+// GetJobResult returns the result of the job with id.
+// Returns sql.ErrNoRows if there is none.
+func GetJobResult(ctx context.Context, db bun.IDB, id string) (*JobResult, error) {
+	result := new(JobResult)
+	err := db.NewSelect().
+		Model(result).
+		Where("job_id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteExpiredJobs deletes the jobs expired before now with their results and returns how many it deleted
+func DeleteExpiredJobs(ctx context.Context, db bun.IDB, now time.Time) (int, error) {
+	expired := db.NewSelect().
+		Model((*Job)(nil)).
+		Column("id").
+		Where("expires_at <= ?", now)
+	if _, err := db.NewDelete().
+		Model((*JobResult)(nil)).
+		Where("job_id IN (?)", expired).
+		Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	res, err := db.NewDelete().
+		Model((*Job)(nil)).
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
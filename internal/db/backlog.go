@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// BacklogGroup are the pending messages sharing the tenant, campaign and priority, the start of the recipient and
+// the minute they are scheduled for
+type BacklogGroup struct {
+	Tenant   string
+	Campaign string
+	Priority int
+	// Prefix is the start of the recipients, long enough to pick their route
+	Prefix string
+	// DueAt is the minute the messages are scheduled for, nil when they are due
+	DueAt *time.Time
+	Count int
+	// OldestAt is the creation time of the oldest message, messages are claimed oldest first
+	OldestAt time.Time
+}
+
+type backlogRow struct {
+	Tenant   string    `bun:"tenant"`
+	Campaign string    `bun:"campaign"`
+	Priority int       `bun:"priority"`
+	Prefix   string    `bun:"prefix"`
+	DueAt    string    `bun:"due_at"`
+	Count    int       `bun:"count"`
+	OldestAt time.Time `bun:"oldest_at"`
+}
+
+// GetBacklog returns the pending messages grouped by tenant, campaign, priority, the first prefixLen characters of
+// the recipient and the minute the ones scheduled after now are due
+func GetBacklog(ctx context.Context, db bun.IDB, prefixLen int, now time.Time) ([]BacklogGroup, error) {
+	var rows []backlogRow
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		Column("tenant", "campaign", "priority").
+		ColumnExpr(`substr("to", 1, ?) AS prefix`, prefixLen).
+		ColumnExpr("CASE WHEN scheduled_at > ? THEN ? END AS due_at", now, timeseriesKey(db, BucketMinute, "scheduled_at")).
+		ColumnExpr("COUNT(*) AS count").
+		ColumnExpr("MIN(created_at) AS oldest_at").
+		Where("status = ?", MessageStatusPending).
+		GroupExpr("tenant, campaign, priority, prefix, due_at").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]BacklogGroup, len(rows))
+	for i, row := range rows {
+		groups[i] = BacklogGroup{
+			Tenant:   row.Tenant,
+			Campaign: row.Campaign,
+			Priority: row.Priority,
+			Prefix:   row.Prefix,
+			Count:    row.Count,
+			OldestAt: row.OldestAt,
+		}
+		if row.DueAt != "" {
+			due, err := time.Parse(time.RFC3339, row.DueAt)
+			if err != nil {
+				return nil, err
+			}
+			groups[i].DueAt = &due
+		}
+	}
+	return groups, nil
+}
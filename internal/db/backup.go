@@ -0,0 +1,366 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// A backup is a header line, a JSON line with the BackupHeader, one section per table and a trailer:
+//
+//	SENDPULSE-BACKUP 1
+//	{"schema_version":"20261016000013_add_message_metadata","created_at":"...","tables":["messages",...]}
+//	COPY messages (id, to, ...)
+//	<rows in the COPY text format>
+//	\.
+//	...
+//	END {"rows":{"messages":120,...},"sha256":"..."}
+//
+// The checksum covers everything before the trailer.
+const (
+	backupMagic = "SENDPULSE-BACKUP"
+	// BackupVersion is the version of the backup layout
+	BackupVersion = 1
+
+	copyEnd       = `\.`
+	backupTrailer = "END "
+)
+
+// BackupTables are the tables a backup contains, in the order they are restored. Every other table is
+// listed in skippedBackupTables.
+var BackupTables = []string{"messages", "suppressions", "links", "usage_counters", "erasures", "message_events", "webhook_overrides",
+	"message_payloads", "send_attempts", "recipient_pauses", "campaigns", "campaign_recipients"}
+
+// skippedBackupTables are not backed up: leases, controls, maintenance job runs, background jobs and ingestion
+// jobs belong to the running instances, message counts are a cache refreshed from the messages
+var skippedBackupTables = []string{"leases", "controls", "maintenance_jobs", "jobs", "job_results", "ingestion_jobs", "message_counts"}
+
+// serialTables get their IDs from a sequence, it continues after the restored IDs
+var serialTables = []string{"messages", "erasures", "message_events", "webhook_overrides", "send_attempts"}
+
+var (
+	ErrInvalidBackup         = errors.New("invalid backup")
+	ErrBackupSchemaMismatch  = errors.New("backup schema version does not match the database")
+	ErrRestoreTablesNotEmpty = errors.New("tables to restore are not empty")
+)
+
+// BackupHeader describes a backup
+type BackupHeader struct {
+	// SchemaVersion is the last migration applied to the backed up database
+	SchemaVersion string    `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Tables        []string  `json:"tables"`
+}
+
+type backupSummary struct {
+	Rows   map[string]int64 `json:"rows"`
+	SHA256 string           `json:"sha256"`
+}
+
+// RestoreOptions configure Restore
+type RestoreOptions struct {
+	// SchemaVersion is the last migration applied to the database restored into, it must match the backup
+	SchemaVersion string
+	// Replace truncates the tables before restoring, otherwise they must be empty
+	Replace bool
+}
+
+// Backup writes the BackupTables to w with COPY from a single snapshot and returns the rows written per table.
+// The database must be Postgres.
+func Backup(ctx context.Context, db *bun.DB, w io.Writer, schemaVersion string) (map[string]int64, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+
+	hash := sha256.New()
+	out := io.MultiWriter(w, hash)
+
+	header, _ := json.Marshal(BackupHeader{SchemaVersion: schemaVersion, CreatedAt: time.Now().UTC(), Tables: BackupTables})
+	if _, err := fmt.Fprintf(out, "%s %d\n%s\n", backupMagic, BackupVersion, header); err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]int64, len(BackupTables))
+	for _, table := range BackupTables {
+		columns, err := tableColumns(ctx, conn, table)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := fmt.Fprintf(out, "COPY %s (%s)\n", table, strings.Join(columns, ", ")); err != nil {
+			return nil, err
+		}
+		result, err := pgdriver.CopyTo(ctx, conn, out, db.Formatter().FormatQuery("COPY ? (?) TO STDOUT", bun.Ident(table), identList(columns)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+		if rows[table], err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Fprintln(out, copyEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, _ := json.Marshal(backupSummary{Rows: rows, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	if _, err := fmt.Fprintf(w, "%s%s\n", backupTrailer, summary); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Restore loads a backup written by Backup in a single transaction, nothing is restored when the backup is
+// invalid or does not match the schema version. It returns the header and the rows restored per table.
+func Restore(ctx context.Context, db *bun.DB, r io.Reader, opts RestoreOptions) (*BackupHeader, map[string]int64, error) {
+	reader := &backupReader{r: bufio.NewReaderSize(r, 64*1024), hash: sha256.New()}
+
+	header, err := reader.header()
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.SchemaVersion != opts.SchemaVersion {
+		return header, nil, fmt.Errorf("%w: backup is at %q, the database at %q, migrate both to the same version",
+			ErrBackupSchemaMismatch, header.SchemaVersion, opts.SchemaVersion)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return header, nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return header, nil, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+		}
+	}()
+
+	if opts.Replace {
+		if _, err := conn.ExecContext(ctx, "TRUNCATE ?", identList(header.Tables)); err != nil {
+			return header, nil, err
+		}
+	} else {
+		for _, table := range header.Tables {
+			exists, err := conn.NewSelect().Table(table).Limit(1).Exists(ctx)
+			if err != nil {
+				return header, nil, err
+			}
+			if exists {
+				return header, nil, fmt.Errorf("%w: %s has rows, restore with replace to truncate them", ErrRestoreTablesNotEmpty, table)
+			}
+		}
+	}
+
+	rows := make(map[string]int64, len(header.Tables))
+	for _, table := range header.Tables {
+		columns, err := reader.section(table)
+		if err != nil {
+			return header, nil, err
+		}
+		known, err := tableColumns(ctx, conn, table)
+		if err != nil {
+			return header, nil, err
+		}
+		for _, column := range columns {
+			if !slices.Contains(known, column) {
+				return header, nil, fmt.Errorf("%w: %s has no column %s", ErrInvalidBackup, table, column)
+			}
+		}
+
+		result, err := pgdriver.CopyFrom(ctx, conn, reader, db.Formatter().FormatQuery("COPY ? (?) FROM STDIN", bun.Ident(table), identList(columns)))
+		if err != nil {
+			return header, nil, fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		if rows[table], err = result.RowsAffected(); err != nil {
+			return header, nil, err
+		}
+	}
+
+	if err := reader.verify(rows); err != nil {
+		return header, nil, err
+	}
+
+	for _, table := range serialTables {
+		if !slices.Contains(header.Tables, table) {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM ?",
+			table, bun.Ident(table)); err != nil {
+			return header, nil, err
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return header, nil, err
+	}
+	committed = true
+	return header, rows, nil
+}
+
+// tableColumns returns the columns of a table in their order
+func tableColumns(ctx context.Context, conn bun.Conn, table string) ([]string, error) {
+	var columns []string
+	err := conn.NewSelect().
+		Table("information_schema.columns").
+		Column("column_name").
+		Where("table_schema = current_schema()").
+		Where("table_name = ?", table).
+		Order("ordinal_position").
+		Scan(ctx, &columns)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist, run the migrations first", table)
+	}
+	return columns, nil
+}
+
+func identList(names []string) bun.Safe {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return bun.Safe(strings.Join(quoted, ", "))
+}
+
+// backupReader reads a backup line by line and hashes the lines. While a section is
+// open it is the io.Reader of the section's rows.
+type backupReader struct {
+	r    *bufio.Reader
+	hash hash.Hash
+	line []byte
+	open bool
+}
+
+func (b *backupReader) readLine() ([]byte, error) {
+	line, err := b.r.ReadBytes('\n')
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: unexpected end of file", ErrInvalidBackup)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.hash.Write(line)
+	return line, nil
+}
+
+func (b *backupReader) header() (*BackupHeader, error) {
+	line, err := b.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if want := fmt.Sprintf("%s %d\n", backupMagic, BackupVersion); string(line) != want {
+		if strings.HasPrefix(string(line), backupMagic+" ") {
+			return nil, fmt.Errorf("%w: unsupported backup version %s", ErrInvalidBackup, strings.TrimSpace(string(line)))
+		}
+		return nil, fmt.Errorf("%w: not a SendPulse backup", ErrInvalidBackup)
+	}
+
+	if line, err = b.readLine(); err != nil {
+		return nil, err
+	}
+	header := new(BackupHeader)
+	if err := json.Unmarshal(line, header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header: %v", ErrInvalidBackup, err)
+	}
+	if len(header.Tables) == 0 {
+		return nil, fmt.Errorf("%w: no tables", ErrInvalidBackup)
+	}
+	for _, table := range header.Tables {
+		if !slices.Contains(BackupTables, table) {
+			return nil, fmt.Errorf("%w: unknown table %s", ErrInvalidBackup, table)
+		}
+	}
+	return header, nil
+}
+
+// section opens the section of table and returns its columns
+func (b *backupReader) section(table string) ([]string, error) {
+	line, err := b.readLine()
+	if err != nil {
+		return nil, err
+	}
+	prefix := "COPY " + table + " ("
+	text := strings.TrimSuffix(string(line), "\n")
+	if !strings.HasPrefix(text, prefix) || !strings.HasSuffix(text, ")") {
+		return nil, fmt.Errorf("%w: expected the %s section", ErrInvalidBackup, table)
+	}
+
+	columns := strings.Split(strings.TrimSuffix(strings.TrimPrefix(text, prefix), ")"), ", ")
+	b.open = true
+	return columns, nil
+}
+
+// Read returns the rows of the open section, io.EOF at its end marker
+func (b *backupReader) Read(p []byte) (int, error) {
+	for len(b.line) == 0 {
+		if !b.open {
+			return 0, io.EOF
+		}
+		line, err := b.readLine()
+		if err != nil {
+			return 0, err
+		}
+		if string(line) == copyEnd+"\n" {
+			b.open = false
+			return 0, io.EOF
+		}
+		b.line = line
+	}
+
+	n := copy(p, b.line)
+	b.line = b.line[n:]
+	return n, nil
+}
+
+// verify checks the trailer against the rows restored and the checksum of the backup
+func (b *backupReader) verify(rows map[string]int64) error {
+	sum := hex.EncodeToString(b.hash.Sum(nil))
+
+	line, err := b.readLine()
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(line, []byte(backupTrailer)) {
+		return fmt.Errorf("%w: expected the trailer", ErrInvalidBackup)
+	}
+	var summary backupSummary
+	if err := json.Unmarshal(line[len(backupTrailer):], &summary); err != nil {
+		return fmt.Errorf("%w: malformed trailer: %v", ErrInvalidBackup, err)
+	}
+
+	if summary.SHA256 != sum {
+		return fmt.Errorf("%w: checksum mismatch, the file is corrupted", ErrInvalidBackup)
+	}
+	for table, count := range rows {
+		if summary.Rows[table] != count {
+			return fmt.Errorf("%w: %s has %d rows, the backup recorded %d", ErrInvalidBackup, table, count, summary.Rows[table])
+		}
+	}
+	return nil
+}
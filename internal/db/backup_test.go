@@ -0,0 +1,37 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackupTables fails when a model is added without deciding whether its table is backed up
+func TestBackupTables(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	tablePattern := regexp.MustCompile("bun:\"table:([a-z_]+)")
+	var tables []string
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, match := range tablePattern.FindAllSubmatch(source, -1) {
+			tables = append(tables, string(match[1]))
+		}
+	}
+	require.NotEmpty(t, tables)
+
+	for _, table := range tables {
+		assert.True(t, slices.Contains(BackupTables, table) || slices.Contains(skippedBackupTables, table),
+			"table %s is neither in BackupTables nor in skippedBackupTables", table)
+	}
+	for _, table := range serialTables {
+		assert.Contains(t, BackupTables, table)
+	}
+}
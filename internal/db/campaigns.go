@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// CampaignStatus is the step of a campaign in the approval workflow
+type CampaignStatus string
+
+// Steps of the approval workflow, a campaign moves from draft to launched. A rejected campaign goes back to draft,
+// a launched one can be paused and resumed.
+const (
+	CampaignStatusDraft           CampaignStatus = "draft"
+	CampaignStatusPendingApproval CampaignStatus = "pending_approval"
+	CampaignStatusApproved        CampaignStatus = "approved"
+	CampaignStatusLaunched        CampaignStatus = "launched"
+	CampaignStatusPaused          CampaignStatus = "paused"
+)
+
+// IsValid reports whether s is a step of the approval workflow
+func (s CampaignStatus) IsValid() bool {
+	switch s {
+	case CampaignStatusDraft, CampaignStatusPendingApproval, CampaignStatusApproved, CampaignStatusLaunched, CampaignStatusPaused:
+		return true
+	}
+	return false
+}
+
+// Campaign is a campaign registered for the approval workflow. The pending messages of a campaign that is not
+// launched yet are held, the scheduler defers them instead of sending them.
+type Campaign struct {
+	bun.BaseModel `bun:"table:campaigns"`
+
+	// Name is the campaign of the messages, Message.Campaign
+	Name        string         `bun:"name,pk" json:"name"`
+	Status      CampaignStatus `bun:"status,notnull" json:"status"`
+	Description string         `bun:"description,nullzero" json:"description,omitempty"`
+	// CreatedBy and the other actors are the names of the API keys that took the steps, empty without keys.
+	// CreatedBy is scheduler for a campaign registered by its first held message.
+	CreatedBy   string     `bun:"created_by,nullzero" json:"created_by,omitempty"`
+	SubmittedBy string     `bun:"submitted_by,nullzero" json:"submitted_by,omitempty"`
+	SubmittedAt *time.Time `bun:"submitted_at" json:"submitted_at,omitempty"`
+	ApprovedBy  string     `bun:"approved_by,nullzero" json:"approved_by,omitempty"`
+	ApprovedAt  *time.Time `bun:"approved_at" json:"approved_at,omitempty"`
+	LaunchedBy  string     `bun:"launched_by,nullzero" json:"launched_by,omitempty"`
+	LaunchedAt  *time.Time `bun:"launched_at" json:"launched_at,omitempty"`
+	// PausedBy and ResumedBy are the last actors that paused and resumed the launched campaign
+	PausedBy  string     `bun:"paused_by,nullzero" json:"paused_by,omitempty"`
+	PausedAt  *time.Time `bun:"paused_at" json:"paused_at,omitempty"`
+	ResumedBy string     `bun:"resumed_by,nullzero" json:"resumed_by,omitempty"`
+	ResumedAt *time.Time `bun:"resumed_at" json:"resumed_at,omitempty"`
+	// RejectedBy and Rejection are the approver that sent the campaign back to draft last and why
+	RejectedBy string    `bun:"rejected_by,nullzero" json:"rejected_by,omitempty"`
+	Rejection  string    `bun:"rejection,nullzero" json:"rejection,omitempty"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// CreateCampaign registers campaign in its status, it returns false when a campaign of its name exists already
+func CreateCampaign(ctx context.Context, db bun.IDB, campaign *Campaign) (bool, error) {
+	campaign.CreatedAt = time.Now()
+	campaign.UpdatedAt = campaign.CreatedAt
+
+	res, err := db.NewInsert().
+		Model(campaign).
+		On("CONFLICT (name) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// GetCampaign returns the campaign of name.
+// Returns sql.ErrNoRows if there is none.
+func GetCampaign(ctx context.Context, db bun.IDB, name string) (*Campaign, error) {
+	campaign := new(Campaign)
+	err := db.NewSelect().
+		Model(campaign).
+		Where("name = ?", name).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// ListCampaigns returns the campaigns of status ordered by name, every campaign when status is empty
+func ListCampaigns(ctx context.Context, db bun.IDB, status CampaignStatus) ([]*Campaign, error) {
+	var campaigns []*Campaign
+	q := db.NewSelect().
+		Model(&campaigns).
+		Order("name ASC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Scan(ctx)
+	return campaigns, err
+}
+
+// ListHeldCampaigns returns the names of the campaigns whose messages are held, the ones not launched yet
+func ListHeldCampaigns(ctx context.Context, db bun.IDB) ([]string, error) {
+	var names []string
+	err := db.NewSelect().
+		Model((*Campaign)(nil)).
+		Column("name").
+		Where("status != ?", CampaignStatusLaunched).
+		Scan(ctx, &names)
+	return names, err
+}
+
+// TransitionCampaign stores the step campaign took from the status from, along with the columns of the step. It
+// returns false when the campaign was no longer in from, e.g. another approver took the step first.
+func TransitionCampaign(ctx context.Context, db bun.IDB, campaign *Campaign, from CampaignStatus, columns ...string) (bool, error) {
+	campaign.UpdatedAt = time.Now()
+
+	res, err := db.NewUpdate().
+		Model(campaign).
+		Column(append([]string{"status", "updated_at"}, columns...)...).
+		WherePK().
+		Where("status = ?", from).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// CountPendingCampaignMessages returns the number of pending messages of every campaign of names
+func CountPendingCampaignMessages(ctx context.Context, db bun.IDB, names []string) (map[string]int, error) {
+	counts := make(map[string]int, len(names))
+	if len(names) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		Campaign string `bun:"campaign"`
+		Count    int    `bun:"count"`
+	}
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		ColumnExpr("campaign, COUNT(*) AS count").
+		Where("status = ?", MessageStatusPending).
+		Where("campaign IN (?)", bun.In(names)).
+		Group("campaign").
+		Scan(ctx, &rows)
+	for _, row := range rows {
+		counts[row.Campaign] = row.Count
+	}
+	return counts, err
+}
+
+// DeferCampaignMessages defers the pending messages of campaign that are due before until to it, so the claims in
+// between go to other traffic. It returns the number of deferred messages.
+func DeferCampaignMessages(ctx context.Context, db bun.IDB, campaign string, until time.Time) (int, error) {
+	res, err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Set("scheduled_at = ?", until).
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", MessageStatusPending).
+		Where("campaign = ?", campaign).
+		Where("scheduled_at IS NULL OR scheduled_at < ?", until).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// CampaignRecipient is a message a registered campaign sent and its recipient. The messages are the progress cursor
+// of the campaign: once paused, changed and resumed it does not send a message it sent already again, e.g. one
+// requeued by the stuck reaper after its send went out. A recipient may be sent several messages of a campaign.
+type CampaignRecipient struct {
+	bun.BaseModel `bun:"table:campaign_recipients"`
+
+	Campaign  string    `bun:"campaign,pk" json:"campaign"`
+	MessageID int64     `bun:"message_id,pk" json:"message_id"`
+	To        string    `bun:"to,notnull" json:"to"`
+	SentAt    time.Time `bun:"sent_at,notnull" json:"sent_at"`
+}
+
+// CampaignProgress is how far a campaign got through its recipients
+type CampaignProgress struct {
+	Campaign string `bun:"campaign"`
+	// Recipients is the number of recipients sent to, Messages the number of messages sent to them.
+	// LastMessageID and LastSentAt are the last send.
+	Recipients    int        `bun:"recipients"`
+	Messages      int        `bun:"messages"`
+	LastMessageID int64      `bun:"last_message_id"`
+	LastSentAt    *time.Time `bun:"last_sent_at"`
+}
+
+// AdvanceCampaignCursor records that campaign sent the message of messageID to recipient, a message recorded
+// already keeps its first send
+func AdvanceCampaignCursor(ctx context.Context, db bun.IDB, campaign, recipient string, messageID int64) error {
+	_, err := db.NewInsert().
+		Model(&CampaignRecipient{Campaign: campaign, MessageID: messageID, To: recipient, SentAt: time.Now()}).
+		On("CONFLICT (campaign, message_id) DO NOTHING").
+		Exec(ctx)
+	return err
+}
+
+// CampaignSentAt returns when campaign sent the message of messageID, nil when it did not send it yet
+func CampaignSentAt(ctx context.Context, db bun.IDB, campaign string, messageID int64) (*time.Time, error) {
+	var sent []time.Time
+	err := db.NewSelect().
+		Model((*CampaignRecipient)(nil)).
+		Column("sent_at").
+		Where("campaign = ?", campaign).
+		Where("message_id = ?", messageID).
+		Scan(ctx, &sent)
+	if err != nil || len(sent) == 0 {
+		return nil, err
+	}
+	return &sent[0], nil
+}
+
+// GetCampaignProgress returns the progress of every campaign of names that sent a message
+func GetCampaignProgress(ctx context.Context, db bun.IDB, names []string) (map[string]*CampaignProgress, error) {
+	progress := make(map[string]*CampaignProgress, len(names))
+	if len(names) == 0 {
+		return progress, nil
+	}
+
+	var rows []*CampaignProgress
+	err := db.NewSelect().
+		Model((*CampaignRecipient)(nil)).
+		ColumnExpr("campaign, COUNT(DISTINCT ?) AS recipients, COUNT(*) AS messages", bun.Ident("to")).
+		ColumnExpr("MAX(message_id) AS last_message_id, MAX(sent_at) AS last_sent_at").
+		Where("campaign IN (?)", bun.In(names)).
+		Group("campaign").
+		Scan(ctx, &rows)
+	for _, row := range rows {
+		progress[row.Campaign] = row
+	}
+	return progress, err
+}
@@ -1,27 +1,104 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/extra/bunotel"
+)
 
-	_ "github.com/uptrace/bun/driver/pgdriver" // PostgreSQL driver
+// Backoff of ConnectWithRetry between the connection attempts
+const (
+	connectRetryMin = 500 * time.Millisecond
+	connectRetryMax = 10 * time.Second
 )
 
 // Connect returns a DB connection.
 func Connect(dsn string) (*bun.DB, error) {
+	return connect(context.Background(), dsn)
+}
+
+// ConnectWithRetry connects like Connect but retries connection errors with a backoff until timeout passed,
+// so the server can start before the database accepts connections. onRetry is called before every wait.
+// Other errors, like wrong credentials, are returned right away, as is every error when timeout is 0.
+func ConnectWithRetry(ctx context.Context, dsn string, timeout time.Duration, onRetry func(attempt int, err error, wait time.Duration)) (*bun.DB, error) {
+	deadline := time.Now().Add(timeout)
+	wait := connectRetryMin
+	for attempt := 1; ; attempt++ {
+		db, err := connect(ctx, dsn)
+		if err == nil || !IsConnectionError(err) || timeout <= 0 {
+			return db, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("database unreachable after %s: %w", timeout, err)
+		}
+		wait = min(wait, remaining)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+		wait = min(wait*2, connectRetryMax)
+	}
+}
+
+func connect(ctx context.Context, dsn string) (*bun.DB, error) {
 	sqldb, err := sql.Open("pg", dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	// Test the connection
-	if err := sqldb.Ping(); err != nil {
+	if err := sqldb.PingContext(ctx); err != nil {
 		sqldb.Close()
 		return nil, err
 	}
 
 	db := bun.NewDB(sqldb, pgdialect.New())
+	// Every query becomes a span of the trace in its context, a no-op unless tracing is enabled
+	db.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName(DatabaseName(dsn))))
 	return db, nil
 }
+
+// IsConnectionError reports whether err means the database could not be reached or dropped the connection,
+// as opposed to an error of the query itself. Such errors go away once the database is back.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		// connection exceptions, and the server shutting down or starting up
+		code := pgErr.Field('C')
+		return strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02" || code == "57P03"
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
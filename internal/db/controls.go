@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MessagingControlName is the control every scheduler of the cluster follows, started when enabled
+const MessagingControlName = "messaging"
+
+// Control is a switch shared by every instance, instances poll it and apply the changes
+type Control struct {
+	bun.BaseModel `bun:"table:controls"`
+
+	Name    string `bun:"name,pk" json:"name"`
+	Enabled bool   `bun:"enabled,notnull" json:"enabled"`
+	// ChangedBy identifies the instance that changed the control last
+	ChangedBy string    `bun:"changed_by,notnull" json:"changed_by"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// GetControl returns the control called name, nil when it was never set
+func GetControl(ctx context.Context, db bun.IDB, name string) (*Control, error) {
+	control := new(Control)
+	err := db.NewSelect().Model(control).Where("name = ?", name).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return control, nil
+}
+
+// SetControl sets the control called name and reports whether it changed, setting it to its current value
+// is a no-op so concurrent requests for the same state change it once
+func SetControl(ctx context.Context, db bun.IDB, name string, enabled bool, changedBy string) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO controls (name, enabled, changed_by, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled, changed_by = EXCLUDED.changed_by, updated_at = EXCLUDED.updated_at
+		WHERE controls.enabled <> EXCLUDED.enabled`,
+		name, enabled, changedBy, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// CostGroup is what message costs are summed by
+type CostGroup string
+
+const (
+	// CostGroupDay sums by the UTC day messages were sent
+	CostGroupDay      CostGroup = "day"
+	CostGroupCampaign CostGroup = "campaign"
+	CostGroupTenant   CostGroup = "tenant"
+)
+
+var ErrInvalidCostGroup = errors.New("cost group must be day, campaign or tenant")
+
+// CostSummary is the cost of the messages sent in a group, messages without a campaign or tenant
+// are summed under an empty key
+type CostSummary struct {
+	Key      string  `bun:"key"`
+	Messages int64   `bun:"messages"`
+	Segments int64   `bun:"segments"`
+	Cost     float64 `bun:"cost"`
+}
+
+// GetCosts sums the cost of the messages sent in [from, to) per group, see Message.Cost. Either bound may be nil.
+func GetCosts(ctx context.Context, db bun.IDB, group CostGroup, from, to *time.Time) ([]*CostSummary, error) {
+	var key string
+	switch group {
+	case CostGroupDay:
+		key = "to_char(sent_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+		if db.Dialect().Name() == dialect.SQLite {
+			key = "strftime('%Y-%m-%d', sent_at)"
+		}
+	case CostGroupCampaign:
+		key = "COALESCE(campaign, '')"
+	case CostGroupTenant:
+		key = "COALESCE(tenant, '')"
+	default:
+		return nil, ErrInvalidCostGroup
+	}
+
+	query := db.NewSelect().
+		Model((*Message)(nil)).
+		ColumnExpr(key + " AS key").
+		ColumnExpr("COUNT(*) AS messages").
+		ColumnExpr("COALESCE(SUM(segments), 0) AS segments").
+		ColumnExpr("COALESCE(SUM(segments * unit_price), 0.0) AS cost").
+		Where("sent_at IS NOT NULL").
+		GroupExpr(key).
+		OrderExpr("key ASC")
+	if from != nil {
+		query = query.Where("sent_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("sent_at < ?", *to)
+	}
+
+	var costs []*CostSummary
+	err := query.Scan(ctx, &costs)
+	return costs, err
+}
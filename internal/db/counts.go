@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MessageStatuses are all message statuses
+var MessageStatuses = []MessageStatus{MessageStatusPending, MessageStatusSending, MessageStatusAccepted, MessageStatusSent,
+	MessageStatusDelivered, MessageStatusUnconfirmed, MessageStatusFailed, MessageStatusBlocked, MessageStatusQuarantined,
+	MessageStatusCancelled, MessageStatusExpired, MessageStatusAcceptedWithoutID}
+
+// MessageCount is the cached number of messages in a status, refreshed periodically so stats and list
+// totals do not count the messages table on every request
+type MessageCount struct {
+	bun.BaseModel `bun:"table:message_counts"`
+
+	Status      MessageStatus `bun:"status,pk" json:"status"`
+	Count       int           `bun:"count,notnull" json:"count"`
+	RefreshedAt time.Time     `bun:"refreshed_at,notnull" json:"refreshed_at"`
+}
+
+// RefreshMessageCounts counts the messages per status into the message_counts table, a row per status
+// including the empty ones so the refresh time is known without messages
+func RefreshMessageCounts(ctx context.Context, db bun.IDB) error {
+	counts, err := CountMessagesByStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	rows := make([]MessageCount, 0, len(MessageStatuses))
+	for _, status := range MessageStatuses {
+		rows = append(rows, MessageCount{Status: status, Count: counts[status], RefreshedAt: now})
+	}
+	_, err = db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (status) DO UPDATE").
+		Set("count = EXCLUDED.count").
+		Set("refreshed_at = EXCLUDED.refreshed_at").
+		Exec(ctx)
+	return err
+}
+
+// GetMessageCounts returns the cached message counts per status and when they were counted, the zero time
+// when they never were
+func GetMessageCounts(ctx context.Context, db bun.IDB) (map[MessageStatus]int, time.Time, error) {
+	var rows []MessageCount
+	if err := db.NewSelect().Model(&rows).Scan(ctx); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	counts := make(map[MessageStatus]int, len(rows))
+	var refreshedAt time.Time
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+		if refreshedAt.IsZero() || row.RefreshedAt.Before(refreshedAt) {
+			refreshedAt = row.RefreshedAt
+		}
+	}
+	return counts, refreshedAt, nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// DeadLetterGroup is what dead-lettered messages are counted by
+type DeadLetterGroup string
+
+const (
+	// DeadLetterGroupReason counts by the error of the last failed send
+	DeadLetterGroupReason DeadLetterGroup = "reason"
+	// DeadLetterGroupClass counts by the failure class, see webhook.ClassifyError
+	DeadLetterGroupClass DeadLetterGroup = "class"
+	// DeadLetterGroupPrefix counts by the leading digits of the recipient
+	DeadLetterGroupPrefix DeadLetterGroup = "prefix"
+	// DeadLetterGroupProvider counts by the provider of the route the message was last claimed for
+	DeadLetterGroupProvider DeadLetterGroup = "provider"
+)
+
+var ErrInvalidDeadLetterGroup = errors.New("dead-letter group must be reason, class, prefix or provider")
+
+// DeadLetterCount is the number of dead-lettered messages in a group, messages without a reason, class or
+// provider are counted under an empty key
+type DeadLetterCount struct {
+	Key      string `bun:"key"`
+	Messages int64  `bun:"messages"`
+}
+
+// DeadLetterHour is the number of messages dead-lettered in an hour of a weekday, both UTC. Weekday 0 is Sunday.
+type DeadLetterHour struct {
+	Weekday  int   `bun:"weekday"`
+	Hour     int   `bun:"hour"`
+	Messages int64 `bun:"messages"`
+}
+
+// deadLetters selects the messages dead-lettered in [from, to), either bound may be nil
+func deadLetters(db bun.IDB, from, to *time.Time) *bun.SelectQuery {
+	query := db.NewSelect().
+		Model((*Message)(nil)).
+		Where("dead_lettered_at IS NOT NULL")
+	if from != nil {
+		query = query.Where("dead_lettered_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("dead_lettered_at < ?", *to)
+	}
+	return query
+}
+
+// CountDeadLetters returns the number of messages dead-lettered in [from, to), either bound may be nil
+func CountDeadLetters(ctx context.Context, db bun.IDB, from, to *time.Time) (int, error) {
+	return deadLetters(db, from, to).Count(ctx)
+}
+
+// TopDeadLetters returns the limit groups with the most messages dead-lettered in [from, to), most first.
+// Prefixes are the + and the first prefixDigits digits of the recipient.
+func TopDeadLetters(ctx context.Context, db bun.IDB, group DeadLetterGroup, prefixDigits, limit int, from, to *time.Time) ([]*DeadLetterCount, error) {
+	var key string
+	var args []any
+	switch group {
+	case DeadLetterGroupReason:
+		key = "COALESCE(failure_error, '')"
+	case DeadLetterGroupClass:
+		key = "COALESCE(failure_class, '')"
+	case DeadLetterGroupPrefix:
+		key, args = `substr("to", 1, ?)`, []any{prefixDigits + 1}
+	case DeadLetterGroupProvider:
+		key = "COALESCE(provider, '')"
+	default:
+		return nil, ErrInvalidDeadLetterGroup
+	}
+
+	var counts []*DeadLetterCount
+	err := deadLetters(db, from, to).
+		ColumnExpr(key+" AS key", args...).
+		ColumnExpr("COUNT(*) AS messages").
+		GroupExpr(key, args...).
+		OrderExpr("messages DESC").
+		OrderExpr("key ASC").
+		Limit(limit).
+		Scan(ctx, &counts)
+	return counts, err
+}
+
+// DeadLetterHeatmap returns the number of messages dead-lettered in [from, to) per UTC weekday and hour, hours
+// without one are left out
+func DeadLetterHeatmap(ctx context.Context, db bun.IDB, from, to *time.Time) ([]*DeadLetterHour, error) {
+	weekday := "CAST(EXTRACT(DOW FROM dead_lettered_at AT TIME ZONE 'UTC') AS INTEGER)"
+	hour := "CAST(EXTRACT(HOUR FROM dead_lettered_at AT TIME ZONE 'UTC') AS INTEGER)"
+	if db.Dialect().Name() == dialect.SQLite {
+		weekday = "CAST(strftime('%w', dead_lettered_at) AS INTEGER)"
+		hour = "CAST(strftime('%H', dead_lettered_at) AS INTEGER)"
+	}
+
+	var hours []*DeadLetterHour
+	err := deadLetters(db, from, to).
+		ColumnExpr(weekday+" AS weekday").
+		ColumnExpr(hour+" AS hour").
+		ColumnExpr("COUNT(*) AS messages").
+		GroupExpr(weekday).
+		GroupExpr(hour).
+		OrderExpr("weekday ASC, hour ASC").
+		Scan(ctx, &hours)
+	return hours, err
+}
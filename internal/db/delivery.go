@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// DeliveryReport is the delivery state of a message reported by the provider after the webhook accepted it
+type DeliveryReport struct {
+	// MessageID is the message ID the webhook returned
+	MessageID string
+	// Status is sent, delivered or failed
+	Status MessageStatus
+	Error  string
+	At     time.Time
+}
+
+// deliveryReportTransitions lists the statuses a reported status may replace. Reports arrive out of order,
+// so a late sent report never overrides delivered or failed.
+var deliveryReportTransitions = map[MessageStatus][]MessageStatus{
+	MessageStatusSent:      {MessageStatusAccepted, MessageStatusUnconfirmed},
+	MessageStatusDelivered: {MessageStatusAccepted, MessageStatusUnconfirmed, MessageStatusSent},
+	MessageStatusFailed:    {MessageStatusAccepted, MessageStatusUnconfirmed, MessageStatusSent},
+}
+
+// ErrInvalidReportStatus is returned for delivery reports with a status other than sent, delivered or failed
+var ErrInvalidReportStatus = errors.New("delivery report status must be sent, delivered or failed")
+
+// ApplyDeliveryReport moves the message the webhook returned report.MessageID for to the reported status.
+// It returns the message and whether the report changed it, a report that arrived after a later one is ignored.
+// Returns sql.ErrNoRows when no message has the webhook message ID.
+func ApplyDeliveryReport(ctx context.Context, db bun.IDB, report DeliveryReport) (*Message, bool, error) {
+	from, ok := deliveryReportTransitions[report.Status]
+	if !ok {
+		return nil, false, ErrInvalidReportStatus
+	}
+
+	message := new(Message)
+	query := db.NewUpdate().
+		Model(message).
+		Set("status = ?", report.Status).
+		Set("updated_at = ?", time.Now()).
+		Where("message_id = ?", report.MessageID).
+		Where("status IN (?)", bun.In(from)).
+		Returning("*")
+	if report.Status == MessageStatusDelivered {
+		query = query.Set("delivered_at = ?", report.At)
+	}
+	if report.Status == MessageStatusFailed {
+		query = query.Set("delivery_error = ?", report.Error)
+	}
+
+	err := query.Scan(ctx)
+	if err == nil {
+		return message, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	// nothing was updated, either the message does not exist or it is already past the reported status
+	err = db.NewSelect().
+		Model(message).
+		Where("message_id = ?", report.MessageID).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return message, false, nil
+}
+
+// MarkUnconfirmedMessages moves messages accepted before acceptedBefore to unconfirmed and returns them
+func MarkUnconfirmedMessages(ctx context.Context, db bun.IDB, acceptedBefore time.Time) ([]*Message, error) {
+	var messages []*Message
+
+	_, err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Set("status = ?", MessageStatusUnconfirmed).
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", MessageStatusAccepted).
+		Where("sent_at < ?", acceptedBefore).
+		Returning("*").
+		Exec(ctx, &messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
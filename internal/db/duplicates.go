@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// duplicatePair is a recipient sent the same content more than once
+type duplicatePair struct {
+	To      string    `bun:"to"`
+	Content string    `bun:"content"`
+	LastAt  time.Time `bun:"last_at"`
+}
+
+// ListDuplicateCandidates returns the messages created in [from, to) whose recipient was given the same content
+// more than once in it, ordered by recipient, content and creation. Only the limit recipient and content pairs
+// created last are returned, truncated is true when there are more.
+func ListDuplicateCandidates(ctx context.Context, db bun.IDB, from, to time.Time, limit int) (messages []*Message, truncated bool, err error) {
+	var pairs []duplicatePair
+	err = db.NewSelect().
+		Model((*Message)(nil)).
+		Column("to", "content").
+		ColumnExpr("MAX(created_at) AS last_at").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("to", "content").
+		Having("COUNT(*) > 1").
+		OrderExpr("last_at DESC").
+		Limit(limit+1).
+		Scan(ctx, &pairs)
+	if err != nil || len(pairs) == 0 {
+		return nil, false, err
+	}
+	if len(pairs) > limit {
+		pairs, truncated = pairs[:limit], true
+	}
+
+	tuples := make([][]any, len(pairs))
+	for i, pair := range pairs {
+		tuples[i] = []any{pair.To, pair.Content}
+	}
+	err = db.NewSelect().
+		Model(&messages).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Where(`("to", content) IN (?)`, bun.In(tuples)).
+		Order("to", "content", "created_at", "id").
+		Scan(ctx)
+	return messages, truncated, err
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type ErasureMode string
+
+const (
+	// ErasureModeDelete deletes the messages of the number
+	ErasureModeDelete ErasureMode = "delete"
+	// ErasureModeAnonymize keeps the messages for statistics and costs but removes the number, the content,
+	// the provider responses and the metadata
+	ErasureModeAnonymize ErasureMode = "anonymize"
+)
+
+const (
+	// ErasedPhone replaces the recipient of anonymized messages, 999 is an unassigned country code
+	ErasedPhone = "+999"
+	// MaxErasureReferenceLength limits the reference stored with an erasure record
+	MaxErasureReferenceLength = 256
+)
+
+func (m ErasureMode) IsValid() bool {
+	return m == ErasureModeDelete || m == ErasureModeAnonymize
+}
+
+// Erasure is the audit record of erasing the data of a phone number. The number itself is not kept,
+// PhoneHash is its SHA-256 so a request can be matched to its record.
+type Erasure struct {
+	bun.BaseModel `bun:"table:erasures"`
+
+	ID          int64       `bun:"id,pk,autoincrement" json:"id"`
+	PhoneHash   string      `bun:"phone_hash,notnull" json:"phone_hash"`
+	Mode        ErasureMode `bun:"mode,notnull" json:"mode"`
+	Messages    int         `bun:"messages,notnull" json:"messages"`
+	Links       int         `bun:"links,notnull" json:"links"`
+	Suppression bool        `bun:"suppression,notnull" json:"suppression"`
+	// Reference is the caller's reference of the request, e.g. a ticket ID
+	Reference string `bun:"reference,nullzero" json:"reference,omitempty"`
+	// RequestedBy is the API key of the request, cli when it was run from the command line
+	RequestedBy string    `bun:"requested_by,notnull" json:"requested_by"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// HashPhone returns the SHA-256 of a phone number as stored in the erasure records
+func HashPhone(phone string) string {
+	sum := sha256.Sum256([]byte(phone))
+	return hex.EncodeToString(sum[:])
+}
+
+// CountErasable returns the number of messages to phone and of the tracked links in them
+func CountErasable(ctx context.Context, db bun.IDB, phone string) (messages, links int, err error) {
+	messages, err = db.NewSelect().
+		Model((*Message)(nil)).
+		Where(`"to" = ?`, phone).
+		Count(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	links, err = db.NewSelect().
+		Model((*Link)(nil)).
+		Where("message_id IN (?)", messagesTo(db, phone)).
+		Count(ctx)
+	return messages, links, err
+}
+
+// EraseMessages deletes the tracked links in the messages to phone, then deletes or anonymizes the messages and
+// the campaign cursors recording them.
+// Anonymized messages that were not sent yet are cancelled. It returns the number of messages and links erased.
+func EraseMessages(ctx context.Context, db bun.IDB, phone string, mode ErasureMode) (messages, links int, err error) {
+	res, err := db.NewDelete().
+		Model((*Link)(nil)).
+		Where("message_id IN (?)", messagesTo(db, phone)).
+		Exec(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	affected, _ := res.RowsAffected()
+	links = int(affected)
+
+	// the raw provider responses are erased with the webhook responses referencing them
+	if _, err := db.NewDelete().
+		Model((*MessagePayload)(nil)).
+		Where("message_id IN (?)", messagesTo(db, phone)).
+		Exec(ctx); err != nil {
+		return 0, links, err
+	}
+
+	if mode == ErasureModeDelete {
+		// the events of deleted messages would only be orphans
+		if _, err := db.NewDelete().
+			Model((*MessageEvent)(nil)).
+			Where("message_id IN (?)", messagesTo(db, phone)).
+			Exec(ctx); err != nil {
+			return 0, links, err
+		}
+		if _, err := db.NewDelete().
+			Model((*SendAttempt)(nil)).
+			Where("message_id IN (?)", messagesTo(db, phone)).
+			Exec(ctx); err != nil {
+			return 0, links, err
+		}
+		if _, err := db.NewDelete().
+			Model((*CampaignRecipient)(nil)).
+			Where(`"to" = ?`, phone).
+			Exec(ctx); err != nil {
+			return 0, links, err
+		}
+		res, err = db.NewDelete().
+			Model((*Message)(nil)).
+			Where(`"to" = ?`, phone).
+			Exec(ctx)
+	} else {
+		// the campaign cursors keep counting the messages sent, without the number
+		if _, err := db.NewUpdate().
+			Model((*CampaignRecipient)(nil)).
+			Set(`"to" = ?`, ErasedPhone).
+			Where(`"to" = ?`, phone).
+			Exec(ctx); err != nil {
+			return 0, links, err
+		}
+		res, err = db.NewUpdate().
+			Model((*Message)(nil)).
+			Set(`"to" = ?`, ErasedPhone).
+			Set("content = ''").
+			Set("rendered_content = NULL").
+			Set("message_id = NULL").
+			Set("webhook_response = NULL").
+			Set("delivery_error = NULL").
+			Set("metadata = NULL").
+			Set("status = CASE WHEN status = ? THEN ? ELSE status END", MessageStatusPending, MessageStatusCancelled).
+			Set("updated_at = ?", time.Now()).
+			Where(`"to" = ?`, phone).
+			Exec(ctx)
+	}
+	if err != nil {
+		return 0, links, err
+	}
+	affected, _ = res.RowsAffected()
+	return int(affected), links, nil
+}
+
+// CreateErasure stores the audit record of an erasure
+func CreateErasure(ctx context.Context, db bun.IDB, erasure *Erasure) error {
+	erasure.CreatedAt = time.Now()
+	_, err := db.NewInsert().
+		Model(erasure).
+		Returning("id").
+		Exec(ctx)
+	return err
+}
+
+// ListErasures returns the erasure records, newest first. phoneHash limits them to the records of a number when set.
+func ListErasures(ctx context.Context, db bun.IDB, phoneHash string, limit, offset int) ([]*Erasure, error) {
+	var erasures []*Erasure
+	query := db.NewSelect().
+		Model(&erasures).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset)
+	if phoneHash != "" {
+		query = query.Where("phone_hash = ?", phoneHash)
+	}
+	err := query.Scan(ctx)
+	return erasures, err
+}
+
+func messagesTo(db bun.IDB, phone string) *bun.SelectQuery {
+	return db.NewSelect().
+		Model((*Message)(nil)).
+		Column("id").
+		Where(`"to" = ?`, phone)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Reasons the scheduler skipped a claimed message instead of sending it
+const (
+	// SkipSuppressed is a message blocked because its recipient was suppressed after it was enqueued
+	SkipSuppressed = "suppressed"
+	// SkipSuppressionCheck is a message requeued because the suppression of its recipient could not be checked
+	SkipSuppressionCheck = "suppression_check_failed"
+	// SkipThrottled is a message deferred to the send slot of its throttle profile
+	SkipThrottled = "throttled"
+	// SkipPaused is a message deferred while its recipient is paused
+	SkipPaused = "paused"
+	// SkipHeld is a message deferred while its campaign waits for approval or launch or is paused
+	SkipHeld = "held"
+	// SkipCampaignSent is a message settled as accepted without sending it, because the progress cursor of its
+	// launched campaign shows it was sent already
+	SkipCampaignSent = "campaign_sent"
+	// SkipCursorCheck is a message requeued because the progress cursor of its campaign could not be checked
+	SkipCursorCheck = "cursor_check_failed"
+	// SkipRateLimited is a message requeued while it waited for the rate limit of its route
+	SkipRateLimited = "rate_limited"
+	// SkipRouteFailed is a message requeued because its route could not be stored
+	SkipRouteFailed = "route_failed"
+	// SkipTransformFailed is a message requeued because its content could not be rendered or stored
+	SkipTransformFailed = "transform_failed"
+	// SkipStopped is a message requeued because the scheduler stopped before it was sent
+	SkipStopped = "stopped"
+	// SkipCancelled is a message an operator cancelled while it was sending, before its webhook call
+	SkipCancelled = "cancelled"
+	// SkipExpired is a message expired because it was claimed after its expires_at
+	SkipExpired = "expired"
+)
+
+// MessageEvent is a decision the scheduler took on a message without sending it, kept for audits
+type MessageEvent struct {
+	bun.BaseModel `bun:"table:message_events"`
+
+	ID        int64 `bun:"id,pk,autoincrement" json:"id"`
+	MessageID int64 `bun:"message_id,notnull" json:"message_id"`
+	// Reason is one of the Skip reasons, Detail adds what the scheduler knew, like the time a message was deferred to
+	Reason    string    `bun:"reason,notnull" json:"reason"`
+	Detail    string    `bun:"detail,nullzero" json:"detail,omitempty"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// CreateMessageEvent stores an event of a message
+func CreateMessageEvent(ctx context.Context, db bun.IDB, event *MessageEvent) error {
+	event.CreatedAt = time.Now()
+	_, err := db.NewInsert().Model(event).Exec(ctx)
+	return err
+}
+
+// GetMessageEvents returns the events of a message, oldest first
+func GetMessageEvents(ctx context.Context, db bun.IDB, messageID int64) ([]*MessageEvent, error) {
+	var events []*MessageEvent
+	err := db.NewSelect().
+		Model(&events).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC", "id ASC").
+		Scan(ctx)
+	return events, err
+}
+
+// DeleteMessageEvents deletes the events of the messages with the given IDs
+func DeleteMessageEvents(ctx context.Context, db bun.IDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.NewDelete().
+		Model((*MessageEvent)(nil)).
+		Where("message_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FailMessage settles a claimed message as failed with its FailureClass, FailureError and DeadLetteredAt.
+// An acknowledgment of an earlier failure is cleared, the note is kept.
+// Like UpdateMessageStatus it returns a *StaleStatusError when the message is no longer sending.
+func FailMessage(ctx context.Context, db bun.IDB, message *Message) error {
+	query := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusFailed).
+		Set("updated_at = ?", time.Now()).
+		Set("failure_class = ?", nullString(message.FailureClass)).
+		Set("failure_error = ?", nullString(message.FailureError)).
+		Set("dead_lettered_at = ?", message.DeadLetteredAt).
+		Set("acknowledged_at = NULL").
+		Set("acknowledged_by = NULL").
+		Where("id = ?", message.ID).
+		Where("status = ?", MessageStatusSending)
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return settled(ctx, db, res, message.ID, MessageStatusFailed)
+}
+
+// RequeueFailures moves the failed messages of classes requeued requeues times and failed before failedBefore
+// back to pending, counting the requeue. Dead-lettered messages are left alone.
+func RequeueFailures(ctx context.Context, db bun.IDB, classes []string, requeues int, failedBefore time.Time) (int, error) {
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("updated_at = ?", time.Now()).
+		Set("requeues = requeues + 1").
+		Where("status = ?", MessageStatusFailed).
+		Where("dead_lettered_at IS NULL").
+		Where("failure_class IN (?)", bun.In(classes)).
+		Where("requeues = ?", requeues).
+		Where("updated_at <= ?", failedBefore).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// DeadLetterFailures moves the failed messages of classes requeued maxRequeues times or more to the dead-letter
+// queue and returns them
+func DeadLetterFailures(ctx context.Context, db bun.IDB, classes []string, maxRequeues int) ([]*Message, error) {
+	var messages []*Message
+	_, err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Set("dead_lettered_at = ?", time.Now()).
+		Where("status = ?", MessageStatusFailed).
+		Where("dead_lettered_at IS NULL").
+		Where("failure_class IN (?)", bun.In(classes)).
+		Where("requeues >= ?", maxRequeues).
+		Returning("*").
+		Exec(ctx, &messages)
+	return messages, err
+}
+
+// nullString stores an empty string as NULL, like the nullzero columns
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// MessageTriage changes the operator note and acknowledgment of a failed message, nil fields are left as they are
+type MessageTriage struct {
+	// Note replaces the note, empty clears it
+	Note *string
+	// Acknowledged acknowledges the failure by By when true and withdraws the acknowledgment when false
+	Acknowledged *bool
+	// By is the API key name the acknowledgment is recorded for
+	By string
+}
+
+// TriageMessage applies triage to a failed message and returns it
+// Returns sql.ErrNoRows if the message does not exist or is not failed
+func TriageMessage(ctx context.Context, db bun.IDB, id int64, triage MessageTriage) (*Message, error) {
+	message := new(Message)
+	query := db.NewUpdate().
+		Model(message).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status = ?", MessageStatusFailed).
+		Returning("*")
+	if triage.Note != nil {
+		query = query.Set("note = ?", nullString(*triage.Note))
+	}
+	if triage.Acknowledged != nil {
+		if *triage.Acknowledged {
+			// acknowledging again keeps the first acknowledgment
+			query = query.Set("acknowledged_at = COALESCE(acknowledged_at, ?)", time.Now()).
+				Set("acknowledged_by = COALESCE(acknowledged_by, ?)", nullString(triage.By))
+		} else {
+			query = query.Set("acknowledged_at = NULL").Set("acknowledged_by = NULL")
+		}
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+	if message.ID == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return message, nil
+}
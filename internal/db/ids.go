@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// IDStrategy is how the public IDs of new messages are generated. The bigint primary key stays the internal
+// ID, a public ID hides the volume of messages from external clients a sequential ID would reveal.
+type IDStrategy string
+
+const (
+	// IDStrategyBigint gives messages no public ID, they are only known by their bigint ID
+	IDStrategyBigint IDStrategy = "bigint"
+	// IDStrategyULID gives messages a ULID, sortable by creation time
+	IDStrategyULID IDStrategy = "ulid"
+	// IDStrategyUUID gives messages a version 7 UUID, sortable by creation time
+	IDStrategyUUID IDStrategy = "uuid"
+)
+
+// NewPublicID returns a public ID by strategy, empty for IDStrategyBigint
+func NewPublicID(strategy IDStrategy) string {
+	switch strategy {
+	case IDStrategyULID:
+		return newULID(time.Now())
+	case IDStrategyUUID:
+		return uuid.Must(uuid.NewV7()).String()
+	default:
+		return ""
+	}
+}
+
+// crockford is the base32 alphabet of ULIDs, without I, L, O and U
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID of t: 48 bits of milliseconds followed by 80 random bits, in 26 Crockford base32 characters
+func newULID(t time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(t.UnixMilli())<<16)
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits are 26 characters of 5 bits, the first one only carries the top 3 bits
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var encoded [26]byte
+	for i := 25; i >= 0; i-- {
+		encoded[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(encoded[:])
+}
+
+// ParsePublicID returns the canonical form of id when it is a ULID or a UUID: ULIDs upper case, UUIDs lower case
+func ParsePublicID(id string) (string, bool) {
+	if len(id) == 26 {
+		id = strings.ToUpper(id)
+		// the first character carries 3 bits, a larger one overflows 128 bits
+		if id[0] > '7' || strings.Trim(id, crockford) != "" {
+			return "", false
+		}
+		return id, true
+	}
+	if parsed, err := uuid.Parse(id); err == nil && len(id) == 36 {
+		return parsed.String(), true
+	}
+	return "", false
+}
+
+// GetMessageIDByPublicID returns the bigint ID of the message with the public ID id
+func GetMessageIDByPublicID(ctx context.Context, db bun.IDB, id string) (int64, error) {
+	var messageID int64
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		Column("id").
+		Where("public_id = ?", id).
+		Scan(ctx, &messageID)
+	return messageID, err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Ingestion job statuses
+const (
+	IngestionStatusQueued    = "queued"
+	IngestionStatusRunning   = "running"
+	IngestionStatusSucceeded = "succeeded"
+	IngestionStatusFailed    = "failed"
+)
+
+// MaxIngestionRejections bounds the rejected rows kept with an ingestion job, the counts cover every row
+const MaxIngestionRejections = 100
+
+// IngestionRejection is a row of an ingestion job that was not enqueued. The recipient and the content are
+// not kept, the line identifies the row in the submitted payload.
+type IngestionRejection struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// IngestionJob is a payload of messages submitted for asynchronous validation and enqueueing
+type IngestionJob struct {
+	bun.BaseModel `bun:"table:ingestion_jobs"`
+
+	// ID is a ULID, returned when the payload is accepted
+	ID     string `bun:"id,pk" json:"id"`
+	Status string `bun:"status,notnull" json:"status"`
+	// Holder is the instance processing the job, APIKey the key it was submitted with
+	Holder     string `bun:"holder,notnull" json:"holder"`
+	APIKey     string `bun:"api_key,nullzero" json:"api_key,omitempty"`
+	Total      int    `bun:"total,notnull" json:"total"`
+	Read       int    `bun:"read,notnull,default:0" json:"read"`
+	Enqueued   int    `bun:"enqueued,notnull,default:0" json:"enqueued"`
+	Duplicates int    `bun:"duplicates,notnull,default:0" json:"duplicates"`
+	Rejected   int    `bun:"rejected,notnull,default:0" json:"rejected"`
+	// Rejections are the first MaxIngestionRejections rejected rows
+	Rejections []IngestionRejection `bun:"rejections,type:jsonb,nullzero" json:"rejections,omitempty"`
+	// Error is why a failed job stopped, the rows enqueued before stay enqueued
+	Error      string     `bun:"error,nullzero" json:"error,omitempty"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	StartedAt  *time.Time `bun:"started_at,nullzero" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+}
+
+// CreateIngestionJob stores a new queued job with a new ULID
+func CreateIngestionJob(ctx context.Context, db bun.IDB, job *IngestionJob) error {
+	job.CreatedAt = time.Now()
+	job.ID = newULID(job.CreatedAt)
+	job.Status = IngestionStatusQueued
+	_, err := db.NewInsert().Model(job).Exec(ctx)
+	return err
+}
+
+// UpdateIngestionJob stores the status, counts, rejections, error and times of job
+func UpdateIngestionJob(ctx context.Context, db bun.IDB, job *IngestionJob) error {
+	_, err := db.NewUpdate().
+		Model(job).
+		Column("status", "read", "enqueued", "duplicates", "rejected", "rejections", "error", "started_at", "finished_at").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+// GetIngestionJob returns the job with id.
+// Returns sql.ErrNoRows if there is none.
+func GetIngestionJob(ctx context.Context, db bun.IDB, id string) (*IngestionJob, error) {
+	job := new(IngestionJob)
+	err := db.NewSelect().
+		Model(job).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
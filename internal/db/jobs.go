@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Job run statuses, background jobs start queued
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// JobRun is the last run of a maintenance job, shared by every instance so the one that ran it last is known
+type JobRun struct {
+	bun.BaseModel `bun:"table:maintenance_jobs"`
+
+	Name string `bun:"name,pk" json:"name"`
+	// Status is running, succeeded or failed
+	Status string `bun:"status,notnull" json:"status"`
+	// Result summarizes what a successful run did, Error is why a failed one failed
+	Result     string     `bun:"result,nullzero" json:"result,omitempty"`
+	Error      string     `bun:"error,nullzero" json:"error,omitempty"`
+	Holder     string     `bun:"holder,notnull" json:"holder"`
+	StartedAt  time.Time  `bun:"started_at,notnull" json:"started_at"`
+	FinishedAt *time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+	Runs       int64      `bun:"runs,notnull,default:0" json:"runs"`
+	Failures   int64      `bun:"failures,notnull,default:0" json:"failures"`
+}
+
+// StartJobRun records that holder started a run of the job called name
+func StartJobRun(ctx context.Context, db bun.IDB, name, holder string, startedAt time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO maintenance_jobs (name, status, holder, started_at, runs, failures) VALUES (?, ?, ?, ?, 0, 0)
+		ON CONFLICT (name) DO UPDATE SET status = EXCLUDED.status, holder = EXCLUDED.holder,
+			started_at = EXCLUDED.started_at, finished_at = NULL, result = NULL, error = NULL`,
+		name, JobStatusRunning, holder, startedAt.UTC())
+	return err
+}
+
+// FinishJobRun records the end of the run of the job called name, failed when runErr is set
+func FinishJobRun(ctx context.Context, db bun.IDB, name, result string, runErr error) error {
+	query := db.NewUpdate().
+		Model((*JobRun)(nil)).
+		Set("finished_at = ?", time.Now().UTC()).
+		Set("runs = runs + 1").
+		Where("name = ?", name)
+	if runErr != nil {
+		query = query.Set("status = ?", JobStatusFailed).Set("error = ?", runErr.Error()).Set("failures = failures + 1")
+	} else {
+		query = query.Set("status = ?", JobStatusSucceeded).Set("result = ?", result)
+	}
+	_, err := query.Exec(ctx)
+	return err
+}
+
+// ListJobRuns returns the last run of every job that ran, by name
+func ListJobRuns(ctx context.Context, db bun.IDB) ([]*JobRun, error) {
+	var runs []*JobRun
+	err := db.NewSelect().Model(&runs).Order("name ASC").Scan(ctx)
+	return runs, err
+}
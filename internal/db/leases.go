@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// SchedulerLeaseName is the lease the scheduler holding the leadership owns
+	SchedulerLeaseName = "scheduler"
+	// ReportLeaseName is taken by the instance sending the daily report, so it is sent once
+	ReportLeaseName = "daily_report"
+)
+
+// Lease is held by one holder until it expires or is released
+type Lease struct {
+	bun.BaseModel `bun:"table:leases"`
+
+	Name      string    `bun:"name,pk" json:"name"`
+	Holder    string    `bun:"holder,notnull" json:"holder"`
+	ExpiresAt time.Time `bun:"expires_at,notnull" json:"expires_at"`
+}
+
+// AcquireLease takes the lease for holder until ttl from now, or renews it when holder already holds it.
+// It returns false when another holder holds a lease that has not expired.
+func AcquireLease(ctx context.Context, db bun.IDB, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at < ?`,
+		name, holder, now.Add(ttl), now)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReleaseLease gives up the lease when holder holds it, so the next AcquireLease of another holder succeeds
+func ReleaseLease(ctx context.Context, db bun.IDB, name, holder string) error {
+	_, err := db.NewDelete().
+		Model((*Lease)(nil)).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Link is a tracked short link that replaced a URL in the content of a message
+type Link struct {
+	bun.BaseModel `bun:"table:links"`
+
+	Code string `bun:"code,pk" json:"code"`
+	// MessageID is set once the message containing the link is stored
+	MessageID     *int64     `bun:"message_id" json:"message_id,omitempty"`
+	URL           string     `bun:"url,notnull" json:"url"`
+	Clicks        int64      `bun:"clicks,notnull,default:0" json:"clicks"`
+	LastClickedAt *time.Time `bun:"last_clicked_at" json:"last_clicked_at,omitempty"`
+	CreatedAt     time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// CampaignClicks are the click counts of the tracked links in the messages of a campaign
+type CampaignClicks struct {
+	Campaign string `bun:"campaign"`
+	// Messages is the number of messages with at least one tracked link
+	Messages int64 `bun:"messages"`
+	Links    int64 `bun:"links"`
+	Clicks   int64 `bun:"clicks"`
+	// ClickedMessages is the number of messages with at least one clicked link
+	ClickedMessages int64 `bun:"clicked_messages"`
+}
+
+// CreateLinks stores new links, their message is attached with AttachLinks once it is stored
+func CreateLinks(ctx context.Context, db bun.IDB, links []*Link) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, link := range links {
+		link.CreatedAt = now
+	}
+
+	_, err := db.NewInsert().Model(&links).Exec(ctx)
+	return err
+}
+
+// AttachLinks sets the message of the links with the given codes
+func AttachLinks(ctx context.Context, db bun.IDB, messageID int64, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	_, err := db.NewUpdate().
+		Model((*Link)(nil)).
+		Set("message_id = ?", messageID).
+		Where("code IN (?)", bun.In(codes)).
+		Exec(ctx)
+	return err
+}
+
+// RecordClick counts a click on the link with code and returns the link.
+// Returns sql.ErrNoRows if no link has the code.
+func RecordClick(ctx context.Context, db bun.IDB, code string) (*Link, error) {
+	link := new(Link)
+	err := db.NewUpdate().
+		Model(link).
+		Set("clicks = clicks + 1").
+		Set("last_clicked_at = ?", time.Now()).
+		Where("code = ?", code).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// GetMessageLinks returns the links of a message in the order they were created
+func GetMessageLinks(ctx context.Context, db bun.IDB, messageID int64) ([]*Link, error) {
+	var links []*Link
+	err := db.NewSelect().
+		Model(&links).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC", "code ASC").
+		Scan(ctx)
+	return links, err
+}
+
+// GetCampaignClicks returns the click counts per campaign, most clicked first. Messages without a
+// campaign are counted under an empty campaign, campaign limits the result to that campaign when set.
+func GetCampaignClicks(ctx context.Context, db bun.IDB, campaign string) ([]*CampaignClicks, error) {
+	var clicks []*CampaignClicks
+	query := db.NewSelect().
+		TableExpr("links AS l").
+		Join("JOIN messages AS m ON m.id = l.message_id").
+		ColumnExpr("COALESCE(m.campaign, '') AS campaign").
+		ColumnExpr("COUNT(DISTINCT m.id) AS messages").
+		ColumnExpr("COUNT(*) AS links").
+		ColumnExpr("COALESCE(SUM(l.clicks), 0) AS clicks").
+		ColumnExpr("COUNT(DISTINCT CASE WHEN l.clicks > 0 THEN m.id END) AS clicked_messages").
+		GroupExpr("COALESCE(m.campaign, '')").
+		OrderExpr("clicks DESC, campaign ASC")
+	if campaign != "" {
+		query = query.Where("m.campaign = ?", campaign)
+	}
+
+	err := query.Scan(ctx, &clicks)
+	return clicks, err
+}
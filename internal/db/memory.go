@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/extra/bunotel"
+)
+
+// memoryDSN names the in-memory database so every connection of the pool shares it
+const memoryDSN = "file:sendpulse?mode=memory&cache=shared"
+
+// memoryModels are the tables of the in-memory storage
+var memoryModels = []any{
+	(*Message)(nil),
+	(*Suppression)(nil),
+	(*Link)(nil),
+	(*UsageCounter)(nil),
+	(*Lease)(nil),
+	(*Erasure)(nil),
+	(*Control)(nil),
+	(*MessageCount)(nil),
+	(*JobRun)(nil),
+	(*MessageEvent)(nil),
+	(*IngestionJob)(nil),
+	(*WebhookOverride)(nil),
+	(*MessagePayload)(nil),
+	(*SendAttempt)(nil),
+	(*RecipientPause)(nil),
+	(*Campaign)(nil),
+	(*CampaignRecipient)(nil),
+	(*Job)(nil),
+	(*JobResult)(nil),
+}
+
+// ConnectMemory returns a DB kept in memory by SQLite with every table created, so the server runs without
+// a database server for demos and integration tests. The data is gone when the process exits, migrations,
+// backups and the other Postgres only commands do not apply to it.
+func ConnectMemory(ctx context.Context) (*bun.DB, error) {
+	sqldb, err := sql.Open(sqliteshim.ShimName, memoryDSN)
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes the writes, SQLite has one writer, and keeps the database alive
+	sqldb.SetMaxOpenConns(1)
+	sqldb.SetConnMaxLifetime(0)
+	sqldb.SetConnMaxIdleTime(0)
+
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	for _, model := range memoryModels {
+		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	db.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName("memory")))
+	return db, nil
+}
@@ -4,9 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/boratanrikulu/sendpulse/internal/sms"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 type MessageStatus string
@@ -16,55 +24,262 @@ const (
 	MessageStatusSending MessageStatus = "sending"
 	MessageStatusSent    MessageStatus = "sent"
 	MessageStatusFailed  MessageStatus = "failed"
-	MaxMessageLength     int           = 160
+	// MessageStatusBlocked marks messages to suppressed recipients, they are never sent
+	MessageStatusBlocked MessageStatus = "blocked"
+	// MessageStatusAccepted marks messages the webhook accepted while delivery reports are enabled,
+	// a delivery report moves them to sent, delivered or failed
+	MessageStatusAccepted MessageStatus = "accepted"
+	// MessageStatusDelivered marks messages the provider reported as delivered to the handset
+	MessageStatusDelivered MessageStatus = "delivered"
+	// MessageStatusUnconfirmed marks accepted messages no delivery report arrived for in time
+	MessageStatusUnconfirmed MessageStatus = "unconfirmed"
+	// MessageStatusAcceptedWithoutID marks messages the webhook answered with 2xx but without a usable message ID,
+	// e.g. an empty or non-JSON body. The provider may have sent them, but no delivery report can be matched.
+	MessageStatusAcceptedWithoutID MessageStatus = "accepted_without_id"
+	// MessageStatusQuarantined marks messages held back by the content policy, they are only sent once released
+	MessageStatusQuarantined MessageStatus = "quarantined"
+	// MessageStatusCancelled marks pending messages cancelled by an operator, they are never sent
+	MessageStatusCancelled MessageStatus = "cancelled"
+	// MessageStatusExpired marks messages claimed after their expires_at, they are never sent
+	MessageStatusExpired MessageStatus = "expired"
+	MaxLabelLength       int           = 64
+	// MaxSenderIDLength is the longest alphanumeric sender ID, carriers reject or truncate longer ones
+	MaxSenderIDLength int = 11
 )
 
+// SentStatuses are the statuses of messages the webhook accepted, whether or not their delivery was reported
+var SentStatuses = []MessageStatus{MessageStatusAccepted, MessageStatusSent, MessageStatusDelivered, MessageStatusUnconfirmed,
+	MessageStatusAcceptedWithoutID}
+
 var (
-	ErrMessageTooLong = errors.New("message content exceeds maximum length")
+	ErrMessageTooLong     = errors.New("message content exceeds the maximum number of SMS segments")
+	ErrEmptyContent       = errors.New("message content is required")
+	ErrInvalidPhoneNumber = errors.New("recipient must be an E.164 phone number")
+	ErrInvalidLabel       = errors.New("tenant and campaign must be at most 64 letters, digits, '.', '_' or '-'")
+	ErrInvalidTimezone    = errors.New("timezone must be an IANA timezone name like Europe/Istanbul")
+	ErrInvalidSender      = errors.New("from must be an E.164 phone number or a sender ID of at most 11 letters, digits, spaces, '.', '_' or '-'")
+	ErrInvalidExpiry      = errors.New("expires_at must be in the future and after the send time")
+	ErrInvalidCallbackURL = errors.New("callback_url must be an absolute http or https URL")
 )
 
+// phoneNumberPattern mirrors the check_phone_format constraint on the messages table and
+// SuppressionPhoneConstraint on the suppressions table
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// senderIDPattern matches alphanumeric sender IDs and short codes, they start and end with a letter or digit
+var senderIDPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9 ._-]*[A-Za-z0-9])?$`)
+
+// labelPattern restricts tenant and campaign names, they end up in metric labels and URLs
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ValidLabel reports whether label is a valid tenant or campaign name
+func ValidLabel(label string) bool {
+	return len(label) <= MaxLabelLength && labelPattern.MatchString(label)
+}
+
 type Message struct {
 	bun.BaseModel `bun:"table:messages"`
 
-	ID              int64         `bun:"id,pk,autoincrement" json:"id"`
-	To              string        `bun:"to,notnull" json:"to"`
-	Content         string        `bun:"content,notnull" json:"content"`
+	ID int64 `bun:"id,pk,autoincrement" json:"id"`
+	// PublicID is the ULID or UUID of the message by database.id_strategy, empty with the bigint strategy
+	PublicID string `bun:"public_id,unique,nullzero" json:"public_id,omitempty"`
+	To       string `bun:"to,notnull" json:"to"`
+	Content  string `bun:"content,notnull" json:"content"`
+	// RenderedContent is the content sent after the transform pipeline of the message rewrote it when it was
+	// claimed, empty when it was sent as enqueued. Encoding and Segments are the ones of the sent content.
+	RenderedContent string        `bun:"rendered_content,nullzero" json:"rendered_content,omitempty"`
 	Status          MessageStatus `bun:"status,notnull,default:'pending'" json:"status"`
-	SentAt          *time.Time    `bun:"sent_at,nullzero" json:"sent_at,omitempty"`
-	MessageID       *string       `bun:"message_id,nullzero" json:"message_id,omitempty"`
-	WebhookResponse *string       `bun:"webhook_response,type:jsonb,nullzero" json:"webhook_response,omitempty"`
-	CreatedAt       time.Time     `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt       time.Time     `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	Priority        int           `bun:"priority,notnull,default:0" json:"priority"`
+	Tenant          string        `bun:"tenant,nullzero" json:"tenant,omitempty"`
+	Campaign        string        `bun:"campaign,nullzero" json:"campaign,omitempty"`
+	Encoding        sms.Encoding  `bun:"encoding,notnull,default:'gsm7'" json:"encoding"`
+	Segments        int           `bun:"segments,notnull,default:1" json:"segments"`
+	ScheduledAt     *time.Time    `bun:"scheduled_at,nullzero" json:"scheduled_at,omitempty"`
+	// Timezone is the IANA timezone of the recipient, a local send time was converted to ScheduledAt with it
+	Timezone        string     `bun:"timezone,nullzero" json:"timezone,omitempty"`
+	SentAt          *time.Time `bun:"sent_at,nullzero" json:"sent_at,omitempty"`
+	MessageID       *string    `bun:"message_id,nullzero" json:"message_id,omitempty"`
+	WebhookResponse *string    `bun:"webhook_response,type:jsonb,nullzero" json:"webhook_response,omitempty"`
+	DeliveredAt     *time.Time `bun:"delivered_at,nullzero" json:"delivered_at,omitempty"`
+	DeliveryError   string     `bun:"delivery_error,nullzero" json:"delivery_error,omitempty"`
+	// PolicyViolations are the content policy rules the message violated without being rejected
+	PolicyViolations []string `bun:"policy_violations,type:jsonb,nullzero" json:"policy_violations,omitempty"`
+	// From is the sender ID or number the message is sent from instead of the sender ID of its route
+	From string `bun:"from,nullzero" json:"from,omitempty"`
+	// Provider, SenderID and UnitPrice are set by the route picked when the message was last claimed, SenderID is
+	// From when it is set
+	Provider  string  `bun:"provider,nullzero" json:"provider,omitempty"`
+	SenderID  string  `bun:"sender_id,nullzero" json:"sender_id,omitempty"`
+	UnitPrice float64 `bun:"unit_price,nullzero" json:"unit_price,omitempty"`
+	// Region is the region.name of the instance that last claimed the message
+	Region string `bun:"region,nullzero" json:"region,omitempty"`
+	// ReplayOf is the ID of the message this one was cloned from by a replay
+	ReplayOf *int64 `bun:"replay_of,nullzero" json:"replay_of,omitempty"`
+	// ReapAfter lets the stuck reaper send a message still sending at a shutdown again once passed, it is
+	// cleared when the message is claimed again
+	ReapAfter *time.Time `bun:"reap_after,nullzero" json:"reap_after,omitempty"`
+	// FailureClass and FailureError are the class and error of the last failed send, see webhook.ClassifyError
+	FailureClass string `bun:"failure_class,nullzero" json:"failure_class,omitempty"`
+	FailureError string `bun:"failure_error,nullzero" json:"failure_error,omitempty"`
+	// Requeues counts the automatic requeues of the transient failures of the message
+	Requeues int `bun:"requeues,notnull,default:0" json:"requeues,omitempty"`
+	// DeadLetteredAt is when the failed message was moved to the dead-letter queue, it is not requeued
+	// automatically anymore
+	DeadLetteredAt *time.Time `bun:"dead_lettered_at,nullzero" json:"dead_lettered_at,omitempty"`
+	// Note is the operator note of a failed message, e.g. what was found investigating it
+	Note string `bun:"note,nullzero" json:"note,omitempty"`
+	// AcknowledgedAt and AcknowledgedBy, the API key name, are set when an operator acknowledged the failure,
+	// they are cleared when the message fails again
+	AcknowledgedAt *time.Time `bun:"acknowledged_at,nullzero" json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `bun:"acknowledged_by,nullzero" json:"acknowledged_by,omitempty"`
+	// ExpiresAt is when the message stops being worth sending, one claimed later is expired instead of sent
+	ExpiresAt *time.Time `bun:"expires_at,nullzero" json:"expires_at,omitempty"`
+	// CallbackURL is where the status notices of the message are posted to when it expires, is blocked or is
+	// dead-lettered
+	CallbackURL string `bun:"callback_url,nullzero" json:"callback_url,omitempty"`
+	// Metadata are the caller defined fields of the message
+	Metadata  Metadata  `bun:"metadata,type:jsonb,nullzero" json:"metadata,omitempty"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
 }
 
-// CreateMessage inserts a new message into the database
-func CreateMessage(ctx context.Context, db bun.IDB, message *Message) error {
-	if len(message.Content) > MaxMessageLength {
+// Cost returns the cost of the message, 0 until it was sent or when its route has no unit price.
+// Messages the webhook accepted are billed even if the provider reports them failed later.
+func (m *Message) Cost() float64 {
+	if m.SentAt == nil {
+		return 0
+	}
+	return float64(m.Segments) * m.UnitPrice
+}
+
+// ValidatePhone checks that phone is an E.164 phone number
+func ValidatePhone(phone string) error {
+	if !phoneNumberPattern.MatchString(phone) {
+		return ErrInvalidPhoneNumber
+	}
+	return nil
+}
+
+// defaultMaxSegments is the limit of messages validated before SetMaxSegments is called
+const defaultMaxSegments = 10
+
+// maxSegments is set once at startup by SetMaxSegments, before any message is validated
+var maxSegments = defaultMaxSegments
+
+// SetMaxSegments sets the most SMS segments the content of a message may take, in the encoding it is sent with.
+// 0 restores the default of 10.
+func SetMaxSegments(segments int) {
+	if segments <= 0 {
+		segments = defaultMaxSegments
+	}
+	maxSegments = segments
+}
+
+// ValidateMessage checks the recipient and content of a message before it is stored
+func ValidateMessage(message *Message) error {
+	if err := ValidatePhone(message.To); err != nil {
+		return err
+	}
+	if message.Content == "" {
+		return ErrEmptyContent
+	}
+	if _, segments, _ := sms.Encode(message.Content); segments > maxSegments {
 		return ErrMessageTooLong
 	}
+	for _, label := range []string{message.Tenant, message.Campaign} {
+		if label != "" && !ValidLabel(label) {
+			return ErrInvalidLabel
+		}
+	}
+	if message.From != "" && !phoneNumberPattern.MatchString(message.From) &&
+		(len(message.From) > MaxSenderIDLength || !senderIDPattern.MatchString(message.From)) {
+		return ErrInvalidSender
+	}
+	if message.Timezone != "" {
+		// Local would load as UTC, and the name is stored for the recipient
+		if _, err := time.LoadLocation(message.Timezone); err != nil || message.Timezone == "Local" || len(message.Timezone) > MaxLabelLength {
+			return ErrInvalidTimezone
+		}
+	}
+	if message.ExpiresAt != nil && (!message.ExpiresAt.After(time.Now()) ||
+		(message.ScheduledAt != nil && !message.ExpiresAt.After(*message.ScheduledAt))) {
+		return ErrInvalidExpiry
+	}
+	if message.CallbackURL != "" {
+		if u, err := url.Parse(message.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidCallbackURL
+		}
+	}
+	return message.Metadata.normalize()
+}
+
+// CreateMessage inserts a new message into the database, blocked when the recipient is suppressed.
+// It is inserted as pending unless the content policy quarantined it. The encoding and segment count
+// are derived from the content, the public ID is the one given, see NewPublicID.
+func CreateMessage(ctx context.Context, db bun.IDB, message *Message) error {
+	if err := ValidateMessage(message); err != nil {
+		return err
+	}
 
 	message.CreatedAt = time.Now()
 	message.UpdatedAt = time.Now()
-	message.Status = MessageStatusPending
+	if message.Status != MessageStatusQuarantined {
+		message.Status = MessageStatusPending
+	}
+	message.Encoding, message.Segments, _ = sms.Encode(message.Content)
+	if err := blockSuppressed(ctx, db, []*Message{message}); err != nil {
+		return err
+	}
 
 	_, err := db.NewInsert().Model(message).Exec(ctx)
 	return err
 }
 
-// ClaimNextMessage atomically claims the next available message for processing
-func ClaimNextMessage(ctx context.Context, db bun.IDB) (*Message, error) {
+// CreateMessages inserts multiple messages with a single statement
+// All messages are validated first, nothing is inserted if any of them is invalid.
+// Messages to suppressed recipients are inserted as blocked, see CreateMessage.
+func CreateMessages(ctx context.Context, db bun.IDB, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, message := range messages {
+		if err := ValidateMessage(message); err != nil {
+			return err
+		}
+		message.CreatedAt = now
+		message.UpdatedAt = now
+		if message.Status != MessageStatusQuarantined {
+			message.Status = MessageStatusPending
+		}
+		message.Encoding, message.Segments, _ = sms.Encode(message.Content)
+	}
+	if err := blockSuppressed(ctx, db, messages); err != nil {
+		return err
+	}
+
+	_, err := db.NewInsert().Model(&messages).Exec(ctx)
+	return err
+}
+
+// ClaimNextMessage atomically claims the next available message for processing.
+// Messages scheduled for the future are skipped, higher priorities go first, raised by aging while they wait.
+func ClaimNextMessage(ctx context.Context, db bun.IDB, aging PriorityAging) (*Message, error) {
 	message := new(Message)
 	now := time.Now()
 
 	query := `
 		UPDATE messages 
 		SET status = ?, 
-		    updated_at = ?
+		    updated_at = ?,
+		    reap_after = NULL
 		WHERE id = (
 			SELECT id FROM messages 
 			WHERE status = ?
-			ORDER BY created_at ASC 
-			FOR UPDATE SKIP LOCKED 
+			  AND (scheduled_at IS NULL OR scheduled_at <= ?)
+			ORDER BY ?
+			? 
 			LIMIT 1
 		) 
 		RETURNING *`
@@ -72,7 +287,10 @@ func ClaimNextMessage(ctx context.Context, db bun.IDB) (*Message, error) {
 	err := db.NewRaw(query,
 		MessageStatusSending,
 		now,
-		MessageStatusPending).Scan(ctx, message)
+		MessageStatusPending,
+		now,
+		aging.claimOrder(db, now),
+		claimLock(db)).Scan(ctx, message)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -88,13 +306,77 @@ func ClaimNextMessage(ctx context.Context, db bun.IDB) (*Message, error) {
 	return message, nil
 }
 
-// UpdateMessageStatus updates the status of a message and optionally sets sent_at and message_id
+// claimLock keeps concurrent claims from taking the same message. SQLite has a single writer, its
+// update statements cannot interleave and it has no row locks.
+func claimLock(db bun.IDB) bun.Safe {
+	if db.Dialect().Name() == dialect.SQLite {
+		return ""
+	}
+	return "FOR UPDATE SKIP LOCKED"
+}
+
+// ClaimIndex is the partial index of the pending messages in claim order. A claim reads its first rows instead of
+// sorting every pending message, which took seconds once the table held a few million messages. Claims ordered
+// with priority aging sort by an expression the index only filters for.
+const ClaimIndex = "idx_messages_pending_claim"
+
+// CreateClaimIndex creates ClaimIndex, concurrently on Postgres so the messages can be enqueued and claimed while
+// it is built
+func CreateClaimIndex(ctx context.Context, db bun.IDB) error {
+	concurrently := "CONCURRENTLY "
+	if db.Dialect().Name() == dialect.SQLite {
+		concurrently = ""
+	}
+	_, err := db.ExecContext(ctx, "CREATE INDEX "+concurrently+"IF NOT EXISTS "+ClaimIndex+
+		" ON messages(priority DESC, created_at) WHERE status = 'pending'")
+	return err
+}
+
+// ClaimDueMessages atomically claims up to limit due messages in the same order as ClaimNextMessage
+func ClaimDueMessages(ctx context.Context, db bun.IDB, limit int, aging PriorityAging) ([]*Message, error) {
+	var messages []*Message
+	now := time.Now()
+
+	query := `
+		UPDATE messages
+		SET status = ?,
+		    updated_at = ?,
+		    reap_after = NULL
+		WHERE id IN (
+			SELECT id FROM messages
+			WHERE status = ?
+			  AND (scheduled_at IS NULL OR scheduled_at <= ?)
+			ORDER BY ?
+			?
+			LIMIT ?
+		)
+		RETURNING *`
+
+	err := db.NewRaw(query,
+		MessageStatusSending,
+		now,
+		MessageStatusPending,
+		now,
+		aging.claimOrder(db, now),
+		claimLock(db),
+		limit).Scan(ctx, &messages)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// UpdateMessageStatus settles a claimed message. It is only updated while it is sending, so a message processed
+// twice or changed by hand meanwhile is never moved back, e.g. from sent to failed; a *StaleStatusError is
+// returned then.
 func UpdateMessageStatus(ctx context.Context, db bun.IDB, messageID int64, status MessageStatus, sentAt *time.Time, webhookMessageID *string, webhookResponse *string) error {
 	query := db.NewUpdate().
 		Model(&Message{}).
 		Set("status = ?", status).
 		Set("updated_at = ?", time.Now()).
-		Where("id = ?", messageID)
+		Where("id = ?", messageID).
+		Where("status = ?", MessageStatusSending)
 
 	if sentAt != nil {
 		query = query.Set("sent_at = ?", *sentAt)
@@ -108,17 +390,59 @@ func UpdateMessageStatus(ctx context.Context, db bun.IDB, messageID int64, statu
 		query = query.Set("webhook_response = ?", *webhookResponse)
 	}
 
-	_, err := query.Exec(ctx)
-	return err
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return settled(ctx, db, res, messageID, status)
+}
+
+// ErrNotClaimed is matched by a *StaleStatusError
+var ErrNotClaimed = errors.New("message is no longer sending")
+
+// StaleStatusError is a status update of a claimed message that found it no longer sending
+type StaleStatusError struct {
+	ID int64
+	// Status is the status the message was to be moved to
+	Status MessageStatus
+	// Current is the status it was found in, empty when it no longer exists
+	Current MessageStatus
+}
+
+func (e *StaleStatusError) Error() string {
+	current := string(e.Current)
+	if current == "" {
+		current = "deleted"
+	}
+	return fmt.Sprintf("message %d is %s instead of sending, not moving it to %s", e.ID, current, e.Status)
+}
+
+func (e *StaleStatusError) Unwrap() error {
+	return ErrNotClaimed
+}
+
+// settled returns a *StaleStatusError when the update of a claimed message to status changed no row
+func settled(ctx context.Context, db bun.IDB, res sql.Result, messageID int64, status MessageStatus) error {
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	stale := &StaleStatusError{ID: messageID, Status: status}
+	err = db.NewSelect().Model((*Message)(nil)).Column("status").Where("id = ?", messageID).Scan(ctx, &stale.Current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return stale
 }
 
-// GetSentMessages retrieves all sent messages with pagination
+// GetSentMessages retrieves all sent messages with pagination, see SentStatuses
 func GetSentMessages(ctx context.Context, db bun.IDB, limit, offset int) ([]*Message, error) {
 	var messages []*Message
 
 	err := db.NewSelect().
 		Model(&messages).
-		Where("status = ?", MessageStatusSent).
+		Where("status IN (?)", bun.In(SentStatuses)).
 		Order("sent_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -127,6 +451,421 @@ func GetSentMessages(ctx context.Context, db bun.IDB, limit, offset int) ([]*Mes
 	return messages, err
 }
 
+// MessageFilter narrows down message queries, zero values are ignored
+type MessageFilter struct {
+	Status MessageStatus
+	From   *time.Time
+	To     *time.Time
+	// Tag matches messages with the tag in their metadata
+	Tag string
+	// Metadata matches messages with all of these metadata values
+	Metadata map[string]string
+	// DeadLettered matches the messages of the dead-letter queue only
+	DeadLettered bool
+	// Acknowledged matches the messages whose failure was acknowledged when true, the others when false
+	Acknowledged *bool
+}
+
+// IsZero reports whether the filter matches every message
+func (f MessageFilter) IsZero() bool {
+	return f.Status == "" && f.From == nil && f.To == nil && f.Tag == "" && len(f.Metadata) == 0 && !f.DeadLettered &&
+		f.Acknowledged == nil
+}
+
+// Key identifies the messages the filter matches, equal filters have equal keys
+func (f MessageFilter) Key() string {
+	var key strings.Builder
+	key.WriteString(string(f.Status))
+	for _, t := range []*time.Time{f.From, f.To} {
+		key.WriteByte('|')
+		if t != nil {
+			key.WriteString(t.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	key.WriteString("|" + f.Tag)
+	if f.DeadLettered {
+		key.WriteString("|dead-lettered")
+	}
+	if f.Acknowledged != nil {
+		fmt.Fprintf(&key, "|acknowledged=%t", *f.Acknowledged)
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Metadata)) {
+		fmt.Fprintf(&key, "|%s=%s", name, f.Metadata[name])
+	}
+	return key.String()
+}
+
+// apply adds the filter conditions to a select, update or delete query
+func (f MessageFilter) apply(query bun.QueryBuilder) bun.QueryBuilder {
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.From != nil {
+		query = query.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("created_at < ?", *f.To)
+	}
+	if f.DeadLettered {
+		query = query.Where("dead_lettered_at IS NOT NULL")
+	}
+	if f.Acknowledged != nil {
+		if *f.Acknowledged {
+			query = query.Where("acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("acknowledged_at IS NULL")
+		}
+	}
+	return f.applyMetadata(query)
+}
+
+// MessageCursor is the position of a message in the creation order of ListMessages
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// MessagePage selects the messages ListMessages returns, newest first unless Ascending. With After the page
+// continues after that message and Offset is ignored, so rows created meanwhile do not shift the pages.
+type MessagePage struct {
+	Limit     int
+	Offset    int
+	After     *MessageCursor
+	Ascending bool
+}
+
+// ListMessages retrieves a page of the messages matching the filter
+func ListMessages(ctx context.Context, db bun.IDB, filter MessageFilter, page MessagePage) ([]*Message, error) {
+	var messages []*Message
+
+	query := db.NewSelect().
+		Model(&messages).
+		ApplyQueryBuilder(filter.apply).
+		Limit(page.Limit)
+	direction, after := "DESC", "<"
+	if page.Ascending {
+		direction, after = "ASC", ">"
+	}
+	if page.After != nil {
+		query = query.Where("created_at "+after+" ? OR (created_at = ? AND id "+after+" ?)",
+			page.After.CreatedAt, page.After.CreatedAt, page.After.ID)
+	} else {
+		query = query.Offset(page.Offset)
+	}
+
+	err := query.
+		Order("created_at "+direction, "id "+direction).
+		Scan(ctx)
+
+	return messages, err
+}
+
+// CountMessages returns the number of messages matching the filter
+func CountMessages(ctx context.Context, db bun.IDB, filter MessageFilter) (int, error) {
+	return db.NewSelect().
+		Model(&Message{}).
+		ApplyQueryBuilder(filter.apply).
+		Count(ctx)
+}
+
+// ForEachMessage walks all messages matching the filter in ID order, fetching batchSize rows at a time
+// Iteration stops at the first error returned by fn
+func ForEachMessage(ctx context.Context, db bun.IDB, filter MessageFilter, batchSize int, fn func(*Message) error) error {
+	return ForEachMessagePage(ctx, db, filter, batchSize, func(messages []*Message) error {
+		for _, message := range messages {
+			if err := fn(message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachMessagePage calls fn with every page of up to batchSize messages matching the filter in ID order, the
+// pages are read with keyset pagination so messages created meanwhile do not shift them
+func ForEachMessagePage(ctx context.Context, db bun.IDB, filter MessageFilter, batchSize int, fn func([]*Message) error) error {
+	var lastID int64
+	for {
+		var messages []*Message
+		err := db.NewSelect().
+			Model(&messages).
+			ApplyQueryBuilder(filter.apply).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(messages) > 0 {
+			if err := fn(messages); err != nil {
+				return err
+			}
+		}
+
+		if len(messages) < batchSize {
+			return nil
+		}
+		lastID = messages[len(messages)-1].ID
+	}
+}
+
+// RequeueMessage moves a single failed message back to pending, out of the dead-letter queue with a fresh budget
+// of automatic requeues
+// Returns sql.ErrNoRows if the message does not exist or is not failed
+func RequeueMessage(ctx context.Context, db bun.IDB, id int64) error {
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("updated_at = ?", time.Now()).
+		Set("requeues = 0").
+		Set("dead_lettered_at = NULL").
+		Where("id = ?", id).
+		Where("status = ?", MessageStatusFailed).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetMessageRoute stores the provider, sender ID and segment price a claimed message is sent with and the region
+// sending it
+func SetMessageRoute(ctx context.Context, db bun.IDB, id int64, provider, senderID, region string, unitPrice float64) error {
+	_, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("provider = ?", provider).
+		Set("sender_id = NULLIF(?, '')", senderID).
+		Set("region = NULLIF(?, '')", region).
+		Set("unit_price = NULLIF(?, 0)", unitPrice).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// SetRenderedContent stores the content a claimed message is sent with after its transform pipeline and the
+// encoding and segments it is sent and billed as
+func SetRenderedContent(ctx context.Context, db bun.IDB, id int64, content string) error {
+	encoding, segments, _ := sms.Encode(content)
+	_, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("rendered_content = ?", content).
+		Set("encoding = ?", encoding).
+		Set("segments = ?", segments).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// PrioritizeMessage moves a pending message to the front of the queue: its priority is raised above every
+// other pending message and a scheduled send time is cleared so it is claimed next.
+// Returns sql.ErrNoRows if the message does not exist or is not pending
+func PrioritizeMessage(ctx context.Context, db bun.IDB, id int64) (*Message, error) {
+	message := new(Message)
+	query := `
+		UPDATE messages
+		SET priority = COALESCE((
+		        SELECT MAX(other.priority) + 1 FROM messages AS other
+		        WHERE other.status = ? AND other.id <> messages.id AND other.priority >= messages.priority
+		    ), priority),
+		    scheduled_at = NULL,
+		    updated_at = ?
+		WHERE id = ? AND status = ?
+		RETURNING *`
+
+	err := db.NewRaw(query, MessageStatusPending, time.Now(), id, MessageStatusPending).Scan(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	if message.ID == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return message, nil
+}
+
+// ResetStuckMessages moves the messages sending since before back to pending and returns how many it moved,
+// e.g. claimed by an instance that crashed before settling them. Messages whose ReapAfter passed are moved
+// too, they were still sending when their instance shut down.
+func ResetStuckMessages(ctx context.Context, db bun.IDB, before time.Time) (int, error) {
+	now := time.Now()
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("reap_after = NULL").
+		Set("updated_at = ?", now).
+		Where("status = ?", MessageStatusSending).
+		WhereGroup(" AND ", func(q *bun.UpdateQuery) *bun.UpdateQuery {
+			return q.Where("updated_at < ?", before).WhereOr("reap_after <= ?", now)
+		}).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// SetReapAfter sets the ReapAfter of the messages of ids still sending to at and returns how many it set
+func SetReapAfter(ctx context.Context, db bun.IDB, ids []int64, at time.Time) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("reap_after = ?", at).
+		Where("id IN (?)", bun.In(ids)).
+		Where("status = ?", MessageStatusSending).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// DeferMessage puts a claimed message back to pending, it is not claimed before until. Like UpdateMessageStatus
+// it returns a *StaleStatusError when the message is no longer sending.
+func DeferMessage(ctx context.Context, db bun.IDB, id int64, until time.Time) error {
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("scheduled_at = ?", until).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status = ?", MessageStatusSending).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return settled(ctx, db, res, id, MessageStatusPending)
+}
+
+// ReleaseMessage moves a quarantined message to pending so it is sent
+// Returns sql.ErrNoRows if the message does not exist or is not quarantined
+func ReleaseMessage(ctx context.Context, db bun.IDB, id int64) error {
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status = ?", MessageStatusQuarantined).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetMessagesByIDs retrieves the messages with ids, missing ones are left out
+func GetMessagesByIDs(ctx context.Context, db bun.IDB, ids []int64) ([]*Message, error) {
+	var messages []*Message
+	err := db.NewSelect().
+		Model(&messages).
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	return messages, err
+}
+
+// TransitionMessages moves the messages with ids that are in status from to status to and returns the IDs it moved
+func TransitionMessages(ctx context.Context, db bun.IDB, ids []int64, from, to MessageStatus) ([]int64, error) {
+	var moved []int64
+	err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Set("status = ?", to).
+		Set("updated_at = ?", time.Now()).
+		Where("id IN (?)", bun.In(ids)).
+		Where("status = ?", from).
+		Returning("id").
+		Scan(ctx, &moved)
+	return moved, err
+}
+
+// RequeueFailedMessages moves all failed messages matching the filter back to pending like RequeueMessage
+// The status of the filter is ignored, only failed messages are requeued
+func RequeueFailedMessages(ctx context.Context, db bun.IDB, filter MessageFilter) (int, error) {
+	filter.Status = MessageStatusFailed
+
+	res, err := db.NewUpdate().
+		Model(&Message{}).
+		Set("status = ?", MessageStatusPending).
+		Set("updated_at = ?", time.Now()).
+		Set("requeues = 0").
+		Set("dead_lettered_at = NULL").
+		ApplyQueryBuilder(filter.apply).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// DeleteMessagesByID deletes the messages with the given IDs and returns how many were deleted
+func DeleteMessagesByID(ctx context.Context, db bun.IDB, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	res, err := db.NewDelete().
+		Model(&Message{}).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// RestoreMessages inserts archived messages as they were, keeping their IDs, statuses and timestamps.
+// Messages whose ID or public ID exists already are skipped, it returns how many were inserted.
+func RestoreMessages(ctx context.Context, db bun.IDB, messages []*Message) (int, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	res, err := db.NewInsert().
+		Model(&messages).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// IsValid reports whether the status is one of the known message statuses
+func (s MessageStatus) IsValid() bool {
+	switch s {
+	case MessageStatusPending, MessageStatusSending, MessageStatusSent, MessageStatusFailed, MessageStatusBlocked,
+		MessageStatusAccepted, MessageStatusDelivered, MessageStatusUnconfirmed, MessageStatusQuarantined, MessageStatusCancelled,
+		MessageStatusExpired, MessageStatusAcceptedWithoutID:
+		return true
+	}
+	return false
+}
+
 // GetMessageByID retrieves a single message by its ID
 func GetMessageByID(ctx context.Context, db bun.IDB, id int64) (*Message, error) {
 	message := &Message{}
@@ -139,11 +878,80 @@ func GetMessageByID(ctx context.Context, db bun.IDB, id int64) (*Message, error)
 	return message, err
 }
 
-// GetTotalSentMessagesCount returns the total count of sent messages
+// CountMessagesByStatus returns the number of messages per status, statuses without messages are omitted
+func CountMessagesByStatus(ctx context.Context, db bun.IDB) (map[MessageStatus]int, error) {
+	var rows []struct {
+		Status MessageStatus `bun:"status"`
+		Count  int           `bun:"count"`
+	}
+
+	err := db.NewSelect().
+		Model(&Message{}).
+		Column("status").
+		ColumnExpr("COUNT(*) AS count").
+		Group("status").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[MessageStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// CountSentSince returns the number of messages sent at or after since, see SentStatuses
+func CountSentSince(ctx context.Context, db bun.IDB, since time.Time) (int, error) {
+	return db.NewSelect().
+		Model(&Message{}).
+		Where("status IN (?)", bun.In(SentStatuses)).
+		Where("sent_at >= ?", since).
+		Count(ctx)
+}
+
+// CountFailedSince returns the number of messages that failed at or after since
+func CountFailedSince(ctx context.Context, db bun.IDB, since time.Time) (int, error) {
+	return db.NewSelect().
+		Model(&Message{}).
+		Where("status = ?", MessageStatusFailed).
+		Where("updated_at >= ?", since).
+		Count(ctx)
+}
+
+// CountPendingMessages returns the number of messages waiting to be sent
+func CountPendingMessages(ctx context.Context, db bun.IDB) (int, error) {
+	return db.NewSelect().
+		Model(&Message{}).
+		Where("status = ?", MessageStatusPending).
+		Count(ctx)
+}
+
+// GetOldestPendingMessage returns the pending message waiting the longest, nil if there is none
+func GetOldestPendingMessage(ctx context.Context, db bun.IDB) (*Message, error) {
+	message := new(Message)
+
+	err := db.NewSelect().
+		Model(message).
+		Where("status = ?", MessageStatusPending).
+		Order("created_at ASC", "id ASC").
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// GetTotalSentMessagesCount returns the total count of sent messages, see SentStatuses
 func GetTotalSentMessagesCount(ctx context.Context, db bun.IDB) (int, error) {
 	count, err := db.NewSelect().
 		Model(&Message{}).
-		Where("status = ?", MessageStatusSent).
+		Where("status IN (?)", bun.In(SentStatuses)).
 		Count(ctx)
 
 	return count, err
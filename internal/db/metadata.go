@@ -0,0 +1,122 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// MetadataTags is the metadata key holding the tags of a message, the other values are strings
+const MetadataTags = "tags"
+
+// Metadata limits, metadata is returned with every message so it stays small
+const (
+	MaxMetadataKeys        = 20
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 256
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata are caller defined fields like order_id or customer_id to correlate a message with their own
+// entities. Values are strings, MetadataTags is a list of strings.
+type Metadata map[string]any
+
+// Tags returns the tags of the metadata
+func (m Metadata) Tags() []string {
+	tags, _ := metadataTags(m[MetadataTags])
+	return tags
+}
+
+// normalize validates the metadata and converts decoded tags to a []string
+func (m Metadata) normalize() error {
+	if len(m) > MaxMetadataKeys {
+		return fmt.Errorf("%w: at most %d keys", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+
+	for key, value := range m {
+		if key == "" || len(key) > MaxMetadataKeyLength || !labelPattern.MatchString(key) {
+			return fmt.Errorf("%w: key %q must be at most %d letters, digits, '.', '_' or '-'", ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+
+		if key == MetadataTags {
+			tags, err := metadataTags(value)
+			if err != nil {
+				return err
+			}
+			m[key] = tags
+			continue
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: value of %q must be a string", ErrInvalidMetadata, key)
+		}
+		if len(text) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q exceeds %d characters", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+func metadataTags(value any) ([]string, error) {
+	var tags []string
+	switch value := value.(type) {
+	case []string:
+		tags = value
+	case []any:
+		for _, tag := range value {
+			text, ok := tag.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: tags must be strings", ErrInvalidMetadata)
+			}
+			tags = append(tags, text)
+		}
+	default:
+		return nil, fmt.Errorf("%w: tags must be a list of strings", ErrInvalidMetadata)
+	}
+
+	if len(tags) > MaxMetadataKeys {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+	for _, tag := range tags {
+		if tag == "" || len(tag) > MaxLabelLength || !labelPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: tag %q must be at most %d letters, digits, '.', '_' or '-'", ErrInvalidMetadata, tag, MaxLabelLength)
+		}
+	}
+	return tags, nil
+}
+
+// applyMetadata adds the tag and metadata conditions of the filter. Postgres matches them with a single
+// containment check the GIN index on metadata serves.
+func (f MessageFilter) applyMetadata(query bun.QueryBuilder) bun.QueryBuilder {
+	if f.Tag == "" && len(f.Metadata) == 0 {
+		return query
+	}
+
+	if q, ok := query.Unwrap().(interface{ Dialect() schema.Dialect }); ok && q.Dialect().Name() == dialect.SQLite {
+		for _, key := range slices.Sorted(maps.Keys(f.Metadata)) {
+			query = query.Where("metadata->>? = ?", key, f.Metadata[key])
+		}
+		if f.Tag != "" {
+			query = query.Where("EXISTS (SELECT 1 FROM json_each(metadata, '$.tags') WHERE value = ?)", f.Tag)
+		}
+		return query
+	}
+
+	contains := make(map[string]any, len(f.Metadata)+1)
+	for key, value := range f.Metadata {
+		contains[key] = value
+	}
+	if f.Tag != "" {
+		contains[MetadataTags] = []string{f.Tag}
+	}
+	document, _ := json.Marshal(contains)
+	return query.Where("metadata @> ?::jsonb", string(document))
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_scheduled_at ON messages(scheduled_at)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_scheduled_at"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS scheduled_at"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS priority"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
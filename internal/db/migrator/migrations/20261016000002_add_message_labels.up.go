@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS tenant VARCHAR(64)"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS campaign VARCHAR(64)"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_tenant_campaign ON messages(tenant, campaign)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_tenant_campaign"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS campaign"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS tenant"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
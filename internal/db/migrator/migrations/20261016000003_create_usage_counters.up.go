@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.UsageCounter)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		// Usage is listed per period
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_usage_counters_period ON usage_counters(period, period_start)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.UsageCounter)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
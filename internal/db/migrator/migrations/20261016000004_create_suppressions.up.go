@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.Suppression)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.Suppression)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMPTZ"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_error VARCHAR"); err != nil {
+			return err
+		}
+
+		// Delivery reports look messages up by the message ID the webhook returned
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_message_id ON messages(message_id)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_message_id"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS delivery_error"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS delivered_at"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
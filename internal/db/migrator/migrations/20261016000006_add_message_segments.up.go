@@ -0,0 +1,82 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/uptrace/bun"
+)
+
+// segmentsBackfillBatchSize is the number of existing messages encoded per batch
+const segmentsBackfillBatchSize = 1000
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS encoding VARCHAR(8) NOT NULL DEFAULT 'gsm7'"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS segments INTEGER NOT NULL DEFAULT 1"); err != nil {
+			return err
+		}
+
+		return backfillSegments(ctx, bunDB)
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS segments"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS encoding"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// backfillSegments encodes the existing messages. Most messages of a batch share an encoding and
+// segment count, so they are updated with one statement per distinct pair.
+func backfillSegments(ctx context.Context, bunDB *bun.DB) error {
+	type encoded struct {
+		encoding sms.Encoding
+		segments int
+	}
+	groups := make(map[encoded][]int64)
+	var pending int
+
+	flush := func() error {
+		for key, ids := range groups {
+			_, err := bunDB.NewUpdate().
+				Model((*db.Message)(nil)).
+				Set("encoding = ?", key.encoding).
+				Set("segments = ?", key.segments).
+				Where("id IN (?)", bun.In(ids)).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		clear(groups)
+		pending = 0
+		return nil
+	}
+
+	err := db.ForEachMessage(ctx, bunDB, db.MessageFilter{}, segmentsBackfillBatchSize, func(message *db.Message) error {
+		encoding, segments, _ := sms.Encode(message.Content)
+		if encoding == sms.EncodingGSM7 && segments == 1 {
+			// already the column defaults
+			return nil
+		}
+		key := encoded{encoding: encoding, segments: segments}
+		groups[key] = append(groups[key], message.ID)
+		if pending++; pending >= segmentsBackfillBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS policy_violations JSONB"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS policy_violations"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
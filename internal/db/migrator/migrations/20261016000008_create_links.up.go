@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.Link)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		// Click counts are looked up per message
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_links_message_id ON links(message_id)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.Link)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider VARCHAR"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender_id VARCHAR"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS sender_id"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS provider"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
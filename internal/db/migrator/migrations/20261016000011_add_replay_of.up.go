@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS replay_of BIGINT REFERENCES messages(id) ON DELETE SET NULL"); err != nil {
+			return err
+		}
+
+		// Replays skip the messages that already have a clone
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_replay_of ON messages(replay_of) WHERE replay_of IS NOT NULL"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS replay_of"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
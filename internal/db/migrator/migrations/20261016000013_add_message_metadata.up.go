@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSONB"); err != nil {
+			return err
+		}
+
+		// Tag and metadata filters are containment checks
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_metadata ON messages USING GIN (metadata jsonb_path_ops)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS metadata"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
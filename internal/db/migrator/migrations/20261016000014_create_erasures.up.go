@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.Erasure)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_erasures_phone_hash ON erasures (phone_hash)"); err != nil {
+			return err
+		}
+
+		// erasures look up the messages of a recipient
+		if _, err := bunDB.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_to ON messages ("to")`); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_to"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.NewDropTable().Model((*db.Erasure)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS public_id VARCHAR(36)"); err != nil {
+			return err
+		}
+
+		// messages created with the bigint strategy have no public ID, NULLs do not collide
+		if _, err := bunDB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_public_id ON messages(public_id)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_public_id"); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS public_id"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.MessageEvent)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		// Events are listed per message
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_message_events_message_id ON message_events(message_id)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.MessageEvent)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.Campaign)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		// the held messages of a campaign are counted and deferred by campaign alone
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_pending_campaign ON messages(campaign) WHERE status = 'pending'"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_messages_pending_campaign"); err != nil {
+			return err
+		}
+		if _, err := bunDB.NewDropTable().Model((*db.Campaign)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		// NOT VALID checks new suppressions only, legacy numbers are normalized by `database normalize-phones`
+		// before it validates the constraint
+		if _, err := bunDB.Exec(`ALTER TABLE suppressions ADD CONSTRAINT ` + db.SuppressionPhoneConstraint +
+			` CHECK (phone ~ '^\+[1-9]\d{1,14}$') NOT VALID`); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec(`ALTER TABLE suppressions DROP CONSTRAINT IF EXISTS ` + db.SuppressionPhoneConstraint); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
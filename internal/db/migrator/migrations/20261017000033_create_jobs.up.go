@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewCreateTable().Model((*db.Job)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.NewCreateTable().Model((*db.JobResult)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		// accepting a job deletes the expired ones
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_jobs_expires_at ON jobs(expires_at) WHERE expires_at IS NOT NULL"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.JobResult)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := bunDB.NewDropTable().Model((*db.Job)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
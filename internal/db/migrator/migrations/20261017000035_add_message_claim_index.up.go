@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		return db.CreateClaimIndex(ctx, bunDB)
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS " + db.ClaimIndex); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		// The SLA report of a provider reads its attempts of a window
+		if _, err := bunDB.Exec("CREATE INDEX IF NOT EXISTS idx_send_attempts_provider_started_at ON send_attempts(provider, started_at)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("DROP INDEX IF EXISTS idx_send_attempts_provider_started_at"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		for _, column := range []string{"paused_by VARCHAR", "paused_at TIMESTAMPTZ", "resumed_by VARCHAR", "resumed_at TIMESTAMPTZ"} {
+			if _, err := bunDB.Exec("ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS " + column); err != nil {
+				return err
+			}
+		}
+
+		if _, err := bunDB.NewCreateTable().Model((*db.CampaignRecipient)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.NewDropTable().Model((*db.CampaignRecipient)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+
+		for _, column := range []string{"resumed_at", "resumed_by", "paused_at", "paused_by"} {
+			if _, err := bunDB.Exec("ALTER TABLE campaigns DROP COLUMN IF EXISTS " + column); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE message_payloads ADD COLUMN IF NOT EXISTS encoding VARCHAR(16)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE message_payloads DROP COLUMN IF EXISTS encoding"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ"); err != nil {
+			return err
+		}
+		if _, err := bunDB.Exec("ALTER TABLE messages ADD COLUMN IF NOT EXISTS callback_url TEXT"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS callback_url"); err != nil {
+			return err
+		}
+		if _, err := bunDB.Exec("ALTER TABLE messages DROP COLUMN IF EXISTS expires_at"); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
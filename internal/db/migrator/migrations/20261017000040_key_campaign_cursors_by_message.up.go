@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, bunDB *bun.DB) error {
+		// a campaign may send several messages to a recipient, the cursor records every message sent
+		if _, err := bunDB.Exec("ALTER TABLE campaign_recipients DROP CONSTRAINT IF EXISTS campaign_recipients_pkey"); err != nil {
+			return err
+		}
+		if _, err := bunDB.Exec("ALTER TABLE campaign_recipients ADD PRIMARY KEY (campaign, message_id)"); err != nil {
+			return err
+		}
+
+		return nil
+	}, func(ctx context.Context, bunDB *bun.DB) error {
+		// only the first message sent to a recipient is kept, like before
+		if _, err := bunDB.Exec(`DELETE FROM campaign_recipients r USING campaign_recipients k
+			WHERE r.campaign = k.campaign AND r."to" = k."to" AND r.message_id > k.message_id`); err != nil {
+			return err
+		}
+		if _, err := bunDB.Exec("ALTER TABLE campaign_recipients DROP CONSTRAINT IF EXISTS campaign_recipients_pkey"); err != nil {
+			return err
+		}
+		if _, err := bunDB.Exec(`ALTER TABLE campaign_recipients ADD PRIMARY KEY (campaign, "to")`); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
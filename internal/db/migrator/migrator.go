@@ -2,11 +2,26 @@ package migrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/uptrace/bun/migrate"
 )
 
+// RollbackAll can be passed to RollbackTo to roll back every applied migration
+const RollbackAll = "0"
+
+var (
+	ErrUnknownMigration = errors.New("unknown migration")
+	ErrNotApplied       = errors.New("migration is not applied")
+	// ErrSchemaBehind is returned by CheckSchema when migrations of the binary are not applied yet
+	ErrSchemaBehind = errors.New("database schema is older than this binary")
+	// ErrSchemaAhead is returned by CheckSchema when the database has migrations the binary does not know
+	ErrSchemaAhead = errors.New("database schema is newer than this binary")
+)
+
 // InitMigrator creates migration tables
 func InitMigrator(ctx context.Context, migrator *migrate.Migrator) error {
 	return migrator.Init(ctx)
@@ -41,6 +56,75 @@ func Rollback(ctx context.Context, migrator *migrate.Migrator) error {
 	return nil
 }
 
+// RollbackSteps rolls back the last n applied migrations (not groups), newest first.
+func RollbackSteps(ctx context.Context, migrator *migrate.Migrator, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1")
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied := ms.Applied()
+	if steps > len(applied) {
+		steps = len(applied)
+	}
+	return rollbackMigrations(ctx, migrator, applied[:steps])
+}
+
+// RollbackTo rolls back every migration applied after the target, the target itself stays applied.
+// The target is a migration name (20241118000001) or name with comment (20241118000001_create_messages),
+// RollbackAll rolls back everything.
+func RollbackTo(ctx context.Context, migrator *migrate.Migrator, target string) error {
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied := ms.Applied()
+	if target == RollbackAll {
+		return rollbackMigrations(ctx, migrator, applied)
+	}
+
+	for i, m := range applied {
+		if m.Name == target || m.String() == target {
+			return rollbackMigrations(ctx, migrator, applied[:i])
+		}
+	}
+
+	for _, m := range ms {
+		if m.Name == target || m.String() == target {
+			return fmt.Errorf("%w: %s", ErrNotApplied, target)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownMigration, target)
+}
+
+// rollbackMigrations runs the down functions of the migrations in the given order
+func rollbackMigrations(ctx context.Context, migrator *migrate.Migrator, ms migrate.MigrationSlice) error {
+	if len(ms) == 0 {
+		config.Log().Info("there are no migrations to roll back")
+		return nil
+	}
+
+	for i := range ms {
+		m := &ms[i]
+		if m.Down != nil {
+			if err := m.Down(ctx, migrator.DB(), nil); err != nil {
+				return fmt.Errorf("rolling back %s: %w", m, err)
+			}
+		}
+		if err := migrator.MarkUnapplied(ctx, m); err != nil {
+			return err
+		}
+		config.Log().Infof("rollbacked %s", m)
+	}
+
+	return nil
+}
+
 // Status shows current migration group
 func Status(ctx context.Context, migrator *migrate.Migrator) error {
 	ms, err := migrator.MigrationsWithStatus(ctx)
@@ -53,3 +137,55 @@ func Status(ctx context.Context, migrator *migrate.Migrator) error {
 
 	return nil
 }
+
+// SchemaVersion returns the name of the last applied migration, empty when none is applied
+func SchemaVersion(ctx context.Context, migrator *migrate.Migrator) (string, error) {
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	applied := ms.Applied()
+	if len(applied) == 0 {
+		return "", nil
+	}
+	return applied[0].Name, nil
+}
+
+// CheckSchema verifies that the applied migrations are exactly the ones registered with the migrator. It returns
+// ErrSchemaBehind when some are not applied yet and ErrSchemaAhead when the database has migrations the
+// migrator does not know, e.g. an old binary running against a database migrated by a newer release.
+func CheckSchema(ctx context.Context, migrator *migrate.Migrator) error {
+	applied, err := migrator.AppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("reading the applied migrations (is the database initialized?): %w", err)
+	}
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(ms))
+	for _, m := range ms {
+		known[m.Name] = true
+	}
+	var unknown []string
+	for _, m := range applied {
+		// the database only records the names, not the comments
+		if !known[m.Name] {
+			unknown = append(unknown, m.Name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: applied migrations %s are unknown, deploy the release that applied them", ErrSchemaAhead, strings.Join(unknown, ", "))
+	}
+
+	if unapplied := ms.Unapplied(); len(unapplied) > 0 {
+		names := make([]string, len(unapplied))
+		for i, m := range unapplied {
+			names[i] = m.String()
+		}
+		return fmt.Errorf("%w: migrations %s are not applied, run sendpulse database migrate", ErrSchemaBehind, strings.Join(names, ", "))
+	}
+	return nil
+}
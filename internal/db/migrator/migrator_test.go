@@ -0,0 +1,59 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/migrate"
+)
+
+// testMigrations returns migrations named after names that change nothing
+func testMigrations(names ...string) *migrate.Migrations {
+	ms := migrate.NewMigrations()
+	noop := func(context.Context, *bun.DB, any) error { return nil }
+	for _, name := range names {
+		ms.Add(migrate.Migration{Name: name, Comment: "test", Up: noop, Down: noop})
+	}
+	return ms
+}
+
+func TestCheckSchema(t *testing.T) {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+	database := bun.NewDB(sqldb, sqlitedialect.New())
+	defer database.Close()
+	ctx := context.Background()
+
+	err = CheckSchema(ctx, migrate.NewMigrator(database, testMigrations("20260101000001")))
+	assert.Error(t, err, "the migration tables are missing")
+
+	older := migrate.NewMigrator(database, testMigrations("20260101000001"))
+	newer := migrate.NewMigrator(database, testMigrations("20260101000001", "20260101000002"))
+	require.NoError(t, older.Init(ctx))
+
+	err = CheckSchema(ctx, newer)
+	assert.True(t, errors.Is(err, ErrSchemaBehind), "nothing is applied: %v", err)
+
+	_, err = older.Migrate(ctx)
+	require.NoError(t, err)
+	require.NoError(t, CheckSchema(ctx, older))
+
+	err = CheckSchema(ctx, newer)
+	assert.True(t, errors.Is(err, ErrSchemaBehind))
+	assert.ErrorContains(t, err, "20260101000002_test")
+
+	_, err = newer.Migrate(ctx)
+	require.NoError(t, err)
+	require.NoError(t, CheckSchema(ctx, newer))
+
+	err = CheckSchema(ctx, older)
+	assert.True(t, errors.Is(err, ErrSchemaAhead), "an old binary on a newer schema: %v", err)
+	assert.ErrorContains(t, err, "20260101000002")
+}
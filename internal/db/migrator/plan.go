@@ -0,0 +1,119 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+// PlannedMigration is a migration that would run, with the statements it would execute
+type PlannedMigration struct {
+	Name       string
+	Statements []string
+	// Err is set when the statements could not be captured, e.g. the migration reads data
+	Err error
+}
+
+// Plan returns the migrations that Migrate would apply without applying them.
+// Each migration is run against a recording connection, so the statements are captured but never sent
+// to the database.
+func Plan(ctx context.Context, migrator *migrate.Migrator) ([]PlannedMigration, error) {
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unapplied := ms.Unapplied()
+	plan := make([]PlannedMigration, 0, len(unapplied))
+	for _, m := range unapplied {
+		planned := PlannedMigration{Name: m.String()}
+		if m.Up != nil {
+			planned.Statements, planned.Err = record(ctx, migrator.DB(), m)
+		}
+		plan = append(plan, planned)
+	}
+
+	return plan, nil
+}
+
+// record runs the up function of the migration against a connection that only records statements
+func record(ctx context.Context, target *bun.DB, m migrate.Migration) ([]string, error) {
+	rec := &recorder{}
+	sqldb := sql.OpenDB(rec)
+	defer sqldb.Close()
+
+	if err := m.Up(ctx, bun.NewDB(sqldb, target.Dialect()), nil); err != nil {
+		return rec.statements, err
+	}
+	return rec.statements, nil
+}
+
+// recorder is a database/sql driver that records statements instead of executing them
+type recorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return &recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return r }
+func (r *recorder) Open(string) (driver.Conn, error)             { return &recorderConn{r}, nil }
+
+func (r *recorder) add(query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, query)
+}
+
+type recorderConn struct {
+	r *recorder
+}
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &recorderStmt{r: c.r, query: query}, nil
+}
+func (c *recorderConn) Close() error              { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) { return recorderTx{}, nil }
+
+func (c *recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.add(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recorderConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.r.add(query)
+	return recorderRows{}, nil
+}
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+
+func (s *recorderStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.r.add(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recorderStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.r.add(s.query)
+	return recorderRows{}, nil
+}
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error   { return nil }
+func (recorderTx) Rollback() error { return nil }
+
+type recorderRows struct{}
+
+func (recorderRows) Columns() []string         { return nil }
+func (recorderRows) Close() error              { return nil }
+func (recorderRows) Next([]driver.Value) error { return io.EOF }
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RecipientPause holds the messages to a phone number, or to every number starting with a prefix, in pending,
+// e.g. during the outage of a carrier. They are sent again once the pause is lifted or expires.
+type RecipientPause struct {
+	bun.BaseModel `bun:"table:recipient_pauses"`
+
+	// Prefix is an E.164 phone number or the start of one, e.g. +9055
+	Prefix string `bun:"prefix,pk" json:"prefix"`
+	Reason string `bun:"reason" json:"reason,omitempty"`
+	// ExpiresAt lifts the pause by itself, a pause without it lasts until it is removed
+	ExpiresAt *time.Time `bun:"expires_at" json:"expires_at,omitempty"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Active reports whether the pause still holds messages at now
+func (p *RecipientPause) Active(now time.Time) bool {
+	return p.ExpiresAt == nil || p.ExpiresAt.After(now)
+}
+
+// PutRecipientPause stores the pause of its prefix, replacing the reason and expiry of an existing one
+func PutRecipientPause(ctx context.Context, db bun.IDB, pause *RecipientPause) error {
+	pause.CreatedAt = time.Now()
+
+	_, err := db.NewInsert().
+		Model(pause).
+		On("CONFLICT (prefix) DO UPDATE").
+		Set("reason = EXCLUDED.reason").
+		Set("expires_at = EXCLUDED.expires_at").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	return err
+}
+
+// ListRecipientPauses returns the pauses active at now ordered by prefix, expired pauses are kept but not listed
+func ListRecipientPauses(ctx context.Context, db bun.IDB, now time.Time) ([]*RecipientPause, error) {
+	var pauses []*RecipientPause
+	err := db.NewSelect().
+		Model(&pauses).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("prefix ASC").
+		Scan(ctx)
+	return pauses, err
+}
+
+// DeleteRecipientPause removes the pause of prefix, it returns false when there was none
+func DeleteRecipientPause(ctx context.Context, db bun.IDB, prefix string) (bool, error) {
+	res, err := db.NewDelete().
+		Model((*RecipientPause)(nil)).
+		Where("prefix = ?", prefix).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// DeferPausedMessages defers the pending messages to the recipients starting with prefix that are due before
+// until to it, so the claims in between go to other traffic. It returns the number of deferred messages.
+func DeferPausedMessages(ctx context.Context, db bun.IDB, prefix string, until time.Time) (int, error) {
+	res, err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Set("scheduled_at = ?", until).
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", MessageStatusPending).
+		Where(`"to" LIKE ?`, prefix+"%").
+		Where("scheduled_at IS NULL OR scheduled_at < ?", until).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// MessagePayload is the raw provider response body of a message too large to be stored inline in its
+// webhook_response, see config.WebhookPayloads
+type MessagePayload struct {
+	bun.BaseModel `bun:"table:message_payloads"`
+
+	MessageID int64  `bun:"message_id,pk" json:"message_id"`
+	Body      string `bun:"body,notnull" json:"body"`
+	// Encoding is the compression of Body, which is base64 encoded, empty when it is not compressed
+	Encoding  string    `bun:"encoding,nullzero" json:"encoding,omitempty"`
+	Size      int       `bun:"size,notnull" json:"size"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// SaveMessagePayload stores the payload of a message, replacing the one of an earlier send
+func SaveMessagePayload(ctx context.Context, db bun.IDB, payload *MessagePayload) error {
+	payload.Size, payload.CreatedAt = len(payload.Body), time.Now()
+	_, err := db.NewInsert().
+		Model(payload).
+		On("CONFLICT (message_id) DO UPDATE").
+		Set("body = EXCLUDED.body").
+		Set("encoding = EXCLUDED.encoding").
+		Set("size = EXCLUDED.size").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	return err
+}
+
+// GetMessagePayload returns the payload of a message
+func GetMessagePayload(ctx context.Context, db bun.IDB, messageID int64) (*MessagePayload, error) {
+	payload := new(MessagePayload)
+	err := db.NewSelect().
+		Model(payload).
+		Where("message_id = ?", messageID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// DeleteMessagePayloads deletes the payloads of the messages with the given IDs
+func DeleteMessagePayloads(ctx context.Context, db bun.IDB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := db.NewDelete().
+		Model((*MessagePayload)(nil)).
+		Where("message_id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	return err
+}
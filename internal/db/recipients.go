@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RecipientCount are the messages to a recipient with a status and the creation time of the first and last
+type RecipientCount struct {
+	Status  MessageStatus `bun:"status"`
+	Count   int           `bun:"count"`
+	FirstAt time.Time     `bun:"first_at"`
+	LastAt  time.Time     `bun:"last_at"`
+}
+
+// CountRecipientMessages returns the messages to phone counted by status
+func CountRecipientMessages(ctx context.Context, db bun.IDB, phone string) ([]RecipientCount, error) {
+	var counts []RecipientCount
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		Column("status").
+		ColumnExpr("COUNT(*) AS count").
+		ColumnExpr("MIN(created_at) AS first_at").
+		ColumnExpr("MAX(created_at) AS last_at").
+		Where(`"to" = ?`, phone).
+		Group("status").
+		Scan(ctx, &counts)
+	return counts, err
+}
+
+// ListRecipientOutcomes returns the sent, delivered and failed messages to phone without their content, the
+// latest first, at most limit
+func ListRecipientOutcomes(ctx context.Context, db bun.IDB, phone string, limit int) ([]*Message, error) {
+	var messages []*Message
+	err := db.NewSelect().
+		Model(&messages).
+		Column("id", "public_id", "status", "sent_at", "delivered_at", "delivery_error", "created_at", "updated_at").
+		Where(`"to" = ?`, phone).
+		Where("status IN (?)", bun.In([]MessageStatus{MessageStatusSent, MessageStatusDelivered, MessageStatusFailed})).
+		Order("created_at DESC", "id DESC").
+		Limit(limit).
+		Scan(ctx)
+	return messages, err
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ReplayFilter selects the messages sent within [From, To) to replay. Only messages in SentStatuses are
+// replayed, failed ones are retried instead, and messages replayed before are skipped.
+type ReplayFilter struct {
+	From time.Time
+	To   time.Time
+	// Provider, Tenant and Campaign narrow the replay when set
+	Provider string
+	Tenant   string
+	Campaign string
+}
+
+func (f ReplayFilter) apply(query bun.QueryBuilder) bun.QueryBuilder {
+	query = query.
+		Where("message.sent_at >= ?", f.From).
+		Where("message.sent_at < ?", f.To).
+		Where("message.status IN (?)", bun.In(SentStatuses)).
+		Where("NOT EXISTS (SELECT 1 FROM messages AS replay WHERE replay.replay_of = message.id)")
+	if f.Provider != "" {
+		query = query.Where("message.provider = ?", f.Provider)
+	}
+	if f.Tenant != "" {
+		query = query.Where("message.tenant = ?", f.Tenant)
+	}
+	if f.Campaign != "" {
+		query = query.Where("message.campaign = ?", f.Campaign)
+	}
+	return query
+}
+
+// CountReplayable returns the number of messages a replay with the filter clones
+func CountReplayable(ctx context.Context, db bun.IDB, filter ReplayFilter) (int, error) {
+	return db.NewSelect().
+		Model(&Message{}).
+		ApplyQueryBuilder(filter.apply).
+		Count(ctx)
+}
+
+// ListReplayable returns up to limit messages a replay with the filter clones, in ID order after afterID
+func ListReplayable(ctx context.Context, db bun.IDB, filter ReplayFilter, afterID int64, limit int) ([]*Message, error) {
+	var messages []*Message
+	err := db.NewSelect().
+		Model(&messages).
+		ApplyQueryBuilder(filter.apply).
+		Where("message.id > ?", afterID).
+		Order("message.id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return messages, err
+}
+
+// ReplayClone returns a pending copy of message to enqueue, pointing back to it with ReplayOf. Its callback URL
+// is kept, its expiry is not as a replay resends on purpose.
+func ReplayClone(message *Message) *Message {
+	return &Message{
+		To:          message.To,
+		Content:     message.Content,
+		Priority:    message.Priority,
+		Tenant:      message.Tenant,
+		Campaign:    message.Campaign,
+		From:        message.From,
+		Timezone:    message.Timezone,
+		Metadata:    message.Metadata,
+		ReplayOf:    &message.ID,
+		CallbackURL: message.CallbackURL,
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FailureReason is the number of messages that failed with a reason, messages the webhook rejected have
+// an empty reason since only delivery reports carry one
+type FailureReason struct {
+	Reason string `bun:"reason" json:"reason"`
+	Count  int    `bun:"count" json:"count"`
+}
+
+// ReportCounts are the messages sent, delivered and failed in a window
+type ReportCounts struct {
+	Sent      int `bun:"sent"`
+	Delivered int `bun:"delivered"`
+	Failed    int `bun:"failed"`
+}
+
+// GetReportCounts counts the messages sent, delivered and failed in [from, to). Delivered messages are
+// counted by the time they were sent, so the delivered count is part of the sent count.
+func GetReportCounts(ctx context.Context, db bun.IDB, from, to time.Time) (*ReportCounts, error) {
+	counts := new(ReportCounts)
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		ColumnExpr("COUNT(*) FILTER (WHERE status IN (?) AND sent_at >= ? AND sent_at < ?) AS sent",
+			bun.In(SentStatuses), from, to).
+		ColumnExpr("COUNT(*) FILTER (WHERE status = ? AND sent_at >= ? AND sent_at < ?) AS delivered",
+			MessageStatusDelivered, from, to).
+		ColumnExpr("COUNT(*) FILTER (WHERE status = ? AND updated_at >= ? AND updated_at < ?) AS failed",
+			MessageStatusFailed, from, to).
+		Scan(ctx, counts)
+	return counts, err
+}
+
+// GetTopFailureReasons returns the most frequent reasons of the messages that failed in [from, to)
+func GetTopFailureReasons(ctx context.Context, db bun.IDB, from, to time.Time, limit int) ([]FailureReason, error) {
+	var reasons []FailureReason
+	err := db.NewSelect().
+		Model((*Message)(nil)).
+		ColumnExpr("COALESCE(delivery_error, '') AS reason").
+		ColumnExpr("COUNT(*) AS count").
+		Where("status = ?", MessageStatusFailed).
+		Where("updated_at >= ?", from).
+		Where("updated_at < ?", to).
+		GroupExpr("COALESCE(delivery_error, '')").
+		OrderExpr("count DESC, reason ASC").
+		Limit(limit).
+		Scan(ctx, &reasons)
+	return reasons, err
+}
+
+// CountBacklogAt returns the number of messages that were waiting to be sent at t: created before t and
+// still pending or sending then. A message left the backlog when it was sent, or when it was last updated
+// for the other statuses.
+func CountBacklogAt(ctx context.Context, db bun.IDB, t time.Time) (int, error) {
+	return db.NewSelect().
+		Model((*Message)(nil)).
+		Where("created_at < ?", t).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("status IN (?)", bun.In([]MessageStatus{MessageStatusPending, MessageStatusSending})).
+				WhereOr("COALESCE(sent_at, updated_at) >= ?", t)
+		}).
+		Count(ctx)
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type SuppressionSource string
+
+const (
+	// SuppressionSourceAPI marks numbers added through the API or CLI
+	SuppressionSourceAPI SuppressionSource = "api"
+	// SuppressionSourceInbound marks numbers that replied with a stop keyword
+	SuppressionSourceInbound SuppressionSource = "inbound"
+	// SuppressionSourceImport marks numbers imported from an opt-out list
+	SuppressionSourceImport SuppressionSource = "import"
+)
+
+// Suppression is a recipient that must not receive messages, their messages are blocked
+type Suppression struct {
+	bun.BaseModel `bun:"table:suppressions"`
+
+	Phone     string            `bun:"phone,pk" json:"phone"`
+	Reason    string            `bun:"reason" json:"reason,omitempty"`
+	Source    SuppressionSource `bun:"source,notnull" json:"source"`
+	CreatedAt time.Time         `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// AddSuppression suppresses a phone number, it returns false when the number was already suppressed
+func AddSuppression(ctx context.Context, db bun.IDB, suppression *Suppression) (bool, error) {
+	if !phoneNumberPattern.MatchString(suppression.Phone) {
+		return false, ErrInvalidPhoneNumber
+	}
+	suppression.CreatedAt = time.Now()
+
+	res, err := db.NewInsert().
+		Model(suppression).
+		On("CONFLICT (phone) DO NOTHING").
+		Returning("NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// AddSuppressions suppresses phone numbers in one statement, skipping the suppressed ones. It returns the number of
+// phone numbers it suppressed. The numbers must be valid and distinct.
+func AddSuppressions(ctx context.Context, db bun.IDB, suppressions []*Suppression) (int, error) {
+	if len(suppressions) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	for _, suppression := range suppressions {
+		suppression.CreatedAt = now
+	}
+
+	res, err := db.NewInsert().
+		Model(&suppressions).
+		On("CONFLICT (phone) DO NOTHING").
+		Returning("NULL").
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// SuppressedPhones returns the phone numbers of phones that are suppressed
+func SuppressedPhones(ctx context.Context, db bun.IDB, phones []string) ([]string, error) {
+	var suppressed []string
+	if len(phones) == 0 {
+		return suppressed, nil
+	}
+	err := db.NewSelect().
+		Model((*Suppression)(nil)).
+		Column("phone").
+		Where("phone IN (?)", bun.In(phones)).
+		Scan(ctx, &suppressed)
+	return suppressed, err
+}
+
+// RemoveSuppression lifts the suppression of a phone number, source limits it to numbers added by
+// that source when set. It returns false when no matching suppression existed.
+func RemoveSuppression(ctx context.Context, db bun.IDB, phone string, source SuppressionSource) (bool, error) {
+	query := db.NewDelete().
+		Model((*Suppression)(nil)).
+		Where("phone = ?", phone)
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// ListSuppressions returns suppressions, newest first
+func ListSuppressions(ctx context.Context, db bun.IDB, limit, offset int) ([]*Suppression, error) {
+	var suppressions []*Suppression
+	err := db.NewSelect().
+		Model(&suppressions).
+		Order("created_at DESC", "phone ASC").
+		Limit(limit).
+		Offset(offset).
+		Scan(ctx)
+	return suppressions, err
+}
+
+func CountSuppressions(ctx context.Context, db bun.IDB) (int, error) {
+	return db.NewSelect().Model((*Suppression)(nil)).Count(ctx)
+}
+
+// IsSuppressed reports whether a phone number is suppressed
+func IsSuppressed(ctx context.Context, db bun.IDB, phone string) (bool, error) {
+	return db.NewSelect().
+		Model((*Suppression)(nil)).
+		Where("phone = ?", phone).
+		Exists(ctx)
+}
+
+// SuppressionPhoneConstraint checks that suppressed phone numbers are E.164, it is added NOT VALID so suppressions
+// stored before it are only checked once it is validated
+const SuppressionPhoneConstraint = "check_suppression_phone_format"
+
+// ListSuppressionPhones returns up to limit suppressed phone numbers sorted after after, in order
+func ListSuppressionPhones(ctx context.Context, db bun.IDB, after string, limit int) ([]string, error) {
+	var phones []string
+	err := db.NewSelect().
+		Model((*Suppression)(nil)).
+		Column("phone").
+		Where("phone > ?", after).
+		Order("phone ASC").
+		Limit(limit).
+		Scan(ctx, &phones)
+	return phones, err
+}
+
+// RenameSuppression moves the suppression of phone to to, keeping its reason, source and creation time. When to is
+// suppressed already the suppression of phone is dropped instead, merged reports that case.
+func RenameSuppression(ctx context.Context, db bun.IDB, phone, to string) (merged bool, err error) {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO suppressions (phone, reason, source, created_at)
+		SELECT ?, reason, source, created_at FROM suppressions WHERE phone = ?
+		ON CONFLICT (phone) DO NOTHING`,
+		to, phone)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := RemoveSuppression(ctx, db, phone, ""); err != nil {
+		return false, err
+	}
+	return inserted == 0, nil
+}
+
+// ValidateSuppressionPhones validates SuppressionPhoneConstraint, failing while a suppressed number is not E.164
+func ValidateSuppressionPhones(ctx context.Context, db bun.IDB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE suppressions VALIDATE CONSTRAINT "+SuppressionPhoneConstraint)
+	return err
+}
+
+// blockSuppressed marks the messages to suppressed recipients as blocked
+func blockSuppressed(ctx context.Context, db bun.IDB, messages []*Message) error {
+	phones := make([]string, 0, len(messages))
+	for _, message := range messages {
+		phones = append(phones, message.To)
+	}
+
+	suppressed, err := SuppressedPhones(ctx, db, phones)
+	if err != nil {
+		return err
+	}
+	if len(suppressed) == 0 {
+		return nil
+	}
+
+	blocked := make(map[string]bool, len(suppressed))
+	for _, phone := range suppressed {
+		blocked[phone] = true
+	}
+	for _, message := range messages {
+		if blocked[message.To] {
+			message.Status = MessageStatusBlocked
+		}
+	}
+	return nil
+}
+
+// GetSuppression returns the suppression of a phone number, sql.ErrNoRows when it is not suppressed
+func GetSuppression(ctx context.Context, db bun.IDB, phone string) (*Suppression, error) {
+	suppression := new(Suppression)
+	err := db.NewSelect().
+		Model(suppression).
+		Where("phone = ?", phone).
+		Scan(ctx)
+	return suppression, err
+}
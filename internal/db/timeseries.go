@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// TimeseriesBucket is the width of a time series point, points start at UTC minute, hour, day or ISO week
+// (Monday) boundaries
+type TimeseriesBucket string
+
+const (
+	BucketMinute TimeseriesBucket = "1m"
+	BucketHour   TimeseriesBucket = "1h"
+	BucketDay    TimeseriesBucket = "1d"
+	BucketWeek   TimeseriesBucket = "1w"
+)
+
+var ErrInvalidTimeseriesBucket = errors.New("bucket must be 1m, 1h, 1d or 1w")
+
+// timeseriesUnits are the date_trunc units of the buckets
+var timeseriesUnits = map[TimeseriesBucket]string{
+	BucketMinute: "minute",
+	BucketHour:   "hour",
+	BucketDay:    "day",
+	BucketWeek:   "week",
+}
+
+// sqliteBuckets format a time as the start of its bucket on SQLite, which has no date_trunc
+var sqliteBuckets = map[TimeseriesBucket]string{
+	BucketMinute: "strftime('%Y-%m-%dT%H:%M:00Z', ?)",
+	BucketHour:   "strftime('%Y-%m-%dT%H:00:00Z', ?)",
+	BucketDay:    "strftime('%Y-%m-%dT00:00:00Z', ?)",
+	BucketWeek:   "strftime('%Y-%m-%dT00:00:00Z', ?, 'weekday 0', '-6 days')",
+}
+
+// Truncate returns the start of the bucket t is in
+func (b TimeseriesBucket) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketMinute:
+		return t.Truncate(time.Minute)
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Next returns the start of the bucket after the one starting at t
+func (b TimeseriesBucket) Next(t time.Time) time.Time {
+	switch b {
+	case BucketMinute:
+		return t.Add(time.Minute)
+	case BucketHour:
+		return t.Add(time.Hour)
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// Valid reports whether b is one of the buckets
+func (b TimeseriesBucket) Valid() bool {
+	_, ok := timeseriesUnits[b]
+	return ok
+}
+
+// TimeseriesPoint are the messages created, sent and failed in a bucket. Sent messages are counted by the
+// time they were sent, failed ones by the time they were last updated, like GetReportCounts.
+type TimeseriesPoint struct {
+	Time    time.Time
+	Created int
+	Sent    int
+	Failed  int
+}
+
+type timeseriesCount struct {
+	Bucket string `bun:"bucket"`
+	Count  int    `bun:"count"`
+}
+
+// GetTimeseries returns a point per bucket in [from, to), from the bucket from is in, with zero points
+// for the buckets without messages
+func GetTimeseries(ctx context.Context, db bun.IDB, bucket TimeseriesBucket, from, to time.Time) ([]TimeseriesPoint, error) {
+	if !bucket.Valid() {
+		return nil, ErrInvalidTimeseriesBucket
+	}
+	from = bucket.Truncate(from)
+
+	var points []TimeseriesPoint
+	index := make(map[int64]int)
+	for t := from; t.Before(to); t = bucket.Next(t) {
+		index[t.Unix()] = len(points)
+		points = append(points, TimeseriesPoint{Time: t})
+	}
+
+	series := []struct {
+		column string
+		where  func(*bun.SelectQuery) *bun.SelectQuery
+		add    func(*TimeseriesPoint, int)
+	}{
+		{"created_at", func(q *bun.SelectQuery) *bun.SelectQuery { return q },
+			func(p *TimeseriesPoint, n int) { p.Created += n }},
+		{"sent_at", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Where("status IN (?)", bun.In(SentStatuses)) },
+			func(p *TimeseriesPoint, n int) { p.Sent += n }},
+		{"updated_at", func(q *bun.SelectQuery) *bun.SelectQuery { return q.Where("status = ?", MessageStatusFailed) },
+			func(p *TimeseriesPoint, n int) { p.Failed += n }},
+	}
+	for _, s := range series {
+		key := timeseriesKey(db, bucket, s.column)
+		var counts []timeseriesCount
+		err := s.where(db.NewSelect().Model((*Message)(nil))).
+			ColumnExpr("? AS bucket", key).
+			ColumnExpr("COUNT(*) AS count").
+			Where("? >= ?", bun.Ident(s.column), from).
+			Where("? < ?", bun.Ident(s.column), to).
+			GroupExpr("?", key).
+			Scan(ctx, &counts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, count := range counts {
+			t, err := time.Parse(time.RFC3339, count.Bucket)
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := index[t.Unix()]; ok {
+				s.add(&points[i], count.Count)
+			}
+		}
+	}
+	return points, nil
+}
+
+// timeseriesKey is the start of the bucket of column as RFC 3339 text in UTC
+func timeseriesKey(db bun.IDB, bucket TimeseriesBucket, column string) bun.Safe {
+	if db.Dialect().Name() == dialect.SQLite {
+		return bun.Safe(strings.Replace(sqliteBuckets[bucket], "?", column, 1))
+	}
+	return bun.Safe(fmt.Sprintf(`to_char(date_trunc('%s', %s AT TIME ZONE 'UTC'), 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`,
+		timeseriesUnits[bucket], column))
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type UsageScope string
+
+const (
+	UsageScopeAPIKey UsageScope = "api_key"
+	UsageScopeTenant UsageScope = "tenant"
+)
+
+type UsagePeriod string
+
+const (
+	UsagePeriodDay   UsagePeriod = "day"
+	UsagePeriodMonth UsagePeriod = "month"
+)
+
+// UsageCounter counts the messages created by an API key or tenant in a UTC day or month
+type UsageCounter struct {
+	bun.BaseModel `bun:"table:usage_counters"`
+
+	Scope       UsageScope  `bun:"scope,pk" json:"scope"`
+	Subject     string      `bun:"subject,pk" json:"subject"`
+	Period      UsagePeriod `bun:"period,pk" json:"period"`
+	PeriodStart time.Time   `bun:"period_start,pk" json:"period_start"`
+	Count       int64       `bun:"count,notnull" json:"count"`
+	UpdatedAt   time.Time   `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// IncrementUsage adds counter.Count to the stored counter, creating it when missing, and sets counter.Count to the
+// stored count. It returns false without changing anything when the result would exceed limit, 0 is unlimited.
+func IncrementUsage(ctx context.Context, db bun.IDB, counter *UsageCounter, limit int64) (bool, error) {
+	if limit > 0 && counter.Count > limit {
+		return false, nil
+	}
+	counter.UpdatedAt = time.Now()
+
+	query := db.NewInsert().Model(counter).
+		On("CONFLICT (scope, subject, period, period_start) DO UPDATE").
+		Set("count = ?TableAlias.count + EXCLUDED.count").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("count")
+	if limit > 0 {
+		query = query.Where("?TableAlias.count + EXCLUDED.count <= ?", limit)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseUsage subtracts counter.Count from the stored counter, never taking it below zero. Counters that were
+// never stored are left alone.
+func ReleaseUsage(ctx context.Context, db bun.IDB, counter *UsageCounter) error {
+	_, err := db.NewUpdate().
+		Model((*UsageCounter)(nil)).
+		Set("count = CASE WHEN count > ? THEN count - ? ELSE 0 END", counter.Count, counter.Count).
+		Set("updated_at = ?", time.Now()).
+		Where("scope = ?", counter.Scope).
+		Where("subject = ?", counter.Subject).
+		Where("period = ?", counter.Period).
+		Where("period_start = ?", counter.PeriodStart).
+		Exec(ctx)
+	return err
+}
+
+// ListUsage returns the counters of the day starting at day and of the month starting at month
+func ListUsage(ctx context.Context, db bun.IDB, day, month time.Time) ([]*UsageCounter, error) {
+	var counters []*UsageCounter
+	err := db.NewSelect().
+		Model(&counters).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				WhereOr("period = ? AND period_start = ?", UsagePeriodDay, day).
+				WhereOr("period = ? AND period_start = ?", UsagePeriodMonth, month)
+		}).
+		Order("scope ASC", "subject ASC", "period ASC").
+		Scan(ctx)
+	return counters, err
+}
+
+// ListSubjectUsage returns the counters of an API key or tenant like ListUsage
+func ListSubjectUsage(ctx context.Context, db bun.IDB, scope UsageScope, subject string, day, month time.Time) ([]*UsageCounter, error) {
+	var counters []*UsageCounter
+	err := db.NewSelect().
+		Model(&counters).
+		Where("scope = ?", scope).
+		Where("subject = ?", subject).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				WhereOr("period = ? AND period_start = ?", UsagePeriodDay, day).
+				WhereOr("period = ? AND period_start = ?", UsagePeriodMonth, month)
+		}).
+		Order("period ASC").
+		Scan(ctx)
+	return counters, err
+}
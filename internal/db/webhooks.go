@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Webhook override scopes
+const (
+	WebhookScopeTenant   = "tenant"
+	WebhookScopeCampaign = "campaign"
+)
+
+// Webhook override authentications
+const (
+	WebhookAuthNone   = "none"
+	WebhookAuthBasic  = "basic"
+	WebhookAuthBearer = "bearer"
+)
+
+// WebhookOverride sends the messages of a tenant or campaign to their own webhook instead of webhook.url
+type WebhookOverride struct {
+	bun.BaseModel `bun:"table:webhook_overrides"`
+
+	ID int64 `bun:"id,pk,autoincrement" json:"id"`
+	// Scope is tenant or campaign, Name the tenant or campaign the override applies to
+	Scope string `bun:"scope,notnull,unique:webhook_overrides_scope_name" json:"scope"`
+	Name  string `bun:"name,notnull,unique:webhook_overrides_scope_name" json:"name"`
+	URL   string `bun:"url,notnull" json:"url"`
+	// Auth is how the requests are authenticated, the credentials are encrypted with webhook.encryption_key
+	Auth        string    `bun:"auth,notnull" json:"auth"`
+	Credentials []byte    `bun:"credentials,nullzero" json:"-"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// PutWebhookOverride stores the override of its scope and name, replacing the URL and credentials of an existing one
+func PutWebhookOverride(ctx context.Context, db bun.IDB, override *WebhookOverride) error {
+	now := time.Now()
+	override.CreatedAt, override.UpdatedAt = now, now
+
+	_, err := db.NewInsert().
+		Model(override).
+		On("CONFLICT (scope, name) DO UPDATE").
+		Set("url = EXCLUDED.url").
+		Set("auth = EXCLUDED.auth").
+		Set("credentials = EXCLUDED.credentials").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("id, created_at").
+		Exec(ctx)
+	return err
+}
+
+// ListWebhookOverrides returns every override ordered by scope and name
+func ListWebhookOverrides(ctx context.Context, db bun.IDB) ([]*WebhookOverride, error) {
+	var overrides []*WebhookOverride
+	err := db.NewSelect().
+		Model(&overrides).
+		Order("scope ASC", "name ASC").
+		Scan(ctx)
+	return overrides, err
+}
+
+// DeleteWebhookOverride removes the override of scope and name, it returns false when there was none
+func DeleteWebhookOverride(ctx context.Context, db bun.IDB, scope, name string) (bool, error) {
+	res, err := db.NewDelete().
+		Model((*WebhookOverride)(nil)).
+		Where("scope = ?", scope).
+		Where("name = ?", name).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+)
+
+// DefaultBuffer is the number of events a subscription holds before the bus drops events for it
+const DefaultBuffer = 1024
+
+// DefaultWait is how long publishing waits for room in the full buffer of a waiting subscription, see
+// SubscribeWaiting, before the event is dropped for it
+const DefaultWait = time.Second
+
+// Bus is the in-process pub/sub of the lifecycle events. The scheduler and the services publish onto it,
+// features reacting to the events (the external publishers, metrics, ...) subscribe to it instead of hooking
+// into the send path. Publishing only blocks for the waiting subscriptions: an event a subscriber has no room
+// for is dropped for that subscriber and counted, right away or once the wait of a waiting subscription is over.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []*Subscription
+	closed bool
+}
+
+// Subscription receives the events published onto the bus after it subscribed
+type Subscription struct {
+	name   string
+	types  []Type
+	events chan Event
+	// wait is how long a full buffer is waited for before an event is dropped, 0 drops it right away
+	wait time.Duration
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a subscriber named name, its name labels the dropped events. It receives the events of
+// types, or every event when no type is given. The events channel is closed by Unsubscribe and Close.
+func (b *Bus) Subscribe(name string, buffer int, types ...Type) *Subscription {
+	return b.SubscribeWaiting(name, buffer, 0, types...)
+}
+
+// SubscribeWaiting registers a subscriber like Subscribe whose events are only dropped once its buffer stayed full
+// for wait, so the publishers slow down to the pace of subscribers delivering the events elsewhere, like the
+// external publishers, instead of losing them under load
+func (b *Bus) SubscribeWaiting(name string, buffer int, wait time.Duration, types ...Type) *Subscription {
+	sub := &Subscription{name: name, types: types, events: make(chan Event, buffer), wait: wait}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs = append(b.subs, sub)
+	return sub
+}
+
+// Unsubscribe stops delivering events to sub and closes its channel
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if i := slices.Index(b.subs, sub); i >= 0 {
+		b.subs = slices.Delete(b.subs, i, i+1)
+		close(sub.events)
+	}
+}
+
+// Publish delivers the event to every subscriber of its type, it never fails
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if len(sub.types) > 0 && !slices.Contains(sub.types, event.Type) {
+			continue
+		}
+		if !sub.send(ctx, event) {
+			telemetry.RecordDroppedEvent(sub.name)
+			config.LogFrom(ctx).WithField("subscriber", sub.name).WithField("message_id", event.MessageID).
+				Warnf("Event bus subscriber is falling behind, dropped %s event", event.Type)
+		}
+	}
+	return nil
+}
+
+// send buffers event, waiting up to the wait of the subscription for room. It returns false when it was dropped.
+func (s *Subscription) send(ctx context.Context, event Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+	if s.wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(s.wait)
+	defer timer.Stop()
+	select {
+	case s.events <- event:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Close closes the channels of every subscription, the events published afterwards are discarded
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, sub := range b.subs {
+		close(sub.events)
+	}
+	b.subs = nil
+}
+
+// Events returns the channel the events are delivered on
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Forward publishes the events of sub to publisher until the subscription is closed. Failed publishes are
+// logged, like the publishes of Queue they never affect the message.
+func Forward(sub *Subscription, publisher Publisher) {
+	for event := range sub.Events() {
+		if err := publisher.Publish(context.Background(), event); err != nil {
+			config.Log().WithField("subscriber", sub.name).WithField("message_id", event.MessageID).
+				Warnf("Failed to publish %s event: %v", event.Type, err)
+		}
+	}
+}
+
+// Count counts the events of sub in sendpulse_lifecycle_events_total until the subscription is closed
+func Count(sub *Subscription) {
+	for event := range sub.Events() {
+		telemetry.RecordLifecycleEvent(string(event.Type))
+	}
+}
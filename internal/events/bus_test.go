@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	all := bus.Subscribe("all", 10)
+	failed := bus.Subscribe("failed", 10, TypeFailed)
+
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeCreated, MessageID: 1}))
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeFailed, MessageID: 1}))
+
+	require.Len(t, all.Events(), 2)
+	assert.Equal(t, TypeCreated, (<-all.Events()).Type)
+	assert.Equal(t, TypeFailed, (<-all.Events()).Type)
+	require.Len(t, failed.Events(), 1)
+	assert.Equal(t, TypeFailed, (<-failed.Events()).Type)
+
+	bus.Unsubscribe(failed)
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeFailed, MessageID: 2}))
+	_, open := <-failed.Events()
+	assert.False(t, open)
+	assert.Len(t, all.Events(), 1)
+
+	bus.Close()
+	<-all.Events()
+	_, open = <-all.Events()
+	assert.False(t, open)
+
+	// subscribing to and publishing onto a closed bus does nothing
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeCreated, MessageID: 3}))
+	_, open = <-bus.Subscribe("late", 10).Events()
+	assert.False(t, open)
+}
+
+func TestBus_DropsForSlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	slow := bus.Subscribe("slow", 1)
+	fast := bus.Subscribe("fast", 10)
+
+	// publishing never waits for the full subscriber
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, bus.Publish(ctx, Event{Type: TypeSent, MessageID: id}))
+	}
+
+	assert.Len(t, slow.Events(), 1)
+	assert.Equal(t, int64(1), (<-slow.Events()).MessageID)
+	assert.Len(t, fast.Events(), 3)
+}
+
+func TestBus_WaitsForWaitingSubscriber(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	durable := bus.SubscribeWaiting("durable", 1, time.Second)
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeSent, MessageID: 1}))
+
+	// the second event waits for the first one to be read instead of being dropped
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-durable.Events()
+	}()
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeSent, MessageID: 2}))
+	assert.Equal(t, int64(2), (<-durable.Events()).MessageID)
+
+	t.Run("drops once the wait is over", func(t *testing.T) {
+		bus := NewBus()
+		durable := bus.SubscribeWaiting("durable", 1, 10*time.Millisecond)
+		dropped := testutil.ToFloat64(telemetry.DroppedEvents.WithLabelValues("durable"))
+		for id := int64(1); id <= 2; id++ {
+			require.NoError(t, bus.Publish(ctx, Event{Type: TypeSent, MessageID: id}))
+		}
+		assert.Len(t, durable.Events(), 1)
+		assert.Equal(t, dropped+1, testutil.ToFloat64(telemetry.DroppedEvents.WithLabelValues("durable")))
+	})
+}
+
+func TestForward(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe("recorder", 10)
+	publisher := &recordingPublisher{}
+
+	require.NoError(t, bus.Publish(context.Background(), Event{Type: TypeCreated, MessageID: 1}))
+	require.NoError(t, bus.Publish(context.Background(), Event{Type: TypeSent, MessageID: 1}))
+	bus.Close()
+
+	// returns once the bus is closed and the buffered events are published
+	Forward(sub, publisher)
+	require.Len(t, publisher.events, 2)
+	assert.Equal(t, TypeSent, publisher.events[1].Type)
+}
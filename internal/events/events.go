@@ -0,0 +1,163 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+)
+
+// Type is the kind of a message lifecycle event
+type Type string
+
+const (
+	// TypeCreated is published once a message was enqueued
+	TypeCreated Type = "created"
+	// TypeAccepted is published once the webhook accepted a message while delivery reports are enabled
+	TypeAccepted Type = "accepted"
+	// TypeAcceptedWithoutID is published once the webhook accepted a message without a usable message ID
+	TypeAcceptedWithoutID Type = "accepted_without_id"
+	// TypeSent is published once the webhook accepted a message, or the provider reported it as sent
+	TypeSent Type = "sent"
+	// TypeDelivered is published once the provider reported a message as delivered
+	TypeDelivered Type = "delivered"
+	// TypeFailed is published once a message failed for good, after the webhook retries or by a delivery report
+	TypeFailed Type = "failed"
+	// TypeUnconfirmed is published once no delivery report arrived for an accepted message in time
+	TypeUnconfirmed Type = "unconfirmed"
+	// TypeBlocked is published once a claimed message was blocked because its recipient was suppressed
+	TypeBlocked Type = "blocked"
+	// TypeExpired is published once a message was claimed after its expires_at and expired instead of sent
+	TypeExpired Type = "expired"
+	// TypeDeadLettered is published once a failed message was moved to the dead-letter queue, after its failed event
+	TypeDeadLettered Type = "dead_lettered"
+)
+
+// Event is a message lifecycle event, published as JSON
+type Event struct {
+	Type      Type             `json:"type"`
+	MessageID int64            `json:"message_id"`
+	To        string           `json:"to"`
+	Status    db.MessageStatus `json:"status"`
+	Tenant    string           `json:"tenant,omitempty"`
+	Campaign  string           `json:"campaign,omitempty"`
+	// WebhookMessageID is the message ID returned by the webhook, set once it was accepted
+	WebhookMessageID string `json:"webhook_message_id,omitempty"`
+	// FailureClass is the class of the failure of a dead-lettered message
+	FailureClass string    `json:"failure_class,omitempty"`
+	At           time.Time `json:"at"`
+	// CallbackURL is where the status notices of the message are posted to, it is not published
+	CallbackURL string `json:"-"`
+}
+
+// Publisher delivers lifecycle events to an external system
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Queue publishes the lifecycle events of the messages passing through the wrapped queue.
+// Publishing is best effort: the message state in the queue is the source of truth,
+// so a failed publish is logged and never fails the queue operation.
+type Queue struct {
+	queue.Queue
+	publisher Publisher
+}
+
+// NewQueue wraps q with publisher, q is returned as is when publisher is nil
+func NewQueue(q queue.Queue, publisher Publisher) queue.Queue {
+	if publisher == nil {
+		return q
+	}
+	return &Queue{Queue: q, publisher: publisher}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	if err := q.Queue.Enqueue(ctx, messages...); err != nil {
+		return err
+	}
+	for _, message := range messages {
+		q.publish(ctx, NewEvent(TypeCreated, message))
+	}
+	return nil
+}
+
+func (q *Queue) Ack(ctx context.Context, message *db.Message, delivery queue.Delivery) error {
+	if err := q.Queue.Ack(ctx, message, delivery); err != nil {
+		return err
+	}
+	event := NewEvent(TypeSent, message)
+	event.Status = db.MessageStatusSent
+	switch delivery.Status {
+	case db.MessageStatusAccepted:
+		event.Type = TypeAccepted
+		event.Status = db.MessageStatusAccepted
+	case db.MessageStatusAcceptedWithoutID:
+		event.Type = TypeAcceptedWithoutID
+		event.Status = db.MessageStatusAcceptedWithoutID
+	}
+	event.WebhookMessageID = delivery.MessageID
+	event.At = delivery.SentAt
+	q.publish(ctx, event)
+	return nil
+}
+
+func (q *Queue) Fail(ctx context.Context, message *db.Message) error {
+	if err := q.Queue.Fail(ctx, message); err != nil {
+		return err
+	}
+	event := NewEvent(TypeFailed, message)
+	event.Status = db.MessageStatusFailed
+	q.publish(ctx, event)
+	if message.DeadLetteredAt != nil {
+		event.Type = TypeDeadLettered
+		event.FailureClass = message.FailureClass
+		q.publish(ctx, event)
+	}
+	return nil
+}
+
+func (q *Queue) Block(ctx context.Context, message *db.Message) error {
+	if err := q.Queue.Block(ctx, message); err != nil {
+		return err
+	}
+	event := NewEvent(TypeBlocked, message)
+	event.Status = db.MessageStatusBlocked
+	q.publish(ctx, event)
+	return nil
+}
+
+func (q *Queue) Expire(ctx context.Context, message *db.Message) error {
+	if err := q.Queue.Expire(ctx, message); err != nil {
+		return err
+	}
+	event := NewEvent(TypeExpired, message)
+	event.Status = db.MessageStatusExpired
+	q.publish(ctx, event)
+	return nil
+}
+
+func (q *Queue) publish(ctx context.Context, event Event) {
+	if err := q.publisher.Publish(ctx, event); err != nil {
+		config.LogFrom(ctx).WithField("message_id", event.MessageID).Warnf("Failed to publish %s event: %v", event.Type, err)
+	}
+}
+
+// NewEvent returns an event of the message in its current status
+func NewEvent(eventType Type, message *db.Message) Event {
+	event := Event{
+		Type:        eventType,
+		MessageID:   message.ID,
+		To:          message.To,
+		Status:      message.Status,
+		Tenant:      message.Tenant,
+		Campaign:    message.Campaign,
+		At:          time.Now().UTC(),
+		CallbackURL: message.CallbackURL,
+	}
+	if message.MessageID != nil {
+		event.WebhookMessageID = *message.MessageID
+	}
+	return event
+}
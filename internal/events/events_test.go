@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	events []Event
+	err    error
+}
+
+func (r *recordingPublisher) Publish(_ context.Context, event Event) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+// fakeQueue assigns IDs on enqueue and fails every operation when err is set
+type fakeQueue struct {
+	queue.Queue
+	nextID int64
+	err    error
+}
+
+func (f *fakeQueue) Enqueue(_ context.Context, messages ...*db.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	for _, message := range messages {
+		f.nextID++
+		message.ID = f.nextID
+		message.Status = db.MessageStatusPending
+	}
+	return nil
+}
+
+func (f *fakeQueue) Ack(context.Context, *db.Message, queue.Delivery) error { return f.err }
+
+func (f *fakeQueue) Fail(context.Context, *db.Message) error { return f.err }
+
+func (f *fakeQueue) Expire(context.Context, *db.Message) error { return f.err }
+
+func TestNewQueue_WithoutPublisher(t *testing.T) {
+	q := &fakeQueue{}
+	assert.Same(t, q, NewQueue(q, nil))
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	q := NewQueue(&fakeQueue{}, publisher)
+
+	first := &db.Message{To: "+905551111111", Content: "First", Tenant: "billing"}
+	second := &db.Message{To: "+905552222222", Content: "Second"}
+	require.NoError(t, q.Enqueue(ctx, first, second))
+
+	sentAt := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	first.Status = db.MessageStatusSending
+	require.NoError(t, q.Ack(ctx, first, queue.Delivery{SentAt: sentAt, MessageID: "webhook-1"}))
+	second.Status = db.MessageStatusSending
+	require.NoError(t, q.Fail(ctx, second))
+
+	require.Len(t, publisher.events, 4)
+	assert.Equal(t, TypeCreated, publisher.events[0].Type)
+	assert.Equal(t, int64(1), publisher.events[0].MessageID)
+	assert.Equal(t, db.MessageStatusPending, publisher.events[0].Status)
+	assert.Equal(t, "billing", publisher.events[0].Tenant)
+	assert.Equal(t, int64(2), publisher.events[1].MessageID)
+
+	assert.Equal(t, Event{
+		Type:             TypeSent,
+		MessageID:        1,
+		To:               "+905551111111",
+		Status:           db.MessageStatusSent,
+		Tenant:           "billing",
+		WebhookMessageID: "webhook-1",
+		At:               sentAt,
+	}, publisher.events[2])
+
+	assert.Equal(t, TypeFailed, publisher.events[3].Type)
+	assert.Equal(t, db.MessageStatusFailed, publisher.events[3].Status)
+}
+
+func TestQueue_AckAccepted(t *testing.T) {
+	publisher := &recordingPublisher{}
+	q := NewQueue(&fakeQueue{}, publisher)
+
+	message := &db.Message{ID: 1, To: "+905551111111", Status: db.MessageStatusSending}
+	delivery := queue.Delivery{Status: db.MessageStatusAccepted, SentAt: time.Now().UTC(), MessageID: "webhook-1"}
+	require.NoError(t, q.Ack(context.Background(), message, delivery))
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, TypeAccepted, publisher.events[0].Type)
+	assert.Equal(t, db.MessageStatusAccepted, publisher.events[0].Status)
+}
+
+func TestQueue_AckAcceptedWithoutID(t *testing.T) {
+	publisher := &recordingPublisher{}
+	q := NewQueue(&fakeQueue{}, publisher)
+
+	message := &db.Message{ID: 1, To: "+905551111111", Status: db.MessageStatusSending}
+	delivery := queue.Delivery{Status: db.MessageStatusAcceptedWithoutID, SentAt: time.Now().UTC()}
+	require.NoError(t, q.Ack(context.Background(), message, delivery))
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, TypeAcceptedWithoutID, publisher.events[0].Type)
+	assert.Equal(t, db.MessageStatusAcceptedWithoutID, publisher.events[0].Status)
+	assert.Empty(t, publisher.events[0].WebhookMessageID)
+}
+
+func TestQueue_NotDelivered(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	q := NewQueue(&fakeQueue{}, publisher)
+
+	now := time.Now()
+	expiring := &db.Message{ID: 1, To: "+905551111111", Status: db.MessageStatusSending, CallbackURL: "https://app.example.com/notices"}
+	require.NoError(t, q.Expire(ctx, expiring))
+	dead := &db.Message{ID: 2, To: "+905551111111", Status: db.MessageStatusSending, FailureClass: "provider_permanent", DeadLetteredAt: &now}
+	require.NoError(t, q.Fail(ctx, dead))
+
+	require.Len(t, publisher.events, 3)
+	assert.Equal(t, TypeExpired, publisher.events[0].Type)
+	assert.Equal(t, db.MessageStatusExpired, publisher.events[0].Status)
+	assert.Equal(t, "https://app.example.com/notices", publisher.events[0].CallbackURL)
+	assert.Equal(t, TypeFailed, publisher.events[1].Type)
+	assert.Equal(t, TypeDeadLettered, publisher.events[2].Type, "a dead-lettered failure follows its failed event")
+	assert.Equal(t, "provider_permanent", publisher.events[2].FailureClass)
+}
+
+func TestQueue_PublishIsBestEffort(t *testing.T) {
+	ctx := context.Background()
+
+	// a failing publisher never fails the queue operation
+	publisher := &recordingPublisher{err: errors.New("nats unavailable")}
+	require.NoError(t, NewQueue(&fakeQueue{}, publisher).Enqueue(ctx, &db.Message{To: "+905551111111"}))
+	assert.Len(t, publisher.events, 1)
+
+	// nothing is published when the queue operation fails
+	publisher = &recordingPublisher{}
+	q := NewQueue(&fakeQueue{err: errors.New("database unavailable")}, publisher)
+	assert.Error(t, q.Enqueue(ctx, &db.Message{To: "+905551111111"}))
+	assert.Error(t, q.Fail(ctx, &db.Message{ID: 1}))
+	assert.Empty(t, publisher.events)
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// flushTimeout bounds how long Close waits for the pending publish acks
+const flushTimeout = 5 * time.Second
+
+// JetStream publishes lifecycle events to <subject>.<event type> on a JetStream stream.
+// Publishes are asynchronous so the scheduler never waits for the server, failed acks are logged.
+type JetStream struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewJetStream connects to NATS and creates the events stream when it does not exist yet
+func NewJetStream(ctx context.Context, url string, cfg config.NATSEvents) (*JetStream, error) {
+	conn, err := nats.Connect(url, nats.Name("sendpulse"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn, jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, msg *nats.Msg, err error) {
+		config.Log().Warnf("Failed to publish event to %s: %v", msg.Subject, err)
+	}))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("creating jetstream context: %w", err)
+	}
+
+	_, err = js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.Subject + ".>"},
+	})
+	if err != nil && !errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		conn.Close()
+		return nil, fmt.Errorf("creating jetstream stream %s: %w", cfg.Stream, err)
+	}
+
+	return &JetStream{conn: conn, js: js, subject: cfg.Subject}, nil
+}
+
+// Publish queues the event for publishing
+func (j *JetStream) Publish(_ context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(j.subject + "." + string(event.Type))
+	msg.Data = data
+	_, err = j.js.PublishMsgAsync(msg)
+	return err
+}
+
+// Close waits for the pending publishes and closes the connection
+func (j *JetStream) Close() {
+	select {
+	case <-j.js.PublishAsyncComplete():
+	case <-time.After(flushTimeout):
+		config.Log().Warnf("Closing nats with %d unacknowledged events", j.js.PublishAsyncPending())
+	}
+	j.conn.Close()
+}
@@ -0,0 +1,236 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+)
+
+// Headers of a signed status notice, the ones providers sign their callbacks with
+const (
+	NoticeSignatureHeader     = "X-Signature"
+	NoticeSignatureTimeHeader = "X-Timestamp"
+)
+
+// Results of a status notice
+const (
+	NoticeDelivered = "delivered"
+	NoticeDropped   = "dropped"
+)
+
+// noticeBackoff is the wait before the second attempt of a notice, it doubles with every attempt
+const noticeBackoff = time.Second
+
+// Errors of the callback URLs notices are not posted to
+var (
+	ErrNoticeHostNotAllowed = errors.New("callback URL host is not in status_notices.allowed_hosts")
+	ErrNoticeAddressBlocked = errors.New("callback URL resolves to a private, loopback or link-local address")
+)
+
+// NoticeTypes are the events of the messages that were not delivered, the ones posted as status notices
+var NoticeTypes = []Type{TypeExpired, TypeBlocked, TypeDeadLettered}
+
+// Notifier posts the events of messages with a callback URL to it as status notices, so the caller that created
+// a message learns it will not be delivered. Run posts the NoticeTypes events of a bus subscription with it.
+type Notifier struct {
+	client  *http.Client
+	cfg     config.StatusNotices
+	allowed []string
+	backoff time.Duration
+	// ctx is cancelled by Stop, ending the posts of Run
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewNotifier creates a notifier posting with the timeout, attempts and allowed hosts of cfg. Its client only
+// dials public addresses and does not follow redirects, as the callback URLs are given by the callers.
+func NewNotifier(cfg config.StatusNotices) *Notifier {
+	allowed := make([]string, len(cfg.AllowedHosts))
+	for i, host := range cfg.AllowedHosts {
+		allowed[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(host), "."))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{
+		client:  newNoticeClient(cfg.Timeout, dialPublicOnly),
+		cfg:     cfg,
+		allowed: allowed,
+		backoff: noticeBackoff,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// newNoticeClient returns a client dialing through control that returns the redirects instead of following them
+func newNoticeClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	return &http.Client{
+		Timeout: timeout,
+		// no proxy, it would be dialed instead of the callback URL
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublicOnly refuses to connect to the addresses of the network of the instance. It runs for the resolved
+// address of every connection, so a host resolving to one, or re-resolving to one after it was checked, is refused.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrNoticeAddressBlocked
+	}
+	return nil
+}
+
+// Run posts the events of sub with status_notices.workers workers until the subscription is closed and its
+// events are posted, so the retries of a slow callback URL hold up one worker instead of every notice queued
+// behind them. Once Stop was called the remaining events are dropped instead, each of them counted and logged.
+func (n *Notifier) Run(sub *Subscription) {
+	var workers sync.WaitGroup
+	for range max(n.cfg.Workers, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for event := range sub.Events() {
+				if err := n.Publish(n.ctx, event); err != nil {
+					config.Log().WithField("subscriber", sub.name).WithField("message_id", event.MessageID).
+						Warnf("Failed to post %s status notice: %v", event.Type, err)
+				}
+			}
+		}()
+	}
+	workers.Wait()
+}
+
+// Stop cancels the posts of Run, the running ones and the ones still queued are dropped. The shutdown calls it
+// once status_notices.drain_timeout passed, so a dead callback URL does not hold it up.
+func (n *Notifier) Stop() {
+	n.cancel()
+}
+
+// Publish posts event to its callback URL, retrying failed posts up to status_notices.max_attempts times.
+// Events of messages without a callback URL are skipped, the ones of a host that is not allowed are dropped.
+func (n *Notifier) Publish(ctx context.Context, event Event) error {
+	if event.CallbackURL == "" {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		telemetry.RecordStatusNotice(string(event.Type), NoticeDropped)
+		return fmt.Errorf("status notice dropped: %w", err)
+	}
+	if !n.hostAllowed(event.CallbackURL) {
+		telemetry.RecordStatusNotice(string(event.Type), NoticeDropped)
+		return ErrNoticeHostNotAllowed
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	wait := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, event.CallbackURL, body)
+		if err == nil {
+			telemetry.RecordStatusNotice(string(event.Type), NoticeDelivered)
+			return nil
+		}
+		if errors.Is(err, ErrNoticeAddressBlocked) {
+			// the address would be refused again
+			telemetry.RecordStatusNotice(string(event.Type), NoticeDropped)
+			return err
+		}
+		if attempt >= n.cfg.MaxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			telemetry.RecordStatusNotice(string(event.Type), NoticeDropped)
+			return ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+	telemetry.RecordStatusNotice(string(event.Type), NoticeDropped)
+	return fmt.Errorf("status notice dropped after %d attempts: %w", n.cfg.MaxAttempts, err)
+}
+
+// hostAllowed reports whether the host of callbackURL is one of the allowed hosts or a subdomain of one
+func (n *Notifier) hostAllowed(callbackURL string) bool {
+	if len(n.allowed) == 0 {
+		return true
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range n.allowed {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(NoticeSignatureTimeHeader, timestamp)
+		req.Header.Set(NoticeSignatureHeader, "sha256="+SignNotice(n.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notice request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback URL returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignNotice returns the hex HMAC-SHA256 of timestamp, a '.' and body with secret
+func SignNotice(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   [][]byte
+		failures = 1
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests, bodies = append(requests, r), append(bodies, body)
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(config.StatusNotices{Timeout: time.Second, MaxAttempts: 2, Secret: "notice-secret"})
+	// the test server listens on loopback, which the notifier refuses to dial
+	notifier.client = newNoticeClient(time.Second, nil)
+	notifier.backoff = time.Millisecond
+	ctx := context.Background()
+
+	t.Run("without a callback URL", func(t *testing.T) {
+		require.NoError(t, notifier.Publish(ctx, Event{Type: TypeExpired, MessageID: 1}))
+		assert.Empty(t, requests)
+	})
+
+	t.Run("retried and signed", func(t *testing.T) {
+		before := testutil.ToFloat64(telemetry.StatusNotices.WithLabelValues(string(TypeExpired), NoticeDelivered))
+		event := NewEvent(TypeExpired, &db.Message{ID: 7, To: "+905551111111", Status: db.MessageStatusExpired,
+			CallbackURL: server.URL})
+		require.NoError(t, notifier.Publish(ctx, event))
+
+		require.Len(t, requests, 2, "the failed post is retried")
+		var notice map[string]any
+		require.NoError(t, json.Unmarshal(bodies[1], &notice))
+		assert.Equal(t, "expired", notice["type"])
+		assert.Equal(t, float64(7), notice["message_id"])
+		assert.NotContains(t, notice, "callback_url")
+
+		timestamp := requests[1].Header.Get(NoticeSignatureTimeHeader)
+		assert.Equal(t, "sha256="+SignNotice("notice-secret", timestamp, bodies[1]), requests[1].Header.Get(NoticeSignatureHeader))
+		assert.Equal(t, before+1, testutil.ToFloat64(telemetry.StatusNotices.WithLabelValues(string(TypeExpired), NoticeDelivered)))
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		mu.Lock()
+		failures, requests = 2, nil
+		mu.Unlock()
+		err := notifier.Publish(ctx, Event{Type: TypeBlocked, MessageID: 8, CallbackURL: server.URL})
+		assert.ErrorContains(t, err, "dropped after 2 attempts")
+		assert.Len(t, requests, 2)
+	})
+
+	t.Run("private addresses", func(t *testing.T) {
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+		guarded := NewNotifier(config.StatusNotices{Timeout: time.Second, MaxAttempts: 2})
+		err := guarded.Publish(ctx, Event{Type: TypeExpired, MessageID: 9, CallbackURL: server.URL})
+		assert.ErrorIs(t, err, ErrNoticeAddressBlocked)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("hosts that are not allowed", func(t *testing.T) {
+		allowlisted := NewNotifier(config.StatusNotices{Timeout: time.Second, MaxAttempts: 2,
+			AllowedHosts: []string{"hooks.example.com"}})
+		allowlisted.client = newNoticeClient(time.Second, nil)
+		err := allowlisted.Publish(ctx, Event{Type: TypeExpired, MessageID: 9, CallbackURL: server.URL})
+		assert.ErrorIs(t, err, ErrNoticeHostNotAllowed)
+		assert.Empty(t, requests)
+
+		assert.True(t, allowlisted.hostAllowed("https://hooks.example.com/notices"))
+		assert.True(t, allowlisted.hostAllowed("https://eu.hooks.example.com:8443/notices"))
+		assert.False(t, allowlisted.hostAllowed("https://hooks.example.com.evil.test/notices"))
+	})
+
+	t.Run("redirects are not followed", func(t *testing.T) {
+		var redirected []string
+		redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			redirected = append(redirected, r.URL.Path)
+			http.Redirect(w, r, "/internal", http.StatusFound)
+		}))
+		defer redirecting.Close()
+
+		err := notifier.Publish(ctx, Event{Type: TypeExpired, MessageID: 10, CallbackURL: redirecting.URL + "/notices"})
+		assert.ErrorContains(t, err, "status: 302")
+		assert.Equal(t, []string{"/notices", "/notices"}, redirected)
+	})
+}
+
+func TestNotifier_Run(t *testing.T) {
+	slowPosted, fastPosted := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			close(fastPosted)
+			return
+		}
+		close(slowPosted)
+		// the slow callback URL answers once the fast one was posted to
+		select {
+		case <-fastPosted:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(config.StatusNotices{Timeout: 10 * time.Second, MaxAttempts: 1, Workers: 2})
+	notifier.client = newNoticeClient(10*time.Second, nil)
+	bus := NewBus()
+	sub := bus.Subscribe("notices", DefaultBuffer, NoticeTypes...)
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(sub)
+		close(done)
+	}()
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeExpired, MessageID: 1, CallbackURL: server.URL + "/slow"}))
+	<-slowPosted
+	require.NoError(t, bus.Publish(ctx, Event{Type: TypeBlocked, MessageID: 2, CallbackURL: server.URL + "/fast"}))
+	select {
+	case <-fastPosted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the notice of the fast callback URL waited for the slow one")
+	}
+
+	bus.Close()
+	<-done
+}
+
+func TestNotifier_Stop(t *testing.T) {
+	posted, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- struct{}{}
+		// a dead callback URL does not answer until the test is over
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier := NewNotifier(config.StatusNotices{Timeout: time.Minute, MaxAttempts: 3, Workers: 1})
+	notifier.client = newNoticeClient(time.Minute, nil)
+	bus := NewBus()
+	sub := bus.Subscribe("notices", DefaultBuffer, NoticeTypes...)
+	for id := int64(1); id <= 3; id++ {
+		require.NoError(t, bus.Publish(context.Background(), Event{Type: TypeExpired, MessageID: id, CallbackURL: server.URL}))
+	}
+	bus.Close()
+	dropped := testutil.ToFloat64(telemetry.StatusNotices.WithLabelValues(string(TypeExpired), NoticeDropped))
+
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(sub)
+		close(done)
+	}()
+	<-posted
+	notifier.Stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run kept posting after Stop")
+	}
+	assert.Equal(t, dropped+3, testutil.ToFloat64(telemetry.StatusNotices.WithLabelValues(string(TypeExpired), NoticeDropped)))
+}
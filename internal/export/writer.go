@@ -0,0 +1,107 @@
+// Package export writes messages as CSV or JSON lines, for the export command and the export jobs of the API
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Export formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Writer writes exported messages in a single format
+type Writer interface {
+	Write(msg dto.MessageResponse) error
+	Flush() error
+}
+
+// ValidFormat checks that format is csv or jsonl
+func ValidFormat(format string) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return fmt.Errorf("unsupported export format %q, expected %s or %s", format, FormatCSV, FormatJSONL)
+	}
+	return nil
+}
+
+// ContentType returns the media type of an export in format
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// NewWriter returns a writer of format writing to w, CSV unless format is jsonl
+func NewWriter(format string, w io.Writer) Writer {
+	if format == FormatJSONL {
+		return &jsonlWriter{encoder: json.NewEncoder(w)}
+	}
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) Write(msg dto.MessageResponse) error {
+	return j.encoder.Encode(msg)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(msg dto.MessageResponse) error {
+	if !c.headerWritten {
+		c.headerWritten = true
+		header := []string{"id", "to", "content", "status", "priority", "scheduled_at", "sent_at", "message_id", "created_at", "tenant", "campaign"}
+		if err := c.writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	messageID := ""
+	if msg.MessageID != nil {
+		messageID = *msg.MessageID
+	}
+
+	return c.writer.Write([]string{
+		strconv.FormatInt(msg.ID, 10),
+		msg.To,
+		msg.Content,
+		msg.Status,
+		strconv.Itoa(msg.Priority),
+		formatOptionalTime(msg.ScheduledAt),
+		formatOptionalTime(msg.SentAt),
+		messageID,
+		msg.CreatedAt.Format(time.RFC3339),
+		msg.Tenant,
+		msg.Campaign,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
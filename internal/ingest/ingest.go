@@ -0,0 +1,241 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+
+	"github.com/uptrace/bun"
+)
+
+// DefaultBatchSize is the number of messages inserted per statement
+const DefaultBatchSize = 500
+
+// ReasonDuplicate is the rejection reason of rows repeating an earlier recipient and content
+const ReasonDuplicate = "duplicate of an earlier row"
+
+// Rejection describes a row that was not imported
+type Rejection struct {
+	Line    int
+	To      string
+	Content string
+	Reason  string
+}
+
+// Result summarizes an import
+type Result struct {
+	Read     int
+	Imported int
+	// Duplicates counts rows skipped because of an earlier identical row, they are included in Rejected
+	Duplicates int
+	Rejected   int
+}
+
+// Importer validates, deduplicates and bulk-inserts messages read from a Reader
+type Importer struct {
+	db        bun.IDB
+	batchSize int
+	seen      map[string]struct{}
+	// ids generates the public IDs of the messages created by the default Enqueue
+	ids db.IDStrategy
+
+	// OnRejected is called for every row that is not imported, including duplicates
+	OnRejected func(Rejection)
+	// OnProgress is called after every inserted batch
+	OnProgress func(Result)
+	// Enqueue inserts a batch, by default the messages are created directly in the database
+	Enqueue func(ctx context.Context, messages []*db.Message) error
+	// Rejects reports whether an error of Enqueue refuses a message rather than failing the import, like a content
+	// policy rejection. The messages of such a batch are enqueued one by one and the refused ones are rejected.
+	Rejects func(err error) bool
+}
+
+// NewImporter creates an importer, a batch size < 1 falls back to DefaultBatchSize
+func NewImporter(database bun.IDB, batchSize int) *Importer {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	importer := &Importer{
+		db:        database,
+		batchSize: batchSize,
+		seen:      make(map[string]struct{}),
+	}
+	importer.Enqueue = func(ctx context.Context, messages []*db.Message) error {
+		for _, message := range messages {
+			message.PublicID = db.NewPublicID(importer.ids)
+		}
+		return db.CreateMessages(ctx, importer.db, messages)
+	}
+	return importer
+}
+
+// SetIDStrategy gives the messages created by the default Enqueue a public ID generated by strategy
+func (i *Importer) SetIDStrategy(strategy db.IDStrategy) {
+	i.ids = strategy
+}
+
+// Import reads every row from r and inserts the valid ones in batches
+// Rows rejected by validation do not stop the import, reader and database errors do
+func (i *Importer) Import(ctx context.Context, r Reader) (*Result, error) {
+	result := &Result{}
+	batch := make([]*db.Message, 0, i.batchSize)
+	rows := make([]*Row, 0, i.batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := i.Enqueue(ctx, batch); err != nil {
+			if i.Rejects == nil || !i.Rejects(err) {
+				return err
+			}
+			if err := i.enqueueEach(ctx, result, batch, rows); err != nil {
+				return err
+			}
+		} else {
+			result.Imported += len(batch)
+		}
+		batch, rows = batch[:0], rows[:0]
+		if i.OnProgress != nil {
+			i.OnProgress(*result)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, ErrMalformedRow) {
+			return result, err
+		}
+		result.Read++
+
+		if err != nil {
+			i.reject(result, row, err.Error())
+			continue
+		}
+
+		message, err := i.Validate(row.Request)
+		if err != nil {
+			i.reject(result, row, err.Error())
+			continue
+		}
+
+		key := message.To + "\x00" + message.Content
+		if _, ok := i.seen[key]; ok {
+			result.Duplicates++
+			i.reject(result, row, ReasonDuplicate)
+			continue
+		}
+		i.seen[key] = struct{}{}
+
+		batch = append(batch, message)
+		rows = append(rows, row)
+		if len(batch) >= i.batchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// enqueueEach enqueues the messages of a batch one by one, rejecting the ones Enqueue refuses
+func (i *Importer) enqueueEach(ctx context.Context, result *Result, batch []*db.Message, rows []*Row) error {
+	for n, message := range batch {
+		if err := i.Enqueue(ctx, []*db.Message{message}); err != nil {
+			if !i.Rejects(err) {
+				return err
+			}
+			i.reject(result, rows[n], err.Error())
+			continue
+		}
+		result.Imported++
+	}
+	return nil
+}
+
+// Validate converts a create request into a message, checking recipient and content
+func (i *Importer) Validate(req dto.CreateMessageRequest) (*db.Message, error) {
+	return NewMessage(req)
+}
+
+// NewMessage converts a create request into a message and validates it, every ingestion path
+// (API, file import and the stream consumers) goes through it
+func NewMessage(req dto.CreateMessageRequest) (*db.Message, error) {
+	message := &db.Message{
+		To:          req.To,
+		Content:     req.Content,
+		Priority:    req.Priority,
+		Tenant:      req.Tenant,
+		Campaign:    req.Campaign,
+		From:        req.From,
+		Timezone:    req.Timezone,
+		Metadata:    db.Metadata(req.Metadata),
+		CallbackURL: req.CallbackURL,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		message.ExpiresAt = &expiresAt
+	}
+	if req.SendAt != nil {
+		sendAt := req.SendAt.UTC()
+		message.ScheduledAt = &sendAt
+	}
+	if req.SendAtLocal != "" {
+		sendAt, err := localSendTime(req)
+		if err != nil {
+			return nil, err
+		}
+		message.ScheduledAt = &sendAt
+	}
+
+	if err := db.ValidateMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// localSendTime resolves the send_at_local of req in its timezone
+func localSendTime(req dto.CreateMessageRequest) (time.Time, error) {
+	if req.SendAt != nil {
+		return time.Time{}, ErrConflictingSendTimes
+	}
+	if req.Timezone == "" {
+		return time.Time{}, ErrLocalTimeWithoutTimezone
+	}
+	location, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return time.Time{}, db.ErrInvalidTimezone
+	}
+	return LocalSendTime(time.Now(), location, req.SendAtLocal)
+}
+
+func (i *Importer) reject(result *Result, row *Row, reason string) {
+	result.Rejected++
+	if i.OnRejected == nil {
+		return
+	}
+
+	rejection := Rejection{Reason: reason}
+	if row != nil {
+		rejection.Line = row.Line
+		rejection.To = row.Request.To
+		rejection.Content = row.Request.Content
+	}
+	i.OnRejected(rejection)
+}
@@ -0,0 +1,375 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+
+	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Suppression)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+
+	return bunDB
+}
+
+func TestCSVReader(t *testing.T) {
+	input := "to,content,priority,send_at,from\n" +
+		"+905551111111,Hello,5,2030-01-01T09:00:00Z,ACME\n" +
+		"+905552222222,World,,,\n" +
+		"+905553333333,Bad priority,high,,\n"
+
+	reader, err := NewCSVReader(strings.NewReader(input))
+	require.NoError(t, err)
+
+	row, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, 2, row.Line)
+	assert.Equal(t, "+905551111111", row.Request.To)
+	assert.Equal(t, 5, row.Request.Priority)
+	assert.NotNil(t, row.Request.SendAt)
+	assert.Equal(t, "ACME", row.Request.From)
+
+	row, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "World", row.Request.Content)
+	assert.Nil(t, row.Request.SendAt)
+
+	row, err = reader.Read()
+	assert.True(t, errors.Is(err, ErrMalformedRow))
+	assert.Equal(t, 4, row.Line)
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestCSVReader_MissingColumn(t *testing.T) {
+	_, err := NewCSVReader(strings.NewReader("to,body\n"))
+
+	assert.True(t, errors.Is(err, ErrMissingColumn))
+}
+
+func TestJSONReader(t *testing.T) {
+	inputs := map[string]string{
+		"array":      `[{"to": "+905551111111", "content": "Hello"}, {"to": "+905552222222", "content": "World"}]`,
+		"json lines": "{\"to\": \"+905551111111\", \"content\": \"Hello\"}\n{\"to\": \"+905552222222\", \"content\": \"World\"}\n",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			reader := NewJSONReader(strings.NewReader(input))
+
+			row, err := reader.Read()
+			require.NoError(t, err)
+			assert.Equal(t, "Hello", row.Request.Content)
+
+			row, err = reader.Read()
+			require.NoError(t, err)
+			assert.Equal(t, "World", row.Request.Content)
+
+			_, err = reader.Read()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestCountJSON(t *testing.T) {
+	for input, expected := range map[string]int{
+		`[{"to": "+905551111111", "content": "Hello"}, {"to": "+905552222222", "content": "World"}]`: 2,
+		"{\"to\": \"+905551111111\"}\n{\"to\": \"+905552222222\"}\n{\"priority\": \"high\"}\n":       3,
+		"[]": 0,
+		"":   0,
+	} {
+		count, err := CountJSON(strings.NewReader(input))
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, count, input)
+	}
+
+	_, err := CountJSON(strings.NewReader(`[{"to": "+905551111111"`))
+	assert.Error(t, err)
+	_, err = CountJSON(strings.NewReader(`"messages"`))
+	assert.True(t, errors.Is(err, ErrUnsupportedInput))
+}
+
+func TestImporter_Import(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	input := "to,content\n" +
+		"+905551111111,Hello\n" +
+		"+905551111111,Hello\n" +
+		"05552222222,Invalid phone\n" +
+		"+905553333333," + strings.Repeat("a", 10*sms.GSM7MultipartSegment+1) + "\n" +
+		"+905554444444,World\n" +
+		"+905555555555,Again\n"
+
+	reader, err := NewCSVReader(strings.NewReader(input))
+	require.NoError(t, err)
+
+	var rejections []Rejection
+	var progress []Result
+	importer := NewImporter(testDB, 2)
+	importer.OnRejected = func(r Rejection) { rejections = append(rejections, r) }
+	importer.OnProgress = func(r Result) { progress = append(progress, r) }
+
+	result, err := importer.Import(context.Background(), reader)
+
+	require.NoError(t, err)
+	assert.Equal(t, 6, result.Read)
+	assert.Equal(t, 3, result.Imported)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 3, result.Rejected)
+	assert.Len(t, progress, 2) // One full batch of 2 and the final partial batch
+
+	require.Len(t, rejections, 3)
+	assert.Equal(t, 3, rejections[0].Line)
+	assert.Equal(t, ReasonDuplicate, rejections[0].Reason)
+	assert.Equal(t, db.ErrInvalidPhoneNumber.Error(), rejections[1].Reason)
+	assert.Equal(t, db.ErrMessageTooLong.Error(), rejections[2].Reason)
+
+	count, err := db.CountMessages(context.Background(), testDB, db.MessageFilter{Status: db.MessageStatusPending})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestImporter_Rejects(t *testing.T) {
+	errRefused := errors.New("refused")
+	var enqueued []string
+	importer := NewImporter(nil, 3)
+	importer.Enqueue = func(_ context.Context, messages []*db.Message) error {
+		for _, message := range messages {
+			if strings.Contains(message.Content, "casino") {
+				return errRefused
+			}
+		}
+		for _, message := range messages {
+			enqueued = append(enqueued, message.Content)
+		}
+		return nil
+	}
+	importer.Rejects = func(err error) bool { return errors.Is(err, errRefused) }
+	var rejections []Rejection
+	importer.OnRejected = func(r Rejection) { rejections = append(rejections, r) }
+
+	input := `[{"to": "+905551111111", "content": "Hello"}, {"to": "+905552222222", "content": "casino"},
+		{"to": "+905553333333", "content": "World"}, {"to": "+905554444444", "content": "Again"}]`
+	result, err := importer.Import(context.Background(), NewJSONReader(strings.NewReader(input)))
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Imported)
+	assert.Equal(t, 1, result.Rejected)
+	assert.Equal(t, []string{"Hello", "World", "Again"}, enqueued)
+	require.Len(t, rejections, 1)
+	assert.Equal(t, 2, rejections[0].Line)
+	assert.Equal(t, "refused", rejections[0].Reason)
+
+	// other errors still stop the import
+	importer = NewImporter(nil, 3)
+	importer.Enqueue = func(context.Context, []*db.Message) error { return errors.New("database is down") }
+	importer.Rejects = func(err error) bool { return errors.Is(err, errRefused) }
+	_, err = importer.Import(context.Background(), NewJSONReader(strings.NewReader(input)))
+	assert.EqualError(t, err, "database is down")
+}
+
+func TestLocalSendTime(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		location *time.Location
+		local    string
+		want     time.Time
+	}{
+		{"later today", time.Date(2026, 10, 16, 5, 0, 0, 0, time.UTC), istanbul, "09:00", time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)},
+		{"passed today", time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC), istanbul, "09:00", time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)},
+		{"date and time", time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC), newYork, "2026-12-01T09:00", time.Date(2026, 12, 1, 14, 0, 0, 0, time.UTC)},
+		{"across the DST change", time.Date(2026, 10, 31, 20, 0, 0, 0, time.UTC), newYork, "09:00", time.Date(2026, 11, 1, 14, 0, 0, 0, time.UTC)},
+		{"skipped by the DST change", time.Date(2026, 3, 7, 20, 0, 0, 0, time.UTC), newYork, "02:30", time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC)},
+		{"repeated by the DST change", time.Date(2026, 10, 31, 20, 0, 0, 0, time.UTC), newYork, "01:30", time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LocalSendTime(tt.now, tt.location, tt.local)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = LocalSendTime(time.Now(), istanbul, "9am")
+	assert.ErrorIs(t, err, ErrInvalidLocalTime)
+}
+
+func TestNewMessage_LocalTime(t *testing.T) {
+	message, err := NewMessage(dto.CreateMessageRequest{To: "+905551111111", Content: "Good morning", SendAtLocal: "09:00", Timezone: "Europe/Istanbul"})
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Istanbul", message.Timezone)
+	require.NotNil(t, message.ScheduledAt)
+	assert.Equal(t, 6, message.ScheduledAt.Hour(), "09:00 in Istanbul is 06:00 UTC")
+	assert.True(t, message.ScheduledAt.After(time.Now()))
+
+	sendAt := time.Now()
+	for _, req := range []struct {
+		req dto.CreateMessageRequest
+		err error
+	}{
+		{dto.CreateMessageRequest{SendAtLocal: "09:00"}, ErrLocalTimeWithoutTimezone},
+		{dto.CreateMessageRequest{SendAtLocal: "09:00", Timezone: "Europe/Istanbul", SendAt: &sendAt}, ErrConflictingSendTimes},
+		{dto.CreateMessageRequest{SendAtLocal: "09:00", Timezone: "Mars/Olympus"}, db.ErrInvalidTimezone},
+		{dto.CreateMessageRequest{Timezone: "Local"}, db.ErrInvalidTimezone},
+	} {
+		req.req.To, req.req.Content = "+905551111111", "Good morning"
+		_, err := NewMessage(req.req)
+		assert.ErrorIs(t, err, req.err)
+	}
+}
+
+func TestNewMessage_From(t *testing.T) {
+	tests := []struct {
+		from  string
+		valid bool
+	}{
+		{"ACME", true},
+		{"Spring Sale", true},
+		{"ACME-DE", true},
+		{"+905551111111", true},
+		{"12345", true},
+		{"ACMEMARKETING", false},
+		{" ACME", false},
+		{"ACME!", false},
+		{"+0551111111", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			message, err := NewMessage(dto.CreateMessageRequest{To: "+905551111111", Content: "Hello", From: tt.from})
+			if !tt.valid {
+				assert.ErrorIs(t, err, db.ErrInvalidSender)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.from, message.From)
+		})
+	}
+}
+
+func TestNewMessage_Expiry(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name        string
+		expiresAt   *time.Time
+		sendAt      *time.Time
+		callbackURL string
+		err         error
+	}{
+		{"expiring with a callback", at(time.Hour), nil, "https://app.example.com/notices", nil},
+		{"expiring after the send time", at(2 * time.Hour), at(time.Hour), "", nil},
+		{"expired already", at(-time.Minute), nil, "", db.ErrInvalidExpiry},
+		{"expiring before the send time", at(time.Hour), at(2 * time.Hour), "", db.ErrInvalidExpiry},
+		{"relative callback", nil, nil, "/notices", db.ErrInvalidCallbackURL},
+		{"callback of another scheme", nil, nil, "ftp://app.example.com/notices", db.ErrInvalidCallbackURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := NewMessage(dto.CreateMessageRequest{To: "+905551111111", Content: "Hello",
+				ExpiresAt: tt.expiresAt, SendAt: tt.sendAt, CallbackURL: tt.callbackURL})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.expiresAt.Equal(*message.ExpiresAt))
+			assert.Equal(t, tt.callbackURL, message.CallbackURL)
+		})
+	}
+}
+
+func TestSuppressionReader(t *testing.T) {
+	read := func(t *testing.T, reader SuppressionReader) []*SuppressionRow {
+		var rows []*SuppressionRow
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return rows
+			}
+			require.NoError(t, err)
+			rows = append(rows, row)
+		}
+	}
+
+	t.Run("provider export", func(t *testing.T) {
+		input := "\ufeffDate,MSISDN,Opt-Out Reason\n" +
+			"2026-01-02,905551111111,STOP reply\n" +
+			"2026-01-03,not a number,\n"
+
+		reader, err := NewSuppressionReader(FormatCSV, strings.NewReader(input))
+		require.NoError(t, err)
+		rows := read(t, reader)
+
+		require.Len(t, rows, 2)
+		assert.Equal(t, &SuppressionRow{Line: 2, Raw: "905551111111", Phone: "+905551111111", Reason: "STOP reply"}, rows[0])
+		assert.Equal(t, 3, rows[1].Line)
+		assert.Empty(t, rows[1].Phone)
+	})
+
+	t.Run("csv without a header", func(t *testing.T) {
+		reader, err := NewSuppressionReader(FormatCSV, strings.NewReader("+90 555 111 11 11,Complaint\n00905552222222\n"))
+		require.NoError(t, err)
+		rows := read(t, reader)
+
+		require.Len(t, rows, 2)
+		assert.Equal(t, "+905551111111", rows[0].Phone)
+		assert.Empty(t, rows[0].Reason, "lists without a header have no reason column")
+		assert.Equal(t, "+905552222222", rows[1].Phone)
+		assert.Equal(t, 2, rows[1].Line)
+	})
+
+	t.Run("csv without a phone column", func(t *testing.T) {
+		_, err := NewSuppressionReader(FormatCSV, strings.NewReader("name,email\n"))
+		assert.True(t, errors.Is(err, ErrMissingColumn))
+	})
+
+	t.Run("text", func(t *testing.T) {
+		input := "# exported 2026-01-02\n\n+905551111111\ntel:+90-555-222-2222\n"
+
+		reader, err := NewSuppressionReader(FormatText, strings.NewReader(input))
+		require.NoError(t, err)
+		rows := read(t, reader)
+
+		require.Len(t, rows, 2)
+		assert.Equal(t, 3, rows[0].Line)
+		assert.Equal(t, "+905552222222", rows[1].Phone)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := NewSuppressionReader("xlsx", strings.NewReader(""))
+		assert.True(t, errors.Is(err, ErrUnsupportedInput))
+	})
+}
@@ -0,0 +1,58 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Layouts of the local send times, a time of day is sent at its next occurrence
+const (
+	localTimeOfDay = "15:04"
+	localDateTime  = "2006-01-02T15:04"
+)
+
+var (
+	ErrLocalTimeWithoutTimezone = errors.New("send_at_local requires a timezone")
+	ErrConflictingSendTimes     = errors.New("send_at and send_at_local cannot be used together")
+	ErrInvalidLocalTime         = errors.New("send_at_local must be HH:MM or YYYY-MM-DDTHH:MM")
+)
+
+// LocalSendTime returns the UTC time local is at in location, local being a time of day (HH:MM), sent at its
+// next occurrence after now, or a date and time (YYYY-MM-DDTHH:MM). A time skipped by a daylight saving
+// change is sent when the skipped hour ends, a time repeated by one at its first occurrence.
+func LocalSendTime(now time.Time, location *time.Location, local string) (time.Time, error) {
+	if t, err := time.ParseInLocation(localDateTime, local, location); err == nil {
+		return inLocation(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), location).UTC(), nil
+	}
+
+	clock, err := time.Parse(localTimeOfDay, local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidLocalTime, local)
+	}
+	today := now.In(location)
+	at := inLocation(today.Year(), today.Month(), today.Day(), clock.Hour(), clock.Minute(), location)
+	if !at.After(now) {
+		at = inLocation(today.Year(), today.Month(), today.Day()+1, clock.Hour(), clock.Minute(), location)
+	}
+	return at.UTC(), nil
+}
+
+// inLocation is time.Date resolving the wall clocks daylight saving changes skip or repeat deterministically
+func inLocation(year int, month time.Month, day, hour, minute int, location *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, minute, 0, 0, location)
+	if t.Hour() == hour && t.Minute() == minute {
+		// a repeated wall clock resolves to either offset, the earlier instant is its first occurrence
+		if earlier := t.Add(-time.Hour); earlier.Hour() == hour && earlier.Minute() == minute {
+			return earlier
+		}
+		return t
+	}
+
+	// skipped: time.Date applied one of the offsets around the change, the zone bounds give the end of the gap
+	start, end := t.ZoneBounds()
+	if t.Hour()*60+t.Minute() > hour*60+minute {
+		return start
+	}
+	return end
+}
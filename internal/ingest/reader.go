@@ -0,0 +1,217 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Supported import formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var (
+	ErrMalformedRow     = errors.New("malformed row")
+	ErrMissingColumn    = errors.New("missing required column")
+	ErrUnsupportedInput = errors.New("unsupported import format")
+)
+
+// Row is a single message read from an import source
+type Row struct {
+	Line    int
+	Request dto.CreateMessageRequest
+}
+
+// Reader streams rows from an import source, returning io.EOF when done
+// Errors wrapping ErrMalformedRow only affect the current row, reading can continue
+type Reader interface {
+	Read() (*Row, error)
+}
+
+// NewReader returns a reader for the given format
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r)
+	case FormatJSON:
+		return NewJSONReader(r), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedInput, format)
+}
+
+// csvReader reads rows from CSV with a header line
+// Required columns are to and content, priority, send_at (RFC3339), send_at_local, timezone, tenant, campaign
+// and from are optional
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVReader reads the header line and returns a CSV row reader
+func NewCSVReader(r io.Reader) (Reader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"to", "content"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, required)
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvReader) Read() (*Row, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &Row{Line: parseErr.Line}, fmt.Errorf("%w: %s", ErrMalformedRow, parseErr.Err)
+		}
+		return nil, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+	row := &Row{
+		Line: line,
+		Request: dto.CreateMessageRequest{
+			To:          c.field(record, "to"),
+			Content:     c.field(record, "content"),
+			Tenant:      c.field(record, "tenant"),
+			Campaign:    c.field(record, "campaign"),
+			From:        c.field(record, "from"),
+			SendAtLocal: c.field(record, "send_at_local"),
+			Timezone:    c.field(record, "timezone"),
+		},
+	}
+
+	if priority := c.field(record, "priority"); priority != "" {
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			return row, fmt.Errorf("%w: invalid priority %q", ErrMalformedRow, priority)
+		}
+		row.Request.Priority = p
+	}
+
+	if sendAt := c.field(record, "send_at"); sendAt != "" {
+		t, err := time.Parse(time.RFC3339, sendAt)
+		if err != nil {
+			return row, fmt.Errorf("%w: invalid send_at %q", ErrMalformedRow, sendAt)
+		}
+		row.Request.SendAt = &t
+	}
+
+	return row, nil
+}
+
+func (c *csvReader) field(record []string, name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// jsonReader reads rows from either a JSON array or newline delimited JSON objects
+type jsonReader struct {
+	input   *bufio.Reader
+	decoder *json.Decoder
+	index   int
+}
+
+// NewJSONReader returns a reader for a JSON array or JSON lines input
+func NewJSONReader(r io.Reader) Reader {
+	return &jsonReader{input: bufio.NewReader(r)}
+}
+
+func (j *jsonReader) Read() (*Row, error) {
+	if j.decoder == nil {
+		if err := j.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !j.decoder.More() {
+		return nil, io.EOF
+	}
+
+	j.index++
+	row := &Row{Line: j.index}
+	if err := j.decoder.Decode(&row.Request); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var timeErr *time.ParseError
+		if errors.As(err, &typeErr) || errors.As(err, &timeErr) {
+			return row, fmt.Errorf("%w: %s", ErrMalformedRow, err)
+		}
+		return nil, fmt.Errorf("failed to decode record %d: %w", j.index, err)
+	}
+
+	return row, nil
+}
+
+// CountJSON returns the number of messages in a JSON array or JSON lines input without decoding them
+func CountJSON(r io.Reader) (int, error) {
+	j := &jsonReader{input: bufio.NewReader(r)}
+	if err := j.start(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	count := 0
+	for j.decoder.More() {
+		var raw json.RawMessage
+		if err := j.decoder.Decode(&raw); err != nil {
+			return count, fmt.Errorf("failed to decode record %d: %w", count+1, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// start creates the decoder, consuming the opening bracket if the input is a JSON array
+func (j *jsonReader) start() error {
+	for {
+		b, err := j.input.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			continue
+		}
+		if err := j.input.UnreadByte(); err != nil {
+			return err
+		}
+
+		j.decoder = json.NewDecoder(j.input)
+		switch b {
+		case '[':
+			_, err := j.decoder.Token()
+			return err
+		case '{':
+			return nil
+		}
+		return fmt.Errorf("%w: expected a JSON array of messages or one JSON object per line", ErrUnsupportedInput)
+	}
+}
@@ -0,0 +1,176 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+)
+
+// FormatText is an opt-out list of one phone number per line, lines starting with # are comments
+const FormatText = "text"
+
+// phoneColumns are the header names of the phone number column of opt-out lists, in order of preference. They
+// cover the exports of the common providers, which list the numbers as msisdn, phone_number or recipient.
+var phoneColumns = []string{"phone", "phone_number", "phonenumber", "number", "msisdn", "mobile", "recipient", "to"}
+
+// reasonColumns are the header names of the reason column of opt-out lists, in order of preference
+var reasonColumns = []string{"reason", "opt_out_reason", "comment", "note"}
+
+// SuppressionRow is a phone number read from an opt-out list, Phone is empty when Raw is not a phone number
+type SuppressionRow struct {
+	Line   int
+	Raw    string
+	Phone  string
+	Reason string
+}
+
+// SuppressionReader streams the numbers of an opt-out list, returning io.EOF when done.
+// Errors wrapping ErrMalformedRow only affect the current row, reading can continue.
+type SuppressionReader interface {
+	Read() (*SuppressionRow, error)
+}
+
+// NewSuppressionReader returns a reader of an opt-out list in format, csv or text
+func NewSuppressionReader(format string, r io.Reader) (SuppressionReader, error) {
+	switch format {
+	case FormatCSV:
+		return newSuppressionCSVReader(r)
+	case FormatText:
+		return &suppressionTextReader{scanner: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s, expected %s or %s", ErrUnsupportedInput, format, FormatCSV, FormatText)
+}
+
+// NormalizePhone turns the ways opt-out lists write phone numbers into E.164: separators, a tel: scheme and a 00
+// international prefix are removed, and numbers of digits only, the MSISDN format of provider exports, get a +.
+// It reports false when the result is not an E.164 phone number.
+func NormalizePhone(raw string) (string, bool) {
+	phone := strings.TrimPrefix(strings.TrimSpace(raw), "tel:")
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '/':
+			return -1
+		}
+		return r
+	}, phone)
+	if rest, ok := strings.CutPrefix(phone, "00"); ok {
+		phone = "+" + rest
+	} else if !strings.HasPrefix(phone, "+") {
+		phone = "+" + phone
+	}
+	return phone, db.ValidatePhone(phone) == nil
+}
+
+// suppressionCSVReader reads opt-out lists from CSV. A header naming one of phoneColumns picks the number column,
+// lists without a header have the number in their first column.
+type suppressionCSVReader struct {
+	reader *csv.Reader
+	phone  int
+	reason int
+	// first is the first record when it is a row rather than a header
+	first []string
+}
+
+func newSuppressionCSVReader(r io.Reader) (*suppressionCSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return &suppressionCSVReader{reader: reader, reason: -1}, nil
+		}
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	c := &suppressionCSVReader{reader: reader, phone: -1, reason: -1}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[strings.NewReplacer(" ", "_", "-", "_").Replace(name)] = i
+	}
+	for _, name := range phoneColumns {
+		if i, ok := columns[name]; ok {
+			c.phone = i
+			break
+		}
+	}
+	for _, name := range reasonColumns {
+		if i, ok := columns[name]; ok {
+			c.reason = i
+			break
+		}
+	}
+	if c.phone >= 0 {
+		return c, nil
+	}
+
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	if _, ok := NormalizePhone(header[0]); !ok {
+		return nil, fmt.Errorf("%w: phone, expected one of %s or a list without a header", ErrMissingColumn, strings.Join(phoneColumns, ", "))
+	}
+	c.phone, c.reason, c.first = 0, -1, header
+	return c, nil
+}
+
+func (c *suppressionCSVReader) Read() (*SuppressionRow, error) {
+	record, line := c.first, 1
+	if record != nil {
+		c.first = nil
+	} else {
+		var err error
+		if record, err = c.reader.Read(); err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return &SuppressionRow{Line: parseErr.Line}, fmt.Errorf("%w: %s", ErrMalformedRow, parseErr.Err)
+			}
+			return nil, err
+		}
+		line, _ = c.reader.FieldPos(0)
+	}
+
+	row := &SuppressionRow{Line: line, Raw: recordField(record, c.phone), Reason: recordField(record, c.reason)}
+	if phone, ok := NormalizePhone(row.Raw); ok {
+		row.Phone = phone
+	}
+	return row, nil
+}
+
+func recordField(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// suppressionTextReader reads opt-out lists of one number per line, skipping blank lines and comments
+type suppressionTextReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (t *suppressionTextReader) Read() (*SuppressionRow, error) {
+	for t.scanner.Scan() {
+		t.line++
+		raw := strings.TrimSpace(strings.TrimPrefix(t.scanner.Text(), "\ufeff"))
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+
+		row := &SuppressionRow{Line: t.line, Raw: raw}
+		if phone, ok := NormalizePhone(raw); ok {
+			row.Phone = phone
+		}
+		return row, nil
+	}
+	if err := t.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,130 @@
+// Package links replaces the links in message content with tracked short links when messages are enqueued.
+package links
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/uptrace/bun"
+)
+
+// CodeLength is the length of the generated link codes, 62^8 codes make collisions unlikely
+const CodeLength = 8
+
+const codeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// linkPattern finds links with a scheme or starting with www., bare domains are left alone since they
+// cannot be told apart from other dotted words reliably
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://|\bwww\.)\S+`)
+
+// Shortener replaces links with short links under its base URL
+type Shortener struct {
+	baseURL string
+}
+
+// NewShortener creates a shortener for the short links of cfg
+func NewShortener(cfg config.LinkTracking) *Shortener {
+	return &Shortener{baseURL: strings.TrimRight(cfg.BaseURL, "/")}
+}
+
+// ShortURL returns the short link of code
+func (s *Shortener) ShortURL(code string) string {
+	return s.baseURL + "/" + code
+}
+
+// Shorten returns content with its links replaced by short links and the links to store.
+// Trailing punctuation is not part of a link and links that already are short links are kept.
+func (s *Shortener) Shorten(content string) (string, []*db.Link, error) {
+	var links []*db.Link
+	var genErr error
+
+	shortened := linkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		target := strings.TrimRight(match, ".,;:!?)]}'\"")
+		suffix := match[len(target):]
+		if genErr != nil || strings.HasPrefix(target, s.baseURL+"/") {
+			return match
+		}
+
+		code, err := newCode()
+		if err != nil {
+			genErr = err
+			return match
+		}
+		if !strings.Contains(target, "://") {
+			target = "http://" + target
+		}
+
+		links = append(links, &db.Link{Code: code, URL: target})
+		return s.ShortURL(code) + suffix
+	})
+	if genErr != nil {
+		return "", nil, genErr
+	}
+	return shortened, links, nil
+}
+
+func newCode() (string, error) {
+	random := make([]byte, CodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generating link code: %w", err)
+	}
+
+	code := make([]byte, CodeLength)
+	for i, b := range random {
+		code[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// Queue shortens the links of messages before enqueueing them to the wrapped queue. The links are stored
+// first and attached to their message once it is stored, a link whose message failed to store is never used.
+type Queue struct {
+	queue.Queue
+	db        *bun.DB
+	shortener *Shortener
+}
+
+// NewQueue wraps q with link tracking, q is returned as is when link tracking is disabled
+func NewQueue(q queue.Queue, database *bun.DB, cfg config.LinkTracking) queue.Queue {
+	if !cfg.Enabled {
+		return q
+	}
+	return &Queue{Queue: q, db: database, shortener: NewShortener(cfg)}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	codes := make([][]string, len(messages))
+	var links []*db.Link
+	for i, message := range messages {
+		content, messageLinks, err := q.shortener.Shorten(message.Content)
+		if err != nil {
+			return err
+		}
+		message.Content = content
+		for _, link := range messageLinks {
+			codes[i] = append(codes[i], link.Code)
+		}
+		links = append(links, messageLinks...)
+	}
+
+	if err := db.CreateLinks(ctx, q.db, links); err != nil {
+		return fmt.Errorf("storing links: %w", err)
+	}
+	if err := q.Queue.Enqueue(ctx, messages...); err != nil {
+		return err
+	}
+
+	// the messages are stored at this point, failing would make the caller enqueue them again
+	for i, message := range messages {
+		if err := db.AttachLinks(ctx, q.db, message.ID, codes[i]); err != nil {
+			config.LogFrom(ctx).WithError(err).WithField("message_id", message.ID).Error("Failed to attach links to message, their clicks are not counted for it")
+		}
+	}
+	return nil
+}
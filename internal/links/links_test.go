@@ -0,0 +1,100 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+const baseURL = "https://sp.example.com/l"
+
+func setupTestDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:")
+	require.NoError(t, err)
+	// every connection would get its own in-memory database
+	sqldb.SetMaxOpenConns(1)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Suppression)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Link)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { bunDB.Close() })
+
+	return bunDB
+}
+
+func TestShortener_Shorten(t *testing.T) {
+	shortener := NewShortener(config.LinkTracking{BaseURL: baseURL + "/"})
+
+	tests := []struct {
+		name    string
+		content string
+		urls    []string
+	}{
+		{"no links", "Your code is 1234", nil},
+		{"link", "Sale: https://example.com/spring?utm=sms", []string{"https://example.com/spring?utm=sms"}},
+		{"trailing punctuation", "See http://example.com/a, or (https://example.org/b).", []string{"http://example.com/a", "https://example.org/b"}},
+		{"www link", "Visit www.example.com now", []string{"http://www.example.com"}},
+		{"bare domains are kept", "Visit example.com/spring", nil},
+		{"short links are kept", "Visit " + baseURL + "/aZ3kP9qR", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, links, err := shortener.Shorten(tt.content)
+			require.NoError(t, err)
+
+			var urls []string
+			for _, link := range links {
+				assert.Len(t, link.Code, CodeLength)
+				assert.Contains(t, content, baseURL+"/"+link.Code)
+				urls = append(urls, link.URL)
+			}
+			assert.Equal(t, tt.urls, urls)
+			if len(links) == 0 {
+				assert.Equal(t, tt.content, content)
+			}
+		})
+	}
+}
+
+func TestQueue(t *testing.T) {
+	testDB := setupTestDB(t)
+	ctx := context.Background()
+
+	t.Run("disabled link tracking does not wrap the queue", func(t *testing.T) {
+		q := queue.NewPostgres(testDB)
+		assert.Same(t, q, NewQueue(q, testDB, config.LinkTracking{}))
+	})
+
+	q := NewQueue(queue.NewPostgres(testDB), testDB, config.LinkTracking{Enabled: true, BaseURL: baseURL})
+	tracked := &db.Message{To: "+905551111111", Content: "Sale at https://example.com/spring!", Campaign: "spring"}
+	plain := &db.Message{To: "+905552222222", Content: "Your code is 1234"}
+	require.NoError(t, q.Enqueue(ctx, tracked, plain))
+
+	links, err := db.GetMessageLinks(ctx, testDB, tracked.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://example.com/spring", links[0].URL)
+	assert.Equal(t, "Sale at "+baseURL+"/"+links[0].Code+"!", tracked.Content)
+
+	stored, err := db.GetMessageByID(ctx, testDB, tracked.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tracked.Content, stored.Content)
+
+	links, err = db.GetMessageLinks(ctx, testDB, plain.ID)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
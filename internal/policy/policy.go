@@ -0,0 +1,194 @@
+// Package policy checks message content against the configured content policy rules when messages are enqueued.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+)
+
+// ErrRejected is returned when a reject rule matched one of the enqueued messages
+var ErrRejected = errors.New("message rejected by content policy")
+
+// linkPattern finds links with a scheme, starting with www. or a bare domain followed by a path
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://\S+|\bwww\.\S+|\b(?:[a-z0-9-]+\.)+[a-z]{2,}/\S*)`)
+
+// Violation is a rule a message violated
+type Violation struct {
+	Rule   string
+	Action string
+	Reason string
+}
+
+// rule is a compiled content rule, check returns why content violates it or an empty string
+type rule struct {
+	name          string
+	action        string
+	campaignsOnly bool
+	check         func(content string) string
+}
+
+// Engine checks messages against the content policy rules
+type Engine struct {
+	rules []rule
+}
+
+// New compiles the rules of cfg, an engine without rules accepts every message
+func New(cfg config.ContentPolicy) (*Engine, error) {
+	engine := &Engine{}
+	for _, r := range cfg.Rules {
+		compiled := rule{name: r.Name, action: r.Action, campaignsOnly: r.CampaignsOnly}
+		switch r.Action {
+		case config.ContentActionReject, config.ContentActionQuarantine, config.ContentActionWarn:
+		default:
+			return nil, fmt.Errorf("content policy rule %q: unknown action %q", r.Name, r.Action)
+		}
+
+		switch r.Type {
+		case config.ContentRuleBannedTerms:
+			compiled.check = bannedTerms(r.Terms)
+		case config.ContentRuleURLAllowlist:
+			compiled.check = urlAllowlist(r.AllowedDomains)
+		case config.ContentRuleOptOutText:
+			compiled.check = optOutText(r.Terms)
+		default:
+			return nil, fmt.Errorf("content policy rule %q: unknown type %q", r.Name, r.Type)
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	return engine, nil
+}
+
+// Check returns the rules message violates in the configured order
+func (e *Engine) Check(message *db.Message) []Violation {
+	var violations []Violation
+	for _, r := range e.rules {
+		if r.campaignsOnly && message.Campaign == "" {
+			continue
+		}
+		if reason := r.check(message.Content); reason != "" {
+			violations = append(violations, Violation{Rule: r.name, Action: r.action, Reason: reason})
+		}
+	}
+	return violations
+}
+
+// bannedTerms matches any of the terms as a whole word, letters and digits around a term are not a match
+func bannedTerms(terms []string) func(string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(` + strings.Join(quoted, "|") + `)(?:[^\p{L}\p{N}]|$)`)
+
+	return func(content string) string {
+		if match := pattern.FindStringSubmatch(content); match != nil {
+			return fmt.Sprintf("contains banned term %q", match[1])
+		}
+		return ""
+	}
+}
+
+// urlAllowlist matches links to hosts that are neither an allowed domain nor one of its subdomains
+func urlAllowlist(domains []string) func(string) string {
+	allowed := make([]string, len(domains))
+	for i, domain := range domains {
+		allowed[i] = strings.ToLower(strings.TrimPrefix(domain, "."))
+	}
+
+	return func(content string) string {
+		for _, link := range linkPattern.FindAllString(content, -1) {
+			host := linkHost(link)
+			if !domainAllowed(host, allowed) {
+				return fmt.Sprintf("links to %s which is not allowlisted", host)
+			}
+		}
+		return ""
+	}
+}
+
+// optOutText matches content containing none of the opt-out texts
+func optOutText(terms []string) func(string) string {
+	return func(content string) string {
+		lower := strings.ToLower(content)
+		for _, term := range terms {
+			if strings.Contains(lower, strings.ToLower(term)) {
+				return ""
+			}
+		}
+		return "missing opt-out text"
+	}
+}
+
+// linkHost returns the lowercased host of a link found in content, trailing punctuation is ignored
+func linkHost(link string) string {
+	link = strings.TrimRight(link, ".,;:!?)]}'\"")
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return strings.ToLower(link)
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func domainAllowed(host string, allowed []string) bool {
+	for _, domain := range allowed {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Queue checks the content policy before enqueueing messages to the wrapped queue. Nothing is enqueued
+// when a message violates a reject rule, messages violating a quarantine rule are enqueued as quarantined.
+// The violated rules that did not reject are stored on the message.
+type Queue struct {
+	queue.Queue
+	engine *Engine
+}
+
+// NewQueue wraps q with engine, q is returned as is when engine has no rules
+func NewQueue(q queue.Queue, engine *Engine) queue.Queue {
+	if engine == nil || len(engine.rules) == 0 {
+		return q
+	}
+	return &Queue{Queue: q, engine: engine}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	for i, message := range messages {
+		violations := q.engine.Check(message)
+		for _, violation := range violations {
+			telemetry.ObservePolicyViolation(violation.Rule, violation.Action)
+			if violation.Action == config.ContentActionReject {
+				if len(messages) > 1 {
+					return fmt.Errorf("%w: message %d: rule %q: %s", ErrRejected, i+1, violation.Rule, violation.Reason)
+				}
+				return fmt.Errorf("%w: rule %q: %s", ErrRejected, violation.Rule, violation.Reason)
+			}
+		}
+
+		message.PolicyViolations = nil
+		for _, violation := range violations {
+			message.PolicyViolations = append(message.PolicyViolations, violation.Rule)
+			if violation.Action == config.ContentActionQuarantine {
+				message.Status = db.MessageStatusQuarantined
+			}
+		}
+		if len(violations) > 0 {
+			config.LogFrom(ctx).WithField("to", message.To).Warnf("Message violates content policy rules %s", strings.Join(message.PolicyViolations, ", "))
+		}
+	}
+	return q.Queue.Enqueue(ctx, messages...)
+}
@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueue records the enqueued messages
+type fakeQueue struct {
+	queue.Queue
+	enqueued []*db.Message
+}
+
+func (f *fakeQueue) Enqueue(_ context.Context, messages ...*db.Message) error {
+	f.enqueued = append(f.enqueued, messages...)
+	return nil
+}
+
+func testPolicy() config.ContentPolicy {
+	return config.ContentPolicy{Rules: []config.ContentRule{
+		{Name: "gambling", Type: config.ContentRuleBannedTerms, Action: config.ContentActionReject, Terms: []string{"casino", "free spins"}},
+		{Name: "links", Type: config.ContentRuleURLAllowlist, Action: config.ContentActionQuarantine, AllowedDomains: []string{"example.com"}},
+		{Name: "opt-out", Type: config.ContentRuleOptOutText, Action: config.ContentActionWarn, Terms: []string{"reply STOP"}, CampaignsOnly: true},
+	}}
+}
+
+func TestEngine_Check(t *testing.T) {
+	engine, err := New(testPolicy())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		message  *db.Message
+		expected []string
+	}{
+		{"clean", &db.Message{Content: "Your code is 1234"}, nil},
+		{"banned term", &db.Message{Content: "Visit our CASINO tonight"}, []string{"gambling"}},
+		{"banned phrase", &db.Message{Content: "50 free spins!"}, []string{"gambling"}},
+		{"banned term inside a word", &db.Message{Content: "Casinos are closed"}, nil},
+		{"allowlisted link", &db.Message{Content: "Track it at https://track.example.com/123."}, nil},
+		{"foreign link", &db.Message{Content: "Track it at https://example.com.evil.io/123"}, []string{"links"}},
+		{"bare domain with path", &db.Message{Content: "See bit.ly/abc"}, []string{"links"}},
+		{"www link", &db.Message{Content: "See www.other.org"}, []string{"links"}},
+		{"campaign without opt-out", &db.Message{Content: "Spring sale", Campaign: "spring"}, []string{"opt-out"}},
+		{"campaign with opt-out", &db.Message{Content: "Spring sale, Reply STOP to opt out", Campaign: "spring"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, violation := range engine.Check(tt.message) {
+				rules = append(rules, violation.Rule)
+			}
+			assert.Equal(t, tt.expected, rules)
+		})
+	}
+}
+
+func TestNew_InvalidRule(t *testing.T) {
+	_, err := New(config.ContentPolicy{Rules: []config.ContentRule{{Name: "x", Type: "regex", Action: config.ContentActionWarn}}})
+	assert.Error(t, err)
+
+	_, err = New(config.ContentPolicy{Rules: []config.ContentRule{{Name: "x", Type: config.ContentRuleURLAllowlist, Action: "drop"}}})
+	assert.Error(t, err)
+}
+
+func TestQueue(t *testing.T) {
+	engine, err := New(testPolicy())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("without rules the queue is not wrapped", func(t *testing.T) {
+		q := &fakeQueue{}
+		empty, err := New(config.ContentPolicy{})
+		require.NoError(t, err)
+		assert.Same(t, q, NewQueue(q, empty))
+	})
+
+	t.Run("a rejected message rejects the whole batch", func(t *testing.T) {
+		inner := &fakeQueue{}
+		err := NewQueue(inner, engine).Enqueue(ctx,
+			&db.Message{Content: "Hello"},
+			&db.Message{Content: "Casino night"})
+
+		assert.ErrorIs(t, err, ErrRejected)
+		assert.Contains(t, err.Error(), `message 2: rule "gambling"`)
+		assert.Empty(t, inner.enqueued)
+	})
+
+	t.Run("quarantine and warn", func(t *testing.T) {
+		inner := &fakeQueue{}
+		quarantined := &db.Message{Content: "Sale at https://shop.io", Campaign: "spring"}
+		warned := &db.Message{Content: "Sale today", Campaign: "spring"}
+		clean := &db.Message{Content: "Your code is 1234"}
+		require.NoError(t, NewQueue(inner, engine).Enqueue(ctx, quarantined, warned, clean))
+
+		require.Len(t, inner.enqueued, 3)
+		assert.Equal(t, db.MessageStatusQuarantined, quarantined.Status)
+		assert.Equal(t, []string{"links", "opt-out"}, quarantined.PolicyViolations)
+		assert.Equal(t, db.MessageStatus(""), warned.Status)
+		assert.Equal(t, []string{"opt-out"}, warned.PolicyViolations)
+		assert.Nil(t, clean.PolicyViolations)
+	})
+}
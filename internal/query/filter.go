@@ -0,0 +1,115 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+)
+
+// MetadataPrefix prefixes the query parameters filtering by a metadata key, e.g. metadata.order_id
+const MetadataPrefix = "metadata."
+
+// Filter errors
+var (
+	ErrInvalidStatus = errors.New("invalid message status")
+	ErrInvalidDate   = errors.New("invalid date")
+	// ErrInvalidMetadata is a key=value metadata pair without a key
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrInvalidAcknowledged is an acknowledged filter other than true or false
+	ErrInvalidAcknowledged = errors.New("invalid acknowledged filter")
+)
+
+// Filters are the message filters of a request or command line as given
+type Filters struct {
+	Status string
+	// From and To are dates as YYYY-MM-DD or RFC3339
+	From string
+	To   string
+	Tag  string
+	// Metadata are the metadata values, parsed by MetadataParams or ParseMetadata
+	Metadata map[string]string
+	// DeadLettered lists only the messages in the dead-letter queue
+	DeadLettered bool
+	// Acknowledged is true for the messages whose failure was acknowledged, false for the others, empty for all
+	Acknowledged string
+}
+
+// MessageFilter validates the filters and returns them as a message filter
+func (f Filters) MessageFilter() (db.MessageFilter, error) {
+	filter := db.MessageFilter{
+		Status:       db.MessageStatus(f.Status),
+		Tag:          f.Tag,
+		Metadata:     f.Metadata,
+		DeadLettered: f.DeadLettered,
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, fmt.Errorf("%w: %s", ErrInvalidStatus, filter.Status)
+	}
+
+	if f.Acknowledged != "" {
+		acknowledged, err := strconv.ParseBool(f.Acknowledged)
+		if err != nil {
+			return filter, fmt.Errorf("%w %q, expected true or false", ErrInvalidAcknowledged, f.Acknowledged)
+		}
+		filter.Acknowledged = &acknowledged
+	}
+
+	var err error
+	if filter.From, err = ParseDate("from", f.From); err != nil {
+		return filter, err
+	}
+	if filter.To, err = ParseDate("to", f.To); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// ParseDate parses the date of the named filter as YYYY-MM-DD or RFC3339, nil when it is empty
+func ParseDate(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q for %s, expected YYYY-MM-DD or RFC3339", ErrInvalidDate, value, name)
+	}
+	return &t, nil
+}
+
+// MetadataParams returns the metadata filters of the metadata.<key> query parameters, nil without any
+func MetadataParams(params map[string]string) map[string]string {
+	var metadata map[string]string
+	for key, value := range params {
+		if name, ok := strings.CutPrefix(key, MetadataPrefix); ok && name != "" {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[name] = value
+		}
+	}
+	return metadata
+}
+
+// ParseMetadata parses metadata given as key=value pairs, like the --metadata flags, nil without any
+func ParseMetadata(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w %q, expected key=value", ErrInvalidMetadata, pair)
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
@@ -0,0 +1,154 @@
+// Package query parses the pagination, sorting and filters of list requests. The REST API and the CLI build
+// their queries with it, so both accept the same values with the same limits and validation errors.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+)
+
+// Pagination limits
+const (
+	// DefaultPageSize is the page size of a request without one
+	DefaultPageSize = 20
+	// MaxPageSize bounds the rows read per request, keeping the memory and response times reasonable
+	MaxPageSize = 100
+	// MinPageSize is the smallest page size
+	MinPageSize = 1
+	// MinPage is the first page, pages start from 1
+	MinPage = 1
+)
+
+// Sort orders of the message lists
+const (
+	// SortNewest lists the latest created messages first, the default
+	SortNewest = "-created_at"
+	// SortOldest lists the earliest created messages first
+	SortOldest = "created_at"
+)
+
+// Pagination errors
+var (
+	ErrInvalidPageSize  = errors.New("page size cannot be negative")
+	ErrPageSizeTooLarge = fmt.Errorf("page size cannot exceed %d", MaxPageSize)
+	ErrPageSizeTooSmall = fmt.Errorf("page size must be at least %d", MinPageSize)
+	ErrInvalidSort      = fmt.Errorf("sort must be %s or %s", SortOldest, SortNewest)
+	// ErrInvalidCursor is a cursor that was not returned as a next_cursor, or one of a list in another order
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Page selects a page of a list, by its number or by the cursor of the page before
+type Page struct {
+	// Number is the page number, starting from 1. It is ignored with a cursor.
+	Number int
+	// Size is the number of rows per page, 0 is DefaultPageSize
+	Size int
+	// Sort is SortNewest (default) or SortOldest
+	Sort string
+	// Cursor is the next_cursor of the page before
+	Cursor string
+}
+
+// Normalize validates the page and fills in the defaults. Numbers below 1 are the first page.
+func (p Page) Normalize() (Page, error) {
+	if p.Number < MinPage {
+		p.Number = MinPage
+	}
+
+	if p.Size < 0 {
+		return p, ErrInvalidPageSize
+	}
+	if p.Size == 0 {
+		p.Size = DefaultPageSize
+	}
+	if p.Size > MaxPageSize {
+		return p, ErrPageSizeTooLarge
+	}
+	if p.Size < MinPageSize {
+		return p, ErrPageSizeTooSmall
+	}
+
+	switch p.Sort {
+	case "":
+		p.Sort = SortNewest
+	case SortNewest, SortOldest:
+	default:
+		return p, fmt.Errorf("%w, got %q", ErrInvalidSort, p.Sort)
+	}
+	if p.Cursor != "" {
+		cursor, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return p, err
+		}
+		if cursor.Sort != p.Sort {
+			return p, fmt.Errorf("%w: the cursor continues a list sorted by %s", ErrInvalidCursor, cursor.Sort)
+		}
+	}
+	return p, nil
+}
+
+// IsZero reports whether the page is the first one in the default order
+func (p Page) IsZero() bool {
+	return p.Cursor == "" && (p.Sort == "" || p.Sort == SortNewest)
+}
+
+// Key identifies the rows of the page, equal pages have equal keys
+func (p Page) Key() string {
+	return fmt.Sprintf("%d/%d/%s/%s", p.Number, p.Size, p.Sort, p.Cursor)
+}
+
+// MessagePage returns the page of db.ListMessages of a normalized page
+func (p Page) MessagePage() (db.MessagePage, error) {
+	page := db.MessagePage{
+		Limit:     p.Size,
+		Offset:    (p.Number - 1) * p.Size,
+		Ascending: p.Sort == SortOldest,
+	}
+	if p.Cursor != "" {
+		cursor, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return page, err
+		}
+		page.After = &db.MessageCursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}
+	}
+	return page, nil
+}
+
+// NextCursor returns the cursor of the page after the messages of a normalized page, empty when the page was
+// not full so no messages follow
+func (p Page) NextCursor(messages []*db.Message) string {
+	if len(messages) == 0 || len(messages) < p.Size {
+		return ""
+	}
+	last := messages[len(messages)-1]
+	return encodeCursor(cursor{Sort: p.Sort, CreatedAt: last.CreatedAt, ID: last.ID})
+}
+
+// cursor is the position after the last message of a page, encoded opaquely for the clients
+type cursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
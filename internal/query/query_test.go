@@ -0,0 +1,103 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPage_Normalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     Page
+		expected Page
+		err      error
+	}{
+		{"defaults", Page{}, Page{Number: 1, Size: DefaultPageSize, Sort: SortNewest}, nil},
+		{"page below the first", Page{Number: -3, Size: 5}, Page{Number: 1, Size: 5, Sort: SortNewest}, nil},
+		{"oldest first", Page{Number: 2, Size: MaxPageSize, Sort: SortOldest}, Page{Number: 2, Size: MaxPageSize, Sort: SortOldest}, nil},
+		{"negative size", Page{Size: -1}, Page{}, ErrInvalidPageSize},
+		{"size too large", Page{Size: MaxPageSize + 1}, Page{}, ErrPageSizeTooLarge},
+		{"unknown sort", Page{Sort: "to"}, Page{}, ErrInvalidSort},
+		{"malformed cursor", Page{Cursor: "not a cursor"}, Page{}, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tt.page.Normalize()
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, page)
+		})
+	}
+}
+
+func TestPage_Cursor(t *testing.T) {
+	createdAt := time.Date(2026, 10, 17, 9, 30, 0, 123456789, time.UTC)
+	messages := []*db.Message{{ID: 7, CreatedAt: createdAt.Add(time.Minute)}, {ID: 5, CreatedAt: createdAt}}
+
+	page, err := Page{Size: 2}.Normalize()
+	require.NoError(t, err)
+	next := page.NextCursor(messages)
+	require.NotEmpty(t, next, "a full page is followed by another")
+	assert.Empty(t, page.NextCursor(messages[:1]), "a page that is not full is the last")
+
+	page, err = Page{Size: 2, Cursor: next}.Normalize()
+	require.NoError(t, err)
+	messagePage, err := page.MessagePage()
+	require.NoError(t, err)
+	require.NotNil(t, messagePage.After)
+	assert.Equal(t, int64(5), messagePage.After.ID)
+	assert.True(t, createdAt.Equal(messagePage.After.CreatedAt))
+	assert.False(t, messagePage.Ascending)
+
+	_, err = Page{Size: 2, Sort: SortOldest, Cursor: next}.Normalize()
+	assert.ErrorIs(t, err, ErrInvalidCursor, "a cursor continues the list in its own order")
+}
+
+func TestFilters_MessageFilter(t *testing.T) {
+	filter, err := Filters{
+		Status:   "failed",
+		From:     "2026-10-01",
+		To:       "2026-10-17T12:00:00Z",
+		Tag:      "shipping",
+		Metadata: MetadataParams(map[string]string{"metadata.order_id": "1001", "metadata.": "ignored", "page": "2"}),
+	}.MessageFilter()
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusFailed, filter.Status)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), *filter.From)
+	assert.Equal(t, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), filter.To.UTC())
+	assert.Equal(t, map[string]string{"order_id": "1001"}, filter.Metadata)
+
+	filter, err = Filters{DeadLettered: true}.MessageFilter()
+	require.NoError(t, err)
+	assert.True(t, filter.DeadLettered)
+	assert.False(t, filter.IsZero(), "the dead-letter queue is a filtered list")
+
+	filter, err = Filters{Acknowledged: "false"}.MessageFilter()
+	require.NoError(t, err)
+	require.NotNil(t, filter.Acknowledged)
+	assert.False(t, *filter.Acknowledged)
+	assert.False(t, filter.IsZero())
+	assert.NotEqual(t, db.MessageFilter{}.Key(), filter.Key())
+
+	_, err = Filters{Status: "unknown"}.MessageFilter()
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+	_, err = Filters{Acknowledged: "maybe"}.MessageFilter()
+	assert.ErrorIs(t, err, ErrInvalidAcknowledged)
+	_, err = Filters{From: "yesterday"}.MessageFilter()
+	assert.ErrorIs(t, err, ErrInvalidDate)
+	assert.Contains(t, err.Error(), "for from")
+
+	metadata, err := ParseMetadata([]string{"order_id=1001", "note=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"order_id": "1001", "note": "a=b"}, metadata)
+	_, err = ParseMetadata([]string{"=1001"})
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+// Backends
+const (
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+)
+
+// New returns the queue backend selected by queue.backend, the returned function releases it
+func New(ctx context.Context, cfg *config.Cfg, database *bun.DB) (Queue, func(), error) {
+	switch cfg.Queue.Backend {
+	case BackendPostgres, "":
+		q := NewPostgres(database)
+		q.SetPriorityAging(PriorityAging(cfg.Queue.PriorityAging))
+		q.SetIDStrategy(db.IDStrategy(cfg.Database.IDStrategy))
+		return q, func() {}, nil
+	case BackendRedis:
+		q, err := NewRedis(ctx, database, cfg.Queue.Redis)
+		if err != nil {
+			return nil, nil, err
+		}
+		q.SetPriorityAging(PriorityAging(cfg.Queue.PriorityAging))
+		q.SetIDStrategy(db.IDStrategy(cfg.Database.IDStrategy))
+		config.Log().Infof("Claiming messages through the redis stream %s", cfg.Queue.Redis.Stream)
+		return q, func() { q.Close() }, nil
+	}
+	return nil, nil, fmt.Errorf("unknown queue backend %q", cfg.Queue.Backend)
+}
+
+// PriorityAging returns the aging of queue.priority_aging
+func PriorityAging(cfg config.PriorityAging) db.PriorityAging {
+	return db.PriorityAging{Interval: cfg.Interval, Curve: cfg.Curve, MaxBoost: cfg.MaxBoost}
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+// Postgres is the queue backed by the messages table, claims use FOR UPDATE SKIP LOCKED
+// so multiple schedulers can share it. It also serves the SQLite database of the memory storage.
+type Postgres struct {
+	db bun.IDB
+	// aging raises the priority of the due messages while they wait, see db.PriorityAging
+	aging db.PriorityAging
+	// ids generates the public IDs of the enqueued messages
+	ids db.IDStrategy
+}
+
+func NewPostgres(database bun.IDB) *Postgres {
+	return &Postgres{db: database}
+}
+
+// SetPriorityAging makes the claims raise the priority of due messages the longer they wait
+func (p *Postgres) SetPriorityAging(aging db.PriorityAging) {
+	p.aging = aging
+}
+
+// SetIDStrategy gives the messages enqueued from now on a public ID generated by strategy
+func (p *Postgres) SetIDStrategy(strategy db.IDStrategy) {
+	p.ids = strategy
+}
+
+func (p *Postgres) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	for _, message := range messages {
+		message.PublicID = db.NewPublicID(p.ids)
+	}
+	return db.CreateMessages(ctx, p.db, messages)
+}
+
+func (p *Postgres) Claim(ctx context.Context) (*db.Message, error) {
+	return db.ClaimNextMessage(ctx, p.db, p.aging)
+}
+
+func (p *Postgres) Ack(ctx context.Context, message *db.Message, delivery Delivery) error {
+	status := delivery.Status
+	if status == "" {
+		status = db.MessageStatusSent
+	}
+	sentAt := delivery.SentAt
+	return db.UpdateMessageStatus(ctx, p.db, message.ID, status, &sentAt, &delivery.MessageID, &delivery.Response)
+}
+
+func (p *Postgres) Fail(ctx context.Context, message *db.Message) error {
+	return db.FailMessage(ctx, p.db, message)
+}
+
+func (p *Postgres) Requeue(ctx context.Context, message *db.Message) error {
+	return db.UpdateMessageStatus(ctx, p.db, message.ID, db.MessageStatusPending, nil, nil, nil)
+}
+
+func (p *Postgres) Defer(ctx context.Context, message *db.Message, until time.Time) error {
+	return db.DeferMessage(ctx, p.db, message.ID, until)
+}
+
+func (p *Postgres) Block(ctx context.Context, message *db.Message) error {
+	return db.UpdateMessageStatus(ctx, p.db, message.ID, db.MessageStatusBlocked, nil, nil, nil)
+}
+
+func (p *Postgres) Cancel(ctx context.Context, message *db.Message) error {
+	return db.UpdateMessageStatus(ctx, p.db, message.ID, db.MessageStatusCancelled, nil, nil, nil)
+}
+
+func (p *Postgres) Expire(ctx context.Context, message *db.Message) error {
+	return db.UpdateMessageStatus(ctx, p.db, message.ID, db.MessageStatusExpired, nil, nil, nil)
+}
@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	// SQLite claims without FOR UPDATE SKIP LOCKED, the locking is covered against Postgres only
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Suppression)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+
+	return bunDB
+}
+
+func TestPostgres(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	q := NewPostgres(testDB)
+
+	load := func(id int64) *db.Message {
+		message, err := db.GetMessageByID(ctx, testDB, id)
+		require.NoError(t, err)
+		return message
+	}
+	// claim marks a message as sending like Claim does
+	claim := func(message *db.Message) {
+		_, err := testDB.NewUpdate().Model((*db.Message)(nil)).
+			Set("status = ?", db.MessageStatusSending).Where("id = ?", message.ID).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("enqueue validates every message", func(t *testing.T) {
+		err := q.Enqueue(ctx,
+			&db.Message{To: "+905551111111", Content: "Valid"},
+			&db.Message{To: "invalid", Content: "Invalid"},
+		)
+
+		assert.ErrorIs(t, err, db.ErrInvalidPhoneNumber)
+		count, err := testDB.NewSelect().Model((*db.Message)(nil)).Count(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	first := &db.Message{To: "+905551111111", Content: "First"}
+	second := &db.Message{To: "+905552222222", Content: "Second"}
+	third := &db.Message{To: "+905553333333", Content: "Third"}
+	require.NoError(t, q.Enqueue(ctx, first, second, third))
+	assert.Equal(t, db.MessageStatusPending, load(first.ID).Status)
+
+	t.Run("ack stores the delivery", func(t *testing.T) {
+		sentAt := time.Now().UTC()
+		claim(first)
+		require.NoError(t, q.Ack(ctx, first, Delivery{SentAt: sentAt, MessageID: "webhook-1", Response: `{"messageId":"webhook-1"}`}))
+
+		message := load(first.ID)
+		assert.Equal(t, db.MessageStatusSent, message.Status)
+		require.NotNil(t, message.MessageID)
+		assert.Equal(t, "webhook-1", *message.MessageID)
+		require.NotNil(t, message.SentAt)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		claim(second)
+		require.NoError(t, q.Fail(ctx, second))
+		assert.Equal(t, db.MessageStatusFailed, load(second.ID).Status)
+	})
+
+	t.Run("settling a message no longer sending", func(t *testing.T) {
+		err := q.Fail(ctx, first)
+
+		var stale *db.StaleStatusError
+		require.ErrorAs(t, err, &stale)
+		assert.ErrorIs(t, err, db.ErrNotClaimed)
+		assert.Equal(t, db.MessageStatusFailed, stale.Status)
+		assert.Equal(t, db.MessageStatusSent, stale.Current)
+		assert.Equal(t, db.MessageStatusSent, load(first.ID).Status, "a sent message is not moved back to failed")
+
+		err = q.Defer(ctx, &db.Message{ID: 999}, time.Now())
+		require.ErrorAs(t, err, &stale)
+		assert.Empty(t, stale.Current, "the message does not exist")
+	})
+
+	t.Run("requeue", func(t *testing.T) {
+		claim(third)
+		require.NoError(t, q.Requeue(ctx, third))
+		assert.Equal(t, db.MessageStatusPending, load(third.ID).Status)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		cancelled := &db.Message{To: "+905556666666", Content: "Cancelled"}
+		require.NoError(t, q.Enqueue(ctx, cancelled))
+		claim(cancelled)
+		require.NoError(t, q.Cancel(ctx, cancelled))
+		assert.Equal(t, db.MessageStatusCancelled, load(cancelled.ID).Status)
+	})
+
+	t.Run("claim takes higher priorities first and skips scheduled messages", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		urgent := &db.Message{To: "+905554444444", Content: "Urgent", Priority: 5}
+		scheduled := &db.Message{To: "+905555555555", Content: "Later", Priority: 9, ScheduledAt: &later}
+		require.NoError(t, q.Enqueue(ctx, urgent, scheduled))
+
+		claimed, err := q.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, urgent.ID, claimed.ID)
+		assert.Equal(t, db.MessageStatusSending, load(urgent.ID).Status)
+
+		claimed, err = q.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, third.ID, claimed.ID)
+
+		claimed, err = q.Claim(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, claimed)
+	})
+}
+
+func TestPostgres_PriorityAging(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	q := NewPostgres(testDB)
+
+	bulk := &db.Message{To: "+905551111111", Content: "Bulk"}
+	urgent := &db.Message{To: "+905552222222", Content: "Urgent", Priority: 5}
+	require.NoError(t, q.Enqueue(ctx, urgent))
+	require.NoError(t, q.Enqueue(ctx, bulk))
+	// the bulk message waited for an hour
+	_, err := testDB.NewUpdate().Model((*db.Message)(nil)).
+		Set("created_at = ?", time.Now().Add(-time.Hour)).Where("id = ?", bulk.ID).Exec(ctx)
+	require.NoError(t, err)
+
+	claimFirst := func(aging db.PriorityAging) int64 {
+		tx, err := testDB.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		claimed, err := db.ClaimNextMessage(ctx, tx, aging)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		return claimed.ID
+	}
+
+	assert.Equal(t, urgent.ID, claimFirst(db.PriorityAging{}), "without aging the higher priority goes first")
+	assert.Equal(t, bulk.ID, claimFirst(db.PriorityAging{Interval: 10 * time.Minute}), "an hour gains 6 levels")
+	assert.Equal(t, urgent.ID, claimFirst(db.PriorityAging{Interval: 10 * time.Minute, MaxBoost: 4}))
+	assert.Equal(t, bulk.ID, claimFirst(db.PriorityAging{Interval: 25 * time.Minute, Curve: db.AgingQuadratic}), "2.4² gains 5.76 levels")
+	assert.Equal(t, urgent.ID, claimFirst(db.PriorityAging{Interval: 25 * time.Minute}))
+
+	q.SetPriorityAging(db.PriorityAging{Interval: 10 * time.Minute})
+	claimed, err := q.Claim(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, bulk.ID, claimed.ID)
+}
+
+// queryRecorder records the queries run through a database
+type queryRecorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *queryRecorder) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (r *queryRecorder) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, event.Query)
+}
+
+// TestPostgres_ClaimIndex checks the claim plans of SQLite, TestPostgres_ClaimIndexPlan those of Postgres
+func TestPostgres_ClaimIndex(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	require.NoError(t, db.CreateClaimIndex(ctx, testDB))
+	require.NoError(t, NewPostgres(testDB).Enqueue(ctx, &db.Message{To: "+905551111111", Content: "Hello"}))
+
+	// plan returns the query plan of the claims run by claim
+	plan := func(claim func(bun.IDB) error) string {
+		recorder := &queryRecorder{}
+		traced := bun.NewDB(testDB.DB, testDB.Dialect())
+		traced.AddQueryHook(recorder)
+		tx, err := traced.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		require.NoError(t, claim(tx))
+
+		var details []string
+		for _, query := range recorder.queries {
+			if !strings.Contains(query, "UPDATE messages") {
+				continue
+			}
+			rows, err := testDB.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+			require.NoError(t, err)
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				require.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+				details = append(details, detail)
+			}
+			require.NoError(t, rows.Err())
+			rows.Close()
+		}
+		require.NotEmpty(t, details, "no claim was run")
+		return strings.Join(details, "\n")
+	}
+
+	claims := map[string]func(bun.IDB) error{
+		"next": func(tx bun.IDB) error {
+			_, err := db.ClaimNextMessage(ctx, tx, db.PriorityAging{})
+			return err
+		},
+		"due": func(tx bun.IDB) error {
+			_, err := db.ClaimDueMessages(ctx, tx, 100, db.PriorityAging{})
+			return err
+		},
+	}
+	for name, claim := range claims {
+		t.Run(name, func(t *testing.T) {
+			queryPlan := plan(claim)
+			assert.Contains(t, queryPlan, "USING INDEX "+db.ClaimIndex)
+			assert.NotContains(t, queryPlan, "TEMP B-TREE FOR ORDER BY", "the pending messages are read in claim order")
+		})
+	}
+}
+
+func TestPriorityAging_Boost(t *testing.T) {
+	linear := db.PriorityAging{Interval: 10 * time.Minute}
+	assert.Zero(t, db.PriorityAging{}.Boost(time.Hour))
+	assert.Zero(t, linear.Boost(-time.Minute))
+	assert.InDelta(t, 6, linear.Boost(time.Hour), 1e-9)
+	assert.InDelta(t, 36, db.PriorityAging{Interval: 10 * time.Minute, Curve: db.AgingQuadratic}.Boost(time.Hour), 1e-9)
+	assert.InDelta(t, 4, db.PriorityAging{Interval: 10 * time.Minute, MaxBoost: 4}.Boost(time.Hour), 1e-9)
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+)
+
+// Queue is the storage the scheduler takes messages from. A backend hands out every
+// pending message to a single consumer at a time until it is acked, failed or requeued.
+type Queue interface {
+	// Enqueue validates and stores new pending messages, nothing is stored if any of them is invalid
+	Enqueue(ctx context.Context, messages ...*db.Message) error
+	// Claim returns the next due message and marks it as sending, nil when no message is due
+	Claim(ctx context.Context) (*db.Message, error)
+	// Ack marks a claimed message as sent, or accepted, with the webhook result
+	Ack(ctx context.Context, message *db.Message, delivery Delivery) error
+	// Fail marks a claimed message as failed with its FailureClass, FailureError and DeadLetteredAt, it is only
+	// sent again after a retry or an automatic requeue of its transient failure
+	Fail(ctx context.Context, message *db.Message) error
+	// Requeue puts a claimed message back to pending so it is claimed again
+	Requeue(ctx context.Context, message *db.Message) error
+	// Defer puts a claimed message back to pending so it is claimed again once until passed
+	Defer(ctx context.Context, message *db.Message, until time.Time) error
+	// Block marks a claimed message as blocked, its recipient was suppressed after it was enqueued
+	Block(ctx context.Context, message *db.Message) error
+	// Cancel marks a claimed message as cancelled, an operator cancelled it before it was sent
+	Cancel(ctx context.Context, message *db.Message) error
+	// Expire marks a claimed message as expired, it was claimed after its expires_at
+	Expire(ctx context.Context, message *db.Message) error
+}
+
+// Delivery is the webhook result stored with an acked message
+type Delivery struct {
+	// Status is the status the message is acked with, sent when empty. It is accepted
+	// when a delivery report confirms the delivery later.
+	Status    db.MessageStatus
+	SentAt    time.Time
+	MessageID string
+	Response  string
+}
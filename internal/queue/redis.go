@@ -0,0 +1,310 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// streamField is the stream entry field holding the message ID
+	streamField = "id"
+	// messageField is the stream entry field holding the JSON encoded message, so claims read it from the
+	// stream instead of loading it from Postgres
+	messageField = "message"
+)
+
+// Redis hands out messages through a Redis Stream consumer group. Postgres stays the source of
+// truth: messages are stored there on Enqueue and due ones are moved to the stream with their
+// content in batches (refills) once the stream runs dry, so every path that writes pending rows
+// keeps working and claims only read Redis. Entries of a consumer that died are reclaimed by the
+// others after ClaimTimeout and checked against Postgres, they may have been settled before.
+type Redis struct {
+	pg     *Postgres
+	db     bun.IDB
+	client *redis.Client
+	cfg    config.QueueRedis
+
+	mu sync.Mutex
+	// entries maps the claimed message IDs to their stream entry IDs for XACK
+	entries map[int64]string
+}
+
+// NewRedis connects to Redis and creates the consumer group when it does not exist yet
+func NewRedis(ctx context.Context, database bun.IDB, cfg config.QueueRedis) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !isBusyGroup(err) {
+		client.Close()
+		return nil, fmt.Errorf("creating redis consumer group: %w", err)
+	}
+
+	return &Redis{
+		pg:      NewPostgres(database),
+		db:      database,
+		client:  client,
+		cfg:     cfg,
+		entries: make(map[int64]string),
+	}, nil
+}
+
+// SetPriorityAging makes the refills add the due messages to the stream by their aged priority
+func (r *Redis) SetPriorityAging(aging db.PriorityAging) {
+	r.pg.SetPriorityAging(aging)
+}
+
+// SetIDStrategy gives the messages enqueued from now on a public ID generated by strategy
+func (r *Redis) SetIDStrategy(strategy db.IDStrategy) {
+	r.pg.SetIDStrategy(strategy)
+}
+
+// Enqueue stores the messages in Postgres, they reach the stream with the next refill
+func (r *Redis) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	return r.pg.Enqueue(ctx, messages...)
+}
+
+// Claim returns a reclaimed entry of a dead consumer first, then a new entry, refilling the stream
+// from Postgres when it has none
+func (r *Redis) Claim(ctx context.Context) (*db.Message, error) {
+	for {
+		entry, reclaimed, err := r.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			refilled, err := r.refill(ctx)
+			if err != nil || refilled == 0 {
+				return nil, err
+			}
+			continue
+		}
+
+		message, err := r.load(ctx, *entry, reclaimed)
+		if err != nil {
+			return nil, err
+		}
+		if message != nil {
+			return message, nil
+		}
+	}
+}
+
+// next reads one stream entry and whether it was reclaimed, pending entries idle for longer than ClaimTimeout
+// go first
+func (r *Redis) next(ctx context.Context) (*redis.XMessage, bool, error) {
+	reclaimed, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   r.cfg.Stream,
+		Group:    r.cfg.Group,
+		Consumer: r.cfg.Consumer,
+		MinIdle:  r.cfg.ClaimTimeout,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("reclaiming redis entries: %w", err)
+	}
+	if len(reclaimed) > 0 {
+		return &reclaimed[0], true, nil
+	}
+
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.cfg.Group,
+		Consumer: r.cfg.Consumer,
+		Streams:  []string{r.cfg.Stream, ">"},
+		Count:    1,
+		Block:    -1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("reading redis stream: %w", err)
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, false, nil
+	}
+	return &streams[0].Messages[0], false, nil
+}
+
+// load returns the message of a stream entry. New entries are decoded from the entry, reclaimed ones and
+// entries without the message are loaded from Postgres; those of messages that are no longer being sent
+// (e.g. acked in Postgres right before a crash) are acked and skipped with a nil message.
+func (r *Redis) load(ctx context.Context, entry redis.XMessage, reclaimed bool) (*db.Message, error) {
+	if encoded, ok := entry.Values[messageField].(string); ok && !reclaimed {
+		var message db.Message
+		if err := json.Unmarshal([]byte(encoded), &message); err == nil {
+			r.track(message.ID, entry.ID)
+			return &message, nil
+		}
+		config.Log().Warnf("Loading the message of redis stream entry %s from the database, it cannot be decoded", entry.ID)
+	}
+
+	id, err := strconv.ParseInt(fmt.Sprint(entry.Values[streamField]), 10, 64)
+	if err != nil {
+		config.Log().Warnf("Dropping malformed redis stream entry %s", entry.ID)
+		return nil, r.ack(ctx, entry.ID)
+	}
+
+	message, err := db.GetMessageByID(ctx, r.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.ack(ctx, entry.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if message.Status != db.MessageStatusSending {
+		return nil, r.ack(ctx, entry.ID)
+	}
+
+	r.track(message.ID, entry.ID)
+	return message, nil
+}
+
+// track remembers the stream entry of a claimed message for XACK
+func (r *Redis) track(messageID int64, entryID string) {
+	r.mu.Lock()
+	r.entries[messageID] = entryID
+	r.mu.Unlock()
+}
+
+// refill claims a batch of due messages in Postgres and adds them to the stream
+func (r *Redis) refill(ctx context.Context) (int, error) {
+	messages, err := db.ClaimDueMessages(ctx, r.db, r.cfg.RefillSize, r.pg.aging)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, message := range messages {
+		values, err := streamValues(message)
+		if err != nil {
+			return 0, err
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: r.cfg.Stream, Values: values})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("adding messages to redis stream: %w", err)
+	}
+	return len(messages), nil
+}
+
+// streamValues returns the stream entry of a claimed message
+func streamValues(message *db.Message) (map[string]any, error) {
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("encoding message %d for the redis stream: %w", message.ID, err)
+	}
+	return map[string]any{streamField: message.ID, messageField: encoded}, nil
+}
+
+func (r *Redis) Ack(ctx context.Context, message *db.Message, delivery Delivery) error {
+	if err := r.pg.Ack(ctx, message, delivery); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+func (r *Redis) Fail(ctx context.Context, message *db.Message) error {
+	if err := r.pg.Fail(ctx, message); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+// Requeue moves the message back to pending in Postgres, it returns to the stream with a later refill
+func (r *Redis) Requeue(ctx context.Context, message *db.Message) error {
+	if err := r.pg.Requeue(ctx, message); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+func (r *Redis) Defer(ctx context.Context, message *db.Message, until time.Time) error {
+	if err := r.pg.Defer(ctx, message, until); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+func (r *Redis) Block(ctx context.Context, message *db.Message) error {
+	if err := r.pg.Block(ctx, message); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+func (r *Redis) Cancel(ctx context.Context, message *db.Message) error {
+	if err := r.pg.Cancel(ctx, message); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+func (r *Redis) Expire(ctx context.Context, message *db.Message) error {
+	if err := r.pg.Expire(ctx, message); err != nil {
+		return r.settleStale(ctx, message, err)
+	}
+	return r.settle(ctx, message)
+}
+
+// settleStale returns err, settling the stream entry when Postgres refused the update because the message
+// is no longer sending, it was settled elsewhere and must not be delivered again
+func (r *Redis) settleStale(ctx context.Context, message *db.Message, err error) error {
+	if errors.Is(err, db.ErrNotClaimed) {
+		if settleErr := r.settle(ctx, message); settleErr != nil {
+			return errors.Join(err, settleErr)
+		}
+	}
+	return err
+}
+
+// settle acks and deletes the stream entry of a claimed message
+func (r *Redis) settle(ctx context.Context, message *db.Message) error {
+	r.mu.Lock()
+	entryID, ok := r.entries[message.ID]
+	delete(r.entries, message.ID)
+	r.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return r.ack(ctx, entryID)
+}
+
+func (r *Redis) ack(ctx context.Context, entryID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.XAck(ctx, r.cfg.Stream, r.cfg.Group, entryID)
+	pipe.XDel(ctx, r.cfg.Stream, entryID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("acking redis entry: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// isBusyGroup reports whether XGROUP CREATE failed because the group already exists
+func isBusyGroup(err error) bool {
+	return strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	server := miniredis.RunT(t)
+
+	ctx := context.Background()
+	newQueue := func(consumer string) *Redis {
+		q, err := NewRedis(ctx, testDB, config.QueueRedis{
+			Address:      server.Addr(),
+			Stream:       "sendpulse:messages",
+			Group:        "sendpulse",
+			Consumer:     consumer,
+			ClaimTimeout: 10 * time.Millisecond,
+			RefillSize:   10,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { q.Close() })
+		return q
+	}
+	first, second := newQueue("first"), newQueue("second")
+
+	// refills need FOR UPDATE SKIP LOCKED, so the claimed messages are added to the stream directly
+	add := func(content string, status db.MessageStatus) *db.Message {
+		message := &db.Message{To: "+905551111111", Content: content, Status: status}
+		_, err := testDB.NewInsert().Model(message).Exec(ctx)
+		require.NoError(t, err)
+		values, err := streamValues(message)
+		require.NoError(t, err)
+		require.NoError(t, first.client.XAdd(ctx, &redis.XAddArgs{Stream: "sendpulse:messages", Values: values}).Err())
+		return message
+	}
+	streamLength := func() int64 {
+		length, err := first.client.XLen(ctx, "sendpulse:messages").Result()
+		require.NoError(t, err)
+		return length
+	}
+
+	t.Run("claim and ack", func(t *testing.T) {
+		message := add("Ack me", db.MessageStatusSending)
+
+		claimed, err := first.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, message.ID, claimed.ID)
+
+		require.NoError(t, first.Ack(ctx, claimed, Delivery{SentAt: time.Now(), MessageID: "webhook-1"}))
+		stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.MessageStatusSent, stored.Status)
+		assert.Zero(t, streamLength())
+	})
+
+	t.Run("claims new entries from the stream alone", func(t *testing.T) {
+		// the message has no row, so it can only come from the stream entry
+		values, err := streamValues(&db.Message{ID: 1000, To: "+905551111111", Content: "Only in Redis", Status: db.MessageStatusSending})
+		require.NoError(t, err)
+		require.NoError(t, first.client.XAdd(ctx, &redis.XAddArgs{Stream: "sendpulse:messages", Values: values}).Err())
+
+		claimed, err := first.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, int64(1000), claimed.ID)
+		assert.Equal(t, "Only in Redis", claimed.Content)
+		require.NoError(t, first.ack(ctx, first.entries[claimed.ID]))
+		assert.Zero(t, streamLength())
+	})
+
+	t.Run("skips reclaimed entries of messages that are no longer sending", func(t *testing.T) {
+		settled := add("Sent before a crash", db.MessageStatusSending)
+		claimed, err := first.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		// the first consumer settles the message in Postgres and dies before acking its entry
+		require.NoError(t, db.UpdateMessageStatus(ctx, testDB, settled.ID, db.MessageStatusSent, nil, nil, nil))
+		message := add("Next", db.MessageStatusSending)
+
+		time.Sleep(20 * time.Millisecond)
+		claimed, err = second.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+		assert.Equal(t, message.ID, claimed.ID)
+		require.NoError(t, second.Fail(ctx, claimed))
+		assert.Zero(t, streamLength())
+	})
+
+	t.Run("reclaims entries of a dead consumer", func(t *testing.T) {
+		message := add("Orphaned", db.MessageStatusSending)
+
+		claimed, err := first.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, claimed)
+
+		// the first consumer never acks, after the claim timeout the second one takes over
+		time.Sleep(20 * time.Millisecond)
+		reclaimed, err := second.Claim(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, reclaimed)
+		assert.Equal(t, message.ID, reclaimed.ID)
+
+		require.NoError(t, second.Requeue(ctx, reclaimed))
+		stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.MessageStatusPending, stored.Status)
+		assert.Zero(t, streamLength())
+	})
+}
@@ -0,0 +1,343 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/uptrace/bun"
+)
+
+// ErrExceeded is returned when enqueueing would exceed a daily or monthly quota
+var ErrExceeded = errors.New("quota exceeded")
+
+// Limit is the number of messages allowed per UTC day and month, 0 is unlimited
+type Limit struct {
+	Daily   int64
+	Monthly int64
+	// WarningThresholds are the shares of the limit at which a Warning is raised
+	WarningThresholds []float64
+}
+
+func (l Limit) of(period db.UsagePeriod) int64 {
+	if period == db.UsagePeriodDay {
+		return l.Daily
+	}
+	return l.Monthly
+}
+
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a context carrying the name of the API key a request was authenticated with
+func ContextWithAPIKey(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, name)
+}
+
+// APIKeyFrom returns the API key name stored by ContextWithAPIKey, empty when there is none
+func APIKeyFrom(ctx context.Context) string {
+	name, _ := ctx.Value(apiKeyContextKey{}).(string)
+	return name
+}
+
+// Warning is an API key or tenant crossing a warning threshold of its quota in the current period
+type Warning struct {
+	Scope   db.UsageScope
+	Subject string
+	Period  db.UsagePeriod
+	// Threshold is the share of the limit that was crossed, e.g. 0.8
+	Threshold float64
+	Used      int64
+	Limit     int64
+	// Reset is when the period ends and the quota is available again
+	Reset time.Time
+}
+
+// String describes the warning in one line
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %q used %.0f%% of its %s quota (%d of %d messages, resets %s)", w.Scope, w.Subject,
+		w.Threshold*100, w.Period, w.Used, w.Limit, w.Reset.Format(time.RFC3339))
+}
+
+// Quotas counts the created messages per API key and tenant in the usage_counters table and
+// enforces the configured limits. Every API key and tenant is counted, limited or not.
+type Quotas struct {
+	db       bun.IDB
+	apiKeys  map[string]Limit
+	tenants  map[string]Limit
+	now      func() time.Time
+	warnings []func(context.Context, Warning)
+}
+
+func New(database bun.IDB, cfg *config.Cfg) *Quotas {
+	q := &Quotas{
+		db:      database,
+		apiKeys: make(map[string]Limit),
+		tenants: make(map[string]Limit),
+		now:     time.Now,
+	}
+	thresholds := func(override []float64) []float64 {
+		if override != nil {
+			return override
+		}
+		return cfg.Quotas.WarningThresholds
+	}
+	for _, key := range cfg.Server.Keys() {
+		q.apiKeys[key.Name] = Limit{Daily: key.DailyQuota, Monthly: key.MonthlyQuota, WarningThresholds: thresholds(key.WarningThresholds)}
+	}
+	for _, tenant := range cfg.Quotas.Tenants {
+		q.tenants[tenant.Tenant] = Limit{Daily: tenant.DailyQuota, Monthly: tenant.MonthlyQuota, WarningThresholds: thresholds(tenant.WarningThresholds)}
+	}
+	return q
+}
+
+// OnWarning calls warn with every Warning raised after it, once the reservation that crossed the threshold is
+// committed. warn runs on the path of the request creating the messages, it must not block.
+func (q *Quotas) OnWarning(warn func(context.Context, Warning)) {
+	q.warnings = append(q.warnings, warn)
+}
+
+func (q *Quotas) limitOf(scope db.UsageScope, subject string) Limit {
+	if scope == db.UsageScopeAPIKey {
+		return q.apiKeys[subject]
+	}
+	return q.tenants[subject]
+}
+
+// Limit returns the limit of an API key or tenant in period, 0 is unlimited
+func (q *Quotas) Limit(scope db.UsageScope, subject string, period db.UsagePeriod) int64 {
+	return q.limitOf(scope, subject).of(period)
+}
+
+// WarningThreshold returns the highest warning threshold of an API key or tenant that used messages of its quota
+// in period reached, 0 when none was reached or there is no quota
+func (q *Quotas) WarningThreshold(scope db.UsageScope, subject string, period db.UsagePeriod, used int64) float64 {
+	limit := q.limitOf(scope, subject)
+	return reachedThreshold(limit.WarningThresholds, limit.of(period), used)
+}
+
+// Reserve counts messages against the API key in ctx and their tenants. Either every counter is
+// incremented or, when one of the quotas would be exceeded, none is and ErrExceeded is returned.
+// A Warning is raised for every quota the reservation takes past a warning threshold.
+func (q *Quotas) Reserve(ctx context.Context, messages []*db.Message) error {
+	return q.reserve(ctx, messages, q.now())
+}
+
+// reserve is Reserve counting against the periods of at
+func (q *Quotas) reserve(ctx context.Context, messages []*db.Message, at time.Time) error {
+	counters := q.counters(ctx, messages, at)
+	var warnings []Warning
+	err := q.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		warnings = warnings[:0]
+		for _, counter := range counters {
+			limit := q.limitOf(counter.Scope, counter.Subject)
+			added := counter.Count
+			ok, err := db.IncrementUsage(ctx, tx, counter, limit.of(counter.Period))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%w: %s %q allows %d messages per %s", ErrExceeded,
+					counter.Scope, counter.Subject, limit.of(counter.Period), counter.Period)
+			}
+
+			threshold := reachedThreshold(limit.WarningThresholds, limit.of(counter.Period), counter.Count)
+			if threshold > reachedThreshold(limit.WarningThresholds, limit.of(counter.Period), counter.Count-added) {
+				warnings = append(warnings, Warning{
+					Scope:     counter.Scope,
+					Subject:   counter.Subject,
+					Period:    counter.Period,
+					Threshold: threshold,
+					Used:      counter.Count,
+					Limit:     limit.of(counter.Period),
+					Reset:     periodEnd(counter.Period, counter.PeriodStart),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range warnings {
+		q.warn(ctx, warning)
+	}
+	return nil
+}
+
+// warn logs and counts a warning and hands it to the OnWarning functions
+func (q *Quotas) warn(ctx context.Context, warning Warning) {
+	config.LogFrom(ctx).WithFields(map[string]any{
+		"scope":     warning.Scope,
+		"subject":   warning.Subject,
+		"period":    warning.Period,
+		"threshold": warning.Threshold,
+		"used":      warning.Used,
+		"limit":     warning.Limit,
+	}).Warn("Quota warning threshold reached")
+	telemetry.RecordQuotaWarning(string(warning.Scope), string(warning.Period))
+
+	for _, warn := range q.warnings {
+		warn(ctx, warning)
+	}
+}
+
+// reachedThreshold returns the highest of thresholds that used messages of limit reached, 0 when none or unlimited
+func reachedThreshold(thresholds []float64, limit, used int64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	var reached float64
+	for _, threshold := range thresholds {
+		if used >= int64(math.Ceil(threshold*float64(limit))) {
+			reached = max(reached, threshold)
+		}
+	}
+	return reached
+}
+
+// Release gives back the usage reserved at reservedAt for messages that were not enqueued after all. It is
+// released from the periods of the reservation, which may have ended since.
+func (q *Quotas) Release(ctx context.Context, messages []*db.Message, reservedAt time.Time) error {
+	counters := q.counters(ctx, messages, reservedAt)
+	return q.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, counter := range counters {
+			if err := db.ReleaseUsage(ctx, tx, counter); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Usage returns the counters of the current UTC day and month
+func (q *Quotas) Usage(ctx context.Context) ([]*db.UsageCounter, error) {
+	day, month := periodStarts(q.now())
+	return db.ListUsage(ctx, q.db, day, month)
+}
+
+// Status is the use of a quota in its current period
+type Status struct {
+	Period db.UsagePeriod
+	Limit  int64
+	Used   int64
+	// Reset is when the period ends and the quota is available again
+	Reset time.Time
+}
+
+// Remaining returns the messages left in the period, 0 once the quota is used up
+func (s Status) Remaining() int64 {
+	return max(s.Limit-s.Used, 0)
+}
+
+// APIKeyStatus returns the day and month quotas of an API key with their use, periods without a quota are
+// left out. The database is only read when the key has a quota.
+func (q *Quotas) APIKeyStatus(ctx context.Context, apiKey string) ([]Status, error) {
+	limit := q.apiKeys[apiKey]
+	if limit.Daily == 0 && limit.Monthly == 0 {
+		return nil, nil
+	}
+
+	day, month := periodStarts(q.now())
+	counters, err := db.ListSubjectUsage(ctx, q.db, db.UsageScopeAPIKey, apiKey, day, month)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[db.UsagePeriod]int64, len(counters))
+	for _, counter := range counters {
+		used[counter.Period] = counter.Count
+	}
+
+	var statuses []Status
+	if limit.Daily > 0 {
+		statuses = append(statuses, Status{Period: db.UsagePeriodDay, Limit: limit.Daily, Used: used[db.UsagePeriodDay], Reset: periodEnd(db.UsagePeriodDay, day)})
+	}
+	if limit.Monthly > 0 {
+		statuses = append(statuses, Status{Period: db.UsagePeriodMonth, Limit: limit.Monthly, Used: used[db.UsagePeriodMonth], Reset: periodEnd(db.UsagePeriodMonth, month)})
+	}
+	return statuses, nil
+}
+
+// counters returns the counters of the day and month of at touched by messages, counting each message once.
+// They are sorted so concurrent reservations lock the rows in the same order.
+func (q *Quotas) counters(ctx context.Context, messages []*db.Message, at time.Time) []*db.UsageCounter {
+	type subject struct {
+		scope db.UsageScope
+		name  string
+	}
+	counts := make(map[subject]int64)
+	if apiKey := APIKeyFrom(ctx); apiKey != "" {
+		counts[subject{db.UsageScopeAPIKey, apiKey}] = int64(len(messages))
+	}
+	for _, message := range messages {
+		if message.Tenant != "" {
+			counts[subject{db.UsageScopeTenant, message.Tenant}]++
+		}
+	}
+
+	day, month := periodStarts(at)
+	var counters []*db.UsageCounter
+	for s, count := range counts {
+		counters = append(counters,
+			&db.UsageCounter{Scope: s.scope, Subject: s.name, Period: db.UsagePeriodDay, PeriodStart: day, Count: count},
+			&db.UsageCounter{Scope: s.scope, Subject: s.name, Period: db.UsagePeriodMonth, PeriodStart: month, Count: count},
+		)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		a, b := counters[i], counters[j]
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Period < b.Period
+	})
+	return counters
+}
+
+// periodEnd returns when the period starting at start ends
+func periodEnd(period db.UsagePeriod, start time.Time) time.Time {
+	if period == db.UsagePeriodDay {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// periodStarts returns the start of the UTC day and month of t
+func periodStarts(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC),
+		time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Queue reserves the quotas of the messages before enqueueing them to the wrapped queue
+type Queue struct {
+	queue.Queue
+	quotas *Quotas
+}
+
+func NewQueue(q queue.Queue, quotas *Quotas) queue.Queue {
+	return &Queue{Queue: q, quotas: quotas}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, messages ...*db.Message) error {
+	reservedAt := q.quotas.now()
+	if err := q.quotas.reserve(ctx, messages, reservedAt); err != nil {
+		return err
+	}
+	if err := q.Queue.Enqueue(ctx, messages...); err != nil {
+		if releaseErr := q.quotas.Release(context.WithoutCancel(ctx), messages, reservedAt); releaseErr != nil {
+			config.LogFrom(ctx).Errorf("Failed to release quota usage: %v", releaseErr)
+		}
+		return err
+	}
+	return nil
+}
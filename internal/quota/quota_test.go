@@ -0,0 +1,212 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:")
+	require.NoError(t, err)
+	// every connection would get its own in-memory database
+	sqldb.SetMaxOpenConns(1)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+	_, err = bunDB.NewCreateTable().Model((*db.UsageCounter)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { bunDB.Close() })
+
+	return bunDB
+}
+
+// failingQueue fails every enqueue, after calling before when set
+type failingQueue struct {
+	queue.Queue
+	before func()
+}
+
+func (q failingQueue) Enqueue(context.Context, ...*db.Message) error {
+	if q.before != nil {
+		q.before()
+	}
+	return errors.New("database unavailable")
+}
+
+func TestQuotas(t *testing.T) {
+	testDB := setupTestDB(t)
+	quotas := New(testDB, &config.Cfg{
+		Server: config.Server{
+			APIKey:  "secret",
+			APIKeys: []config.APIKey{{Name: "reporting", Key: "reporting-secret", DailyQuota: 3, MonthlyQuota: 10}},
+		},
+		Quotas: config.Quotas{
+			Tenants: []config.TenantQuota{{Tenant: "billing", DailyQuota: 2}},
+		},
+	})
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	quotas.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	reporting := ContextWithAPIKey(ctx, "reporting")
+	messages := func(tenant string, n int) []*db.Message {
+		var messages []*db.Message
+		for range n {
+			messages = append(messages, &db.Message{To: "+905551111111", Content: "Hello", Tenant: tenant})
+		}
+		return messages
+	}
+	usage := func() map[string]int64 {
+		counters, err := quotas.Usage(ctx)
+		require.NoError(t, err)
+		counts := make(map[string]int64)
+		for _, counter := range counters {
+			counts[string(counter.Scope)+"/"+counter.Subject+"/"+string(counter.Period)] = counter.Count
+		}
+		return counts
+	}
+
+	t.Run("counts every key and tenant", func(t *testing.T) {
+		require.NoError(t, quotas.Reserve(ContextWithAPIKey(ctx, config.DefaultAPIKeyName), messages("marketing", 5)))
+		require.NoError(t, quotas.Reserve(reporting, messages("billing", 1)))
+
+		assert.Equal(t, map[string]int64{
+			"api_key/default/day":     5,
+			"api_key/default/month":   5,
+			"api_key/reporting/day":   1,
+			"api_key/reporting/month": 1,
+			"tenant/billing/day":      1,
+			"tenant/billing/month":    1,
+			"tenant/marketing/day":    5,
+			"tenant/marketing/month":  5,
+		}, usage())
+	})
+
+	t.Run("rejects without counting when any quota is exceeded", func(t *testing.T) {
+		// the key allows 2 more messages today, the billing tenant only 1
+		err := quotas.Reserve(reporting, messages("billing", 2))
+		assert.ErrorIs(t, err, ErrExceeded)
+		assert.Contains(t, err.Error(), `tenant "billing" allows 2 messages per day`)
+
+		err = quotas.Reserve(reporting, messages("", 3))
+		assert.ErrorIs(t, err, ErrExceeded)
+		assert.Contains(t, err.Error(), `api_key "reporting" allows 3 messages per day`)
+
+		counts := usage()
+		assert.Equal(t, int64(1), counts["api_key/reporting/day"])
+		assert.Equal(t, int64(1), counts["tenant/billing/day"])
+	})
+
+	t.Run("daily quotas reset the next day", func(t *testing.T) {
+		now = now.AddDate(0, 0, 1)
+		require.NoError(t, quotas.Reserve(reporting, messages("billing", 2)))
+
+		counts := usage()
+		assert.Equal(t, int64(2), counts["api_key/reporting/day"])
+		assert.Equal(t, int64(3), counts["api_key/reporting/month"])
+	})
+
+	t.Run("a failed enqueue releases the reservation", func(t *testing.T) {
+		q := NewQueue(failingQueue{}, quotas)
+		assert.Error(t, q.Enqueue(reporting, messages("", 1)...))
+		assert.Equal(t, int64(2), usage()["api_key/reporting/day"])
+	})
+
+	t.Run("status of an api key", func(t *testing.T) {
+		statuses, err := quotas.APIKeyStatus(ctx, "reporting")
+		require.NoError(t, err)
+		assert.Equal(t, []Status{
+			{Period: db.UsagePeriodDay, Limit: 3, Used: 2, Reset: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+			{Period: db.UsagePeriodMonth, Limit: 10, Used: 3, Reset: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		}, statuses)
+		assert.Equal(t, int64(1), statuses[0].Remaining())
+
+		statuses, err = quotas.APIKeyStatus(ctx, config.DefaultAPIKeyName)
+		require.NoError(t, err)
+		assert.Empty(t, statuses, "keys without quotas have no status")
+	})
+
+	t.Run("a failed enqueue across midnight releases the reserved day", func(t *testing.T) {
+		reservedAt := now
+		q := NewQueue(failingQueue{before: func() { now = now.AddDate(0, 0, 1) }}, quotas)
+		assert.Error(t, q.Enqueue(reporting, messages("", 1)...))
+		assert.NotContains(t, usage(), "api_key/reporting/day", "nothing is released from the new day")
+
+		day, month := periodStarts(reservedAt)
+		counters, err := db.ListSubjectUsage(ctx, testDB, db.UsageScopeAPIKey, "reporting", day, month)
+		require.NoError(t, err)
+		require.Len(t, counters, 2)
+		assert.Equal(t, int64(2), counters[0].Count)
+		assert.Equal(t, int64(3), counters[1].Count)
+	})
+
+	t.Run("releases never take a counter below zero", func(t *testing.T) {
+		require.NoError(t, quotas.Reserve(reporting, messages("", 1)))
+		require.NoError(t, quotas.Release(reporting, messages("", 3), now))
+		assert.Equal(t, int64(0), usage()["api_key/reporting/day"])
+	})
+}
+
+func TestQuotas_Warnings(t *testing.T) {
+	testDB := setupTestDB(t)
+	quotas := New(testDB, &config.Cfg{
+		Server: config.Server{
+			APIKeys: []config.APIKey{{Name: "reporting", Key: "reporting-secret", DailyQuota: 10, WarningThresholds: []float64{0.5}}},
+		},
+		Quotas: config.Quotas{
+			Tenants:           []config.TenantQuota{{Tenant: "billing", DailyQuota: 20, MonthlyQuota: 100}},
+			WarningThresholds: config.DefaultQuotaWarningThresholds,
+		},
+	})
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	quotas.now = func() time.Time { return now }
+
+	var warnings []Warning
+	quotas.OnWarning(func(_ context.Context, warning Warning) { warnings = append(warnings, warning) })
+	ctx := context.Background()
+	reporting := ContextWithAPIKey(ctx, "reporting")
+	reserve := func(ctx context.Context, n int) {
+		var messages []*db.Message
+		for range n {
+			messages = append(messages, &db.Message{To: "+905551111111", Content: "Hello", Tenant: "billing"})
+		}
+		require.NoError(t, quotas.Reserve(ctx, messages))
+	}
+
+	reserve(reporting, 4)
+	assert.Empty(t, warnings)
+
+	reserve(reporting, 1)
+	require.Len(t, warnings, 1, "the key overrides the thresholds")
+	assert.Equal(t, Warning{
+		Scope:     db.UsageScopeAPIKey,
+		Subject:   "reporting",
+		Period:    db.UsagePeriodDay,
+		Threshold: 0.5,
+		Used:      5,
+		Limit:     10,
+		Reset:     time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+	}, warnings[0])
+
+	reserve(reporting, 1)
+	assert.Len(t, warnings, 1, "a threshold warns once")
+
+	warnings = nil
+	reserve(ctx, 13)
+	require.Len(t, warnings, 1, "a reservation crossing both thresholds warns the highest")
+	assert.Equal(t, "tenant", string(warnings[0].Scope))
+	assert.Equal(t, 0.95, warnings[0].Threshold)
+	assert.Equal(t, 0.95, quotas.WarningThreshold(db.UsageScopeTenant, "billing", db.UsagePeriodDay, 19))
+	assert.Zero(t, quotas.WarningThreshold(db.UsageScopeTenant, "billing", db.UsagePeriodMonth, 19))
+}
@@ -0,0 +1,219 @@
+// Package report sends the daily summary report of the previous day to the configured channels
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/alert"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/uptrace/bun"
+)
+
+// notifyTimeout is the maximum time sending the report over one channel may take
+const notifyTimeout = 30 * time.Second
+
+// leaseTTL keeps the other instances from sending the report again, it is shorter than a day so the next
+// report can be sent by any instance
+const leaseTTL = 12 * time.Hour
+
+// webhookRejected is reported for the failures without a reason, the webhook rejected them
+const webhookRejected = "rejected by the webhook"
+
+// Digest is the summary of one day
+type Digest struct {
+	App  string    `json:"app"`
+	Mode string    `json:"mode"`
+	Day  string    `json:"day"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	Sent      int `json:"sent"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	// DeliveryRate is the share of the sent messages reported delivered when delivery reports are enabled,
+	// otherwise the share of the sent and failed messages the webhook accepted
+	DeliveryRate float64 `json:"delivery_rate"`
+	// TopErrors are the most frequent failure reasons
+	TopErrors []db.FailureReason `json:"top_errors"`
+	// BacklogStart and BacklogEnd are the messages waiting to be sent when the day started and ended
+	BacklogStart int `json:"backlog_start"`
+	BacklogEnd   int `json:"backlog_end"`
+
+	deliveryReports bool
+}
+
+// Subject is a one line summary of the report
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("[REPORT] %s (%s): %s", d.App, d.Mode, d.Day)
+}
+
+// Text is the human readable report used by chat and email notifiers
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", d.Subject())
+	if d.deliveryReports {
+		fmt.Fprintf(&b, "Sent: %d, delivered: %d, failed: %d\n", d.Sent, d.Delivered, d.Failed)
+	} else {
+		fmt.Fprintf(&b, "Sent: %d, failed: %d\n", d.Sent, d.Failed)
+	}
+	fmt.Fprintf(&b, "Delivery rate: %.1f%%\n", d.DeliveryRate*100)
+	fmt.Fprintf(&b, "Backlog: %d -> %d pending (%+d)", d.BacklogStart, d.BacklogEnd, d.BacklogEnd-d.BacklogStart)
+
+	if len(d.TopErrors) > 0 {
+		b.WriteString("\nTop failure reasons:")
+		for _, reason := range d.TopErrors {
+			fmt.Fprintf(&b, "\n  %d  %s", reason.Count, reason.Reason)
+		}
+	}
+	return b.String()
+}
+
+// Reporter sends the report of the previous day every day at reports.at
+type Reporter struct {
+	cfg       *config.Cfg
+	db        bun.IDB
+	notifiers []alert.Notifier
+	location  *time.Location
+	at        time.Time
+	holder    string
+}
+
+func NewReporter(cfg *config.Cfg, database bun.IDB, notifiers []alert.Notifier) (*Reporter, error) {
+	location, err := time.LoadLocation(cfg.Reports.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reports.timezone: %w", err)
+	}
+	at, err := time.Parse("15:04", cfg.Reports.At)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reports.at: %w", err)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "sendpulse"
+	}
+	return &Reporter{
+		cfg:       cfg,
+		db:        database,
+		notifiers: notifiers,
+		location:  location,
+		at:        at,
+		holder:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}, nil
+}
+
+// Next returns the first time the report is sent after now
+func (r *Reporter) Next(now time.Time) time.Time {
+	local := now.In(r.location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), r.at.Hour(), r.at.Minute(), 0, 0, r.location)
+	if !next.After(now) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, r.at.Hour(), r.at.Minute(), 0, 0, r.location)
+	}
+	return next
+}
+
+// Run sends the report every day until ctx is cancelled. With several instances the one taking the report
+// lease sends it.
+func (r *Reporter) Run(ctx context.Context) {
+	config.Log().Infof("Daily report scheduled at %s %s (notifiers: %d)", r.cfg.Reports.At, r.location, len(r.notifiers))
+	for {
+		next := r.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		acquired, err := db.AcquireLease(ctx, r.db, db.ReportLeaseName, r.holder, leaseTTL)
+		if err != nil {
+			config.Log().Errorf("Failed to take the report lease: %v", err)
+			continue
+		}
+		if !acquired {
+			config.Log().Debug("Daily report is sent by another instance")
+			continue
+		}
+		if err := r.Send(ctx, next.AddDate(0, 0, -1)); err != nil {
+			config.Log().Errorf("Failed to send the daily report: %v", err)
+		}
+	}
+}
+
+// Send builds the report of the day and sends it to every notifier
+func (r *Reporter) Send(ctx context.Context, day time.Time) error {
+	digest, err := r.Build(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	config.Log().Info(digest.Subject())
+	for _, notifier := range r.notifiers {
+		nctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := notifier.Notify(nctx, digest); err != nil {
+			config.Log().Errorf("Failed to send the daily report via %s: %v", notifier.Name(), err)
+		}
+		cancel()
+	}
+	return nil
+}
+
+// Build summarizes the day of the reports.timezone the time day is in
+func (r *Reporter) Build(ctx context.Context, day time.Time) (*Digest, error) {
+	from := startOfDay(day.In(r.location))
+	to := from.AddDate(0, 0, 1)
+
+	counts, err := db.GetReportCounts(ctx, r.db, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	digest := &Digest{
+		App:             r.cfg.AppName,
+		Mode:            string(r.cfg.Server.Mode),
+		Day:             from.Format(time.DateOnly),
+		From:            from,
+		To:              to,
+		Sent:            counts.Sent,
+		Delivered:       counts.Delivered,
+		Failed:          counts.Failed,
+		deliveryReports: r.cfg.DeliveryReports.Enabled,
+	}
+	if digest.deliveryReports {
+		if counts.Sent > 0 {
+			digest.DeliveryRate = float64(counts.Delivered) / float64(counts.Sent)
+		}
+	} else if counts.Sent+counts.Failed > 0 {
+		digest.DeliveryRate = float64(counts.Sent) / float64(counts.Sent+counts.Failed)
+	}
+
+	if r.cfg.Reports.TopErrors > 0 {
+		digest.TopErrors, err = db.GetTopFailureReasons(ctx, r.db, from, to, r.cfg.Reports.TopErrors)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load failure reasons: %w", err)
+		}
+		for i := range digest.TopErrors {
+			if digest.TopErrors[i].Reason == "" {
+				digest.TopErrors[i].Reason = webhookRejected
+			}
+		}
+	}
+
+	if digest.BacklogStart, err = db.CountBacklogAt(ctx, r.db, from); err != nil {
+		return nil, fmt.Errorf("failed to count the backlog: %w", err)
+	}
+	if digest.BacklogEnd, err = db.CountBacklogAt(ctx, r.db, to); err != nil {
+		return nil, fmt.Errorf("failed to count the backlog: %w", err)
+	}
+	return digest, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/alert"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	return bunDB
+}
+
+func testConfig() *config.Cfg {
+	cfg := &config.Cfg{AppName: "sendpulse"}
+	cfg.Server.Mode = config.ModeProd
+	cfg.Reports = config.Reports{Enabled: true, At: "08:00", Timezone: "Europe/Istanbul", TopErrors: 2}
+	return cfg
+}
+
+func TestReporter_Next(t *testing.T) {
+	reporter, err := NewReporter(testConfig(), nil, nil)
+	require.NoError(t, err)
+	istanbul, _ := time.LoadLocation("Europe/Istanbul")
+
+	// 04:59 UTC is 07:59 in Istanbul
+	next := reporter.Next(time.Date(2026, 10, 15, 4, 59, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 15, 8, 0, 0, 0, istanbul), next)
+
+	next = reporter.Next(time.Date(2026, 10, 15, 5, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 16, 8, 0, 0, 0, istanbul), next)
+}
+
+func TestReporter_Send(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+	ctx := context.Background()
+
+	cfg := testConfig()
+	cfg.Reports.Timezone = "UTC"
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		t := day.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+
+	messages := []*db.Message{
+		// pending since the day before, sent during the day
+		{Status: db.MessageStatusDelivered, CreatedAt: *at(-2), SentAt: at(1), UpdatedAt: *at(2)},
+		{Status: db.MessageStatusSent, CreatedAt: *at(3), SentAt: at(3), UpdatedAt: *at(3)},
+		{Status: db.MessageStatusSent, CreatedAt: *at(4), SentAt: at(4), UpdatedAt: *at(4)},
+		{Status: db.MessageStatusFailed, CreatedAt: *at(5), UpdatedAt: *at(5)},
+		{Status: db.MessageStatusFailed, CreatedAt: *at(6), UpdatedAt: *at(6), DeliveryError: "unknown subscriber"},
+		{Status: db.MessageStatusFailed, CreatedAt: *at(7), UpdatedAt: *at(7), DeliveryError: "unknown subscriber"},
+		// still waiting when the day ended
+		{Status: db.MessageStatusPending, CreatedAt: *at(-1), UpdatedAt: *at(-1)},
+		{Status: db.MessageStatusPending, CreatedAt: *at(20), UpdatedAt: *at(20)},
+		{Status: db.MessageStatusPending, CreatedAt: *at(22), UpdatedAt: *at(22)},
+		// the next day
+		{Status: db.MessageStatusSent, CreatedAt: *at(25), SentAt: at(25), UpdatedAt: *at(25)},
+	}
+	for _, message := range messages {
+		message.To = "+905551111111"
+		message.Content = "Hello"
+		_, err := database.NewInsert().Model(message).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	reporter, err := NewReporter(cfg, database, alert.NewChannelNotifiers(server.URL, server.URL, config.AlertEmail{}))
+	require.NoError(t, err)
+
+	digest, err := reporter.Build(ctx, day.Add(12*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-15", digest.Day)
+	assert.Equal(t, 3, digest.Sent)
+	assert.Equal(t, 1, digest.Delivered)
+	assert.Equal(t, 3, digest.Failed)
+	assert.InDelta(t, 0.5, digest.DeliveryRate, 0.001)
+	assert.Equal(t, []db.FailureReason{{Reason: "unknown subscriber", Count: 2}, {Reason: webhookRejected, Count: 1}}, digest.TopErrors)
+	assert.Equal(t, 2, digest.BacklogStart)
+	assert.Equal(t, 3, digest.BacklogEnd)
+
+	require.NoError(t, reporter.Send(ctx, day))
+	require.Len(t, bodies, 2)
+	assert.Equal(t, "[REPORT] sendpulse (prod): 2026-10-15\n"+
+		"Sent: 3, failed: 3\n"+
+		"Delivery rate: 50.0%\n"+
+		"Backlog: 2 -> 3 pending (+1)\n"+
+		"Top failure reasons:\n"+
+		"  2  unknown subscriber\n"+
+		"  1  rejected by the webhook", bodies[0]["text"])
+	assert.Equal(t, float64(3), bodies[1]["sent"])
+	assert.Equal(t, "2026-10-15", bodies[1]["day"])
+}
@@ -1,31 +1,110 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
-	"github.com/boratanrikulu/sendpulse/internal/dto"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/export"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/schema"
 	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
 	"github.com/gofiber/fiber/v2"
 )
 
 type Handlers struct {
 	messageService service.MessageInterface
 	scheduler      service.SchedulerInterface
+	health         service.HealthInterface
+	usage          service.UsageInterface
+	suppression    service.SuppressionInterface
+	deliveries     service.DeliveryReportInterface
+	links          service.LinkInterface
+	costs          service.CostInterface
+	replays        service.ReplayInterface
+	erasures       service.ErasureInterface
+	maintenance    service.MaintenanceInterface
+	ingestion      service.IngestionInterface
+	webhooks       service.WebhookOverrideInterface
+	pauses         service.PauseInterface
+	campaigns      service.CampaignInterface
+	deadLetters    service.DeadLetterInterface
+	jobs           service.JobInterface
+	sla            service.SLAInterface
+	// readOnly is the read-only mode toggled by operators, schemaReadOnly is set while the server is read-only
+	// because of the database schema
+	readOnly       *service.ReadOnlyMode
+	schemaReadOnly bool
+	// region is the role of the region of the instance
+	region *service.RegionFailover
+	// runtime reports the runtime of the instance
+	runtime *service.RuntimeService
 }
 
-func NewHandlers(messageService service.MessageInterface, scheduler service.SchedulerInterface) *Handlers {
+// Services are the services the API is served with. Messages and Scheduler are required, Health and Maintenance
+// may be nil; the endpoints of the other ones fail while they are nil.
+type Services struct {
+	Messages        service.MessageInterface
+	Scheduler       service.SchedulerInterface
+	Health          service.HealthInterface
+	Usage           service.UsageInterface
+	Suppression     service.SuppressionInterface
+	DeliveryReports service.DeliveryReportInterface
+	Links           service.LinkInterface
+	Costs           service.CostInterface
+	Replays         service.ReplayInterface
+	Erasures        service.ErasureInterface
+	Maintenance     service.MaintenanceInterface
+	Ingestion       service.IngestionInterface
+	Webhooks        service.WebhookOverrideInterface
+	Pauses          service.PauseInterface
+	Campaigns       service.CampaignInterface
+	DeadLetters     service.DeadLetterInterface
+	Jobs            service.JobInterface
+	SLA             service.SLAInterface
+	// Runtime reports the runtime of the instance at /api/v1/admin/runtime
+	Runtime *service.RuntimeService
+}
+
+func NewHandlers(services Services) *Handlers {
 	return &Handlers{
-		messageService: messageService,
-		scheduler:      scheduler,
+		messageService: services.Messages,
+		scheduler:      services.Scheduler,
+		health:         services.Health,
+		usage:          services.Usage,
+		suppression:    services.Suppression,
+		deliveries:     services.DeliveryReports,
+		links:          services.Links,
+		costs:          services.Costs,
+		replays:        services.Replays,
+		erasures:       services.Erasures,
+		maintenance:    services.Maintenance,
+		ingestion:      services.Ingestion,
+		webhooks:       services.Webhooks,
+		pauses:         services.Pauses,
+		campaigns:      services.Campaigns,
+		deadLetters:    services.DeadLetters,
+		jobs:           services.Jobs,
+		sla:            services.SLA,
+		runtime:        services.Runtime,
 	}
 }
 
 // healthHandler handles health check requests
 // @Summary Health Check
-// @Description Check if the service is running
+// @Description Check if the service is running, the status is degraded while the database is unreachable
 // @Tags health
 // @Produce json
 // @Success 200 {object} dto.HealthResponse
@@ -39,55 +118,115 @@ func (h *Handlers) healthHandler(c *fiber.Ctx) error {
 		Service: "sendpulse",
 		Version: config.Version,
 		Mode:    string(getCfg(c).Server.Mode),
+		Listen:  getCfg(c).Server.ListenAddresses(),
+	}
+	if h.health != nil {
+		response.Database = "up"
+		if !h.health.DatabaseUp(c.UserContext()) {
+			response.Status = "degraded"
+			response.Database = "down"
+		}
 	}
 
 	return c.JSON(response)
 }
 
+// readinessHandler handles readiness probe requests
+// @Summary Readiness Check
+// @Description Check if the service and its dependencies are ready to serve traffic
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.ReadinessResponse
+// @Failure 503 {object} dto.ReadinessResponse
+// @Router /readyz [get]
+func (h *Handlers) readinessHandler(c *fiber.Ctx) error {
+	response := h.health.Ready(c.UserContext())
+
+	statusCode := 200
+	if response.Status != "ok" {
+		statusCode = 503
+	}
+
+	return c.Status(statusCode).JSON(response)
+}
+
 // startMessagingHandler handles starting the messaging service
 // @Summary Start Messaging Service
-// @Description Start the automatic message sending process
+// @Description Start the automatic message sending process, on every instance when messaging.control_interval is set. Starting a running service is 409 with code already_running, or 200 with idempotent=true.
 // @Tags messaging
 // @Produce json
+// @Param idempotent query bool false "Answer 200 when the service is already running"
 // @Success 200 {object} dto.MessagingControlResponse
-// @Failure 400 {object} dto.MessagingControlResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.MessagingControlResponse
 // @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /api/v1/messaging/start [post]
 func (h *Handlers) startMessagingHandler(c *fiber.Ctx) error {
-	response, err := h.scheduler.Start(c.Context())
+	idempotent, err := idempotentParam(c)
 	if err != nil {
-		return handleError(c, err)
+		return invalidRequest(c, err)
 	}
 
-	statusCode := 200
-	if response.Status == "error" {
-		statusCode = 400
+	// the processing loop outlives the request, on shutdown it is stopped by the handoff
+	response, err := h.scheduler.Start(context.WithoutCancel(c.UserContext()))
+	if err != nil {
+		return handleError(c, err)
 	}
 
-	return c.Status(statusCode).JSON(response)
+	return controlResponse(c, response, idempotent)
 }
 
 // stopMessagingHandler handles stopping the messaging service
 // @Summary Stop Messaging Service
-// @Description Stop the automatic message sending process
+// @Description Stop the automatic message sending process, on every instance when messaging.control_interval is set. Stopping a stopped service is 409 with code not_running, or 200 with idempotent=true.
 // @Tags messaging
 // @Produce json
+// @Param idempotent query bool false "Answer 200 when the service is not running"
 // @Success 200 {object} dto.MessagingControlResponse
-// @Failure 400 {object} dto.MessagingControlResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.MessagingControlResponse
 // @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
 // @Router /api/v1/messaging/stop [post]
 func (h *Handlers) stopMessagingHandler(c *fiber.Ctx) error {
-	response, err := h.scheduler.Stop(c.Context())
+	idempotent, err := idempotentParam(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.scheduler.Stop(c.UserContext())
 	if err != nil {
 		return handleError(c, err)
 	}
 
-	statusCode := 200
-	if response.Status == "error" {
-		statusCode = 400
+	return controlResponse(c, response, idempotent)
+}
+
+// idempotentParam parses the idempotent query parameter of the messaging control requests
+func idempotentParam(c *fiber.Ctx) (bool, error) {
+	value := c.Query("idempotent")
+	if value == "" {
+		return false, nil
+	}
+	idempotent, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("idempotent must be true or false")
 	}
+	return idempotent, nil
+}
 
-	return c.Status(statusCode).JSON(response)
+// controlResponse answers a messaging control request, a start or stop that changed nothing is 409 Conflict,
+// or a success when the request is idempotent
+func controlResponse(c *fiber.Ctx, response *dto.MessagingControlResponse, idempotent bool) error {
+	if response.Code != dto.ControlAlreadyRunning && response.Code != dto.ControlNotRunning {
+		return c.JSON(response)
+	}
+	if idempotent {
+		response.Status = "success"
+		return c.JSON(response)
+	}
+	return c.Status(fiber.StatusConflict).JSON(response)
 }
 
 // messagingStatusHandler handles getting messaging service status
@@ -96,103 +235,404 @@ func (h *Handlers) stopMessagingHandler(c *fiber.Ctx) error {
 // @Tags messaging
 // @Produce json
 // @Success 200 {object} dto.MessagingStatusResponse
+// @Security ApiKeyAuth
 // @Router /api/v1/messaging/status [get]
 func (h *Handlers) messagingStatusHandler(c *fiber.Ctx) error {
 	response := h.scheduler.GetStatus()
 	return c.JSON(response)
 }
 
-// listMessagesHandler handles listing sent messages with pagination
-// @Summary List Sent Messages
-// @Description Get a paginated list of sent messages
+// forecastHandler handles the forecast of the pending messages
+// @Summary Messaging Forecast
+// @Description Estimate when the pending messages of every campaign are sent, following the batches of the scheduler with its interval, batch size, route rate limits and throttle profiles, or with the settings given to answer what-if questions before changing them. Assumes messaging runs from now, sends succeed and no more messages arrive. The campaigns draining last come first, drain_at is unset for those not drained within the horizon.
+// @Tags messaging
+// @Produce json
+// @Param interval query string false "Interval between batches (default: messaging.interval)"
+// @Param batch_size query int false "Messages claimed per batch (default: messaging.batch_size)"
+// @Param rate_limit query number false "Messages per second of every route, 0 is unlimited (default: the route rate limits)"
+// @Success 200 {object} dto.ForecastResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messaging/forecast [get]
+func (h *Handlers) forecastHandler(c *fiber.Ctx) error {
+	var opts service.ForecastOptions
+	if value := c.Query("interval"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return badRequest(c, fmt.Sprintf("invalid interval: %v", err))
+		}
+		opts.Interval = interval
+	}
+	if value := c.Query("batch_size"); value != "" {
+		batchSize, err := strconv.Atoi(value)
+		if err != nil {
+			return badRequest(c, "invalid batch_size")
+		}
+		opts.BatchSize = batchSize
+	}
+	if value := c.Query("rate_limit"); value != "" {
+		rateLimit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return badRequest(c, "invalid rate_limit")
+		}
+		opts.RateLimit = &rateLimit
+	}
+
+	response, err := h.messageService.Forecast(c.UserContext(), getCfg(c), opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidForecast) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// statsHandler handles queue statistics requests
+// @Summary Message Statistics
+// @Description Message counts per status, today's throughput and failure rate and the age of the oldest pending message
 // @Tags messages
 // @Produce json
-// @Param page query int false "Page number (default: 1)" minimum(1)
-// @Param page_size query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
-// @Success 200 {object} dto.MessagesListResponse
+// @Success 200 {object} dto.StatsResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/stats [get]
+func (h *Handlers) statsHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.Stats(c.UserContext())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// timeseriesHandler handles message counts over time
+// @Summary Message Time Series
+// @Description Messages created, sent and failed per UTC minute, hour, day or week, with zero counts for the buckets without messages. Sent messages are counted when they were sent, failed ones when they failed.
+// @Tags messages
+// @Produce json
+// @Param bucket query string false "Bucket width (default: 1h)" Enums(1m, 1h, 1d, 1w)
+// @Param from query string false "From (YYYY-MM-DD or RFC3339, default: 24 buckets before to)"
+// @Param to query string false "To, exclusive (YYYY-MM-DD or RFC3339, default: now)"
+// @Success 200 {object} dto.TimeseriesResponse
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
-// @Router /api/v1/messages [get]
-func (h *Handlers) listMessagesHandler(c *fiber.Ctx) error {
-	// Parse query parameters - let service handle validation
-	page := 1
-	if pageParam := c.Query("page"); pageParam != "" {
-		if p, err := strconv.Atoi(pageParam); err == nil {
-			page = p
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/stats/timeseries [get]
+func (h *Handlers) timeseriesHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.messageService.Timeseries(c.UserContext(), c.Query("bucket", string(db.BucketHour)), filter.From, filter.To)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTimeseries) {
+			return invalidRequest(c, err)
 		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// duplicatesHandler handles the report of likely double-sends
+// @Summary Duplicate Messages
+// @Description Report the recipients given the same content more than once in a window of creation times, each message created within the window of the one before, with the API links of the messages. The groups created last come first; at most limit recipient and content pairs are examined.
+// @Tags messages
+// @Produce json
+// @Param from query string false "Created at or after (YYYY-MM-DD or RFC3339, default: 24h before to)"
+// @Param to query string false "Created before (YYYY-MM-DD or RFC3339, default: now)"
+// @Param window query string false "Longest gap between two messages of a group (default: 10m)"
+// @Param limit query int false "Recipient and content pairs examined (default: 100, max: 1000)"
+// @Success 200 {object} dto.DuplicatesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/duplicates [get]
+func (h *Handlers) duplicatesHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+	window, err := time.ParseDuration(c.Query("window", service.DefaultDuplicateWindow.String()))
+	if err != nil {
+		return badRequest(c, fmt.Sprintf("invalid window: %v", err))
 	}
 
-	pageSize := 20
-	if pageSizeParam := c.Query("page_size"); pageSizeParam != "" {
-		if ps, err := strconv.Atoi(pageSizeParam); err == nil {
-			pageSize = ps
+	response, err := h.messageService.Duplicates(c.UserContext(), filter.From, filter.To, window, c.QueryInt("limit", service.DefaultDuplicateLimit))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDuplicateReport) {
+			return invalidRequest(c, err)
 		}
+		return handleError(c, err)
 	}
 
-	response, err := h.messageService.GetSentMessages(c.Context(), page, pageSize)
+	return c.JSON(response)
+}
+
+// recipientStatsHandler handles the delivery statistics of a recipient
+// @Summary Recipient Stats
+// @Description Summarize the messages to a phone number for support cases like a customer never receiving texts: the counts by status, when the last one was delivered (or sent without a delivery report), the last failure with its provider error, the failures in a row since the last success and the longest such run, and whether the number is suppressed
+// @Tags messages
+// @Produce json
+// @Param phone path string true "Phone number (E.164, + may be encoded as %2B)"
+// @Success 200 {object} dto.RecipientStatsResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/recipients/{phone}/stats [get]
+func (h *Handlers) recipientStatsHandler(c *fiber.Ctx) error {
+	phone, err := url.PathUnescape(c.Params("phone"))
 	if err != nil {
-		// Handle pagination errors with 400 Bad Request
-		if errors.Is(err, service.ErrInvalidPageSize) ||
-			errors.Is(err, service.ErrPageSizeTooLarge) ||
-			errors.Is(err, service.ErrPageSizeTooSmall) {
-			return c.Status(400).JSON(&dto.ErrorResponse{
-				BaseResponse: dto.BaseResponse{
-					Status:    "error",
-					Timestamp: time.Now().UTC(),
-				},
-				Message: err.Error(),
-			})
+		return errorResponse(c, dto.CodeInvalidPhone, "Invalid phone number")
+	}
+
+	response, err := h.messageService.RecipientStats(c.UserContext(), phone)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPhone) {
+			return invalidRequest(c, err)
 		}
 		return handleError(c, err)
 	}
 
-	response.Timestamp = time.Now().UTC()
 	return c.JSON(response)
 }
 
-// getMessageHandler handles getting a specific message by ID
-// @Summary Get Message by ID
-// @Description Get details of a specific message by its ID
+// usageHandler handles quota usage requests
+// @Summary Quota Usage
+// @Description Messages created per API key and tenant in the current UTC day and month, with their quotas
 // @Tags messages
 // @Produce json
-// @Param id path string true "Message ID"
-// @Success 200 {object} dto.SingleMessageResponse
+// @Success 200 {object} dto.UsageResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/usage [get]
+func (h *Handlers) usageHandler(c *fiber.Ctx) error {
+	response, err := h.usage.GetUsage(c.UserContext())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// errorCodesHandler handles listing the codes of the error responses
+// @Summary Error Codes
+// @Description The codes of the error responses with their names, HTTP statuses and descriptions. Codes are stable, clients should match the code of an error response instead of its message. Requires no API key.
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.ErrorCodesResponse
+// @Router /api/v1/errors [get]
+func (h *Handlers) errorCodesHandler(c *fiber.Ctx) error {
+	return c.JSON(&dto.ErrorCodesResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Errors: dto.ErrorCodes,
+	})
+}
+
+// schemasHandler handles listing the JSON Schemas of the request and response DTOs
+// @Summary JSON Schemas
+// @Description The JSON Schemas (draft 2020-12) of the request and response bodies of this API version, to generate clients from and validate payloads against. They are generated from the DTOs of the running version. Requires no API key.
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.SchemasResponse
+// @Router /api/v1/schemas [get]
+func (h *Handlers) schemasHandler(c *fiber.Ctx) error {
+	names := schema.Names()
+	response := &dto.SchemasResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Version: schema.Version,
+		Schemas: make([]dto.SchemaRef, len(names)),
+	}
+	for i, name := range names {
+		response.Schemas[i] = dto.SchemaRef{Name: name, URL: schema.Path(name)}
+	}
+	return c.JSON(response)
+}
+
+// schemaHandler handles requests for the JSON Schema of a DTO
+// @Summary JSON Schema
+// @Description The JSON Schema of a request or response body, the structs it refers to are bundled in $defs. Fields without omitempty are required in responses. Requires no API key.
+// @Tags health
+// @Produce application/schema+json
+// @Param name path string true "Schema name, e.g. CreateMessageRequest, with or without .json"
+// @Success 200 {object} object
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /api/v1/schemas/{name} [get]
+func (h *Handlers) schemaHandler(c *fiber.Ctx) error {
+	raw, ok := schema.Get(strings.TrimSuffix(c.Params("name"), ".json"))
+	if !ok {
+		return errorResponse(c, dto.CodeSchemaNotFound, "Schema not found")
+	}
+	c.Set(fiber.HeaderContentType, "application/schema+json")
+	return c.Send(raw)
+}
+
+// limitsHandler handles requests for the quotas of the calling API key
+// @Summary API Key Limits
+// @Description The daily and monthly quotas of the calling API key with the messages used and remaining and when they reset, empty for keys without quotas. Requires no scope.
+// @Tags messages
+// @Produce json
+// @Success 200 {object} dto.LimitsResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/limits [get]
+func (h *Handlers) limitsHandler(c *fiber.Ctx) error {
+	response, err := h.usage.Limits(c.UserContext())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// listWebhookOverridesHandler handles listing the webhook overrides
+// @Summary List Webhook Overrides
+// @Description Get the webhooks the messages of tenants and campaigns are sent to instead of webhook.url, ordered by scope and name. Credentials are not returned.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} dto.WebhookOverridesResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/webhooks/overrides [get]
+func (h *Handlers) listWebhookOverridesHandler(c *fiber.Ctx) error {
+	response, err := h.webhooks.ListOverrides(c.UserContext())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// putWebhookOverrideHandler handles setting the webhook of a tenant or campaign
+// @Summary Set Webhook Override
+// @Description Send the messages of a tenant or campaign routed to webhook.url to their own webhook, e.g. the gateway of a white-label customer. The override of a message's campaign wins over its tenant's, routes to named providers are not overridden. A token is sent as a bearer token, a username and password with basic authentication, both are encrypted with webhook.encryption_key and never returned. Messages whose credentials cannot be decrypted, e.g. after the key changed, are requeued instead of sent to webhook.url.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param scope path string true "Scope" Enums(tenant, campaign)
+// @Param name path string true "Tenant or campaign"
+// @Param override body dto.WebhookOverrideRequest true "Webhook of the tenant or campaign"
+// @Success 200 {object} dto.SingleWebhookOverrideResponse
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/webhooks/overrides/{scope}/{name} [put]
+func (h *Handlers) putWebhookOverrideHandler(c *fiber.Ctx) error {
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return badRequest(c, "Invalid name")
+	}
+
+	var req dto.WebhookOverrideRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.webhooks.PutOverride(c.UserContext(), c.Params("scope"), name, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhookOverride) || errors.Is(err, service.ErrNoEncryptionKey) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// deleteWebhookOverrideHandler handles removing the webhook of a tenant or campaign
+// @Summary Delete Webhook Override
+// @Description Remove the webhook of a tenant or campaign with its credentials, their messages are sent to webhook.url again
+// @Tags webhooks
+// @Produce json
+// @Param scope path string true "Scope" Enums(tenant, campaign)
+// @Param name path string true "Tenant or campaign"
+// @Success 204
 // @Failure 404 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
-// @Router /api/v1/messages/{id} [get]
-func (h *Handlers) getMessageHandler(c *fiber.Ctx) error {
-	messageID := c.Params("id")
-	if messageID == "" {
-		return c.Status(400).JSON(&dto.ErrorResponse{
+// @Security ApiKeyAuth
+// @Router /api/v1/webhooks/overrides/{scope}/{name} [delete]
+func (h *Handlers) deleteWebhookOverrideHandler(c *fiber.Ctx) error {
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return badRequest(c, "Invalid name")
+	}
+
+	if err := h.webhooks.DeleteOverride(c.UserContext(), c.Params("scope"), name); err != nil {
+		if errors.Is(err, service.ErrWebhookOverrideNotFound) {
+			return errorResponse(c, dto.CodeOverrideNotFound, "Webhook override not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(204)
+}
+
+// egressHandler handles listing the egress addresses
+// @Summary Egress Addresses
+// @Description The local addresses the webhook client sends from, set by webhook.local_address or webhook.interface, and the public egress IPs of webhook.egress_ips, for providers allowing known source IPs only
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.EgressResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/egress [get]
+func (h *Handlers) egressHandler(c *fiber.Ctx) error {
+	cfg := getCfg(c).Webhook
+	ips, err := webhook.LocalAddrs(cfg)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	response := &dto.EgressResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Interface:      cfg.Interface,
+		LocalAddresses: []string{},
+		EgressIPs:      cfg.EgressIPs,
+	}
+	for _, ip := range ips {
+		response.LocalAddresses = append(response.LocalAddresses, ip.String())
+	}
+	if response.EgressIPs == nil {
+		response.EgressIPs = []string{}
+	}
+	return c.JSON(response)
+}
+
+// maintenanceJobsHandler handles listing the maintenance jobs
+// @Summary Maintenance Jobs
+// @Description The maintenance jobs scheduled on the instance with their next run, and their last run on any instance: its status, result or error, and the run and failure counts
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.MaintenanceJobsResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/jobs [get]
+func (h *Handlers) maintenanceJobsHandler(c *fiber.Ctx) error {
+	if h.maintenance == nil {
+		return c.JSON(&dto.MaintenanceJobsResponse{
 			BaseResponse: dto.BaseResponse{
-				Status:    "error",
+				Status:    "ok",
 				Timestamp: time.Now().UTC(),
 			},
-			Message: "Message ID is required",
+			Jobs: []dto.MaintenanceJobResponse{},
 		})
 	}
 
-	response, err := h.messageService.GetMessageByID(c.Context(), messageID)
+	response, err := h.maintenance.Jobs(c.UserContext())
 	if err != nil {
-		if errors.Is(err, service.ErrMessageNotFound) {
-			return c.Status(404).JSON(&dto.ErrorResponse{
-				BaseResponse: dto.BaseResponse{
-					Status:    "error",
-					Timestamp: time.Now().UTC(),
-				},
-				Message: "Message not found",
-			})
-		}
-		if errors.Is(err, service.ErrInvalidMessageID) {
-			return c.Status(400).JSON(&dto.ErrorResponse{
-				BaseResponse: dto.BaseResponse{
-					Status:    "error",
-					Timestamp: time.Now().UTC(),
-				},
-				Message: "Invalid message ID format",
-			})
-		}
 		return handleError(c, err)
 	}
 
@@ -200,20 +640,1567 @@ func (h *Handlers) getMessageHandler(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
-// Helper functions
+// warmupHandler handles warming up the instance
+// @Summary Warmup
+// @Description Prepare a freshly started instance before it is added to the load balancer rotation: open the database connections, load the webhook overrides and connect to the webhook of every provider, so the first requests do not pay for them. Every step is reported in checks, the status is 503 when one failed. It is answered while the instance is read-only.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.WarmupResponse
+// @Failure 503 {object} dto.WarmupResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/warmup [post]
+func (h *Handlers) warmupHandler(c *fiber.Ctx) error {
+	response := h.health.Warmup(c.UserContext())
 
-func getCfg(c *fiber.Ctx) *config.Cfg {
-	return c.Locals("cfg").(*config.Cfg)
+	statusCode := fiber.StatusOK
+	if response.Status != "ok" {
+		statusCode = fiber.StatusServiceUnavailable
+	}
+	return c.Status(statusCode).JSON(response)
 }
 
-func handleError(c *fiber.Ctx, err error) error {
-	config.Log().Errorf("Handler error: %v", err)
+// readOnlyHandler handles getting the read-only state of the server
+// @Summary Read-Only Mode
+// @Description Whether the instance is read-only: in the read-only mode toggled by operators, or because the database schema does not match the binary
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.ReadOnlyResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/read-only [get]
+func (h *Handlers) readOnlyHandler(c *fiber.Ctx) error {
+	return c.JSON(h.readOnlyResponse())
+}
+
+// setReadOnlyHandler handles enabling and disabling the read-only mode
+// @Summary Set Read-Only Mode
+// @Description Enable or disable the read-only mode of the instance, e.g. while a corrupted database is restored. While it is enabled only reads are answered, other requests like creating messages, retries and messaging control are 503 with code read_only, and the scheduler claims no messages. Both resume once it is disabled. Start every instance with server.read_only to make the whole cluster read-only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param mode body dto.ReadOnlyRequest true "Whether the mode is enabled"
+// @Success 200 {object} dto.ReadOnlyResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/read-only [put]
+func (h *Handlers) setReadOnlyHandler(c *fiber.Ctx) error {
+	var req dto.ReadOnlyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+	if req.Enabled == nil {
+		return badRequest(c, "enabled is required")
+	}
+
+	h.readOnly.Set(*req.Enabled, quota.APIKeyFrom(c.UserContext()))
+	return c.JSON(h.readOnlyResponse())
+}
+
+func (h *Handlers) readOnlyResponse() *dto.ReadOnlyResponse {
+	enabled, since, changedBy := h.readOnly.Status()
+	response := &dto.ReadOnlyResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Enabled:   enabled,
+		ChangedBy: changedBy,
+		Schema:    h.schemaReadOnly,
+	}
+	if !since.IsZero() {
+		response.Since = &since
+	}
+	return response
+}
+
+// regionHandler handles getting the role of the region
+// @Summary Region
+// @Description The region of the instance and whether it is active or passive. The scheduler of a passive region claims no messages until the region is promoted.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.RegionResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/region [get]
+func (h *Handlers) regionHandler(c *fiber.Ctx) error {
+	return c.JSON(h.regionResponse())
+}
+
+// promoteRegionHandler handles promoting the region to active
+// @Summary Promote Region
+// @Description Promote the region of the instance to active so its scheduler claims messages, e.g. once the active region failed. Demote the other region first, or make sure it is down, so the regions never send the same message. Every instance of the region is promoted on its own, a promotion is not kept across restarts.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.RegionResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/region/promote [post]
+func (h *Handlers) promoteRegionHandler(c *fiber.Ctx) error {
+	h.region.SetRole(config.RegionActive, quota.APIKeyFrom(c.UserContext()))
+	return c.JSON(h.regionResponse())
+}
+
+// demoteRegionHandler handles demoting the region to passive
+// @Summary Demote Region
+// @Description Demote the region of the instance to passive, its scheduler stops claiming messages after the running batch. Every instance of the region is demoted on its own, a demotion is not kept across restarts.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.RegionResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/region/demote [post]
+func (h *Handlers) demoteRegionHandler(c *fiber.Ctx) error {
+	h.region.SetRole(config.RegionPassive, quota.APIKeyFrom(c.UserContext()))
+	return c.JSON(h.regionResponse())
+}
+
+func (h *Handlers) regionResponse() *dto.RegionResponse {
+	role, since, changedBy := h.region.Status()
+	return &dto.RegionResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Region:    h.region.Name(),
+		Role:      role,
+		Since:     since,
+		ChangedBy: changedBy,
+	}
+}
+
+// runtimeHandler handles getting the runtime of the instance
+// @Summary Runtime
+// @Description The build, GOMAXPROCS, goroutine count, memory, uptime and scheduler internals of the instance and its effective configuration with the secrets redacted, for incident triage. Every instance reports its own runtime.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.RuntimeResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/runtime [get]
+func (h *Handlers) runtimeHandler(c *fiber.Ctx) error {
+	return c.JSON(h.runtime.Runtime())
+}
+
+// routingHandler handles getting the routing decisions of the instance
+// @Summary Routing
+// @Description The routes of the scheduler of the instance and the providers their messages are split between, with the weight, health score, current share and routing decisions of every provider. Scores follow the success rate and latency of the sends of the instance within routing.health.window, every instance reports its own.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.RoutingResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/admin/routing [get]
+func (h *Handlers) routingHandler(c *fiber.Ctx) error {
+	return c.JSON(h.runtime.Routing())
+}
+
+// listMessagesHandler handles listing messages with pagination
+// @Summary List Messages
+// @Description Get a paginated list of sent messages, or of messages of any status matching the status, creation date, tag and metadata filters, sorting or a cursor
+// @Tags messages
+// @Produce json
+// @Param page query int false "Page number (default: 1)" minimum(1)
+// @Param page_size query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Param sort query string false "Order of the messages of any status, newest (default) or oldest first" Enums(-created_at, created_at)
+// @Param cursor query string false "The next_cursor of the page before, continues the list instead of page"
+// @Param status query string false "Only messages with this status" Enums(pending, sending, accepted, sent, delivered, unconfirmed, accepted_without_id, failed, blocked, quarantined, cancelled, expired)
+// @Param from query string false "Only messages created at or after this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Only messages created before this date (YYYY-MM-DD or RFC3339)"
+// @Param tag query string false "Only messages with this tag in their metadata"
+// @Param metadata.order_id query string false "Only messages with this metadata value, any metadata.<key> parameter filters by that key"
+// @Param dead_lettered query bool false "Only failed messages in the dead-letter queue, no longer requeued automatically"
+// @Param acknowledged query bool false "Only messages whose failure an operator acknowledged (true) or did not acknowledge yet (false)"
+// @Success 200 {object} dto.MessagesListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages [get]
+func (h *Handlers) listMessagesHandler(c *fiber.Ctx) error {
+	// Parse query parameters - let service handle validation
+	page := query.Page{
+		Number: c.QueryInt("page", query.MinPage),
+		Size:   c.QueryInt("page_size", query.DefaultPageSize),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	// Without filters, sorting or a cursor only sent messages are listed, ordered by sent time
+	var response *dto.MessagesListResponse
+	if filter.IsZero() && page.IsZero() {
+		response, err = h.messageService.GetSentMessages(c.UserContext(), page.Number, page.Size)
+	} else {
+		response, err = h.messageService.ListMessages(c.UserContext(), filter, page)
+	}
+	if err != nil {
+		// Handle validation errors with 400 Bad Request
+		if errors.Is(err, service.ErrInvalidPageSize) ||
+			errors.Is(err, service.ErrPageSizeTooLarge) ||
+			errors.Is(err, service.ErrPageSizeTooSmall) ||
+			errors.Is(err, service.ErrInvalidStatus) ||
+			errors.Is(err, query.ErrInvalidSort) ||
+			errors.Is(err, query.ErrInvalidCursor) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	response.Timestamp = time.Now().UTC()
+	return c.JSON(response)
+}
+
+// getMessageHandler handles getting a specific message by ID
+// @Summary Get Message by ID
+// @Description Get details of a specific message by its ID
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id} [get]
+func (h *Handlers) getMessageHandler(c *fiber.Ctx) error {
+	messageID := c.Params("id")
+	if messageID == "" {
+		return errorResponse(c, dto.CodeInvalidMessageID, "Message ID is required")
+	}
+
+	response, err := h.messageService.GetMessageByID(c.UserContext(), messageID)
+	if err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return errorResponse(c, dto.CodeInvalidMessageID, "Invalid message ID format")
+		}
+		return handleError(c, err)
+	}
+
+	response.Timestamp = time.Now().UTC()
+	return c.JSON(response)
+}
+
+// createMessageHandler handles enqueueing a new message
+// @Summary Create Message
+// @Description Enqueue a new message, optionally prioritized or scheduled for a later time. Messages violating a reject rule of the content policy are refused with 422, those violating a quarantine rule are enqueued as quarantined. A message with expires_at is expired instead of sent once it passed, a status notice is posted to its callback_url, or the one of the API key, when it expires, is blocked or is dead-lettered.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param message body dto.CreateMessageRequest true "Message to enqueue"
+// @Success 201 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages [post]
+func (h *Handlers) createMessageHandler(c *fiber.Ctx) error {
+	var req dto.CreateMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeInvalidBody,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+	}
+
+	// the status notices of the message go to the callback URL of the API key unless the request names one
+	if key, ok := c.Locals(apiKeyLocalsKey).(config.APIKey); ok && req.CallbackURL == "" {
+		req.CallbackURL = key.CallbackURL
+	}
+	response, err := h.messageService.CreateMessage(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessage) {
+			return errorResponse(c, dto.CodeInvalidMessage, err.Error())
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			return errorResponse(c, dto.CodeQuotaExceeded, err.Error())
+		}
+		if errors.Is(err, service.ErrContentRejected) {
+			return errorResponse(c, dto.CodeContentRejected, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	response.Timestamp = time.Now().UTC()
+	return c.Status(201).JSON(response)
+}
+
+// listPausesHandler handles listing the recipient pauses
+// @Summary List Recipient Pauses
+// @Description Get the active pauses of phone numbers and prefix ranges ordered by prefix, expired pauses are not listed
+// @Tags messaging
+// @Produce json
+// @Success 200 {object} dto.RecipientPausesResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messaging/pauses [get]
+func (h *Handlers) listPausesHandler(c *fiber.Ctx) error {
+	response, err := h.pauses.ListPauses(c.UserContext())
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// createPauseHandler handles pausing a recipient or prefix range
+// @Summary Pause Recipients
+// @Description Pause sending to a phone number or to every number starting with a prefix, e.g. +9055 during the outage of a carrier. Their pending messages stay queued, deferred until the pause is lifted or expires and checked again every messaging.pause_recheck. Pausing a paused prefix replaces its reason and expiry.
+// @Tags messaging
+// @Accept json
+// @Produce json
+// @Param pause body dto.RecipientPauseRequest true "Phone number or prefix to pause"
+// @Success 201 {object} dto.SingleRecipientPauseResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messaging/pauses [post]
+func (h *Handlers) createPauseHandler(c *fiber.Ctx) error {
+	var req dto.RecipientPauseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.pauses.AddPause(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPause) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.Status(201).JSON(response)
+}
+
+// deletePauseHandler handles lifting the pause of a recipient or prefix range
+// @Summary Resume Recipients
+// @Description Lift the pause of a phone number or prefix, its messages are sent again within messaging.pause_recheck
+// @Tags messaging
+// @Produce json
+// @Param prefix path string true "Paused phone number or prefix (+ may be encoded as %2B)"
+// @Success 204
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messaging/pauses/{prefix} [delete]
+func (h *Handlers) deletePauseHandler(c *fiber.Ctx) error {
+	prefix, err := url.PathUnescape(c.Params("prefix"))
+	if err != nil {
+		return badRequest(c, "Invalid prefix")
+	}
+
+	if err := h.pauses.RemovePause(c.UserContext(), prefix); err != nil {
+		if errors.Is(err, service.ErrPauseNotFound) {
+			return errorResponse(c, dto.CodePauseNotFound, "Pause not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(204)
+}
+
+// listCampaignsHandler handles listing the campaigns of the approval workflow
+// @Summary List Campaigns
+// @Description Get the campaigns of the approval workflow ordered by name, with the number of their pending messages
+// @Tags campaigns
+// @Produce json
+// @Param status query string false "Filter by status" Enums(draft, pending_approval, approved, launched, paused)
+// @Success 200 {object} dto.CampaignsResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns [get]
+func (h *Handlers) listCampaignsHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.ListCampaigns(c.UserContext(), c.Query("status"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// getCampaignHandler handles getting a campaign of the approval workflow
+// @Summary Get Campaign
+// @Description Get a campaign of the approval workflow by name
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name} [get]
+func (h *Handlers) getCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.GetCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// createCampaignHandler handles registering a campaign as a draft
+// @Summary Create Campaign
+// @Description Register a campaign as a draft. Its pending messages are held, deferred and checked again every campaigns.recheck, until the campaign is approved by another API key and launched.
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param campaign body dto.CampaignRequest true "Campaign to register"
+// @Success 201 {object} dto.SingleCampaignResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns [post]
+func (h *Handlers) createCampaignHandler(c *fiber.Ctx) error {
+	var req dto.CampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.campaigns.CreateCampaign(c.UserContext(), &req)
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.Status(201).JSON(response)
+}
+
+// submitCampaignHandler handles submitting a draft campaign for approval
+// @Summary Submit Campaign
+// @Description Submit a draft campaign for approval, another API key has to approve it
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/submit [post]
+func (h *Handlers) submitCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.SubmitCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// approveCampaignHandler handles approving a submitted campaign
+// @Summary Approve Campaign
+// @Description Approve a campaign pending approval, the API key that submitted it cannot approve it
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/approve [post]
+func (h *Handlers) approveCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.ApproveCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// rejectCampaignHandler handles sending a submitted campaign back to draft
+// @Summary Reject Campaign
+// @Description Reject a campaign pending approval, it goes back to draft with the reason of the rejection
+// @Tags campaigns
+// @Accept json
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Param review body dto.CampaignReviewRequest true "Reason of the rejection"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/reject [post]
+func (h *Handlers) rejectCampaignHandler(c *fiber.Ctx) error {
+	var req dto.CampaignReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.campaigns.RejectCampaign(c.UserContext(), c.Params("name"), &req)
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// launchCampaignHandler handles launching an approved campaign
+// @Summary Launch Campaign
+// @Description Launch an approved campaign, its held messages are sent within campaigns.recheck
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/launch [post]
+func (h *Handlers) launchCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.LaunchCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// pauseCampaignHandler handles pausing a launched campaign
+// @Summary Pause Campaign
+// @Description Pause a launched campaign, its pending messages are held from now on and the sends in flight finish. Its throttle profile, transform pipeline or pending messages can be changed before it is resumed.
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/pause [post]
+func (h *Handlers) pauseCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.PauseCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// resumeCampaignHandler handles resuming a paused campaign
+// @Summary Resume Campaign
+// @Description Resume a paused campaign from its progress cursor within campaigns.recheck, its messages to recipients the campaign sent to already are cancelled instead of sent
+// @Tags campaigns
+// @Produce json
+// @Param name path string true "Campaign name"
+// @Success 200 {object} dto.SingleCampaignResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/campaigns/{name}/resume [post]
+func (h *Handlers) resumeCampaignHandler(c *fiber.Ctx) error {
+	response, err := h.campaigns.ResumeCampaign(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// campaignError maps the errors of the approval workflow to their codes
+func campaignError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidCampaign):
+		return invalidRequest(c, err)
+	case errors.Is(err, service.ErrCampaignNotFound):
+		return errorResponse(c, dto.CodeCampaignNotFound, "Campaign not found")
+	case errors.Is(err, service.ErrCampaignExists):
+		return errorResponse(c, dto.CodeCampaignExists, "Campaign already registered")
+	case errors.Is(err, service.ErrCampaignStatus):
+		return errorResponse(c, dto.CodeCampaignStatus, err.Error())
+	case errors.Is(err, service.ErrSelfApproval):
+		return errorResponse(c, dto.CodeSelfApproval, "The API key that submitted the campaign cannot approve it")
+	}
+	return handleError(c, err)
+}
+
+// listSuppressionsHandler handles listing suppressed recipients with pagination
+// @Summary List Suppressions
+// @Description Get a paginated list of suppressed recipients, newest first
+// @Tags suppressions
+// @Produce json
+// @Param page query int false "Page number (default: 1)" minimum(1)
+// @Param page_size query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} dto.SuppressionsListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/suppressions [get]
+func (h *Handlers) listSuppressionsHandler(c *fiber.Ctx) error {
+	response, err := h.suppression.ListSuppressions(c.UserContext(), c.QueryInt("page", 1), c.QueryInt("page_size", 20))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPageSize) ||
+			errors.Is(err, service.ErrPageSizeTooLarge) ||
+			errors.Is(err, service.ErrPageSizeTooSmall) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// createSuppressionHandler handles suppressing a recipient
+// @Summary Suppress Recipient
+// @Description Suppress a recipient, their pending and new messages are blocked instead of sent
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param suppression body dto.CreateSuppressionRequest true "Recipient to suppress"
+// @Success 201 {object} dto.SingleSuppressionResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/suppressions [post]
+func (h *Handlers) createSuppressionHandler(c *fiber.Ctx) error {
+	var req dto.CreateSuppressionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.suppression.AddSuppression(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPhone) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.Status(201).JSON(response)
+}
+
+// importSuppressionsHandler handles importing an opt-out list
+// @Summary Import Suppressions
+// @Description Suppress every number of an opt-out list posted as the request body, e.g. the do-not-contact list of a previous vendor. CSV lists name their number column phone, phone_number, number, msisdn, mobile, recipient or to (an optional reason column is kept), or have the number in their first column without a header; text lists hold one number per line. Numbers are normalized to E.164, MSISDNs without a + included. Repeated and already suppressed numbers are counted and skipped, invalid rows are reported without stopping the import. With dry_run nothing is suppressed.
+// @Tags suppressions
+// @Accept plain
+// @Produce json
+// @Param format query string false "List format (default: text for text/plain bodies, csv otherwise)" Enums(csv, text)
+// @Param reason query string false "Reason of the numbers without one of their own"
+// @Param dry_run query bool false "Report what the import would do without suppressing any number"
+// @Param list body string true "Opt-out list"
+// @Success 200 {object} dto.SuppressionImportResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/suppressions/import [post]
+func (h *Handlers) importSuppressionsHandler(c *fiber.Ctx) error {
+	opts := service.SuppressionImport{
+		Format: c.Query("format", ingest.FormatCSV),
+		Reason: c.Query("reason"),
+		DryRun: c.QueryBool("dry_run"),
+	}
+	if c.Query("format") == "" && strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMETextPlain) {
+		opts.Format = ingest.FormatText
+	}
+
+	response, err := h.suppression.ImportSuppressions(c.UserContext(), bytes.NewReader(c.Body()), opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImport) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// deleteSuppressionHandler handles lifting the suppression of a recipient
+// @Summary Unsuppress Recipient
+// @Description Lift the suppression of a recipient, messages blocked in the meantime stay blocked
+// @Tags suppressions
+// @Produce json
+// @Param phone path string true "Suppressed phone number (E.164, + may be encoded as %2B)"
+// @Success 204
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/suppressions/{phone} [delete]
+func (h *Handlers) deleteSuppressionHandler(c *fiber.Ctx) error {
+	phone, err := url.PathUnescape(c.Params("phone"))
+	if err != nil {
+		return errorResponse(c, dto.CodeInvalidPhone, "Invalid phone number")
+	}
+
+	if err := h.suppression.RemoveSuppression(c.UserContext(), phone); err != nil {
+		if errors.Is(err, service.ErrSuppressionNotFound) {
+			return errorResponse(c, dto.CodeSuppressionNotFound, "Suppression not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.SendStatus(204)
+}
+
+// inboundMessageHandler handles messages received from recipients
+// @Summary Inbound Message
+// @Description Called by the SMS provider for every received message. Stop keywords (STOP, UNSUBSCRIBE, ...) suppress the sender, start keywords (START, UNSTOP) lift a suppression they created. With callbacks.providers configured the request is authenticated by its provider signature instead of an API key.
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param message body dto.InboundMessageRequest true "Received message"
+// @Success 200 {object} dto.InboundMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/inbound [post]
+func (h *Handlers) inboundMessageHandler(c *fiber.Ctx) error {
+	var req dto.InboundMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.suppression.HandleInbound(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPhone) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// deliveryReportHandler handles delivery reports of sent messages
+// @Summary Delivery Report
+// @Description Called by the SMS provider when the state of an accepted message changes. The message is looked up by the message ID the webhook returned and moved to sent, delivered or failed (undelivered is the same as failed). Reports older than the current status, like sent after delivered, are ignored. With callbacks.providers configured the request is authenticated by its provider signature instead of an API key.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param report body dto.DeliveryReportRequest true "Delivery report"
+// @Success 200 {object} dto.DeliveryReportResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/delivery-reports [post]
+func (h *Handlers) deliveryReportHandler(c *fiber.Ctx) error {
+	var req dto.DeliveryReportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.deliveries.HandleDeliveryReport(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDeliveryReport) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// releaseMessageHandler handles releasing a quarantined message
+// @Summary Release Message
+// @Description Move a message quarantined by the content policy to pending so it is sent
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/release [post]
+func (h *Handlers) releaseMessageHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.ReleaseMessage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		if errors.Is(err, service.ErrNotQuarantined) {
+			return errorResponse(c, dto.CodeNotQuarantined, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// prioritizeMessageHandler handles moving a pending message to the front of the queue
+// @Summary Prioritize Message
+// @Description Raise the priority of a pending message above every other pending message and clear its scheduled time, so it is claimed next
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/prioritize [post]
+func (h *Handlers) prioritizeMessageHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.PrioritizeMessage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		if errors.Is(err, service.ErrNotPending) {
+			return errorResponse(c, dto.CodeNotPending, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// cancelMessageHandler handles cancelling a pending or sending message
+// @Summary Cancel Message
+// @Description Cancel a pending message, or a sending one whose webhook call was not issued yet, e.g. to stop a message sent by mistake. A cancelled message is never sent. Once the webhook call was issued the cancel fails with 409 send_already_issued and the message ends sent or failed as usual. A message sending on another instance fails with 409 send_not_local, only the instance that claimed it can cancel the send.
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/cancel [post]
+func (h *Handlers) cancelMessageHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.CancelMessage(c.UserContext(), c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMessageID):
+			return invalidRequest(c, err)
+		case errors.Is(err, service.ErrMessageNotFound):
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		case errors.Is(err, service.ErrNotCancellable):
+			return errorResponse(c, dto.CodeNotCancellable, err.Error())
+		case errors.Is(err, service.ErrSendIssued):
+			return errorResponse(c, dto.CodeSendIssued, err.Error())
+		case errors.Is(err, service.ErrSendNotLocal):
+			return errorResponse(c, dto.CodeSendNotLocal, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// triageMessageHandler handles annotating and acknowledging a failed message
+// @Summary Triage Message
+// @Description Set the operator note of a failed or dead-lettered message and acknowledge its failure, so the next on-call knows which failures were investigated already. The acknowledgment records the API key, acknowledging again keeps the first one and false withdraws it. It is cleared when the message fails again, the note is kept. List the open failures with status=failed&acknowledged=false.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Param request body dto.TriageMessageRequest true "Note and acknowledgment, fields left out are kept"
+// @Success 200 {object} dto.SingleMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/triage [patch]
+func (h *Handlers) triageMessageHandler(c *fiber.Ctx) error {
+	var req dto.TriageMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.messageService.TriageMessage(c.UserContext(), c.Params("id"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMessageID), errors.Is(err, service.ErrInvalidTriage):
+			return invalidRequest(c, err)
+		case errors.Is(err, service.ErrMessageNotFound):
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		case errors.Is(err, service.ErrNotFailed):
+			return errorResponse(c, dto.CodeNotFailed, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// messageEventsHandler handles listing why the scheduler skipped a message
+// @Summary Message Events
+// @Description Get why the scheduler skipped a claimed message instead of sending it, oldest first: suppressed recipient, failed suppression check, throttled, rate limited, failed route, stopped scheduler, cancelled send or expiry. Recorded with messaging.skip_events.
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.MessageEventsResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/events [get]
+func (h *Handlers) messageEventsHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.MessageEvents(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// sendAttemptsHandler handles listing the webhook requests of a message
+// @Summary Send Attempts
+// @Description Get every webhook request of the sends of a message with its provider, timings, status code and error, oldest first, e.g. to see that a message took 4 tries over 90 seconds. Recorded with messaging.send_attempts.
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.SendAttemptsResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/attempts [get]
+func (h *Handlers) sendAttemptsHandler(c *fiber.Ctx) error {
+	response, err := h.messageService.SendAttempts(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// replayMessagesHandler handles replaying the messages sent within a window
+// @Summary Replay Messages
+// @Description Clone the messages sent (accepted, sent, delivered, unconfirmed or accepted_without_id) within a window and enqueue the clones, e.g. after a delivery blackout of the provider. Run it with dry_run first and pass the matched count as expected_count, a different count is refused with 409. Windows longer than replay.max_window are refused with 400, more matches than replay.max_messages with 422. Messages replayed before are skipped.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param replay body dto.ReplayRequest true "Window and filters of the replay"
+// @Success 200 {object} dto.ReplayResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/replay [post]
+func (h *Handlers) replayMessagesHandler(c *fiber.Ctx) error {
+	var req dto.ReplayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.replays.Replay(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReplay) {
+			return invalidRequest(c, err)
+		}
+		for _, mapping := range []struct {
+			err  error
+			code string
+		}{
+			{service.ErrReplayCountMismatch, dto.CodeReplayCountMismatch},
+			{service.ErrReplayTooLarge, dto.CodeReplayTooLarge},
+		} {
+			if errors.Is(err, mapping.err) {
+				return errorResponse(c, mapping.code, err.Error())
+			}
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// submitIngestionHandler handles accepting a large payload of messages for asynchronous ingestion
+// @Summary Ingest Messages Asynchronously
+// @Description Accept a JSON array, or one JSON object per line, of up to ingestion.max_messages messages and answer right away with the ID of the ingestion job validating and enqueueing them in the background. Invalid and duplicate messages are rejected without stopping the job, messages violating a reject rule of the content policy too. A job fails when enqueueing fails, e.g. on an exceeded quota, the messages enqueued before stay enqueued. Follow the job with GET /api/v1/messages/async/{id}. Payloads over ingestion.max_messages are refused with 413, with every worker busy and ingestion.max_queued jobs waiting with 429.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param messages body []dto.CreateMessageRequest true "Messages to enqueue"
+// @Success 202 {object} dto.IngestionJobResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/async [post]
+func (h *Handlers) submitIngestionHandler(c *fiber.Ctx) error {
+	// the body is reused by fiber once the handler returned, the job reads it later
+	response, err := h.ingestion.Submit(c.UserContext(), bytes.Clone(c.Body()))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidIngestion) {
+			return invalidRequest(c, err)
+		}
+		for _, mapping := range []struct {
+			err  error
+			code string
+		}{
+			{service.ErrIngestionTooLarge, dto.CodePayloadTooLarge},
+			{service.ErrIngestionBusy, dto.CodeIngestionBusy},
+		} {
+			if errors.Is(err, mapping.err) {
+				return errorResponse(c, mapping.code, err.Error())
+			}
+		}
+		return handleError(c, err)
+	}
+
+	c.Location("/api/v1/messages/async/" + response.ID)
+	return c.Status(202).JSON(response)
+}
+
+// ingestionJobHandler handles getting an asynchronous ingestion job and its progress
+// @Summary Ingestion Job
+// @Description Get the state of an asynchronous ingestion job with the messages read, enqueued, duplicate and rejected so far and the first rejected rows
+// @Tags messages
+// @Produce json
+// @Param id path string true "Ingestion job ID"
+// @Success 200 {object} dto.IngestionJobResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/async/{id} [get]
+func (h *Handlers) ingestionJobHandler(c *fiber.Ctx) error {
+	response, err := h.ingestion.Job(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrIngestionJobNotFound) {
+			return errorResponse(c, dto.CodeIngestionJobNotFound, "Ingestion job not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// exportMessagesHandler handles accepting an export of messages as a background job
+// @Summary Export Messages
+// @Description Accept an export of the messages matching the filters to a CSV or JSON lines file and answer right away with the job writing it in the background. The messages are read page by page, jobs.export.page_size at a time and no faster than jobs.export.rows_per_second, so large exports do not compete with the scheduler for the database. Follow the job with GET /api/v1/jobs/{id} and download the file from its result_url once it succeeded. Exports matching more than jobs.export.max_messages messages are refused with 422, with every worker busy and jobs.max_queued jobs waiting with 429.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param export body dto.ExportRequest true "Format and filters of the export"
+// @Success 202 {object} dto.JobResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/export [post]
+func (h *Handlers) exportMessagesHandler(c *fiber.Ctx) error {
+	var req dto.ExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.jobs.SubmitExport(c.UserContext(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidExport):
+			return invalidRequest(c, err)
+		case errors.Is(err, service.ErrExportTooLarge):
+			return errorResponse(c, dto.CodeExportTooLarge, err.Error())
+		case errors.Is(err, service.ErrJobsBusy):
+			return errorResponse(c, dto.CodeJobsBusy, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	c.Location("/api/v1/jobs/" + response.ID)
+	return c.Status(202).JSON(response)
+}
+
+// jobHandler handles getting a background job and its progress
+// @Summary Job
+// @Description Get the state and progress of a background job, an export or an asynchronous ingestion, with the URL downloading its output once it succeeded. Finished jobs are kept for jobs.result_ttl.
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} dto.JobResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/jobs/{id} [get]
+func (h *Handlers) jobHandler(c *fiber.Ctx) error {
+	response, err := h.jobs.Job(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			return errorResponse(c, dto.CodeJobNotFound, "Job not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// jobResultHandler handles downloading the output of a succeeded background job
+// @Summary Job Result
+// @Description Download the output of a succeeded background job, e.g. the CSV or JSON lines file of an export, until the job expires
+// @Tags jobs
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param id path string true "Job ID"
+// @Success 200 {file} file
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/jobs/{id}/result [get]
+func (h *Handlers) jobResultHandler(c *fiber.Ctx) error {
+	result, err := h.jobs.Result(c.UserContext(), c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			return errorResponse(c, dto.CodeJobNotFound, "Job not found")
+		case errors.Is(err, service.ErrJobResultUnavailable):
+			return errorResponse(c, dto.CodeJobResultUnavailable, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, result.ContentType)
+	c.Attachment(result.JobID + exportExtension(result.ContentType))
+	return c.Send(result.Body)
+}
+
+// exportExtension returns the file extension of an output of contentType
+func exportExtension(contentType string) string {
+	if contentType == export.ContentType(export.FormatJSONL) {
+		return "." + export.FormatJSONL
+	}
+	return "." + export.FormatCSV
+}
+
+// createErasureHandler handles erasing the data of a phone number
+// @Summary Erase Recipient Data
+// @Description Delete or anonymize the messages to a phone number and delete the tracked links in them, e.g. for a right to be forgotten request. Anonymized messages keep their status, timestamps, campaign and cost without the number, content, provider responses and metadata, the pending ones are cancelled. The suppression of the number is kept unless include_suppression is set. An audit record identifying the number by its SHA-256 is stored with the erasure. Run it with dry_run to count the data first.
+// @Tags erasures
+// @Accept json
+// @Produce json
+// @Param erasure body dto.ErasureRequest true "Phone number and mode of the erasure"
+// @Success 200 {object} dto.ErasureResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/erasures [post]
+func (h *Handlers) createErasureHandler(c *fiber.Ctx) error {
+	var req dto.ErasureRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	requestedBy := "anonymous"
+	if keyID := c.Locals(apiKeyIDKey); keyID != nil {
+		requestedBy = fmt.Sprint(keyID)
+	}
+
+	response, err := h.erasures.Erase(c.UserContext(), &req, requestedBy)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidErasure) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// listErasuresHandler handles listing the erasure audit records
+// @Summary List Erasures
+// @Description Get a paginated list of the erasure audit records, newest first
+// @Tags erasures
+// @Produce json
+// @Param phone query string false "Only the records of this phone number"
+// @Param page query int false "Page number (default: 1)" minimum(1)
+// @Param page_size query int false "Page size (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} dto.ErasuresListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/erasures [get]
+func (h *Handlers) listErasuresHandler(c *fiber.Ctx) error {
+	response, err := h.erasures.ListErasures(c.UserContext(), c.Query("phone"), c.QueryInt("page", 1), c.QueryInt("page_size", 20))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPageSize) ||
+			errors.Is(err, service.ErrPageSizeTooLarge) ||
+			errors.Is(err, service.ErrPageSizeTooSmall) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// bulkStatusHandler handles changing the status of several messages at once
+// @Summary Bulk Status Change
+// @Description Cancel pending messages (action cancel) or move failed messages back to the queue (action requeue) in one transaction, at most 1000 IDs per request. Messages in another status are skipped, the results report updated, skipped or not_found per ID.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param request body dto.BulkStatusRequest true "Message IDs and action"
+// @Success 200 {object} dto.BulkStatusResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/status [patch]
+func (h *Handlers) bulkStatusHandler(c *fiber.Ctx) error {
+	var req dto.BulkStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.messageService.BulkUpdateStatus(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBulkStatus) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// validateMessageHandler handles validating a message without enqueueing it
+// @Summary Validate Message
+// @Description Validate a message like Create Message without enqueueing it, and return the encoding (gsm7 or ucs2) and the number of SMS segments it is sent and billed as
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param message body dto.CreateMessageRequest true "Message to validate"
+// @Success 200 {object} dto.ValidateMessageResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/validate [post]
+func (h *Handlers) validateMessageHandler(c *fiber.Ctx) error {
+	var req dto.CreateMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errorResponse(c, dto.CodeInvalidBody, "Invalid request body")
+	}
+
+	response, err := h.messageService.ValidateMessage(c.UserContext(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessage) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// Helper functions
+
+// linkRedirectHandler handles clicks on tracked short links
+// @Summary Follow Short Link
+// @Description Count a click on a tracked short link and redirect to its URL. Public, recipients open it from their messages.
+// @Tags links
+// @Param code path string true "Link code"
+// @Success 302
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /l/{code} [get]
+func (h *Handlers) linkRedirectHandler(c *fiber.Ctx) error {
+	target, err := h.links.Click(c.UserContext(), c.Params("code"))
+	if err != nil {
+		if errors.Is(err, service.ErrLinkNotFound) {
+			return errorResponse(c, dto.CodeLinkNotFound, "Link not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.Redirect(target, fiber.StatusFound)
+}
+
+// messageLinksHandler handles listing the tracked links of a message
+// @Summary Message Links
+// @Description Get the tracked short links of a message with their click counts
+// @Tags links
+// @Produce json
+// @Param id path string true "Message ID, or its ULID or UUID public ID"
+// @Success 200 {object} dto.MessageLinksResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/messages/{id}/links [get]
+func (h *Handlers) messageLinksHandler(c *fiber.Ctx) error {
+	response, err := h.links.MessageLinks(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessageID) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return errorResponse(c, dto.CodeMessageNotFound, "Message not found")
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// campaignClicksHandler handles the link click counts per campaign
+// @Summary Campaign Clicks
+// @Description Get the clicks on tracked short links per campaign, most clicked first. Messages without a campaign are counted under an empty campaign.
+// @Tags links
+// @Produce json
+// @Param campaign query string false "Only this campaign"
+// @Success 200 {object} dto.CampaignClicksResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/clicks [get]
+func (h *Handlers) campaignClicksHandler(c *fiber.Ctx) error {
+	response, err := h.links.CampaignClicks(c.UserContext(), c.Query("campaign"))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// costsHandler handles the cost report of sent messages
+// @Summary Cost Report
+// @Description Sum the cost of the messages sent in a window per UTC day, campaign or tenant. A message costs its segments times the unit price of the route it was sent through, captured when it was sent.
+// @Tags costs
+// @Produce json
+// @Param group_by query string false "Group by (default: day)" Enums(day, campaign, tenant)
+// @Param from query string false "Sent at or after (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Sent before (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} dto.CostReportResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/costs [get]
+func (h *Handlers) costsHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.costs.Costs(c.UserContext(), c.Query("group_by", string(db.CostGroupDay)), filter.From, filter.To)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCostGroup) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// deadLetterTopHandler handles the top groups of the dead-letter queue
+// @Summary Top Dead Letters
+// @Description Count the messages dead-lettered in a window by the error of their last failed send, their failure class, the leading digits of their recipient or their provider, most first, to find recurring root causes before requeueing them. Messages without a reason, class or provider are counted under an empty key.
+// @Tags dead-letters
+// @Produce json
+// @Param group_by query string false "Group by (default: reason)" Enums(reason, class, prefix, provider)
+// @Param limit query int false "Number of groups (default: 10, max: 100)" minimum(1) maximum(100)
+// @Param prefix_digits query int false "Digits of the recipient prefixes after the + (default: 3)" minimum(1) maximum(15)
+// @Param from query string false "Dead-lettered at or after (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Dead-lettered before (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} dto.DeadLetterTopResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/dead-letters/top [get]
+func (h *Handlers) deadLetterTopHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.deadLetters.Top(c.UserContext(), service.DeadLetterQuery{
+		GroupBy:      c.Query("group_by", string(db.DeadLetterGroupReason)),
+		Limit:        c.QueryInt("limit"),
+		PrefixDigits: c.QueryInt("prefix_digits"),
+		From:         filter.From,
+		To:           filter.To,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDeadLetterQuery) {
+			return invalidRequest(c, err)
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// deadLetterHeatmapHandler handles the dead-letter heatmap
+// @Summary Dead Letter Heatmap
+// @Description Count the messages dead-lettered in a window by UTC weekday and hour, failures piling up at the same hours point at batch jobs or provider maintenance windows.
+// @Tags dead-letters
+// @Produce json
+// @Param from query string false "Dead-lettered at or after (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Dead-lettered before (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} dto.DeadLetterHeatmapResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/dead-letters/heatmap [get]
+func (h *Handlers) deadLetterHeatmapHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.deadLetters.Heatmap(c.UserContext(), filter.From, filter.To)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// providerSLAHandler handles the SLA report of a provider
+// @Summary Provider SLA
+// @Description Report how a provider served the attempts sent to it in a window: the share of attempts that succeeded, the average and p50/p95/p99 latency, and the downtime windows of consecutive minutes in which every attempt failed. Without bounds the window is the last 30 days, without to it ends now and without from it starts 30 days before to.
+// @Tags providers
+// @Produce json
+// @Param name path string true "Provider name, webhook for webhook.url"
+// @Param from query string false "Attempts started at or after (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Attempts started before (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} dto.ProviderSLAResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/v1/providers/{name}/sla [get]
+func (h *Handlers) providerSLAHandler(c *fiber.Ctx) error {
+	filter, err := parseMessageFilter(c)
+	if err != nil {
+		return invalidRequest(c, err)
+	}
+
+	response, err := h.sla.ProviderSLA(c.UserContext(), c.Params("name"), filter.From, filter.To)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSLAWindow) {
+			return invalidRequest(c, err)
+		}
+		if errors.Is(err, service.ErrProviderNotFound) {
+			return errorResponse(c, dto.CodeProviderNotFound, err.Error())
+		}
+		return handleError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+func getCfg(c *fiber.Ctx) *config.Cfg {
+	return c.Locals("cfg").(*config.Cfg)
+}
+
+// parseMessageFilter builds a message filter from the status, from, to, tag, metadata.<key>, dead_lettered and
+// acknowledged query parameters
+func parseMessageFilter(c *fiber.Ctx) (db.MessageFilter, error) {
+	return query.Filters{
+		Status:       c.Query("status"),
+		From:         c.Query("from"),
+		To:           c.Query("to"),
+		Tag:          c.Query("tag"),
+		Metadata:     query.MetadataParams(c.Queries()),
+		DeadLettered: c.QueryBool("dead_lettered"),
+		Acknowledged: c.Query("acknowledged"),
+	}.MessageFilter()
+}
+
+// errorResponse responds with code and the HTTP status the error catalog has for it
+func errorResponse(c *fiber.Ctx, code, message string) error {
+	status := fiber.StatusInternalServerError
+	if entry, ok := dto.LookupErrorCode(code); ok {
+		status = entry.HTTPStatus
+	}
+	return c.Status(status).JSON(&dto.ErrorResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "error",
+			Timestamp: time.Now().UTC(),
+		},
+		Code:    code,
+		Message: message,
+	})
+}
+
+func badRequest(c *fiber.Ctx, message string) error {
+	return errorResponse(c, dto.CodeInvalidRequest, message)
+}
+
+// invalidCodes are the codes of the service validation errors more specific than invalid_request
+var invalidCodes = []struct {
+	err  error
+	code string
+}{
+	{service.ErrInvalidPageSize, dto.CodeInvalidPageSize},
+	{service.ErrInvalidMessageID, dto.CodeInvalidMessageID},
+	{service.ErrInvalidStatus, dto.CodeInvalidStatus},
+	{service.ErrInvalidPhone, dto.CodeInvalidPhone},
+	{service.ErrInvalidMessage, dto.CodeInvalidMessage},
+}
+
+// invalidRequest responds 400 with the message of a validation error and its code
+func invalidRequest(c *fiber.Ctx, err error) error {
+	for _, mapping := range invalidCodes {
+		if errors.Is(err, mapping.err) {
+			return errorResponse(c, mapping.code, err.Error())
+		}
+	}
+	return badRequest(c, err.Error())
+}
+
+// databaseRetryAfter is the Retry-After in seconds of the requests failing while the database is unreachable
+const databaseRetryAfter = "5"
+
+// handleError responds 503 to database connection errors so clients retry, 500 to the others
+func handleError(c *fiber.Ctx, err error) error {
+	if db.IsConnectionError(err) {
+		config.LogFrom(c.UserContext()).WithField("route", c.Route().Path).Warnf("Database unavailable: %v", err)
+		c.Set(fiber.HeaderRetryAfter, databaseRetryAfter)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeDatabaseUnavailable,
+			Message: "Database unavailable, retry later",
+			Error:   err.Error(),
+		})
+	}
+
+	config.LogFrom(c.UserContext()).WithField("route", c.Route().Path).Errorf("Handler error: %v", err)
+	telemetry.CaptureError(c.UserContext(), err, map[string]string{
+		"route":      c.Route().Path,
+		"request_id": fmt.Sprint(c.Locals(requestIDKey)),
+	})
 
 	return c.Status(500).JSON(&dto.ErrorResponse{
 		BaseResponse: dto.BaseResponse{
 			Status:    "error",
 			Timestamp: time.Now().UTC(),
 		},
+		Code:    dto.CodeInternal,
 		Message: "Internal server error",
 		Error:   err.Error(),
 	})
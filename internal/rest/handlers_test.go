@@ -2,76 +2,73 @@ package rest
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
-	"github.com/boratanrikulu/sendpulse/internal/dto"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
 	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/sendpulsetest"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-// MockMessage implements service interface for testing
-type MockMessage struct {
+type MockHealth struct {
 	mock.Mock
 }
 
-func (m *MockMessage) GetSentMessages(ctx context.Context, page, pageSize int) (*dto.MessagesListResponse, error) {
-	args := m.Called(ctx, page, pageSize)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*dto.MessagesListResponse), args.Error(1)
-}
-
-func (m *MockMessage) GetMessageByID(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
-	args := m.Called(ctx, id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
-}
-
-type MockScheduler struct {
-	mock.Mock
-}
-
-func (m *MockScheduler) Start(ctx context.Context) (*dto.MessagingControlResponse, error) {
+func (m *MockHealth) Ready(ctx context.Context) *dto.ReadinessResponse {
 	args := m.Called(ctx)
-	return args.Get(0).(*dto.MessagingControlResponse), args.Error(1)
+	return args.Get(0).(*dto.ReadinessResponse)
 }
 
-func (m *MockScheduler) Stop(ctx context.Context) (*dto.MessagingControlResponse, error) {
+func (m *MockHealth) Warmup(ctx context.Context) *dto.WarmupResponse {
 	args := m.Called(ctx)
-	return args.Get(0).(*dto.MessagingControlResponse), args.Error(1)
+	return args.Get(0).(*dto.WarmupResponse)
 }
 
-func (m *MockScheduler) GetStatus() *dto.MessagingStatusResponse {
-	args := m.Called()
-	return args.Get(0).(*dto.MessagingStatusResponse)
+func (m *MockHealth) DatabaseUp(ctx context.Context) bool {
+	args := m.Called(ctx)
+	return args.Bool(0)
 }
 
-func (m *MockScheduler) IsRunning() bool {
-	args := m.Called()
-	return args.Bool(0)
+func setupTestApp() (*fiber.App, *sendpulsetest.MockMessage, *sendpulsetest.MockScheduler) {
+	app, mockMessage, mockScheduler, _ := setupTestAppWithHealth()
+	return app, mockMessage, mockScheduler
 }
 
-func setupTestApp() (*fiber.App, *MockMessage, *MockScheduler) {
+func setupTestAppWithHealth() (*fiber.App, *sendpulsetest.MockMessage, *sendpulsetest.MockScheduler, *MockHealth) {
 	cfg := &config.Cfg{
 		AppName: "sendpulse",
 		Server: config.Server{
-			Mode: config.ModeDev,
+			Address:   ":8080",
+			Addresses: []string{"[::]:8080", ":8080"},
+			Mode:      config.ModeDev,
 		},
 	}
 
-	mockMessage := &MockMessage{}
-	mockScheduler := &MockScheduler{}
+	mockMessage := &sendpulsetest.MockMessage{}
+	mockScheduler := &sendpulsetest.MockScheduler{}
+	mockHealth := &MockHealth{}
 
-	handlers := NewHandlers(mockMessage, mockScheduler)
+	handlers := NewHandlers(Services{
+		Messages:  mockMessage,
+		Scheduler: mockScheduler,
+		Health:    mockHealth,
+		Runtime:   service.NewRuntimeService(cfg, nil),
+	})
+	handlers.region = service.NewRegionFailover(config.Region{Name: "eu-west", Role: config.RegionPassive})
 
 	app := fiber.New()
 	// Simulate middleware that sets config in locals
@@ -82,25 +79,217 @@ func setupTestApp() (*fiber.App, *MockMessage, *MockScheduler) {
 
 	api := app.Group("/api/v1")
 	api.Get("/health", handlers.healthHandler)
+	api.Get("/errors", handlers.errorCodesHandler)
+	api.Get("/schemas", handlers.schemasHandler)
+	api.Get("/schemas/:name", handlers.schemaHandler)
+	api.Get("/stats", handlers.statsHandler)
 	api.Post("/messaging/start", handlers.startMessagingHandler)
 	api.Post("/messaging/stop", handlers.stopMessagingHandler)
 	api.Get("/messaging/status", handlers.messagingStatusHandler)
 	api.Get("/messages", handlers.listMessagesHandler)
+	api.Post("/messages", handlers.createMessageHandler)
+	api.Post("/messages/validate", handlers.validateMessageHandler)
 	api.Get("/messages/:id", handlers.getMessageHandler)
-
-	return app, mockMessage, mockScheduler
+	api.Post("/messages/:id/release", handlers.releaseMessageHandler)
+	api.Post("/messages/:id/cancel", handlers.cancelMessageHandler)
+	api.Patch("/messages/:id/triage", handlers.triageMessageHandler)
+	api.Post("/admin/warmup", handlers.warmupHandler)
+	api.Get("/admin/region", handlers.regionHandler)
+	api.Post("/admin/region/promote", handlers.promoteRegionHandler)
+	api.Post("/admin/region/demote", handlers.demoteRegionHandler)
+	api.Get("/admin/runtime", handlers.runtimeHandler)
+	api.Get("/admin/routing", handlers.routingHandler)
+	app.Get("/readyz", handlers.readinessHandler)
+
+	return app, mockMessage, mockScheduler, mockHealth
 }
 
 func TestHandlers_Health(t *testing.T) {
+	t.Run("database up", func(t *testing.T) {
+		app, _, _, mockHealth := setupTestAppWithHealth()
+		mockHealth.On("DatabaseUp", mock.Anything).Return(true)
+
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var response dto.HealthResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "ok", response.Status)
+		assert.Equal(t, "up", response.Database)
+		assert.Equal(t, []string{":8080", "[::]:8080"}, response.Listen, "the configured addresses without duplicates")
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		app, _, _, mockHealth := setupTestAppWithHealth()
+		mockHealth.On("DatabaseUp", mock.Anything).Return(false)
+
+		req := httptest.NewRequest("GET", "/api/v1/health", nil)
+		resp, err := app.Test(req)
+
+		// Health endpoint should always work regardless of service state
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var response dto.HealthResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "degraded", response.Status)
+		assert.Equal(t, "down", response.Database)
+	})
+}
+
+func TestHandlers_Readiness(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		app, _, _, mockHealth := setupTestAppWithHealth()
+		mockHealth.On("Ready", mock.Anything).Return(&dto.ReadinessResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Checks:       map[string]string{"database": "ok"},
+		})
+
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		mockHealth.AssertExpectations(t)
+	})
+
+	t.Run("database unreachable", func(t *testing.T) {
+		app, _, _, mockHealth := setupTestAppWithHealth()
+		mockHealth.On("Ready", mock.Anything).Return(&dto.ReadinessResponse{
+			BaseResponse: dto.BaseResponse{Status: "error"},
+			Checks:       map[string]string{"database": "connection refused"},
+		})
+
+		req := httptest.NewRequest("GET", "/readyz", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 503, resp.StatusCode)
+		mockHealth.AssertExpectations(t)
+	})
+}
+
+func TestHandlers_Warmup(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		checks map[string]string
+		status int
+	}{
+		{"every step succeeds", map[string]string{"database": "ok", "provider:webhook": "ok"}, 200},
+		{"provider unreachable", map[string]string{"database": "ok", "provider:webhook": "connection refused"}, 503},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _, _, mockHealth := setupTestAppWithHealth()
+			status := "ok"
+			if tt.status != 200 {
+				status = "error"
+			}
+			mockHealth.On("Warmup", mock.Anything).Return(&dto.WarmupResponse{
+				BaseResponse: dto.BaseResponse{Status: status},
+				Checks:       tt.checks,
+			})
+
+			resp, err := app.Test(httptest.NewRequest("POST", "/api/v1/admin/warmup", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+
+			var response dto.WarmupResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			assert.Equal(t, tt.checks, response.Checks)
+		})
+	}
+}
+
+func TestHandlers_Region(t *testing.T) {
 	app, _, _ := setupTestApp()
+	region := func(method, path string) dto.RegionResponse {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+
+		var response dto.RegionResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return response
+	}
 
-	req := httptest.NewRequest("GET", "/api/v1/health", nil)
-	resp, err := app.Test(req)
+	response := region("GET", "/api/v1/admin/region")
+	assert.Equal(t, "eu-west", response.Region)
+	assert.Equal(t, config.RegionPassive, response.Role)
+	assert.Equal(t, "config", response.ChangedBy)
 
-	assert.NoError(t, err)
-	assert.Equal(t, 200, resp.StatusCode)
+	response = region("POST", "/api/v1/admin/region/promote")
+	assert.Equal(t, config.RegionActive, response.Role)
+	assert.Empty(t, response.ChangedBy, "the API key promoting the region, none without keys")
+	promotedAt := response.Since
+	assert.Equal(t, promotedAt, region("POST", "/api/v1/admin/region/promote").Since, "promoting an active region changes nothing")
+
+	assert.Equal(t, config.RegionPassive, region("POST", "/api/v1/admin/region/demote").Role)
+}
+
+func TestHandlers_Runtime(t *testing.T) {
+	app, _, _ := setupTestApp()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/admin/runtime", nil))
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var response dto.RuntimeResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, config.Version, response.Build.Version)
+	assert.Positive(t, response.Goroutines)
+	assert.Nil(t, response.Scheduler)
+	assert.Equal(t, ":8080", response.Config["server"].(map[string]any)["address"])
+}
+
+func TestHandlers_Routing(t *testing.T) {
+	app, _, _ := setupTestApp()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/admin/routing", nil))
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var response dto.RoutingResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	require.Len(t, response.Routes, 1, "only the default route is configured")
+	require.Len(t, response.Routes[0].Providers, 1)
+	assert.Equal(t, config.WebhookProvider, response.Routes[0].Providers[0].Provider)
+	assert.Equal(t, 1.0, response.Routes[0].Providers[0].Share)
+}
+
+func TestHandlers_Stats(t *testing.T) {
+	t.Run("successful response", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("Stats", mock.Anything).Return(&dto.StatsResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Counts:       map[string]int{"pending": 2, "sent": 3},
+			Total:        5,
+		}, nil)
 
-	// Health endpoint should always work regardless of service state
+		req := httptest.NewRequest("GET", "/api/v1/stats", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var response dto.StatsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, 5, response.Total)
+		assert.Equal(t, 2, response.Counts["pending"])
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("Stats", mock.Anything).Return(nil, errors.New("database connection failed"))
+
+		req := httptest.NewRequest("GET", "/api/v1/stats", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 500, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
 }
 
 func TestHandlers_ListMessages(t *testing.T) {
@@ -169,6 +358,86 @@ func TestHandlers_ListMessages(t *testing.T) {
 		assert.Equal(t, 400, resp.StatusCode)
 		mockMessage.AssertExpectations(t)
 	})
+
+	t.Run("filters list messages of any status", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		expectedResponse := &dto.MessagesListResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Messages:     []dto.MessageResponse{},
+			Page:         1,
+			PageSize:     20,
+		}
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		mockMessage.On("ListMessages", mock.Anything, db.MessageFilter{
+			Status: db.MessageStatusFailed,
+			From:   &from,
+		}, query.Page{Number: 1, Size: 20}).Return(expectedResponse, nil)
+
+		req := httptest.NewRequest("GET", "/api/v1/messages?status=failed&from=2024-01-01", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("invalid date filter", func(t *testing.T) {
+		app, _, _ := setupTestApp()
+
+		req := httptest.NewRequest("GET", "/api/v1/messages?from=yesterday", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("invalid status filter", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+
+		req := httptest.NewRequest("GET", "/api/v1/messages?status=unknown", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+		var errResp dto.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		assert.Equal(t, dto.CodeInvalidStatus, errResp.Code)
+		mockMessage.AssertNotCalled(t, "ListMessages", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("sort and cursor list messages of any status", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		expectedResponse := &dto.MessagesListResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Messages:     []dto.MessageResponse{},
+			PageSize:     20,
+			NextCursor:   "next",
+		}
+		page := query.Page{Number: 1, Size: 20, Sort: query.SortOldest, Cursor: "previous"}
+		mockMessage.On("ListMessages", mock.Anything, db.MessageFilter{}, page).Return(expectedResponse, nil)
+
+		req := httptest.NewRequest("GET", "/api/v1/messages?sort=created_at&cursor=previous", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		var response dto.MessagesListResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "next", response.NextCursor)
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("invalid sort", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("ListMessages", mock.Anything, db.MessageFilter{}, mock.Anything).Return(nil, query.ErrInvalidSort)
+
+		req := httptest.NewRequest("GET", "/api/v1/messages?sort=to", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
 }
 
 func TestHandlers_GetMessage(t *testing.T) {
@@ -204,6 +473,9 @@ func TestHandlers_GetMessage(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, 404, resp.StatusCode)
+		var body dto.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, dto.CodeMessageNotFound, body.Code)
 		mockMessage.AssertExpectations(t)
 	})
 
@@ -220,6 +492,19 @@ func TestHandlers_GetMessage(t *testing.T) {
 		mockMessage.AssertExpectations(t)
 	})
 
+	t.Run("database unreachable", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("GetMessageByID", mock.Anything, "123").Return(nil, fmt.Errorf("failed to get message: %w", syscall.ECONNREFUSED))
+
+		req := httptest.NewRequest("GET", "/api/v1/messages/123", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 503, resp.StatusCode)
+		assert.Equal(t, "5", resp.Header.Get("Retry-After"))
+		mockMessage.AssertExpectations(t)
+	})
+
 	t.Run("empty message ID", func(t *testing.T) {
 		app, _, _ := setupTestApp()
 		// Should handle missing ID parameter - test with malformed URL that won't match any route
@@ -232,6 +517,191 @@ func TestHandlers_GetMessage(t *testing.T) {
 	})
 }
 
+func TestHandlers_CancelMessage(t *testing.T) {
+	t.Run("cancelled", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("CancelMessage", mock.Anything, "123").Return(&dto.SingleMessageResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Message:      dto.MessageResponse{ID: 123, Status: "cancelled"},
+		}, nil)
+
+		resp, err := app.Test(httptest.NewRequest("POST", "/api/v1/messages/123/cancel", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	races := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"webhook call issued", service.ErrSendIssued, dto.CodeSendIssued},
+		{"sending on another instance", service.ErrSendNotLocal, dto.CodeSendNotLocal},
+		{"not cancellable", service.ErrNotCancellable, dto.CodeNotCancellable},
+	}
+	for _, tt := range races {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mockMessage, _ := setupTestApp()
+			mockMessage.On("CancelMessage", mock.Anything, "123").Return(nil, fmt.Errorf("message 123: %w", tt.err))
+
+			resp, err := app.Test(httptest.NewRequest("POST", "/api/v1/messages/123/cancel", nil))
+
+			assert.NoError(t, err)
+			assert.Equal(t, 409, resp.StatusCode)
+			var body dto.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Code)
+		})
+	}
+}
+
+func TestHandlers_TriageMessage(t *testing.T) {
+	triage := func(app *fiber.App, body string) *http.Response {
+		req := httptest.NewRequest("PATCH", "/api/v1/messages/123/triage", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("acknowledged", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		acknowledged := true
+		mockMessage.On("TriageMessage", mock.Anything, "123", &dto.TriageMessageRequest{Acknowledged: &acknowledged}).
+			Return(&dto.SingleMessageResponse{
+				BaseResponse: dto.BaseResponse{Status: "ok"},
+				Message:      dto.MessageResponse{ID: 123, Status: "failed", AcknowledgedBy: "oncall"},
+			}, nil)
+
+		resp := triage(app, `{"acknowledged": true}`)
+
+		assert.Equal(t, 200, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	errs := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not failed", service.ErrNotFailed, 409, dto.CodeNotFailed},
+		{"nothing to change", service.ErrInvalidTriage, 400, dto.CodeInvalidRequest},
+		{"not found", service.ErrMessageNotFound, 404, dto.CodeMessageNotFound},
+	}
+	for _, tt := range errs {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mockMessage, _ := setupTestApp()
+			mockMessage.On("TriageMessage", mock.Anything, "123", mock.Anything).Return(nil, fmt.Errorf("message 123: %w", tt.err))
+
+			resp := triage(app, `{"note": "investigating"}`)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			var body dto.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Code)
+		})
+	}
+}
+
+func TestHandlers_CreateMessage(t *testing.T) {
+	t.Run("successful response", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		expectedResponse := &dto.SingleMessageResponse{
+			BaseResponse: dto.BaseResponse{Status: "ok"},
+			Message: dto.MessageResponse{
+				ID:       1,
+				To:       "+905551111111",
+				Content:  "Test message",
+				Status:   "pending",
+				Priority: 5,
+			},
+		}
+
+		mockMessage.On("CreateMessage", mock.Anything, &dto.CreateMessageRequest{
+			To:       "+905551111111",
+			Content:  "Test message",
+			Priority: 5,
+		}).Return(expectedResponse, nil)
+
+		body := `{"to": "+905551111111", "content": "Test message", "priority": 5}`
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 201, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("CreateMessage", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidMessage)
+
+		body := `{"to": "invalid", "content": "Test message"}`
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		app, mockMessage, _ := setupTestApp()
+		mockMessage.On("CreateMessage", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: api_key \"reporting\" allows 10 messages per day", service.ErrQuotaExceeded))
+
+		body := `{"to": "+905551111111", "content": "Test message"}`
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 429, resp.StatusCode)
+		mockMessage.AssertExpectations(t)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		app, _, _ := setupTestApp()
+
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader("{"))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
+
+func TestHandlers_ValidateMessage(t *testing.T) {
+	app, mockMessage, _ := setupTestApp()
+	mockMessage.On("ValidateMessage", mock.Anything, &dto.CreateMessageRequest{To: "+905551111111", Content: "Test message"}).
+		Return(&dto.ValidateMessageResponse{BaseResponse: dto.BaseResponse{Status: "ok"}, Encoding: "gsm7", Segments: 1, Length: 12}, nil)
+	mockMessage.On("ValidateMessage", mock.Anything, &dto.CreateMessageRequest{To: "invalid", Content: "Test message"}).
+		Return(nil, service.ErrInvalidMessage)
+
+	req := httptest.NewRequest("POST", "/api/v1/messages/validate", strings.NewReader(`{"to": "+905551111111", "content": "Test message"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response dto.ValidateMessageResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, "gsm7", response.Encoding)
+	assert.Equal(t, 1, response.Segments)
+
+	req = httptest.NewRequest("POST", "/api/v1/messages/validate", strings.NewReader(`{"to": "invalid", "content": "Test message"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+	mockMessage.AssertExpectations(t)
+}
+
 func TestHandlers_MessagingControl(t *testing.T) {
 	t.Run("start messaging success", func(t *testing.T) {
 		app, _, mockScheduler := setupTestApp()
@@ -261,6 +731,7 @@ func TestHandlers_MessagingControl(t *testing.T) {
 				Status:    "error",
 				Timestamp: time.Now().UTC(),
 			},
+			Code:    dto.ControlAlreadyRunning,
 			Message: "Messaging service is already running",
 		}
 
@@ -270,10 +741,48 @@ func TestHandlers_MessagingControl(t *testing.T) {
 		resp, err := app.Test(req)
 
 		assert.NoError(t, err)
-		assert.Equal(t, 400, resp.StatusCode) // Should return 400 for error status
+		assert.Equal(t, 409, resp.StatusCode)
+		var response dto.MessagingControlResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, dto.ControlAlreadyRunning, response.Code)
+		mockScheduler.AssertExpectations(t)
+	})
+
+	t.Run("stop messaging not running idempotent", func(t *testing.T) {
+		app, _, mockScheduler := setupTestApp()
+		expectedResponse := &dto.MessagingControlResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.ControlNotRunning,
+			Message: "Messaging service is not running",
+		}
+
+		mockScheduler.On("Stop", mock.Anything).Return(expectedResponse, nil)
+
+		req := httptest.NewRequest("POST", "/api/v1/messaging/stop?idempotent=true", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		var response dto.MessagingControlResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		assert.Equal(t, "success", response.Status)
+		assert.Equal(t, dto.ControlNotRunning, response.Code)
 		mockScheduler.AssertExpectations(t)
 	})
 
+	t.Run("invalid idempotent", func(t *testing.T) {
+		app, _, _ := setupTestApp()
+
+		req := httptest.NewRequest("POST", "/api/v1/messaging/stop?idempotent=maybe", nil)
+		resp, err := app.Test(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
 	t.Run("stop messaging success", func(t *testing.T) {
 		app, _, mockScheduler := setupTestApp()
 		expectedResponse := &dto.MessagingControlResponse{
@@ -336,6 +845,67 @@ func TestHandlers_ErrorHandling(t *testing.T) {
 	})
 }
 
+func TestHandlers_ErrorCodes(t *testing.T) {
+	app, mockMessage, _ := setupTestApp()
+
+	req := httptest.NewRequest("GET", "/api/v1/errors", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response dto.ErrorCodesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	codes, names := map[string]bool{}, map[string]bool{}
+	for _, entry := range response.Errors {
+		assert.False(t, codes[entry.Code], "code %s is used twice", entry.Code)
+		assert.False(t, names[entry.Name], "name %s is used twice", entry.Name)
+		assert.NotEmpty(t, entry.Description, entry.Code)
+		assert.GreaterOrEqual(t, entry.HTTPStatus, 400, entry.Code)
+		codes[entry.Code], names[entry.Name] = true, true
+	}
+	assert.True(t, codes[dto.CodeMessageNotFound])
+
+	t.Run("validation errors", func(t *testing.T) {
+		mockMessage.On("GetSentMessages", mock.Anything, 1, -1).Return(nil, service.ErrInvalidPageSize)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/messages?page_size=-1", nil))
+		require.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+		var body dto.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, dto.CodeInvalidPageSize, body.Code)
+	})
+}
+
+func TestHandlers_Schemas(t *testing.T) {
+	app, _, _ := setupTestApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/schemas", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	var response dto.SchemasResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, "v1", response.Version)
+	assert.Contains(t, response.Schemas, dto.SchemaRef{Name: "CreateMessageRequest", URL: "/api/v1/schemas/CreateMessageRequest.json"})
+
+	for _, path := range []string{"/api/v1/schemas/CreateMessageRequest.json", "/api/v1/schemas/CreateMessageRequest"} {
+		resp, err = app.Test(httptest.NewRequest("GET", path, nil))
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode, path)
+		assert.Equal(t, "application/schema+json", resp.Header.Get("Content-Type"))
+		var schema map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schema))
+		assert.Equal(t, "CreateMessageRequest", schema["title"])
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/schemas/Message.json", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, dto.CodeSchemaNotFound, body.Code)
+}
+
 func TestHandlers_QueryParameterParsing(t *testing.T) {
 	t.Run("valid parameters parsed correctly", func(t *testing.T) {
 		app, mockMessage, _ := setupTestApp()
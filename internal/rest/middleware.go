@@ -0,0 +1,525 @@
+package rest
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// APIKeyHeader is the header carrying the API key, "Authorization: Bearer <key>" is accepted as well
+const APIKeyHeader = "X-API-Key"
+
+// requestIDKey is the locals key of the request ID set by the requestid middleware
+const requestIDKey = "requestid"
+
+// apiKeyIDKey is the locals key of the ID of the API key a request was authenticated with
+const apiKeyIDKey = "api_key_id"
+
+// apiKeyLocalsKey is the locals key of the config.APIKey a request was authenticated with
+const apiKeyLocalsKey = "api_key"
+
+// apiKeyAuth rejects requests without one of the configured API keys, no keys disable the check.
+// The name of the matching key is stored in the user context for the quotas.
+func apiKeyAuth(keys []config.APIKey) fiber.Handler {
+	keyIDs := make([]string, len(keys))
+	for i, key := range keys {
+		keyIDs[i] = apiKeyID(key.Key)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if len(keys) == 0 {
+			return c.Next()
+		}
+
+		provided := c.Get(APIKeyHeader)
+		if provided == "" {
+			provided, _ = strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		}
+		// every key is compared so the response time does not reveal which one matched
+		matched := -1
+		for i, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key.Key)) == 1 {
+				matched = i
+			}
+		}
+		if matched < 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(&dto.ErrorResponse{
+				BaseResponse: dto.BaseResponse{
+					Status:    "error",
+					Timestamp: time.Now().UTC(),
+				},
+				Code:    dto.CodeUnauthorized,
+				Message: "Invalid or missing API key",
+			})
+		}
+
+		c.Locals(apiKeyIDKey, keyIDs[matched])
+		c.Locals(apiKeyLocalsKey, keys[matched])
+		c.SetUserContext(quota.ContextWithAPIKey(c.UserContext(), keys[matched].Name))
+		return c.Next()
+	}
+}
+
+// requireScope rejects requests authenticated with an API key lacking scope with 403. Requests passed without
+// a key because none is configured are allowed.
+func requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := c.Locals(apiKeyLocalsKey).(config.APIKey)
+		if !ok || key.HasScope(scope) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeMissingScope,
+			Message: fmt.Sprintf("API key %q lacks the %s scope", key.Name, scope),
+		})
+	}
+}
+
+// Rate limit headers of the tightest quota of the API key a request was authenticated with
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	// RateLimitResetHeader is the number of seconds until the quota is available again
+	RateLimitResetHeader = "X-RateLimit-Reset"
+)
+
+// quotaHeaders adds the rate limit headers of the quota of the API key with the fewest messages remaining to
+// the response, after the handler so a message it created is counted. Keys without quotas get no headers and
+// a failed usage read only leaves them out.
+func quotaHeaders(usage service.UsageInterface) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		ctx := c.UserContext()
+		statuses, statusErr := usage.QuotaStatus(ctx)
+		if statusErr != nil {
+			config.LogFrom(ctx).WithError(statusErr).Warn("Failed to read the quota usage for the rate limit headers")
+			return err
+		}
+		if len(statuses) == 0 {
+			return err
+		}
+
+		tightest := statuses[0]
+		for _, status := range statuses[1:] {
+			if status.Remaining() < tightest.Remaining() ||
+				(status.Remaining() == tightest.Remaining() && status.Reset.Before(tightest.Reset)) {
+				tightest = status
+			}
+		}
+		reset := int64(math.Ceil(time.Until(tightest.Reset).Seconds()))
+		c.Set(RateLimitLimitHeader, strconv.FormatInt(tightest.Limit, 10))
+		c.Set(RateLimitRemainingHeader, strconv.FormatInt(tightest.Remaining(), 10))
+		c.Set(RateLimitResetHeader, strconv.FormatInt(max(reset, 0), 10))
+		return err
+	}
+}
+
+// apiKeyID identifies an API key in logs without revealing it
+func apiKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Default callback signature headers
+const (
+	SignatureHeader     = "X-Signature"
+	CallbackTokenHeader = "X-Callback-Token"
+	SignatureTimeHeader = "X-Timestamp"
+)
+
+// callbackProviderKey is the locals key of the provider a callback was verified for
+const callbackProviderKey = "callback_provider"
+
+// callbackAuth rejects provider callbacks without a valid signature or token of one of the providers.
+// Hmac signatures cover "<timestamp>.<body>", a timestamp outside the tolerance or a signature seen before
+// is rejected as a replay. Signatures are remembered per instance.
+func callbackAuth(cfg config.Callbacks) fiber.Handler {
+	seen := &signatureCache{seen: make(map[string]time.Time)}
+
+	return func(c *fiber.Ctx) error {
+		reason := "missing signature"
+		for _, provider := range cfg.Providers {
+			var err error
+			switch provider.Method {
+			case config.CallbackHMAC:
+				err = verifyHMAC(c, provider, cfg.Tolerance, seen)
+			case config.CallbackToken:
+				err = verifyToken(c, provider)
+			}
+			if err == nil {
+				c.Locals(callbackProviderKey, provider.Name)
+				return c.Next()
+			}
+			if !errors.Is(err, errNoSignature) {
+				reason = err.Error()
+			}
+		}
+
+		config.LogFrom(c.UserContext()).WithField("reason", reason).Warn("Rejected unsigned callback")
+		return c.Status(fiber.StatusUnauthorized).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeInvalidSignature,
+			Message: "Invalid callback signature",
+			Error:   reason,
+		})
+	}
+}
+
+// errNoSignature is returned when a request carries no signature of a provider
+var errNoSignature = errors.New("missing signature")
+
+func verifyHMAC(c *fiber.Ctx, provider config.CallbackProvider, tolerance time.Duration, seen *signatureCache) error {
+	signature := strings.TrimPrefix(c.Get(cmp.Or(provider.Header, SignatureHeader)), "sha256=")
+	if signature == "" {
+		return errNoSignature
+	}
+	timestamp := c.Get(cmp.Or(provider.TimestampHeader, SignatureTimeHeader))
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+
+	mac := hmac.New(sha256.New, []byte(provider.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(c.Body())
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return errors.New("signature mismatch")
+	}
+
+	signedAt := time.Unix(unix, 0)
+	if age := time.Since(signedAt); age > tolerance || age < -tolerance {
+		return errors.New("timestamp outside the tolerance")
+	}
+	if !seen.add(signature, signedAt.Add(tolerance)) {
+		return errors.New("replayed signature")
+	}
+	return nil
+}
+
+func verifyToken(c *fiber.Ctx, provider config.CallbackProvider) error {
+	token := c.Get(cmp.Or(provider.Header, CallbackTokenHeader))
+	if token == "" {
+		return errNoSignature
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(provider.Secret)) != 1 {
+		return errors.New("token mismatch")
+	}
+	return nil
+}
+
+// signatureCache remembers the accepted signatures until their timestamp left the tolerance
+type signatureCache struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// add returns false when signature was accepted before
+func (s *signatureCache) add(signature string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.pruned) > time.Minute {
+		for seen, expiry := range s.seen {
+			if now.After(expiry) {
+				delete(s.seen, seen)
+			}
+		}
+		s.pruned = now
+	}
+	if _, ok := s.seen[signature]; ok {
+		return false
+	}
+	s.seen[signature] = expiresAt
+	return true
+}
+
+// tracing starts a server span for every request, continuing the trace of an incoming traceparent header.
+// Handlers pass c.UserContext() to the services so their spans become children of the request span.
+func tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := telemetry.Tracer().Start(ctx, c.Method()+" "+c.Path(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		// the route is only known once the request went through the router
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", c.Response().StatusCode()),
+		)
+		if err != nil {
+			telemetry.RecordError(span, err)
+		} else if status := c.Response().StatusCode(); status >= fiber.StatusInternalServerError {
+			telemetry.RecordError(span, fmt.Errorf("status %d", status))
+		}
+		return err
+	}
+}
+
+// requestLogger stores a logger with the request ID, method, path and trace IDs in the user context,
+// handlers log through config.LogFrom(c.UserContext()). Must run after the requestid and tracing middlewares.
+func requestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		entry := config.Log().WithFields(telemetry.LogFields(ctx)).WithFields(logrus.Fields{
+			"request_id": c.Locals(requestIDKey),
+			"method":     c.Method(),
+			"path":       c.Path(),
+		})
+		c.SetUserContext(config.ContextWithLog(ctx, entry))
+		return c.Next()
+	}
+}
+
+// accessLog logs every finished request with its status, latency, response size and API key ID,
+// sampled per route. Server errors are always logged. Must run after the requestLogger middleware.
+func accessLog(cfg config.AccessLog) fiber.Handler {
+	rates := make(map[string]float64, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		rates[route.Path] = route.SampleRate
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !cfg.Enabled {
+			return c.Next()
+		}
+
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			// let the error handler write the response so the logged status is the one the client gets
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		rate, ok := rates[route]
+		if !ok {
+			rate = cfg.SampleRate
+		}
+		if status < fiber.StatusInternalServerError && (rate <= 0 || rand.Float64() >= rate) {
+			return nil
+		}
+
+		entry := config.LogFrom(c.UserContext()).WithFields(logrus.Fields{
+			"route":      route,
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      len(c.Response().Body()),
+			"ip":         c.IP(),
+		})
+		if keyID := c.Locals(apiKeyIDKey); keyID != nil {
+			entry = entry.WithField(apiKeyIDKey, keyID)
+		}
+
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			entry.Error("Request completed")
+		case status >= fiber.StatusBadRequest:
+			entry.Warn("Request completed")
+		default:
+			entry.Info("Request completed")
+		}
+		return nil
+	}
+}
+
+// requestTimeout cancels the user context of a request once the timeout of its route passed and answers it
+// with 504 instead of the response of the handler. Handlers pass the user context to the services, so their
+// database calls return when it is cancelled.
+func requestTimeout(cfg config.Timeouts) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timeout := cfg.For(c.Method(), c.Path())
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+
+		route := c.Route().Path
+		telemetry.RecordRequestTimeout(route)
+		config.LogFrom(ctx).WithField("route", route).Warnf("Request exceeded its %s timeout", timeout)
+		return c.Status(fiber.StatusGatewayTimeout).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeRequestTimeout,
+			Message: "Request timed out",
+			Error:   fmt.Sprintf("the request exceeded its %s timeout", timeout),
+		})
+	}
+}
+
+// readOnlyPath toggles the read-only mode, it is let through while the mode is enabled so it can be disabled
+const readOnlyPath = "/api/v1/admin/read-only"
+
+// warmupPath warms up the instance, it only reads and is let through while the server is read-only
+const warmupPath = "/api/v1/admin/warmup"
+
+// readOnly answers requests other than reads with 503 while the server is read-only: always when schema is set,
+// the database schema does not match the binary and writes could corrupt it, and while mode is enabled
+func readOnly(schema bool, mode *service.ReadOnlyMode) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if c.Path() == warmupPath {
+			return c.Next()
+		}
+		reason := "the database schema does not match this release, only reads are served"
+		if !schema {
+			if mode == nil || !mode.Enabled() || c.Path() == readOnlyPath {
+				return c.Next()
+			}
+			reason = "the read-only mode is enabled, only reads are served until it is disabled"
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeReadOnly,
+			Message: "Server is read-only",
+			Error:   reason,
+		})
+	}
+}
+
+// shedLoad answers the low-priority reads of its routes with 503 and a Retry-After header while shedder sheds
+// load, every request passes without a shedder
+func shedLoad(shedder *service.LoadShedder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if shedder == nil {
+			return c.Next()
+		}
+		shedding, reason := shedder.Shedding()
+		if !shedding {
+			return c.Next()
+		}
+
+		telemetry.RecordShedRequest(c.Route().Path)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(shedder.RetryAfter().Seconds())))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&dto.ErrorResponse{
+			BaseResponse: dto.BaseResponse{
+				Status:    "error",
+				Timestamp: time.Now().UTC(),
+			},
+			Code:    dto.CodeLoadShed,
+			Message: "Database under load, retry later",
+			Error:   reason,
+		})
+	}
+}
+
+// recoverer turns a handler panic into a 500 ErrorResponse instead of dropping the connection,
+// the panic is logged with its stack trace, counted and reported to Sentry
+func recoverer() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			reportPanic(c, recovered)
+
+			// the panic value may contain request data, it is only logged
+			err = c.Status(fiber.StatusInternalServerError).JSON(&dto.ErrorResponse{
+				BaseResponse: dto.BaseResponse{
+					Status:    "error",
+					Timestamp: time.Now().UTC(),
+				},
+				Code:    dto.CodeInternal,
+				Message: "Internal server error",
+			})
+		}()
+
+		return c.Next()
+	}
+}
+
+// reportPanic logs a recovered handler panic with its stack trace, counts it and reports it to Sentry
+func reportPanic(c *fiber.Ctx, recovered any) {
+	config.LogFrom(c.UserContext()).
+		WithField("route", c.Route().Path).
+		WithField("stack", string(debug.Stack())).
+		Errorf("Handler panic: %v", recovered)
+	telemetry.RecordPanic("http")
+	telemetry.CapturePanic(c.UserContext(), recovered, map[string]string{
+		"route":      c.Route().Path,
+		"request_id": fmt.Sprint(c.Locals(requestIDKey)),
+	})
+}
+
+// headerCarrier adapts the fiber request headers to the OpenTelemetry propagators
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,577 @@
+package rest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	newApp := func(key string) *fiber.App {
+		app := fiber.New()
+		app.Use(apiKeyAuth(config.Server{
+			APIKey:  key,
+			APIKeys: []config.APIKey{{Name: "reporting", Key: "reporting-secret"}},
+		}.Keys()))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString(quota.APIKeyFrom(c.UserContext()))
+		})
+		return app
+	}
+
+	tests := []struct {
+		name           string
+		key            string
+		headers        map[string]string
+		expectedStatus int
+		expectedKey    string
+	}{
+		{
+			name:           "missing key",
+			key:            "secret",
+			expectedStatus: 401,
+		},
+		{
+			name:           "wrong key",
+			key:            "secret",
+			headers:        map[string]string{APIKeyHeader: "wrong"},
+			expectedStatus: 401,
+		},
+		{
+			name:           "key header",
+			key:            "secret",
+			headers:        map[string]string{APIKeyHeader: "secret"},
+			expectedStatus: 200,
+			expectedKey:    config.DefaultAPIKeyName,
+		},
+		{
+			name:           "bearer token",
+			key:            "secret",
+			headers:        map[string]string{"Authorization": "Bearer secret"},
+			expectedStatus: 200,
+			expectedKey:    config.DefaultAPIKeyName,
+		},
+		{
+			name:           "named key",
+			key:            "secret",
+			headers:        map[string]string{APIKeyHeader: "reporting-secret"},
+			expectedStatus: 200,
+			expectedKey:    "reporting",
+		},
+		{
+			name:           "named key without server.api_key",
+			headers:        map[string]string{APIKeyHeader: "reporting-secret"},
+			expectedStatus: 200,
+			expectedKey:    "reporting",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := newApp(tt.key).Test(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus == 200 {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedKey, string(body))
+			}
+		})
+	}
+
+	t.Run("disabled without configured keys", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(apiKeyAuth(config.Server{}.Keys()))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	app := fiber.New()
+	app.Use(apiKeyAuth(config.Server{
+		APIKey: "secret",
+		APIKeys: []config.APIKey{
+			{Name: "reporting", Key: "reporting-secret", Scopes: []string{config.ScopeStatsRead, config.ScopeMessagesRead}},
+		},
+	}.Keys()))
+	app.Get("/stats", requireScope(config.ScopeStatsRead), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Post("/messaging/stop", requireScope(config.ScopeMessagingControl), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	request := func(method, path, key string) (int, string) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(APIKeyHeader, key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := request("GET", "/stats", "reporting-secret")
+	assert.Equal(t, 200, status)
+
+	status, body := request("POST", "/messaging/stop", "reporting-secret")
+	assert.Equal(t, 403, status)
+	assert.Contains(t, body, "lacks the messaging:control scope")
+
+	status, _ = request("POST", "/messaging/stop", "secret")
+	assert.Equal(t, 200, status, "a key without scopes may use every endpoint")
+
+	t.Run("allowed without configured keys", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(apiKeyAuth(config.Server{}.Keys()))
+		app.Post("/", requireScope(config.ScopeMessagingControl), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+}
+
+func TestCallbackAuth(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", callbackAuth(config.Callbacks{
+		Tolerance: time.Minute,
+		Providers: []config.CallbackProvider{
+			{Name: "acme", Method: config.CallbackHMAC, Secret: "acme-secret"},
+			{Name: "other", Method: config.CallbackToken, Secret: "other-token", Header: "X-Other-Token"},
+		},
+	}), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(callbackProviderKey).(string))
+	})
+
+	body := `{"message_id":"abc","status":"delivered"}`
+	sign := func(secret string, at time.Time) (string, string) {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "." + body))
+		return timestamp, "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	post := func(headers map[string]string) (int, string) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		response, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(response)
+	}
+
+	timestamp, signature := sign("acme-secret", time.Now())
+	status, provider := post(map[string]string{SignatureTimeHeader: timestamp, SignatureHeader: signature})
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "acme", provider)
+
+	status, response := post(map[string]string{SignatureTimeHeader: timestamp, SignatureHeader: signature})
+	assert.Equal(t, 401, status)
+	assert.Contains(t, response, "replayed signature")
+
+	timestamp, signature = sign("acme-secret", time.Now().Add(-2*time.Minute))
+	status, response = post(map[string]string{SignatureTimeHeader: timestamp, SignatureHeader: signature})
+	assert.Equal(t, 401, status)
+	assert.Contains(t, response, "timestamp outside the tolerance")
+
+	timestamp, signature = sign("wrong-secret", time.Now())
+	status, response = post(map[string]string{SignatureTimeHeader: timestamp, SignatureHeader: signature})
+	assert.Equal(t, 401, status)
+	assert.Contains(t, response, "signature mismatch")
+
+	status, provider = post(map[string]string{"X-Other-Token": "other-token"})
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "other", provider)
+
+	status, response = post(nil)
+	assert.Equal(t, 401, status)
+	assert.Contains(t, response, "missing signature")
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	app := fiber.New()
+	app.Use(tracing())
+	app.Get("/messages/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(500)
+	})
+
+	req := httptest.NewRequest("GET", "/messages/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /messages/:id", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestRequestLogger(t *testing.T) {
+	app := fiber.New()
+	app.Use(requestid.New(requestid.Config{ContextKey: requestIDKey}))
+	app.Use(requestLogger())
+
+	var fields logrus.Fields
+	app.Get("/", func(c *fiber.Ctx) error {
+		fields = config.LogFrom(c.UserContext()).Data
+		return c.SendString("ok")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-123")
+	resp, err := app.Test(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "req-123", resp.Header.Get(fiber.HeaderXRequestID))
+	assert.Equal(t, "req-123", fields["request_id"])
+	assert.Equal(t, "GET", fields["method"])
+	assert.Equal(t, "/", fields["path"])
+}
+
+func TestRecoverer(t *testing.T) {
+	app := fiber.New()
+	app.Use(recoverer())
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		panic("boom")
+	})
+
+	before := testutil.ToFloat64(telemetry.Panics.WithLabelValues("http"))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "error", body.Status)
+	assert.Equal(t, "Internal server error", body.Message)
+	assert.Empty(t, body.Error)
+	assert.Equal(t, before+1, testutil.ToFloat64(telemetry.Panics.WithLabelValues("http")))
+}
+
+func TestAccessLog(t *testing.T) {
+	hooks := config.Log().ReplaceHooks(make(logrus.LevelHooks))
+	defer config.Log().ReplaceHooks(hooks)
+	hook := logtest.NewLocal(config.Log())
+
+	app := fiber.New()
+	app.Use(requestid.New(requestid.Config{ContextKey: requestIDKey}))
+	app.Use(requestLogger())
+	app.Use(accessLog(config.AccessLog{
+		Enabled:    true,
+		SampleRate: 1,
+		Routes: []config.AccessLogRoute{
+			{Path: "/health", SampleRate: 0},
+		},
+	}))
+	app.Use(recoverer())
+	app.Use(apiKeyAuth(config.Server{APIKey: "secret"}.Keys()))
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		panic("boom")
+	})
+	app.Get("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("messages")
+	})
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "down")
+	})
+
+	send := func(path string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(APIKeyHeader, "secret")
+		req.Header.Set(fiber.HeaderXRequestID, "req-"+path)
+		_, err := app.Test(req)
+		require.NoError(t, err)
+	}
+
+	t.Run("logs request details", func(t *testing.T) {
+		hook.Reset()
+		send("/messages")
+
+		require.Len(t, hook.AllEntries(), 1)
+		entry := hook.LastEntry()
+		assert.Equal(t, logrus.InfoLevel, entry.Level)
+		assert.Equal(t, "req-/messages", entry.Data["request_id"])
+		assert.Equal(t, "/messages", entry.Data["route"])
+		assert.Equal(t, 200, entry.Data["status"])
+		assert.Equal(t, len("messages"), entry.Data["bytes"])
+		assert.Equal(t, apiKeyID("secret"), entry.Data[apiKeyIDKey])
+		assert.Contains(t, entry.Data, "latency_ms")
+	})
+
+	t.Run("excluded route", func(t *testing.T) {
+		hook.Reset()
+		send("/health")
+
+		assert.Empty(t, hook.AllEntries())
+	})
+
+	t.Run("error status from the error handler", func(t *testing.T) {
+		hook.Reset()
+		send("/fail")
+
+		require.Len(t, hook.AllEntries(), 1)
+		assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+		assert.Equal(t, 503, hook.LastEntry().Data["status"])
+	})
+
+	t.Run("panic recovered inside the access log", func(t *testing.T) {
+		hook.Reset()
+		send("/panic")
+
+		entries := hook.AllEntries()
+		require.Len(t, entries, 2)
+		assert.Equal(t, "req-/panic", entries[0].Data["request_id"], "the panic is logged with the request")
+		assert.Equal(t, "Request completed", entries[1].Message)
+		assert.Equal(t, 500, entries[1].Data["status"])
+	})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	app := fiber.New()
+	app.Use(requestTimeout(config.Timeouts{
+		Default: 20 * time.Millisecond,
+		Routes: []config.RouteTimeout{
+			{Path: "/reports/:name", Timeout: time.Second},
+			{Path: "/reports/slow", Method: "GET", Timeout: 0},
+		},
+	}))
+	// waits for the context like a slow database query and fails when it is cancelled
+	slow := func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			return c.Status(fiber.StatusInternalServerError).JSON(&dto.ErrorResponse{Message: "Failed to get report"})
+		case <-time.After(50 * time.Millisecond):
+			return c.SendString("report")
+		}
+	}
+	app.Get("/stats", slow)
+	app.Get("/reports/:name", slow)
+
+	send := func(path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	before := testutil.ToFloat64(telemetry.RequestTimeouts.WithLabelValues("/stats"))
+	resp := send("/stats")
+	assert.Equal(t, fiber.StatusGatewayTimeout, resp.StatusCode)
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "error", body.Status)
+	assert.Equal(t, "Request timed out", body.Message)
+	assert.Equal(t, "the request exceeded its 20ms timeout", body.Error)
+	assert.Equal(t, before+1, testutil.ToFloat64(telemetry.RequestTimeouts.WithLabelValues("/stats")))
+
+	assert.Equal(t, fiber.StatusOK, send("/reports/daily").StatusCode, "a route timeout overrides the default")
+	assert.Equal(t, fiber.StatusOK, send("/reports/slow").StatusCode, "the most specific route wins, 0 disables its timeout")
+}
+
+// fakeUsage returns statuses as the quotas of every API key
+type fakeUsage struct {
+	statuses []quota.Status
+	err      error
+}
+
+func (f *fakeUsage) GetUsage(context.Context) (*dto.UsageResponse, error) { return nil, f.err }
+
+func (f *fakeUsage) Limits(context.Context) (*dto.LimitsResponse, error) { return nil, f.err }
+
+func (f *fakeUsage) QuotaStatus(context.Context) ([]quota.Status, error) { return f.statuses, f.err }
+
+func TestQuotaHeaders(t *testing.T) {
+	newApp := func(usage *fakeUsage) *fiber.App {
+		app := fiber.New()
+		app.Use(quotaHeaders(usage))
+		app.Get("/messages", func(c *fiber.Ctx) error {
+			return c.SendString("messages")
+		})
+		return app
+	}
+	send := func(app *fiber.App) *http.Response {
+		resp, err := app.Test(httptest.NewRequest("GET", "/messages", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		return resp
+	}
+
+	t.Run("tightest quota", func(t *testing.T) {
+		now := time.Now()
+		resp := send(newApp(&fakeUsage{statuses: []quota.Status{
+			{Period: db.UsagePeriodDay, Limit: 100, Used: 90, Reset: now.Add(time.Hour)},
+			{Period: db.UsagePeriodMonth, Limit: 1000, Used: 995, Reset: now.Add(240 * time.Hour)},
+		}}))
+
+		assert.Equal(t, "1000", resp.Header.Get(RateLimitLimitHeader))
+		assert.Equal(t, "5", resp.Header.Get(RateLimitRemainingHeader))
+		reset, err := strconv.Atoi(resp.Header.Get(RateLimitResetHeader))
+		require.NoError(t, err)
+		assert.InDelta(t, 240*60*60, reset, 5)
+	})
+
+	t.Run("no quotas", func(t *testing.T) {
+		resp := send(newApp(&fakeUsage{}))
+		assert.Empty(t, resp.Header.Get(RateLimitLimitHeader))
+	})
+
+	t.Run("failed usage read", func(t *testing.T) {
+		resp := send(newApp(&fakeUsage{err: errors.New("database down")}))
+		assert.Empty(t, resp.Header.Get(RateLimitRemainingHeader), "the response is sent without the headers")
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	app := fiber.New()
+	app.Use(readOnly(true, service.NewReadOnlyMode(false)))
+	app.Get("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("messages")
+	})
+	app.Post("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("created")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/messages", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/messages", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Server is read-only", body.Message)
+}
+
+func TestReadOnly_Mode(t *testing.T) {
+	mode := service.NewReadOnlyMode(true)
+	app := fiber.New()
+	app.Use(readOnly(false, mode))
+	app.Get("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("messages")
+	})
+	app.Post("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("created")
+	})
+	app.Put(readOnlyPath, func(c *fiber.Ctx) error {
+		return c.SendString("toggled")
+	})
+	app.Post(warmupPath, func(c *fiber.Ctx) error {
+		return c.SendString("warmed up")
+	})
+
+	status := func(method, path string) int {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, fiber.StatusOK, status("GET", "/messages"))
+	assert.Equal(t, fiber.StatusServiceUnavailable, status("POST", "/messages"))
+	assert.Equal(t, fiber.StatusOK, status("PUT", readOnlyPath), "the mode can be disabled while it is enabled")
+	assert.Equal(t, fiber.StatusOK, status("POST", warmupPath), "the warmup only reads")
+
+	assert.True(t, mode.Set(false, "ops"))
+	assert.Equal(t, fiber.StatusOK, status("POST", "/messages"))
+	enabled, since, changedBy := mode.Status()
+	assert.False(t, enabled)
+	assert.False(t, since.IsZero())
+	assert.Equal(t, "ops", changedBy)
+	assert.False(t, mode.Set(false, "ops"), "disabling a disabled mode changes nothing")
+}
+
+func TestShedLoad(t *testing.T) {
+	shedder := service.NewLoadShedder(nil, config.LoadShedding{Latency: 500 * time.Millisecond, RetryAfter: 30 * time.Second})
+	app := fiber.New()
+	app.Get("/stats", shedLoad(shedder), func(c *fiber.Ctx) error {
+		return c.SendString("stats")
+	})
+	app.Post("/messages", func(c *fiber.Ctx) error {
+		return c.SendString("created")
+	})
+	app.Get("/usage", shedLoad(nil), func(c *fiber.Ctx) error {
+		return c.SendString("usage")
+	})
+	send := func(method, path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, fiber.StatusOK, send("GET", "/stats").StatusCode)
+
+	shedder.Observe(time.Second, sql.DBStats{})
+	before := testutil.ToFloat64(telemetry.ShedRequests.WithLabelValues("/stats"))
+	resp := send("GET", "/stats")
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, dto.CodeLoadShed, body.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(telemetry.ShedRequests.WithLabelValues("/stats")))
+
+	assert.Equal(t, fiber.StatusOK, send("POST", "/messages").StatusCode, "message creation is never shed")
+	assert.Equal(t, fiber.StatusOK, send("GET", "/usage").StatusCode, "nothing is shed without a shedder")
+
+	shedder.Observe(10*time.Millisecond, sql.DBStats{})
+	assert.Equal(t, fiber.StatusOK, send("GET", "/stats").StatusCode, "requests are served once the load recovers")
+}
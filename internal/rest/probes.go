@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"context"
+	"net"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// ProbeServer serves the Prometheus metrics and the readiness probe of a process running without the REST API,
+// like `sendpulse worker`, so it is scraped and probed like the API pods
+type ProbeServer struct {
+	address string
+	app     *fiber.App
+}
+
+// NewProbeServer creates a ProbeServer listening on address
+func NewProbeServer(address string, health service.HealthInterface) *ProbeServer {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(recoverer())
+
+	handlers := &Handlers{health: health}
+	app.Get("/readyz", handlers.readinessHandler)
+	app.Get("/metrics", adaptor.HTTPHandler(telemetry.MetricsHandler()))
+
+	return &ProbeServer{address: address, app: app}
+}
+
+// Start binds the address and serves in the background until ctx is cancelled, a taken address fails the start
+func (s *ProbeServer) Start(ctx context.Context) error {
+	ln, err := listen([]string{s.address})
+	if err != nil {
+		return err
+	}
+	config.Log().Infof("Serving metrics and probes on %s", ln[0].Addr())
+
+	go func() {
+		<-ctx.Done()
+		if err := s.app.Shutdown(); err != nil {
+			config.Log().Errorf("Probe server shutdown error: %v", err)
+		}
+	}()
+	go func(ln net.Listener) {
+		if err := s.app.Listener(ln); err != nil {
+			config.Log().Errorf("Probe server error: %v", err)
+		}
+	}(ln[0])
+	return nil
+}
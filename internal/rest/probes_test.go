@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeServer(t *testing.T) {
+	health := new(MockHealth)
+	health.On("Ready", mock.Anything).Return(&dto.ReadinessResponse{
+		BaseResponse: dto.BaseResponse{Status: "error"},
+		Checks:       map[string]string{"database": "connection refused"},
+	})
+	server := NewProbeServer("127.0.0.1:0", health)
+
+	resp, err := server.app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+
+	resp, err = server.app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "go_goroutines")
+
+	resp, err = server.app.Test(httptest.NewRequest("GET", "/api/v1/messages", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode, "the REST API is not served")
+	health.AssertExpectations(t)
+}
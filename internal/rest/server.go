@@ -2,15 +2,19 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
+	"net"
+	"strings"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
 
 	"github.com/arsmn/fiber-swagger/v2"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 // Server is public rest api service of sendpulse
@@ -18,34 +22,79 @@ type Server struct {
 	Cfg      *config.Cfg
 	handlers *Handlers
 	app      *fiber.App
+	readOnly bool
+	// readOnlyMode is toggled by operators, unlike readOnly
+	readOnlyMode *service.ReadOnlyMode
+	// region is the role of the region of the instance, promoted and demoted by operators
+	region *service.RegionFailover
+	// shedder sheds the stats and export requests while the database is under load, nil never sheds
+	shedder *service.LoadShedder
 }
 
-// NewServer creates a new Server.
-func NewServer(cfg *config.Cfg, messageService *service.MessageService, scheduler *service.Scheduler) *Server {
+// NewServer creates a new Server serving the API with services.
+func NewServer(cfg *config.Cfg, services Services) *Server {
 	return &Server{
 		Cfg:      cfg,
-		handlers: NewHandlers(messageService, scheduler),
+		handlers: NewHandlers(services),
 	}
 }
 
-// Start runs the rest service.
+// SetReadOnly rejects every /api/v1 request but reads with 503, for a database schema this binary does not match
+func (s *Server) SetReadOnly() {
+	s.readOnly = true
+}
+
+// SetReadOnlyMode sets the read-only mode operators toggle, shared with the scheduler so messaging pauses
+// along. Without one the mode starts with server.read_only. Must be called before Start.
+func (s *Server) SetReadOnlyMode(mode *service.ReadOnlyMode) {
+	s.readOnlyMode = mode
+}
+
+// SetRegionFailover sets the role of the region operators promote and demote, shared with the scheduler so it
+// only claims messages while the region is active. Without one the region keeps region.role. Must be called before
+// Start.
+func (s *Server) SetRegionFailover(failover *service.RegionFailover) {
+	s.region = failover
+}
+
+// SetLoadShedder sets the load shedder answering the stats and export requests with 503 while the database is
+// under load. Must be called before Start.
+func (s *Server) SetLoadShedder(shedder *service.LoadShedder) {
+	s.shedder = shedder
+}
+
+// Start runs the rest service on every address of server.address and server.addresses.
 func (s *Server) Start(ctx context.Context) error {
+	// every address is bound before serving, so a taken port fails the start instead of serving on the others
+	listeners, err := listen(s.Cfg.Server.ListenAddresses())
+	if err != nil {
+		return err
+	}
+
 	s.app = fiber.New(fiber.Config{
 		AppName: fmt.Sprintf("%s (mode: %s)", s.Cfg.AppName, s.Cfg.Server.Mode),
+		// the payloads of the async ingestion are far larger than the default 4 MB
+		BodyLimit: max(s.Cfg.Ingestion.MaxBodySize, fiber.DefaultBodyLimit),
+		// the banners of several listeners would interleave, the addresses are logged below instead
+		DisableStartupMessage: len(listeners) > 1,
 	})
-	s.app.Use(logger.New(
-		logger.Config{
-			TimeZone:   time.UTC.String(),
-			TimeFormat: time.RFC3339,
-		},
-	))
 	s.app.Use("/", func(c *fiber.Ctx) error {
 		c.Locals("cfg", s.Cfg)
 		return c.Next()
 	})
+	s.app.Use(requestid.New(requestid.Config{ContextKey: requestIDKey}))
+	s.app.Use(tracing())
+	s.app.Use(requestLogger())
+	s.app.Use(accessLog(s.Cfg.Server.AccessLog))
+	// inside the access log, so requests that panic are logged with the 500 they are answered with
+	s.app.Use(recoverer())
 	s.applyRouting()
 
-	config.Log().Infof("Starting SendPulse server on %s", s.Cfg.Server.Address)
+	addresses := make([]string, len(listeners))
+	for i, ln := range listeners {
+		addresses[i] = ln.Addr().String()
+	}
+	config.Log().Infof("Starting SendPulse server on %s", strings.Join(addresses, ", "))
 
 	// Handle graceful shutdown
 	go func() {
@@ -57,23 +106,179 @@ func (s *Server) Start(ctx context.Context) error {
 	}()
 
 	config.Log().Info("SendPulse server started successfully")
-	return s.app.Listen(s.Cfg.Server.Address)
+	return s.serve(listeners)
+}
+
+// listen binds every address, closing the bound ones when one fails
+func listen(addresses []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		ln, err := net.Listen(config.ListenNetwork(address), address)
+		if err != nil {
+			for _, bound := range listeners {
+				bound.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// serve serves the app on every listener until it is shut down. A listener failing shuts the app down, so the
+// server never keeps running on part of its addresses.
+func (s *Server) serve(listeners []net.Listener) error {
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func() {
+			errs <- s.app.Listener(ln)
+		}()
+	}
+
+	var err error
+	for range listeners {
+		if serveErr := <-errs; serveErr != nil && err == nil {
+			err = serveErr
+			if shutdownErr := s.app.Shutdown(); shutdownErr != nil {
+				err = errors.Join(err, shutdownErr)
+			}
+		}
+	}
+	return err
 }
 
 func (s *Server) applyRouting() {
 	// Swagger documentation endpoint
 	s.app.Get("/swagger/*", swagger.HandlerDefault)
 
+	// Container probe endpoint
+	s.app.Get("/readyz", s.handlers.readinessHandler)
+
+	// Tracked short links are opened by recipients, they stay public
+	s.app.Get("/l/:code", s.handlers.linkRedirectHandler)
+
+	// Prometheus scrape endpoint
+	s.app.Get("/metrics", adaptor.HTTPHandler(telemetry.MetricsHandler()))
+
 	api := s.app.Group("/api/v1")
+	api.Use(requestTimeout(s.Cfg.Server.Timeouts))
+	if s.readOnlyMode == nil {
+		s.readOnlyMode = service.NewReadOnlyMode(s.Cfg.Server.ReadOnly)
+	}
+	api.Use(readOnly(s.readOnly, s.readOnlyMode))
+	s.handlers.readOnly, s.handlers.schemaReadOnly = s.readOnlyMode, s.readOnly
+	if s.region == nil {
+		s.region = service.NewRegionFailover(s.Cfg.Region)
+	}
+	s.handlers.region = s.region
 
+	// Health, the error catalog and the schemas stay public, they are registered before the API key check
 	api.Get("/health", s.handlers.healthHandler)
+	api.Get("/errors", s.handlers.errorCodesHandler)
+	api.Get("/schemas", s.handlers.schemasHandler)
+	api.Get("/schemas/:name", s.handlers.schemaHandler)
+
+	// Callbacks of providers with a configured secret are verified by their signature instead of an API key
+	if len(s.Cfg.Callbacks.Providers) > 0 {
+		verify := callbackAuth(s.Cfg.Callbacks)
+		api.Post("/delivery-reports", verify, s.handlers.deliveryReportHandler)
+		api.Post("/inbound", verify, s.handlers.inboundMessageHandler)
+	}
+	api.Use(apiKeyAuth(s.Cfg.Server.Keys()))
+	if s.Cfg.Server.RateLimitHeaders {
+		api.Use(quotaHeaders(s.handlers.usage))
+	}
+
+	// Quotas of the calling API key, open to every key so clients can throttle themselves
+	api.Get("/limits", s.handlers.limitsHandler)
+
+	// Stats and exports are shed while the database is under load, message creation and the scheduler keep it.
+	// The messaging status and pauses are left out, operators need them most during an incident.
+	statsRead, shed := requireScope(config.ScopeStatsRead), shedLoad(s.shedder)
+	api.Get("/stats", statsRead, shed, s.handlers.statsHandler)
+	api.Get("/usage", statsRead, shed, s.handlers.usageHandler)
+	api.Get("/costs", statsRead, shed, s.handlers.costsHandler)
 
 	// Messaging control endpoints
-	api.Post("/messaging/start", s.handlers.startMessagingHandler)
-	api.Post("/messaging/stop", s.handlers.stopMessagingHandler)
-	api.Get("/messaging/status", s.handlers.messagingStatusHandler)
+	control := requireScope(config.ScopeMessagingControl)
+	api.Post("/messaging/start", control, s.handlers.startMessagingHandler)
+	api.Post("/messaging/stop", control, s.handlers.stopMessagingHandler)
+	api.Get("/messaging/status", statsRead, s.handlers.messagingStatusHandler)
+	api.Get("/messaging/forecast", statsRead, shed, s.handlers.forecastHandler)
+	api.Get("/messaging/pauses", statsRead, s.handlers.listPausesHandler)
+	api.Post("/messaging/pauses", control, s.handlers.createPauseHandler)
+	api.Delete("/messaging/pauses/:prefix", control, s.handlers.deletePauseHandler)
+
+	// Campaign approval endpoints, approving takes its own scope so large blasts get a second pair of eyes
+	campaignsRead, campaignsWrite := requireScope(config.ScopeCampaignsRead), requireScope(config.ScopeCampaignsWrite)
+	campaignsApprove := requireScope(config.ScopeCampaignsApprove)
+	api.Get("/campaigns", campaignsRead, s.handlers.listCampaignsHandler)
+	api.Post("/campaigns", campaignsWrite, s.handlers.createCampaignHandler)
+	api.Get("/campaigns/:name", campaignsRead, s.handlers.getCampaignHandler)
+	api.Post("/campaigns/:name/submit", campaignsWrite, s.handlers.submitCampaignHandler)
+	api.Post("/campaigns/:name/launch", campaignsWrite, s.handlers.launchCampaignHandler)
+	api.Post("/campaigns/:name/pause", campaignsWrite, s.handlers.pauseCampaignHandler)
+	api.Post("/campaigns/:name/resume", campaignsWrite, s.handlers.resumeCampaignHandler)
+	api.Post("/campaigns/:name/approve", campaignsApprove, s.handlers.approveCampaignHandler)
+	api.Post("/campaigns/:name/reject", campaignsApprove, s.handlers.rejectCampaignHandler)
+
+	// Message endpoints
+	messagesRead, messagesWrite := requireScope(config.ScopeMessagesRead), requireScope(config.ScopeMessagesWrite)
+	api.Get("/messages", messagesRead, s.handlers.listMessagesHandler)
+	api.Post("/messages", messagesWrite, s.handlers.createMessageHandler)
+	api.Post("/messages/validate", messagesWrite, s.handlers.validateMessageHandler)
+	api.Post("/messages/replay", messagesWrite, s.handlers.replayMessagesHandler)
+	api.Post("/messages/async", messagesWrite, s.handlers.submitIngestionHandler)
+	api.Post("/messages/export", messagesRead, shed, s.handlers.exportMessagesHandler)
+	api.Get("/messages/async/:id", messagesRead, s.handlers.ingestionJobHandler)
+	api.Patch("/messages/status", messagesWrite, s.handlers.bulkStatusHandler)
+	api.Get("/messages/stats/timeseries", statsRead, shed, s.handlers.timeseriesHandler)
+	api.Get("/messages/duplicates", messagesRead, s.handlers.duplicatesHandler)
+	api.Get("/messages/:id", messagesRead, s.handlers.getMessageHandler)
+	api.Post("/messages/:id/release", messagesWrite, s.handlers.releaseMessageHandler)
+	api.Post("/messages/:id/prioritize", messagesWrite, s.handlers.prioritizeMessageHandler)
+	api.Post("/messages/:id/cancel", messagesWrite, s.handlers.cancelMessageHandler)
+	api.Patch("/messages/:id/triage", messagesWrite, s.handlers.triageMessageHandler)
+	api.Get("/messages/:id/links", messagesRead, s.handlers.messageLinksHandler)
+	api.Get("/messages/:id/events", messagesRead, s.handlers.messageEventsHandler)
+	api.Get("/messages/:id/attempts", messagesRead, s.handlers.sendAttemptsHandler)
+	api.Get("/clicks", statsRead, shed, s.handlers.campaignClicksHandler)
+	api.Get("/recipients/:phone/stats", messagesRead, s.handlers.recipientStatsHandler)
+	api.Get("/dead-letters/top", statsRead, shed, s.handlers.deadLetterTopHandler)
+	api.Get("/dead-letters/heatmap", statsRead, shed, s.handlers.deadLetterHeatmapHandler)
+	api.Get("/providers/:name/sla", statsRead, shed, s.handlers.providerSLAHandler)
+	api.Get("/jobs/:id", messagesRead, s.handlers.jobHandler)
+	api.Get("/jobs/:id/result", messagesRead, s.handlers.jobResultHandler)
+
+	// Delivery reports are posted by the SMS provider
+	callbacks := requireScope(config.ScopeCallbacks)
+	api.Post("/delivery-reports", callbacks, s.handlers.deliveryReportHandler)
+
+	// Suppression list endpoints, inbound messages are posted by the SMS provider
+	api.Get("/suppressions", requireScope(config.ScopeSuppressionsRead), s.handlers.listSuppressionsHandler)
+	api.Post("/suppressions", requireScope(config.ScopeSuppressionsWrite), s.handlers.createSuppressionHandler)
+	api.Post("/suppressions/import", requireScope(config.ScopeSuppressionsWrite), s.handlers.importSuppressionsHandler)
+	api.Delete("/suppressions/:phone", requireScope(config.ScopeSuppressionsWrite), s.handlers.deleteSuppressionHandler)
+	api.Post("/inbound", callbacks, s.handlers.inboundMessageHandler)
+
+	// Erasures of the data of a recipient, e.g. for right to be forgotten requests
+	api.Get("/erasures", requireScope(config.ScopeErasuresRead), s.handlers.listErasuresHandler)
+	api.Post("/erasures", requireScope(config.ScopeErasuresWrite), s.handlers.createErasureHandler)
+
+	// Webhooks of tenants and campaigns replacing webhook.url
+	api.Get("/webhooks/overrides", requireScope(config.ScopeWebhooksRead), s.handlers.listWebhookOverridesHandler)
+	api.Put("/webhooks/overrides/:scope/:name", requireScope(config.ScopeWebhooksWrite), s.handlers.putWebhookOverrideHandler)
+	api.Delete("/webhooks/overrides/:scope/:name", requireScope(config.ScopeWebhooksWrite), s.handlers.deleteWebhookOverrideHandler)
 
-	// Message history endpoints
-	api.Get("/messages", s.handlers.listMessagesHandler)
-	api.Get("/messages/:id", s.handlers.getMessageHandler)
+	// Admin endpoints
+	api.Get("/admin/egress", requireScope(config.ScopeAdminRead), s.handlers.egressHandler)
+	api.Get("/admin/jobs", requireScope(config.ScopeAdminRead), s.handlers.maintenanceJobsHandler)
+	api.Get("/admin/read-only", requireScope(config.ScopeAdminRead), s.handlers.readOnlyHandler)
+	api.Put("/admin/read-only", requireScope(config.ScopeAdminWrite), s.handlers.setReadOnlyHandler)
+	api.Post("/admin/warmup", requireScope(config.ScopeAdminWrite), s.handlers.warmupHandler)
+	api.Get("/admin/region", requireScope(config.ScopeAdminRead), s.handlers.regionHandler)
+	api.Post("/admin/region/promote", requireScope(config.ScopeAdminWrite), s.handlers.promoteRegionHandler)
+	api.Post("/admin/region/demote", requireScope(config.ScopeAdminWrite), s.handlers.demoteRegionHandler)
+	api.Get("/admin/runtime", requireScope(config.ScopeAdminRead), s.handlers.runtimeHandler)
+	api.Get("/admin/routing", requireScope(config.ScopeAdminRead), s.handlers.routingHandler)
 }
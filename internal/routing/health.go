@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+)
+
+// healthBuckets is the number of buckets the window of the health scores rolls by
+const healthBuckets = 10
+
+// ProviderHealth is the health of a provider over the window of its score
+type ProviderHealth struct {
+	Provider string
+	// Score is between routing.health.min_score and 1, it is 1 while the provider has too few sends to score
+	Score float64
+	// Sends and Failures are the sends within the window, only transient failures count
+	Sends    int
+	Failures int
+	// Latency is the average duration of the sends within the window
+	Latency time.Duration
+}
+
+// Health keeps the rolling health scores of the providers, from the success rate and latency of their sends
+type Health struct {
+	cfg    config.ProviderHealth
+	bucket time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	providers map[string]*[healthBuckets]healthBucket
+}
+
+// healthBucket holds the sends of a provider that started within a bucket of the window
+type healthBucket struct {
+	start    time.Time
+	sends    int
+	failures int
+	latency  time.Duration
+}
+
+// NewHealth returns the health of providers scored by cfg
+func NewHealth(cfg config.ProviderHealth) *Health {
+	return &Health{
+		cfg:       cfg,
+		bucket:    max(cfg.Window/healthBuckets, time.Millisecond),
+		now:       time.Now,
+		providers: make(map[string]*[healthBuckets]healthBucket),
+	}
+}
+
+// Observe records a send of provider that took latency, failed reports a transient failure. Permanent failures
+// are the message's fault, not the provider's, they are observed as successful sends.
+func (h *Health) Observe(provider string, latency time.Duration, failed bool) {
+	now := h.now()
+	start := now.Truncate(h.bucket)
+
+	h.mu.Lock()
+	buckets, ok := h.providers[provider]
+	if !ok {
+		buckets = new([healthBuckets]healthBucket)
+		h.providers[provider] = buckets
+	}
+	bucket := &buckets[start.UnixNano()/int64(h.bucket)%healthBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = healthBucket{start: start}
+	}
+	bucket.sends++
+	bucket.latency += latency
+	if failed {
+		bucket.failures++
+	}
+	health := h.health(provider, buckets, now)
+	h.mu.Unlock()
+
+	telemetry.SetProviderHealthScore(provider, health.Score)
+}
+
+// Score returns the score of provider
+func (h *Health) Score(provider string) float64 {
+	return h.Provider(provider).Score
+}
+
+// Provider returns the health of provider
+func (h *Health) Provider(provider string) ProviderHealth {
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health(provider, h.providers[provider], now)
+}
+
+// health sums the buckets of provider within the window. Must be called with mu held.
+func (h *Health) health(provider string, buckets *[healthBuckets]healthBucket, now time.Time) ProviderHealth {
+	health := ProviderHealth{Provider: provider, Score: 1}
+	if buckets == nil {
+		return health
+	}
+
+	var latency time.Duration
+	since := now.Add(-h.cfg.Window)
+	for _, bucket := range buckets {
+		if bucket.sends == 0 || !bucket.start.After(since) {
+			continue
+		}
+		health.Sends += bucket.sends
+		health.Failures += bucket.failures
+		latency += bucket.latency
+	}
+	if health.Sends == 0 {
+		return health
+	}
+	health.Latency = latency / time.Duration(health.Sends)
+	if health.Sends < h.cfg.MinSamples {
+		return health
+	}
+
+	score := float64(health.Sends-health.Failures) / float64(health.Sends)
+	if health.Latency > h.cfg.LatencyTarget {
+		score *= float64(h.cfg.LatencyTarget) / float64(health.Latency)
+	}
+	health.Score = max(score, h.cfg.MinScore)
+	return health
+}
@@ -0,0 +1,265 @@
+// Package routing picks the provider, sender ID and rate limit messages are sent with by their recipient's country prefix,
+// splits the messages of routes with several providers by their weight and health score, and throttles campaigns and
+// tenants by their throttle profile.
+package routing
+
+import (
+	"cmp"
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+)
+
+// Route is a resolved route of the routing config
+type Route struct {
+	// Prefix is the recipient prefix of the route, empty for the default route
+	Prefix string
+	// Provider is the name of the provider, config.WebhookProvider for webhook.url
+	Provider string
+	// URL is the webhook of the provider, empty for config.SandboxProvider
+	URL      string
+	SenderID string
+	// UnitPrice is the price of a segment sent through the route
+	UnitPrice float64
+	// Credentials authenticate the requests to URL, they are only set by the webhook overrides of tenants
+	// and campaigns
+	Credentials webhook.Credentials
+	// Override is the webhook override of a tenant or campaign replacing URL, its sends do not count towards
+	// the health of Provider
+	Override string
+
+	limiter *limiter
+}
+
+// Wait blocks until the rate limit of the route allows the next message, it returns right away when the
+// route is unlimited. It returns the error of ctx when ctx is done first.
+func (r *Route) Wait(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+	return r.limiter.wait(ctx)
+}
+
+// Interval returns the time between the sends of the route, 0 when it is unlimited
+func (r *Route) Interval() time.Duration {
+	if r.limiter == nil {
+		return 0
+	}
+	return r.limiter.interval
+}
+
+// Router resolves the route of a recipient. Rate limits and health scores are per router, so every scheduler
+// limits its own sends and scores the providers by its own sends.
+type Router struct {
+	// routes are sorted by descending prefix length so the longest matching prefix wins
+	routes   []*weightedRoute
+	fallback *weightedRoute
+	health   *Health
+	// random picks the provider of routes with several providers, it returns a number in [0, 1)
+	random func() float64
+}
+
+// weightedRoute is a route of the config resolved for each of its providers, the providers share the rate
+// limit of the route
+type weightedRoute struct {
+	prefix    string
+	providers []*Route
+	weights   []float64
+	decisions []atomic.Int64
+}
+
+// RouteStatus is a route and the shares of its providers
+type RouteStatus struct {
+	// Prefix is empty for the default route
+	Prefix    string
+	Providers []RouteProvider
+}
+
+// RouteProvider is a provider of a route, its health and the messages routed to it
+type RouteProvider struct {
+	ProviderHealth
+	Weight float64
+	// Share is the fraction of the messages of the route routed to the provider now, its weight times its score
+	// over the sum of the route
+	Share float64
+	// Decisions is the number of messages routed to the provider through the route by the router
+	Decisions int64
+}
+
+// New resolves the routes of cfg. Routes to unknown providers are rejected when the config is loaded,
+// they would fall back to webhook.url here.
+func New(cfg *config.Cfg) *Router {
+	urls := map[string]string{config.WebhookProvider: cfg.Webhook.URL, config.SandboxProvider: ""}
+	for _, provider := range cfg.Routing.Providers {
+		urls[provider.Name] = provider.URL
+	}
+
+	resolve := func(route config.Route) *weightedRoute {
+		var limit *limiter
+		if route.RateLimit > 0 {
+			limit = &limiter{interval: time.Duration(float64(time.Second) / route.RateLimit)}
+		}
+		providers := route.Providers
+		if len(providers) == 0 {
+			providers = []config.WeightedProvider{{Name: route.Provider, Weight: 1}}
+		}
+
+		resolved := &weightedRoute{prefix: route.Prefix, decisions: make([]atomic.Int64, len(providers))}
+		for _, weighted := range providers {
+			provider := weighted.Name
+			if _, ok := urls[provider]; !ok {
+				provider = config.WebhookProvider
+			}
+			resolved.providers = append(resolved.providers, &Route{Prefix: route.Prefix, Provider: provider, URL: urls[provider], SenderID: route.SenderID, UnitPrice: route.UnitPrice, limiter: limit})
+			resolved.weights = append(resolved.weights, weighted.Weight)
+		}
+		return resolved
+	}
+
+	router := &Router{
+		fallback: resolve(cfg.Routing.Default),
+		health:   NewHealth(cfg.Routing.Health),
+		random:   rand.Float64,
+	}
+	for _, route := range cfg.Routing.Routes {
+		router.routes = append(router.routes, resolve(route))
+	}
+	slices.SortStableFunc(router.routes, func(a, b *weightedRoute) int {
+		return cmp.Compare(len(b.prefix), len(a.prefix))
+	})
+	return router
+}
+
+// Route returns the route of the recipient to. Routes with several providers pick one at random, proportionally
+// to its weight times its health score.
+func (r *Router) Route(to string) *Route {
+	route := r.fallback
+	for _, candidate := range r.routes {
+		if strings.HasPrefix(to, candidate.prefix) {
+			route = candidate
+			break
+		}
+	}
+
+	picked := 0
+	if len(route.providers) > 1 {
+		shares := r.shares(route)
+		pick := r.random()
+		for picked < len(shares)-1 && pick >= shares[picked] {
+			pick -= shares[picked]
+			picked++
+		}
+	}
+	route.decisions[picked].Add(1)
+	return route.providers[picked]
+}
+
+// Observe records a send through route that took latency towards the health score of its provider, failed
+// reports a transient failure. Sends to webhook overrides are not observed.
+func (r *Router) Observe(route *Route, latency time.Duration, failed bool) {
+	if route.Override != "" {
+		return
+	}
+	r.health.Observe(route.Provider, latency, failed)
+}
+
+// Status returns every route, the default route last, with the health and shares of its providers
+func (r *Router) Status() []RouteStatus {
+	statuses := make([]RouteStatus, 0, len(r.routes)+1)
+	for _, route := range append(slices.Clone(r.routes), r.fallback) {
+		status := RouteStatus{Prefix: route.prefix}
+		for i, share := range r.shares(route) {
+			status.Providers = append(status.Providers, RouteProvider{
+				ProviderHealth: r.health.Provider(route.providers[i].Provider),
+				Weight:         route.weights[i],
+				Share:          share,
+				Decisions:      route.decisions[i].Load(),
+			})
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// shares returns the fraction of the messages of route each of its providers gets by weight and health score
+func (r *Router) shares(route *weightedRoute) []float64 {
+	shares := make([]float64, len(route.providers))
+	if len(shares) == 1 {
+		shares[0] = 1
+		return shares
+	}
+
+	var total float64
+	for i, provider := range route.providers {
+		shares[i] = route.weights[i] * r.health.Score(provider.Provider)
+		total += shares[i]
+	}
+	// every provider scoring 0 is only possible without a min_score, the weights decide then
+	if total == 0 {
+		copy(shares, route.weights)
+		for _, weight := range route.weights {
+			total += weight
+		}
+	}
+	for i := range shares {
+		shares[i] /= total
+	}
+	return shares
+}
+
+// limiter spaces sends evenly at interval, there is no burst
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// reserve takes the next slot when it starts within the given duration from now and returns its start,
+// otherwise it returns the start of the next slot without taking it
+func (l *limiter) reserve(now time.Time, within time.Duration) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	if at.Sub(now) > within {
+		return at, false
+	}
+	l.next = at.Add(l.interval)
+	return at, true
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Route(t *testing.T) {
+	router := New(&config.Cfg{
+		Webhook: config.Webhook{URL: "https://webhook.example.com"},
+		Routing: config.Routing{
+			Providers: []config.Provider{
+				{Name: "provider-a", URL: "https://a.example.com"},
+				{Name: "provider-b", URL: "https://b.example.com"},
+			},
+			Routes: []config.Route{
+				{Prefix: "+4", Provider: "provider-a"},
+				{Prefix: "+49", Provider: "provider-b", SenderID: "ACME-DE"},
+				{Prefix: "+90", SenderID: "ACME"},
+			},
+			Default: config.Route{Provider: "provider-a"},
+		},
+	})
+
+	tests := []struct {
+		to       string
+		provider string
+		url      string
+		senderID string
+	}{
+		{"+491511111111", "provider-b", "https://b.example.com", "ACME-DE"},
+		{"+441111111111", "provider-a", "https://a.example.com", ""},
+		{"+905551111111", config.WebhookProvider, "https://webhook.example.com", "ACME"},
+		{"+15551111111", "provider-a", "https://a.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			route := router.Route(tt.to)
+			assert.Equal(t, tt.provider, route.Provider)
+			assert.Equal(t, tt.url, route.URL)
+			assert.Equal(t, tt.senderID, route.SenderID)
+		})
+	}
+}
+
+func TestRoute_Wait(t *testing.T) {
+	router := New(&config.Cfg{Routing: config.Routing{
+		Routes: []config.Route{{Prefix: "+90", RateLimit: 20}},
+	}})
+	ctx := context.Background()
+
+	t.Run("unlimited routes do not wait", func(t *testing.T) {
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, router.Route("+15551111111").Wait(ctx))
+		}
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("limited routes space sends", func(t *testing.T) {
+		route := router.Route("+905551111111")
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, route.Wait(ctx))
+		}
+		// the first send goes out right away, the next two wait 50ms each
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("cancelled wait", func(t *testing.T) {
+		route := router.Route("+905551111111")
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.NoError(t, route.Wait(ctx))
+		assert.ErrorIs(t, route.Wait(cancelled), context.Canceled)
+	})
+}
+
+func TestRouter_WeightedRoute(t *testing.T) {
+	router := New(&config.Cfg{
+		Webhook: config.Webhook{URL: "https://webhook.example.com"},
+		Routing: config.Routing{
+			Providers: []config.Provider{
+				{Name: "provider-a", URL: "https://a.example.com"},
+				{Name: "provider-b", URL: "https://b.example.com"},
+			},
+			Routes: []config.Route{{Prefix: "+90", SenderID: "ACME", RateLimit: 10, Providers: []config.WeightedProvider{
+				{Name: "provider-a", Weight: 3},
+				{Name: "provider-b", Weight: 1},
+			}}},
+			Health: config.ProviderHealth{Window: time.Minute, LatencyTarget: time.Second, MinSamples: 4, MinScore: 0.1},
+		},
+	})
+	pick := 0.0
+	router.random = func() float64 { return pick }
+
+	route := func(random float64) *Route {
+		pick = random
+		return router.Route("+905551111111")
+	}
+	a, b := route(0.7), route(0.8)
+	assert.Equal(t, "provider-a", a.Provider)
+	assert.Equal(t, "https://a.example.com", a.URL)
+	assert.Equal(t, "ACME", a.SenderID)
+	assert.Equal(t, "provider-b", b.Provider, "provider-a gets 3 of every 4 messages")
+	assert.Equal(t, "https://b.example.com", b.URL)
+	assert.Same(t, a.limiter, b.limiter, "the providers share the rate limit of the route")
+
+	for range 4 {
+		router.Observe(a, 100*time.Millisecond, true)
+		router.Observe(&Route{Provider: "provider-b", Override: "tenant:acme"}, time.Millisecond, true)
+	}
+	assert.Equal(t, "provider-b", route(0.7).Provider, "a failing provider loses its share")
+	assert.Equal(t, "provider-a", route(0.2).Provider, "a failing provider keeps a trickle of messages")
+
+	statuses := router.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "", statuses[1].Prefix, "the default route is last")
+	providers := statuses[0].Providers
+	require.Len(t, providers, 2)
+	assert.InDelta(t, 0.1, providers[0].Score, 0.001)
+	assert.InDelta(t, 0.3/1.3, providers[0].Share, 0.001)
+	assert.Equal(t, 4, providers[0].Failures)
+	assert.Equal(t, 100*time.Millisecond, providers[0].Latency)
+	assert.EqualValues(t, 2, providers[0].Decisions)
+	assert.InDelta(t, 1, providers[1].Score, 0.001, "sends to webhook overrides are not observed")
+	assert.Zero(t, providers[1].Sends)
+	assert.EqualValues(t, 2, providers[1].Decisions)
+}
+
+func TestHealth(t *testing.T) {
+	health := NewHealth(config.ProviderHealth{Window: time.Minute, LatencyTarget: time.Second, MinSamples: 4, MinScore: 0.1})
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	health.now = func() time.Time { return now }
+
+	for range 3 {
+		health.Observe("provider-a", 500*time.Millisecond, false)
+	}
+	assert.Equal(t, 1.0, health.Score("provider-a"), "too few sends to score")
+	health.Observe("provider-a", 500*time.Millisecond, true)
+	assert.InDelta(t, 0.75, health.Score("provider-a"), 0.001)
+
+	for range 4 {
+		health.Observe("provider-b", 4*time.Second, false)
+	}
+	assert.InDelta(t, 0.25, health.Score("provider-b"), 0.001, "slow sends lower the score")
+	assert.Equal(t, 4*time.Second, health.Provider("provider-b").Latency)
+
+	now = now.Add(30 * time.Second)
+	for range 60 {
+		health.Observe("provider-a", 500*time.Millisecond, true)
+	}
+	assert.InDelta(t, 0.1, health.Score("provider-a"), 0.001, "the score does not drop below min_score")
+
+	now = now.Add(45 * time.Second)
+	assert.Equal(t, 60, health.Provider("provider-a").Sends, "the sends older than the window are dropped")
+	now = now.Add(time.Minute)
+	assert.Equal(t, 1.0, health.Score("provider-a"), "a provider recovers once its failures leave the window")
+	assert.Equal(t, 1.0, health.Score("provider-c"), "providers without sends are healthy")
+}
+
+func TestThrottles(t *testing.T) {
+	throttles := NewThrottles(&config.Cfg{Throttling: config.Throttling{
+		Profiles: []config.ThrottleProfile{{Name: "gentle", RateLimit: 1}, {Name: "burst", RateLimit: 50}},
+		Assignments: []config.ThrottleAssignment{
+			{Campaign: "spring-sale", Profile: "burst"},
+			{Tenant: "acme", Profile: "burst"},
+		},
+		DefaultCampaignProfile: "gentle",
+	}})
+
+	tests := []struct {
+		name             string
+		tenant, campaign string
+		profile, key     string
+	}{
+		{"transactional", "", "", "", ""},
+		{"assigned campaign wins over its tenant", "acme", "spring-sale", "burst", "campaign:spring-sale"},
+		{"assigned tenant", "acme", "", "burst", "tenant:acme"},
+		{"default campaign profile", "globex", "newsletter", "gentle", "campaign:newsletter"},
+		{"unassigned tenant", "globex", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, key := throttles.Profile(tt.tenant, tt.campaign)
+			assert.Equal(t, tt.profile, profile)
+			assert.Equal(t, tt.key, key)
+		})
+	}
+
+	t.Run("reserve", func(t *testing.T) {
+		at, ok := throttles.Reserve("", "", 0)
+		assert.True(t, ok, "unthrottled messages are sent right away")
+		assert.True(t, at.IsZero())
+
+		_, ok = throttles.Reserve("", "newsletter", time.Second)
+		assert.True(t, ok)
+		next, ok := throttles.Reserve("", "newsletter", 100*time.Millisecond)
+		assert.False(t, ok, "the next gentle slot is a second away")
+		assert.WithinDuration(t, time.Now().Add(time.Second), next, 100*time.Millisecond)
+
+		_, ok = throttles.Reserve("", "promo", 0)
+		assert.True(t, ok, "every campaign is limited separately")
+	})
+}
@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+)
+
+// Throttles limits the send rate of campaigns and tenants by their throttle profile. Every campaign and tenant
+// has its own limiter at the rate of its profile, the limits are per Throttles like the route rate limits.
+type Throttles struct {
+	// rates are the intervals between the sends of a profile
+	rates     map[string]time.Duration
+	campaigns map[string]string
+	tenants   map[string]string
+	// defaultProfile throttles the campaigns without an assignment
+	defaultProfile string
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+// NewThrottles resolves the throttle profiles and assignments of cfg
+func NewThrottles(cfg *config.Cfg) *Throttles {
+	t := &Throttles{
+		rates:          make(map[string]time.Duration),
+		campaigns:      make(map[string]string),
+		tenants:        make(map[string]string),
+		defaultProfile: cfg.Throttling.DefaultCampaignProfile,
+		limiters:       make(map[string]*limiter),
+	}
+	for _, profile := range cfg.Throttling.Profiles {
+		if profile.RateLimit > 0 {
+			t.rates[profile.Name] = time.Duration(float64(time.Second) / profile.RateLimit)
+		}
+	}
+	for _, assignment := range cfg.Throttling.Assignments {
+		if assignment.Campaign != "" {
+			t.campaigns[assignment.Campaign] = assignment.Profile
+		} else if assignment.Tenant != "" {
+			t.tenants[assignment.Tenant] = assignment.Profile
+		}
+	}
+	return t
+}
+
+// Profile returns the profile throttling the messages of tenant and campaign and the key of their limiter,
+// an empty profile when they are not throttled
+func (t *Throttles) Profile(tenant, campaign string) (profile, key string) {
+	if campaign != "" {
+		if profile, ok := t.campaigns[campaign]; ok {
+			return profile, "campaign:" + campaign
+		}
+	}
+	if tenant != "" {
+		if profile, ok := t.tenants[tenant]; ok {
+			return profile, "tenant:" + tenant
+		}
+	}
+	if campaign != "" && t.defaultProfile != "" {
+		return t.defaultProfile, "campaign:" + campaign
+	}
+	return "", ""
+}
+
+// Interval returns the key of the limiter of tenant and campaign and the time between their sends, 0 when they
+// are not throttled
+func (t *Throttles) Interval(tenant, campaign string) (key string, interval time.Duration) {
+	profile, key := t.Profile(tenant, campaign)
+	interval, throttled := t.rates[profile]
+	if !throttled {
+		return "", 0
+	}
+	return key, interval
+}
+
+// Reserve reserves the next send slot of a message of tenant and campaign and returns when it starts, the zero
+// time when the message is not throttled. A slot starting later than within is not reserved, ok is false then
+// and at is when the next slot starts.
+func (t *Throttles) Reserve(tenant, campaign string, within time.Duration) (at time.Time, ok bool) {
+	profile, key := t.Profile(tenant, campaign)
+	interval, throttled := t.rates[profile]
+	if !throttled {
+		return time.Time{}, true
+	}
+
+	t.mu.Lock()
+	l, exists := t.limiters[key]
+	if !exists {
+		l = &limiter{interval: interval}
+		t.limiters[key] = l
+	}
+	t.mu.Unlock()
+
+	return l.reserve(time.Now(), within)
+}
@@ -0,0 +1,329 @@
+// Package schema generates the JSON Schemas of the request and response DTOs served at /api/v1/schemas, so
+// clients can generate code from them and validate payloads without the Swagger document. The schemas are
+// generated from the DTO types, they always describe the payloads of the running version.
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+const (
+	// Version is the API version the schemas describe, they change along with its DTOs
+	Version = "v1"
+	// Draft is the JSON Schema dialect of the schemas
+	Draft = "https://json-schema.org/draft/2020-12/schema"
+	// BasePath is where the schemas are served, the $id of a schema is its path
+	BasePath = "/api/" + Version + "/schemas/"
+)
+
+// dtos are the types with a schema, every struct of package dto
+var dtos = []any{
+	dto.CreateMessageRequest{},
+	dto.CreateSuppressionRequest{},
+	dto.InboundMessageRequest{},
+	dto.DeliveryReportRequest{},
+	dto.ReplayRequest{},
+	dto.BulkStatusRequest{},
+	dto.TriageMessageRequest{},
+	dto.ExportRequest{},
+	dto.ErasureRequest{},
+	dto.WebhookOverrideRequest{},
+	dto.RecipientPauseRequest{},
+	dto.CampaignRequest{},
+	dto.CampaignReviewRequest{},
+	dto.ReadOnlyRequest{},
+	dto.BaseResponse{},
+	dto.HealthResponse{},
+	dto.ReadinessResponse{},
+	dto.WarmupResponse{},
+	dto.MessageResponse{},
+	dto.MessagesListResponse{},
+	dto.SingleMessageResponse{},
+	dto.StatsResponse{},
+	dto.TimeseriesPoint{},
+	dto.TimeseriesResponse{},
+	dto.DuplicateMessage{},
+	dto.DuplicateGroup{},
+	dto.DuplicatesResponse{},
+	dto.SuppressionResponse{},
+	dto.SuppressionImportRejection{},
+	dto.SuppressionImportResponse{},
+	dto.SuppressionsListResponse{},
+	dto.SingleSuppressionResponse{},
+	dto.RecipientFailure{},
+	dto.RecipientStatsResponse{},
+	dto.WebhookOverrideResponse{},
+	dto.WebhookOverridesResponse{},
+	dto.SingleWebhookOverrideResponse{},
+	dto.RecipientPauseResponse{},
+	dto.RecipientPausesResponse{},
+	dto.SingleRecipientPauseResponse{},
+	dto.CampaignResponse{},
+	dto.CampaignCursor{},
+	dto.CampaignsResponse{},
+	dto.SingleCampaignResponse{},
+	dto.InboundMessageResponse{},
+	dto.ValidateMessageResponse{},
+	dto.DeliveryReportResponse{},
+	dto.UsageCounter{},
+	dto.UsageResponse{},
+	dto.QuotaLimit{},
+	dto.LimitsResponse{},
+	dto.EgressResponse{},
+	dto.MaintenanceJobResponse{},
+	dto.MaintenanceJobsResponse{},
+	dto.ReadOnlyResponse{},
+	dto.RegionResponse{},
+	dto.BuildInfo{},
+	dto.SchedulerRuntime{},
+	dto.RuntimeResponse{},
+	dto.RouteProvider{},
+	dto.RouteRouting{},
+	dto.RoutingResponse{},
+	dto.IngestionRejection{},
+	dto.IngestionJobResponse{},
+	dto.JobResponse{},
+	dto.MessagingControlResponse{},
+	dto.MessagingStatusResponse{},
+	dto.CampaignForecast{},
+	dto.ForecastResponse{},
+	dto.ErrorResponse{},
+	dto.ErrorCode{},
+	dto.ErrorCodesResponse{},
+	dto.SchemaRef{},
+	dto.SchemasResponse{},
+	dto.LinkResponse{},
+	dto.MessageLinksResponse{},
+	dto.MessageEventResponse{},
+	dto.MessageEventsResponse{},
+	dto.SendAttemptResponse{},
+	dto.SendAttemptsResponse{},
+	dto.CampaignClicks{},
+	dto.CampaignClicksResponse{},
+	dto.CostSummary{},
+	dto.CostReportResponse{},
+	dto.DeadLetterCount{},
+	dto.DeadLetterTopResponse{},
+	dto.DeadLetterHeatmapResponse{},
+	dto.DowntimeWindow{},
+	dto.ProviderSLAResponse{},
+	dto.ReplayResponse{},
+	dto.BulkStatusResult{},
+	dto.BulkStatusResponse{},
+	dto.ErasureRecord{},
+	dto.ErasureResponse{},
+	dto.ErasuresListResponse{},
+}
+
+var (
+	generateOnce sync.Once
+	schemas      map[string]json.RawMessage
+	names        []string
+)
+
+// Names returns the names of the schemas, the names of their DTO types, sorted
+func Names() []string {
+	generateOnce.Do(generateAll)
+	return names
+}
+
+// Get returns the schema of the DTO named name, false without one
+func Get(name string) (json.RawMessage, bool) {
+	generateOnce.Do(generateAll)
+	schema, ok := schemas[name]
+	return schema, ok
+}
+
+// Path returns where the schema of the DTO named name is served
+func Path(name string) string {
+	return BasePath + name + ".json"
+}
+
+func generateAll() {
+	schemas = make(map[string]json.RawMessage, len(dtos))
+	for _, value := range dtos {
+		t := reflect.TypeOf(value)
+		// the schemas are built from the types alone, they cannot fail to marshal
+		schemas[t.Name()], _ = json.Marshal(Generate(t))
+		names = append(names, t.Name())
+	}
+	slices.Sort(names)
+}
+
+// Generate returns the JSON Schema of the struct type t, the structs it refers to are bundled in $defs so the
+// schema validates without fetching others
+func Generate(t reflect.Type) map[string]any {
+	g := &generator{root: t, defs: make(map[string]any)}
+	schema := g.object(t)
+	schema["$schema"] = Draft
+	schema["$id"] = Path(t.Name())
+	schema["title"] = t.Name()
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type generator struct {
+	root reflect.Type
+	defs map[string]any
+}
+
+// object returns the schema of a struct. Fields without omitempty are always present in responses, requests
+// are validated by the API itself, their fields are not required by the schema.
+func (g *generator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	g.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 && !strings.HasSuffix(t.Name(), "Request") {
+		slices.Sort(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds the JSON fields of t to properties, the fields of embedded structs are inlined like encoding/json
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && deref(field.Type).Kind() == reflect.Struct {
+			g.fields(deref(field.Type), properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.schema(field.Type)
+		if field.Tag.Get("swaggertype") == "object" {
+			schema = map[string]any{"type": "object"}
+		}
+		if example, ok := parseExample(field.Tag.Get("example"), field.Type); ok {
+			schema["examples"] = []any{example}
+		}
+		omitempty := slices.Contains(strings.Split(options, ","), "omitempty")
+		if !omitempty {
+			*required = append(*required, name)
+			// nil pointers, slices and maps are null
+			switch field.Type.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map:
+				schema = nullable(schema)
+			}
+		}
+		properties[name] = schema
+	}
+}
+
+// schema returns the schema of the values of t
+func (g *generator) schema(t reflect.Type) map[string]any {
+	t = deref(t)
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == g.root {
+			return map[string]any{"$ref": "#"}
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			// set before the fields, so a struct referring to itself refers to the definition
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	// interfaces hold any value
+	return map[string]any{}
+}
+
+// nullable lets schema be null as well
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// parseExample returns the example tag of a field of type t as a value of the type, comma separated for slices
+func parseExample(example string, t reflect.Type) (any, bool) {
+	if example == "" {
+		return nil, false
+	}
+	t = deref(t)
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		var values []any
+		for _, item := range strings.Split(example, ",") {
+			value, ok := parseExample(item, t.Elem())
+			if !ok {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		return values, true
+	}
+
+	var value any
+	var err error
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err = strconv.ParseInt(example, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		value, err = strconv.ParseFloat(example, 64)
+	case reflect.Bool:
+		value, err = strconv.ParseBool(example)
+	case reflect.String, reflect.Struct:
+		value = example
+	default:
+		return nil, false
+	}
+	return value, err == nil
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("request", func(t *testing.T) {
+		schema := Generate(reflect.TypeOf(dto.CreateMessageRequest{}))
+		assert.Equal(t, Draft, schema["$schema"])
+		assert.Equal(t, "/api/v1/schemas/CreateMessageRequest.json", schema["$id"])
+		assert.NotContains(t, schema, "required", "requests are validated by the API")
+
+		properties := schema["properties"].(map[string]any)
+		assert.Equal(t, map[string]any{"type": "string"}, properties["to"])
+		assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, properties["send_at"])
+		assert.Equal(t, map[string]any{"type": "string", "examples": []any{"ACME"}}, properties["from"])
+		assert.Equal(t, map[string]any{"type": "object"}, properties["metadata"])
+	})
+
+	t.Run("response", func(t *testing.T) {
+		schema := Generate(reflect.TypeOf(dto.ErrorCodesResponse{}))
+		assert.Equal(t, []string{"errors", "status", "timestamp"}, schema["required"], "the base response is inlined")
+
+		properties := schema["properties"].(map[string]any)
+		assert.Equal(t, map[string]any{
+			"type":  []string{"array", "null"},
+			"items": map[string]any{"$ref": "#/$defs/ErrorCode"},
+		}, properties["errors"])
+
+		code := schema["$defs"].(map[string]any)["ErrorCode"].(map[string]any)
+		assert.Equal(t, map[string]any{"type": "integer", "examples": []any{int64(404)}},
+			code["properties"].(map[string]any)["http_status"])
+	})
+
+	t.Run("examples", func(t *testing.T) {
+		schema := Generate(reflect.TypeOf(dto.BulkStatusRequest{}))
+		assert.Equal(t, []any{[]any{int64(41), int64(42)}}, schema["properties"].(map[string]any)["ids"].(map[string]any)["examples"])
+	})
+}
+
+func TestGet(t *testing.T) {
+	raw, ok := Get("MessageResponse")
+	require.True(t, ok)
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(raw, &schema))
+	assert.Equal(t, "MessageResponse", schema["title"])
+
+	_, ok = Get("Message")
+	assert.False(t, ok)
+}
+
+func TestNames(t *testing.T) {
+	// every struct of package dto has a schema
+	files, err := parser.ParseDir(token.NewFileSet(), "../../pkg/dto", nil, 0)
+	require.NoError(t, err)
+
+	var structs []string
+	for _, file := range files["dto"].Files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					if spec := spec.(*ast.TypeSpec); spec.Name.IsExported() {
+						if _, ok := spec.Type.(*ast.StructType); ok {
+							structs = append(structs, spec.Name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	assert.ElementsMatch(t, structs, Names())
+}
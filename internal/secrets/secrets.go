@@ -0,0 +1,66 @@
+// Package secrets encrypts credentials stored in the database, e.g. of webhook overrides, and archived messages
+// with AES-256-GCM.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the size of the decoded encryption keys in bytes
+const KeySize = 32
+
+// ErrDecrypt is returned for sealed values that were not encrypted with the key or were altered
+var ErrDecrypt = errors.New("failed to decrypt secret, the encryption key may have changed")
+
+// Box encrypts and decrypts secrets with a single key
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a box from a base64 encoded 32 byte key, e.g. generated with `openssl rand -base64 32`
+func NewBox(key string) (*Box, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not base64: %w", err)
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext, the random nonce is prepended to the result
+func (b *Box) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a value sealed with the key of the box
+func (b *Box) Open(sealed []byte) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := b.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
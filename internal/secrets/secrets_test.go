@@ -0,0 +1,52 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", KeySize)))
+	box, err := NewBox(key)
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte(`{"token":"s3cret"}`))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "s3cret")
+
+	again, err := box.Seal([]byte(`{"token":"s3cret"}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every value gets its own nonce")
+
+	opened, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"s3cret"}`, string(opened))
+
+	t.Run("altered", func(t *testing.T) {
+		altered := append([]byte(nil), sealed...)
+		altered[len(altered)-1] ^= 1
+		_, err := box.Open(altered)
+		assert.True(t, errors.Is(err, ErrDecrypt))
+		_, err = box.Open(sealed[:4])
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other, err := NewBox(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", KeySize))))
+		require.NoError(t, err)
+		_, err = other.Open(sealed)
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		_, err := NewBox("not base64!")
+		assert.Error(t, err)
+		_, err = NewBox(base64.StdEncoding.EncodeToString([]byte("short")))
+		assert.Error(t, err)
+	})
+}
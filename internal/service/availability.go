@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/uptrace/bun"
+)
+
+// DatabaseAvailability tracks whether the database is reachable. Watch pings it periodically and
+// connection errors reported by its users mark it down right away. While it is down the scheduler does not
+// claim messages and the health endpoint reports the service degraded, both resume once a ping succeeds.
+type DatabaseAvailability struct {
+	db *bun.DB
+
+	mu    sync.RWMutex
+	up    bool
+	since time.Time
+	err   error
+}
+
+func NewDatabaseAvailability(database *bun.DB) *DatabaseAvailability {
+	telemetry.SetDatabaseUp(true)
+	return &DatabaseAvailability{
+		db:    database,
+		up:    true,
+		since: time.Now(),
+	}
+}
+
+// Up reports whether the database is reachable
+func (a *DatabaseAvailability) Up() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.up
+}
+
+// Status returns whether the database is reachable, since when, and the error that marked it down
+func (a *DatabaseAvailability) Status() (bool, time.Time, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.up, a.since, a.err
+}
+
+// Report marks the database down when err is a connection error and reports whether it was one
+func (a *DatabaseAvailability) Report(err error) bool {
+	if !db.IsConnectionError(err) {
+		return false
+	}
+	a.set(false, err)
+	return true
+}
+
+// Check pings the database and updates the state with the result
+func (a *DatabaseAvailability) Check(ctx context.Context) bool {
+	cctx, cancel := context.WithTimeout(ctx, ReadinessCheckTimeout)
+	defer cancel()
+
+	err := a.db.PingContext(cctx)
+	a.set(err == nil, err)
+	return err == nil
+}
+
+// Watch checks the database every interval until ctx is cancelled
+func (a *DatabaseAvailability) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Check(ctx)
+		}
+	}
+}
+
+func (a *DatabaseAvailability) set(up bool, err error) {
+	a.mu.Lock()
+	changed := a.up != up
+	if changed {
+		a.up = up
+		a.since = time.Now()
+	}
+	if !up {
+		a.err = err
+	} else {
+		a.err = nil
+	}
+	since := a.since
+	a.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	telemetry.SetDatabaseUp(up)
+	log := config.Log().WithField("component", "database")
+	if up {
+		log.Info("Database is reachable again, resuming")
+	} else {
+		log.WithError(err).WithField("since", since).Error("Database is unreachable, running degraded until it returns")
+	}
+}
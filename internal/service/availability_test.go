@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseAvailability(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	availability := NewDatabaseAvailability(testDB)
+	assert.True(t, availability.Up())
+
+	assert.False(t, availability.Report(errors.New("syntax error at or near \"FROM\"")), "query errors do not mark it down")
+	assert.True(t, availability.Up())
+
+	refused := fmt.Errorf("failed to claim message: %w", syscall.ECONNREFUSED)
+	assert.True(t, availability.Report(refused))
+	up, _, err := availability.Status()
+	assert.False(t, up)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+
+	health := NewHealthService(testDB)
+	health.SetAvailability(availability)
+	assert.False(t, health.DatabaseUp(context.Background()), "the tracked state is reported without a ping")
+
+	assert.True(t, availability.Check(context.Background()), "a successful ping resumes")
+	up, _, err = availability.Status()
+	assert.True(t, up)
+	assert.NoError(t, err)
+	assert.True(t, health.DatabaseUp(context.Background()))
+}
+
+// unreachableQueue fails every claim like a queue whose database went away
+type unreachableQueue struct {
+	fakeQueue
+	claims int
+}
+
+func (u *unreachableQueue) Claim(_ context.Context) (*db.Message, error) {
+	u.claims++
+	return nil, fmt.Errorf("failed to claim message: %w", syscall.ECONNREFUSED)
+}
+
+func TestScheduler_ProcessBatch_DatabaseDown(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{Messaging: config.Messaging{BatchSize: 5}}
+	q := &unreachableQueue{}
+	availability := NewDatabaseAvailability(testDB)
+
+	scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+	scheduler.SetAvailability(availability)
+	scheduler.processBatch(context.Background())
+
+	assert.Equal(t, 1, q.claims, "the batch stops at the first connection error")
+	assert.False(t, availability.Up())
+	assert.True(t, scheduler.GetStatus().Paused)
+
+	require.True(t, availability.Check(context.Background()))
+	assert.False(t, scheduler.GetStatus().Paused, "claiming resumes once the database is back")
+}
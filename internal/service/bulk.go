@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// Bulk status actions
+const (
+	// BulkActionCancel cancels pending messages
+	BulkActionCancel = "cancel"
+	// BulkActionRequeue moves failed messages back to the queue
+	BulkActionRequeue = "requeue"
+)
+
+// Bulk status results
+const (
+	BulkResultUpdated  = "updated"
+	BulkResultSkipped  = "skipped"
+	BulkResultNotFound = "not_found"
+)
+
+// MaxBulkStatusIDs bounds the messages changed by a single bulk status request
+const MaxBulkStatusIDs = 1000
+
+var ErrInvalidBulkStatus = errors.New("invalid bulk status request")
+
+// bulkTransitions are the from and to statuses of the bulk actions
+var bulkTransitions = map[string][2]db.MessageStatus{
+	BulkActionCancel:  {db.MessageStatusPending, db.MessageStatusCancelled},
+	BulkActionRequeue: {db.MessageStatusFailed, db.MessageStatusPending},
+}
+
+// BulkUpdateStatus applies the action of req to every message in one transaction. Messages that are not in
+// the status the action applies to are skipped, the results report the outcome per ID.
+func (s *MessageService) BulkUpdateStatus(ctx context.Context, req *dto.BulkStatusRequest) (*dto.BulkStatusResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.BulkUpdateStatus")
+	defer span.End()
+
+	transition, ok := bulkTransitions[req.Action]
+	if !ok {
+		return nil, fmt.Errorf("%w: action must be %s or %s, got %q", ErrInvalidBulkStatus, BulkActionCancel, BulkActionRequeue, req.Action)
+	}
+	if len(req.IDs) == 0 {
+		return nil, fmt.Errorf("%w: ids are required", ErrInvalidBulkStatus)
+	}
+	if len(req.IDs) > MaxBulkStatusIDs {
+		return nil, fmt.Errorf("%w: at most %d ids per request", ErrInvalidBulkStatus, MaxBulkStatusIDs)
+	}
+
+	response := &dto.BulkStatusResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Action:  req.Action,
+		Results: make([]dto.BulkStatusResult, len(req.IDs)),
+	}
+
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		messages, err := db.GetMessagesByIDs(ctx, tx, req.IDs)
+		if err != nil {
+			return err
+		}
+		moved, err := db.TransitionMessages(ctx, tx, req.IDs, transition[0], transition[1])
+		if err != nil {
+			return err
+		}
+
+		statuses := make(map[int64]db.MessageStatus, len(messages))
+		for _, message := range messages {
+			statuses[message.ID] = message.Status
+		}
+		for _, id := range moved {
+			statuses[id] = transition[1]
+		}
+		updated := make(map[int64]bool, len(moved))
+		for _, id := range moved {
+			updated[id] = true
+		}
+
+		for i, id := range req.IDs {
+			status, found := statuses[id]
+			switch {
+			case !found:
+				response.Results[i] = dto.BulkStatusResult{ID: id, Result: BulkResultNotFound}
+			case updated[id]:
+				response.Results[i] = dto.BulkStatusResult{ID: id, Result: BulkResultUpdated, Status: string(status)}
+			default:
+				response.Results[i] = dto.BulkStatusResult{ID: id, Result: BulkResultSkipped, Status: string(status)}
+			}
+		}
+		response.Updated = len(moved)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config.LogFrom(ctx).WithField("action", req.Action).Infof("Bulk status change updated %d of %d messages", response.Updated, len(req.IDs))
+	return response, nil
+}
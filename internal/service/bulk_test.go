@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageService_BulkUpdateStatus(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "a", Status: db.MessageStatusPending},
+		{To: "+905552222222", Content: "b", Status: db.MessageStatusPending},
+		{To: "+905553333333", Content: "c", Status: db.MessageStatusFailed},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+	service := NewMessageService(testDB)
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		for _, req := range []*dto.BulkStatusRequest{
+			{IDs: []int64{messages[0].ID}, Action: "delete"},
+			{Action: BulkActionCancel},
+			{IDs: make([]int64, MaxBulkStatusIDs+1), Action: BulkActionCancel},
+		} {
+			_, err := service.BulkUpdateStatus(ctx, req)
+			assert.ErrorIs(t, err, ErrInvalidBulkStatus)
+		}
+	})
+
+	t.Run("cancels pending messages", func(t *testing.T) {
+		response, err := service.BulkUpdateStatus(ctx, &dto.BulkStatusRequest{
+			IDs:    []int64{messages[0].ID, messages[2].ID, 999, messages[1].ID},
+			Action: BulkActionCancel,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, response.Updated)
+		assert.Equal(t, []dto.BulkStatusResult{
+			{ID: messages[0].ID, Result: BulkResultUpdated, Status: "cancelled"},
+			{ID: messages[2].ID, Result: BulkResultSkipped, Status: "failed"},
+			{ID: 999, Result: BulkResultNotFound},
+			{ID: messages[1].ID, Result: BulkResultUpdated, Status: "cancelled"},
+		}, response.Results)
+
+		stored, err := db.GetMessageByID(ctx, testDB, messages[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.MessageStatusCancelled, stored.Status)
+	})
+
+	t.Run("requeues failed messages", func(t *testing.T) {
+		response, err := service.BulkUpdateStatus(ctx, &dto.BulkStatusRequest{
+			IDs:    []int64{messages[2].ID, messages[0].ID},
+			Action: BulkActionRequeue,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, response.Updated)
+		assert.Equal(t, []dto.BulkStatusResult{
+			{ID: messages[2].ID, Result: BulkResultUpdated, Status: "pending"},
+			{ID: messages[0].ID, Result: BulkResultSkipped, Status: "cancelled"},
+		}, response.Results)
+	})
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// Campaign approval errors
+var (
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignExists   = errors.New("campaign already registered")
+	// ErrCampaignStatus is a step the campaign is not in the status for, e.g. launching a campaign not approved yet
+	ErrCampaignStatus = errors.New("campaign status conflict")
+	// ErrSelfApproval is the API key that submitted a campaign approving it
+	ErrSelfApproval = errors.New("the API key that submitted the campaign cannot approve it")
+)
+
+// MaxCampaignDescriptionLength bounds the description of a campaign
+const MaxCampaignDescriptionLength = 500
+
+// Steps of the campaign approval workflow, the labels of telemetry.CampaignSteps
+const (
+	campaignCreated   = "created"
+	campaignSubmitted = "submitted"
+	campaignApproved  = "approved"
+	campaignRejected  = "rejected"
+	campaignLaunched  = "launched"
+	campaignPaused    = "paused"
+	campaignResumed   = "resumed"
+)
+
+// campaignRegistrar is the actor of the campaigns registered by their first held message
+const campaignRegistrar = "scheduler"
+
+// CampaignInterface defines the approval workflow of campaigns
+type CampaignInterface interface {
+	ListCampaigns(ctx context.Context, status string) (*dto.CampaignsResponse, error)
+	GetCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+	CreateCampaign(ctx context.Context, req *dto.CampaignRequest) (*dto.SingleCampaignResponse, error)
+	SubmitCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+	ApproveCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+	RejectCampaign(ctx context.Context, name string, req *dto.CampaignReviewRequest) (*dto.SingleCampaignResponse, error)
+	LaunchCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+	PauseCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+	ResumeCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error)
+}
+
+// CampaignService runs the approval workflow of campaigns: a draft is submitted, approved by another API key and
+// launched. The scheduler holds the pending messages of a registered campaign until it is launched and while it is
+// paused. A launched campaign keeps the recipients it sent to as its progress cursor, so after a pause, a change of
+// its throttle profile or content and a resume it skips the messages to recipients it sent to already.
+type CampaignService struct {
+	db  *bun.DB
+	cfg config.Campaigns
+}
+
+func NewCampaignService(database *bun.DB, cfg config.Campaigns) *CampaignService {
+	return &CampaignService{
+		db:  database,
+		cfg: cfg,
+	}
+}
+
+// ListCampaigns returns the campaigns of status ordered by name, every campaign when status is empty
+func (s *CampaignService) ListCampaigns(ctx context.Context, status string) (*dto.CampaignsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.ListCampaigns")
+	defer span.End()
+
+	if status != "" && !db.CampaignStatus(status).IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q, expected draft, pending_approval, approved, launched or paused", ErrInvalidCampaign, status)
+	}
+	campaigns, err := db.ListCampaigns(ctx, s.db, db.CampaignStatus(status))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(campaigns))
+	for i, campaign := range campaigns {
+		names[i] = campaign.Name
+	}
+	pending, err := db.CountPendingCampaignMessages(ctx, s.db, names)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := db.GetCampaignProgress(ctx, s.db, names)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.CampaignResponse, len(campaigns))
+	for i, campaign := range campaigns {
+		responses[i] = convertCampaign(campaign, pending[campaign.Name], progress[campaign.Name])
+	}
+	return &dto.CampaignsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Campaigns: responses,
+	}, nil
+}
+
+// GetCampaign returns the campaign of name with its pending messages
+func (s *CampaignService) GetCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.GetCampaign")
+	defer span.End()
+
+	campaign, err := s.campaign(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.singleCampaign(ctx, campaign)
+}
+
+// CreateCampaign registers req.Name as a draft, the pending messages of the campaign are held right away and the
+// ones enqueued later when they are claimed
+func (s *CampaignService) CreateCampaign(ctx context.Context, req *dto.CampaignRequest) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.CreateCampaign")
+	defer span.End()
+
+	if req.Name == "" || !db.ValidLabel(req.Name) {
+		return nil, fmt.Errorf("%w: name must be 1 to %d letters, digits, '.', '_' or '-'", ErrInvalidCampaign, db.MaxLabelLength)
+	}
+	if len(req.Description) > MaxCampaignDescriptionLength {
+		return nil, fmt.Errorf("%w: description cannot exceed %d characters", ErrInvalidCampaign, MaxCampaignDescriptionLength)
+	}
+
+	campaign := &db.Campaign{
+		Name:        req.Name,
+		Status:      db.CampaignStatusDraft,
+		Description: req.Description,
+		CreatedBy:   quota.APIKeyFrom(ctx),
+	}
+	created, err := db.CreateCampaign(ctx, s.db, campaign)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, fmt.Errorf("%w: %s", ErrCampaignExists, req.Name)
+	}
+	telemetry.RecordCampaignStep(campaignCreated)
+
+	deferred, err := db.DeferCampaignMessages(ctx, s.db, campaign.Name, time.Now().Add(s.cfg.Recheck))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("campaign", campaign.Name).Infof("Campaign registered for approval, held %d pending messages", deferred)
+	return s.singleCampaign(ctx, campaign)
+}
+
+// SubmitCampaign submits a draft for approval
+func (s *CampaignService) SubmitCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.SubmitCampaign")
+	defer span.End()
+
+	return s.step(ctx, name, campaignSubmitted, db.CampaignStatusDraft, db.CampaignStatusPendingApproval,
+		func(campaign *db.Campaign, actor string, now time.Time) ([]string, error) {
+			campaign.SubmittedBy, campaign.SubmittedAt = actor, &now
+			return []string{"submitted_by", "submitted_at"}, nil
+		})
+}
+
+// ApproveCampaign approves a submitted campaign. The API key that submitted it cannot approve it, on a server
+// without API keys anyone can.
+func (s *CampaignService) ApproveCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.ApproveCampaign")
+	defer span.End()
+
+	return s.step(ctx, name, campaignApproved, db.CampaignStatusPendingApproval, db.CampaignStatusApproved,
+		func(campaign *db.Campaign, actor string, now time.Time) ([]string, error) {
+			if actor != "" && actor == campaign.SubmittedBy {
+				return nil, ErrSelfApproval
+			}
+			campaign.ApprovedBy, campaign.ApprovedAt = actor, &now
+			return []string{"approved_by", "approved_at"}, nil
+		})
+}
+
+// RejectCampaign sends a submitted campaign back to draft with the reason of the rejection
+func (s *CampaignService) RejectCampaign(ctx context.Context, name string, req *dto.CampaignReviewRequest) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.RejectCampaign")
+	defer span.End()
+
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required to reject a campaign", ErrInvalidCampaign)
+	}
+	if len(req.Reason) > MaxCampaignDescriptionLength {
+		return nil, fmt.Errorf("%w: reason cannot exceed %d characters", ErrInvalidCampaign, MaxCampaignDescriptionLength)
+	}
+	return s.step(ctx, name, campaignRejected, db.CampaignStatusPendingApproval, db.CampaignStatusDraft,
+		func(campaign *db.Campaign, actor string, _ time.Time) ([]string, error) {
+			campaign.RejectedBy, campaign.Rejection = actor, req.Reason
+			return []string{"rejected_by", "rejection"}, nil
+		})
+}
+
+// LaunchCampaign launches an approved campaign, its held messages are sent within campaigns.recheck
+func (s *CampaignService) LaunchCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.LaunchCampaign")
+	defer span.End()
+
+	return s.step(ctx, name, campaignLaunched, db.CampaignStatusApproved, db.CampaignStatusLaunched,
+		func(campaign *db.Campaign, actor string, now time.Time) ([]string, error) {
+			campaign.LaunchedBy, campaign.LaunchedAt = actor, &now
+			return []string{"launched_by", "launched_at"}, nil
+		})
+}
+
+// PauseCampaign pauses a launched campaign, its pending messages are held from now on and the sends in flight
+// finish
+func (s *CampaignService) PauseCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.PauseCampaign")
+	defer span.End()
+
+	response, err := s.step(ctx, name, campaignPaused, db.CampaignStatusLaunched, db.CampaignStatusPaused,
+		func(campaign *db.Campaign, actor string, now time.Time) ([]string, error) {
+			campaign.PausedBy, campaign.PausedAt = actor, &now
+			return []string{"paused_by", "paused_at"}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	deferred, err := db.DeferCampaignMessages(ctx, s.db, name, time.Now().Add(s.cfg.Recheck))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("campaign", name).Infof("Held %d pending messages of the paused campaign", deferred)
+	return response, nil
+}
+
+// ResumeCampaign resumes a paused campaign from its progress cursor within campaigns.recheck, its messages to
+// recipients it sent to before the pause are cancelled instead of sent
+func (s *CampaignService) ResumeCampaign(ctx context.Context, name string) (*dto.SingleCampaignResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CampaignService.ResumeCampaign")
+	defer span.End()
+
+	return s.step(ctx, name, campaignResumed, db.CampaignStatusPaused, db.CampaignStatusLaunched,
+		func(campaign *db.Campaign, actor string, now time.Time) ([]string, error) {
+			campaign.ResumedBy, campaign.ResumedAt = actor, &now
+			return []string{"resumed_by", "resumed_at"}, nil
+		})
+}
+
+// step moves the campaign of name from the status from to to on behalf of the calling API key, apply sets the
+// fields of the step and returns their columns
+func (s *CampaignService) step(ctx context.Context, name, step string, from, to db.CampaignStatus, apply func(campaign *db.Campaign, actor string, now time.Time) ([]string, error)) (*dto.SingleCampaignResponse, error) {
+	campaign, err := s.campaign(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != from {
+		return nil, fmt.Errorf("%w: campaign %s is %s, it must be %s to be %s", ErrCampaignStatus, name, campaign.Status, from, step)
+	}
+
+	actor := quota.APIKeyFrom(ctx)
+	columns, err := apply(campaign, actor, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	campaign.Status = to
+	changed, err := db.TransitionCampaign(ctx, s.db, campaign, from, columns...)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, fmt.Errorf("%w: campaign %s changed meanwhile", ErrCampaignStatus, name)
+	}
+	telemetry.RecordCampaignStep(step)
+
+	config.LogFrom(ctx).WithField("campaign", name).WithField("api_key", actor).Infof("Campaign %s, it is %s", step, to)
+	return s.singleCampaign(ctx, campaign)
+}
+
+// campaign returns the campaign of name, ErrCampaignNotFound when it is not registered
+func (s *CampaignService) campaign(ctx context.Context, name string) (*db.Campaign, error) {
+	campaign, err := db.GetCampaign(ctx, s.db, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCampaignNotFound, name)
+	}
+	return campaign, err
+}
+
+func (s *CampaignService) singleCampaign(ctx context.Context, campaign *db.Campaign) (*dto.SingleCampaignResponse, error) {
+	pending, err := db.CountPendingCampaignMessages(ctx, s.db, []string{campaign.Name})
+	if err != nil {
+		return nil, err
+	}
+	progress, err := db.GetCampaignProgress(ctx, s.db, []string{campaign.Name})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.SingleCampaignResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Campaign: convertCampaign(campaign, pending[campaign.Name], progress[campaign.Name]),
+	}, nil
+}
+
+// convertCampaign converts campaign, progress is nil for a campaign that did not send yet
+func convertCampaign(campaign *db.Campaign, pending int, progress *db.CampaignProgress) dto.CampaignResponse {
+	response := dto.CampaignResponse{
+		Name:        campaign.Name,
+		Status:      string(campaign.Status),
+		Description: campaign.Description,
+		CreatedBy:   campaign.CreatedBy,
+		SubmittedBy: campaign.SubmittedBy,
+		SubmittedAt: campaign.SubmittedAt,
+		ApprovedBy:  campaign.ApprovedBy,
+		ApprovedAt:  campaign.ApprovedAt,
+		LaunchedBy:  campaign.LaunchedBy,
+		LaunchedAt:  campaign.LaunchedAt,
+		PausedBy:    campaign.PausedBy,
+		PausedAt:    campaign.PausedAt,
+		ResumedBy:   campaign.ResumedBy,
+		ResumedAt:   campaign.ResumedAt,
+		RejectedBy:  campaign.RejectedBy,
+		Rejection:   campaign.Rejection,
+		Pending:     pending,
+		CreatedAt:   campaign.CreatedAt,
+		UpdatedAt:   campaign.UpdatedAt,
+	}
+	if progress != nil {
+		response.Cursor = &dto.CampaignCursor{
+			Recipients:    progress.Recipients,
+			Messages:      progress.Messages,
+			LastMessageID: progress.LastMessageID,
+			LastSentAt:    progress.LastSentAt,
+		}
+	}
+	return response
+}
+
+// heldCampaigns are the campaigns whose messages the scheduler holds in a batch, the ones not launched yet or paused
+type heldCampaigns struct {
+	// requireApproval holds the campaigns that are not registered too, see config.Campaigns.RequireApproval
+	requireApproval bool
+
+	mu sync.Mutex
+	// held are the statuses of the held campaigns
+	held map[string]db.CampaignStatus
+	// launched are the registered campaigns that were launched, the others are registered by their first message
+	launched map[string]bool
+	// deferred are the campaigns whose pending messages the batch deferred already
+	deferred map[string]bool
+}
+
+// loadHeldCampaigns loads the campaigns held by the approval workflow
+func loadHeldCampaigns(ctx context.Context, database bun.IDB, cfg config.Campaigns) (*heldCampaigns, error) {
+	campaigns, err := db.ListCampaigns(ctx, database, "")
+	if err != nil {
+		return nil, err
+	}
+	held := &heldCampaigns{
+		requireApproval: cfg.RequireApproval,
+		held:            make(map[string]db.CampaignStatus),
+		launched:        make(map[string]bool),
+		deferred:        make(map[string]bool),
+	}
+	for _, campaign := range campaigns {
+		if campaign.Status == db.CampaignStatusLaunched {
+			held.launched[campaign.Name] = true
+		} else {
+			held.held[campaign.Name] = campaign.Status
+		}
+	}
+	return held, nil
+}
+
+// holds returns the status of campaign when its messages are held, empty when they are not, and whether the
+// campaign must be registered first because approval is required and it is not registered yet
+func (h *heldCampaigns) holds(campaign string) (status db.CampaignStatus, register bool) {
+	if h == nil || campaign == "" {
+		return "", false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if status, ok := h.held[campaign]; ok {
+		return status, false
+	}
+	if !h.requireApproval || h.launched[campaign] {
+		return "", false
+	}
+	h.held[campaign] = db.CampaignStatusDraft
+	return db.CampaignStatusDraft, true
+}
+
+// tracks reports whether campaign is a launched registered campaign, whose progress cursor skips the recipients
+// it sent to already
+func (h *heldCampaigns) tracks(campaign string) bool {
+	if h == nil || campaign == "" {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.launched[campaign]
+}
+
+// first reports whether campaign is seen for the first time in the batch
+func (h *heldCampaigns) first(campaign string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.deferred[campaign] {
+		return false
+	}
+	h.deferred[campaign] = true
+	return true
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaignService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	marketing := quota.ContextWithAPIKey(ctx, "marketing")
+	ops := quota.ContextWithAPIKey(ctx, "ops")
+	service := NewCampaignService(testDB, config.Campaigns{Recheck: time.Hour})
+
+	t.Run("invalid campaigns", func(t *testing.T) {
+		for _, req := range []dto.CampaignRequest{
+			{},
+			{Name: "spring sale"},
+			{Name: "spring-sale", Description: string(make([]byte, MaxCampaignDescriptionLength+1))},
+		} {
+			_, err := service.CreateCampaign(ctx, &req)
+			assert.True(t, errors.Is(err, ErrInvalidCampaign), "%+v", req)
+		}
+		_, err := service.ListCampaigns(ctx, "sent")
+		assert.True(t, errors.Is(err, ErrInvalidCampaign))
+	})
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Spring sale", Campaign: "spring-sale", Status: db.MessageStatusPending},
+		{To: "+905552222222", Content: "Spring sale", Campaign: "spring-sale", Status: db.MessageStatusPending},
+		{To: "+905553333333", Content: "Password reset", Status: db.MessageStatusPending},
+	}
+	_, err := testDB.NewInsert().Model(&messages).Exec(ctx)
+	require.NoError(t, err)
+
+	created, err := service.CreateCampaign(marketing, &dto.CampaignRequest{Name: "spring-sale", Description: "Spring sale"})
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CampaignStatusDraft), created.Campaign.Status)
+	assert.Equal(t, "marketing", created.Campaign.CreatedBy)
+	assert.Equal(t, 2, created.Campaign.Pending)
+	held, err := db.GetMessageByID(ctx, testDB, messages[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, held.ScheduledAt, "the pending messages are held right away")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *held.ScheduledAt, time.Minute)
+	other, err := db.GetMessageByID(ctx, testDB, messages[2].ID)
+	require.NoError(t, err)
+	assert.Nil(t, other.ScheduledAt)
+
+	_, err = service.CreateCampaign(ops, &dto.CampaignRequest{Name: "spring-sale"})
+	assert.True(t, errors.Is(err, ErrCampaignExists))
+	_, err = service.LaunchCampaign(marketing, "spring-sale")
+	assert.True(t, errors.Is(err, ErrCampaignStatus), "a draft cannot be launched")
+	_, err = service.SubmitCampaign(marketing, "winter-sale")
+	assert.True(t, errors.Is(err, ErrCampaignNotFound))
+
+	submitted, err := service.SubmitCampaign(marketing, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CampaignStatusPendingApproval), submitted.Campaign.Status)
+	assert.Equal(t, "marketing", submitted.Campaign.SubmittedBy)
+
+	_, err = service.ApproveCampaign(marketing, "spring-sale")
+	assert.True(t, errors.Is(err, ErrSelfApproval), "the submitter cannot approve its own campaign")
+	_, err = service.RejectCampaign(ops, "spring-sale", &dto.CampaignReviewRequest{})
+	assert.True(t, errors.Is(err, ErrInvalidCampaign), "a rejection requires a reason")
+
+	rejected, err := service.RejectCampaign(ops, "spring-sale", &dto.CampaignReviewRequest{Reason: "Link points to staging"})
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CampaignStatusDraft), rejected.Campaign.Status)
+	assert.Equal(t, "Link points to staging", rejected.Campaign.Rejection)
+
+	_, err = service.SubmitCampaign(marketing, "spring-sale")
+	require.NoError(t, err)
+	approved, err := service.ApproveCampaign(ops, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CampaignStatusApproved), approved.Campaign.Status)
+	assert.Equal(t, "ops", approved.Campaign.ApprovedBy)
+	require.NotNil(t, approved.Campaign.ApprovedAt)
+
+	launched, err := service.LaunchCampaign(marketing, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CampaignStatusLaunched), launched.Campaign.Status)
+	assert.Equal(t, "marketing", launched.Campaign.LaunchedBy)
+
+	assert.Nil(t, launched.Campaign.Cursor, "the campaign sent no message yet")
+
+	list, err := service.ListCampaigns(ctx, string(db.CampaignStatusLaunched))
+	require.NoError(t, err)
+	require.Len(t, list.Campaigns, 1)
+	assert.Equal(t, 2, list.Campaigns[0].Pending)
+	list, err = service.ListCampaigns(ctx, string(db.CampaignStatusDraft))
+	require.NoError(t, err)
+	assert.Empty(t, list.Campaigns)
+
+	t.Run("pause and resume", func(t *testing.T) {
+		_, err := service.ResumeCampaign(marketing, "spring-sale")
+		assert.True(t, errors.Is(err, ErrCampaignStatus), "a launched campaign cannot be resumed")
+
+		require.NoError(t, db.AdvanceCampaignCursor(ctx, testDB, "spring-sale", messages[0].To, messages[0].ID))
+		paused, err := service.PauseCampaign(marketing, "spring-sale")
+		require.NoError(t, err)
+		assert.Equal(t, string(db.CampaignStatusPaused), paused.Campaign.Status)
+		assert.Equal(t, "marketing", paused.Campaign.PausedBy)
+		require.NotNil(t, paused.Campaign.PausedAt)
+		_, err = service.PauseCampaign(marketing, "spring-sale")
+		assert.True(t, errors.Is(err, ErrCampaignStatus))
+
+		resumed, err := service.ResumeCampaign(ops, "spring-sale")
+		require.NoError(t, err)
+		assert.Equal(t, string(db.CampaignStatusLaunched), resumed.Campaign.Status)
+		assert.Equal(t, "ops", resumed.Campaign.ResumedBy)
+		require.NotNil(t, resumed.Campaign.Cursor)
+		assert.Equal(t, 1, resumed.Campaign.Cursor.Recipients)
+		assert.Equal(t, messages[0].ID, resumed.Campaign.Cursor.LastMessageID)
+		assert.NotNil(t, resumed.Campaign.Cursor.LastSentAt)
+	})
+}
+
+func TestScheduler_ProcessBatch_HeldCampaigns(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	_, err := db.CreateCampaign(ctx, testDB, &db.Campaign{Name: "spring-sale", Status: db.CampaignStatusApproved})
+	require.NoError(t, err)
+	_, err = db.CreateCampaign(ctx, testDB, &db.Campaign{Name: "newsletter", Status: db.CampaignStatusLaunched})
+	require.NoError(t, err)
+
+	process := func(requireApproval bool, messages ...*db.Message) *fakeQueue {
+		cfg := &config.Cfg{
+			Messaging: config.Messaging{BatchSize: len(messages), SkipEvents: true},
+			Webhook:   config.Webhook{URL: server.URL},
+			Campaigns: config.Campaigns{RequireApproval: requireApproval, Recheck: time.Hour},
+		}
+		q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+		require.NoError(t, q.Enqueue(ctx, messages...))
+		NewSchedulerWithQueue(testDB, q, cfg).processBatch(ctx)
+		return q
+	}
+
+	q := process(false,
+		&db.Message{ID: 1, To: "+905551111111", Content: "Approved, not launched", Campaign: "spring-sale"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Launched", Campaign: "newsletter"},
+		&db.Message{ID: 3, To: "+905553333333", Content: "Not registered", Campaign: "flash-sale"},
+		&db.Message{ID: 4, To: "+905554444444", Content: "Password reset"},
+	)
+	assert.Equal(t, []int64{1}, q.deferred, "only the registered campaigns are held without require_approval")
+	assert.Len(t, q.acked, 3)
+	events, err := db.GetMessageEvents(ctx, testDB, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipHeld, events[0].Reason)
+
+	q = process(true,
+		&db.Message{ID: 5, To: "+905555555555", Content: "Not registered", Campaign: "flash-sale"},
+		&db.Message{ID: 6, To: "+905556666666", Content: "Not registered", Campaign: "flash-sale"},
+		&db.Message{ID: 7, To: "+905557777777", Content: "Launched", Campaign: "newsletter"},
+		&db.Message{ID: 8, To: "+905558888888", Content: "Password reset"},
+	)
+	assert.ElementsMatch(t, []int64{5, 6}, q.deferred, "require_approval holds the campaigns that are not registered")
+	assert.Len(t, q.acked, 2)
+	registered, err := db.GetCampaign(ctx, testDB, "flash-sale")
+	require.NoError(t, err)
+	assert.Equal(t, db.CampaignStatusDraft, registered.Status, "the first message registers its campaign as a draft")
+	assert.Equal(t, campaignRegistrar, registered.CreatedBy)
+}
+
+func TestScheduler_ProcessBatch_ResumedCampaign(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	campaign := &db.Campaign{Name: "newsletter", Status: db.CampaignStatusLaunched}
+	_, err := db.CreateCampaign(ctx, testDB, campaign)
+	require.NoError(t, err)
+
+	process := func(messages ...*db.Message) *fakeQueue {
+		cfg := &config.Cfg{
+			Messaging: config.Messaging{BatchSize: len(messages), SkipEvents: true},
+			Webhook:   config.Webhook{URL: server.URL},
+			Campaigns: config.Campaigns{Recheck: time.Hour},
+		}
+		q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+		require.NoError(t, q.Enqueue(ctx, messages...))
+		NewSchedulerWithQueue(testDB, q, cfg).processBatch(ctx)
+		return q
+	}
+	transition := func(from, to db.CampaignStatus) {
+		campaign.Status = to
+		changed, err := db.TransitionCampaign(ctx, testDB, campaign, from)
+		require.NoError(t, err)
+		require.True(t, changed)
+	}
+
+	q := process(&db.Message{ID: 1, To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"})
+	assert.Len(t, q.acked, 1)
+
+	transition(db.CampaignStatusLaunched, db.CampaignStatusPaused)
+	q = process(&db.Message{ID: 2, To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"})
+	assert.Equal(t, []int64{2}, q.deferred, "a paused campaign is held")
+	events, err := db.GetMessageEvents(ctx, testDB, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0].Detail, "campaign newsletter is paused")
+
+	transition(db.CampaignStatusPaused, db.CampaignStatusLaunched)
+	replayOf := int64(1)
+	q = process(
+		// the stuck reaper requeued message 1, its send went out but was never settled
+		&db.Message{ID: 1, To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 3, To: "+905551111111", Content: "Newsletter, part 2", Campaign: "newsletter"},
+		&db.Message{ID: 4, To: "+905551111111", Content: "Newsletter", Campaign: "newsletter", ReplayOf: &replayOf},
+	)
+	assert.Empty(t, q.cancelled)
+	require.Len(t, q.acked, 4, "a recipient is sent every message of the campaign and replays")
+	assert.Equal(t, db.MessageStatusAcceptedWithoutID, q.acked[1].Status, "a message sent before the pause is not sent again")
+	for _, id := range []int64{2, 3, 4} {
+		assert.NotEqual(t, db.MessageStatusAcceptedWithoutID, q.acked[id].Status, id)
+	}
+	events, err = db.GetMessageEvents(ctx, testDB, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipCampaignSent, events[0].Reason)
+	assert.Contains(t, events[0].Detail, "campaign newsletter sent the message at ")
+
+	progress, err := db.GetCampaignProgress(ctx, testDB, []string{"newsletter"})
+	require.NoError(t, err)
+	require.Contains(t, progress, "newsletter")
+	assert.Equal(t, 2, progress["newsletter"].Recipients)
+	assert.Equal(t, 4, progress["newsletter"].Messages)
+	assert.Equal(t, int64(4), progress["newsletter"].LastMessageID)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Cancellation errors
+var (
+	// ErrSendCancelled is the cause of the send context of a message cancelled by an operator
+	ErrSendCancelled  = errors.New("send cancelled by an operator")
+	ErrNotCancellable = errors.New("only pending and sending messages can be cancelled")
+	// ErrSendIssued is returned once the webhook call of a sending message was issued, the send runs to its end
+	ErrSendIssued = errors.New("the webhook call of the message was already issued")
+	// ErrSendNotLocal is returned for a message sending on another instance, only the scheduler that claimed it
+	// can cancel its send
+	ErrSendNotLocal = errors.New("the message is being sent by another instance")
+)
+
+// SendCanceller cancels the sends of claimed messages, see Scheduler.CancelSend
+type SendCanceller interface {
+	CancelSend(ctx context.Context, id int64) error
+}
+
+// SetSendCanceller makes CancelMessage cancel the sends of sending messages with sends, without one only
+// pending messages can be cancelled
+func (s *MessageService) SetSendCanceller(sends SendCanceller) {
+	s.sends = sends
+}
+
+// CancelMessage cancels a pending message, or a sending one as long as the webhook call of its send was not
+// issued. A cancelled message is never sent. A pending message claimed meanwhile is cancelled like a sending one.
+func (s *MessageService) CancelMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.CancelMessage")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := db.GetMessageByID(ctx, s.db, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	if message.Status == db.MessageStatusPending {
+		moved, err := db.TransitionMessages(ctx, s.db, []int64{messageID}, db.MessageStatusPending, db.MessageStatusCancelled)
+		if err != nil {
+			return nil, err
+		}
+		if len(moved) == 0 {
+			if message, err = db.GetMessageByID(ctx, s.db, messageID); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+			}
+		} else {
+			message.Status, message.UpdatedAt = db.MessageStatusCancelled, time.Now()
+		}
+	}
+
+	switch {
+	case message.Status == db.MessageStatusCancelled:
+	case message.Status != db.MessageStatusSending:
+		return nil, fmt.Errorf("%w: message %d is %s", ErrNotCancellable, message.ID, message.Status)
+	case s.sends == nil:
+		return nil, fmt.Errorf("%w: message %d", ErrSendNotLocal, message.ID)
+	default:
+		if err := s.sends.CancelSend(ctx, messageID); err != nil {
+			return nil, fmt.Errorf("message %d: %w", messageID, err)
+		}
+		// the scheduler settled the message as cancelled
+		if message, err = db.GetMessageByID(ctx, s.db, messageID); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+		}
+	}
+	config.LogFrom(ctx).WithField("message_id", messageID).Info("Message cancelled")
+
+	return &dto.SingleMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Message: s.convertToMessageResponse(message),
+	}, nil
+}
+
+// CancelSend cancels the send of the message of id claimed by the scheduler and waits until the message is
+// settled as cancelled. It fails with ErrSendIssued once the webhook call was issued and with ErrSendNotLocal
+// when the scheduler did not claim the message.
+func (s *Scheduler) CancelSend(ctx context.Context, id int64) error {
+	s.inFlightMu.Lock()
+	send, ok := s.inFlight[id]
+	switch {
+	case !ok:
+		s.inFlightMu.Unlock()
+		return ErrSendNotLocal
+	case send.issued:
+		s.inFlightMu.Unlock()
+		return ErrSendIssued
+	}
+	// issueSend checks cancelled under the same lock, the webhook call is never issued from here on
+	send.cancelled = true
+	send.cancel(ErrSendCancelled)
+	s.inFlightMu.Unlock()
+
+	select {
+	case <-send.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageService_CancelMessage(t *testing.T) {
+	received, release := make(chan string, 2), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.MessagePayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload.To
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "slow"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"},
+		{To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"},
+	}
+	require.NoError(t, db.CreateMessages(ctx, testDB, messages))
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 2, Interval: time.Hour, SkipEvents: true},
+		Webhook:   config.Webhook{URL: server.URL},
+		Throttling: config.Throttling{
+			Profiles:               []config.ThrottleProfile{{Name: "gentle", RateLimit: 1}},
+			DefaultCampaignProfile: "gentle",
+		},
+	}
+	scheduler := NewScheduler(testDB, cfg)
+	service := NewMessageService(testDB)
+	service.SetSendCanceller(scheduler)
+
+	_, err := scheduler.Start(ctx)
+	require.NoError(t, err)
+	scheduler.tick(ctx)
+	// the first message taking the send slot is in flight, the other waits for its slot
+	sending := <-received
+	issued, waiting := messages[0], messages[1]
+	if sending != issued.To {
+		issued, waiting = waiting, issued
+	}
+
+	t.Run("sending before the webhook call", func(t *testing.T) {
+		response, err := service.CancelMessage(ctx, strconv.FormatInt(waiting.ID, 10))
+		require.NoError(t, err)
+		assert.Equal(t, "cancelled", response.Message.Status)
+
+		events, err := db.GetMessageEvents(ctx, testDB, waiting.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, db.SkipCancelled, events[0].Reason)
+	})
+
+	t.Run("webhook call issued", func(t *testing.T) {
+		_, err := service.CancelMessage(ctx, strconv.FormatInt(issued.ID, 10))
+		assert.ErrorIs(t, err, ErrSendIssued)
+	})
+
+	close(release)
+	_, err = scheduler.Stop(ctx)
+	require.NoError(t, err)
+	scheduler.Wait()
+	assert.Len(t, received, 0, "the cancelled message was never sent")
+
+	stored, err := db.GetMessageByID(ctx, testDB, issued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusSent, stored.Status, "a send whose webhook call was issued runs to its end")
+
+	t.Run("not cancellable", func(t *testing.T) {
+		_, err := service.CancelMessage(ctx, strconv.FormatInt(issued.ID, 10))
+		assert.ErrorIs(t, err, ErrNotCancellable)
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		pending := &db.Message{To: "+905553333333", Content: "Later"}
+		require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{pending}))
+
+		response, err := service.CancelMessage(ctx, strconv.FormatInt(pending.ID, 10))
+		require.NoError(t, err)
+		assert.Equal(t, "cancelled", response.Message.Status)
+	})
+
+	t.Run("sending on another instance", func(t *testing.T) {
+		claimed := &db.Message{To: "+905554444444", Content: "Elsewhere", Status: db.MessageStatusSending}
+		_, err := testDB.NewInsert().Model(claimed).Exec(ctx)
+		require.NoError(t, err)
+
+		_, err = service.CancelMessage(ctx, strconv.FormatInt(claimed.ID, 10))
+		assert.ErrorIs(t, err, ErrSendNotLocal)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := service.CancelMessage(ctx, "999999")
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+}
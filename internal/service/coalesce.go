@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescer runs identical concurrent reads once, e.g. the stats of a dashboard whose widgets refresh at the
+// same time. A read started while an identical one is in flight waits for it and gets its result.
+type coalescer struct {
+	group singleflight.Group
+}
+
+// coalesce runs fn once for the concurrent calls with the same operation and key, the callers share its result.
+// fn outlives the cancellation of the caller that started it, so the others still get its result, a cancelled
+// caller stops waiting. A nil coalescer runs fn for every call.
+func coalesce[T any](ctx context.Context, c *coalescer, operation, key string, fn func(context.Context) (T, error)) (T, error) {
+	if c == nil {
+		return fn(ctx)
+	}
+
+	results := c.group.DoChan(operation+"\x00"+key, func() (any, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	var zero T
+	select {
+	case result := <-results:
+		if result.Shared {
+			telemetry.RecordCoalescedRead(operation)
+		}
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		return result.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
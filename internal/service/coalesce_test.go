@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	c := &coalescer{}
+	release := make(chan struct{})
+	var calls atomic.Int32
+	read := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := coalesce(context.Background(), c, "stats", "", read)
+			assert.NoError(t, err)
+			results[i] = result
+		}()
+	}
+	// let the callers join the read in flight before it finishes
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, []int{42, 42, 42, 42, 42}, results)
+
+	// a different key is read on its own, and a finished read is not reused
+	_, err := coalesce(context.Background(), c, "stats", "other", read)
+	require.NoError(t, err)
+	_, err = coalesce(context.Background(), c, "stats", "", read)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestCoalesce_CancelledCaller(t *testing.T) {
+	c := &coalescer{}
+	release := make(chan struct{})
+	read := func(ctx context.Context) (int, error) {
+		<-release
+		// the read outlives the caller that started it
+		return 42, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := coalesce(ctx, c, "stats", "", read)
+		cancelled <- err
+	}()
+	waiting := make(chan int)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		result, _ := coalesce(context.Background(), c, "stats", "", read)
+		waiting <- result
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+	time.Sleep(40 * time.Millisecond)
+	close(release)
+	assert.Equal(t, 42, <-waiting)
+}
+
+func TestMessageService_CoalesceReads(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	_, err := testDB.NewInsert().Model(&db.Message{To: "+905551111111", Content: "Pending", Status: db.MessageStatusPending}).
+		Exec(context.Background())
+	require.NoError(t, err)
+
+	service := NewMessageService(testDB)
+	service.CoalesceReads()
+
+	stats, err := service.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Total)
+
+	list, err := service.ListMessages(context.Background(), db.MessageFilter{Status: db.MessageStatusPending}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Len(t, list.Messages, 1)
+
+	// every caller gets its own response to set fields of
+	other, err := service.ListMessages(context.Background(), db.MessageFilter{Status: db.MessageStatusPending}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	assert.NotSame(t, list, other)
+}
+
+func TestMessageFilter_Key(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := db.MessageFilter{Status: db.MessageStatusSent, From: &from, Metadata: map[string]string{"a": "1", "b": "2"}}
+	same := db.MessageFilter{Status: db.MessageStatusSent, From: &from, Metadata: map[string]string{"b": "2", "a": "1"}}
+
+	assert.Equal(t, filter.Key(), same.Key())
+	assert.NotEqual(t, filter.Key(), db.MessageFilter{Status: db.MessageStatusSent, To: &from}.Key())
+	assert.NotEqual(t, filter.Key(), db.MessageFilter{Status: db.MessageStatusSent, From: &from}.Key())
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+var ErrInvalidCostGroup = errors.New("invalid cost group")
+
+// CostInterface defines cost reporting operations
+type CostInterface interface {
+	Costs(ctx context.Context, groupBy string, from, to *time.Time) (*dto.CostReportResponse, error)
+}
+
+type CostService struct {
+	db  *bun.DB
+	cfg config.Routing
+}
+
+func NewCostService(database *bun.DB, cfg config.Routing) *CostService {
+	return &CostService{
+		db:  database,
+		cfg: cfg,
+	}
+}
+
+// Costs sums the cost of the messages sent in [from, to) per UTC day, campaign or tenant.
+// A message costs its segments times the unit price of the route it was sent through.
+func (s *CostService) Costs(ctx context.Context, groupBy string, from, to *time.Time) (*dto.CostReportResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "CostService.Costs")
+	defer span.End()
+
+	costs, err := db.GetCosts(ctx, s.db, db.CostGroup(groupBy), from, to)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCostGroup) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCostGroup, err.Error())
+		}
+		return nil, err
+	}
+
+	response := &dto.CostReportResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		GroupBy:  groupBy,
+		Currency: s.cfg.Currency,
+		Costs:    make([]dto.CostSummary, len(costs)),
+	}
+	for i, cost := range costs {
+		response.Costs[i] = dto.CostSummary{
+			Key:      cost.Key,
+			Messages: cost.Messages,
+			Segments: cost.Segments,
+			Cost:     roundCost(cost.Cost),
+		}
+		response.Total.Messages += cost.Messages
+		response.Total.Segments += cost.Segments
+		response.Total.Cost += cost.Cost
+	}
+	response.Total.Cost = roundCost(response.Total.Cost)
+
+	return response, nil
+}
+
+// roundCost rounds to the 6 decimals unit prices are stored with
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
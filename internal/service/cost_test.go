@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCostService_Costs(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	day1 := time.Date(2026, 10, 15, 23, 30, 0, 0, time.UTC)
+	day2 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "a", Status: db.MessageStatusSent, Segments: 1, UnitPrice: 0.05, Campaign: "spring", Tenant: "acme", SentAt: &day1},
+		{To: "+905552222222", Content: "b", Status: db.MessageStatusDelivered, Segments: 2, UnitPrice: 0.05, Campaign: "spring", Tenant: "acme", SentAt: &day2},
+		{To: "+491511111111", Content: "c", Status: db.MessageStatusFailed, Segments: 3, UnitPrice: 0.1, Tenant: "globex", SentAt: &day2},
+		// never sent, not billed
+		{To: "+905553333333", Content: "d", Status: db.MessageStatusFailed, Segments: 1, UnitPrice: 0.05, Campaign: "spring"},
+		// sent without a unit price
+		{To: "+15551111111", Content: "e", Status: db.MessageStatusSent, Segments: 1, SentAt: &day2},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewCostService(testDB, config.Routing{Currency: "EUR"})
+
+	t.Run("by day", func(t *testing.T) {
+		response, err := service.Costs(ctx, "day", nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "EUR", response.Currency)
+		require.Len(t, response.Costs, 2)
+		assert.Equal(t, "2026-10-15", response.Costs[0].Key)
+		assert.InDelta(t, 0.05, response.Costs[0].Cost, 1e-9)
+		assert.Equal(t, "2026-10-16", response.Costs[1].Key)
+		assert.Equal(t, int64(3), response.Costs[1].Messages)
+		assert.Equal(t, int64(6), response.Costs[1].Segments)
+		assert.InDelta(t, 0.4, response.Costs[1].Cost, 1e-9)
+		assert.Equal(t, int64(4), response.Total.Messages)
+		assert.InDelta(t, 0.45, response.Total.Cost, 1e-9)
+	})
+
+	t.Run("by campaign within a window", func(t *testing.T) {
+		from := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+		response, err := service.Costs(ctx, "campaign", &from, nil)
+		require.NoError(t, err)
+
+		require.Len(t, response.Costs, 2)
+		assert.Equal(t, "", response.Costs[0].Key)
+		assert.InDelta(t, 0.3, response.Costs[0].Cost, 1e-9)
+		assert.Equal(t, "spring", response.Costs[1].Key)
+		assert.InDelta(t, 0.1, response.Costs[1].Cost, 1e-9)
+	})
+
+	t.Run("by tenant", func(t *testing.T) {
+		response, err := service.Costs(ctx, "tenant", nil, nil)
+		require.NoError(t, err)
+
+		require.Len(t, response.Costs, 3)
+		assert.Equal(t, "acme", response.Costs[1].Key)
+		assert.InDelta(t, 0.15, response.Costs[1].Cost, 1e-9)
+	})
+
+	t.Run("invalid group", func(t *testing.T) {
+		_, err := service.Costs(ctx, "provider", nil, nil)
+		assert.ErrorIs(t, err, ErrInvalidCostGroup)
+	})
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/uptrace/bun"
+)
+
+// countCacheMaxAge is the number of refresh intervals cached counts are used for, older counts mean no
+// instance refreshes them and the messages are counted instead
+const countCacheMaxAge = 3
+
+// CountCache keeps the message counts per status in the message_counts table, so the stats and list totals
+// read a row per status instead of counting the messages on every request
+type CountCache struct {
+	db       bun.IDB
+	interval time.Duration
+}
+
+// NewCountCache creates a count cache refreshed every interval, 0 disables it
+func NewCountCache(database bun.IDB, interval time.Duration) *CountCache {
+	return &CountCache{db: database, interval: interval}
+}
+
+// Run refreshes the counts every interval until ctx is cancelled, it does nothing when the cache is disabled.
+// Every instance running it refreshes the same rows, which is harmless.
+func (c *CountCache) Run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+			config.Log().Errorf("Failed to refresh the message counts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh counts the messages per status into the cache
+func (c *CountCache) Refresh(ctx context.Context) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "CountCache.Refresh")
+	defer span.End()
+
+	return db.RefreshMessageCounts(ctx, c.db)
+}
+
+// Counts returns the cached message counts per status and when they were counted, ok is false when the cache
+// is disabled or the counts are older than countCacheMaxAge intervals
+func (c *CountCache) Counts(ctx context.Context) (counts map[db.MessageStatus]int, countedAt time.Time, ok bool, err error) {
+	if c == nil || c.interval <= 0 {
+		return nil, time.Time{}, false, nil
+	}
+
+	counts, countedAt, err = db.GetMessageCounts(ctx, c.db)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if countedAt.IsZero() || time.Since(countedAt) > countCacheMaxAge*c.interval {
+		return nil, time.Time{}, false, nil
+	}
+	return counts, countedAt, true, nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// Dead-letter analytics
+const (
+	DefaultDeadLetterTop = 10
+	MaxDeadLetterTop     = 100
+	// DefaultDeadLetterPrefixDigits are the digits of the recipient prefixes counted by default, e.g. +905
+	DefaultDeadLetterPrefixDigits = 3
+	MaxDeadLetterPrefixDigits     = 15
+)
+
+var ErrInvalidDeadLetterQuery = errors.New("invalid dead-letter query")
+
+// DeadLetterQuery selects the groups counted by DeadLetterService.Top
+type DeadLetterQuery struct {
+	// GroupBy is reason, class, prefix or provider
+	GroupBy string
+	// Limit is the number of groups returned, DefaultDeadLetterTop when 0
+	Limit int
+	// PrefixDigits are the digits of the recipient prefixes, DefaultDeadLetterPrefixDigits when 0
+	PrefixDigits int
+	// From and To bound when the messages were dead-lettered, either may be nil
+	From, To *time.Time
+}
+
+// DeadLetterInterface defines the analytics of the dead-letter queue
+type DeadLetterInterface interface {
+	Top(ctx context.Context, query DeadLetterQuery) (*dto.DeadLetterTopResponse, error)
+	Heatmap(ctx context.Context, from, to *time.Time) (*dto.DeadLetterHeatmapResponse, error)
+}
+
+// DeadLetterService aggregates the dead-letter queue, so recurring root causes are found before messages are
+// requeued
+type DeadLetterService struct {
+	db *bun.DB
+}
+
+func NewDeadLetterService(database *bun.DB) *DeadLetterService {
+	return &DeadLetterService{
+		db: database,
+	}
+}
+
+// Top returns the failure reasons, failure classes, recipient prefixes or providers with the most messages
+// dead-lettered in the window
+func (s *DeadLetterService) Top(ctx context.Context, query DeadLetterQuery) (*dto.DeadLetterTopResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "DeadLetterService.Top")
+	defer span.End()
+
+	if query.Limit == 0 {
+		query.Limit = DefaultDeadLetterTop
+	}
+	if query.PrefixDigits == 0 {
+		query.PrefixDigits = DefaultDeadLetterPrefixDigits
+	}
+	if query.Limit < 1 || query.Limit > MaxDeadLetterTop {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidDeadLetterQuery, MaxDeadLetterTop)
+	}
+	if query.PrefixDigits < 1 || query.PrefixDigits > MaxDeadLetterPrefixDigits {
+		return nil, fmt.Errorf("%w: prefix_digits must be between 1 and %d", ErrInvalidDeadLetterQuery, MaxDeadLetterPrefixDigits)
+	}
+
+	counts, err := db.TopDeadLetters(ctx, s.db, db.DeadLetterGroup(query.GroupBy), query.PrefixDigits, query.Limit, query.From, query.To)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidDeadLetterGroup) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidDeadLetterQuery, err.Error())
+		}
+		return nil, err
+	}
+	total, err := db.CountDeadLetters(ctx, s.db, query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.DeadLetterTopResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		GroupBy: query.GroupBy,
+		Total:   int64(total),
+		Groups:  make([]dto.DeadLetterCount, len(counts)),
+	}
+	for i, count := range counts {
+		response.Groups[i] = dto.DeadLetterCount{
+			Key:      count.Key,
+			Messages: count.Messages,
+			Share:    float64(count.Messages) / float64(total),
+		}
+	}
+	return response, nil
+}
+
+// Heatmap returns the messages dead-lettered in the window by UTC weekday and hour, failures piling up at the
+// same hours point at batch jobs or provider maintenance windows
+func (s *DeadLetterService) Heatmap(ctx context.Context, from, to *time.Time) (*dto.DeadLetterHeatmapResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "DeadLetterService.Heatmap")
+	defer span.End()
+
+	hours, err := db.DeadLetterHeatmap(ctx, s.db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.DeadLetterHeatmapResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+	}
+	var peak int64
+	for _, hour := range hours {
+		response.Counts[hour.Weekday][hour.Hour] = hour.Messages
+		response.Total += hour.Messages
+		if hour.Messages > peak {
+			peak = hour.Messages
+			response.PeakWeekday, response.PeakHour = hour.Weekday, hour.Hour
+		}
+	}
+	return response, nil
+}
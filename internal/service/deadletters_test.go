@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	// 2026-10-12 is a Monday
+	monday := time.Date(2026, 10, 12, 9, 15, 0, 0, time.UTC)
+	tuesday := time.Date(2026, 10, 13, 22, 40, 0, 0, time.UTC)
+	deadLettered := func(to, provider, class, reason string, at time.Time) *db.Message {
+		return &db.Message{To: to, Content: "a", Status: db.MessageStatusFailed, Provider: provider,
+			FailureClass: class, FailureError: reason, DeadLetteredAt: &at}
+	}
+	messages := []*db.Message{
+		deadLettered("+905551111111", "netgsm", "provider_temporary", "provider returned status 503", monday),
+		deadLettered("+905552222222", "netgsm", "provider_temporary", "provider returned status 503", monday.Add(10*time.Minute)),
+		deadLettered("+905553333333", "netgsm", "timeout", "context deadline exceeded", monday.Add(20*time.Minute)),
+		deadLettered("+491511111111", "twilio", "provider_temporary", "provider returned status 503", tuesday),
+		deadLettered("+15551111111", "", "", "", tuesday),
+		// failed but still requeued automatically
+		{To: "+905554444444", Content: "a", Status: db.MessageStatusFailed, Provider: "netgsm", FailureClass: "timeout"},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewDeadLetterService(testDB)
+
+	t.Run("top reasons", func(t *testing.T) {
+		response, err := service.Top(ctx, DeadLetterQuery{GroupBy: "reason"})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(5), response.Total)
+		require.Len(t, response.Groups, 3)
+		assert.Equal(t, "provider returned status 503", response.Groups[0].Key)
+		assert.Equal(t, int64(3), response.Groups[0].Messages)
+		assert.InDelta(t, 0.6, response.Groups[0].Share, 1e-9)
+		assert.Equal(t, "", response.Groups[1].Key, "messages without a reason are counted under an empty key")
+	})
+
+	t.Run("top prefixes within a window", func(t *testing.T) {
+		to := tuesday.Truncate(24 * time.Hour)
+		response, err := service.Top(ctx, DeadLetterQuery{GroupBy: "prefix", PrefixDigits: 2, Limit: 1, To: &to})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(3), response.Total)
+		require.Len(t, response.Groups, 1)
+		assert.Equal(t, "+90", response.Groups[0].Key)
+		assert.Equal(t, int64(3), response.Groups[0].Messages)
+	})
+
+	t.Run("top providers", func(t *testing.T) {
+		response, err := service.Top(ctx, DeadLetterQuery{GroupBy: "provider"})
+		require.NoError(t, err)
+
+		require.Len(t, response.Groups, 3)
+		assert.Equal(t, "netgsm", response.Groups[0].Key)
+		assert.Equal(t, int64(3), response.Groups[0].Messages)
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		_, err := service.Top(ctx, DeadLetterQuery{GroupBy: "tenant"})
+		assert.ErrorIs(t, err, ErrInvalidDeadLetterQuery)
+		_, err = service.Top(ctx, DeadLetterQuery{GroupBy: "reason", Limit: MaxDeadLetterTop + 1})
+		assert.ErrorIs(t, err, ErrInvalidDeadLetterQuery)
+	})
+
+	t.Run("heatmap", func(t *testing.T) {
+		response, err := service.Heatmap(ctx, nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(5), response.Total)
+		assert.Equal(t, int64(3), response.Counts[time.Monday][9])
+		assert.Equal(t, int64(2), response.Counts[time.Tuesday][22])
+		assert.Equal(t, int(time.Monday), response.PeakWeekday)
+		assert.Equal(t, 9, response.PeakHour)
+	})
+}
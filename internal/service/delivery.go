@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// ErrInvalidDeliveryReport is returned for delivery reports without a message ID or with an unknown status
+var ErrInvalidDeliveryReport = errors.New("invalid delivery report")
+
+// reportStatuses maps the statuses providers report to message statuses
+var reportStatuses = map[string]db.MessageStatus{
+	"sent":        db.MessageStatusSent,
+	"delivered":   db.MessageStatusDelivered,
+	"failed":      db.MessageStatusFailed,
+	"undelivered": db.MessageStatusFailed,
+}
+
+// DeliveryReportInterface defines delivery report operations
+type DeliveryReportInterface interface {
+	HandleDeliveryReport(ctx context.Context, req *dto.DeliveryReportRequest) (*dto.DeliveryReportResponse, error)
+}
+
+// DeliveryReportService applies the delivery reports of the provider and flags accepted messages
+// no report arrived for, see config.DeliveryReports
+type DeliveryReportService struct {
+	db        *bun.DB
+	cfg       config.DeliveryReports
+	publisher events.Publisher
+}
+
+// NewDeliveryReportService creates the service, publisher may be nil
+func NewDeliveryReportService(database *bun.DB, cfg config.DeliveryReports, publisher events.Publisher) *DeliveryReportService {
+	return &DeliveryReportService{
+		db:        database,
+		cfg:       cfg,
+		publisher: publisher,
+	}
+}
+
+// HandleDeliveryReport moves the reported message to sent, delivered or failed.
+// Reports are accepted whether or not two-phase delivery is enabled, so a sent message can still be confirmed as delivered.
+func (s *DeliveryReportService) HandleDeliveryReport(ctx context.Context, req *dto.DeliveryReportRequest) (*dto.DeliveryReportResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "DeliveryReportService.HandleDeliveryReport")
+	defer span.End()
+
+	status, ok := reportStatuses[req.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: status must be sent, delivered, failed or undelivered, got %q", ErrInvalidDeliveryReport, req.Status)
+	}
+	if req.MessageID == "" {
+		return nil, fmt.Errorf("%w: message_id is required", ErrInvalidDeliveryReport)
+	}
+
+	report := db.DeliveryReport{MessageID: req.MessageID, Status: status, Error: req.Error, At: time.Now().UTC()}
+	if req.At != nil {
+		report.At = req.At.UTC()
+	}
+
+	message, applied, err := db.ApplyDeliveryReport(ctx, s.db, report)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: no message with webhook message ID %s", ErrMessageNotFound, req.MessageID)
+		}
+		return nil, err
+	}
+
+	log := config.LogFrom(ctx).WithField("message_id", message.ID).WithField("webhook_message_id", req.MessageID)
+	if applied {
+		telemetry.ObserveDeliveryReport(string(status), telemetry.DeliveryReportApplied)
+		log.Debugf("Delivery report applied, message is %s", message.Status)
+		s.publish(ctx, reportEventType(status), message, report.At)
+	} else {
+		telemetry.ObserveDeliveryReport(string(status), telemetry.DeliveryReportIgnored)
+		log.Debugf("Delivery report ignored, message is already %s", message.Status)
+	}
+
+	return &dto.DeliveryReportResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		ID:            message.ID,
+		MessageStatus: string(message.Status),
+		Applied:       applied,
+	}, nil
+}
+
+// WatchUnconfirmed marks messages accepted longer than delivery_reports.timeout ago as unconfirmed
+// every delivery_reports.check_interval until ctx is cancelled. It does nothing when two-phase delivery is disabled.
+func (s *DeliveryReportService) WatchUnconfirmed(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.MarkUnconfirmed(ctx); err != nil && ctx.Err() == nil {
+			config.Log().Errorf("Failed to mark unconfirmed messages: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MarkUnconfirmed marks messages accepted longer than delivery_reports.timeout ago as unconfirmed
+// and returns how many were marked
+func (s *DeliveryReportService) MarkUnconfirmed(ctx context.Context) (int, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "DeliveryReportService.MarkUnconfirmed")
+	defer span.End()
+
+	messages, err := db.MarkUnconfirmedMessages(ctx, s.db, time.Now().Add(-s.cfg.Timeout))
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	telemetry.AddUnconfirmedMessages(len(messages))
+	config.LogFrom(ctx).Warnf("No delivery report arrived within %s for %d accepted messages, marked them unconfirmed", s.cfg.Timeout, len(messages))
+	for _, message := range messages {
+		s.publish(ctx, events.TypeUnconfirmed, message, time.Now().UTC())
+	}
+	return len(messages), nil
+}
+
+func (s *DeliveryReportService) publish(ctx context.Context, eventType events.Type, message *db.Message, at time.Time) {
+	if s.publisher == nil {
+		return
+	}
+
+	event := events.NewEvent(eventType, message)
+	event.At = at
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		config.LogFrom(ctx).WithField("message_id", message.ID).Warnf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+func reportEventType(status db.MessageStatus) events.Type {
+	switch status {
+	case db.MessageStatusDelivered:
+		return events.TypeDelivered
+	case db.MessageStatusFailed:
+		return events.TypeFailed
+	default:
+		return events.TypeSent
+	}
+}
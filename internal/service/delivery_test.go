@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func insertAccepted(t *testing.T, bunDB *bun.DB, webhookMessageID string, sentAt time.Time) *db.Message {
+	message := &db.Message{
+		To:        "+905551111111",
+		Content:   "Hello",
+		Status:    db.MessageStatusAccepted,
+		SentAt:    &sentAt,
+		MessageID: &webhookMessageID,
+		CreatedAt: sentAt,
+		UpdatedAt: sentAt,
+	}
+	_, err := bunDB.NewInsert().Model(message).Exec(context.Background())
+	require.NoError(t, err)
+	return message
+}
+
+func TestDeliveryReportService_HandleDeliveryReport(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	service := NewDeliveryReportService(testDB, config.DeliveryReports{}, publisher)
+	message := insertAccepted(t, testDB, "report-1", time.Now())
+
+	report := func(status string) *dto.DeliveryReportResponse {
+		response, err := service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{MessageID: "report-1", Status: status})
+		require.NoError(t, err)
+		return response
+	}
+
+	response := report("sent")
+	assert.True(t, response.Applied)
+	assert.Equal(t, message.ID, response.ID)
+	assert.Equal(t, "sent", response.MessageStatus)
+
+	response = report("delivered")
+	assert.True(t, response.Applied)
+	assert.Equal(t, "delivered", response.MessageStatus)
+
+	// a late sent report does not override delivered
+	response = report("sent")
+	assert.False(t, response.Applied)
+	assert.Equal(t, "delivered", response.MessageStatus)
+
+	stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusDelivered, stored.Status)
+	assert.NotNil(t, stored.DeliveredAt)
+
+	require.Len(t, publisher.events, 2)
+	assert.Equal(t, events.TypeSent, publisher.events[0].Type)
+	assert.Equal(t, events.TypeDelivered, publisher.events[1].Type)
+	assert.Equal(t, "report-1", publisher.events[1].WebhookMessageID)
+
+	t.Run("undelivered fails the message", func(t *testing.T) {
+		message := insertAccepted(t, testDB, "report-2", time.Now())
+
+		response, err := service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{MessageID: "report-2", Status: "undelivered", Error: "Handset unreachable"})
+		require.NoError(t, err)
+		assert.Equal(t, "failed", response.MessageStatus)
+
+		stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Handset unreachable", stored.DeliveryError)
+	})
+
+	t.Run("invalid reports", func(t *testing.T) {
+		_, err := service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{MessageID: "report-1", Status: "read"})
+		assert.ErrorIs(t, err, ErrInvalidDeliveryReport)
+
+		_, err = service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{Status: "delivered"})
+		assert.ErrorIs(t, err, ErrInvalidDeliveryReport)
+
+		_, err = service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{MessageID: "unknown", Status: "delivered"})
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+}
+
+func TestDeliveryReportService_MarkUnconfirmed(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	publisher := &recordingPublisher{}
+	service := NewDeliveryReportService(testDB, config.DeliveryReports{Enabled: true, Timeout: time.Hour}, publisher)
+	stuck := insertAccepted(t, testDB, "stuck-1", time.Now().Add(-2*time.Hour))
+	recent := insertAccepted(t, testDB, "recent-1", time.Now())
+
+	marked, err := service.MarkUnconfirmed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, marked)
+
+	stored, err := db.GetMessageByID(ctx, testDB, stuck.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusUnconfirmed, stored.Status)
+	stored, err = db.GetMessageByID(ctx, testDB, recent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusAccepted, stored.Status)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, events.TypeUnconfirmed, publisher.events[0].Type)
+	assert.Equal(t, stuck.ID, publisher.events[0].MessageID)
+
+	// a late report still confirms an unconfirmed message
+	response, err := service.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{MessageID: "stuck-1", Status: "delivered"})
+	require.NoError(t, err)
+	assert.True(t, response.Applied)
+	assert.Equal(t, "delivered", response.MessageStatus)
+}
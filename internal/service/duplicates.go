@@ -0,0 +1,121 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Duplicate report defaults and limits
+const (
+	// DefaultDuplicateLookback is how far back the report looks without from
+	DefaultDuplicateLookback = 24 * time.Hour
+	// DefaultDuplicateWindow is the longest gap between two messages counted as a double-send
+	DefaultDuplicateWindow = 10 * time.Minute
+	DefaultDuplicateLimit  = 100
+	MaxDuplicateLimit      = 1000
+)
+
+var ErrInvalidDuplicateReport = errors.New("invalid duplicate report")
+
+// Duplicates reports the recipients given the same content more than once in [from, to), each message created
+// within window of the one before. to defaults to now and from to DefaultDuplicateLookback before to. At most
+// limit recipient and content pairs are examined, the ones repeated last first.
+func (s *MessageService) Duplicates(ctx context.Context, from, to *time.Time, window time.Duration, limit int) (*dto.DuplicatesResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.Duplicates")
+	defer span.End()
+
+	end := time.Now().UTC()
+	if to != nil {
+		end = to.UTC()
+	}
+	start := end.Add(-DefaultDuplicateLookback)
+	if from != nil {
+		start = from.UTC()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidDuplicateReport)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window must be positive", ErrInvalidDuplicateReport)
+	}
+	if limit < 1 || limit > MaxDuplicateLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidDuplicateReport, MaxDuplicateLimit)
+	}
+
+	messages, truncated, err := db.ListDuplicateCandidates(ctx, s.db, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.DuplicatesResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		From:          start,
+		To:            end,
+		WindowSeconds: int64(window / time.Second),
+		Groups:        []dto.DuplicateGroup{},
+		Truncated:     truncated,
+	}
+	// the candidates are ordered by recipient, content and creation, a gap over window starts a new group
+	var group []*db.Message
+	flush := func() {
+		if len(group) > 1 {
+			response.Groups = append(response.Groups, convertDuplicateGroup(group))
+			response.Duplicates += len(group) - 1
+		}
+		group = nil
+	}
+	for _, msg := range messages {
+		if len(group) > 0 {
+			last := group[len(group)-1]
+			if last.To != msg.To || last.Content != msg.Content || msg.CreatedAt.Sub(last.CreatedAt) > window {
+				flush()
+			}
+		}
+		group = append(group, msg)
+	}
+	flush()
+
+	slices.SortStableFunc(response.Groups, func(a, b dto.DuplicateGroup) int {
+		return cmp.Compare(b.LastAt.UnixNano(), a.LastAt.UnixNano())
+	})
+	return response, nil
+}
+
+func convertDuplicateGroup(messages []*db.Message) dto.DuplicateGroup {
+	group := dto.DuplicateGroup{
+		To:       messages[0].To,
+		Content:  messages[0].Content,
+		Count:    len(messages),
+		FirstAt:  messages[0].CreatedAt,
+		LastAt:   messages[len(messages)-1].CreatedAt,
+		Messages: make([]dto.DuplicateMessage, len(messages)),
+	}
+	for i, msg := range messages {
+		// messages are looked up by their public ID when they have one
+		ref := strconv.FormatInt(msg.ID, 10)
+		if msg.PublicID != "" {
+			ref = msg.PublicID
+		}
+		group.Messages[i] = dto.DuplicateMessage{
+			ID:        shownMessageID(msg.ID, msg.PublicID),
+			PublicID:  msg.PublicID,
+			Status:    string(msg.Status),
+			CreatedAt: msg.CreatedAt,
+			SentAt:    msg.SentAt,
+			Link:      "/api/v1/messages/" + ref,
+		}
+	}
+	return group
+}
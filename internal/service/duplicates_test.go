@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageService_Duplicates(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	messages := []*db.Message{
+		// sent twice within a minute, a third time hours later
+		{To: "+905551111111", Content: "Your order shipped", Status: db.MessageStatusSent, CreatedAt: now.Add(-3 * time.Hour)},
+		{To: "+905551111111", Content: "Your order shipped", Status: db.MessageStatusSent, CreatedAt: now.Add(-3*time.Hour + time.Minute)},
+		{To: "+905551111111", Content: "Your order shipped", Status: db.MessageStatusPending, CreatedAt: now.Add(-time.Hour)},
+		// the same content to another recipient and another content to the same one are not duplicates
+		{To: "+905552222222", Content: "Your order shipped", Status: db.MessageStatusSent, CreatedAt: now.Add(-3 * time.Hour)},
+		{To: "+905551111111", Content: "Your order arrived", Status: db.MessageStatusSent, CreatedAt: now.Add(-3 * time.Hour)},
+		// a double-send before the report window
+		{To: "+905553333333", Content: "Welcome", Status: db.MessageStatusSent, CreatedAt: now.Add(-48 * time.Hour)},
+		{To: "+905553333333", Content: "Welcome", Status: db.MessageStatusSent, CreatedAt: now.Add(-48 * time.Hour)},
+		{To: "+905554444444", Content: "Code 1234", Status: db.MessageStatusSent, CreatedAt: now.Add(-2 * time.Hour)},
+		{To: "+905554444444", Content: "Code 1234", Status: db.MessageStatusFailed, CreatedAt: now.Add(-2*time.Hour + time.Second)},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+	report, err := service.Duplicates(ctx, nil, nil, DefaultDuplicateWindow, DefaultDuplicateLimit)
+	require.NoError(t, err)
+
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, 2, report.Duplicates)
+	assert.False(t, report.Truncated)
+	assert.Equal(t, "+905554444444", report.Groups[0].To, "the groups created last come first")
+	group := report.Groups[1]
+	assert.Equal(t, "+905551111111", group.To)
+	assert.Equal(t, "Your order shipped", group.Content)
+	require.Len(t, group.Messages, 2, "the message an hour later is outside the window")
+	assert.Equal(t, messages[0].ID, group.Messages[0].ID)
+	assert.Equal(t, "/api/v1/messages/"+strconv.FormatInt(messages[1].ID, 10), group.Messages[1].Link)
+
+	t.Run("wider window", func(t *testing.T) {
+		report, err := service.Duplicates(ctx, nil, nil, 3*time.Hour, DefaultDuplicateLimit)
+		require.NoError(t, err)
+		require.Len(t, report.Groups, 2)
+		assert.Equal(t, 3, report.Groups[0].Count)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		report, err := service.Duplicates(ctx, nil, nil, DefaultDuplicateWindow, 1)
+		require.NoError(t, err)
+		require.Len(t, report.Groups, 1)
+		assert.Equal(t, "+905551111111", report.Groups[0].To, "the pair repeated last is examined")
+		assert.True(t, report.Truncated)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		from, to := now, now.Add(-time.Hour)
+		for _, tc := range []struct {
+			from, to *time.Time
+			window   time.Duration
+			limit    int
+		}{
+			{from: &from, to: &to, window: time.Minute, limit: 10},
+			{window: 0, limit: 10},
+			{window: time.Minute, limit: MaxDuplicateLimit + 1},
+		} {
+			_, err := service.Duplicates(ctx, tc.from, tc.to, tc.window, tc.limit)
+			assert.True(t, errors.Is(err, ErrInvalidDuplicateReport), "%+v: %v", tc, err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// ErasureRequestedByCLI is the requester of the erasures run from the command line
+const ErasureRequestedByCLI = "cli"
+
+var ErrInvalidErasure = errors.New("invalid erasure")
+
+// ErasureInterface defines the erasure of the data of a phone number
+type ErasureInterface interface {
+	Erase(ctx context.Context, req *dto.ErasureRequest, requestedBy string) (*dto.ErasureResponse, error)
+	ListErasures(ctx context.Context, phone string, page, pageSize int) (*dto.ErasuresListResponse, error)
+}
+
+// ErasureService erases the messages and tracked links of a phone number and keeps an audit record of it
+type ErasureService struct {
+	db *bun.DB
+}
+
+func NewErasureService(database *bun.DB) *ErasureService {
+	return &ErasureService{db: database}
+}
+
+// Erase deletes or anonymizes the messages to the number of req and deletes the tracked links in them,
+// in one transaction with the audit record. The suppression of the number is only lifted with
+// IncludeSuppression, so an opt-out outlives the erasure.
+func (s *ErasureService) Erase(ctx context.Context, req *dto.ErasureRequest, requestedBy string) (*dto.ErasureResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "ErasureService.Erase")
+	defer span.End()
+
+	if err := db.ValidatePhone(req.Phone); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidErasure, err)
+	}
+	if req.Phone == db.ErasedPhone {
+		return nil, fmt.Errorf("%w: %s replaces the recipient of anonymized messages", ErrInvalidErasure, db.ErasedPhone)
+	}
+	mode := db.ErasureMode(req.Mode)
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("%w: mode must be delete or anonymize", ErrInvalidErasure)
+	}
+	if len(req.Reference) > db.MaxErasureReferenceLength {
+		return nil, fmt.Errorf("%w: reference exceeds %d characters", ErrInvalidErasure, db.MaxErasureReferenceLength)
+	}
+
+	response := &dto.ErasureResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		DryRun: req.DryRun,
+	}
+
+	if req.DryRun {
+		messages, links, err := db.CountErasable(ctx, s.db, req.Phone)
+		if err != nil {
+			return nil, err
+		}
+		suppressed, err := db.IsSuppressed(ctx, s.db, req.Phone)
+		if err != nil {
+			return nil, err
+		}
+		response.Messages, response.Links, response.Suppressed = messages, links, suppressed
+		return response, nil
+	}
+
+	erasure := &db.Erasure{
+		PhoneHash:   db.HashPhone(req.Phone),
+		Mode:        mode,
+		Reference:   req.Reference,
+		RequestedBy: requestedBy,
+	}
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if erasure.Messages, erasure.Links, err = db.EraseMessages(ctx, tx, req.Phone, mode); err != nil {
+			return err
+		}
+
+		if req.IncludeSuppression {
+			if erasure.Suppression, err = db.RemoveSuppression(ctx, tx, req.Phone, ""); err != nil {
+				return err
+			}
+		} else if response.Suppressed, err = db.IsSuppressed(ctx, tx, req.Phone); err != nil {
+			return err
+		}
+
+		return db.CreateErasure(ctx, tx, erasure)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record := convertToErasureRecord(erasure)
+	response.Messages, response.Links, response.Erasure = erasure.Messages, erasure.Links, &record
+	return response, nil
+}
+
+// ListErasures retrieves paginated erasure records, newest first. phone limits them to the records of a number.
+func (s *ErasureService) ListErasures(ctx context.Context, phone string, page, pageSize int) (*dto.ErasuresListResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "ErasureService.ListErasures")
+	defer span.End()
+
+	page, pageSize, err := normalizePagination(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var phoneHash string
+	if phone != "" {
+		phoneHash = db.HashPhone(phone)
+	}
+	erasures, err := db.ListErasures(ctx, s.db, phoneHash, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]dto.ErasureRecord, len(erasures))
+	for i, erasure := range erasures {
+		records[i] = convertToErasureRecord(erasure)
+	}
+
+	return &dto.ErasuresListResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Erasures: records,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func convertToErasureRecord(erasure *db.Erasure) dto.ErasureRecord {
+	return dto.ErasureRecord{
+		ID:          erasure.ID,
+		PhoneHash:   erasure.PhoneHash,
+		Mode:        string(erasure.Mode),
+		Messages:    erasure.Messages,
+		Links:       erasure.Links,
+		Suppression: erasure.Suppression,
+		Reference:   erasure.Reference,
+		RequestedBy: erasure.RequestedBy,
+		CreatedAt:   erasure.CreatedAt,
+	}
+}
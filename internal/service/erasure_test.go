@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErasureService_Erase(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Your code is 1234", Status: db.MessageStatusSent, Campaign: "otp",
+			Metadata: db.Metadata{"customer_id": "c-1"}},
+		{To: "+905551111111", Content: "Sale", Status: db.MessageStatusPending},
+		{To: "+905552222222", Content: "Sale", Status: db.MessageStatusSent},
+		{To: "+905553333333", Content: "Sale", Status: db.MessageStatusSent},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.CreateLinks(ctx, testDB, []*db.Link{
+		{Code: "erase1", URL: "https://example.com/c-1"},
+		{Code: "keep1", URL: "https://example.com/sale"},
+	}))
+	require.NoError(t, db.AttachLinks(ctx, testDB, messages[0].ID, []string{"erase1"}))
+	require.NoError(t, db.AttachLinks(ctx, testDB, messages[2].ID, []string{"keep1"}))
+	for _, msg := range []*db.Message{messages[0], messages[2], messages[3]} {
+		require.NoError(t, db.AdvanceCampaignCursor(ctx, testDB, "sale", msg.To, msg.ID))
+	}
+	// cursor returns the recipient the campaign cursor recorded for message, empty when it recorded none
+	cursor := func(message *db.Message) string {
+		var recipients []string
+		require.NoError(t, testDB.NewSelect().Model((*db.CampaignRecipient)(nil)).Column("to").
+			Where("message_id = ?", message.ID).Scan(ctx, &recipients))
+		if len(recipients) == 0 {
+			return ""
+		}
+		return recipients[0]
+	}
+	for _, phone := range []string{"+905551111111", "+905553333333"} {
+		_, err := db.AddSuppression(ctx, testDB, &db.Suppression{Phone: phone, Source: db.SuppressionSourceInbound})
+		require.NoError(t, err)
+	}
+
+	service := NewErasureService(testDB)
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		for _, req := range []*dto.ErasureRequest{
+			{Phone: "05551111111", Mode: "delete"},
+			{Phone: "+905551111111", Mode: "forget"},
+			{Phone: db.ErasedPhone, Mode: "delete"},
+		} {
+			_, err := service.Erase(ctx, req, "default")
+			assert.ErrorIs(t, err, ErrInvalidErasure)
+		}
+	})
+
+	t.Run("dry run counts", func(t *testing.T) {
+		response, err := service.Erase(ctx, &dto.ErasureRequest{Phone: "+905551111111", Mode: "anonymize", DryRun: true}, "default")
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Messages)
+		assert.Equal(t, 1, response.Links)
+		assert.True(t, response.Suppressed)
+		assert.Nil(t, response.Erasure)
+	})
+
+	t.Run("anonymizes messages and keeps the suppression", func(t *testing.T) {
+		response, err := service.Erase(ctx, &dto.ErasureRequest{Phone: "+905551111111", Mode: "anonymize", Reference: "DSR-1"}, "default")
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Messages)
+		assert.Equal(t, 1, response.Links)
+		assert.True(t, response.Suppressed)
+		require.NotNil(t, response.Erasure)
+		assert.Equal(t, db.HashPhone("+905551111111"), response.Erasure.PhoneHash)
+		assert.Equal(t, "default", response.Erasure.RequestedBy)
+
+		sent, err := db.GetMessageByID(ctx, testDB, messages[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.ErasedPhone, sent.To)
+		assert.Empty(t, sent.Content)
+		assert.Empty(t, sent.Metadata)
+		assert.Equal(t, db.MessageStatusSent, sent.Status)
+		assert.Equal(t, "otp", sent.Campaign)
+		assert.Equal(t, db.ErasedPhone, cursor(messages[0]), "the campaign cursor keeps the message without the number")
+
+		pending, err := db.GetMessageByID(ctx, testDB, messages[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, db.MessageStatusCancelled, pending.Status, "anonymized messages are not sent")
+
+		links, err := db.GetMessageLinks(ctx, testDB, messages[0].ID)
+		require.NoError(t, err)
+		assert.Empty(t, links)
+		links, err = db.GetMessageLinks(ctx, testDB, messages[2].ID)
+		require.NoError(t, err)
+		assert.Len(t, links, 1)
+
+		suppressed, err := db.IsSuppressed(ctx, testDB, "+905551111111")
+		require.NoError(t, err)
+		assert.True(t, suppressed)
+	})
+
+	t.Run("deletes messages and the suppression", func(t *testing.T) {
+		response, err := service.Erase(ctx, &dto.ErasureRequest{Phone: "+905553333333", Mode: "delete", IncludeSuppression: true}, ErasureRequestedByCLI)
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Messages)
+		assert.True(t, response.Erasure.Suppression)
+
+		_, err = db.GetMessageByID(ctx, testDB, messages[3].ID)
+		assert.Error(t, err)
+		suppressed, err := db.IsSuppressed(ctx, testDB, "+905553333333")
+		require.NoError(t, err)
+		assert.False(t, suppressed)
+
+		other, err := db.GetMessageByID(ctx, testDB, messages[2].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "+905552222222", other.To)
+		assert.Empty(t, cursor(messages[3]))
+		assert.Equal(t, "+905552222222", cursor(messages[2]))
+	})
+
+	t.Run("lists the audit records", func(t *testing.T) {
+		response, err := service.ListErasures(ctx, "", 1, 10)
+		require.NoError(t, err)
+		require.Len(t, response.Erasures, 2)
+		assert.Equal(t, "delete", response.Erasures[0].Mode)
+		assert.Equal(t, "DSR-1", response.Erasures[1].Reference)
+
+		response, err = service.ListErasures(ctx, "+905551111111", 1, 10)
+		require.NoError(t, err)
+		require.Len(t, response.Erasures, 1)
+		assert.Equal(t, "anonymize", response.Erasures[0].Mode)
+	})
+}
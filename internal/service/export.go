@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/export"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+var (
+	ErrInvalidExport = errors.New("invalid export")
+	// ErrExportTooLarge is returned when an export matches more than jobs.export.max_messages messages
+	ErrExportTooLarge = errors.New("export matches too many messages")
+)
+
+// SubmitExport accepts an export job writing the messages matching the filters of req to a CSV or JSON lines
+// file, downloaded from the result URL of the job once it succeeded. Exports matching more than
+// jobs.export.max_messages messages are refused with ErrExportTooLarge.
+func (s *JobService) SubmitExport(ctx context.Context, req *dto.ExportRequest) (*dto.JobResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "JobService.SubmitExport")
+	defer span.End()
+
+	params := *req
+	params.Format = cmp.Or(req.Format, export.FormatCSV)
+	if err := export.ValidFormat(params.Format); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExport, err.Error())
+	}
+	filters := query.Filters{
+		Status:       req.Status,
+		From:         req.From,
+		To:           req.To,
+		Tag:          req.Tag,
+		Metadata:     req.Metadata,
+		DeadLettered: req.DeadLettered,
+	}
+	if req.Acknowledged != nil {
+		filters.Acknowledged = strconv.FormatBool(*req.Acknowledged)
+	}
+	filter, err := filters.MessageFilter()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExport, err.Error())
+	}
+
+	total, err := db.CountMessages(ctx, s.db, filter)
+	if err != nil {
+		return nil, err
+	}
+	if total > s.cfg.Export.MaxMessages {
+		return nil, fmt.Errorf("%w: %d messages, jobs.export.max_messages is %d", ErrExportTooLarge, total, s.cfg.Export.MaxMessages)
+	}
+
+	return s.Submit(ctx, db.JobKindExport, params, total, func(ctx context.Context, progress *JobProgress) (*JobOutput, error) {
+		return s.export(ctx, filter, params.Format, progress)
+	})
+}
+
+// export writes the messages matching filter in format, reading them page by page no faster than
+// jobs.export.rows_per_second
+func (s *JobService) export(ctx context.Context, filter db.MessageFilter, format string, progress *JobProgress) (*JobOutput, error) {
+	var body bytes.Buffer
+	writer := export.NewWriter(format, &body)
+
+	var exported int
+	read := time.Now()
+	err := db.ForEachMessagePage(ctx, s.db, filter, s.cfg.Export.PageSize, func(messages []*db.Message) error {
+		for _, msg := range messages {
+			if err := writer.Write(s.messages.convertToMessageResponse(msg)); err != nil {
+				return err
+			}
+		}
+		exported += len(messages)
+		progress.Add(ctx, len(messages))
+
+		if err := pace(ctx, read, len(messages), s.cfg.Export.RowsPerSecond); err != nil {
+			return err
+		}
+		read = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return &JobOutput{
+		Summary:     fmt.Sprintf("Exported %d messages", exported),
+		ContentType: export.ContentType(format),
+		Body:        body.Bytes(),
+	}, nil
+}
+
+// pace waits until rows read since started took at least rows/perSecond, it returns right away when perSecond is
+// 0 and early when ctx is cancelled
+func pace(ctx context.Context, started time.Time, rows int, perSecond float64) error {
+	if perSecond <= 0 {
+		return nil
+	}
+	wait := time.Duration(float64(rows)/perSecond*float64(time.Second)) - time.Since(started)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/routing"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// Forecast limits
+const (
+	// ForecastHorizon is how far ahead the forecast follows the scheduler
+	ForecastHorizon = 30 * 24 * time.Hour
+	// maxForecastTicks bounds the batches simulated with short intervals
+	maxForecastTicks = 1_000_000
+)
+
+var ErrInvalidForecast = errors.New("invalid forecast")
+
+// ForecastOptions replace the messaging settings of a forecast, the zero value uses the configured ones
+type ForecastOptions struct {
+	Interval  time.Duration
+	BatchSize int
+	// RateLimit limits every route to the messages per second, 0 leaves them unlimited and nil keeps the
+	// configured route rate limits
+	RateLimit *float64
+}
+
+// Forecast estimates when the pending messages are sent by a scheduler running cfg with the settings of opts,
+// per campaign and in total. It follows the batches of the scheduler from now on: every interval the batch size
+// messages are claimed by priority and age, throttled campaigns and tenants are deferred once their profile has
+// no slot before the next batch, and route rate limits space the sends of a batch. Sends are assumed to succeed
+// right away and no more messages to arrive, the backlog of several schedulers sharing the database drains faster.
+func (s *MessageService) Forecast(ctx context.Context, cfg *config.Cfg, opts ForecastOptions) (*dto.ForecastResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.Forecast")
+	defer span.End()
+
+	forecastCfg := *cfg
+	if opts.Interval < 0 || opts.BatchSize < 0 {
+		return nil, fmt.Errorf("%w: interval and batch_size must be positive", ErrInvalidForecast)
+	}
+	if opts.Interval > 0 {
+		forecastCfg.Messaging.Interval = opts.Interval
+	}
+	if opts.BatchSize > 0 {
+		forecastCfg.Messaging.BatchSize = opts.BatchSize
+	}
+	if opts.RateLimit != nil {
+		if *opts.RateLimit < 0 {
+			return nil, fmt.Errorf("%w: rate_limit must not be negative", ErrInvalidForecast)
+		}
+		forecastCfg.Routing.Routes = slices.Clone(cfg.Routing.Routes)
+		for i := range forecastCfg.Routing.Routes {
+			forecastCfg.Routing.Routes[i].RateLimit = *opts.RateLimit
+		}
+		forecastCfg.Routing.Default.RateLimit = *opts.RateLimit
+	}
+
+	// the recipients are grouped by as much of their number as the longest route prefix
+	prefixLen := 0
+	for _, route := range forecastCfg.Routing.Routes {
+		prefixLen = max(prefixLen, len(route.Prefix))
+	}
+	now := time.Now().UTC()
+	groups, err := db.GetBacklog(ctx, s.db, prefixLen, now)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := simulateForecast(groups, &forecastCfg, now)
+	response := &dto.ForecastResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: now,
+		},
+		Interval:  forecastCfg.Messaging.Interval.String(),
+		BatchSize: forecastCfg.Messaging.BatchSize,
+		RateLimit: opts.RateLimit,
+		Horizon:   forecast.horizon,
+		Campaigns: []dto.CampaignForecast{},
+	}
+
+	campaigns := make(map[string]*dto.CampaignForecast)
+	drained := make(map[string]bool)
+	allDrained := true
+	var last time.Time
+	for i, group := range groups {
+		campaign, ok := campaigns[group.Campaign]
+		if !ok {
+			campaign = &dto.CampaignForecast{Campaign: group.Campaign}
+			campaigns[group.Campaign] = campaign
+			drained[group.Campaign] = true
+		}
+		campaign.Pending += group.Count
+		response.Pending += group.Count
+		if group.DueAt != nil {
+			campaign.Scheduled += group.Count
+			response.Scheduled += group.Count
+		}
+
+		sentAt := forecast.drainedAt[i]
+		if sentAt.IsZero() {
+			drained[group.Campaign], allDrained = false, false
+			continue
+		}
+		if campaign.DrainAt == nil || sentAt.After(*campaign.DrainAt) {
+			campaign.DrainAt = &sentAt
+		}
+		if sentAt.After(last) {
+			last = sentAt
+		}
+	}
+
+	for name, campaign := range campaigns {
+		if !drained[name] {
+			campaign.DrainAt = nil
+		} else {
+			campaign.DrainSeconds = drainSeconds(now, *campaign.DrainAt)
+		}
+		response.Campaigns = append(response.Campaigns, *campaign)
+	}
+	// the campaigns draining last come first, the ones past the horizon before them
+	slices.SortFunc(response.Campaigns, func(a, b dto.CampaignForecast) int {
+		if (a.DrainAt == nil) != (b.DrainAt == nil) {
+			if a.DrainAt == nil {
+				return -1
+			}
+			return 1
+		}
+		if a.DrainAt != nil && !a.DrainAt.Equal(*b.DrainAt) {
+			return b.DrainAt.Compare(*a.DrainAt)
+		}
+		return cmp.Compare(a.Campaign, b.Campaign)
+	})
+
+	if allDrained && response.Pending > 0 {
+		response.DrainAt = &last
+		response.DrainSeconds = drainSeconds(now, last)
+	}
+	if elapsed := forecast.end.Sub(now).Seconds(); elapsed > 0 {
+		response.MessagesPerSecond = float64(forecast.sent) / elapsed
+	}
+	return response, nil
+}
+
+func drainSeconds(now, at time.Time) *int64 {
+	seconds := int64(at.Sub(now).Round(time.Second) / time.Second)
+	return &seconds
+}
+
+// forecastResult is the outcome of simulateForecast
+type forecastResult struct {
+	// drainedAt are the times the last messages of the groups are sent, zero for the groups not drained by horizon
+	drainedAt []time.Time
+	sent      int
+	// end is when the last message was sent, or horizon when the backlog was not drained
+	end     time.Time
+	horizon time.Time
+}
+
+// forecastGroup is a backlog group being sent by simulateForecast
+type forecastGroup struct {
+	index    int
+	priority int
+	oldestAt time.Time
+	dueAt    time.Time
+	// waitingSince is when the priority aging of the group starts, its schedule or its oldest message
+	waitingSince time.Time
+	remaining    int
+	// deferred messages were claimed while their throttle had no slot, they are claimed again from deferredUntil
+	deferred      int
+	deferredUntil time.Time
+
+	route            string
+	routeInterval    time.Duration
+	throttle         string
+	throttleInterval time.Duration
+}
+
+// claimable returns the messages of the group the scheduler would claim at t
+func (g *forecastGroup) claimable(t time.Time) int {
+	if g.dueAt.After(t) {
+		return 0
+	}
+	if g.deferred > 0 && !g.deferredUntil.After(t) {
+		g.deferred = 0
+	}
+	return g.remaining - g.deferred
+}
+
+// nextClaim returns when messages of the group are claimable next
+func (g *forecastGroup) nextClaim() time.Time {
+	if g.remaining > g.deferred {
+		return g.dueAt
+	}
+	return later(g.dueAt, g.deferredUntil)
+}
+
+// simulateForecast follows the batches of a scheduler running cfg from now until groups are sent or the horizon
+func simulateForecast(groups []db.BacklogGroup, cfg *config.Cfg, now time.Time) forecastResult {
+	interval, batchSize := cfg.Messaging.Interval, cfg.Messaging.BatchSize
+	router, throttles := routing.New(cfg), routing.NewThrottles(cfg)
+	horizon := ForecastHorizon
+	if interval < ForecastHorizon/maxForecastTicks {
+		horizon = interval * maxForecastTicks
+	}
+	result := forecastResult{
+		drainedAt: make([]time.Time, len(groups)),
+		horizon:   now.Add(horizon),
+	}
+
+	active := make([]*forecastGroup, 0, len(groups))
+	for i, group := range groups {
+		route := router.Route(group.Prefix)
+		g := &forecastGroup{
+			index:         i,
+			priority:      group.Priority,
+			oldestAt:      group.OldestAt,
+			dueAt:         now,
+			remaining:     group.Count,
+			route:         route.Prefix,
+			routeInterval: route.Interval(),
+		}
+		g.waitingSince = g.oldestAt
+		if group.DueAt != nil {
+			g.dueAt = *group.DueAt
+			g.waitingSince = g.dueAt
+		}
+		g.throttle, g.throttleInterval = throttles.Interval(group.Tenant, group.Campaign)
+		active = append(active, g)
+	}
+	// messages are claimed by priority raised by aging, then the oldest first
+	aging := queue.PriorityAging(cfg.Queue.PriorityAging)
+	sortClaims := func(t time.Time) {
+		slices.SortStableFunc(active, func(a, b *forecastGroup) int {
+			ap, bp := float64(a.priority)+aging.Boost(t.Sub(a.waitingSince)), float64(b.priority)+aging.Boost(t.Sub(b.waitingSince))
+			if ap != bp {
+				return cmp.Compare(bp, ap)
+			}
+			return a.oldestAt.Compare(b.oldestAt)
+		})
+	}
+	sortClaims(now)
+
+	routeNext := make(map[string]time.Time)
+	throttleNext := make(map[string]time.Time)
+	result.end = now
+	// a started scheduler claims its first batch an interval later
+	t := now.Add(interval)
+	for len(active) > 0 && !t.After(result.horizon) {
+		if aging.Interval > 0 {
+			sortClaims(t)
+		}
+		capacity := batchSize
+		batchEnd := t
+		for _, g := range active {
+			if capacity == 0 {
+				break
+			}
+			claimed := min(g.claimable(t), capacity)
+			if claimed == 0 {
+				continue
+			}
+			capacity -= claimed
+
+			sent, sentAt := claimed, t
+			if g.throttleInterval > 0 {
+				// slots starting before the next batch are waited for, the other messages are deferred
+				start := later(throttleNext[g.throttle], t)
+				slots := 0
+				if !start.After(t.Add(interval)) {
+					slots = int(t.Add(interval).Sub(start)/g.throttleInterval) + 1
+				}
+				sent = min(claimed, slots)
+				if sent > 0 {
+					sentAt = start.Add(time.Duration(sent-1) * g.throttleInterval)
+					throttleNext[g.throttle] = start.Add(time.Duration(sent) * g.throttleInterval)
+				}
+				if deferred := claimed - sent; deferred > 0 {
+					if g.deferred == 0 {
+						g.deferredUntil = later(throttleNext[g.throttle], t)
+					}
+					g.deferred += deferred
+				}
+			}
+			if sent > 0 && g.routeInterval > 0 {
+				start := later(routeNext[g.route], t)
+				sentAt = later(sentAt, start.Add(time.Duration(sent-1)*g.routeInterval))
+				routeNext[g.route] = start.Add(time.Duration(sent) * g.routeInterval)
+			}
+			if sent == 0 {
+				continue
+			}
+
+			g.remaining -= sent
+			result.sent += sent
+			batchEnd = later(batchEnd, sentAt)
+			if g.remaining == 0 {
+				result.drainedAt[g.index] = sentAt
+			}
+		}
+		result.end = later(result.end, batchEnd)
+
+		active = slices.DeleteFunc(active, func(g *forecastGroup) bool { return g.remaining == 0 })
+		t = nextBatch(cfg.Messaging.OverlapPolicy, t, batchEnd, interval)
+		// skip the ticks without claimable messages
+		if len(active) > 0 {
+			next := active[0].nextClaim()
+			for _, g := range active[1:] {
+				if claim := g.nextClaim(); claim.Before(next) {
+					next = claim
+				}
+			}
+			if next.After(t) {
+				t = t.Add((next.Sub(t) + interval - 1) / interval * interval)
+			}
+		}
+	}
+	if len(active) > 0 {
+		result.end = result.horizon
+	}
+	return result
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// nextBatch returns when the batch after the one started at t and ending at end starts with the overlap policy
+func nextBatch(policy string, t, end time.Time, interval time.Duration) time.Time {
+	next := t.Add(interval)
+	if !end.After(next) {
+		return next
+	}
+	switch policy {
+	case config.OverlapQueue:
+		return end
+	case config.OverlapConcurrent:
+		return next
+	default:
+		// the ticks while the batch runs are skipped
+		return t.Add((end.Sub(t) + interval) / interval * interval)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageService_Forecast(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	later := now.Add(2 * time.Hour)
+	var messages []*db.Message
+	for i := 0; i < 10; i++ {
+		messages = append(messages, &db.Message{To: "+905551111111", Content: "Spring sale", Status: db.MessageStatusPending,
+			Campaign: "spring-sale", CreatedAt: now.Add(-time.Hour)})
+	}
+	messages = append(messages,
+		// prioritized messages are claimed before the older campaign
+		&db.Message{To: "+905552222222", Content: "Code 1234", Status: db.MessageStatusPending, Priority: 1, CreatedAt: now},
+		&db.Message{To: "+905552222222", Content: "Code 5678", Status: db.MessageStatusPending, Priority: 1, CreatedAt: now},
+		&db.Message{To: "+905553333333", Content: "Reminder", Status: db.MessageStatusPending, Campaign: "reminders",
+			ScheduledAt: &later, CreatedAt: now},
+		&db.Message{To: "+905553333333", Content: "Sent", Status: db.MessageStatusSent, Campaign: "spring-sale", CreatedAt: now},
+	)
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	cfg := &config.Cfg{Messaging: config.Messaging{Interval: time.Minute, BatchSize: 5}}
+	service := NewMessageService(testDB)
+	campaign := func(t *testing.T, forecast *dto.ForecastResponse, name string) dto.CampaignForecast {
+		for _, campaign := range forecast.Campaigns {
+			if campaign.Campaign == name {
+				return campaign
+			}
+		}
+		t.Fatalf("campaign %q is not forecast", name)
+		return dto.CampaignForecast{}
+	}
+
+	t.Run("configured", func(t *testing.T) {
+		forecast, err := service.Forecast(ctx, cfg, ForecastOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "1m0s", forecast.Interval)
+		assert.Equal(t, 5, forecast.BatchSize)
+		assert.Equal(t, 13, forecast.Pending)
+		assert.Equal(t, 1, forecast.Scheduled)
+		require.Len(t, forecast.Campaigns, 3)
+		assert.Equal(t, "reminders", forecast.Campaigns[0].Campaign, "the campaigns draining last come first")
+
+		// 2 prioritized messages and 3 of the campaign in the first batch, 5 in the second and 2 in the third
+		assert.Equal(t, int64(60), *campaign(t, forecast, "").DrainSeconds)
+		sale := campaign(t, forecast, "spring-sale")
+		assert.Equal(t, 10, sale.Pending)
+		assert.Equal(t, int64(180), *sale.DrainSeconds)
+
+		reminders := campaign(t, forecast, "reminders")
+		assert.Equal(t, 1, reminders.Scheduled)
+		assert.InDelta(t, (2 * time.Hour).Seconds(), float64(*reminders.DrainSeconds), 120, "scheduled messages are sent once due")
+		require.NotNil(t, forecast.DrainAt)
+		assert.Equal(t, *reminders.DrainSeconds, *forecast.DrainSeconds)
+	})
+
+	t.Run("priority aging", func(t *testing.T) {
+		aged := *cfg
+		aged.Queue.PriorityAging = config.PriorityAging{Interval: 30 * time.Minute, Curve: db.AgingLinear}
+		forecast, err := service.Forecast(ctx, &aged, ForecastOptions{})
+		require.NoError(t, err)
+
+		// the campaign waited for an hour, 2 levels above the prioritized messages
+		assert.Equal(t, int64(120), *campaign(t, forecast, "spring-sale").DrainSeconds)
+		assert.Equal(t, int64(180), *campaign(t, forecast, "").DrainSeconds)
+	})
+
+	t.Run("what if", func(t *testing.T) {
+		forecast, err := service.Forecast(ctx, cfg, ForecastOptions{BatchSize: 20})
+		require.NoError(t, err)
+		assert.Equal(t, int64(60), *campaign(t, forecast, "spring-sale").DrainSeconds, "a larger batch sends the campaign at once")
+
+		// a message every 10s through the route, the campaign follows the 2 prioritized messages
+		rateLimit := 0.1
+		forecast, err = service.Forecast(ctx, cfg, ForecastOptions{BatchSize: 20, RateLimit: &rateLimit})
+		require.NoError(t, err)
+		assert.Equal(t, int64(60+110), *campaign(t, forecast, "spring-sale").DrainSeconds)
+		assert.Equal(t, &rateLimit, forecast.RateLimit)
+	})
+
+	t.Run("throttled", func(t *testing.T) {
+		throttled := *cfg
+		throttled.Messaging.BatchSize = 20
+		throttled.Throttling = config.Throttling{
+			Profiles:    []config.ThrottleProfile{{Name: "gentle", RateLimit: 1.0 / 30}},
+			Assignments: []config.ThrottleAssignment{{Campaign: "spring-sale", Profile: "gentle"}},
+		}
+		forecast, err := service.Forecast(ctx, &throttled, ForecastOptions{})
+		require.NoError(t, err)
+		// 3 slots of the profile per batch, the messages deferred past the next batch are claimed by the one after
+		assert.Equal(t, int64(420), *campaign(t, forecast, "spring-sale").DrainSeconds)
+		assert.Equal(t, int64(60), *campaign(t, forecast, "").DrainSeconds)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := service.Forecast(ctx, cfg, ForecastOptions{BatchSize: -1})
+		assert.ErrorIs(t, err, ErrInvalidForecast)
+		rateLimit := -1.0
+		_, err = service.Forecast(ctx, cfg, ForecastOptions{RateLimit: &rateLimit})
+		assert.ErrorIs(t, err, ErrInvalidForecast)
+	})
+}
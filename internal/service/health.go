@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// ReadinessCheckTimeout bounds each dependency check of a readiness probe
+const ReadinessCheckTimeout = 2 * time.Second
+
+// HealthInterface defines readiness checks of the service dependencies
+type HealthInterface interface {
+	Ready(ctx context.Context) *dto.ReadinessResponse
+	// Warmup prepares the connections and caches of a freshly started instance
+	Warmup(ctx context.Context) *dto.WarmupResponse
+	// DatabaseUp reports whether the database is reachable
+	DatabaseUp(ctx context.Context) bool
+}
+
+type HealthService struct {
+	db *bun.DB
+	// availability answers DatabaseUp without a ping when set
+	availability *DatabaseAvailability
+	// scheduler is warmed up along with the database, nil skips the webhook overrides and providers
+	scheduler *Scheduler
+}
+
+func NewHealthService(database *bun.DB) *HealthService {
+	return &HealthService{
+		db: database,
+	}
+}
+
+// SetAvailability makes the health checks use and update the tracked database availability
+func (s *HealthService) SetAvailability(availability *DatabaseAvailability) {
+	s.availability = availability
+}
+
+// SetScheduler makes the warmups load the webhook overrides of scheduler and connect to its providers
+func (s *HealthService) SetScheduler(scheduler *Scheduler) {
+	s.scheduler = scheduler
+}
+
+// DatabaseUp reports whether the database is reachable, pinging it unless its availability is tracked
+func (s *HealthService) DatabaseUp(ctx context.Context) bool {
+	if s.availability != nil {
+		return s.availability.Up()
+	}
+	return s.pingDatabase(ctx) == nil
+}
+
+func (s *HealthService) pingDatabase(ctx context.Context) error {
+	cctx, cancel := context.WithTimeout(ctx, ReadinessCheckTimeout)
+	defer cancel()
+
+	err := s.db.PingContext(cctx)
+	if s.availability != nil {
+		s.availability.set(err == nil, err)
+	}
+	return err
+}
+
+// Ready checks every dependency needed to serve traffic
+// The response status is "ok" only if all checks pass
+func (s *HealthService) Ready(ctx context.Context) *dto.ReadinessResponse {
+	response := &dto.ReadinessResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Checks: map[string]string{},
+	}
+
+	if err := s.pingDatabase(ctx); err != nil {
+		response.Status = "error"
+		response.Checks["database"] = err.Error()
+	} else {
+		response.Checks["database"] = "ok"
+	}
+
+	return response
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthService_Ready(t *testing.T) {
+	testDB := setupTestDB(t)
+
+	service := NewHealthService(testDB)
+
+	t.Run("database reachable", func(t *testing.T) {
+		response := service.Ready(context.Background())
+
+		assert.Equal(t, "ok", response.Status)
+		assert.Equal(t, "ok", response.Checks["database"])
+	})
+
+	t.Run("database closed", func(t *testing.T) {
+		testDB.Close()
+
+		response := service.Ready(context.Background())
+
+		assert.Equal(t, "error", response.Status)
+		assert.NotEqual(t, "ok", response.Checks["database"])
+	})
+}
+
+func TestHealthService_Warmup(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	var heads atomic.Int32
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		heads.Add(1)
+	}))
+	defer provider.Close()
+
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{URL: provider.URL},
+		Routing: config.Routing{Providers: []config.Provider{
+			{Name: "acme", URL: provider.URL},
+			{Name: "down", URL: "http://127.0.0.1:1/unreachable"},
+			{Name: config.SandboxProvider},
+		}},
+	}
+	service := NewHealthService(testDB)
+	service.SetScheduler(NewScheduler(testDB, cfg))
+
+	response := service.Warmup(context.Background())
+
+	assert.Equal(t, "error", response.Status, "an unreachable provider fails the warmup")
+	assert.Equal(t, "ok", response.Checks["database"])
+	assert.Equal(t, "ok", response.Checks["webhook_overrides"])
+	assert.Equal(t, "ok", response.Checks["provider:webhook"])
+	assert.Equal(t, "ok", response.Checks["provider:acme"])
+	assert.NotEqual(t, "ok", response.Checks["provider:down"])
+	assert.NotContains(t, response.Checks, "provider:"+config.SandboxProvider, "the sandbox is served in-process")
+	assert.Equal(t, int32(2), heads.Load())
+}
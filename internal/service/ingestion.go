@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrInvalidIngestion = errors.New("invalid ingestion payload")
+	// ErrIngestionTooLarge is returned when a payload carries more than ingestion.max_messages messages
+	ErrIngestionTooLarge = errors.New("ingestion payload has too many messages")
+	// ErrIngestionBusy is returned when ingestion.max_queued jobs already wait for a worker
+	ErrIngestionBusy        = errors.New("too many ingestion jobs are waiting, retry later")
+	ErrIngestionJobNotFound = errors.New("ingestion job not found")
+)
+
+// ingestionInterrupted is the error of the jobs stopped or never started because the instance shut down
+const ingestionInterrupted = "interrupted by the shutdown of the instance"
+
+// IngestionInterface defines asynchronous ingestion jobs
+type IngestionInterface interface {
+	Submit(ctx context.Context, payload []byte) (*dto.IngestionJobResponse, error)
+	Job(ctx context.Context, id string) (*dto.IngestionJobResponse, error)
+}
+
+// IngestionService validates and enqueues large payloads of messages in the background. Jobs are processed by the
+// instance that accepted them, their progress is kept in the ingestion_jobs table so any instance reports it.
+type IngestionService struct {
+	db     *bun.DB
+	queue  queue.Queue
+	cfg    config.Ingestion
+	holder string
+	// slots bounds the accepted jobs not finished yet, jobs hands them to the workers
+	slots chan struct{}
+	jobs  chan *ingestionJob
+}
+
+type ingestionJob struct {
+	job     *db.IngestionJob
+	payload []byte
+}
+
+// NewIngestionService creates an ingestion service enqueueing the messages to q, the jobs are processed once
+// Run is called
+func NewIngestionService(database *bun.DB, q queue.Queue, cfg config.Ingestion) *IngestionService {
+	capacity := cfg.Workers + cfg.MaxQueued
+	return &IngestionService{
+		db:     database,
+		queue:  q,
+		cfg:    cfg,
+		holder: leaseHolder(),
+		slots:  make(chan struct{}, capacity),
+		jobs:   make(chan *ingestionJob, capacity),
+	}
+}
+
+// Submit accepts a JSON array or JSON lines payload of messages as a queued job. Only the size of the payload is
+// checked here, the messages are validated and enqueued by the job. Payloads over ingestion.max_messages are
+// refused with ErrIngestionTooLarge, with every worker busy and ingestion.max_queued jobs waiting with
+// ErrIngestionBusy.
+func (s *IngestionService) Submit(ctx context.Context, payload []byte) (*dto.IngestionJobResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "IngestionService.Submit")
+	defer span.End()
+
+	total, err := ingest.CountJSON(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIngestion, err.Error())
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: no messages", ErrInvalidIngestion)
+	}
+	if total > s.cfg.MaxMessages {
+		return nil, fmt.Errorf("%w: %d messages, ingestion.max_messages is %d", ErrIngestionTooLarge, total, s.cfg.MaxMessages)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil, ErrIngestionBusy
+	}
+
+	job := &db.IngestionJob{
+		Holder: s.holder,
+		APIKey: quota.APIKeyFrom(ctx),
+		Total:  total,
+	}
+	if err := db.CreateIngestionJob(ctx, s.db, job); err != nil {
+		<-s.slots
+		return nil, err
+	}
+	s.jobs <- &ingestionJob{job: job, payload: payload}
+
+	config.LogFrom(ctx).WithField("ingestion_job", job.ID).Infof("Accepted ingestion job of %d messages", total)
+	return convertIngestionJob(job), nil
+}
+
+// Job returns the ingestion job with id and its progress
+func (s *IngestionService) Job(ctx context.Context, id string) (*dto.IngestionJobResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "IngestionService.Job")
+	defer span.End()
+
+	job, err := db.GetIngestionJob(ctx, s.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrIngestionJobNotFound, id)
+		}
+		return nil, err
+	}
+	return convertIngestionJob(job), nil
+}
+
+// Run processes the accepted jobs with ingestion.workers workers until ctx is cancelled. A job running at that
+// point stops after its current batch, it and the jobs still queued are failed as interrupted.
+func (s *IngestionService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range s.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					if ctx.Err() != nil {
+						s.interrupt(ctx, job)
+						continue
+					}
+					s.process(ctx, job)
+					<-s.slots
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case job := <-s.jobs:
+			s.interrupt(ctx, job)
+		default:
+			return
+		}
+	}
+}
+
+// interrupt fails a job that was not started before the shutdown
+func (s *IngestionService) interrupt(ctx context.Context, job *ingestionJob) {
+	job.job.Status = db.IngestionStatusFailed
+	job.job.Error = ingestionInterrupted
+	s.finish(ctx, job.job)
+	<-s.slots
+}
+
+// process validates and enqueues the messages of job in batches, storing its progress after every batch.
+// Rejected rows do not stop the job, a failing enqueue does, the batches enqueued before stay enqueued.
+func (s *IngestionService) process(ctx context.Context, job *ingestionJob) {
+	ctx, span := telemetry.Tracer().Start(ctx, "IngestionService.process")
+	defer span.End()
+
+	log := config.Log().WithField("ingestion_job", job.job.ID)
+	started := time.Now()
+	job.job.Status = db.IngestionStatusRunning
+	job.job.StartedAt = &started
+	if err := db.UpdateIngestionJob(ctx, s.db, job.job); err != nil {
+		log.Errorf("Failed to record the start of the ingestion job: %v", err)
+	}
+
+	importer := ingest.NewImporter(s.db, s.cfg.BatchSize)
+	importer.Enqueue = func(ctx context.Context, messages []*db.Message) error {
+		return s.queue.Enqueue(ctx, messages...)
+	}
+	importer.Rejects = func(err error) bool {
+		return errors.Is(err, ErrContentRejected)
+	}
+	importer.OnRejected = func(rejection ingest.Rejection) {
+		if len(job.job.Rejections) < db.MaxIngestionRejections {
+			job.job.Rejections = append(job.job.Rejections, db.IngestionRejection{Line: rejection.Line, Reason: rejection.Reason})
+		}
+	}
+	importer.OnProgress = func(result ingest.Result) {
+		setIngestionResult(job.job, result)
+		if err := db.UpdateIngestionJob(ctx, s.db, job.job); err != nil {
+			log.Warnf("Failed to record the progress of the ingestion job: %v", err)
+		}
+	}
+
+	// the messages count against the quotas of the API key the payload was submitted with
+	result, err := importer.Import(quota.ContextWithAPIKey(ctx, job.job.APIKey), ingest.NewJSONReader(bytes.NewReader(job.payload)))
+	job.payload = nil
+	if result != nil {
+		setIngestionResult(job.job, *result)
+	}
+	job.job.Status = db.IngestionStatusSucceeded
+	if err != nil {
+		job.job.Status = db.IngestionStatusFailed
+		job.job.Error = err.Error()
+		if ctx.Err() != nil {
+			job.job.Error = ingestionInterrupted
+		}
+		log.Errorf("Ingestion job failed after enqueueing %d of %d messages: %v", job.job.Enqueued, job.job.Total, err)
+	} else {
+		log.Infof("Ingestion job enqueued %d of %d messages in %s, %d rejected", job.job.Enqueued, job.job.Total,
+			time.Since(started).Round(time.Millisecond), job.job.Rejected)
+	}
+	s.finish(ctx, job.job)
+}
+
+// finish records the end of job, also when ctx was cancelled by the shutdown
+func (s *IngestionService) finish(ctx context.Context, job *db.IngestionJob) {
+	telemetry.RecordIngestionJob(job.Status)
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := db.UpdateIngestionJob(ctx, s.db, job); err != nil {
+		config.Log().WithField("ingestion_job", job.ID).Errorf("Failed to record the end of the ingestion job: %v", err)
+	}
+}
+
+func setIngestionResult(job *db.IngestionJob, result ingest.Result) {
+	job.Read = result.Read
+	job.Enqueued = result.Imported
+	job.Duplicates = result.Duplicates
+	job.Rejected = result.Rejected
+}
+
+func convertIngestionJob(job *db.IngestionJob) *dto.IngestionJobResponse {
+	response := &dto.IngestionJobResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		ID:         job.ID,
+		State:      job.Status,
+		Total:      job.Total,
+		Read:       job.Read,
+		Enqueued:   job.Enqueued,
+		Duplicates: job.Duplicates,
+		Rejected:   job.Rejected,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	for _, rejection := range job.Rejections {
+		response.Rejections = append(response.Rejections, dto.IngestionRejection{Line: rejection.Line, Reason: rejection.Reason})
+	}
+	return response
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/policy"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestionService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	engine, err := policy.New(config.ContentPolicy{Rules: []config.ContentRule{
+		{Name: "gambling", Type: config.ContentRuleBannedTerms, Action: config.ContentActionReject, Terms: []string{"casino"}},
+	}})
+	require.NoError(t, err)
+	cfg := config.Ingestion{MaxMessages: 10, Workers: 1, MaxQueued: 1, BatchSize: 2}
+	service := NewIngestionService(testDB, policy.NewQueue(queue.NewPostgres(testDB), engine), cfg)
+	ctx := context.Background()
+
+	t.Run("refused payloads", func(t *testing.T) {
+		_, err := service.Submit(ctx, []byte(`[{"to": "+905551111111"`))
+		assert.True(t, errors.Is(err, ErrInvalidIngestion))
+		_, err = service.Submit(ctx, []byte(`[]`))
+		assert.True(t, errors.Is(err, ErrInvalidIngestion))
+		_, err = service.Submit(ctx, []byte("["+strings.Repeat(`{"to": "+905551111111", "content": "Hi"},`, 10)+`{}]`))
+		assert.True(t, errors.Is(err, ErrIngestionTooLarge))
+	})
+
+	payload := `{"to": "+905551111111", "content": "Order shipped"}
+{"to": "+905551111111", "content": "Order shipped"}
+{"to": "05552222222", "content": "Invalid phone"}
+{"to": "+905553333333", "content": "Win at the casino"}
+{"to": "+905554444444", "content": "Order delivered"}`
+	submitted, err := service.Submit(ctx, []byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, db.IngestionStatusQueued, submitted.State)
+	assert.Equal(t, 5, submitted.Total)
+
+	t.Run("busy", func(t *testing.T) {
+		// one job waits for the stopped worker, one more may be queued
+		second, err := service.Submit(ctx, []byte(`[{"to": "+905555555555", "content": "Second"}]`))
+		require.NoError(t, err)
+		_, err = service.Submit(ctx, []byte(`[{"to": "+905556666666", "content": "Third"}]`))
+		assert.True(t, errors.Is(err, ErrIngestionBusy))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			service.Run(runCtx)
+		}()
+		require.Eventually(t, func() bool {
+			job, err := service.Job(ctx, second.ID)
+			return err == nil && job.State == db.IngestionStatusSucceeded
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+	})
+
+	job, err := service.Job(ctx, submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.IngestionStatusSucceeded, job.State)
+	assert.Equal(t, 5, job.Read)
+	assert.Equal(t, 2, job.Enqueued)
+	assert.Equal(t, 1, job.Duplicates)
+	assert.Equal(t, 3, job.Rejected)
+	require.Len(t, job.Rejections, 3)
+	assert.Equal(t, dto.IngestionRejection{Line: 2, Reason: "duplicate of an earlier row"}, job.Rejections[0])
+	assert.Equal(t, 3, job.Rejections[1].Line)
+	assert.Equal(t, 4, job.Rejections[2].Line, "content policy rejections do not fail the job")
+	assert.Contains(t, job.Rejections[2].Reason, "gambling")
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	count, err := db.CountMessages(ctx, testDB, db.MessageFilter{Status: db.MessageStatusPending})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = service.Job(ctx, "01J9ZQ4K8M3V6X2T5R7N0B1C4D")
+	assert.True(t, errors.Is(err, ErrIngestionJobNotFound))
+}
+
+func TestIngestionService_Shutdown(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	service := NewIngestionService(testDB, queue.NewPostgres(testDB), config.Ingestion{MaxMessages: 10, Workers: 1, MaxQueued: 2, BatchSize: 2})
+	ctx := context.Background()
+	var ids []string
+	for i := range 2 {
+		job, err := service.Submit(ctx, []byte(fmt.Sprintf(`[{"to": "+90555111111%d", "content": "Hi"}]`, i)))
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+
+	// the instance shuts down before a worker picked the jobs up
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	service.Run(cancelled)
+
+	for _, id := range ids {
+		job, err := service.Job(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, db.IngestionStatusFailed, job.State)
+		assert.Equal(t, ingestionInterrupted, job.Error)
+		assert.Zero(t, job.Enqueued)
+	}
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobResultUnavailable is returned for the output of a job that did not succeed or left none
+	ErrJobResultUnavailable = errors.New("job result unavailable")
+	// ErrJobsBusy is returned when jobs.max_queued jobs already wait for a worker
+	ErrJobsBusy = errors.New("too many jobs are waiting, retry later")
+)
+
+// JobFunc runs a background job, reporting the units of work it did to progress
+type JobFunc func(ctx context.Context, progress *JobProgress) (*JobOutput, error)
+
+// JobOutput is what a succeeded job leaves
+type JobOutput struct {
+	// Summary is the result of the job, e.g. Exported 1200 messages
+	Summary string
+	// ContentType and Body are the output downloaded from the result URL of the job, none without a content type
+	ContentType string
+	Body        []byte
+}
+
+// JobProgress records the progress of a running job
+type JobProgress struct {
+	db  bun.IDB
+	job *db.Job
+}
+
+// Add records n more units of work done and stores the progress of the job
+func (p *JobProgress) Add(ctx context.Context, n int) {
+	p.job.Done += n
+	if err := db.UpdateJob(ctx, p.db, p.job); err != nil {
+		config.Log().WithField("job", p.job.ID).Warnf("Failed to record the progress of the job: %v", err)
+	}
+}
+
+// JobInterface defines the background jobs
+type JobInterface interface {
+	Job(ctx context.Context, id string) (*dto.JobResponse, error)
+	Result(ctx context.Context, id string) (*db.JobResult, error)
+	SubmitExport(ctx context.Context, req *dto.ExportRequest) (*dto.JobResponse, error)
+}
+
+// JobService runs long-running operations in the background. Jobs are run by the instance that accepted them,
+// their progress and output are kept in the jobs and job_results tables so any instance reports them until they
+// expire after jobs.result_ttl.
+type JobService struct {
+	db       *bun.DB
+	messages *MessageService
+	cfg      config.Jobs
+	holder   string
+	// slots bounds the accepted jobs not finished yet, jobs hands them to the workers
+	slots chan struct{}
+	jobs  chan *queuedJob
+}
+
+type queuedJob struct {
+	job *db.Job
+	run JobFunc
+}
+
+// NewJobService creates a job service exporting the messages as messages lists them, the jobs are run once Run
+// is called
+func NewJobService(database *bun.DB, messages *MessageService, cfg config.Jobs) *JobService {
+	capacity := cfg.Workers + cfg.MaxQueued
+	return &JobService{
+		db:       database,
+		messages: messages,
+		cfg:      cfg,
+		holder:   leaseHolder(),
+		slots:    make(chan struct{}, capacity),
+		jobs:     make(chan *queuedJob, capacity),
+	}
+}
+
+// Submit accepts a queued job of kind running run with total units of work, params are stored with it. With
+// every worker busy and jobs.max_queued jobs waiting it is refused with ErrJobsBusy. The expired jobs are
+// deleted first.
+func (s *JobService) Submit(ctx context.Context, kind string, params any, total int, run JobFunc) (*dto.JobResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "JobService.Submit")
+	defer span.End()
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil, ErrJobsBusy
+	}
+
+	if deleted, err := db.DeleteExpiredJobs(ctx, s.db, time.Now()); err != nil {
+		config.LogFrom(ctx).Warnf("Failed to delete the expired jobs: %v", err)
+	} else if deleted > 0 {
+		config.LogFrom(ctx).Infof("Deleted %d expired jobs", deleted)
+	}
+
+	job := &db.Job{
+		Kind:   kind,
+		Holder: s.holder,
+		APIKey: quota.APIKeyFrom(ctx),
+		Total:  total,
+	}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			<-s.slots
+			return nil, err
+		}
+		value := string(encoded)
+		job.Params = &value
+	}
+	if err := db.CreateJob(ctx, s.db, job); err != nil {
+		<-s.slots
+		return nil, err
+	}
+	s.jobs <- &queuedJob{job: job, run: run}
+
+	config.LogFrom(ctx).WithField("job", job.ID).Infof("Accepted %s job of %d", kind, total)
+	return convertJob(job), nil
+}
+
+// Job returns the job with id and its progress, ingestion jobs included
+func (s *JobService) Job(ctx context.Context, id string) (*dto.JobResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "JobService.Job")
+	defer span.End()
+
+	job, err := s.job(ctx, id)
+	if err == nil {
+		return convertJob(job), nil
+	}
+	if !errors.Is(err, ErrJobNotFound) {
+		return nil, err
+	}
+
+	ingestion, err := db.GetIngestionJob(ctx, s.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		return nil, err
+	}
+	return convertJob(&db.Job{
+		ID:         ingestion.ID,
+		Kind:       db.JobKindIngestion,
+		Status:     ingestion.Status,
+		Total:      ingestion.Total,
+		Done:       ingestion.Read,
+		Result:     ingestionSummary(ingestion),
+		Error:      ingestion.Error,
+		CreatedAt:  ingestion.CreatedAt,
+		StartedAt:  ingestion.StartedAt,
+		FinishedAt: ingestion.FinishedAt,
+	}), nil
+}
+
+// Result returns the output of the succeeded job with id
+func (s *JobService) Result(ctx context.Context, id string) (*db.JobResult, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "JobService.Result")
+	defer span.End()
+
+	job, err := s.job(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != db.JobStatusSucceeded || job.ResultType == "" {
+		return nil, fmt.Errorf("%w: job %s is %s", ErrJobResultUnavailable, id, job.Status)
+	}
+
+	result, err := db.GetJobResult(ctx, s.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: job %s left no output", ErrJobResultUnavailable, id)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// job returns the job with id unless it expired
+func (s *JobService) job(ctx context.Context, id string) (*db.Job, error) {
+	job, err := db.GetJob(ctx, s.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+		return nil, err
+	}
+	if job.ExpiresAt != nil && !job.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: %s expired", ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+// Run runs the accepted jobs with jobs.workers workers until ctx is cancelled. A job running at that point is
+// cancelled, it and the jobs still queued are failed as interrupted.
+func (s *JobService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range s.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					if ctx.Err() != nil {
+						s.interrupt(ctx, job)
+						continue
+					}
+					s.process(ctx, job)
+					<-s.slots
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case job := <-s.jobs:
+			s.interrupt(ctx, job)
+		default:
+			return
+		}
+	}
+}
+
+// interrupt fails a job that was not started before the shutdown
+func (s *JobService) interrupt(ctx context.Context, job *queuedJob) {
+	job.job.Status = db.JobStatusFailed
+	job.job.Error = ingestionInterrupted
+	s.finish(ctx, job.job)
+	<-s.slots
+}
+
+// process runs job and stores its output
+func (s *JobService) process(ctx context.Context, job *queuedJob) {
+	ctx, span := telemetry.Tracer().Start(ctx, "JobService.process")
+	defer span.End()
+
+	log := config.Log().WithField("job", job.job.ID)
+	started := time.Now()
+	job.job.Status = db.JobStatusRunning
+	job.job.StartedAt = &started
+	if err := db.UpdateJob(ctx, s.db, job.job); err != nil {
+		log.Errorf("Failed to record the start of the job: %v", err)
+	}
+
+	// the job runs for the API key it was submitted with
+	output, err := job.run(quota.ContextWithAPIKey(ctx, job.job.APIKey), &JobProgress{db: s.db, job: job.job})
+	if err == nil && output.ContentType != "" {
+		err = db.SaveJobResult(ctx, s.db, &db.JobResult{JobID: job.job.ID, ContentType: output.ContentType, Body: output.Body})
+		job.job.ResultType = output.ContentType
+	}
+	if err != nil {
+		job.job.Status = db.JobStatusFailed
+		job.job.Error = err.Error()
+		job.job.ResultType = ""
+		if ctx.Err() != nil {
+			job.job.Error = ingestionInterrupted
+		}
+		log.Errorf("%s job failed after %d of %d: %v", job.job.Kind, job.job.Done, job.job.Total, err)
+	} else {
+		job.job.Status = db.JobStatusSucceeded
+		job.job.Result = output.Summary
+		log.Infof("%s job succeeded in %s: %s", job.job.Kind, time.Since(started).Round(time.Millisecond), output.Summary)
+	}
+	s.finish(ctx, job.job)
+}
+
+// finish records the end of job, also when ctx was cancelled by the shutdown
+func (s *JobService) finish(ctx context.Context, job *db.Job) {
+	telemetry.RecordJob(job.Kind, job.Status)
+
+	finished := time.Now()
+	expires := finished.Add(s.cfg.ResultTTL)
+	job.FinishedAt, job.ExpiresAt = &finished, &expires
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := db.UpdateJob(ctx, s.db, job); err != nil {
+		config.Log().WithField("job", job.ID).Errorf("Failed to record the end of the job: %v", err)
+	}
+}
+
+// ingestionSummary summarizes a succeeded ingestion job like the result of a job
+func ingestionSummary(job *db.IngestionJob) string {
+	if job.Status != db.IngestionStatusSucceeded {
+		return ""
+	}
+	return fmt.Sprintf("Enqueued %d messages, %d duplicates and %d rejected", job.Enqueued, job.Duplicates, job.Rejected)
+}
+
+func convertJob(job *db.Job) *dto.JobResponse {
+	response := &dto.JobResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		ID:         job.ID,
+		Kind:       job.Kind,
+		State:      job.Status,
+		Progress:   job.Progress(),
+		Total:      job.Total,
+		Done:       job.Done,
+		Result:     job.Result,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		ExpiresAt:  job.ExpiresAt,
+	}
+	if job.Status == db.JobStatusSucceeded && job.ResultType != "" {
+		response.ResultURL = "/api/v1/jobs/" + job.ID + "/result"
+	}
+	return response
+}
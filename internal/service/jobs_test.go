@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobService_Export(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	for i, status := range []db.MessageStatus{db.MessageStatusFailed, db.MessageStatusSent, db.MessageStatusFailed, db.MessageStatusFailed} {
+		msg := &db.Message{To: "+90555111111" + string(rune('0'+i)), Content: "Hi", Status: status}
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	cfg := config.Jobs{Workers: 1, MaxQueued: 1, ResultTTL: time.Hour, Export: config.ExportJobs{MaxMessages: 3, PageSize: 2}}
+	service := NewJobService(testDB, NewMessageService(testDB), cfg)
+
+	t.Run("refused exports", func(t *testing.T) {
+		_, err := service.SubmitExport(ctx, &dto.ExportRequest{Format: "xlsx"})
+		assert.True(t, errors.Is(err, ErrInvalidExport))
+		_, err = service.SubmitExport(ctx, &dto.ExportRequest{Status: "lost"})
+		assert.True(t, errors.Is(err, ErrInvalidExport))
+		_, err = service.SubmitExport(ctx, &dto.ExportRequest{})
+		assert.True(t, errors.Is(err, ErrExportTooLarge))
+	})
+
+	submitted, err := service.SubmitExport(ctx, &dto.ExportRequest{Format: "jsonl", Status: "failed"})
+	require.NoError(t, err)
+	assert.Equal(t, db.JobKindExport, submitted.Kind)
+	assert.Equal(t, db.JobStatusQueued, submitted.State)
+	assert.Equal(t, 3, submitted.Total)
+	assert.Zero(t, submitted.Progress)
+	assert.Empty(t, submitted.ResultURL)
+
+	t.Run("busy", func(t *testing.T) {
+		// one job waits for the stopped worker, one more may be queued
+		_, err := service.SubmitExport(ctx, &dto.ExportRequest{Status: "sent"})
+		require.NoError(t, err)
+		_, err = service.SubmitExport(ctx, &dto.ExportRequest{Status: "sent"})
+		assert.True(t, errors.Is(err, ErrJobsBusy))
+	})
+
+	_, err = service.Result(ctx, submitted.ID)
+	assert.True(t, errors.Is(err, ErrJobResultUnavailable), "a queued job has no result yet")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Run(runCtx)
+	}()
+	require.Eventually(t, func() bool {
+		job, err := service.Job(ctx, submitted.ID)
+		return err == nil && job.State == db.JobStatusSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	job, err := service.Job(ctx, submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, 3, job.Done)
+	assert.Equal(t, "Exported 3 messages", job.Result)
+	assert.Equal(t, "/api/v1/jobs/"+submitted.ID+"/result", job.ResultURL)
+	assert.NotNil(t, job.StartedAt)
+	require.NotNil(t, job.ExpiresAt)
+	assert.WithinDuration(t, job.FinishedAt.Add(time.Hour), *job.ExpiresAt, time.Second)
+
+	result, err := service.Result(ctx, submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", result.ContentType)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(result.Body)), "\n"), 3)
+	assert.NotContains(t, string(result.Body), `"status":"sent"`)
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := testDB.NewUpdate().Model((*db.Job)(nil)).
+			Set("expires_at = ?", time.Now().Add(-time.Minute)).
+			Where("id = ?", submitted.ID).
+			Exec(ctx)
+		require.NoError(t, err)
+
+		_, err = service.Job(ctx, submitted.ID)
+		assert.True(t, errors.Is(err, ErrJobNotFound))
+
+		deleted, err := db.DeleteExpiredJobs(ctx, testDB, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		_, err = db.GetJobResult(ctx, testDB, submitted.ID)
+		assert.Error(t, err, "the result is deleted with its job")
+	})
+}
+
+func TestJobService_Ingestion(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	started := time.Now()
+	ingestion := &db.IngestionJob{ID: "01J9ZQ4K8M3V6X2T5R7N0B1C4D", Status: db.IngestionStatusRunning, Total: 10, Read: 4, StartedAt: &started}
+	_, err := testDB.NewInsert().Model(ingestion).Exec(ctx)
+	require.NoError(t, err)
+
+	service := NewJobService(testDB, NewMessageService(testDB), config.Jobs{Workers: 1})
+
+	job, err := service.Job(ctx, ingestion.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.JobKindIngestion, job.Kind)
+	assert.Equal(t, db.IngestionStatusRunning, job.State)
+	assert.Equal(t, 40, job.Progress)
+	assert.Empty(t, job.ResultURL)
+
+	_, err = service.Job(ctx, "01J9ZQ4K8M3V6X2T5R7N0B1C4E")
+	assert.True(t, errors.Is(err, ErrJobNotFound))
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/links"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// ErrLinkNotFound is returned for clicks on unknown link codes
+var ErrLinkNotFound = errors.New("link not found")
+
+// LinkInterface defines tracked link operations
+type LinkInterface interface {
+	Click(ctx context.Context, code string) (string, error)
+	MessageLinks(ctx context.Context, id string) (*dto.MessageLinksResponse, error)
+	CampaignClicks(ctx context.Context, campaign string) (*dto.CampaignClicksResponse, error)
+}
+
+type LinkService struct {
+	db        *bun.DB
+	shortener *links.Shortener
+}
+
+func NewLinkService(database *bun.DB, cfg config.LinkTracking) *LinkService {
+	return &LinkService{
+		db:        database,
+		shortener: links.NewShortener(cfg),
+	}
+}
+
+// Click counts a click on the link with code and returns the URL it redirects to.
+// Links keep working when link tracking is disabled later on.
+func (s *LinkService) Click(ctx context.Context, code string) (string, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "LinkService.Click")
+	defer span.End()
+
+	link, err := db.RecordClick(ctx, s.db, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrLinkNotFound
+		}
+		return "", err
+	}
+	telemetry.RecordLinkClick()
+
+	return link.URL, nil
+}
+
+// MessageLinks returns the tracked links of a message with their clicks
+func (s *LinkService) MessageLinks(ctx context.Context, id string) (*dto.MessageLinksResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "LinkService.MessageLinks")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.GetMessageByID(ctx, s.db, messageID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+
+	messageLinks, err := db.GetMessageLinks(ctx, s.db, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.MessageLinksResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		MessageID: shownMessageID(messageID, id),
+		Links:     make([]dto.LinkResponse, len(messageLinks)),
+	}
+	for i, link := range messageLinks {
+		response.Links[i] = dto.LinkResponse{
+			Code:          link.Code,
+			URL:           link.URL,
+			ShortURL:      s.shortener.ShortURL(link.Code),
+			Clicks:        link.Clicks,
+			LastClickedAt: link.LastClickedAt,
+		}
+		response.Clicks += link.Clicks
+	}
+	return response, nil
+}
+
+// CampaignClicks returns the link clicks per campaign, only of campaign when it is set
+func (s *LinkService) CampaignClicks(ctx context.Context, campaign string) (*dto.CampaignClicksResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "LinkService.CampaignClicks")
+	defer span.End()
+
+	clicks, err := db.GetCampaignClicks(ctx, s.db, campaign)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.CampaignClicksResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Campaigns: make([]dto.CampaignClicks, len(clicks)),
+	}
+	for i, c := range clicks {
+		response.Campaigns[i] = dto.CampaignClicks{
+			Campaign:        c.Campaign,
+			Messages:        c.Messages,
+			Links:           c.Links,
+			Clicks:          c.Clicks,
+			ClickedMessages: c.ClickedMessages,
+		}
+		if c.Messages > 0 {
+			response.Campaigns[i].ClickRate = float64(c.ClickedMessages) / float64(c.Messages)
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Sale 1", Status: db.MessageStatusSent, Campaign: "spring"},
+		{To: "+905552222222", Content: "Sale 2", Status: db.MessageStatusSent, Campaign: "spring"},
+		{To: "+905553333333", Content: "Receipt", Status: db.MessageStatusSent},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.CreateLinks(ctx, testDB, []*db.Link{
+		{Code: "spring1a", URL: "https://example.com/spring"},
+		{Code: "spring1b", URL: "https://example.com/terms"},
+		{Code: "spring2a", URL: "https://example.com/spring"},
+		{Code: "receipt1", URL: "https://example.com/receipt"},
+	}))
+	require.NoError(t, db.AttachLinks(ctx, testDB, messages[0].ID, []string{"spring1a", "spring1b"}))
+	require.NoError(t, db.AttachLinks(ctx, testDB, messages[1].ID, []string{"spring2a"}))
+	require.NoError(t, db.AttachLinks(ctx, testDB, messages[2].ID, []string{"receipt1"}))
+
+	service := NewLinkService(testDB, config.LinkTracking{Enabled: true, BaseURL: "https://sp.example.com/l"})
+
+	for _, code := range []string{"spring1a", "spring1a", "spring1b", "receipt1"} {
+		target, err := service.Click(ctx, code)
+		require.NoError(t, err)
+		assert.Contains(t, target, "https://example.com/")
+	}
+
+	t.Run("unknown link", func(t *testing.T) {
+		_, err := service.Click(ctx, "unknown")
+		assert.ErrorIs(t, err, ErrLinkNotFound)
+	})
+
+	t.Run("message links", func(t *testing.T) {
+		response, err := service.MessageLinks(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, response.Links, 2)
+		assert.Equal(t, int64(3), response.Clicks)
+		assert.Equal(t, "https://sp.example.com/l/spring1a", response.Links[0].ShortURL)
+		assert.Equal(t, int64(2), response.Links[0].Clicks)
+		assert.NotNil(t, response.Links[0].LastClickedAt)
+
+		_, err = service.MessageLinks(ctx, "99")
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+		_, err = service.MessageLinks(ctx, "abc")
+		assert.ErrorIs(t, err, ErrInvalidMessageID)
+	})
+
+	t.Run("campaign clicks", func(t *testing.T) {
+		response, err := service.CampaignClicks(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, response.Campaigns, 2)
+
+		spring := response.Campaigns[0]
+		assert.Equal(t, "spring", spring.Campaign)
+		assert.Equal(t, int64(2), spring.Messages)
+		assert.Equal(t, int64(3), spring.Links)
+		assert.Equal(t, int64(3), spring.Clicks)
+		assert.Equal(t, int64(1), spring.ClickedMessages)
+		assert.InDelta(t, 0.5, spring.ClickRate, 0.001)
+		assert.Equal(t, "", response.Campaigns[1].Campaign)
+
+		response, err = service.CampaignClicks(ctx, "spring")
+		require.NoError(t, err)
+		assert.Len(t, response.Campaigns, 1)
+	})
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/uptrace/bun"
+)
+
+// LoadShedder tracks whether the database is under load, its ping latency or pool saturation above the thresholds
+// of server.load_shedding. While it is the stats and export requests are answered with 503, message creation and
+// the scheduler keep the connections for themselves.
+type LoadShedder struct {
+	db  *bun.DB
+	cfg config.LoadShedding
+
+	mu       sync.RWMutex
+	shedding bool
+	reason   string
+}
+
+func NewLoadShedder(database *bun.DB, cfg config.LoadShedding) *LoadShedder {
+	telemetry.SetLoadShedding(false, 0, 0)
+	return &LoadShedder{db: database, cfg: cfg}
+}
+
+// Shedding reports whether the low-priority reads are shed and why
+func (s *LoadShedder) Shedding() (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shedding, s.reason
+}
+
+// RetryAfter is how long clients of shed requests are asked to wait
+func (s *LoadShedder) RetryAfter() time.Duration {
+	return s.cfg.RetryAfter
+}
+
+// Check pings the database and updates the state with its latency and the pool statistics
+func (s *LoadShedder) Check(ctx context.Context) bool {
+	// a ping waits for a free connection, so a saturated pool shows in the latency as well
+	cctx, cancel := context.WithTimeout(ctx, ReadinessCheckTimeout)
+	defer cancel()
+
+	started := time.Now()
+	s.db.PingContext(cctx)
+	return s.Observe(time.Since(started), s.db.Stats())
+}
+
+// Observe updates the state with a ping latency and pool statistics and reports whether load is shed. A failed
+// ping is left to DatabaseAvailability, its latency is observed like any other.
+func (s *LoadShedder) Observe(latency time.Duration, stats sql.DBStats) bool {
+	saturation := 0.0
+	if stats.MaxOpenConnections > 0 {
+		saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+	}
+
+	var reason string
+	switch {
+	case s.cfg.Latency > 0 && latency >= s.cfg.Latency:
+		reason = fmt.Sprintf("the database ping took %s, over %s", latency.Round(time.Millisecond), s.cfg.Latency)
+	case s.cfg.PoolSaturation > 0 && saturation >= s.cfg.PoolSaturation:
+		reason = fmt.Sprintf("%d of %d database connections are in use", stats.InUse, stats.MaxOpenConnections)
+	}
+	shedding := reason != ""
+
+	s.mu.Lock()
+	changed := s.shedding != shedding
+	s.shedding = shedding
+	s.reason = reason
+	s.mu.Unlock()
+
+	telemetry.SetLoadShedding(shedding, latency, saturation)
+	if changed {
+		log := config.Log().WithField("component", "load_shedding")
+		if shedding {
+			log.WithField("reason", reason).Warn("Database is under load, shedding stats and export requests")
+		} else {
+			log.Info("Database load is back under the thresholds, serving stats and export requests again")
+		}
+	}
+	return shedding
+}
+
+// Watch checks the database every server.load_shedding.interval until ctx is cancelled
+func (s *LoadShedder) Watch(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
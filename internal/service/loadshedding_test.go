@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedder(t *testing.T) {
+	shedder := NewLoadShedder(nil, config.LoadShedding{Latency: 500 * time.Millisecond, PoolSaturation: 0.8})
+
+	assert.False(t, shedder.Observe(10*time.Millisecond, sql.DBStats{MaxOpenConnections: 10, InUse: 7}))
+	shedding, reason := shedder.Shedding()
+	assert.False(t, shedding)
+	assert.Empty(t, reason)
+
+	assert.True(t, shedder.Observe(time.Second, sql.DBStats{MaxOpenConnections: 10, InUse: 1}), "a slow ping sheds")
+	_, reason = shedder.Shedding()
+	assert.Contains(t, reason, "ping took 1s")
+	assert.Equal(t, 1.0, testutil.ToFloat64(telemetry.LoadShedding))
+
+	assert.True(t, shedder.Observe(10*time.Millisecond, sql.DBStats{MaxOpenConnections: 10, InUse: 8}), "a saturated pool sheds")
+	_, reason = shedder.Shedding()
+	assert.Equal(t, "8 of 10 database connections are in use", reason)
+	assert.Equal(t, 0.8, testutil.ToFloat64(telemetry.DatabasePoolSaturation))
+
+	assert.False(t, shedder.Observe(10*time.Millisecond, sql.DBStats{InUse: 50}), "a pool without a limit is never saturated")
+	assert.Equal(t, 0.0, testutil.ToFloat64(telemetry.LoadShedding))
+}
+
+func TestLoadShedder_Check(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	assert.False(t, NewLoadShedder(testDB, config.LoadShedding{Latency: time.Minute}).Check(context.Background()))
+	assert.True(t, NewLoadShedder(testDB, config.LoadShedding{Latency: time.Nanosecond}).Check(context.Background()))
+}
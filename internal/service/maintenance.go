@@ -0,0 +1,376 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/archive"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/cron"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/uptrace/bun"
+)
+
+// MaintenanceInterface defines maintenance job operations
+type MaintenanceInterface interface {
+	Jobs(ctx context.Context) (*dto.MaintenanceJobsResponse, error)
+}
+
+// MaintenanceFunc runs a maintenance job and summarizes what it did
+type MaintenanceFunc func(ctx context.Context) (string, error)
+
+// Maintenance runs housekeeping jobs on their schedules. With several instances every run is taken by the
+// instance acquiring the lease of the job, the last run of every job is recorded in the maintenance_jobs table.
+type Maintenance struct {
+	db     bun.IDB
+	holder string
+
+	mu   sync.Mutex
+	jobs []*maintenanceJob
+}
+
+type maintenanceJob struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	run      MaintenanceFunc
+	next     time.Time
+}
+
+// NewMaintenance creates a maintenance runner without jobs
+func NewMaintenance(database bun.IDB) *Maintenance {
+	return &Maintenance{db: database, holder: leaseHolder()}
+}
+
+// Add adds the job called name running on the cron schedule spec, see cron.Parse
+func (m *Maintenance) Add(name, spec string, run MaintenanceFunc) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("maintenance job %s: %w", name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = append(m.jobs, &maintenanceJob{name: name, spec: spec, schedule: schedule, run: run})
+	return nil
+}
+
+// Run runs every job on its schedule until ctx is cancelled
+func (m *Maintenance) Run(ctx context.Context) {
+	m.mu.Lock()
+	jobs := m.jobs
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		config.Log().Infof("Maintenance job %s scheduled (%s)", job.name, job.spec)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.runJob(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+// runJob waits for every run time of job and runs it when this instance takes its lease
+func (m *Maintenance) runJob(ctx context.Context, job *maintenanceJob) {
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			config.Log().Warnf("Maintenance job %s has no next run time, it is not run anymore", job.name)
+			return
+		}
+		m.setNext(job, next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// the lease is held until the next run time, so the other instances skip this one
+		ttl := time.Until(job.schedule.Next(time.Now()))
+		acquired, err := db.AcquireLease(ctx, m.db, "maintenance:"+job.name, m.holder, ttl)
+		if err != nil {
+			config.Log().Errorf("Failed to take the lease of maintenance job %s: %v", job.name, err)
+			continue
+		}
+		if !acquired {
+			config.Log().Debugf("Maintenance job %s is run by another instance", job.name)
+			continue
+		}
+		m.runOnce(ctx, job)
+	}
+}
+
+// runOnce runs job and records the run, a panic fails the run instead of the process
+func (m *Maintenance) runOnce(ctx context.Context, job *maintenanceJob) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Maintenance."+job.name)
+	defer span.End()
+
+	log := config.Log().WithField("job", job.name)
+	started := time.Now()
+	if err := db.StartJobRun(ctx, m.db, job.name, m.holder, started); err != nil {
+		log.Errorf("Failed to record the start of the maintenance job: %v", err)
+	}
+
+	result, err := func() (result string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				telemetry.RecordPanic("maintenance")
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return job.run(ctx)
+	}()
+	telemetry.RecordMaintenanceRun(job.name, err == nil, time.Since(started))
+
+	// the run is recorded even when it was cut short by the shutdown
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if recordErr := db.FinishJobRun(recordCtx, m.db, job.name, result, err); recordErr != nil {
+		log.Errorf("Failed to record the end of the maintenance job: %v", recordErr)
+	}
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Errorf("Maintenance job failed after %s: %v", time.Since(started).Round(time.Millisecond), err)
+		}
+		return
+	}
+	log.Infof("Maintenance job finished in %s: %s", time.Since(started).Round(time.Millisecond), result)
+}
+
+func (m *Maintenance) setNext(job *maintenanceJob, next time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.next = next
+}
+
+// Jobs lists the jobs of the instance with their last run across the cluster
+func (m *Maintenance) Jobs(ctx context.Context) (*dto.MaintenanceJobsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Maintenance.Jobs")
+	defer span.End()
+
+	runs, err := db.ListJobRuns(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	lastRuns := make(map[string]*db.JobRun, len(runs))
+	for _, run := range runs {
+		lastRuns[run.Name] = run
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	response := &dto.MaintenanceJobsResponse{
+		BaseResponse: dto.BaseResponse{Status: "ok"},
+		Jobs:         make([]dto.MaintenanceJobResponse, 0, len(m.jobs)),
+	}
+	for _, job := range m.jobs {
+		item := dto.MaintenanceJobResponse{Name: job.name, Schedule: job.spec}
+		if !job.next.IsZero() {
+			next := job.next
+			item.NextRunAt = &next
+		}
+		if run, ok := lastRuns[job.name]; ok {
+			started := run.StartedAt
+			item.LastStatus = run.Status
+			item.LastResult = run.Result
+			item.LastError = run.Error
+			item.LastHolder = run.Holder
+			item.LastStartedAt = &started
+			item.LastFinishedAt = run.FinishedAt
+			item.Runs = run.Runs
+			item.Failures = run.Failures
+		}
+		response.Jobs = append(response.Jobs, item)
+	}
+	return response, nil
+}
+
+// Names of the maintenance jobs, as in the maintenance config
+const (
+	JobStuckReaper  = "stuck_reaper"
+	JobRetention    = "retention"
+	JobArchive      = "archive"
+	JobStatsRefresh = "stats_refresh"
+	JobReport       = "report"
+	// JobFailureRequeue requeues the transient failures, see FailureRequeueJob
+	JobFailureRequeue = "failure_requeue"
+)
+
+// StuckReaperJob moves the messages sending for longer than olderThan back to pending so they are sent again
+func StuckReaperJob(database bun.IDB, olderThan time.Duration) MaintenanceFunc {
+	return func(ctx context.Context) (string, error) {
+		reset, err := db.ResetStuckMessages(ctx, database, time.Now().Add(-olderThan))
+		if err != nil {
+			return "", err
+		}
+		if reset > 0 {
+			config.Log().Warnf("Reset %d message(s) sending for over %s to pending", reset, olderThan)
+		}
+		return fmt.Sprintf("reset %d stuck message(s) to pending", reset), nil
+	}
+}
+
+// FailureRequeueJob requeues the messages failed with a transient failure once their backoff passed and moves the
+// ones requeued job.MaxRequeues times to the dead-letter queue, publishing their dead_lettered events to publisher
+// when it is not nil. The backoff starts at job.Backoff and doubles with every requeue of the message up to
+// job.MaxBackoff.
+func FailureRequeueJob(database bun.IDB, job config.MaintenanceJob, publisher events.Publisher) MaintenanceFunc {
+	classes := make([]string, len(webhook.TransientFailures))
+	for i, class := range webhook.TransientFailures {
+		classes[i] = string(class)
+	}
+
+	return func(ctx context.Context) (string, error) {
+		now := time.Now()
+		requeued := 0
+		for requeues := range job.MaxRequeues {
+			count, err := db.RequeueFailures(ctx, database, classes, requeues, now.Add(-requeueBackoff(job, requeues)))
+			requeued += count
+			if err != nil {
+				telemetry.RecordFailureRequeues("requeued", requeued)
+				return "", fmt.Errorf("requeue stopped after %d messages: %w", requeued, err)
+			}
+		}
+		telemetry.RecordFailureRequeues("requeued", requeued)
+
+		deadLettered, err := db.DeadLetterFailures(ctx, database, classes, job.MaxRequeues)
+		if err != nil {
+			return "", err
+		}
+		telemetry.RecordFailureRequeues("dead_lettered", len(deadLettered))
+		if len(deadLettered) > 0 {
+			config.Log().Warnf("Moved %d message(s) failing after %d requeues to the dead-letter queue", len(deadLettered), job.MaxRequeues)
+		}
+		if publisher != nil {
+			for _, message := range deadLettered {
+				event := events.NewEvent(events.TypeDeadLettered, message)
+				event.FailureClass = message.FailureClass
+				if err := publisher.Publish(ctx, event); err != nil {
+					config.Log().WithField("message_id", message.ID).Warnf("Failed to publish %s event: %v", event.Type, err)
+				}
+			}
+		}
+		return fmt.Sprintf("requeued %d transient failure(s), dead-lettered %d", requeued, len(deadLettered)), nil
+	}
+}
+
+// requeueBackoff returns how long a failure of a message requeued requeues times waits for its next requeue
+func requeueBackoff(job config.MaintenanceJob, requeues int) time.Duration {
+	backoff := job.Backoff
+	for range requeues {
+		if backoff >= job.MaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	return min(backoff, job.MaxBackoff)
+}
+
+// RetentionJob deletes the messages created longer than olderThan ago
+func RetentionJob(messages *MessageService, olderThan time.Duration) MaintenanceFunc {
+	return func(ctx context.Context) (string, error) {
+		cutoff := time.Now().Add(-olderThan)
+		deleted, err := messages.PurgeMessages(ctx, db.MessageFilter{To: &cutoff}, PurgeOptions{Pause: 100 * time.Millisecond})
+		if err != nil {
+			return "", fmt.Errorf("retention stopped after %d messages: %w", deleted, err)
+		}
+		return fmt.Sprintf("deleted %d message(s) created before %s", deleted, cutoff.UTC().Format(time.RFC3339)), nil
+	}
+}
+
+// ArchiveJob writes the messages created longer than olderThan ago to a new JSONL file in dir, then deletes
+// them. Every batch is on disk before it is deleted.
+func ArchiveJob(messages *MessageService, olderThan time.Duration, dir string) MaintenanceFunc {
+	return func(ctx context.Context) (string, error) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		cutoff := time.Now().Add(-olderThan)
+		path := filepath.Join(dir, fmt.Sprintf("messages-%s.jsonl", time.Now().UTC().Format("20060102T150405.000Z")))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		buffered := bufio.NewWriter(file)
+		encoder := json.NewEncoder(buffered)
+		deleted, err := messages.PurgeMessages(ctx, db.MessageFilter{To: &cutoff}, PurgeOptions{
+			Pause: 100 * time.Millisecond,
+			Archive: func(batch []dto.MessageResponse) error {
+				for _, msg := range batch {
+					if err := encoder.Encode(msg); err != nil {
+						return err
+					}
+				}
+				if err := buffered.Flush(); err != nil {
+					return err
+				}
+				return file.Sync()
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("archive stopped after %d messages written to %s: %w", deleted, path, err)
+		}
+		if deleted == 0 {
+			// nothing was archived, no empty files pile up
+			file.Close()
+			os.Remove(path)
+			return "no messages to archive", nil
+		}
+		return fmt.Sprintf("archived %d message(s) created before %s to %s", deleted, cutoff.UTC().Format(time.RFC3339), path), nil
+	}
+}
+
+// ArchiveStoreJob archives and then deletes the messages created before olderThan like ArchiveJob, writing
+// them to the encrypted parts of a run of a, partSize messages each
+func ArchiveStoreJob(messages *MessageService, olderThan time.Duration, a *archive.Archive, partSize int) MaintenanceFunc {
+	return func(ctx context.Context) (string, error) {
+		cutoff := time.Now().Add(-olderThan)
+		run := a.NewRun(time.Now(), cutoff)
+		deleted, err := messages.PurgeMessages(ctx, db.MessageFilter{To: &cutoff}, PurgeOptions{
+			BatchSize: partSize,
+			Pause:     100 * time.Millisecond,
+			Archive: func(batch []dto.MessageResponse) error {
+				return run.WritePart(ctx, batch)
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("archive stopped after %d messages written to %s: %w", deleted, run.Location(), err)
+		}
+		if deleted == 0 {
+			return "no messages to archive", nil
+		}
+		if err := run.Finish(ctx); err != nil {
+			return "", fmt.Errorf("archived %d messages to %s without completing its manifest: %w", deleted, run.Location(), err)
+		}
+		return fmt.Sprintf("archived %d message(s) created before %s to %s", deleted, cutoff.UTC().Format(time.RFC3339), run.Location()), nil
+	}
+}
+
+// StatsRefreshJob refreshes the message counts of the count cache
+func StatsRefreshJob(counts *CountCache) MaintenanceFunc {
+	return func(ctx context.Context) (string, error) {
+		if err := counts.Refresh(ctx); err != nil {
+			return "", err
+		}
+		return "refreshed the message counts", nil
+	}
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/archive"
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance_RecordsRuns(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	maintenance := NewMaintenance(testDB)
+	require.NoError(t, maintenance.Add("ok", "@every 1h", func(context.Context) (string, error) { return "did it", nil }))
+	require.NoError(t, maintenance.Add("broken", "0 3 * * *", func(context.Context) (string, error) { return "", errors.New("boom") }))
+	require.NoError(t, maintenance.Add("panics", "@daily", func(context.Context) (string, error) { panic("oops") }))
+	assert.Error(t, maintenance.Add("invalid", "every hour", nil))
+
+	for _, job := range maintenance.jobs {
+		maintenance.runOnce(ctx, job)
+	}
+	maintenance.runOnce(ctx, maintenance.jobs[0])
+
+	response, err := maintenance.Jobs(ctx)
+	require.NoError(t, err)
+	require.Len(t, response.Jobs, 3)
+
+	ok := response.Jobs[0]
+	assert.Equal(t, "ok", ok.Name)
+	assert.Equal(t, "@every 1h", ok.Schedule)
+	assert.Equal(t, db.JobStatusSucceeded, ok.LastStatus)
+	assert.Equal(t, "did it", ok.LastResult)
+	assert.Equal(t, int64(2), ok.Runs)
+	assert.Zero(t, ok.Failures)
+	require.NotNil(t, ok.LastFinishedAt)
+
+	broken := response.Jobs[1]
+	assert.Equal(t, db.JobStatusFailed, broken.LastStatus)
+	assert.Equal(t, "boom", broken.LastError)
+	assert.Equal(t, int64(1), broken.Failures)
+
+	assert.Equal(t, db.JobStatusFailed, response.Jobs[2].LastStatus)
+	assert.Equal(t, "panic: oops", response.Jobs[2].LastError)
+}
+
+func TestMaintenance_Run(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ran := make(chan struct{}, 10)
+	maintenance := NewMaintenance(testDB)
+	require.NoError(t, maintenance.Add("tick", "@every 10ms", func(context.Context) (string, error) {
+		ran <- struct{}{}
+		return "", nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		maintenance.Run(ctx)
+		close(done)
+	}()
+	<-ran
+	cancel()
+	<-done
+
+	response, err := maintenance.Jobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, response.Jobs, 1)
+	assert.NotNil(t, response.Jobs[0].NextRunAt)
+	assert.GreaterOrEqual(t, response.Jobs[0].Runs, int64(1))
+}
+
+func TestStuckReaperJob(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	stuck := &db.Message{To: "+905551111111", Content: "Stuck", Status: db.MessageStatusSending, UpdatedAt: now.Add(-time.Hour)}
+	inFlight := &db.Message{To: "+905552222222", Content: "In flight", Status: db.MessageStatusSending, UpdatedAt: now}
+	sent := &db.Message{To: "+905553333333", Content: "Sent", Status: db.MessageStatusSent, UpdatedAt: now.Add(-time.Hour)}
+	// left sending by a shutdown, it is reaped once its reap_after passed
+	shutdown := &db.Message{To: "+905554444444", Content: "Shutdown", Status: db.MessageStatusSending, UpdatedAt: now}
+	for _, msg := range []*db.Message{stuck, inFlight, sent, shutdown} {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	marked, err := db.SetReapAfter(ctx, testDB, []int64{shutdown.ID, sent.ID}, now.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, marked, "only messages still sending are marked")
+
+	result, err := StuckReaperJob(testDB, 10*time.Minute)(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "reset 2 stuck message(s) to pending", result)
+
+	for msg, status := range map[*db.Message]db.MessageStatus{stuck: db.MessageStatusPending, inFlight: db.MessageStatusSending, sent: db.MessageStatusSent, shutdown: db.MessageStatusPending} {
+		stored, err := db.GetMessageByID(ctx, testDB, msg.ID)
+		require.NoError(t, err)
+		assert.Equal(t, status, stored.Status, msg.Content)
+		assert.Nil(t, stored.ReapAfter, msg.Content)
+	}
+}
+
+func TestFailureRequeueJob(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	failed := func(content, class string, requeues int, age time.Duration) *db.Message {
+		return &db.Message{To: "+905551111111", Content: content, Status: db.MessageStatusFailed, FailureClass: class, Requeues: requeues, UpdatedAt: now.Add(-age)}
+	}
+	due := failed("Due", string(webhook.FailureNetwork), 0, 2*time.Minute)
+	backingOff := failed("Backing off", string(webhook.FailureProviderTemporary), 1, 90*time.Second)
+	backedOff := failed("Backed off", string(webhook.FailureProviderTemporary), 2, 5*time.Minute)
+	permanent := failed("Permanent", string(webhook.FailureProviderPermanent), 0, time.Hour)
+	exhausted := failed("Exhausted", string(webhook.FailureNetwork), 3, time.Hour)
+	exhausted.CallbackURL = "https://caller.example.com/notices"
+	for _, msg := range []*db.Message{due, backingOff, backedOff, permanent, exhausted} {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	// backoffs of 1m, 2m and 4m, capped at 4m
+	job := config.MaintenanceJob{Backoff: time.Minute, MaxBackoff: 4 * time.Minute, MaxRequeues: 3}
+	publisher := &recordingPublisher{}
+	result, err := FailureRequeueJob(testDB, job, publisher)(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "requeued 2 transient failure(s), dead-lettered 1", result)
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, events.TypeDeadLettered, publisher.events[0].Type)
+	assert.Equal(t, exhausted.ID, publisher.events[0].MessageID)
+	assert.Equal(t, string(webhook.FailureNetwork), publisher.events[0].FailureClass)
+	assert.Equal(t, exhausted.CallbackURL, publisher.events[0].CallbackURL, "the notice goes to the caller")
+
+	for msg, requeues := range map[*db.Message]int{due: 1, backingOff: 1, backedOff: 3, permanent: 0, exhausted: 3} {
+		stored, err := db.GetMessageByID(ctx, testDB, msg.ID)
+		require.NoError(t, err)
+		assert.Equal(t, requeues, stored.Requeues, msg.Content)
+		if msg == due || msg == backedOff {
+			assert.Equal(t, db.MessageStatusPending, stored.Status, msg.Content)
+			continue
+		}
+		assert.Equal(t, db.MessageStatusFailed, stored.Status, msg.Content)
+		assert.Equal(t, msg == exhausted, stored.DeadLetteredAt != nil, msg.Content)
+	}
+
+	assert.Equal(t, time.Minute, requeueBackoff(job, 0))
+	assert.Equal(t, 4*time.Minute, requeueBackoff(job, 2))
+	assert.Equal(t, 4*time.Minute, requeueBackoff(job, 60), "the backoff does not overflow")
+}
+
+// archiveStore is an archive.Store keeping the objects in a map
+type archiveStore map[string][]byte
+
+func (s archiveStore) Put(_ context.Context, key string, body []byte) error {
+	s[key] = body
+	return nil
+}
+
+func (s archiveStore) Get(_ context.Context, key string) ([]byte, error) {
+	body, ok := s[key]
+	if !ok {
+		return nil, archive.ErrNotFound
+	}
+	return body, nil
+}
+
+func (s archiveStore) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func TestArchiveStoreJob(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	sentAt := old.Add(time.Minute)
+	for _, msg := range []*db.Message{
+		{To: "+905551111111", Content: "Old", Status: db.MessageStatusSent, Segments: 2, UnitPrice: 0.05, SentAt: &sentAt,
+			Metadata: db.Metadata{"order_id": "42"}, CreatedAt: old},
+		{To: "+905552222222", Content: "Old too", Status: db.MessageStatusFailed, CreatedAt: old},
+		{To: "+905553333333", Content: "Older", Status: db.MessageStatusDelivered, CreatedAt: old.Add(-time.Hour)},
+		{To: "+905554444444", Content: "New", Status: db.MessageStatusSent, CreatedAt: time.Now()},
+	} {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	box, err := secrets.NewBox(testEncryptionKey("k"))
+	require.NoError(t, err)
+	store := archiveStore{}
+	a := archive.New(store, box, "")
+	messages := NewMessageService(testDB)
+
+	result, err := ArchiveStoreJob(messages, 24*time.Hour, a, 2)(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, result, "archived 3 message(s)")
+
+	runs, err := a.Runs(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.True(t, runs[0].Complete)
+	assert.Len(t, runs[0].Parts, 2, "a part is written per batch")
+	count, err := db.CountMessages(ctx, testDB, db.MessageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var archived []dto.MessageResponse
+	require.NoError(t, a.Read(ctx, runs[0], func(msg dto.MessageResponse) error {
+		archived = append(archived, msg)
+		return nil
+	}))
+	require.Len(t, archived, 3)
+
+	restored, err := messages.RestoreMessages(ctx, archived)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored)
+	restored, err = messages.RestoreMessages(ctx, archived)
+	require.NoError(t, err)
+	assert.Zero(t, restored, "messages in the database are not restored again")
+
+	list, err := db.ListMessages(ctx, testDB, db.MessageFilter{Status: db.MessageStatusSent, To: &sentAt}, db.MessagePage{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Old", list[0].Content)
+	assert.InDelta(t, 0.05, list[0].UnitPrice, 1e-9)
+	assert.Equal(t, "42", list[0].Metadata["order_id"])
+	assert.True(t, list[0].CreatedAt.Equal(old))
+
+	result, err = ArchiveStoreJob(messages, 72*time.Hour, a, 2)(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "no messages to archive", result)
+	runs, err = a.Runs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1, "runs without messages write no manifest")
+}
+
+func TestArchiveJob(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, msg := range []*db.Message{
+		{To: "+905551111111", Content: "Old", Status: db.MessageStatusSent, CreatedAt: old},
+		{To: "+905552222222", Content: "Old too", Status: db.MessageStatusFailed, CreatedAt: old},
+		{To: "+905553333333", Content: "New", Status: db.MessageStatusSent, CreatedAt: time.Now()},
+	} {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	job := ArchiveJob(NewMessageService(testDB), 24*time.Hour, dir)
+	result, err := job(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, result, "archived 2 message(s)")
+
+	files, err := filepath.Glob(filepath.Join(dir, "messages-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	file, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	assert.Equal(t, 2, lines)
+
+	count, err := db.CountMessages(ctx, testDB, db.MessageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// a run without old messages leaves no empty file behind
+	result, err = job(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "no messages to archive", result)
+	files, err = filepath.Glob(filepath.Join(dir, "messages-*.jsonl"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -2,52 +2,149 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/boratanrikulu/sendpulse/internal/db"
-	"github.com/boratanrikulu/sendpulse/internal/dto"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/policy"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
 	"github.com/uptrace/bun"
 )
 
-// Pagination constants
+// Pagination constants, the limits are the ones of the query package
 const (
-	// DefaultPageSize is the default number of messages returned per page when not specified
-	DefaultPageSize = 20
-	// MaxPageSize is the maximum number of messages that can be returned per page
-	// This prevents excessive memory usage and ensures reasonable response times
-	MaxPageSize = 100
-	// MinPageSize is the minimum page size allowed (must be at least 1)
-	MinPageSize = 1
-	// MinPage is the minimum page number (pages start from 1)
-	MinPage = 1
+	DefaultPageSize = query.DefaultPageSize
+	MaxPageSize     = query.MaxPageSize
+	MinPageSize     = query.MinPageSize
+	MinPage         = query.MinPage
+	// ExportBatchSize is the number of rows fetched per query while exporting
+	ExportBatchSize = 1000
+	// MaxNoteLength is the longest operator note of a failed message in bytes
+	MaxNoteLength = 2000
 )
 
 // Pagination errors
 var (
-	ErrInvalidPageSize  = errors.New("page size cannot be negative")
-	ErrPageSizeTooLarge = fmt.Errorf("page size cannot exceed %d", MaxPageSize)
-	ErrPageSizeTooSmall = fmt.Errorf("page size must be at least %d", MinPageSize)
-	ErrMessageNotFound  = errors.New("message not found")
-	ErrInvalidMessageID = errors.New("invalid message ID format")
+	ErrInvalidPageSize   = query.ErrInvalidPageSize
+	ErrPageSizeTooLarge  = query.ErrPageSizeTooLarge
+	ErrPageSizeTooSmall  = query.ErrPageSizeTooSmall
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrInvalidMessageID  = errors.New("invalid message ID format")
+	ErrInvalidMessage    = errors.New("invalid message")
+	ErrInvalidStatus     = query.ErrInvalidStatus
+	ErrNotRetryable      = errors.New("only failed messages can be retried")
+	ErrNotQuarantined    = errors.New("only quarantined messages can be released")
+	ErrNotPending        = errors.New("only pending messages can be prioritized")
+	ErrNotFailed         = errors.New("only failed messages can be triaged")
+	ErrInvalidTriage     = errors.New("invalid triage")
+	ErrInvalidTimeseries = errors.New("invalid time series")
 )
 
+// ErrContentRejected is returned when a message violates a reject rule of the content policy
+var ErrContentRejected = policy.ErrRejected
+
 // MessageInterface defines message-related operations
 type MessageInterface interface {
 	GetSentMessages(ctx context.Context, page, pageSize int) (*dto.MessagesListResponse, error)
+	ListMessages(ctx context.Context, filter db.MessageFilter, page query.Page) (*dto.MessagesListResponse, error)
 	GetMessageByID(ctx context.Context, id string) (*dto.SingleMessageResponse, error)
+	CreateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.SingleMessageResponse, error)
+	ValidateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.ValidateMessageResponse, error)
+	ReleaseMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error)
+	PrioritizeMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error)
+	CancelMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error)
+	TriageMessage(ctx context.Context, id string, req *dto.TriageMessageRequest) (*dto.SingleMessageResponse, error)
+	MessageEvents(ctx context.Context, id string) (*dto.MessageEventsResponse, error)
+	SendAttempts(ctx context.Context, id string) (*dto.SendAttemptsResponse, error)
+	BulkUpdateStatus(ctx context.Context, req *dto.BulkStatusRequest) (*dto.BulkStatusResponse, error)
+	Stats(ctx context.Context) (*dto.StatsResponse, error)
+	Timeseries(ctx context.Context, bucket string, from, to *time.Time) (*dto.TimeseriesResponse, error)
+	Duplicates(ctx context.Context, from, to *time.Time, window time.Duration, limit int) (*dto.DuplicatesResponse, error)
+	Forecast(ctx context.Context, cfg *config.Cfg, opts ForecastOptions) (*dto.ForecastResponse, error)
+	RecipientStats(ctx context.Context, phone string) (*dto.RecipientStatsResponse, error)
 }
 
 type MessageService struct {
-	db *bun.DB
+	db     *bun.DB
+	queue  queue.Queue
+	counts *CountCache
+	// reads coalesces identical concurrent stats, list and time series reads, nil runs each of them
+	reads *coalescer
+	// replica serves the list reads, nil reads them from db
+	replica *replicaReads
+	// sends cancels the sends of sending messages, nil when only pending messages can be cancelled
+	sends SendCanceller
 }
 
 func NewMessageService(database *bun.DB) *MessageService {
+	return NewMessageServiceWithQueue(database, queue.NewPostgres(database))
+}
+
+// NewMessageServiceWithQueue creates a message service enqueueing created messages to q
+func NewMessageServiceWithQueue(database *bun.DB, q queue.Queue) *MessageService {
 	return &MessageService{
-		db: database,
+		db:    database,
+		queue: q,
+	}
+}
+
+// SetCountCache serves the stats and unfiltered list totals from counts while they are fresh
+func (s *MessageService) SetCountCache(counts *CountCache) {
+	s.counts = counts
+}
+
+// CoalesceReads makes identical concurrent stats, list and time series reads share a single query
+func (s *MessageService) CoalesceReads() {
+	s.reads = &coalescer{}
+}
+
+// ReadFromReplica sends the message list reads to replica, see config.Replica
+func (s *MessageService) ReadFromReplica(replica *bun.DB, cfg config.Replica) {
+	s.replica = &replicaReads{replica: replica, cfg: cfg}
+}
+
+// shared returns a copy of a response shared by coalesced reads, the handlers set fields of their response
+func shared[T any](response *T, err error) (*T, error) {
+	if err != nil {
+		return nil, err
+	}
+	copied := *response
+	return &copied, nil
+}
+
+// countsByStatus returns the message counts per status, from the count cache while it is fresh. countedAt is
+// when the cached counts were counted, the zero time when the messages were counted now.
+func (s *MessageService) countsByStatus(ctx context.Context) (counts map[db.MessageStatus]int, countedAt time.Time, err error) {
+	counts, countedAt, ok, err := s.counts.Counts(ctx)
+	if err != nil || ok {
+		return counts, countedAt, err
+	}
+	counts, err = db.CountMessagesByStatus(ctx, s.db)
+	return counts, time.Time{}, err
+}
+
+// cachedTotal returns the number of messages in statuses from the count cache, ok is false when the cache
+// is disabled or stale
+func (s *MessageService) cachedTotal(ctx context.Context, statuses ...db.MessageStatus) (total int, ok bool, err error) {
+	counts, _, ok, err := s.counts.Counts(ctx)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	for _, status := range statuses {
+		total += counts[status]
 	}
+	return total, true, nil
 }
 
 // GetSentMessages retrieves paginated sent messages
@@ -56,40 +153,44 @@ func NewMessageService(database *bun.DB) *MessageService {
 // - pageSize: Number of messages per page (0 = default, must be between 1-100)
 // Returns error if pageSize is invalid (negative or > 100)
 func (s *MessageService) GetSentMessages(ctx context.Context, page, pageSize int) (*dto.MessagesListResponse, error) {
-	// Validate and normalize page number
-	// Pages start from 1, so anything less than 1 defaults to first page
-	if page < MinPage {
-		page = MinPage
-	}
+	return shared(coalesce(ctx, s.reads, "sent_messages", fmt.Sprintf("%d/%d", page, pageSize),
+		func(ctx context.Context) (*dto.MessagesListResponse, error) {
+			return s.getSentMessages(ctx, page, pageSize)
+		}))
+}
 
-	// Validate and normalize page size
-	if pageSize < 0 {
-		return nil, ErrInvalidPageSize
-	}
-	if pageSize == 0 {
-		// If pageSize is 0, use the default page size
-		pageSize = DefaultPageSize
-	}
-	if pageSize > MaxPageSize {
-		return nil, ErrPageSizeTooLarge
-	}
-	if pageSize < MinPageSize {
-		return nil, ErrPageSizeTooSmall
+func (s *MessageService) getSentMessages(ctx context.Context, page, pageSize int) (*dto.MessagesListResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.GetSentMessages")
+	defer span.End()
+
+	page, pageSize, err := normalizePagination(page, pageSize)
+	if err != nil {
+		return nil, err
 	}
 
 	offset := (page - 1) * pageSize
 
 	// Get messages
-	messages, err := db.GetSentMessages(ctx, s.db, pageSize, offset)
+	messages, err := readReplica(ctx, s.replica, s.db, "sent_messages", func(ctx context.Context, database bun.IDB) ([]*db.Message, error) {
+		return db.GetSentMessages(ctx, database, pageSize, offset)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Get total count
-	total, err := db.GetTotalSentMessagesCount(ctx, s.db)
+	total, cached, err := s.cachedTotal(ctx, db.SentStatuses...)
 	if err != nil {
 		return nil, err
 	}
+	if !cached {
+		total, err = readReplica(ctx, s.replica, s.db, "sent_messages_count", func(ctx context.Context, database bun.IDB) (int, error) {
+			return db.GetTotalSentMessagesCount(ctx, database)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Convert to DTOs
 	messageResponses := make([]dto.MessageResponse, len(messages))
@@ -108,11 +209,93 @@ func (s *MessageService) GetSentMessages(ctx context.Context, page, pageSize int
 	}, nil
 }
 
+// ListMessages retrieves a page of the messages of any status matching the filter, by page number or by the
+// cursor of the page before. Pagination rules are the same as GetSentMessages.
+func (s *MessageService) ListMessages(ctx context.Context, filter db.MessageFilter, page query.Page) (*dto.MessagesListResponse, error) {
+	return shared(coalesce(ctx, s.reads, "list_messages", filter.Key()+"/"+page.Key(),
+		func(ctx context.Context) (*dto.MessagesListResponse, error) {
+			return s.listMessages(ctx, filter, page)
+		}))
+}
+
+func (s *MessageService) listMessages(ctx context.Context, filter db.MessageFilter, page query.Page) (*dto.MessagesListResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.ListMessages")
+	defer span.End()
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStatus, filter.Status)
+	}
+
+	page, err := page.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	messagePage, err := page.MessagePage()
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := readReplica(ctx, s.replica, s.db, "list_messages", func(ctx context.Context, database bun.IDB) ([]*db.Message, error) {
+		return db.ListMessages(ctx, database, filter, messagePage)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.countMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	messageResponses := make([]dto.MessageResponse, len(messages))
+	for i, msg := range messages {
+		messageResponses[i] = s.convertToMessageResponse(msg)
+	}
+
+	response := &dto.MessagesListResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Messages:   messageResponses,
+		Total:      total,
+		Page:       page.Number,
+		PageSize:   page.Size,
+		NextCursor: page.NextCursor(messages),
+	}
+	if page.Cursor != "" {
+		// a page continued from a cursor has no number
+		response.Page = 0
+	}
+	return response, nil
+}
+
+// countMessages returns the number of messages matching filter, from the count cache when the filter is
+// on the status only
+func (s *MessageService) countMessages(ctx context.Context, filter db.MessageFilter) (int, error) {
+	byStatus := filter
+	byStatus.Status = ""
+	if byStatus.IsZero() {
+		statuses := db.MessageStatuses
+		if filter.Status != "" {
+			statuses = []db.MessageStatus{filter.Status}
+		}
+		if total, ok, err := s.cachedTotal(ctx, statuses...); err != nil || ok {
+			return total, err
+		}
+	}
+	return readReplica(ctx, s.replica, s.db, "count_messages", func(ctx context.Context, database bun.IDB) (int, error) {
+		return db.CountMessages(ctx, database, filter)
+	})
+}
+
 // GetMessageByID retrieves a single message by its ID
 func (s *MessageService) GetMessageByID(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
-	messageID, err := strconv.ParseInt(id, 10, 64)
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.GetMessageByID")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrInvalidMessageID, err.Error())
+		return nil, err
 	}
 
 	message, err := db.GetMessageByID(ctx, s.db, messageID)
@@ -120,6 +303,132 @@ func (s *MessageService) GetMessageByID(ctx context.Context, id string) (*dto.Si
 		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
 	}
 
+	response := s.convertToMessageResponse(message)
+	// only a single message is answered with the body stored outside its row
+	if err := resolvePayload(ctx, s.db, response.WebhookResponse); err != nil {
+		config.LogFrom(ctx).WithField("message_id", messageID).Warnf("Failed to load the webhook response body: %v", err)
+	}
+
+	return &dto.SingleMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Message: response,
+	}, nil
+}
+
+// MessageEvents returns why the scheduler skipped the message of id, oldest first
+func (s *MessageService) MessageEvents(ctx context.Context, id string) (*dto.MessageEventsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.MessageEvents")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.GetMessageByID(ctx, s.db, messageID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+
+	events, err := db.GetMessageEvents(ctx, s.db, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.MessageEventsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		MessageID: shownMessageID(messageID, id),
+		Events:    make([]dto.MessageEventResponse, len(events)),
+	}
+	for i, event := range events {
+		response.Events[i] = dto.MessageEventResponse{
+			Reason:    event.Reason,
+			Detail:    event.Detail,
+			CreatedAt: event.CreatedAt,
+		}
+	}
+	return response, nil
+}
+
+// SendAttempts returns the webhook requests of every send of the message of id with their timings, oldest first
+func (s *MessageService) SendAttempts(ctx context.Context, id string) (*dto.SendAttemptsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.SendAttempts")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.GetMessageByID(ctx, s.db, messageID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+
+	attempts, err := db.GetSendAttempts(ctx, s.db, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.SendAttemptsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		MessageID: shownMessageID(messageID, id),
+		Attempts:  make([]dto.SendAttemptResponse, len(attempts)),
+	}
+	for i, attempt := range attempts {
+		response.Attempts[i] = dto.SendAttemptResponse{
+			Attempt:    i + 1,
+			Provider:   attempt.Provider,
+			StatusCode: attempt.StatusCode,
+			Error:      attempt.Error,
+			StartedAt:  attempt.StartedAt,
+			FinishedAt: attempt.FinishedAt,
+			DurationMS: attempt.FinishedAt.Sub(attempt.StartedAt).Milliseconds(),
+		}
+	}
+	if len(attempts) > 0 {
+		first, last := attempts[0].StartedAt, attempts[len(attempts)-1].FinishedAt
+		response.FirstAttemptAt, response.LastAttemptAt = &first, &last
+	}
+	return response, nil
+}
+
+// parseMessageID returns the bigint ID of the message id refers to, by its bigint ID or its ULID or UUID public ID.
+// Both are accepted for every message, the clients that stored bigint IDs keep working once public IDs are given.
+func parseMessageID(ctx context.Context, database bun.IDB, id string) (int64, error) {
+	if messageID, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return messageID, nil
+	}
+	publicID, ok := db.ParsePublicID(id)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is neither a number, a ULID nor a UUID", ErrInvalidMessageID, id)
+	}
+	messageID, err := db.GetMessageIDByPublicID(ctx, database, publicID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	return messageID, nil
+}
+
+// CreateMessage validates and enqueues a new message
+// A SendAt in the future delays the message until that time, higher priorities are claimed first
+func (s *MessageService) CreateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.SingleMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.CreateMessage")
+	defer span.End()
+
+	message, err := ingest.NewMessage(*req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMessage, err.Error())
+	}
+
+	if err := s.queue.Enqueue(ctx, message); err != nil {
+		return nil, err
+	}
+
 	return &dto.SingleMessageResponse{
 		BaseResponse: dto.BaseResponse{
 			Status: "ok",
@@ -128,25 +437,558 @@ func (s *MessageService) GetMessageByID(ctx context.Context, id string) (*dto.Si
 	}, nil
 }
 
+// ValidateMessage validates a message like CreateMessage without enqueueing it and returns its encoding and segment count
+func (s *MessageService) ValidateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.ValidateMessageResponse, error) {
+	_, span := telemetry.Tracer().Start(ctx, "MessageService.ValidateMessage")
+	defer span.End()
+
+	message, err := ingest.NewMessage(*req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMessage, err.Error())
+	}
+
+	encoding, segments, length := sms.Encode(message.Content)
+	return &dto.ValidateMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Encoding: string(encoding),
+		Segments: segments,
+		Length:   length,
+	}, nil
+}
+
+// ExportMessages streams every message matching the filter to fn in ID order
+func (s *MessageService) ExportMessages(ctx context.Context, filter db.MessageFilter, fn func(dto.MessageResponse) error) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.ExportMessages")
+	defer span.End()
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidStatus, filter.Status)
+	}
+
+	return db.ForEachMessage(ctx, s.db, filter, ExportBatchSize, func(msg *db.Message) error {
+		return fn(s.convertToMessageResponse(msg))
+	})
+}
+
+// RetryMessage moves a single failed message back to the queue
+// With dryRun set nothing is changed, the returned count is what would have been requeued
+func (s *MessageService) RetryMessage(ctx context.Context, id string, dryRun bool) (int, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.RetryMessage")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return 0, err
+	}
+
+	message, err := db.GetMessageByID(ctx, s.db, messageID)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	if message.Status != db.MessageStatusFailed {
+		return 0, fmt.Errorf("%w: message %d is %s", ErrNotRetryable, message.ID, message.Status)
+	}
+
+	if dryRun {
+		return 1, nil
+	}
+
+	if err := db.RequeueMessage(ctx, s.db, messageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: message %d changed status concurrently", ErrNotRetryable, messageID)
+		}
+		return 0, err
+	}
+	return 1, nil
+}
+
+// ReleaseMessage moves a message quarantined by the content policy to the queue so it is sent
+func (s *MessageService) ReleaseMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.ReleaseMessage")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := db.GetMessageByID(ctx, s.db, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	if message.Status != db.MessageStatusQuarantined {
+		return nil, fmt.Errorf("%w: message %d is %s", ErrNotQuarantined, message.ID, message.Status)
+	}
+
+	if err := db.ReleaseMessage(ctx, s.db, messageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: message %d changed status concurrently", ErrNotQuarantined, messageID)
+		}
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("message_id", messageID).Info("Quarantined message released")
+
+	message.Status = db.MessageStatusPending
+	return &dto.SingleMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Message: s.convertToMessageResponse(message),
+	}, nil
+}
+
+// PrioritizeMessage moves a pending message to the front of the queue so it is claimed next, e.g. when
+// a notification of a customer must go out now
+func (s *MessageService) PrioritizeMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.PrioritizeMessage")
+	defer span.End()
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := db.GetMessageByID(ctx, s.db, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	if current.Status != db.MessageStatusPending {
+		return nil, fmt.Errorf("%w: message %d is %s", ErrNotPending, current.ID, current.Status)
+	}
+
+	message, err := db.PrioritizeMessage(ctx, s.db, messageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: message %d changed status concurrently", ErrNotPending, messageID)
+		}
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("message_id", messageID).
+		Infof("Message prioritized (priority: %d -> %d)", current.Priority, message.Priority)
+
+	return &dto.SingleMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Message: s.convertToMessageResponse(message),
+	}, nil
+}
+
+// TriageMessage sets the operator note of a failed message and acknowledges its failure for the API key of
+// ctx, or withdraws the acknowledgment, so the next on-call knows which failures were investigated already
+func (s *MessageService) TriageMessage(ctx context.Context, id string, req *dto.TriageMessageRequest) (*dto.SingleMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.TriageMessage")
+	defer span.End()
+
+	if req.Note == nil && req.Acknowledged == nil {
+		return nil, fmt.Errorf("%w: note or acknowledged is required", ErrInvalidTriage)
+	}
+	if req.Note != nil && len(*req.Note) > MaxNoteLength {
+		return nil, fmt.Errorf("%w: note must be at most %d bytes", ErrInvalidTriage, MaxNoteLength)
+	}
+
+	messageID, err := parseMessageID(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := db.GetMessageByID(ctx, s.db, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, err.Error())
+	}
+	if current.Status != db.MessageStatusFailed {
+		return nil, fmt.Errorf("%w: message %d is %s", ErrNotFailed, current.ID, current.Status)
+	}
+
+	message, err := db.TriageMessage(ctx, s.db, messageID, db.MessageTriage{
+		Note:         req.Note,
+		Acknowledged: req.Acknowledged,
+		By:           quota.APIKeyFrom(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: message %d changed status concurrently", ErrNotFailed, messageID)
+		}
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("message_id", messageID).
+		Infof("Message triaged (acknowledged: %t)", message.AcknowledgedAt != nil)
+
+	return &dto.SingleMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status: "ok",
+		},
+		Message: s.convertToMessageResponse(message),
+	}, nil
+}
+
+// RetryFailedMessages moves all failed messages matching the filter back to the queue
+// With dryRun set nothing is changed, the returned count is what would have been requeued
+func (s *MessageService) RetryFailedMessages(ctx context.Context, filter db.MessageFilter, dryRun bool) (int, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.RetryFailedMessages")
+	defer span.End()
+
+	filter.Status = db.MessageStatusFailed
+
+	if dryRun {
+		return db.CountMessages(ctx, s.db, filter)
+	}
+	return db.RequeueFailedMessages(ctx, s.db, filter)
+}
+
+// PurgeOptions controls how PurgeMessages deletes messages
+type PurgeOptions struct {
+	// BatchSize is the number of messages deleted per statement, defaults to ExportBatchSize
+	BatchSize int
+	// Pause is waited between batches to limit the load on the database
+	Pause time.Duration
+	// DryRun only counts the messages that would be deleted
+	DryRun bool
+	// Archive is called with every batch before it is deleted, an error aborts the purge
+	Archive func([]dto.MessageResponse) error
+	// OnProgress is called after every deleted batch with the number of messages deleted so far
+	OnProgress func(deleted int)
+}
+
+// PurgeMessages deletes the messages matching the filter in batches and returns how many were deleted
+func (s *MessageService) PurgeMessages(ctx context.Context, filter db.MessageFilter, opts PurgeOptions) (int, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.PurgeMessages")
+	defer span.End()
+
+	if opts.DryRun {
+		return db.CountMessages(ctx, s.db, filter)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = ExportBatchSize
+	}
+
+	var deleted int
+	for {
+		messages, err := db.ListMessages(ctx, s.db, filter, db.MessagePage{Limit: opts.BatchSize})
+		if err != nil {
+			return deleted, err
+		}
+		if len(messages) == 0 {
+			return deleted, nil
+		}
+
+		ids := make([]int64, len(messages))
+		batch := make([]dto.MessageResponse, len(messages))
+		for i, msg := range messages {
+			ids[i] = msg.ID
+			batch[i] = s.convertToArchivedMessage(msg)
+		}
+
+		if opts.Archive != nil {
+			if err := opts.Archive(batch); err != nil {
+				return deleted, err
+			}
+		}
+
+		if err := db.DeleteMessageEvents(ctx, s.db, ids); err != nil {
+			return deleted, err
+		}
+		if err := db.DeleteMessagePayloads(ctx, s.db, ids); err != nil {
+			return deleted, err
+		}
+		if err := db.DeleteSendAttempts(ctx, s.db, ids); err != nil {
+			return deleted, err
+		}
+		n, err := db.DeleteMessagesByID(ctx, s.db, ids)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+		if opts.OnProgress != nil {
+			opts.OnProgress(deleted)
+		}
+
+		if len(messages) < opts.BatchSize {
+			return deleted, nil
+		}
+		if opts.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return deleted, ctx.Err()
+			case <-time.After(opts.Pause):
+			}
+		}
+	}
+}
+
+// RestoreMessages inserts archived messages back with their IDs and statuses and returns how many were
+// inserted, messages that exist already are skipped. The unit price is derived from the archived cost.
+func (s *MessageService) RestoreMessages(ctx context.Context, messages []dto.MessageResponse) (int, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.RestoreMessages")
+	defer span.End()
+
+	now := time.Now()
+	restored := make([]*db.Message, len(messages))
+	for i, msg := range messages {
+		message := &db.Message{
+			ID:               msg.ID,
+			PublicID:         msg.PublicID,
+			To:               msg.To,
+			Content:          msg.Content,
+			Status:           db.MessageStatus(msg.Status),
+			Priority:         msg.Priority,
+			Tenant:           msg.Tenant,
+			Campaign:         msg.Campaign,
+			From:             msg.From,
+			Encoding:         sms.Encoding(msg.Encoding),
+			Segments:         msg.Segments,
+			ScheduledAt:      msg.ScheduledAt,
+			ExpiresAt:        msg.ExpiresAt,
+			CallbackURL:      msg.CallbackURL,
+			Timezone:         msg.Timezone,
+			SentAt:           msg.SentAt,
+			MessageID:        msg.MessageID,
+			DeliveredAt:      msg.DeliveredAt,
+			DeliveryError:    msg.DeliveryError,
+			PolicyViolations: msg.PolicyViolations,
+			Provider:         msg.Provider,
+			SenderID:         msg.SenderID,
+			ReplayOf:         msg.ReplayOf,
+			Metadata:         msg.Metadata,
+			CreatedAt:        msg.CreatedAt,
+			UpdatedAt:        now,
+		}
+		if !message.Status.IsValid() {
+			return 0, fmt.Errorf("message %d has unknown status %q", msg.ID, msg.Status)
+		}
+		if msg.Segments > 0 {
+			message.UnitPrice = msg.Cost / float64(msg.Segments)
+		}
+		if msg.WebhookResponse != nil {
+			data, err := json.Marshal(msg.WebhookResponse)
+			if err != nil {
+				return 0, err
+			}
+			webhookResponse := string(data)
+			message.WebhookResponse = &webhookResponse
+		}
+		restored[i] = message
+	}
+	return db.RestoreMessages(ctx, s.db, restored)
+}
+
+// Stats returns message counts per status, today's throughput and failure rate
+// and the age of the oldest pending message
+func (s *MessageService) Stats(ctx context.Context) (*dto.StatsResponse, error) {
+	return shared(coalesce(ctx, s.reads, "stats", "", s.stats))
+}
+
+func (s *MessageService) stats(ctx context.Context) (*dto.StatsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.Stats")
+	defer span.End()
+
+	now := time.Now()
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	counts, countedAt, err := s.countsByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sentToday, err := db.CountSentSince(ctx, s.db, today)
+	if err != nil {
+		return nil, err
+	}
+
+	failedToday, err := db.CountFailedSince(ctx, s.db, today)
+	if err != nil {
+		return nil, err
+	}
+
+	oldest, err := db.GetOldestPendingMessage(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.StatsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: now,
+		},
+		Counts:      make(map[string]int),
+		SentToday:   sentToday,
+		FailedToday: failedToday,
+	}
+	if !countedAt.IsZero() {
+		response.CountsCachedAt = &countedAt
+	}
+	for _, status := range db.MessageStatuses {
+		response.Counts[string(status)] = counts[status]
+		response.Total += counts[status]
+	}
+	if sentToday+failedToday > 0 {
+		response.FailureRate = float64(failedToday) / float64(sentToday+failedToday)
+	}
+	if oldest != nil {
+		response.OldestPendingAt = &oldest.CreatedAt
+		response.OldestPendingAgeSeconds = int64(now.Sub(oldest.CreatedAt).Seconds())
+	}
+
+	return response, nil
+}
+
+// Time series limits, without from the series has DefaultTimeseriesPoints points
+const (
+	DefaultTimeseriesPoints = 24
+	MaxTimeseriesPoints     = 1000
+)
+
+// Timeseries counts the messages created, sent and failed per UTC minute, hour, day or week in [from, to).
+// to defaults to now, from to DefaultTimeseriesPoints buckets before to.
+func (s *MessageService) Timeseries(ctx context.Context, bucket string, from, to *time.Time) (*dto.TimeseriesResponse, error) {
+	key := db.MessageFilter{From: from, To: to}.Key()
+	return shared(coalesce(ctx, s.reads, "timeseries", bucket+"/"+key,
+		func(ctx context.Context) (*dto.TimeseriesResponse, error) {
+			return s.timeseries(ctx, bucket, from, to)
+		}))
+}
+
+func (s *MessageService) timeseries(ctx context.Context, bucket string, from, to *time.Time) (*dto.TimeseriesResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.Timeseries")
+	defer span.End()
+
+	b := db.TimeseriesBucket(bucket)
+	if !b.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimeseries, db.ErrInvalidTimeseriesBucket.Error())
+	}
+
+	end := time.Now().UTC()
+	if to != nil {
+		end = to.UTC()
+	}
+	start := b.Truncate(end)
+	if from != nil {
+		start = b.Truncate(*from)
+	} else {
+		for range DefaultTimeseriesPoints - 1 {
+			start = b.Truncate(start.Add(-time.Second))
+		}
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidTimeseries)
+	}
+	points := 0
+	for t := start; t.Before(end) && points <= MaxTimeseriesPoints; t = b.Next(t) {
+		points++
+	}
+	if points > MaxTimeseriesPoints {
+		return nil, fmt.Errorf("%w: at most %d points, use a larger bucket or a shorter window", ErrInvalidTimeseries, MaxTimeseriesPoints)
+	}
+
+	series, err := db.GetTimeseries(ctx, s.db, b, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.TimeseriesResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Bucket: bucket,
+		From:   start,
+		To:     end,
+		Points: make([]dto.TimeseriesPoint, len(series)),
+	}
+	for i, point := range series {
+		response.Points[i] = dto.TimeseriesPoint{
+			Time:    point.Time,
+			Created: point.Created,
+			Sent:    point.Sent,
+			Failed:  point.Failed,
+		}
+	}
+	return response, nil
+}
+
+// normalizePagination validates and normalizes page and page size like query.Page.Normalize
+func normalizePagination(page, pageSize int) (int, int, error) {
+	normalized, err := query.Page{Number: page, Size: pageSize}.Normalize()
+	if err != nil {
+		return 0, 0, err
+	}
+	return normalized.Number, normalized.Size, nil
+}
+
+// shownMessageID returns the bigint ID of a message as shown to API clients, ref being its public ID or its bigint
+// ID. It is 0, left out of the response, for messages known by their public ID.
+func shownMessageID(messageID int64, ref string) int64 {
+	if _, ok := db.ParsePublicID(ref); ok {
+		return 0
+	}
+	return messageID
+}
+
+// convertToArchivedMessage converts db.Message to the dto.MessageResponse archived by a purge, which always has
+// the bigint ID the message is restored with
+func (s *MessageService) convertToArchivedMessage(msg *db.Message) dto.MessageResponse {
+	response := s.convertToMessageResponse(msg)
+	response.ID, response.ReplayOf = msg.ID, msg.ReplayOf
+	return response
+}
+
 // convertToMessageResponse converts db.Message to dto.MessageResponse
 func (s *MessageService) convertToMessageResponse(msg *db.Message) dto.MessageResponse {
 	response := dto.MessageResponse{
-		ID:        msg.ID,
-		To:        msg.To,
-		Content:   msg.Content,
-		Status:    string(msg.Status),
-		SentAt:    msg.SentAt,
-		MessageID: msg.MessageID,
-		CreatedAt: msg.CreatedAt,
+		ID:               shownMessageID(msg.ID, msg.PublicID),
+		PublicID:         msg.PublicID,
+		To:               msg.To,
+		Content:          msg.Content,
+		RenderedContent:  msg.RenderedContent,
+		Status:           string(msg.Status),
+		Priority:         msg.Priority,
+		Tenant:           msg.Tenant,
+		Campaign:         msg.Campaign,
+		From:             msg.From,
+		Encoding:         string(msg.Encoding),
+		Segments:         msg.Segments,
+		ScheduledAt:      msg.ScheduledAt,
+		ExpiresAt:        msg.ExpiresAt,
+		CallbackURL:      msg.CallbackURL,
+		Timezone:         msg.Timezone,
+		SentAt:           msg.SentAt,
+		MessageID:        msg.MessageID,
+		DeliveredAt:      msg.DeliveredAt,
+		DeliveryError:    msg.DeliveryError,
+		PolicyViolations: msg.PolicyViolations,
+		Provider:         msg.Provider,
+		SenderID:         msg.SenderID,
+		Cost:             roundCost(msg.Cost()),
+		ReplayOf:         msg.ReplayOf,
+		Region:           msg.Region,
+		FailureClass:     msg.FailureClass,
+		FailureError:     msg.FailureError,
+		Requeues:         msg.Requeues,
+		DeadLetteredAt:   msg.DeadLetteredAt,
+		Note:             msg.Note,
+		AcknowledgedAt:   msg.AcknowledgedAt,
+		AcknowledgedBy:   msg.AcknowledgedBy,
+		Metadata:         msg.Metadata,
+		CreatedAt:        msg.CreatedAt,
 	}
 
 	// Parse webhook response if exists
 	if msg.WebhookResponse != nil {
 		var webhookResp map[string]any
 		if err := json.Unmarshal([]byte(*msg.WebhookResponse), &webhookResp); err == nil {
+			if err := decodeStoredBody(webhookResp); err != nil {
+				config.Log().WithField("message_id", msg.ID).Warnf("Failed to decompress the webhook response body: %v", err)
+			}
 			response.WebhookResponse = webhookResp
 		}
 	}
+	if response.ID == 0 {
+		// the message replayed is a bigint ID as well
+		response.ReplayOf = nil
+	}
 
 	return response
 }
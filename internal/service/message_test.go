@@ -4,10 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uptrace/bun"
@@ -25,6 +33,40 @@ func setupTestDB(t *testing.T) *bun.DB {
 	// Create table structure to match production schema
 	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).Exec(context.Background())
 	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Suppression)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Link)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Lease)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Erasure)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Control)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.MessageCount)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.JobRun)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.MessageEvent)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.IngestionJob)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.WebhookOverride)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.MessagePayload)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.SendAttempt)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.RecipientPause)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Campaign)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.CampaignRecipient)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.Job)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	_, err = bunDB.NewCreateTable().Model((*db.JobResult)(nil)).Exec(context.Background())
+	require.NoError(t, err)
 
 	return bunDB
 }
@@ -165,6 +207,165 @@ func TestMessageService_GetSentMessages_WithData(t *testing.T) {
 	}
 }
 
+func TestMessageService_ListMessages(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	now := time.Now().UTC()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Old failure", Status: db.MessageStatusFailed, CreatedAt: now.Add(-48 * time.Hour)},
+		{To: "+905552222222", Content: "Recent failure", Status: db.MessageStatusFailed, CreatedAt: now.Add(-time.Hour)},
+		{To: "+905553333333", Content: "Pending", Status: db.MessageStatusPending, CreatedAt: now},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+
+	t.Run("no filter returns all statuses newest first", func(t *testing.T) {
+		result, err := service.ListMessages(context.Background(), db.MessageFilter{}, query.Page{Number: 1, Size: 20})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, result.Total)
+		assert.Equal(t, "Pending", result.Messages[0].Content)
+	})
+
+	t.Run("status and date filters", func(t *testing.T) {
+		from := now.Add(-24 * time.Hour)
+		result, err := service.ListMessages(context.Background(), db.MessageFilter{
+			Status: db.MessageStatusFailed,
+			From:   &from,
+		}, query.Page{Number: 1, Size: 20})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, "Recent failure", result.Messages[0].Content)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		result, err := service.ListMessages(context.Background(), db.MessageFilter{Status: "unknown"}, query.Page{Number: 1, Size: 20})
+
+		assert.True(t, errors.Is(err, ErrInvalidStatus))
+		assert.Nil(t, result)
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		result, err := service.ListMessages(context.Background(), db.MessageFilter{}, query.Page{Number: 1, Size: MaxPageSize + 1})
+
+		assert.True(t, errors.Is(err, ErrPageSizeTooLarge))
+		assert.Nil(t, result)
+	})
+
+	t.Run("cursor pages", func(t *testing.T) {
+		// created at the same time as the pending message, ties are ordered by ID
+		tie := &db.Message{To: "+905554444444", Content: "Pending too", Status: db.MessageStatusPending, CreatedAt: now}
+		_, err := testDB.NewInsert().Model(tie).Exec(context.Background())
+		require.NoError(t, err)
+
+		for _, tt := range []struct {
+			sort     string
+			expected []string
+		}{
+			{query.SortNewest, []string{"Pending too", "Pending", "Recent failure", "Old failure"}},
+			{query.SortOldest, []string{"Old failure", "Recent failure", "Pending", "Pending too"}},
+		} {
+			var contents []string
+			page := query.Page{Size: 3, Sort: tt.sort}
+			for {
+				result, err := service.ListMessages(context.Background(), db.MessageFilter{}, page)
+				require.NoError(t, err)
+				for _, msg := range result.Messages {
+					contents = append(contents, msg.Content)
+				}
+				if result.NextCursor == "" {
+					break
+				}
+				page.Cursor = result.NextCursor
+			}
+			assert.Equal(t, tt.expected, contents, tt.sort)
+		}
+	})
+}
+
+func TestMessageService_Metadata(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	service := NewMessageService(testDB)
+	for _, req := range []*dto.CreateMessageRequest{
+		{To: "+905551111111", Content: "Order shipped", Metadata: map[string]any{"order_id": "1001", "tags": []any{"vip", "shipping"}}},
+		{To: "+905552222222", Content: "Order shipped", Metadata: map[string]any{"order_id": "1002", "tags": []any{"shipping"}}},
+		{To: "+905553333333", Content: "No metadata"},
+	} {
+		_, err := service.CreateMessage(ctx, req)
+		require.NoError(t, err)
+	}
+
+	t.Run("filters by tag and metadata", func(t *testing.T) {
+		result, err := service.ListMessages(ctx, db.MessageFilter{Tag: "shipping"}, query.Page{Number: 1, Size: 20})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Total)
+
+		result, err = service.ListMessages(ctx, db.MessageFilter{Tag: "shipping", Metadata: map[string]string{"order_id": "1001"}}, query.Page{Number: 1, Size: 20})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Total)
+		assert.Equal(t, "+905551111111", result.Messages[0].To)
+		assert.Equal(t, "1001", result.Messages[0].Metadata["order_id"])
+		assert.Equal(t, []any{"vip", "shipping"}, result.Messages[0].Metadata["tags"])
+
+		result, err = service.ListMessages(ctx, db.MessageFilter{Tag: "vip", Metadata: map[string]string{"order_id": "1002"}}, query.Page{Number: 1, Size: 20})
+		require.NoError(t, err)
+		assert.Zero(t, result.Total)
+	})
+
+	t.Run("rejects invalid metadata", func(t *testing.T) {
+		for _, metadata := range []map[string]any{
+			{"order_id": 1001},
+			{"tags": "vip"},
+			{"tags": []any{"not a tag"}},
+			{"": "empty key"},
+		} {
+			_, err := service.CreateMessage(ctx, &dto.CreateMessageRequest{To: "+905554444444", Content: "Hello", Metadata: metadata})
+			assert.ErrorIs(t, err, ErrInvalidMessage, "%v", metadata)
+		}
+	})
+}
+
+func TestMessageService_ExportMessages(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	for i := 0; i < ExportBatchSize+5; i++ {
+		status := db.MessageStatusSent
+		if i%2 == 0 {
+			status = db.MessageStatusFailed
+		}
+		msg := &db.Message{To: "+905551111111", Content: "Test message", Status: status}
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+
+	var exported []dto.MessageResponse
+	err := service.ExportMessages(context.Background(), db.MessageFilter{Status: db.MessageStatusFailed}, func(msg dto.MessageResponse) error {
+		exported = append(exported, msg)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Len(t, exported, (ExportBatchSize+6)/2) // Spans more than one batch
+	for i, msg := range exported {
+		assert.Equal(t, "failed", msg.Status)
+		if i > 0 {
+			assert.Greater(t, msg.ID, exported[i-1].ID)
+		}
+	}
+}
+
 func TestMessageService_GetMessageByID(t *testing.T) {
 	testDB := setupTestDB(t)
 	defer testDB.Close()
@@ -211,6 +412,609 @@ func TestMessageService_GetMessageByID(t *testing.T) {
 	})
 }
 
+func TestMessageService_GetMessageByPublicID(t *testing.T) {
+	for _, strategy := range []db.IDStrategy{db.IDStrategyULID, db.IDStrategyUUID} {
+		t.Run(string(strategy), func(t *testing.T) {
+			testDB := setupTestDB(t)
+			defer testDB.Close()
+
+			q := queue.NewPostgres(testDB)
+			q.SetIDStrategy(strategy)
+			msg := &db.Message{To: "+905551111111", Content: "Test message"}
+			require.NoError(t, q.Enqueue(context.Background(), msg))
+			require.NotEmpty(t, msg.PublicID)
+			publicID, ok := db.ParsePublicID(msg.PublicID)
+			require.True(t, ok)
+			assert.Equal(t, msg.PublicID, publicID)
+
+			service := NewMessageServiceWithQueue(testDB, q)
+			for _, id := range []string{msg.PublicID, strings.ToLower(msg.PublicID)} {
+				result, err := service.GetMessageByID(context.Background(), id)
+				require.NoError(t, err, id)
+				assert.Zero(t, result.Message.ID, "the bigint ID is not shown")
+				assert.Equal(t, msg.PublicID, result.Message.PublicID)
+			}
+
+			// the bigint IDs stored by clients are still accepted, only the ones of messages without a public ID are shown
+			result, err := service.GetMessageByID(context.Background(), strconv.FormatInt(msg.ID, 10))
+			require.NoError(t, err)
+			assert.Equal(t, msg.PublicID, result.Message.PublicID)
+			assert.Zero(t, result.Message.ID)
+			legacy := &db.Message{To: "+905552222222", Content: "Created with the bigint strategy"}
+			_, err = testDB.NewInsert().Model(legacy).Exec(context.Background())
+			require.NoError(t, err)
+			result, err = service.GetMessageByID(context.Background(), strconv.FormatInt(legacy.ID, 10))
+			require.NoError(t, err)
+			assert.Equal(t, legacy.ID, result.Message.ID)
+			_, err = service.GetMessageByID(context.Background(), "999")
+			assert.ErrorIs(t, err, ErrMessageNotFound)
+
+			// a well formed public ID of no message is not found, anything else is invalid
+			other := "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+			if strategy == db.IDStrategyUUID {
+				other = "0192f0c4-7e4b-7c3a-9d1e-2f6a8b0c1d2e"
+			}
+			_, err = service.GetMessageByID(context.Background(), other)
+			assert.ErrorIs(t, err, ErrMessageNotFound)
+			_, err = service.GetMessageByID(context.Background(), "81ARZ3NDEKTSV4RRFFQ69G5FAV")
+			assert.ErrorIs(t, err, ErrInvalidMessageID)
+		})
+	}
+
+	t.Run("bigint", func(t *testing.T) {
+		testDB := setupTestDB(t)
+		defer testDB.Close()
+
+		msg := &db.Message{To: "+905551111111", Content: "Test message"}
+		require.NoError(t, db.CreateMessage(context.Background(), testDB, msg))
+		assert.Empty(t, msg.PublicID)
+	})
+}
+
+func TestMessageService_CreateMessage(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	service := NewMessageService(testDB)
+
+	t.Run("valid message", func(t *testing.T) {
+		sendAt := time.Now().Add(time.Hour)
+		result, err := service.CreateMessage(context.Background(), &dto.CreateMessageRequest{
+			To:       "+905551111111",
+			Content:  "Test message",
+			Priority: 5,
+			SendAt:   &sendAt,
+			Tenant:   "acme",
+			Campaign: "spring-sale",
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Equal(t, "pending", result.Message.Status)
+		assert.Equal(t, 5, result.Message.Priority)
+		assert.Equal(t, "acme", result.Message.Tenant)
+		assert.Equal(t, "spring-sale", result.Message.Campaign)
+		assert.NotNil(t, result.Message.ScheduledAt)
+		assert.NotZero(t, result.Message.ID)
+		assert.Equal(t, "gsm7", result.Message.Encoding)
+		assert.Equal(t, 1, result.Message.Segments)
+	})
+
+	t.Run("ucs2 message", func(t *testing.T) {
+		result, err := service.CreateMessage(context.Background(), &dto.CreateMessageRequest{
+			To:      "+905551111111",
+			Content: strings.Repeat("ş", 71),
+		})
+
+		require.NoError(t, err)
+		stored, err := db.GetMessageByID(context.Background(), testDB, result.Message.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sms.EncodingUCS2, stored.Encoding)
+		assert.Equal(t, 2, stored.Segments)
+	})
+
+	tests := []struct {
+		name string
+		req  *dto.CreateMessageRequest
+	}{
+		{
+			name: "invalid phone number",
+			req:  &dto.CreateMessageRequest{To: "05551111111", Content: "Test message"},
+		},
+		{
+			name: "empty content",
+			req:  &dto.CreateMessageRequest{To: "+905551111111"},
+		},
+		{
+			name: "content too long",
+			req:  &dto.CreateMessageRequest{To: "+905551111111", Content: strings.Repeat("a", 10*sms.GSM7MultipartSegment+1)},
+		},
+		{
+			name: "invalid campaign",
+			req:  &dto.CreateMessageRequest{To: "+905551111111", Content: "Test message", Campaign: "spring sale"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.CreateMessage(context.Background(), tt.req)
+
+			assert.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidMessage))
+			assert.Nil(t, result)
+		})
+	}
+}
+
+func TestMessageService_ValidateMessage(t *testing.T) {
+	service := NewMessageService(nil)
+
+	result, err := service.ValidateMessage(context.Background(), &dto.CreateMessageRequest{To: "+905551111111", Content: "Kampanya başladı"})
+	require.NoError(t, err)
+	assert.Equal(t, "ucs2", result.Encoding)
+	assert.Equal(t, 1, result.Segments)
+	assert.Equal(t, 16, result.Length)
+
+	_, err = service.ValidateMessage(context.Background(), &dto.CreateMessageRequest{To: "05551111111", Content: "Hello"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	// the length limit is the number of segments in the encoding of the content, not its bytes
+	db.SetMaxSegments(2)
+	t.Cleanup(func() { db.SetMaxSegments(config.DefaultMaxSegments) })
+	result, err = service.ValidateMessage(context.Background(), &dto.CreateMessageRequest{To: "+905551111111", Content: strings.Repeat("ş", 2*sms.UCS2MultipartSegment)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Segments)
+
+	_, err = service.ValidateMessage(context.Background(), &dto.CreateMessageRequest{To: "+905551111111", Content: strings.Repeat("ş", 2*sms.UCS2MultipartSegment+1)})
+	assert.ErrorContains(t, err, db.ErrMessageTooLong.Error())
+	_, err = service.ValidateMessage(context.Background(), &dto.CreateMessageRequest{To: "+905551111111", Content: strings.Repeat("a", 2*sms.GSM7MultipartSegment)})
+	assert.NoError(t, err)
+}
+
+func TestMessageService_RetryMessages(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Failed 1", Status: db.MessageStatusFailed},
+		{To: "+905552222222", Content: "Failed 2", Status: db.MessageStatusFailed},
+		{To: "+905553333333", Content: "Sent", Status: db.MessageStatusSent},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		count, err := service.RetryFailedMessages(context.Background(), db.MessageFilter{}, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		failed, err := db.CountMessages(context.Background(), testDB, db.MessageFilter{Status: db.MessageStatusFailed})
+		require.NoError(t, err)
+		assert.Equal(t, 2, failed)
+	})
+
+	t.Run("sent message is not retryable", func(t *testing.T) {
+		_, err := service.RetryMessage(context.Background(), "3", false)
+
+		assert.True(t, errors.Is(err, ErrNotRetryable))
+	})
+
+	t.Run("retry single message", func(t *testing.T) {
+		count, err := service.RetryMessage(context.Background(), "1", false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		msg, err := db.GetMessageByID(context.Background(), testDB, 1)
+		require.NoError(t, err)
+		assert.Equal(t, db.MessageStatusPending, msg.Status)
+	})
+
+	t.Run("retry all remaining failed messages", func(t *testing.T) {
+		count, err := service.RetryFailedMessages(context.Background(), db.MessageFilter{}, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestMessageService_ReleaseMessage(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Sale at https://shop.io", Status: db.MessageStatusQuarantined, PolicyViolations: []string{"links"}},
+		{To: "+905552222222", Content: "Hello", Status: db.MessageStatusPending},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+
+	response, err := service.ReleaseMessage(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", response.Message.Status)
+	assert.Equal(t, []string{"links"}, response.Message.PolicyViolations)
+
+	msg, err := db.GetMessageByID(context.Background(), testDB, 1)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusPending, msg.Status)
+
+	_, err = service.ReleaseMessage(context.Background(), "1")
+	assert.True(t, errors.Is(err, ErrNotQuarantined))
+	_, err = service.ReleaseMessage(context.Background(), "2")
+	assert.True(t, errors.Is(err, ErrNotQuarantined))
+	_, err = service.ReleaseMessage(context.Background(), "99")
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+}
+
+func TestMessageService_PrioritizeMessage(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	later := time.Now().Add(time.Hour)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Order shipped", Status: db.MessageStatusPending, ScheduledAt: &later},
+		{To: "+905552222222", Content: "Campaign", Status: db.MessageStatusPending, Priority: 5},
+		{To: "+905553333333", Content: "Reminder", Status: db.MessageStatusPending, Priority: 3},
+		{To: "+905554444444", Content: "Sent", Status: db.MessageStatusSent, Priority: 9},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+
+	response, err := service.PrioritizeMessage(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, 6, response.Message.Priority, "above every other pending message, sent ones do not count")
+	assert.Nil(t, response.Message.ScheduledAt)
+
+	msg, err := db.GetMessageByID(context.Background(), testDB, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 6, msg.Priority)
+	assert.Nil(t, msg.ScheduledAt)
+
+	response, err = service.PrioritizeMessage(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, 6, response.Message.Priority, "a message already in front keeps its priority")
+
+	_, err = service.PrioritizeMessage(context.Background(), "4")
+	assert.True(t, errors.Is(err, ErrNotPending))
+	_, err = service.PrioritizeMessage(context.Background(), "99")
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+	_, err = service.PrioritizeMessage(context.Background(), "abc")
+	assert.True(t, errors.Is(err, ErrInvalidMessageID))
+}
+
+func TestMessageService_TriageMessage(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Order shipped", Status: db.MessageStatusFailed, FailureClass: "provider_permanent"},
+		{To: "+905552222222", Content: "Reminder", Status: db.MessageStatusFailed, FailureClass: "network"},
+		{To: "+905553333333", Content: "Sent", Status: db.MessageStatusSent},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+	note, acknowledged := "Number ported, asked the customer for a new one", true
+
+	response, err := service.TriageMessage(quota.ContextWithAPIKey(ctx, "oncall"), "1",
+		&dto.TriageMessageRequest{Note: &note, Acknowledged: &acknowledged})
+	require.NoError(t, err)
+	assert.Equal(t, note, response.Message.Note)
+	require.NotNil(t, response.Message.AcknowledgedAt)
+	assert.Equal(t, "oncall", response.Message.AcknowledgedBy)
+
+	t.Run("acknowledging again keeps the first acknowledgment", func(t *testing.T) {
+		response, err := service.TriageMessage(quota.ContextWithAPIKey(ctx, "other"), "1", &dto.TriageMessageRequest{Acknowledged: &acknowledged})
+		require.NoError(t, err)
+		assert.Equal(t, "oncall", response.Message.AcknowledgedBy)
+		assert.Equal(t, note, response.Message.Note, "a note left out is kept")
+	})
+
+	t.Run("listings filter by acknowledgment", func(t *testing.T) {
+		open := false
+		list, err := service.ListMessages(ctx, db.MessageFilter{Status: db.MessageStatusFailed, Acknowledged: &open}, query.Page{Number: 1, Size: 10})
+		require.NoError(t, err)
+		require.Len(t, list.Messages, 1)
+		assert.Equal(t, int64(2), list.Messages[0].ID)
+
+		list, err = service.ListMessages(ctx, db.MessageFilter{Acknowledged: &acknowledged}, query.Page{Number: 1, Size: 10})
+		require.NoError(t, err)
+		require.Len(t, list.Messages, 1)
+		assert.Equal(t, note, list.Messages[0].Note)
+	})
+
+	t.Run("failing again clears the acknowledgment", func(t *testing.T) {
+		_, err := testDB.NewUpdate().Model((*db.Message)(nil)).Set("status = ?", db.MessageStatusSending).Where("id = 1").Exec(ctx)
+		require.NoError(t, err)
+		require.NoError(t, db.FailMessage(ctx, testDB, &db.Message{ID: 1, FailureClass: "network", FailureError: "connection reset"}))
+
+		msg, err := db.GetMessageByID(ctx, testDB, 1)
+		require.NoError(t, err)
+		assert.Nil(t, msg.AcknowledgedAt)
+		assert.Empty(t, msg.AcknowledgedBy)
+		assert.Equal(t, note, msg.Note)
+	})
+
+	t.Run("withdraw and clear", func(t *testing.T) {
+		empty, withdrawn := "", false
+		_, err := service.TriageMessage(ctx, "2", &dto.TriageMessageRequest{Acknowledged: &acknowledged})
+		require.NoError(t, err)
+		response, err := service.TriageMessage(ctx, "2", &dto.TriageMessageRequest{Note: &empty, Acknowledged: &withdrawn})
+		require.NoError(t, err)
+		assert.Nil(t, response.Message.AcknowledgedAt)
+		assert.Empty(t, response.Message.Note)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := service.TriageMessage(ctx, "3", &dto.TriageMessageRequest{Acknowledged: &acknowledged})
+		assert.ErrorIs(t, err, ErrNotFailed)
+		_, err = service.TriageMessage(ctx, "99", &dto.TriageMessageRequest{Acknowledged: &acknowledged})
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+		_, err = service.TriageMessage(ctx, "1", &dto.TriageMessageRequest{})
+		assert.ErrorIs(t, err, ErrInvalidTriage)
+		long := strings.Repeat("a", MaxNoteLength+1)
+		_, err = service.TriageMessage(ctx, "1", &dto.TriageMessageRequest{Note: &long})
+		assert.ErrorIs(t, err, ErrInvalidTriage)
+	})
+}
+
+func TestMessageService_MessageEvents(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	_, err := testDB.NewInsert().Model(&db.Message{To: "+905551111111", Content: "Newsletter", Status: db.MessageStatusPending}).Exec(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, db.CreateMessageEvent(context.Background(), testDB, &db.MessageEvent{MessageID: 1, Reason: db.SkipThrottled, Detail: "deferred until 2026-10-16T09:30:00Z"}))
+	require.NoError(t, db.CreateMessageEvent(context.Background(), testDB, &db.MessageEvent{MessageID: 1, Reason: db.SkipStopped}))
+
+	service := NewMessageService(testDB)
+
+	response, err := service.MessageEvents(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), response.MessageID)
+	require.Len(t, response.Events, 2)
+	assert.Equal(t, db.SkipThrottled, response.Events[0].Reason)
+	assert.Equal(t, "deferred until 2026-10-16T09:30:00Z", response.Events[0].Detail)
+	assert.Equal(t, db.SkipStopped, response.Events[1].Reason)
+
+	_, err = service.MessageEvents(context.Background(), "99")
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+	_, err = service.MessageEvents(context.Background(), "abc")
+	assert.True(t, errors.Is(err, ErrInvalidMessageID))
+
+	// purged messages take their events with them
+	deleted, err := service.PurgeMessages(context.Background(), db.MessageFilter{}, PurgeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	events, err := db.GetMessageEvents(context.Background(), testDB, 1)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestMessageService_PurgeMessages(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Old sent 1", Status: db.MessageStatusSent, CreatedAt: old},
+		{To: "+905552222222", Content: "Old sent 2", Status: db.MessageStatusSent, CreatedAt: old},
+		{To: "+905553333333", Content: "Old sent 3", Status: db.MessageStatusSent, CreatedAt: old},
+		{To: "+905554444444", Content: "Old failed", Status: db.MessageStatusFailed, CreatedAt: old},
+		{To: "+905555555555", Content: "New sent", Status: db.MessageStatusSent, CreatedAt: time.Now()},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+	cutoff := time.Now().Add(-90 * 24 * time.Hour)
+	filter := db.MessageFilter{Status: db.MessageStatusSent, To: &cutoff}
+
+	t.Run("dry run only counts", func(t *testing.T) {
+		count, err := service.PurgeMessages(context.Background(), filter, PurgeOptions{DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		total, err := db.CountMessages(context.Background(), testDB, db.MessageFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 5, total)
+	})
+
+	t.Run("deletes in batches and archives first", func(t *testing.T) {
+		var archived []dto.MessageResponse
+		var progress []int
+		count, err := service.PurgeMessages(context.Background(), filter, PurgeOptions{
+			BatchSize: 2,
+			Archive: func(batch []dto.MessageResponse) error {
+				archived = append(archived, batch...)
+				return nil
+			},
+			OnProgress: func(deleted int) {
+				progress = append(progress, deleted)
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Len(t, archived, 3)
+		assert.Equal(t, []int{2, 3}, progress)
+
+		total, err := db.CountMessages(context.Background(), testDB, db.MessageFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+	})
+
+	t.Run("archive error keeps the batch", func(t *testing.T) {
+		archiveErr := errors.New("disk full")
+		_, err := service.PurgeMessages(context.Background(), db.MessageFilter{To: &cutoff}, PurgeOptions{
+			Archive: func([]dto.MessageResponse) error { return archiveErr },
+		})
+
+		assert.ErrorIs(t, err, archiveErr)
+
+		failed, err := db.CountMessages(context.Background(), testDB, db.MessageFilter{Status: db.MessageStatusFailed})
+		require.NoError(t, err)
+		assert.Equal(t, 1, failed)
+	})
+}
+
+func TestMessageService_Stats(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	now := time.Now()
+	yesterday := now.Add(-48 * time.Hour)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Old pending", Status: db.MessageStatusPending, CreatedAt: now.Add(-time.Hour)},
+		{To: "+905552222222", Content: "New pending", Status: db.MessageStatusPending, CreatedAt: now},
+		{To: "+905553333333", Content: "Sent today", Status: db.MessageStatusSent, SentAt: &now},
+		{To: "+905554444444", Content: "Sent today", Status: db.MessageStatusSent, SentAt: &now},
+		{To: "+905555555555", Content: "Sent before", Status: db.MessageStatusSent, SentAt: &yesterday},
+		{To: "+905556666666", Content: "Failed today", Status: db.MessageStatusFailed, UpdatedAt: now},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	stats, err := NewMessageService(testDB).Stats(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Total)
+	assert.Equal(t, 2, stats.Counts["pending"])
+	assert.Equal(t, 0, stats.Counts["sending"])
+	assert.Equal(t, 3, stats.Counts["sent"])
+	assert.Equal(t, 1, stats.Counts["failed"])
+	assert.Equal(t, 2, stats.SentToday)
+	assert.Equal(t, 1, stats.FailedToday)
+	assert.InDelta(t, 1.0/3.0, stats.FailureRate, 0.0001)
+	require.NotNil(t, stats.OldestPendingAt)
+	assert.GreaterOrEqual(t, stats.OldestPendingAgeSeconds, int64(3599))
+}
+
+func TestMessageService_CountCache(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	insert := func(status db.MessageStatus) {
+		_, err := testDB.NewInsert().Model(&db.Message{To: "+905551111111", Content: "Test", Status: status}).Exec(ctx)
+		require.NoError(t, err)
+	}
+	insert(db.MessageStatusPending)
+	insert(db.MessageStatusSent)
+
+	service := NewMessageService(testDB)
+	counts := NewCountCache(testDB, time.Minute)
+	service.SetCountCache(counts)
+
+	stats, err := service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total, "the messages are counted until the cache is refreshed")
+	assert.Nil(t, stats.CountsCachedAt)
+
+	require.NoError(t, counts.Refresh(ctx))
+	insert(db.MessageStatusPending)
+
+	stats, err = service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total, "the cached counts are served")
+	assert.Equal(t, 1, stats.Counts["pending"])
+	require.NotNil(t, stats.CountsCachedAt)
+
+	list, err := service.ListMessages(ctx, db.MessageFilter{Status: db.MessageStatusPending}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.Total, "status only filters are served from the cache")
+	assert.Len(t, list.Messages, 2)
+
+	list, err = service.ListMessages(ctx, db.MessageFilter{Tag: "none"}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 0, list.Total, "other filters are counted")
+
+	sent, err := service.GetSentMessages(ctx, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent.Total)
+
+	require.NoError(t, counts.Refresh(ctx))
+	stats, err = service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+
+	_, err = testDB.NewUpdate().Model((*db.MessageCount)(nil)).Set("refreshed_at = ?", time.Now().Add(-time.Hour)).Where("1 = 1").Exec(ctx)
+	require.NoError(t, err)
+	insert(db.MessageStatusFailed)
+	stats, err = service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Total, "stale counts are not used")
+	assert.Nil(t, stats.CountsCachedAt)
+}
+
+func TestMessageService_Timeseries(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	from := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return from.Add(time.Duration(minutes) * time.Minute) }
+	sent, sentLater := at(15), at(130)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Sent", Status: db.MessageStatusSent, CreatedAt: at(5), SentAt: &sent},
+		{To: "+905552222222", Content: "Sent later", Status: db.MessageStatusSent, CreatedAt: at(10), SentAt: &sentLater},
+		{To: "+905553333333", Content: "Failed", Status: db.MessageStatusFailed, CreatedAt: at(20), UpdatedAt: at(125)},
+		{To: "+905554444444", Content: "Pending", Status: db.MessageStatusPending, CreatedAt: at(140)},
+		{To: "+905555555555", Content: "Outside", Status: db.MessageStatusPending, CreatedAt: at(-30)},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+	to := at(180)
+	series, err := service.Timeseries(context.Background(), "1h", &from, &to)
+	require.NoError(t, err)
+
+	require.Len(t, series.Points, 3, "buckets without messages are zero filled")
+	for i, want := range []dto.TimeseriesPoint{
+		{Time: at(0), Created: 3, Sent: 1},
+		{Time: at(60)},
+		{Time: at(120), Created: 1, Sent: 1, Failed: 1},
+	} {
+		assert.True(t, want.Time.Equal(series.Points[i].Time), "point %d starts at %s", i, series.Points[i].Time)
+		want.Time = series.Points[i].Time
+		assert.Equal(t, want, series.Points[i])
+	}
+
+	_, err = service.Timeseries(context.Background(), "5m", &from, &to)
+	assert.ErrorIs(t, err, ErrInvalidTimeseries)
+	_, err = service.Timeseries(context.Background(), "1h", &to, &from)
+	assert.ErrorIs(t, err, ErrInvalidTimeseries)
+	_, err = service.Timeseries(context.Background(), "1m", nil, nil)
+	assert.NoError(t, err)
+	year := from.AddDate(-1, 0, 0)
+	_, err = service.Timeseries(context.Background(), "1m", &year, &from)
+	assert.ErrorIs(t, err, ErrInvalidTimeseries, "too many points")
+}
+
 func TestMessageService_ConvertToMessageResponse(t *testing.T) {
 	service := NewMessageService(nil) // No DB needed for pure function
 
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// Recipient pause errors
+var (
+	ErrInvalidPause  = errors.New("invalid pause")
+	ErrPauseNotFound = errors.New("pause not found")
+)
+
+// pausePrefixPattern matches E.164 phone numbers and the starts of them
+var pausePrefixPattern = regexp.MustCompile(`^\+\d{1,15}$`)
+
+// PauseInterface defines the pauses of recipients and prefix ranges
+type PauseInterface interface {
+	ListPauses(ctx context.Context) (*dto.RecipientPausesResponse, error)
+	AddPause(ctx context.Context, req *dto.RecipientPauseRequest) (*dto.SingleRecipientPauseResponse, error)
+	RemovePause(ctx context.Context, prefix string) error
+}
+
+// PauseService stores the pauses holding the messages to phone numbers or prefix ranges in pending, e.g. during
+// the outage of a carrier, the scheduler defers their messages until the pauses are lifted or expire
+type PauseService struct {
+	db  *bun.DB
+	cfg config.Messaging
+}
+
+func NewPauseService(database *bun.DB, cfg config.Messaging) *PauseService {
+	return &PauseService{
+		db:  database,
+		cfg: cfg,
+	}
+}
+
+// ListPauses returns the active pauses ordered by prefix
+func (s *PauseService) ListPauses(ctx context.Context) (*dto.RecipientPausesResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "PauseService.ListPauses")
+	defer span.End()
+
+	pauses, err := db.ListRecipientPauses(ctx, s.db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RecipientPauseResponse, len(pauses))
+	for i, pause := range pauses {
+		responses[i] = convertRecipientPause(pause)
+	}
+	return &dto.RecipientPausesResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Pauses: responses,
+	}, nil
+}
+
+// AddPause pauses the messages to req.Prefix, replacing the reason and expiry of an existing pause. The pending
+// messages of the prefix are deferred right away, the ones enqueued later when they are claimed.
+func (s *PauseService) AddPause(ctx context.Context, req *dto.RecipientPauseRequest) (*dto.SingleRecipientPauseResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "PauseService.AddPause")
+	defer span.End()
+
+	if !pausePrefixPattern.MatchString(req.Prefix) {
+		return nil, fmt.Errorf("%w: prefix %q must be + followed by 1 to 15 digits", ErrInvalidPause, req.Prefix)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidPause)
+	}
+
+	pause := &db.RecipientPause{Prefix: req.Prefix, Reason: req.Reason, ExpiresAt: req.ExpiresAt}
+	if err := db.PutRecipientPause(ctx, s.db, pause); err != nil {
+		return nil, err
+	}
+	deferred, err := db.DeferPausedMessages(ctx, s.db, pause.Prefix, pauseDeferral(pause, s.cfg.PauseRecheck))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField("pause", pause.Prefix).Infof("Paused recipients, deferred %d pending messages", deferred)
+
+	return &dto.SingleRecipientPauseResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Pause:    convertRecipientPause(pause),
+		Deferred: deferred,
+	}, nil
+}
+
+// RemovePause lifts the pause of prefix, its messages are sent again within messaging.pause_recheck
+func (s *PauseService) RemovePause(ctx context.Context, prefix string) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "PauseService.RemovePause")
+	defer span.End()
+
+	deleted, err := db.DeleteRecipientPause(ctx, s.db, prefix)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s", ErrPauseNotFound, prefix)
+	}
+	return nil
+}
+
+func convertRecipientPause(pause *db.RecipientPause) dto.RecipientPauseResponse {
+	return dto.RecipientPauseResponse{
+		Prefix:    pause.Prefix,
+		Reason:    pause.Reason,
+		ExpiresAt: pause.ExpiresAt,
+		CreatedAt: pause.CreatedAt,
+	}
+}
+
+// pauseDeferral returns when the messages of pause are checked again: after recheck, or at the expiry of the
+// pause when it is sooner
+func pauseDeferral(pause *db.RecipientPause, recheck time.Duration) time.Time {
+	until := time.Now().Add(recheck)
+	if pause.ExpiresAt != nil && pause.ExpiresAt.Before(until) {
+		return *pause.ExpiresAt
+	}
+	return until
+}
+
+// recipientPauses are the pauses the scheduler claims a batch with, the longest matching prefix wins
+type recipientPauses struct {
+	pauses []*db.RecipientPause
+	// deferred are the prefixes whose pending messages the batch deferred already
+	mu       sync.Mutex
+	deferred map[string]bool
+}
+
+// loadRecipientPauses loads the active pauses
+func loadRecipientPauses(ctx context.Context, database bun.IDB) (*recipientPauses, error) {
+	pauses, err := db.ListRecipientPauses(ctx, database, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(pauses, func(a, b *db.RecipientPause) int {
+		return cmp.Compare(len(b.Prefix), len(a.Prefix))
+	})
+	return &recipientPauses{pauses: pauses, deferred: make(map[string]bool)}, nil
+}
+
+// of returns the pause of the recipient to, nil when it is not paused. Pauses expiring during the batch no
+// longer apply.
+func (p *recipientPauses) of(to string) *db.RecipientPause {
+	if p == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, pause := range p.pauses {
+		if strings.HasPrefix(to, pause.Prefix) && pause.Active(now) {
+			return pause
+		}
+	}
+	return nil
+}
+
+// first reports whether prefix is seen for the first time in the batch
+func (p *recipientPauses) first(prefix string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.deferred[prefix] {
+		return false
+	}
+	p.deferred[prefix] = true
+	return true
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	service := NewPauseService(testDB, config.Messaging{PauseRecheck: time.Hour})
+	past := time.Now().Add(-time.Minute)
+
+	t.Run("invalid pauses", func(t *testing.T) {
+		for _, req := range []dto.RecipientPauseRequest{
+			{Prefix: "9055"},
+			{Prefix: "+90 55"},
+			{Prefix: "+9055", ExpiresAt: &past},
+		} {
+			_, err := service.AddPause(ctx, &req)
+			assert.True(t, errors.Is(err, ErrInvalidPause), "%+v", req)
+		}
+	})
+
+	later := time.Now().Add(24 * time.Hour)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Paused", Status: db.MessageStatusPending},
+		{To: "+905551111112", Content: "Scheduled after the recheck", Status: db.MessageStatusPending, ScheduledAt: &later},
+		{To: "+905561111111", Content: "Other prefix", Status: db.MessageStatusPending},
+	}
+	_, err := testDB.NewInsert().Model(&messages).Exec(ctx)
+	require.NoError(t, err)
+
+	created, err := service.AddPause(ctx, &dto.RecipientPauseRequest{Prefix: "+90555", Reason: "Carrier outage"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.Deferred, "only the pending messages due before the recheck are deferred")
+	paused, err := db.GetMessageByID(ctx, testDB, messages[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, paused.ScheduledAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *paused.ScheduledAt, time.Minute)
+
+	expired := &db.RecipientPause{Prefix: "+1", ExpiresAt: &past}
+	require.NoError(t, db.PutRecipientPause(ctx, testDB, expired))
+
+	list, err := service.ListPauses(ctx)
+	require.NoError(t, err)
+	require.Len(t, list.Pauses, 1, "expired pauses are not listed")
+	assert.Equal(t, "Carrier outage", list.Pauses[0].Reason)
+
+	require.NoError(t, service.RemovePause(ctx, "+90555"))
+	err = service.RemovePause(ctx, "+90555")
+	assert.True(t, errors.Is(err, ErrPauseNotFound))
+}
+
+func TestScheduler_ProcessBatch_RecipientPauses(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(10 * time.Minute)
+	require.NoError(t, db.PutRecipientPause(ctx, testDB, &db.RecipientPause{Prefix: "+90555", ExpiresAt: &expiresAt}))
+	require.NoError(t, db.PutRecipientPause(ctx, testDB, &db.RecipientPause{Prefix: "+4477"}))
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 3, PauseRecheck: time.Hour, SkipEvents: true},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(ctx,
+		&db.Message{ID: 1, To: "+905551111111", Content: "Paused until the expiry"},
+		&db.Message{ID: 2, To: "+447700900123", Content: "Paused until removed"},
+		&db.Message{ID: 3, To: "+905561111111", Content: "Not paused"},
+	))
+
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(ctx)
+
+	assert.ElementsMatch(t, []int64{1, 2}, q.deferred)
+	assert.Contains(t, q.acked, int64(3))
+	events, err := db.GetMessageEvents(ctx, testDB, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipPaused, events[0].Reason)
+	assert.Contains(t, events[0].Detail, expiresAt.UTC().Format(time.RFC3339), "a pause expiring before the recheck resumes at its expiry")
+}
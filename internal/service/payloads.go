@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/klauspost/compress/zstd"
+	"github.com/uptrace/bun"
+)
+
+// PayloadStore keeps the provider response bodies too large to be stored inline in the webhook_response of
+// their message. The stored response references a body by the payload_ref Put returned, prefixed with the
+// name of its storage so the body is found after the storage changed. A body is stored with its encoding,
+// empty for a body that is not compressed.
+type PayloadStore interface {
+	Put(ctx context.Context, messageID int64, body, encoding string) (ref string, err error)
+	Get(ctx context.Context, ref string) (body, encoding string, err error)
+}
+
+// NewPayloadStore returns the store of a webhook.payloads.storage, nil for none
+func NewPayloadStore(database bun.IDB, storage string) PayloadStore {
+	switch storage {
+	case config.PayloadStorageTable:
+		return tablePayloads{db: database}
+	}
+	return nil
+}
+
+// tablePayloads stores the bodies in the message_payloads table, a body is referenced by its message ID
+type tablePayloads struct {
+	db bun.IDB
+}
+
+func (t tablePayloads) Put(ctx context.Context, messageID int64, body, encoding string) (string, error) {
+	if err := db.SaveMessagePayload(ctx, t.db, &db.MessagePayload{MessageID: messageID, Body: body, Encoding: encoding}); err != nil {
+		return "", err
+	}
+	return config.PayloadStorageTable + ":" + strconv.FormatInt(messageID, 10), nil
+}
+
+func (t tablePayloads) Get(ctx context.Context, ref string) (string, string, error) {
+	messageID, err := strconv.ParseInt(strings.TrimPrefix(ref, config.PayloadStorageTable+":"), 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid payload reference %q", ref)
+	}
+	payload, err := db.GetMessagePayload(ctx, t.db, messageID)
+	if err != nil {
+		return "", "", err
+	}
+	return payload.Body, payload.Encoding, nil
+}
+
+// storedResponse is the webhook response stored with a message. The raw body is stored inline up to the
+// threshold, a larger one is referenced by PayloadRef. A compressed body is base64 encoded, BodyEncoding is its
+// compression.
+type storedResponse struct {
+	*webhook.Response
+	Body         string `json:"body,omitempty"`
+	BodyEncoding string `json:"body_encoding,omitempty"`
+	PayloadRef   string `json:"payload_ref,omitempty"`
+	PayloadSize  int    `json:"payload_size,omitempty"`
+}
+
+// storeResponse returns the JSON of response stored with the message of messageID, its body is dropped when
+// it is above the threshold and no payload store is configured
+func storeResponse(ctx context.Context, payloads PayloadStore, cfg config.WebhookPayloads, messageID int64, response *webhook.Response) (string, error) {
+	stored := storedResponse{Response: response}
+	body, encoding := compressBody(response.Body, cfg)
+	switch {
+	case response.Body == "":
+	case len(body) <= cfg.Threshold:
+		stored.Body, stored.BodyEncoding = body, encoding
+	case payloads != nil:
+		ref, err := payloads.Put(ctx, messageID, body, encoding)
+		if err != nil {
+			return "", err
+		}
+		stored.PayloadRef, stored.PayloadSize = ref, len(response.Body)
+	}
+	data, err := json.Marshal(stored)
+	return string(data), err
+}
+
+// resolvePayload adds the body referenced by the payload_ref of a stored webhook response to it
+func resolvePayload(ctx context.Context, database bun.IDB, response map[string]any) error {
+	ref, ok := response["payload_ref"].(string)
+	if !ok || ref == "" {
+		return nil
+	}
+	storage, _, _ := strings.Cut(ref, ":")
+	payloads := NewPayloadStore(database, storage)
+	if payloads == nil {
+		return fmt.Errorf("unknown payload storage %q", storage)
+	}
+	body, encoding, err := payloads.Get(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if body, err = decompressBody(body, encoding); err != nil {
+		return err
+	}
+	response["body"] = body
+	return nil
+}
+
+// decodeStoredBody decompresses the inline body of a stored webhook response, so it is served as received
+func decodeStoredBody(response map[string]any) error {
+	encoding, _ := response["body_encoding"].(string)
+	body, _ := response["body"].(string)
+	if encoding == "" {
+		return nil
+	}
+	decoded, err := decompressBody(body, encoding)
+	if err != nil {
+		return err
+	}
+	response["body"] = decoded
+	delete(response, "body_encoding")
+	return nil
+}
+
+// zstd encoders and decoders are safe for concurrent EncodeAll and DecodeAll calls
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressBody returns body compressed with webhook.payloads.compression and base64 encoded and its encoding.
+// A body below compress_above or one the compression would not shrink is returned as is with no encoding.
+func compressBody(body string, cfg config.WebhookPayloads) (string, string) {
+	if body == "" || len(body) < cfg.CompressAbove {
+		return body, ""
+	}
+
+	var compressed []byte
+	switch cfg.Compression {
+	case config.PayloadCompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write([]byte(body)); err != nil || writer.Close() != nil {
+			return body, ""
+		}
+		compressed = buf.Bytes()
+	case config.PayloadCompressionZstd:
+		compressed = zstdEncoder.EncodeAll([]byte(body), nil)
+	default:
+		return body, ""
+	}
+
+	if encoded := base64.StdEncoding.EncodeToString(compressed); len(encoded) < len(body) {
+		return encoded, cfg.Compression
+	}
+	return body, ""
+}
+
+// decompressBody returns the body compressBody encoded with encoding
+func decompressBody(body, encoding string) (string, error) {
+	if encoding == "" {
+		return body, nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s body: %w", encoding, err)
+	}
+
+	switch encoding {
+	case config.PayloadCompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return "", fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer reader.Close()
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return "", fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return string(decompressed), nil
+	case config.PayloadCompressionZstd:
+		decompressed, err := zstdDecoder.DecodeAll(compressed, nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid zstd body: %w", err)
+		}
+		return string(decompressed), nil
+	}
+	return "", fmt.Errorf("unknown body encoding %q", encoding)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_ProcessBatch_StoresResponseBodies(t *testing.T) {
+	body := `{"message": "Accepted", "messageId": "payload-1", "trace": "` + strings.Repeat("x", 400) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		payloads config.WebhookPayloads
+		inline   bool
+		ref      bool
+		// encoding is the compression the body is stored with
+		encoding string
+	}{
+		{"dropped", config.WebhookPayloads{Storage: config.PayloadStorageNone, Threshold: 16}, false, false, ""},
+		{"inline below the threshold", config.WebhookPayloads{Storage: config.PayloadStorageTable, Threshold: 1024}, true, false, ""},
+		{"table above the threshold", config.WebhookPayloads{Storage: config.PayloadStorageTable, Threshold: 16}, false, true, ""},
+		{"below compress_above", config.WebhookPayloads{Storage: config.PayloadStorageTable, Threshold: 1024,
+			Compression: config.PayloadCompressionGzip, CompressAbove: 1024}, true, false, ""},
+		{"gzip inline", config.WebhookPayloads{Storage: config.PayloadStorageNone, Threshold: 200,
+			Compression: config.PayloadCompressionGzip, CompressAbove: 256}, true, false, config.PayloadCompressionGzip},
+		{"zstd table", config.WebhookPayloads{Storage: config.PayloadStorageTable, Threshold: 16,
+			Compression: config.PayloadCompressionZstd, CompressAbove: 256}, false, true, config.PayloadCompressionZstd},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := setupTestDB(t)
+			defer testDB.Close()
+			ctx := context.Background()
+
+			message := &db.Message{To: "+905551111111", Content: "Hello"}
+			require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{message}))
+
+			cfg := &config.Cfg{
+				Messaging: config.Messaging{BatchSize: 1},
+				Webhook:   config.Webhook{URL: server.URL, Payloads: tt.payloads},
+			}
+			NewScheduler(testDB, cfg).processBatch(ctx)
+
+			stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+			require.NoError(t, err)
+			require.Equal(t, db.MessageStatusSent, stored.Status)
+			var row map[string]any
+			require.NoError(t, json.Unmarshal([]byte(*stored.WebhookResponse), &row))
+			assert.Equal(t, "payload-1", row["message_id"])
+			assert.Equal(t, tt.inline && tt.encoding == "", row["body"] == body, "the body is stored inline as received")
+			assert.Equal(t, tt.inline, row["body"] != nil, "the body is stored inline")
+			assert.Equal(t, tt.ref, row["payload_ref"] != nil, "the body is referenced")
+			if tt.inline {
+				encoding, _ := row["body_encoding"].(string)
+				assert.Equal(t, tt.encoding, encoding)
+			}
+			if tt.ref {
+				payload, err := db.GetMessagePayload(ctx, testDB, message.ID)
+				require.NoError(t, err)
+				assert.Equal(t, tt.encoding, payload.Encoding)
+				if tt.encoding != "" {
+					assert.Less(t, len(payload.Body), len(body)/2, "the stored body is compressed")
+				}
+			}
+
+			response, err := NewMessageService(testDB).GetMessageByID(ctx, strconv.FormatInt(message.ID, 10))
+			require.NoError(t, err)
+			if tt.inline || tt.ref {
+				assert.Equal(t, body, response.Message.WebhookResponse["body"], "a single message is read with its body")
+			} else {
+				assert.NotContains(t, response.Message.WebhookResponse, "body")
+			}
+		})
+	}
+
+	t.Run("deleted with the message", func(t *testing.T) {
+		testDB := setupTestDB(t)
+		defer testDB.Close()
+		ctx := context.Background()
+
+		message := &db.Message{To: "+905551111111", Content: "Hello", Status: db.MessageStatusSent}
+		require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{message}))
+		_, err := NewPayloadStore(testDB, config.PayloadStorageTable).Put(ctx, message.ID, body, "")
+		require.NoError(t, err)
+
+		_, err = NewMessageService(testDB).PurgeMessages(ctx, db.MessageFilter{}, PurgeOptions{})
+		require.NoError(t, err)
+		_, err = db.GetMessagePayload(ctx, testDB, message.ID)
+		assert.Error(t, err, "no payload outlives its message")
+	})
+}
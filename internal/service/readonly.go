@@ -0,0 +1,63 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+)
+
+// ReadOnlyMode is the read-only mode operators enable while they restore the database, e.g. after it was
+// corrupted. While it is enabled the API only answers reads and the scheduler claims no messages, both resume
+// once it is disabled. It starts with server.read_only and is toggled through the admin API of the instance.
+type ReadOnlyMode struct {
+	mu        sync.RWMutex
+	enabled   bool
+	since     time.Time
+	changedBy string
+}
+
+// NewReadOnlyMode creates the mode, enabled by the configuration when enabled is set
+func NewReadOnlyMode(enabled bool) *ReadOnlyMode {
+	m := &ReadOnlyMode{enabled: enabled}
+	if enabled {
+		m.since = time.Now().UTC()
+		m.changedBy = "config"
+	}
+	return m
+}
+
+// Enabled reports whether the mode is enabled
+func (m *ReadOnlyMode) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// Status returns whether the mode is enabled, since when and who changed it last, since is zero when it was
+// never enabled
+func (m *ReadOnlyMode) Status() (bool, time.Time, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled, m.since, m.changedBy
+}
+
+// Set enables or disables the mode on behalf of changedBy and reports whether it changed
+func (m *ReadOnlyMode) Set(enabled bool, changedBy string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enabled == enabled {
+		return false
+	}
+	m.enabled = enabled
+	m.since = time.Now().UTC()
+	m.changedBy = changedBy
+
+	log := config.Log().WithField("component", "read_only").WithField("changed_by", changedBy)
+	if enabled {
+		log.Warn("Read-only mode enabled, writes are rejected and messaging is paused")
+	} else {
+		log.Info("Read-only mode disabled, resuming writes and messaging")
+	}
+	return true
+}
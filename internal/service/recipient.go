@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// MaxRecipientOutcomes bounds the latest sent, delivered and failed messages the failure streaks of a recipient
+// are counted over
+const MaxRecipientOutcomes = 10_000
+
+// RecipientStats summarizes the messages to phone: their counts by status, the last success and failure, the
+// failure streaks and whether the recipient is suppressed
+func (s *MessageService) RecipientStats(ctx context.Context, phone string) (*dto.RecipientStatsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "MessageService.RecipientStats")
+	defer span.End()
+
+	if err := db.ValidatePhone(phone); err != nil {
+		return nil, ErrInvalidPhone
+	}
+
+	counts, err := db.CountRecipientMessages(ctx, s.db, phone)
+	if err != nil {
+		return nil, err
+	}
+	response := &dto.RecipientStatsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Phone:  phone,
+		Counts: make(map[string]int, len(counts)),
+	}
+	for _, count := range counts {
+		response.Counts[string(count.Status)] = count.Count
+		response.Total += count.Count
+		if response.FirstMessageAt == nil || count.FirstAt.Before(*response.FirstMessageAt) {
+			first := count.FirstAt
+			response.FirstMessageAt = &first
+		}
+		if response.LastMessageAt == nil || count.LastAt.After(*response.LastMessageAt) {
+			last := count.LastAt
+			response.LastMessageAt = &last
+		}
+	}
+
+	outcomes, err := db.ListRecipientOutcomes(ctx, s.db, phone, MaxRecipientOutcomes+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(outcomes) > MaxRecipientOutcomes {
+		outcomes, response.OutcomesTruncated = outcomes[:MaxRecipientOutcomes], true
+	}
+	// the outcomes are the latest first, the failures before the first success are the current streak
+	streak, current := 0, true
+	for _, msg := range outcomes {
+		if msg.Status != db.MessageStatusFailed {
+			if response.LastSuccessAt == nil {
+				response.LastSuccessAt = successAt(msg)
+			}
+			streak, current = 0, false
+			continue
+		}
+		if response.LastFailure == nil {
+			response.LastFailure = convertRecipientFailure(msg)
+		}
+		streak++
+		if current {
+			response.FailureStreak = streak
+		}
+		response.LongestFailureStreak = max(response.LongestFailureStreak, streak)
+	}
+
+	suppression, err := db.GetSuppression(ctx, s.db, phone)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		converted := convertToSuppressionResponse(suppression)
+		response.Suppressed, response.Suppression = true, &converted
+	}
+	return response, nil
+}
+
+// successAt returns when a sent or delivered message reached the recipient, as far as it is known
+func successAt(msg *db.Message) *time.Time {
+	if msg.DeliveredAt != nil {
+		return msg.DeliveredAt
+	}
+	if msg.SentAt != nil {
+		return msg.SentAt
+	}
+	return &msg.UpdatedAt
+}
+
+func convertRecipientFailure(msg *db.Message) *dto.RecipientFailure {
+	// messages are looked up by their public ID when they have one
+	ref := strconv.FormatInt(msg.ID, 10)
+	if msg.PublicID != "" {
+		ref = msg.PublicID
+	}
+	return &dto.RecipientFailure{
+		ID:       shownMessageID(msg.ID, msg.PublicID),
+		PublicID: msg.PublicID,
+		FailedAt: msg.UpdatedAt,
+		Error:    msg.DeliveryError,
+		Link:     "/api/v1/messages/" + ref,
+	}
+}
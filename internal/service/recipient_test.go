@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageService_RecipientStats(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	at := func(hours int) *time.Time {
+		v := now.Add(time.Duration(hours) * time.Hour)
+		return &v
+	}
+	const phone = "+905551111111"
+	messages := []*db.Message{
+		{To: phone, Content: "1", Status: db.MessageStatusFailed, CreatedAt: *at(-10), UpdatedAt: *at(-10)},
+		{To: phone, Content: "2", Status: db.MessageStatusFailed, CreatedAt: *at(-9), UpdatedAt: *at(-9)},
+		{To: phone, Content: "3", Status: db.MessageStatusFailed, CreatedAt: *at(-8), UpdatedAt: *at(-8)},
+		{To: phone, Content: "4", Status: db.MessageStatusDelivered, CreatedAt: *at(-7), SentAt: at(-7), DeliveredAt: at(-6)},
+		{To: phone, Content: "5", Status: db.MessageStatusFailed, CreatedAt: *at(-5), UpdatedAt: *at(-5)},
+		{To: phone, Content: "6", Status: db.MessageStatusFailed, CreatedAt: *at(-4), UpdatedAt: *at(-3), DeliveryError: "absent subscriber"},
+		{To: phone, Content: "7", Status: db.MessageStatusPending, CreatedAt: *at(-1)},
+		{To: "+905552222222", Content: "other", Status: db.MessageStatusSent, CreatedAt: *at(-1), SentAt: at(-1)},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewMessageService(testDB)
+	stats, err := service.RecipientStats(ctx, phone)
+	require.NoError(t, err)
+	assert.Equal(t, 7, stats.Total)
+	assert.Equal(t, map[string]int{"failed": 5, "delivered": 1, "pending": 1}, stats.Counts)
+	assert.WithinDuration(t, *at(-10), *stats.FirstMessageAt, time.Second)
+	assert.WithinDuration(t, *at(-1), *stats.LastMessageAt, time.Second)
+	require.NotNil(t, stats.LastSuccessAt)
+	assert.WithinDuration(t, *at(-6), *stats.LastSuccessAt, time.Second, "the delivery time of the last delivered message")
+	require.NotNil(t, stats.LastFailure)
+	assert.Equal(t, messages[5].ID, stats.LastFailure.ID)
+	assert.Equal(t, "absent subscriber", stats.LastFailure.Error)
+	assert.Equal(t, "/api/v1/messages/"+strconv.FormatInt(messages[5].ID, 10), stats.LastFailure.Link)
+	assert.Equal(t, 2, stats.FailureStreak)
+	assert.Equal(t, 3, stats.LongestFailureStreak)
+	assert.False(t, stats.Suppressed)
+
+	t.Run("suppressed", func(t *testing.T) {
+		_, err := db.AddSuppression(ctx, testDB, &db.Suppression{Phone: phone, Source: db.SuppressionSourceInbound})
+		require.NoError(t, err)
+		stats, err := service.RecipientStats(ctx, phone)
+		require.NoError(t, err)
+		assert.True(t, stats.Suppressed)
+		assert.Equal(t, "inbound", stats.Suppression.Source)
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		stats, err := service.RecipientStats(ctx, "+905559999999")
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Empty(t, stats.Counts)
+		assert.Nil(t, stats.LastSuccessAt)
+		assert.Nil(t, stats.LastFailure)
+	})
+
+	t.Run("invalid phone", func(t *testing.T) {
+		_, err := service.RecipientStats(ctx, "0555")
+		assert.ErrorIs(t, err, ErrInvalidPhone)
+	})
+}
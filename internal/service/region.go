@@ -0,0 +1,71 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+)
+
+// RegionFailover is the role of the region of the instance in an active/passive deployment across regions. The
+// scheduler of a passive region claims no messages, so two regions never send the same message, until operators
+// promote it through the admin API of the instance, e.g. once the active region failed. It starts with region.role.
+type RegionFailover struct {
+	name      string
+	mu        sync.RWMutex
+	role      string
+	since     time.Time
+	changedBy string
+}
+
+// NewRegionFailover creates the failover of the region of cfg in its configured role
+func NewRegionFailover(cfg config.Region) *RegionFailover {
+	f := &RegionFailover{name: cfg.Name, role: cfg.Role, since: time.Now().UTC(), changedBy: "config"}
+	if f.role == "" {
+		f.role = config.RegionActive
+	}
+	telemetry.SetRegionActive(f.name, f.role == config.RegionActive)
+	return f
+}
+
+// Name returns the region name, empty when it is not set
+func (f *RegionFailover) Name() string {
+	return f.name
+}
+
+// Passive reports whether the region is passive, its scheduler does not claim messages then
+func (f *RegionFailover) Passive() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.role == config.RegionPassive
+}
+
+// Status returns the role, since when the region has it and who changed it last
+func (f *RegionFailover) Status() (string, time.Time, string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.role, f.since, f.changedBy
+}
+
+// SetRole promotes the region to config.RegionActive or demotes it to config.RegionPassive on behalf of
+// changedBy and reports whether it changed
+func (f *RegionFailover) SetRole(role, changedBy string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.role == role {
+		return false
+	}
+	f.role = role
+	f.since = time.Now().UTC()
+	f.changedBy = changedBy
+	telemetry.SetRegionActive(f.name, role == config.RegionActive)
+
+	log := config.Log().WithField("component", "region").WithField("region", f.name).WithField("changed_by", changedBy)
+	if role == config.RegionActive {
+		log.Warn("Region promoted to active, the scheduler claims messages")
+	} else {
+		log.Warn("Region demoted to passive, the scheduler stops claiming messages")
+	}
+	return true
+}
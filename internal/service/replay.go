@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrInvalidReplay = errors.New("invalid replay")
+	// ErrReplayTooLarge is returned when more messages than replay.max_messages match
+	ErrReplayTooLarge = errors.New("replay matches too many messages")
+	// ErrReplayCountMismatch is returned when the expected count is not the number of matching messages
+	ErrReplayCountMismatch = errors.New("replay count does not match")
+)
+
+// ReplayInterface defines replays of sent messages
+type ReplayInterface interface {
+	Replay(ctx context.Context, req *dto.ReplayRequest) (*dto.ReplayResponse, error)
+}
+
+// ReplayService clones the messages sent within a window and enqueues the clones, e.g. when the provider
+// admits a delivery blackout
+type ReplayService struct {
+	db    *bun.DB
+	queue queue.Queue
+	cfg   config.Replay
+}
+
+// NewReplayService creates a replay service enqueueing the clones to q
+func NewReplayService(database *bun.DB, q queue.Queue, cfg config.Replay) *ReplayService {
+	return &ReplayService{
+		db:    database,
+		queue: q,
+		cfg:   cfg,
+	}
+}
+
+// Replay clones the messages sent within the window of req and enqueues the clones. To guard against
+// accidental mass re-sends the window is bounded by replay.max_window, the matching messages by
+// replay.max_messages, and unless it is a dry run req.ExpectedCount must be the number of matching
+// messages. Messages replayed before are skipped, so an interrupted replay can be run again.
+func (s *ReplayService) Replay(ctx context.Context, req *dto.ReplayRequest) (*dto.ReplayResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "ReplayService.Replay")
+	defer span.End()
+
+	if req.From.IsZero() || req.To.IsZero() {
+		return nil, fmt.Errorf("%w: from and to are required", ErrInvalidReplay)
+	}
+	if !req.From.Before(req.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidReplay)
+	}
+	if req.To.After(time.Now()) {
+		return nil, fmt.Errorf("%w: to cannot be in the future", ErrInvalidReplay)
+	}
+	if window := req.To.Sub(req.From); window > s.cfg.MaxWindow {
+		return nil, fmt.Errorf("%w: the window of %s exceeds replay.max_window (%s)", ErrInvalidReplay, window, s.cfg.MaxWindow)
+	}
+
+	filter := db.ReplayFilter{
+		From:     req.From,
+		To:       req.To,
+		Provider: req.Provider,
+		Tenant:   req.Tenant,
+		Campaign: req.Campaign,
+	}
+	matched, err := db.CountReplayable(ctx, s.db, filter)
+	if err != nil {
+		return nil, err
+	}
+	if matched > s.cfg.MaxMessages {
+		return nil, fmt.Errorf("%w: %d messages match, replay.max_messages is %d", ErrReplayTooLarge, matched, s.cfg.MaxMessages)
+	}
+
+	response := &dto.ReplayResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		DryRun:  req.DryRun,
+		Matched: matched,
+	}
+	if req.DryRun {
+		return response, nil
+	}
+	if req.ExpectedCount != matched {
+		return nil, fmt.Errorf("%w: %d messages match, expected %d", ErrReplayCountMismatch, matched, req.ExpectedCount)
+	}
+
+	log := config.LogFrom(ctx).WithField("from", req.From).WithField("to", req.To)
+	var lastID int64
+	for response.Replayed < matched {
+		messages, err := db.ListReplayable(ctx, s.db, filter, lastID, min(ExportBatchSize, matched-response.Replayed))
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		clones := make([]*db.Message, len(messages))
+		for i, message := range messages {
+			clones[i] = db.ReplayClone(message)
+		}
+		if err := s.queue.Enqueue(ctx, clones...); err != nil {
+			log.WithError(err).Errorf("Replay interrupted after %d of %d messages", response.Replayed, matched)
+			return nil, err
+		}
+		telemetry.AddReplayedMessages(len(clones))
+
+		response.Replayed += len(clones)
+		lastID = messages[len(messages)-1].ID
+	}
+	log.Infof("Replayed %d sent messages", response.Replayed)
+
+	return response, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayService_Replay(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	from := time.Now().Add(-2 * time.Hour).UTC()
+	inWindow := from.Add(30 * time.Minute)
+	beforeWindow := from.Add(-time.Minute)
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "a", Status: db.MessageStatusSent, Provider: "provider-b", Campaign: "spring", SentAt: &inWindow},
+		{To: "+905552222222", Content: "b", Status: db.MessageStatusDelivered, Provider: "provider-b", SentAt: &inWindow},
+		{To: "+905553333333", Content: "c", Status: db.MessageStatusSent, Provider: "webhook", SentAt: &inWindow},
+		// failed messages are retried instead
+		{To: "+905554444444", Content: "d", Status: db.MessageStatusFailed, Provider: "provider-b", SentAt: &inWindow},
+		{To: "+905555555555", Content: "e", Status: db.MessageStatusSent, Provider: "provider-b", SentAt: &beforeWindow},
+	}
+	for _, msg := range messages {
+		_, err := testDB.NewInsert().Model(msg).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	service := NewReplayService(testDB, queue.NewPostgres(testDB), config.Replay{MaxWindow: 24 * time.Hour, MaxMessages: 2})
+	request := func() *dto.ReplayRequest {
+		return &dto.ReplayRequest{From: from, To: from.Add(time.Hour), Provider: "provider-b", DryRun: true}
+	}
+
+	t.Run("rejects invalid windows", func(t *testing.T) {
+		for _, req := range []*dto.ReplayRequest{
+			{To: from},
+			{From: from, To: from},
+			{From: from, To: time.Now().Add(time.Hour)},
+			{From: from.Add(-48 * time.Hour), To: from},
+		} {
+			_, err := service.Replay(ctx, req)
+			assert.ErrorIs(t, err, ErrInvalidReplay)
+		}
+	})
+
+	t.Run("rejects too many matches", func(t *testing.T) {
+		req := request()
+		req.Provider = ""
+		_, err := service.Replay(ctx, req)
+		assert.ErrorIs(t, err, ErrReplayTooLarge)
+	})
+
+	t.Run("dry run counts", func(t *testing.T) {
+		response, err := service.Replay(ctx, request())
+		require.NoError(t, err)
+		assert.True(t, response.DryRun)
+		assert.Equal(t, 2, response.Matched)
+		assert.Zero(t, response.Replayed)
+	})
+
+	t.Run("requires the expected count", func(t *testing.T) {
+		req := request()
+		req.DryRun = false
+		req.ExpectedCount = 3
+		_, err := service.Replay(ctx, req)
+		assert.ErrorIs(t, err, ErrReplayCountMismatch)
+	})
+
+	t.Run("replays once", func(t *testing.T) {
+		req := request()
+		req.DryRun = false
+		req.ExpectedCount = 2
+		response, err := service.Replay(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Replayed)
+
+		var clones []*db.Message
+		require.NoError(t, testDB.NewSelect().Model(&clones).Where("replay_of IS NOT NULL").Order("replay_of ASC").Scan(ctx))
+		require.Len(t, clones, 2)
+		assert.Equal(t, messages[0].ID, *clones[0].ReplayOf)
+		assert.Equal(t, db.MessageStatusPending, clones[0].Status)
+		assert.Equal(t, "spring", clones[0].Campaign)
+		assert.Equal(t, messages[1].ID, *clones[1].ReplayOf)
+
+		response, err = service.Replay(ctx, request())
+		require.NoError(t, err)
+		assert.Zero(t, response.Matched, "replayed messages are skipped")
+	})
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/uptrace/bun"
+)
+
+// Databases answering a replica read
+const (
+	readSourceReplica = "replica"
+	readSourcePrimary = "primary"
+)
+
+// replicaReads sends reads to a read replica, retrying failed ones on the primary and with hedging also sending
+// the ones the replica is slow to answer
+type replicaReads struct {
+	replica *bun.DB
+	cfg     config.Replica
+}
+
+// readReplica runs fn on the replica of r and returns its result. A failed replica read is run on primary,
+// with hedging so is a read not answered within the hedge delay, the first successful answer is used and the
+// other read cancelled. A nil r runs fn on primary.
+func readReplica[T any](ctx context.Context, r *replicaReads, primary bun.IDB, operation string, fn func(context.Context, bun.IDB) (T, error)) (T, error) {
+	if r == nil {
+		return fn(ctx, primary)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		value  T
+		err    error
+		source string
+	}
+	// buffered so the losing read does not block once it returns
+	results := make(chan result, 2)
+	run := func(source string, database bun.IDB) {
+		go func() {
+			value, err := fn(ctx, database)
+			results <- result{value: value, err: err, source: source}
+		}()
+	}
+
+	run(readSourceReplica, r.replica)
+	running, primaryStarted := 1, false
+	var hedge <-chan time.Time
+	if r.cfg.Hedge {
+		timer := time.NewTimer(r.cfg.HedgeDelay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var err error
+	for running > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			if !primaryStarted {
+				primaryStarted = true
+				running++
+				run(readSourcePrimary, primary)
+			}
+		case res := <-results:
+			running--
+			if res.err == nil {
+				telemetry.RecordReplicaRead(operation, res.source)
+				return res.value, nil
+			}
+			err = res.err
+			if res.source == readSourceReplica && !primaryStarted && ctx.Err() == nil {
+				config.LogFrom(ctx).WithError(res.err).Warnf("Replica read %s failed, reading from the primary", operation)
+				primaryStarted = true
+				running++
+				run(readSourcePrimary, primary)
+			}
+		}
+	}
+
+	var zero T
+	return zero, err
+}
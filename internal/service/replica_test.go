@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+// setupReplicaDB returns a database apart from the one of setupTestDB with the messages table only
+func setupReplicaDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file:replica?mode=memory&cache=shared")
+	require.NoError(t, err)
+
+	bunDB := bun.NewDB(sqldb, sqlitedialect.New())
+	_, err = bunDB.NewCreateTable().Model((*db.Message)(nil)).IfNotExists().Exec(context.Background())
+	require.NoError(t, err)
+	return bunDB
+}
+
+func TestReadReplica(t *testing.T) {
+	primary, replica := setupTestDB(t), setupReplicaDB(t)
+	defer primary.Close()
+	defer replica.Close()
+	ctx := context.Background()
+
+	source := func(database bun.IDB) string {
+		if database == replica {
+			return readSourceReplica
+		}
+		return readSourcePrimary
+	}
+
+	t.Run("replica", func(t *testing.T) {
+		got, err := readReplica(ctx, &replicaReads{replica: replica}, primary, "test", func(_ context.Context, database bun.IDB) (string, error) {
+			return source(database), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, readSourceReplica, got)
+	})
+
+	t.Run("failed replica read", func(t *testing.T) {
+		got, err := readReplica(ctx, &replicaReads{replica: replica}, primary, "test", func(_ context.Context, database bun.IDB) (string, error) {
+			if database == replica {
+				return "", errors.New("replica down")
+			}
+			return source(database), nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, readSourcePrimary, got)
+
+		_, err = readReplica(ctx, &replicaReads{replica: replica}, primary, "test", func(_ context.Context, database bun.IDB) (string, error) {
+			return "", errors.New(source(database) + " down")
+		})
+		assert.EqualError(t, err, "primary down")
+	})
+
+	t.Run("hedged", func(t *testing.T) {
+		slowReplica := func(ctx context.Context, database bun.IDB) (string, error) {
+			if database == replica {
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			}
+			return source(database), nil
+		}
+
+		started := time.Now()
+		got, err := readReplica(ctx, &replicaReads{replica: replica, cfg: config.Replica{Hedge: true, HedgeDelay: 10 * time.Millisecond}},
+			primary, "test", slowReplica)
+		require.NoError(t, err)
+		assert.Equal(t, readSourcePrimary, got)
+		assert.Less(t, time.Since(started), 500*time.Millisecond, "the primary answers while the replica hangs")
+
+		got, err = readReplica(ctx, &replicaReads{replica: replica, cfg: config.Replica{Hedge: true, HedgeDelay: time.Second}},
+			primary, "test", func(_ context.Context, database bun.IDB) (string, error) {
+				return source(database), nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, readSourceReplica, got, "the primary is not read when the replica answers in time")
+	})
+}
+
+func TestMessageService_ReadFromReplica(t *testing.T) {
+	primary, replica := setupTestDB(t), setupReplicaDB(t)
+	defer primary.Close()
+	defer replica.Close()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreateMessage(ctx, primary, &db.Message{To: "+905551111111", Content: "Primary"}))
+	_, err := replica.NewInsert().Model(&db.Message{To: "+905552222222", Content: "Replica", Status: db.MessageStatusPending}).Exec(ctx)
+	require.NoError(t, err)
+
+	service := NewMessageService(primary)
+	service.ReadFromReplica(replica, config.Replica{})
+	response, err := service.ListMessages(ctx, db.MessageFilter{}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, "Replica", response.Messages[0].Content)
+	assert.Equal(t, 1, response.Total)
+
+	// the replica lost its connection, the reads fall back to the primary
+	require.NoError(t, replica.Close())
+	response, err = service.ListMessages(ctx, db.MessageFilter{}, query.Page{Number: 1, Size: 10})
+	require.NoError(t, err)
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, "Primary", response.Messages[0].Content)
+}
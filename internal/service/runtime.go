@@ -0,0 +1,111 @@
+package service
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/routing"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// processStarted is when the process started, the uptime counts from it
+var processStarted = time.Now()
+
+// Build returns the build of the binary. The commit is the one the Go toolchain stamped, empty when the binary was
+// not built from a checkout.
+func Build() dto.BuildInfo {
+	build := dto.BuildInfo{Version: config.Version, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Commit = setting.Value
+		case "vcs.time":
+			build.CommitTime = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// RuntimeService reports the build, the Go runtime, the scheduler internals and the effective configuration of
+// the instance, so an incident can be triaged without a shell on it
+type RuntimeService struct {
+	cfg       *config.Cfg
+	scheduler *Scheduler
+}
+
+// NewRuntimeService creates the runtime report of an instance, scheduler is nil when it runs none
+func NewRuntimeService(cfg *config.Cfg, scheduler *Scheduler) *RuntimeService {
+	return &RuntimeService{cfg: cfg, scheduler: scheduler}
+}
+
+// Runtime returns the runtime of the instance, the secrets of its configuration are redacted
+func (s *RuntimeService) Runtime() *dto.RuntimeResponse {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	hostname, _ := os.Hostname()
+
+	response := &dto.RuntimeResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Build:          Build(),
+		Hostname:       hostname,
+		PID:            os.Getpid(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		NumCPU:         runtime.NumCPU(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: memory.HeapAlloc,
+		GCCycles:       memory.NumGC,
+		StartedAt:      processStarted.UTC(),
+		UptimeSeconds:  int64(time.Since(processStarted).Seconds()),
+		Config:         s.cfg.Redacted(),
+	}
+	if s.scheduler != nil {
+		response.Scheduler = s.scheduler.runtime()
+	}
+	return response
+}
+
+// Routing returns the routes of the scheduler of the instance with the health scores and shares of their providers.
+// An instance without a scheduler reports the routes of its configuration, without any sends.
+func (s *RuntimeService) Routing() *dto.RoutingResponse {
+	router := routing.New(s.cfg)
+	if s.scheduler != nil {
+		router = s.scheduler.router
+	}
+
+	response := &dto.RoutingResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		WindowSeconds: int64(s.cfg.Routing.Health.Window.Seconds()),
+	}
+	for _, route := range router.Status() {
+		converted := dto.RouteRouting{Prefix: route.Prefix}
+		for _, provider := range route.Providers {
+			converted.Providers = append(converted.Providers, dto.RouteProvider{
+				Provider:  provider.Provider,
+				Weight:    provider.Weight,
+				Score:     provider.Score,
+				Share:     provider.Share,
+				Sends:     provider.Sends,
+				Failures:  provider.Failures,
+				LatencyMS: provider.Latency.Milliseconds(),
+				Decisions: provider.Decisions,
+			})
+		}
+		response.Routes = append(response.Routes, converted)
+	}
+	return response
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeService_Runtime(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Server: config.Server{
+			Address: ":8080",
+			APIKey:  "top-secret",
+			APIKeys: []config.APIKey{{Name: "ops", Key: "ops-secret"}},
+		},
+		Database: config.Database{
+			DSN:     "postgres://sendpulse:hunter2@db:5432/sendpulse",
+			Replica: config.Replica{DSN: "host=replica user=sendpulse password=hunter2 dbname=sendpulse"},
+		},
+		Messaging: config.Messaging{BatchSize: 5, Interval: 2 * time.Minute},
+		Region:    config.Region{Name: "eu-west", Role: config.RegionPassive},
+	}
+	scheduler := NewScheduler(testDB, cfg)
+	scheduler.SetRegionFailover(NewRegionFailover(cfg.Region))
+
+	response := NewRuntimeService(cfg, scheduler).Runtime()
+	assert.Equal(t, config.Version, response.Build.Version)
+	assert.NotEmpty(t, response.Build.GoVersion)
+	assert.Positive(t, response.GOMAXPROCS)
+	assert.Positive(t, response.Goroutines)
+	assert.False(t, response.StartedAt.After(time.Now()))
+
+	require.NotNil(t, response.Scheduler)
+	assert.False(t, response.Scheduler.Running)
+	assert.True(t, response.Scheduler.Paused, "claiming is paused while the region is passive")
+	assert.Equal(t, "eu-west", response.Scheduler.Region)
+	assert.Equal(t, config.RegionPassive, response.Scheduler.RegionRole)
+	assert.NotEmpty(t, response.Scheduler.Holder)
+
+	server := response.Config["server"].(map[string]any)
+	assert.Equal(t, ":8080", server["address"])
+	assert.Equal(t, config.RedactedValue, server["api_key"])
+	assert.Equal(t, config.RedactedValue, server["api_keys"].([]any)[0].(map[string]any)["key"])
+	assert.Equal(t, "ops", server["api_keys"].([]any)[0].(map[string]any)["name"])
+
+	database := response.Config["database"].(map[string]any)
+	assert.NotContains(t, database["dsn"], "hunter2")
+	assert.Contains(t, database["dsn"], "@db:5432/sendpulse", "the rest of the DSN is kept")
+	assert.NotContains(t, database["replica"].(map[string]any)["dsn"], "hunter2")
+	assert.NotContains(t, database, "DB", "the connection is not configuration")
+	assert.Equal(t, "2m0s", response.Config["messaging"].(map[string]any)["interval"])
+	assert.Equal(t, "", response.Config["sentry"].(map[string]any)["dsn"])
+
+	assert.Nil(t, NewRuntimeService(cfg, nil).Runtime().Scheduler, "an instance without a scheduler reports none")
+}
+
+func TestRuntimeService_Routing(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{URL: "https://webhook.example.com"},
+		Routing: config.Routing{
+			Providers: []config.Provider{{Name: "provider-b", URL: "https://b.example.com"}},
+			Routes: []config.Route{{Prefix: "+90", Providers: []config.WeightedProvider{
+				{Name: config.WebhookProvider, Weight: 1},
+				{Name: "provider-b", Weight: 1},
+			}}},
+			Health: config.ProviderHealth{Window: 5 * time.Minute, LatencyTarget: time.Second, MinSamples: 1, MinScore: 0.05},
+		},
+	}
+	scheduler := NewScheduler(testDB, cfg)
+	route := scheduler.router.Route("+905551111111")
+	scheduler.router.Observe(route, 250*time.Millisecond, false)
+
+	response := NewRuntimeService(cfg, scheduler).Routing()
+	assert.EqualValues(t, 300, response.WindowSeconds)
+	require.Len(t, response.Routes, 2)
+	assert.Equal(t, "+90", response.Routes[0].Prefix)
+	require.Len(t, response.Routes[0].Providers, 2)
+	for _, provider := range response.Routes[0].Providers {
+		assert.Equal(t, 0.5, provider.Share)
+		if provider.Provider == route.Provider {
+			assert.Equal(t, 1, provider.Sends)
+			assert.EqualValues(t, 250, provider.LatencyMS)
+			assert.EqualValues(t, 1, provider.Decisions)
+		}
+	}
+	assert.Equal(t, config.WebhookProvider, response.Routes[1].Providers[0].Provider, "the default route is last")
+
+	configured := NewRuntimeService(cfg, nil).Routing()
+	require.Len(t, configured.Routes, 2, "an instance without a scheduler reports the configured routes")
+	assert.Zero(t, configured.Routes[0].Providers[0].Decisions)
+}
@@ -1,16 +1,30 @@
 package service
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
 	"github.com/boratanrikulu/sendpulse/internal/db"
-	"github.com/boratanrikulu/sendpulse/internal/dto"
-	"github.com/boratanrikulu/sendpulse/internal/webhook"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/routing"
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/internal/transform"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/sirupsen/logrus"
 	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const MAXIMUM_MESSAGE_SENDING_TIME = 5 * time.Second
@@ -26,81 +40,312 @@ type SchedulerInterface interface {
 // Scheduler handles the automatic message sending functionality
 type Scheduler struct {
 	db            *bun.DB
+	queue         queue.Queue
 	cfg           *config.Cfg
 	webhookClient *webhook.Client
-	running       bool
-	stopCh        chan struct{}
-	mu            sync.RWMutex
+	// sandboxClient sends the messages routed to config.SandboxProvider
+	sandboxClient *webhook.Client
+	router        *routing.Router
+	throttles     *routing.Throttles
+	transforms    *transform.Pipelines
+	reports       DeliveryReportInterface
+	// payloads stores the provider response bodies above webhook.payloads.threshold, nil drops them
+	payloads PayloadStore
+	// box decrypts the credentials of the webhook overrides, overrides are the ones loaded by the last batch
+	box       *secrets.Box
+	overrides atomic.Pointer[webhookOverrides]
+	// pauses are the recipient pauses loaded by the last batch
+	pauses atomic.Pointer[recipientPauses]
+	// campaigns are the campaigns held by the approval workflow loaded by the last batch
+	campaigns atomic.Pointer[heldCampaigns]
+	// availability pauses claiming while the database is unreachable, nil when it is not tracked
+	availability *DatabaseAvailability
+	// readOnly pauses claiming while the read-only mode is enabled, nil when there is none
+	readOnly *ReadOnlyMode
+	// region pauses claiming while the region of the instance is passive, nil when there is no failover
+	region *RegionFailover
+	// holder identifies the scheduler as the holder of the leader lease, leader is whether it holds it
+	holder  string
+	leader  atomic.Bool
+	running bool
+	// following is set while Run keeps the scheduler in line with the cluster control, unfollow ends it
+	following atomic.Bool
+	unfollow  context.CancelFunc
+	// clusterEnabled is the last known state of the cluster control, see controlState
+	clusterEnabled atomic.Int32
+	// batches is the number of running batches, queued is set when a batch waits for them to finish,
+	// see config.Messaging.OverlapPolicy
+	batchMu sync.Mutex
+	batches int
+	queued  bool
+	stopCh  chan struct{}
+	mu      sync.RWMutex
+	loops   sync.WaitGroup
+	// inFlight are the messages claimed by the scheduler until they are settled, the sends left at a shutdown
+	// are marked for the reaper
+	inFlightMu sync.Mutex
+	inFlight   map[int64]*inFlightSend
+}
+
+// inFlightSend is a message claimed by the scheduler. Its send is cancelled with ErrSendCancelled until the
+// webhook call is issued, done is closed once the message was settled.
+type inFlightSend struct {
+	cancel    context.CancelCauseFunc
+	cancelled bool
+	issued    bool
+	done      chan struct{}
 }
 
 func NewScheduler(database *bun.DB, cfg *config.Cfg) *Scheduler {
-	return &Scheduler{
+	q := queue.NewPostgres(database)
+	q.SetPriorityAging(queue.PriorityAging(cfg.Queue.PriorityAging))
+	return NewSchedulerWithQueue(database, q, cfg)
+}
+
+// NewSchedulerWithQueue creates a scheduler sending the messages of q, database is used for the
+// suppression checks and the queue gauges
+func NewSchedulerWithQueue(database *bun.DB, q queue.Queue, cfg *config.Cfg) *Scheduler {
+	s := &Scheduler{
 		db:            database,
+		queue:         q,
 		cfg:           cfg,
 		webhookClient: webhook.NewClient(cfg),
+		router:        routing.New(cfg),
+		throttles:     routing.NewThrottles(cfg),
+		transforms:    transform.New(cfg.Transforms, cfg.LinkTracking),
+		box:           webhookBox(cfg.Webhook),
+		payloads:      NewPayloadStore(database, cfg.Webhook.Payloads.Storage),
+		reports:       NewDeliveryReportService(database, cfg.DeliveryReports, nil),
+		holder:        leaseHolder(),
 		stopCh:        make(chan struct{}),
+		inFlight:      make(map[int64]*inFlightSend),
+	}
+	s.sandboxClient = s.webhookClient.WithHandler(webhook.NewSandbox(webhook.SandboxOptions{
+		ReportDelay:        cfg.Routing.Sandbox.ReportDelay,
+		DelayedReportDelay: cfg.Routing.Sandbox.DelayedReportDelay,
+		Report:             s.applySandboxReport,
+	}))
+	return s
+}
+
+// SetDeliveryReports sets the service the delivery reports of the sandbox provider are applied with,
+// by default they do not publish events. Must be called before Start.
+func (s *Scheduler) SetDeliveryReports(reports DeliveryReportInterface) {
+	s.reports = reports
+}
+
+// SetAvailability makes the scheduler pause claiming while availability reports the database down and
+// report the connection errors of its claims to it. Must be called before Start.
+func (s *Scheduler) SetAvailability(availability *DatabaseAvailability) {
+	s.availability = availability
+}
+
+// SetReadOnlyMode makes the scheduler pause claiming while mode is enabled. Must be called before Start.
+func (s *Scheduler) SetReadOnlyMode(mode *ReadOnlyMode) {
+	s.readOnly = mode
+}
+
+// SetRegionFailover makes the scheduler pause claiming while the region of failover is passive. Must be called
+// before Start.
+func (s *Scheduler) SetRegionFailover(failover *RegionFailover) {
+	s.region = failover
+}
+
+// paused reports whether claiming is paused because the database is unreachable, the server is read-only or its
+// region is passive
+func (s *Scheduler) paused() bool {
+	return s.availability != nil && !s.availability.Up() || s.readOnly != nil && s.readOnly.Enabled() ||
+		s.region != nil && s.region.Passive()
+}
+
+// applySandboxReport applies a delivery report of the sandbox provider as if the provider posted it
+func (s *Scheduler) applySandboxReport(ctx context.Context, report webhook.SandboxReport) error {
+	_, err := s.reports.HandleDeliveryReport(ctx, &dto.DeliveryReportRequest{
+		MessageID: report.MessageID,
+		Status:    report.Status,
+		Error:     report.Error,
+	})
+	if err != nil {
+		config.LogFrom(ctx).WithError(err).WithField("webhook_message_id", report.MessageID).Warn("Failed to apply sandbox delivery report")
 	}
+	return err
 }
 
-// Start begins the automatic message sending process
+// Start begins the automatic message sending process. While the scheduler follows the cluster control
+// (see Run) it starts messaging on every instance.
 func (s *Scheduler) Start(ctx context.Context) (*dto.MessagingControlResponse, error) {
+	if s.following.Load() {
+		changed, err := db.SetControl(ctx, s.db, db.MessagingControlName, true, s.holder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start messaging across the cluster: %w", err)
+		}
+		s.clusterEnabled.Store(controlState(true))
+		s.start(context.WithoutCancel(ctx))
+		if !changed {
+			return controlResponse("error", dto.ControlAlreadyRunning, "Messaging service is already running"), nil
+		}
+		config.Log().WithField("holder", s.holder).Info("Messaging started across the cluster")
+		return controlResponse("success", dto.ControlStarted, "Messaging service started across the cluster"), nil
+	}
+
+	if !s.start(ctx) {
+		return controlResponse("error", dto.ControlAlreadyRunning, "Messaging service is already running"), nil
+	}
+	return controlResponse("success", dto.ControlStarted, "Messaging service started successfully"), nil
+}
+
+// Stop halts the automatic message sending process. While the scheduler follows the cluster control
+// (see Run) it stops messaging on every instance.
+func (s *Scheduler) Stop(ctx context.Context) (*dto.MessagingControlResponse, error) {
+	if s.following.Load() {
+		changed, err := db.SetControl(ctx, s.db, db.MessagingControlName, false, s.holder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop messaging across the cluster: %w", err)
+		}
+		s.clusterEnabled.Store(controlState(false))
+		s.stop()
+		if !changed {
+			return controlResponse("error", dto.ControlNotRunning, "Messaging service is not running"), nil
+		}
+		config.Log().WithField("holder", s.holder).Info("Messaging stopped across the cluster")
+		return controlResponse("success", dto.ControlStopped, "Messaging service stopped across the cluster"), nil
+	}
+
+	if !s.stop() {
+		return controlResponse("error", dto.ControlNotRunning, "Messaging service is not running"), nil
+	}
+	return controlResponse("success", dto.ControlStopped, "Messaging service stopped successfully"), nil
+}
+
+// start starts the processing loop of this instance, it returns false when it was running
+func (s *Scheduler) start(ctx context.Context) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.running {
-		return &dto.MessagingControlResponse{
-			BaseResponse: dto.BaseResponse{
-				Status:    "error",
-				Timestamp: time.Now().UTC(),
-			},
-			Message: "Messaging service is already running",
-		}, nil
+		return false
 	}
 
 	s.running = true
 	s.stopCh = make(chan struct{})
 
 	// Start the message processing loop in a goroutine
-	go s.processMessages(ctx)
+	s.loops.Add(1)
+	go s.processMessages(ctx, s.stopCh)
 
 	config.Log().Info("Messaging service started")
-
-	return &dto.MessagingControlResponse{
-		BaseResponse: dto.BaseResponse{
-			Status:    "success",
-			Timestamp: time.Now().UTC(),
-		},
-		Message: "Messaging service started successfully",
-	}, nil
+	return true
 }
 
-// Stop halts the automatic message sending process
-func (s *Scheduler) Stop(ctx context.Context) (*dto.MessagingControlResponse, error) {
+// stop stops the processing loop of this instance, it returns false when it was not running
+func (s *Scheduler) stop() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if !s.running {
-		return &dto.MessagingControlResponse{
-			BaseResponse: dto.BaseResponse{
-				Status:    "error",
-				Timestamp: time.Now().UTC(),
-			},
-			Message: "Messaging service is not running",
-		}, nil
+		return false
 	}
 
 	s.running = false
 	close(s.stopCh)
 
 	config.Log().Info("Messaging service stopped")
+	return true
+}
 
+func controlResponse(status, code, message string) *dto.MessagingControlResponse {
 	return &dto.MessagingControlResponse{
 		BaseResponse: dto.BaseResponse{
-			Status:    "success",
+			Status:    status,
 			Timestamp: time.Now().UTC(),
 		},
-		Message: "Messaging service stopped successfully",
-	}, nil
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Run starts messaging and, with messaging.control_interval set, keeps the scheduler in line with the cluster
+// wide messaging control until ctx is cancelled or Handoff is called: Start and Stop then apply to every
+// instance, and a restarting instance stays stopped when messaging was stopped across the cluster. Without a
+// control record messaging starts when messaging.enabled is set.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.cfg.Messaging.ControlInterval <= 0 {
+		if s.cfg.Messaging.Enabled {
+			s.start(context.WithoutCancel(ctx))
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.unfollow = cancel
+	s.mu.Unlock()
+	s.following.Store(true)
+
+	control, err := db.GetControl(ctx, s.db, db.MessagingControlName)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to read the messaging control: %w", err)
+	}
+	if control == nil {
+		if s.cfg.Messaging.Enabled {
+			s.start(context.WithoutCancel(ctx))
+		}
+	} else {
+		s.applyControl(ctx, control)
+	}
+
+	go s.followControl(ctx)
+	return nil
+}
+
+// followControl polls the messaging control every messaging.control_interval and applies its changes
+func (s *Scheduler) followControl(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Messaging.ControlInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			control, err := db.GetControl(ctx, s.db, db.MessagingControlName)
+			if err != nil {
+				if ctx.Err() == nil {
+					config.Log().WithField("component", "scheduler").WithError(err).Warn("Failed to read the messaging control")
+				}
+				continue
+			}
+			if control != nil {
+				s.applyControl(ctx, control)
+			}
+		}
+	}
+}
+
+// applyControl starts or stops this instance as the control says
+func (s *Scheduler) applyControl(ctx context.Context, control *db.Control) {
+	if ctx.Err() != nil {
+		return
+	}
+	s.clusterEnabled.Store(controlState(control.Enabled))
+
+	log := config.Log().WithField("component", "scheduler").WithField("changed_by", control.ChangedBy)
+	if control.Enabled {
+		if s.start(context.WithoutCancel(ctx)) {
+			log.Info("Messaging started by the cluster control")
+		}
+	} else if s.stop() {
+		log.Info("Messaging stopped by the cluster control")
+	}
+}
+
+// controlState is the cluster state as stored in clusterEnabled: 0 unknown, 1 stopped, 2 started
+func controlState(enabled bool) int32 {
+	if enabled {
+		return 2
+	}
+	return 1
 }
 
 // GetStatus returns the current status of the messaging service
@@ -108,17 +353,53 @@ func (s *Scheduler) GetStatus() *dto.MessagingStatusResponse {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return &dto.MessagingStatusResponse{
+	var clusterEnabled *bool
+	if state := s.clusterEnabled.Load(); state != 0 {
+		enabled := state == controlState(true)
+		clusterEnabled = &enabled
+	}
+
+	response := &dto.MessagingStatusResponse{
 		BaseResponse: dto.BaseResponse{
 			Status:    "ok",
 			Timestamp: time.Now().UTC(),
 		},
-		Enabled:    s.running,
-		Interval:   s.cfg.Messaging.Interval.String(),
-		BatchSize:  s.cfg.Messaging.BatchSize,
-		MaxRetries: s.cfg.Messaging.MaxRetries,
-		RetryDelay: s.cfg.Messaging.RetryDelay.String(),
+		Enabled:        s.running,
+		ClusterEnabled: clusterEnabled,
+		Role:           s.role(),
+		Paused:         s.paused(),
+		Region:         s.cfg.Region.Name,
+		Interval:       s.cfg.Messaging.Interval.String(),
+		BatchSize:      s.cfg.Messaging.BatchSize,
+		MaxRetries:     s.cfg.Messaging.MaxRetries,
+		RetryDelay:     s.cfg.Messaging.RetryDelay.String(),
+	}
+	if s.region != nil {
+		response.RegionRole, _, _ = s.region.Status()
 	}
+	return response
+}
+
+// runtime returns the internals of the scheduler, see RuntimeService
+func (s *Scheduler) runtime() *dto.SchedulerRuntime {
+	status := s.GetStatus()
+	response := &dto.SchedulerRuntime{
+		Running:    status.Enabled,
+		Following:  s.following.Load(),
+		Holder:     s.holder,
+		Role:       status.Role,
+		Paused:     status.Paused,
+		Region:     status.Region,
+		RegionRole: status.RegionRole,
+	}
+
+	s.batchMu.Lock()
+	response.Batches, response.BatchQueued = s.batches, s.queued
+	s.batchMu.Unlock()
+	s.inFlightMu.Lock()
+	response.InFlight = len(s.inFlight)
+	s.inFlightMu.Unlock()
+	return response
 }
 
 // IsRunning returns whether the messaging service is currently running
@@ -128,8 +409,89 @@ func (s *Scheduler) IsRunning() bool {
 	return s.running
 }
 
-// processMessages is the main message processing loop
-func (s *Scheduler) processMessages(ctx context.Context) {
+// Wait blocks until every processing loop started by Start has returned
+// Used on shutdown after Stop or context cancellation to let the current batch finish
+func (s *Scheduler) Wait() {
+	s.loops.Wait()
+}
+
+// Handoff hands sending over to another instance on shutdown. It stops claiming, requeues the claimed messages
+// not sent yet, waits until the in-flight sends finished or ctx is done and releases the leader lease, so a
+// standby scheduler takes over on its next lease check instead of once the lease expired. It returns the error
+// of ctx when sends were still in flight, their messages are left to the stuck reaper after
+// messaging.shutdown_reap_after.
+func (s *Scheduler) Handoff(ctx context.Context) error {
+	log := config.Log().WithField("component", "scheduler")
+	// a stopping instance leaves the cluster control as it is
+	s.mu.Lock()
+	if s.unfollow != nil {
+		s.unfollow()
+	}
+	s.mu.Unlock()
+	s.following.Store(false)
+	s.stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.reapInFlight(context.WithoutCancel(ctx))
+	}
+
+	// the claimed messages are not pending, releasing the lease early cannot make them sent twice
+	if s.leader.Swap(false) {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if releaseErr := db.ReleaseLease(releaseCtx, s.db, db.SchedulerLeaseName, s.holder); releaseErr != nil {
+			log.WithError(releaseErr).Error("Failed to release the scheduler leadership, a standby takes over once it expires")
+		} else {
+			log.Info("Scheduler leadership released")
+		}
+	}
+	return err
+}
+
+// reapInFlight marks the messages still being sent, so the stuck reaper sends them again after
+// messaging.shutdown_reap_after instead of once they are sending for maintenance.stuck_reaper.older_than
+func (s *Scheduler) reapInFlight(ctx context.Context) {
+	log := config.Log().WithField("component", "scheduler")
+
+	s.inFlightMu.Lock()
+	ids := make([]int64, 0, len(s.inFlight))
+	for id, send := range s.inFlight {
+		// the messages not sent yet are requeued by the stopped batches
+		if send.issued {
+			ids = append(ids, id)
+		}
+	}
+	s.inFlightMu.Unlock()
+
+	if s.cfg.Messaging.ShutdownReapAfter <= 0 {
+		log.Warnf("Handing over with %d sends still in flight, their messages stay in sending", len(ids))
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	marked, err := db.SetReapAfter(ctx, s.db, ids, time.Now().Add(s.cfg.Messaging.ShutdownReapAfter))
+	if err != nil {
+		log.WithError(err).Errorf("Handing over with %d sends still in flight, failed to mark them for the stuck reaper", len(ids))
+		return
+	}
+	log.Warnf("Handing over with %d sends still in flight, the stuck reaper sends them again after %s",
+		marked, s.cfg.Messaging.ShutdownReapAfter)
+}
+
+// processMessages is the main message processing loop, it runs until stopCh of its start is closed
+func (s *Scheduler) processMessages(ctx context.Context, stopCh <-chan struct{}) {
+	defer s.loops.Done()
+
 	ticker := time.NewTicker(s.cfg.Messaging.Interval)
 	defer ticker.Stop()
 
@@ -137,6 +499,14 @@ func (s *Scheduler) processMessages(ctx context.Context) {
 		return
 	}
 
+	// a standby checks the lease more often than it would claim, so it takes over right after a handoff
+	var standby <-chan time.Time
+	if s.cfg.Messaging.LeaderLease > 0 {
+		standbyTicker := time.NewTicker(min(standbyInterval, s.cfg.Messaging.Interval))
+		defer standbyTicker.Stop()
+		standby = standbyTicker.C
+	}
+
 	config.Log().Info("Message processing loop started")
 
 	for {
@@ -144,27 +514,190 @@ func (s *Scheduler) processMessages(ctx context.Context) {
 		case <-ctx.Done():
 			config.Log().Info("Message processing stopped due to context cancellation")
 			return
-		case <-s.stopCh:
+		case <-stopCh:
 			config.Log().Info("Message processing stopped")
 			return
 		case <-ticker.C:
-			s.processBatch(ctx)
+			if !s.paused() && s.lead(ctx) {
+				s.tick(ctx)
+			}
+		case <-standby:
+			if !s.paused() && !s.leader.Load() && s.lead(ctx) {
+				s.tick(ctx)
+			}
+		}
+	}
+}
+
+// standbyInterval is how often a standby scheduler checks whether the leader lease is free
+const standbyInterval = time.Second
+
+// lead reports whether the scheduler may claim messages. With messaging.leader_lease set it acquires or
+// renews the lease, a scheduler that cannot tell whether it holds the lease does not claim.
+func (s *Scheduler) lead(ctx context.Context) bool {
+	if s.cfg.Messaging.LeaderLease <= 0 {
+		return true
+	}
+
+	log := config.Log().WithField("component", "scheduler").WithField("holder", s.holder)
+	acquired, err := db.AcquireLease(ctx, s.db, db.SchedulerLeaseName, s.holder, s.cfg.Messaging.LeaderLease)
+	if err != nil {
+		log.WithError(err).Error("Failed to acquire the scheduler leadership")
+		acquired = false
+	}
+
+	if was := s.leader.Swap(acquired); was != acquired {
+		if acquired {
+			log.Info("Scheduler leadership acquired")
+		} else {
+			log.Warn("Scheduler leadership lost, standing by")
+		}
+	}
+	return acquired
+}
+
+// role returns leader or standby while the leader lease is enabled
+func (s *Scheduler) role() string {
+	if s.cfg.Messaging.LeaderLease <= 0 {
+		return ""
+	}
+	if s.leader.Load() {
+		return "leader"
+	}
+	return "standby"
+}
+
+// leaseHolder identifies a scheduler among the instances sharing the database
+func leaseHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "sendpulse"
+	}
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), holderSeq.Add(1))
+}
+
+var holderSeq atomic.Int64
+
+// Actions taken on a tick overlapping a running batch, the labels of telemetry.BatchOverlaps
+const (
+	overlapSkipped    = "skipped"
+	overlapQueued     = "queued"
+	overlapConcurrent = "concurrent"
+)
+
+// tick starts a batch, applying messaging.overlap_policy when the previous one is still running
+func (s *Scheduler) tick(ctx context.Context) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	if s.batches > 0 {
+		log := config.Log().WithField("component", "scheduler").WithField("running", s.batches)
+		switch s.cfg.Messaging.OverlapPolicy {
+		case config.OverlapQueue:
+			telemetry.RecordBatchOverlap(overlapQueued)
+			if !s.queued {
+				s.queued = true
+				log.Warn("Previous batch is still running, the next one starts once it finished")
+			}
+			return
+		case config.OverlapConcurrent:
+			if s.batches >= s.cfg.Messaging.MaxConcurrentBatches {
+				telemetry.RecordBatchOverlap(overlapSkipped)
+				log.Warn("Concurrent batch limit reached, skipping the tick")
+				return
+			}
+			telemetry.RecordBatchOverlap(overlapConcurrent)
+			log.Warn("Previous batch is still running, starting another one alongside")
+		default:
+			telemetry.RecordBatchOverlap(overlapSkipped)
+			log.Warn("Previous batch is still running, skipping the tick")
+			return
 		}
 	}
+
+	s.batches++
+	s.loops.Add(1)
+	go s.runBatches(ctx)
+}
+
+// runBatches processes a batch and then the batch queued meanwhile, if any, while the scheduler runs
+func (s *Scheduler) runBatches(ctx context.Context) {
+	defer s.loops.Done()
+
+	for {
+		s.processBatch(ctx)
+
+		s.batchMu.Lock()
+		if !s.queued || ctx.Err() != nil || !s.IsRunning() {
+			s.queued = false
+			s.batches--
+			s.batchMu.Unlock()
+			return
+		}
+		s.queued = false
+		s.batchMu.Unlock()
+	}
 }
 
 // processBatch processes a batch of messages
 func (s *Scheduler) processBatch(ctx context.Context) {
+	// every batch is the root of its own trace
+	ctx, span := telemetry.Tracer().Start(ctx, "Scheduler.processBatch", trace.WithNewRoot())
+	defer span.End()
+
+	log := config.Log().WithField("component", "scheduler").WithFields(telemetry.LogFields(ctx))
+	ctx = config.ContextWithLog(ctx, log)
+	defer s.recoverPanic(ctx)
+
+	// stopping the scheduler ends the claims and the waits for send slots, the messages not sent yet are
+	// requeued right away while the sends already running finish
+	ctx, cancel := s.untilStopped(ctx)
+	defer cancel()
+
+	// without the overrides the messages of white-label tenants and campaigns would go to webhook.url
+	overrides, err := loadWebhookOverrides(ctx, s.db, s.box)
+	if err != nil {
+		log.Errorf("Failed to load webhook overrides, skipping batch: %v", err)
+		return
+	}
+	s.overrides.Store(overrides)
+	// without the pauses the messages to paused recipients would be sent
+	pauses, err := loadRecipientPauses(ctx, s.db)
+	if err != nil {
+		log.Errorf("Failed to load recipient pauses, skipping batch: %v", err)
+		return
+	}
+	s.pauses.Store(pauses)
+	// without the held campaigns a blast would go out before it was approved
+	campaigns, err := loadHeldCampaigns(ctx, s.db, s.cfg.Campaigns)
+	if err != nil {
+		log.Errorf("Failed to load held campaigns, skipping batch: %v", err)
+		return
+	}
+	s.campaigns.Store(campaigns)
+	// the first sends of the batch take the connections opened ahead instead of each dialing the provider
+	if connections := s.cfg.Webhook.Connections.Prewarm; connections > 0 {
+		for provider, err := range s.warmupProviders(ctx, connections) {
+			if err != nil {
+				log.WithError(err).WithField("provider", provider).Warn("Failed to prewarm the provider connections")
+			}
+		}
+	}
+
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, s.cfg.Messaging.BatchSize)
 
-	config.Log().Infof("Processing messages")
+	log.Info("Processing messages")
 
 	var sentCount int
-	for i := 0; i < s.cfg.Messaging.BatchSize; i++ {
-		message, err := db.ClaimNextMessage(ctx, s.db)
+	for i := 0; i < s.cfg.Messaging.BatchSize && ctx.Err() == nil; i++ {
+		message, err := s.queue.Claim(ctx)
 		if err != nil {
-			config.Log().Errorf("Failed to claim message: %v", err)
+			log.Errorf("Failed to claim message: %v", err)
+			if s.availability != nil && s.availability.Report(err) {
+				log.Warn("Database is unreachable, claiming is paused until it returns")
+				break
+			}
 			continue
 		}
 
@@ -174,54 +707,649 @@ func (s *Scheduler) processBatch(ctx context.Context) {
 
 		wg.Add(1)
 		sentCount++
+		// tracked right away, so the message can be cancelled while it waits for a send slot
+		msgCtx := s.trackInFlight(ctx, message.ID)
 		go func(msg *db.Message) {
 			defer wg.Done()
+			defer s.untrackInFlight(msg.ID)
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			s.processMessage(ctx, msg)
+			s.processMessage(msgCtx, msg)
 		}(message)
 	}
 
-	done := make(chan struct{})
+	// the sends outlive ctx, they are bounded by MAXIMUM_MESSAGE_SENDING_TIME
+	wg.Wait()
+	if ctx.Err() != nil {
+		log.Infof("Batch processing stopped, proceed %d messages", sentCount)
+	} else {
+		log.Infof("Batch processing completed, proceed %d messages", sentCount)
+	}
+	span.SetAttributes(attribute.Int("sendpulse.batch.messages", sentCount))
+}
+
+// untilStopped returns a context cancelled once the scheduler is stopped
+func (s *Scheduler) untilStopped(ctx context.Context) (context.Context, context.CancelFunc) {
+	s.mu.RLock()
+	stopCh := s.stopCh
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
 	go func() {
-		wg.Wait()
-		close(done)
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
-
-	select {
-	case <-ctx.Done():
-		config.Log().Info("Batch processing cancelled")
-	case <-done:
-		config.Log().Infof("Batch processing completed, proceed %d messages", sentCount)
-	}
+	return ctx, cancel
 }
 
+// processMessage sends a claimed message. Until the send starts a cancelled ctx requeues the message, or cancels
+// it when CancelSend cancelled ctx, the send itself runs to completion and its status update within
+// messaging.persist_timeout.
 func (s *Scheduler) processMessage(ctx context.Context, message *db.Message) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Scheduler.processMessage",
+		trace.WithAttributes(attribute.Int64("sendpulse.message.id", message.ID)))
+	defer span.End()
+
+	log := config.LogFrom(ctx).WithField("message_id", message.ID).WithFields(telemetry.LogFields(ctx))
+	if message.Tenant != "" {
+		log = log.WithField("tenant", message.Tenant)
+	}
+	if message.Campaign != "" {
+		log = log.WithField("campaign", message.Campaign)
+	}
+	ctx = config.ContextWithLog(ctx, log)
+	defer s.recoverMessagePanic(ctx, message)
+
+	if s.expireLate(ctx, message) || s.blockSuppressed(ctx, message) || s.deferPaused(ctx, message) ||
+		s.holdCampaign(ctx, message) || s.skipCampaignSent(ctx, message) || !s.throttle(ctx, message) {
+		return
+	}
+	route, ok := s.route(ctx, message)
+	if !ok {
+		return
+	}
+	log = log.WithField("provider", route.Provider)
+	ctx = config.ContextWithLog(ctx, log)
+	content, ok := s.render(ctx, message)
+	if !ok {
+		return
+	}
+
 	payload := webhook.MessagePayload{
 		To:      message.To,
-		Content: message.Content,
+		Content: content,
+		From:    route.SenderID,
 	}
 
+	// the scheduler stopped or an operator cancelled the send while the message waited, it was not attempted
+	// and is sent by the next claim unless it was cancelled
+	if ctx.Err() != nil || !s.issueSend(message.ID) {
+		log.Debug("Send stopped before the webhook call, requeueing or cancelling message")
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
+		}
+		s.skipped(ctx, message, db.SkipStopped, "")
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
 	cctx, cancel := context.WithTimeout(ctx, MAXIMUM_MESSAGE_SENDING_TIME)
 	defer cancel()
-	response, err := s.webhookClient.SendMessageWithRetry(cctx, payload)
+	started := time.Now()
+	var attempts []*db.SendAttempt
+	var onAttempt func(webhook.Attempt)
+	if s.cfg.Messaging.SendAttempts {
+		onAttempt = func(attempt webhook.Attempt) {
+			attempts = append(attempts, sendAttempt(message.ID, route.Provider, attempt))
+		}
+	}
+	response, err := s.send(cctx, route, payload, onAttempt)
+	s.router.Observe(route, time.Since(started), err != nil && webhook.ClassifyError(err).Transient())
+	// the send went out, its result is written even when the batch was stopped meanwhile
+	pctx, cancelPersist := s.persistContext(ctx)
+	defer cancelPersist()
+	// recorded before the message is settled, so a settled message lists all of its attempts
+	if err := db.CreateSendAttempts(pctx, s.db, attempts); err != nil {
+		log.Warnf("Failed to record the send attempts of the message: %v", err)
+	}
+	if err != nil {
+		telemetry.ObserveSend(string(db.MessageStatusFailed), messageLabels(message), time.Since(started))
+		class := webhook.ClassifyError(err)
+		log.WithField("failure_class", class).Errorf("Failed to send message: %v", err)
+		telemetry.RecordError(span, err)
+		// retries are exhausted at this point, so only repeated failures are reported; an outage fails every send
+		// of the provider, so they are sampled and grouped by provider and failure class
+		telemetry.CaptureSampled(ctx, err, []string{"webhook", route.Provider, string(class)},
+			map[string]string{"component": "webhook", "provider": route.Provider, "failure_class": string(class)})
+		classifyFailure(message, class, err)
+		if updateErr := s.queue.Fail(pctx, message); updateErr != nil {
+			settleFailed(log, "Failed to update message to failed status", updateErr)
+		}
+		return
+	}
+
+	responseJSON, err := storeResponse(pctx, s.payloads, s.cfg.Webhook.Payloads, message.ID, response)
+	if err != nil {
+		// the send is settled all the same, only without the body
+		log.Warnf("Failed to store the webhook response body: %v", err)
+		responseJSON, _ = storeResponse(pctx, nil, s.cfg.Webhook.Payloads, message.ID, response)
+	}
+	delivery := queue.Delivery{
+		SentAt:    time.Now().UTC(),
+		MessageID: response.MessageID,
+		Response:  responseJSON,
+	}
+	switch {
+	case !response.Usable():
+		// no delivery report can be matched without a message ID, so it is not tracked as cleanly sent
+		delivery.Status = db.MessageStatusAcceptedWithoutID
+		log.WithField("status_code", response.StatusCode).Warnf("Webhook accepted message without a usable response: %s", response.Problem)
+	case s.cfg.DeliveryReports.Enabled:
+		delivery.Status = db.MessageStatusAccepted
+	}
+	sentStatus := delivery.Status
+	if sentStatus == "" || sentStatus == db.MessageStatusAccepted {
+		sentStatus = db.MessageStatusSent
+	}
+	telemetry.ObserveSend(string(sentStatus), messageLabels(message), time.Since(started))
+
+	if err := s.queue.Ack(pctx, message, delivery); err != nil {
+		settleFailed(log, "Failed to update message status", err)
+	}
+	// the send went out, a resumed campaign must not send to the recipient again
+	if s.campaigns.Load().tracks(message.Campaign) {
+		if err := db.AdvanceCampaignCursor(pctx, s.db, message.Campaign, message.To, message.ID); err != nil {
+			log.Warnf("Failed to advance the progress cursor of the campaign: %v", err)
+		}
+	}
+
+	log.WithField("webhook_message_id", delivery.MessageID).Debug("Message sent successfully")
+}
+
+// persistContext returns the context of a status write of a claimed message. It is detached from ctx, so a batch
+// cancelled by a stop or shutdown still records the outcome of its messages, and bounded by
+// messaging.persist_timeout, so a hung database does not keep the batch and the handoff waiting.
+func (s *Scheduler) persistContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := s.cfg.Messaging.PersistTimeout
+	if timeout <= 0 {
+		timeout = config.DefaultPersistTimeout
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+// trackInFlight adds the claimed message of id to the in-flight sends and returns the context of its send,
+// cancelled by CancelSend until the webhook call is issued
+func (s *Scheduler) trackInFlight(ctx context.Context, id int64) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	s.inFlight[id] = &inFlightSend{cancel: cancel, done: make(chan struct{})}
+	return ctx
+}
+
+// untrackInFlight removes the settled message of id from the in-flight sends
+func (s *Scheduler) untrackInFlight(id int64) {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	if send, ok := s.inFlight[id]; ok {
+		send.cancel(nil)
+		close(send.done)
+		delete(s.inFlight, id)
+	}
+}
+
+// issueSend marks the webhook call of the message of id as issued, from then on it can no longer be cancelled.
+// It reports false when the send was cancelled before.
+func (s *Scheduler) issueSend(id int64) bool {
+	s.inFlightMu.Lock()
+	defer s.inFlightMu.Unlock()
+	send, ok := s.inFlight[id]
+	if !ok {
+		return true
+	}
+	if send.cancelled {
+		return false
+	}
+	send.issued = true
+	return true
+}
+
+// requeue puts a claimed message back to pending, or marks it cancelled when an operator cancelled its send
+func (s *Scheduler) requeue(ctx context.Context, message *db.Message) error {
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if errors.Is(context.Cause(ctx), ErrSendCancelled) {
+		config.LogFrom(ctx).Info("Send cancelled before the webhook call, message cancelled")
+		return s.queue.Cancel(pctx, message)
+	}
+	return s.queue.Requeue(pctx, message)
+}
+
+// skipped records why message was skipped with messaging.skip_events, a failure to record it is only logged
+func (s *Scheduler) skipped(ctx context.Context, message *db.Message, reason, detail string) {
+	if !s.cfg.Messaging.SkipEvents {
+		return
+	}
+	// a cancelled send ends the waits and checks like a stop, the message was cancelled instead of requeued
+	if errors.Is(context.Cause(ctx), ErrSendCancelled) {
+		reason, detail = db.SkipCancelled, ""
+	}
+	event := &db.MessageEvent{MessageID: message.ID, Reason: reason, Detail: detail}
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if err := db.CreateMessageEvent(pctx, s.db, event); err != nil {
+		config.LogFrom(ctx).WithField("reason", reason).Warnf("Failed to record the skip of the message: %v", err)
+	}
+}
+
+// settleFailed logs a failed status update of a claimed message. A message found no longer sending was processed
+// twice or changed by hand meanwhile, it kept its status and the conflict is counted.
+func settleFailed(log *logrus.Entry, msg string, err error) {
+	var stale *db.StaleStatusError
+	if errors.As(err, &stale) {
+		telemetry.RecordStaleStatusUpdate(string(stale.Status), string(stale.Current))
+		log.WithField("current_status", stale.Current).Warnf("%s, it is no longer sending and was processed twice or changed meanwhile: %v", msg, err)
+		return
+	}
+	log.Errorf("%s: %v", msg, err)
+}
+
+// expireLate expires message when it was claimed after its expires_at and reports whether it must not be sent, a
+// message deferred or requeued past its expiry is never sent late
+func (s *Scheduler) expireLate(ctx context.Context, message *db.Message) bool {
+	if message.ExpiresAt == nil || time.Now().Before(*message.ExpiresAt) {
+		return false
+	}
+
+	log := config.LogFrom(ctx)
+	log.WithField("expires_at", message.ExpiresAt).Info("Expiring message, it was claimed after its expiry")
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if err := s.queue.Expire(pctx, message); err != nil {
+		settleFailed(log, "Failed to update message to expired status", err)
+	}
+	s.skipped(ctx, message, db.SkipExpired, "expired at "+message.ExpiresAt.UTC().Format(time.RFC3339))
+	return true
+}
+
+// blockSuppressed blocks message when its recipient was suppressed after it was enqueued and reports
+// whether it must not be sent. When the check fails the message is requeued, it is never sent to a
+// number that may be suppressed.
+func (s *Scheduler) blockSuppressed(ctx context.Context, message *db.Message) bool {
+	log := config.LogFrom(ctx)
+
+	suppressed, err := db.IsSuppressed(ctx, s.db, message.To)
+	if err != nil {
+		log.Errorf("Failed to check recipient suppression: %v", err)
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
+		}
+		s.skipped(ctx, message, db.SkipSuppressionCheck, err.Error())
+		return true
+	}
+	if !suppressed {
+		return false
+	}
+
+	log.Info("Blocking message, the recipient is suppressed")
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if err := s.queue.Block(pctx, message); err != nil {
+		settleFailed(log, "Failed to update message to blocked status", err)
+	}
+	s.skipped(ctx, message, db.SkipSuppressed, "")
+	return true
+}
+
+// deferPaused defers message while its recipient is paused and reports whether it must not be sent now. The first
+// paused message of a pause in a batch defers the other pending messages of the pause along with it, so they do
+// not take the claims of other traffic until the pause is checked again.
+func (s *Scheduler) deferPaused(ctx context.Context, message *db.Message) bool {
+	pauses := s.pauses.Load()
+	pause := pauses.of(message.To)
+	if pause == nil {
+		return false
+	}
+
+	until := pauseDeferral(pause, s.cfg.Messaging.PauseRecheck)
+	log := config.LogFrom(ctx).WithField("pause", pause.Prefix)
+	log.WithField("until", until).Debug("Recipient is paused, deferring message")
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if err := s.queue.Defer(pctx, message, until); err != nil {
+		settleFailed(log, "Failed to defer message", err)
+	}
+	if pauses.first(pause.Prefix) {
+		if deferred, err := db.DeferPausedMessages(pctx, s.db, pause.Prefix, until); err != nil {
+			log.Warnf("Failed to defer the pending messages of the pause: %v", err)
+		} else if deferred > 0 {
+			log.Infof("Deferred %d pending messages of the pause", deferred)
+		}
+	}
+	s.skipped(ctx, message, db.SkipPaused, "paused by "+pause.Prefix+" until "+until.UTC().Format(time.RFC3339))
+	return true
+}
+
+// holdCampaign defers message while its campaign waits for approval or launch or is paused and reports whether it
+// must not be sent now. With campaigns.require_approval the first message of a campaign that is not registered
+// registers it as a draft. The first held message of a campaign in a batch defers the other pending messages of
+// the campaign.
+func (s *Scheduler) holdCampaign(ctx context.Context, message *db.Message) bool {
+	campaigns := s.campaigns.Load()
+	status, register := campaigns.holds(message.Campaign)
+	if status == "" {
+		return false
+	}
+
+	log := config.LogFrom(ctx)
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if register {
+		campaign := &db.Campaign{Name: message.Campaign, Status: db.CampaignStatusDraft, CreatedBy: campaignRegistrar}
+		if created, err := db.CreateCampaign(pctx, s.db, campaign); err != nil {
+			log.Warnf("Failed to register the campaign for approval: %v", err)
+		} else if created {
+			telemetry.RecordCampaignStep(campaignCreated)
+			log.Info("Campaign registered for approval by its first message, its messages are held until it is launched")
+		}
+	}
+
+	until := time.Now().Add(s.cfg.Campaigns.Recheck)
+	log.WithField("until", until).WithField("campaign_status", status).Debug("Campaign is held, deferring message")
+	if err := s.queue.Defer(pctx, message, until); err != nil {
+		settleFailed(log, "Failed to defer message", err)
+	}
+	if campaigns.first(message.Campaign) {
+		if deferred, err := db.DeferCampaignMessages(pctx, s.db, message.Campaign, until); err != nil {
+			log.Warnf("Failed to defer the pending messages of the campaign: %v", err)
+		} else if deferred > 0 {
+			log.Infof("Deferred %d pending messages of the held campaign", deferred)
+		}
+	}
+	s.skipped(ctx, message, db.SkipHeld, "campaign "+message.Campaign+" is "+string(status)+", deferred until "+until.UTC().Format(time.RFC3339))
+	return true
+}
+
+// skipCampaignSent settles message as accepted without sending it when the progress cursor of its launched campaign
+// shows it was sent already, e.g. before its send was reaped, and reports whether it must not be sent. A campaign
+// paused, changed and resumed so continues from its cursor. Replays are new messages and are sent. When the check
+// fails the message is requeued, it may have been sent.
+func (s *Scheduler) skipCampaignSent(ctx context.Context, message *db.Message) bool {
+	if !s.campaigns.Load().tracks(message.Campaign) {
+		return false
+	}
+	log := config.LogFrom(ctx)
+
+	sentAt, err := db.CampaignSentAt(ctx, s.db, message.Campaign, message.ID)
+	if err != nil {
+		log.Errorf("Failed to check the progress cursor of the campaign: %v", err)
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
+		}
+		s.skipped(ctx, message, db.SkipCursorCheck, err.Error())
+		return true
+	}
+	if sentAt == nil {
+		return false
+	}
+
+	log.WithField("sent_at", sentAt).Info("Not sending message again, the campaign sent it already")
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	// the provider accepted the message, its message ID is not known anymore
+	if err := s.queue.Ack(pctx, message, queue.Delivery{Status: db.MessageStatusAcceptedWithoutID, SentAt: *sentAt}); err != nil {
+		settleFailed(log, "Failed to update message status", err)
+	}
+	s.skipped(ctx, message, db.SkipCampaignSent, fmt.Sprintf("campaign %s sent the message at %s", message.Campaign, sentAt.UTC().Format(time.RFC3339)))
+	return true
+}
+
+// throttle waits for the send slot of the throttle profile of message's campaign or tenant and reports whether it
+// may be sent now. A message whose slot starts later than the next tick is deferred to it instead, so the
+// claims in between go to other traffic rather than waiting behind a throttled campaign.
+func (s *Scheduler) throttle(ctx context.Context, message *db.Message) bool {
+	at, ok := s.throttles.Reserve(message.Tenant, message.Campaign, s.cfg.Messaging.Interval)
+	log := config.LogFrom(ctx)
+	if !ok {
+		log.WithField("until", at).Debug("Message is throttled, deferring it")
+		pctx, cancel := s.persistContext(ctx)
+		defer cancel()
+		if err := s.queue.Defer(pctx, message, at); err != nil {
+			settleFailed(log, "Failed to defer message", err)
+		}
+		s.skipped(ctx, message, db.SkipThrottled, "deferred until "+at.UTC().Format(time.RFC3339))
+		return false
+	}
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		// the batch was cancelled while waiting for the slot
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
+		}
+		s.skipped(ctx, message, db.SkipStopped, "while waiting for the throttle slot")
+		return false
+	}
+}
+
+// route picks the route of message, stores its provider, sender ID, unit price and region on the message and waits
+// for the rate limit of the route. It reports false when the message must not be sent now, it is requeued then.
+func (s *Scheduler) route(ctx context.Context, message *db.Message) (*routing.Route, bool) {
+	route := s.router.Route(message.To)
+	log := config.LogFrom(ctx).WithField("provider", route.Provider)
+
+	reason := db.SkipRouteFailed
+	var err error
+	// the override of the message's campaign or tenant replaces webhook.url, named providers are kept
+	if target := s.overrides.Load().of(message); target != nil && route.Provider == config.WebhookProvider {
+		overridden := *route
+		overridden.URL, overridden.Credentials, overridden.Override, err = target.url, target.credentials, target.override, target.err
+		if err != nil {
+			err = fmt.Errorf("webhook override %s: %w", target.override, err)
+		}
+		route = &overridden
+		log = log.WithField("webhook_override", target.override)
+	}
+	// the sender of the message replaces the sender ID of its route
+	if message.From != "" {
+		withSender := *route
+		withSender.SenderID = message.From
+		route = &withSender
+	}
+	if err == nil {
+		err = db.SetMessageRoute(ctx, s.db, message.ID, route.Provider, route.SenderID, s.cfg.Region.Name, route.UnitPrice)
+	}
+	if err == nil {
+		message.Provider, message.SenderID, message.UnitPrice = route.Provider, route.SenderID, route.UnitPrice
+		message.Region = s.cfg.Region.Name
+		telemetry.RecordRoutingDecision(route.Prefix, route.Provider)
+		reason = db.SkipRateLimited
+		err = route.Wait(ctx)
+	}
+	if err != nil {
+		log.Errorf("Failed to route message, requeueing it: %v", err)
+		// the batch may have been cancelled while waiting for the rate limit
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
+		}
+		s.skipped(ctx, message, reason, route.Provider+": "+err.Error())
+		return nil, false
+	}
+	return route, true
+}
+
+// render returns the content message is sent with after the transform pipeline of its campaign or tenant and
+// stores it with the short links it created. A message rendered by an earlier claim is sent with the same
+// content, so retries match the first attempt and its short links keep working. It reports false when the
+// message must not be sent now, it is requeued then.
+func (s *Scheduler) render(ctx context.Context, message *db.Message) (string, bool) {
+	if message.RenderedContent != "" {
+		return message.RenderedContent, true
+	}
+	pipeline := s.transforms.Of(message.Tenant, message.Campaign)
+	if pipeline == nil {
+		return message.Content, true
+	}
+
+	log := config.LogFrom(ctx).WithField("transform_pipeline", pipeline.Name)
+	content, created, err := pipeline.Render(message.Content)
+	if err == nil && content == message.Content {
+		return content, true
+	}
+	if err == nil {
+		err = db.CreateLinks(ctx, s.db, created)
+	}
+	if err == nil {
+		codes := make([]string, len(created))
+		for i, link := range created {
+			codes[i] = link.Code
+		}
+		err = db.AttachLinks(ctx, s.db, message.ID, codes)
+	}
+	if err == nil {
+		err = db.SetRenderedContent(ctx, s.db, message.ID, content)
+	}
 	if err != nil {
-		config.Log().Errorf("Failed to send message %d: %v", message.ID, err)
-		if updateErr := db.UpdateMessageStatus(ctx, s.db, message.ID, db.MessageStatusFailed, nil, nil, nil); updateErr != nil {
-			config.Log().Errorf("Failed to update message %d to failed status: %v", message.ID, updateErr)
+		log.Errorf("Failed to render message content, requeueing it: %v", err)
+		if err := s.requeue(ctx, message); err != nil {
+			settleFailed(log, "Failed to requeue message", err)
 		}
+		s.skipped(ctx, message, db.SkipTransformFailed, pipeline.Name+": "+err.Error())
+		return "", false
+	}
+
+	message.RenderedContent = content
+	message.Encoding, message.Segments, _ = sms.Encode(content)
+	telemetry.RecordTransform(pipeline.Name)
+	return content, true
+}
+
+// ReportQueueMetrics refreshes the queue gauges every messaging.metrics_interval until ctx is cancelled.
+// It runs independently of Start and Stop so a stopped scheduler still reports a growing backlog.
+func (s *Scheduler) ReportQueueMetrics(ctx context.Context) {
+	if s.cfg.Messaging.MetricsInterval <= 0 {
 		return
 	}
 
-	responseJSON, _ := json.Marshal(response)
-	responseStr := string(responseJSON)
-	messageID := response.MessageID
-	now := time.Now().UTC()
+	ticker := time.NewTicker(s.cfg.Messaging.MetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RefreshQueueMetrics(ctx); err != nil && ctx.Err() == nil {
+			config.Log().Errorf("Failed to refresh queue metrics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshQueueMetrics updates the pending count and oldest pending age gauges
+func (s *Scheduler) RefreshQueueMetrics(ctx context.Context) error {
+	pending, err := db.CountPendingMessages(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	oldest, err := db.GetOldestPendingMessage(ctx, s.db)
+	if err != nil {
+		return err
+	}
+
+	var age time.Duration
+	if oldest != nil {
+		age = time.Since(oldest.CreatedAt)
+	}
+	telemetry.SetQueueMetrics(pending, age)
+	return nil
+}
+
+func messageLabels(message *db.Message) telemetry.MessageLabels {
+	return telemetry.MessageLabels{Tenant: message.Tenant, Campaign: message.Campaign}
+}
+
+// send delivers the payload to the provider of route, tracking it in the in-flight sends gauge. onAttempt observes
+// every webhook request of the send when it is not nil.
+func (s *Scheduler) send(ctx context.Context, route *routing.Route, payload webhook.MessagePayload, onAttempt func(webhook.Attempt)) (*webhook.Response, error) {
+	telemetry.AddInFlightSends(1)
+	defer telemetry.AddInFlightSends(-1)
+
+	if route.Provider == config.SandboxProvider {
+		return s.sandboxClient.WithAttempts(onAttempt).SendMessageWithRetry(ctx, payload)
+	}
+	return s.webhookClient.WithURL(route.URL).WithCredentials(route.Credentials).WithAttempts(onAttempt).SendMessageWithRetry(ctx, payload)
+}
+
+// classifyFailure attaches the failure of its send to message. Transient failures are requeued by the
+// failure_requeue maintenance job, the others are moved to the dead-letter queue right away.
+func classifyFailure(message *db.Message, class webhook.FailureClass, err error) {
+	message.FailureClass, message.FailureError = string(class), err.Error()
+	if !class.Transient() {
+		now := time.Now()
+		message.DeadLetteredAt = &now
+	}
+	telemetry.RecordSendFailure(cmp.Or(string(class), "unclassified"))
+}
 
-	if err := db.UpdateMessageStatus(ctx, s.db, message.ID, db.MessageStatusSent, &now, &messageID, &responseStr); err != nil {
-		config.Log().Errorf("Failed to update message %d status: %v", message.ID, err)
+// sendAttempt returns the record of a webhook request of the message of messageID sent to provider
+func sendAttempt(messageID int64, provider string, attempt webhook.Attempt) *db.SendAttempt {
+	record := &db.SendAttempt{
+		MessageID:  messageID,
+		Provider:   provider,
+		StatusCode: attempt.StatusCode,
+		StartedAt:  attempt.StartedAt,
+		FinishedAt: attempt.FinishedAt,
+	}
+	if attempt.Err != nil {
+		record.Error = attempt.Err.Error()
 	}
+	return record
+}
+
+// recoverPanic keeps a panic in a batch goroutine from crashing the process. Must be deferred directly.
+func (s *Scheduler) recoverPanic(ctx context.Context) {
+	if recovered := recover(); recovered != nil {
+		s.reportPanic(ctx, recovered)
+	}
+}
+
+// recoverMessagePanic recovers a panic while sending message and marks it failed,
+// otherwise it would stay in sending forever. Must be deferred directly.
+func (s *Scheduler) recoverMessagePanic(ctx context.Context, message *db.Message) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	s.reportPanic(ctx, recovered)
+
+	// the panic may have happened after the send deadline, the status update must still go through
+	classifyFailure(message, "", fmt.Errorf("panic: %v", recovered))
+	pctx, cancel := s.persistContext(ctx)
+	defer cancel()
+	if err := s.queue.Fail(pctx, message); err != nil {
+		settleFailed(config.LogFrom(ctx), "Failed to update message to failed status after panic", err)
+		return
+	}
+	telemetry.ObserveSend(string(db.MessageStatusFailed), messageLabels(message), 0)
+}
 
-	config.Log().Debugf("Message %d sent successfully to %s", message.ID, message.To)
+// reportPanic logs the stack trace, counts the panic and reports it to Sentry
+func (s *Scheduler) reportPanic(ctx context.Context, recovered any) {
+	config.LogFrom(ctx).WithField("stack", string(debug.Stack())).Errorf("Scheduler panic: %v", recovered)
+	telemetry.RecordPanic("scheduler")
+	telemetry.CapturePanic(ctx, recovered, map[string]string{"component": "scheduler"})
 }
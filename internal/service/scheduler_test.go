@@ -2,11 +2,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestScheduler_StartStop(t *testing.T) {
@@ -36,6 +51,7 @@ func TestScheduler_StartStop(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, "error", response.Status) // Indicates already running
+		assert.Equal(t, dto.ControlAlreadyRunning, response.Code)
 		assert.Contains(t, response.Message, "already running")
 		assert.True(t, service.IsRunning())
 	})
@@ -55,11 +71,69 @@ func TestScheduler_StartStop(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, "error", response.Status) // Indicates not running
+		assert.Equal(t, dto.ControlNotRunning, response.Code)
 		assert.Contains(t, response.Message, "not running")
 		assert.False(t, service.IsRunning())
 	})
 }
 
+func TestScheduler_ClusterControl(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{
+			Enabled:         true,
+			Interval:        time.Hour,
+			BatchSize:       1,
+			ControlInterval: 10 * time.Millisecond,
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := NewScheduler(testDB, cfg)
+	second := NewScheduler(testDB, cfg)
+	require.NoError(t, first.Run(ctx))
+	require.NoError(t, second.Run(ctx))
+	assert.True(t, first.IsRunning(), "without a control record messaging.enabled starts messaging")
+	assert.True(t, second.IsRunning())
+
+	response, err := first.Stop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "success", response.Status)
+	assert.Contains(t, response.Message, "across the cluster")
+	assert.False(t, first.IsRunning())
+	assert.Eventually(t, func() bool { return !second.IsRunning() }, time.Second, 5*time.Millisecond,
+		"stopping one instance stops the others")
+	assert.False(t, *second.GetStatus().ClusterEnabled)
+
+	response, err = second.Stop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "error", response.Status, "a second stop changes nothing")
+
+	// a restarting instance stays stopped
+	third := NewScheduler(testDB, cfg)
+	require.NoError(t, third.Run(ctx))
+	assert.False(t, third.IsRunning())
+
+	_, err = second.Start(ctx)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return first.IsRunning() && third.IsRunning() }, time.Second, 5*time.Millisecond)
+
+	// a shutdown leaves the others running
+	require.NoError(t, first.Handoff(ctx))
+	assert.False(t, first.IsRunning())
+	control, err := db.GetControl(ctx, testDB, db.MessagingControlName)
+	require.NoError(t, err)
+	assert.True(t, control.Enabled)
+	time.Sleep(3 * cfg.Messaging.ControlInterval)
+	assert.False(t, first.IsRunning(), "a handed off scheduler no longer follows the control")
+
+	require.NoError(t, second.Handoff(ctx))
+	require.NoError(t, third.Handoff(ctx))
+}
+
 func TestScheduler_GetStatus(t *testing.T) {
 	cfg := &config.Cfg{
 		Messaging: config.Messaging{
@@ -96,6 +170,29 @@ func TestScheduler_GetStatus(t *testing.T) {
 		// Cleanup
 		_, _ = service.Stop(context.Background())
 	})
+
+	t.Run("paused while read-only", func(t *testing.T) {
+		mode := NewReadOnlyMode(true)
+		service.SetReadOnlyMode(mode)
+		defer service.SetReadOnlyMode(nil)
+
+		assert.True(t, service.GetStatus().Paused)
+		mode.Set(false, "ops")
+		assert.False(t, service.GetStatus().Paused, "claiming resumes once the mode is disabled")
+	})
+
+	t.Run("paused while the region is passive", func(t *testing.T) {
+		region := NewRegionFailover(config.Region{Name: "eu-west", Role: config.RegionPassive})
+		service.SetRegionFailover(region)
+		defer service.SetRegionFailover(nil)
+
+		response := service.GetStatus()
+		assert.True(t, response.Paused)
+		assert.Equal(t, config.RegionPassive, response.RegionRole)
+		assert.True(t, region.SetRole(config.RegionActive, "ops"))
+		assert.False(t, region.SetRole(config.RegionActive, "ops"), "the region is active already")
+		assert.False(t, service.GetStatus().Paused, "claiming resumes once the region is promoted")
+	})
 }
 
 func TestScheduler_IsRunning_ThreadSafety(t *testing.T) {
@@ -162,3 +259,961 @@ func TestScheduler_ContextCancellation(t *testing.T) {
 	// Cleanup
 	_, _ = service.Stop(context.Background())
 }
+
+func TestScheduler_Wait(t *testing.T) {
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{
+			Interval: time.Minute,
+			Enabled:  true,
+		},
+	}
+
+	service := NewScheduler(nil, cfg)
+
+	_, err := service.Start(context.Background())
+	assert.NoError(t, err)
+
+	_, err = service.Stop(context.Background())
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		service.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Stop")
+	}
+}
+
+func TestScheduler_RefreshQueueMetrics(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	scheduler := NewScheduler(testDB, &config.Cfg{})
+
+	t.Run("empty queue", func(t *testing.T) {
+		require.NoError(t, scheduler.RefreshQueueMetrics(context.Background()))
+
+		assert.Equal(t, 0.0, testutil.ToFloat64(telemetry.PendingMessages))
+		assert.Equal(t, 0.0, testutil.ToFloat64(telemetry.OldestPendingAge))
+	})
+
+	t.Run("pending backlog", func(t *testing.T) {
+		now := time.Now()
+		messages := []*db.Message{
+			{To: "+905551111111", Content: "Old pending", Status: db.MessageStatusPending, CreatedAt: now.Add(-time.Hour)},
+			{To: "+905552222222", Content: "New pending", Status: db.MessageStatusPending, CreatedAt: now},
+			{To: "+905553333333", Content: "Sent", Status: db.MessageStatusSent, CreatedAt: now.Add(-2 * time.Hour)},
+		}
+		for _, msg := range messages {
+			_, err := testDB.NewInsert().Model(msg).Exec(context.Background())
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, scheduler.RefreshQueueMetrics(context.Background()))
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(telemetry.PendingMessages))
+		assert.InDelta(t, time.Hour.Seconds(), testutil.ToFloat64(telemetry.OldestPendingAge), 60)
+	})
+}
+
+func TestScheduler_ProcessMessage_Panic(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	message := &db.Message{To: "+905551111111", Content: "Panics", Status: db.MessageStatusSending}
+	_, err := testDB.NewInsert().Model(message).Exec(context.Background())
+	require.NoError(t, err)
+
+	scheduler := NewScheduler(testDB, &config.Cfg{})
+	// a nil client panics on the first send
+	scheduler.webhookClient = nil
+
+	before := testutil.ToFloat64(telemetry.Panics.WithLabelValues("scheduler"))
+
+	assert.NotPanics(t, func() {
+		scheduler.processMessage(context.Background(), message)
+	})
+
+	stored := new(db.Message)
+	require.NoError(t, testDB.NewSelect().Model(stored).Where("id = ?", message.ID).Scan(context.Background()))
+	assert.Equal(t, db.MessageStatusFailed, stored.Status)
+	assert.Equal(t, before+1, testutil.ToFloat64(telemetry.Panics.WithLabelValues("scheduler")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(telemetry.InFlightSends))
+}
+
+// fakeQueue hands out its pending messages in order and records what happened to them
+// slowQueue blocks every claim until release is closed, keeping batches running
+type slowQueue struct {
+	fakeQueue
+	release chan struct{}
+	claims  atomic.Int32
+}
+
+func (q *slowQueue) Claim(ctx context.Context) (*db.Message, error) {
+	q.claims.Add(1)
+	select {
+	case <-q.release:
+	case <-ctx.Done():
+	}
+	return nil, nil
+}
+
+func TestScheduler_OverlapPolicy(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	for _, tc := range []struct {
+		policy string
+		// claims are the batches started by three overlapping ticks, after is the number once they finished
+		claims, after int
+		action        string
+	}{
+		{config.OverlapSkip, 1, 1, overlapSkipped},
+		{config.OverlapQueue, 1, 2, overlapQueued},
+		{config.OverlapConcurrent, 2, 2, overlapConcurrent},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			cfg := &config.Cfg{Messaging: config.Messaging{
+				BatchSize:            1,
+				OverlapPolicy:        tc.policy,
+				MaxConcurrentBatches: 2,
+			}}
+			q := &slowQueue{release: make(chan struct{})}
+			scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+			scheduler.running = true
+			overlaps := testutil.ToFloat64(telemetry.BatchOverlaps.WithLabelValues(tc.action))
+
+			for range 3 {
+				scheduler.tick(context.Background())
+			}
+			assert.Eventually(t, func() bool { return q.claims.Load() == int32(tc.claims) }, time.Second, 5*time.Millisecond)
+			assert.Greater(t, testutil.ToFloat64(telemetry.BatchOverlaps.WithLabelValues(tc.action)), overlaps)
+
+			close(q.release)
+			scheduler.Wait()
+			assert.Equal(t, int32(tc.after), q.claims.Load())
+			assert.Zero(t, scheduler.batches)
+		})
+	}
+}
+
+type fakeQueue struct {
+	mu        sync.Mutex
+	pending   []*db.Message
+	acked     map[int64]queue.Delivery
+	failed    []int64
+	blocked   []int64
+	deferred  []int64
+	cancelled []int64
+	expired   []int64
+}
+
+func (f *fakeQueue) Enqueue(_ context.Context, messages ...*db.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = append(f.pending, messages...)
+	return nil
+}
+
+func (f *fakeQueue) Claim(_ context.Context) (*db.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.pending) == 0 {
+		return nil, nil
+	}
+	message := f.pending[0]
+	f.pending = f.pending[1:]
+	return message, nil
+}
+
+func (f *fakeQueue) Ack(_ context.Context, message *db.Message, delivery queue.Delivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.acked[message.ID] = delivery
+	return nil
+}
+
+func (f *fakeQueue) Fail(_ context.Context, message *db.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = append(f.failed, message.ID)
+	return nil
+}
+
+func (f *fakeQueue) Requeue(ctx context.Context, message *db.Message) error {
+	return f.Enqueue(ctx, message)
+}
+
+func (f *fakeQueue) Defer(_ context.Context, message *db.Message, until time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	message.ScheduledAt = &until
+	f.deferred = append(f.deferred, message.ID)
+	return nil
+}
+
+func (f *fakeQueue) Block(_ context.Context, message *db.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocked = append(f.blocked, message.ID)
+	return nil
+}
+
+func (f *fakeQueue) Cancel(_ context.Context, message *db.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, message.ID)
+	return nil
+}
+
+func (f *fakeQueue) Expire(_ context.Context, message *db.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expired = append(f.expired, message.ID)
+	return nil
+}
+
+func TestScheduler_ProcessBatch_Queue(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	// the third recipient opted out after the message was enqueued
+	_, err := db.AddSuppression(context.Background(), testDB, &db.Suppression{Phone: "+905553333333", Source: db.SuppressionSourceInbound})
+	require.NoError(t, err)
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 3},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(context.Background(),
+		&db.Message{ID: 1, To: "+905551111111", Content: "First"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Second"},
+		&db.Message{ID: 3, To: "+905553333333", Content: "Third"},
+		&db.Message{ID: 4, To: "+905554444444", Content: "Fourth"},
+	))
+
+	scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+	scheduler.processBatch(context.Background())
+
+	assert.Len(t, q.acked, 2)
+	assert.Empty(t, q.failed)
+	assert.Equal(t, []int64{3}, q.blocked)
+	assert.Len(t, q.pending, 1, "only a batch worth of messages is claimed")
+	for _, delivery := range q.acked {
+		assert.NotEmpty(t, delivery.MessageID)
+		assert.False(t, delivery.SentAt.IsZero())
+	}
+}
+
+func TestScheduler_ProcessBatch_AcceptedWithoutID(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		reports bool
+		status  db.MessageStatus
+	}{
+		{"message ID", `{"message": "Accepted", "messageId": "webhook-1"}`, false, ""},
+		{"message ID with delivery reports", `{"message": "Accepted", "messageId": "webhook-1"}`, true, db.MessageStatusAccepted},
+		{"empty body", ``, false, db.MessageStatusAcceptedWithoutID},
+		{"no message ID", `{"message": "Accepted"}`, true, db.MessageStatusAcceptedWithoutID},
+		{"blank message ID", `{"message": "Accepted", "messageId": "  "}`, false, db.MessageStatusAcceptedWithoutID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			testDB := setupTestDB(t)
+			defer testDB.Close()
+
+			cfg := &config.Cfg{
+				Messaging:       config.Messaging{BatchSize: 1},
+				Webhook:         config.Webhook{URL: server.URL},
+				DeliveryReports: config.DeliveryReports{Enabled: tt.reports},
+			}
+			q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+			require.NoError(t, q.Enqueue(context.Background(), &db.Message{ID: 1, To: "+905551111111", Content: "Hello"}))
+
+			NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+			require.Contains(t, q.acked, int64(1), "a 2xx response is never a failure")
+			assert.Equal(t, tt.status, q.acked[1].Status)
+			assert.Empty(t, q.failed)
+		})
+	}
+}
+
+func TestScheduler_ProcessBatch_RecordsSendAttempts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "attempts-1"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	message := &db.Message{To: "+905551111111", Content: "Hello"}
+	require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{message}))
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 1, MaxRetries: 3, RetryDelay: 10 * time.Millisecond, SendAttempts: true},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	NewScheduler(testDB, cfg).processBatch(ctx)
+
+	service := NewMessageService(testDB)
+	response, err := service.SendAttempts(ctx, strconv.FormatInt(message.ID, 10))
+	require.NoError(t, err)
+	require.Len(t, response.Attempts, 3)
+	for i, attempt := range response.Attempts {
+		assert.Equal(t, i+1, attempt.Attempt)
+		assert.Equal(t, config.WebhookProvider, attempt.Provider)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, response.Attempts[0].StatusCode)
+	assert.Equal(t, "webhook returned status: 503", response.Attempts[0].Error)
+	assert.Equal(t, http.StatusAccepted, response.Attempts[2].StatusCode)
+	assert.Empty(t, response.Attempts[2].Error)
+	require.NotNil(t, response.FirstAttemptAt)
+	assert.GreaterOrEqual(t, response.LastAttemptAt.Sub(*response.FirstAttemptAt), 20*time.Millisecond, "the retries waited for the retry delay")
+
+	t.Run("disabled", func(t *testing.T) {
+		requests = 2
+		other := &db.Message{To: "+905552222222", Content: "Hello"}
+		require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{other}))
+		cfg.Messaging.SendAttempts = false
+		NewScheduler(testDB, cfg).processBatch(ctx)
+
+		response, err := service.SendAttempts(ctx, strconv.FormatInt(other.ID, 10))
+		require.NoError(t, err)
+		assert.Empty(t, response.Attempts)
+	})
+
+	t.Run("purged with the message", func(t *testing.T) {
+		_, err := service.PurgeMessages(ctx, db.MessageFilter{}, PurgeOptions{})
+		require.NoError(t, err)
+		attempts, err := db.GetSendAttempts(ctx, testDB, message.ID)
+		require.NoError(t, err)
+		assert.Empty(t, attempts)
+	})
+}
+
+func TestScheduler_ProcessBatch_StampsRegion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "region-1"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	message := &db.Message{To: "+905551111111", Content: "Hello"}
+	require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{message}))
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 1},
+		Webhook:   config.Webhook{URL: server.URL},
+		Region:    config.Region{Name: "eu-west", Role: config.RegionActive},
+	}
+	NewScheduler(testDB, cfg).processBatch(ctx)
+
+	stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusSent, stored.Status)
+	assert.Equal(t, "eu-west", stored.Region)
+}
+
+func TestScheduler_ProcessBatch_Transforms(t *testing.T) {
+	var contents sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.MessagePayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		contents.Store(payload.To, payload.Content)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "rendered"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "  Spring sale 🌸 at https://shop.example.com/sale ", Campaign: "spring"},
+		{To: "+905552222222", Content: "Your code is 1234", Tenant: "acme"},
+		{To: "+905553333333", Content: "Your order shipped"},
+	}
+	require.NoError(t, db.CreateMessages(ctx, testDB, messages))
+
+	cfg := &config.Cfg{
+		Messaging:    config.Messaging{BatchSize: 3},
+		Webhook:      config.Webhook{URL: server.URL},
+		LinkTracking: config.LinkTracking{BaseURL: "https://sp.example.com/l"},
+		Transforms: config.Transforms{
+			Pipelines: []config.TransformPipeline{
+				{Name: "marketing", Steps: []config.TransformStep{
+					{Type: config.TransformTrim},
+					{Type: config.TransformStripEmoji},
+					{Type: config.TransformShortenLinks},
+					{Type: config.TransformFooter, Text: "Reply STOP to opt out"},
+				}},
+				{Name: "signed", Steps: []config.TransformStep{{Type: config.TransformFooter, Text: "- Acme", Separator: "\n"}}},
+			},
+			Assignments: []config.TransformAssignment{
+				{Campaign: "spring", Pipeline: "marketing"},
+				{Tenant: "acme", Pipeline: "signed"},
+			},
+		},
+	}
+	NewScheduler(testDB, cfg).processBatch(ctx)
+
+	marketing, err := db.GetMessageByID(ctx, testDB, messages[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusSent, marketing.Status)
+	assert.Equal(t, "  Spring sale 🌸 at https://shop.example.com/sale ", marketing.Content, "the original content is kept")
+	assert.Regexp(t, `^Spring sale at https://sp\.example\.com/l/\w{8} Reply STOP to opt out$`, marketing.RenderedContent)
+	assert.Equal(t, sms.EncodingGSM7, marketing.Encoding, "the segments are the ones of the sent content")
+	sent, _ := contents.Load(marketing.To)
+	assert.Equal(t, marketing.RenderedContent, sent)
+	links, err := db.GetMessageLinks(ctx, testDB, marketing.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://shop.example.com/sale", links[0].URL)
+
+	signed, err := db.GetMessageByID(ctx, testDB, messages[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Your code is 1234\n- Acme", signed.RenderedContent)
+
+	unassigned, err := db.GetMessageByID(ctx, testDB, messages[2].ID)
+	require.NoError(t, err)
+	assert.Empty(t, unassigned.RenderedContent, "messages without a pipeline are sent as enqueued")
+	sent, _ = contents.Load(unassigned.To)
+	assert.Equal(t, "Your order shipped", sent)
+}
+
+// persistQueue records the context of the acks of a fakeQueue
+type persistQueue struct {
+	*fakeQueue
+	ackErr      error
+	ackDeadline time.Time
+}
+
+func (q *persistQueue) Ack(ctx context.Context, message *db.Message, delivery queue.Delivery) error {
+	q.mu.Lock()
+	q.ackErr = ctx.Err()
+	q.ackDeadline, _ = ctx.Deadline()
+	q.mu.Unlock()
+	return q.fakeQueue.Ack(ctx, message, delivery)
+}
+
+func TestScheduler_ProcessBatch_PersistsAfterStop(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "late"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	q := &persistQueue{fakeQueue: &fakeQueue{acked: make(map[int64]queue.Delivery)}}
+	require.NoError(t, q.Enqueue(context.Background(), &db.Message{ID: 1, To: "+905551111111", Content: "Hello"}))
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 1, PersistTimeout: time.Minute},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.processBatch(ctx)
+	}()
+	<-received
+	// the batch is cancelled by a shutdown while the webhook call is running
+	cancel()
+	stopped := time.Now()
+	close(release)
+	<-done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	assert.Contains(t, q.acked, int64(1), "the result of a send that went out is written after the stop")
+	assert.NoError(t, q.ackErr, "the status update does not inherit the cancellation of the batch")
+	assert.WithinDuration(t, stopped.Add(time.Minute), q.ackDeadline, 10*time.Second, "the status update is bounded by messaging.persist_timeout")
+}
+
+func TestScheduler_ProcessBatch_PrewarmsConnections(t *testing.T) {
+	var heads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 1},
+		Webhook:   config.Webhook{URL: server.URL, Connections: config.WebhookConnections{Prewarm: 3}},
+		Routing:   config.Routing{Providers: []config.Provider{{Name: config.SandboxProvider}}},
+	}
+	NewScheduler(testDB, cfg).processBatch(context.Background())
+	assert.Equal(t, int32(3), heads.Load(), "the webhook is prewarmed, the in-process sandbox is not")
+}
+
+func TestScheduler_ProcessBatch_ClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		class        webhook.FailureClass
+		requests     int
+		deadLettered bool
+	}{
+		{"temporary", http.StatusServiceUnavailable, webhook.FailureProviderTemporary, 3, false},
+		{"rate limited", http.StatusTooManyRequests, webhook.FailureProviderTemporary, 3, false},
+		{"permanent", http.StatusBadRequest, webhook.FailureProviderPermanent, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			testDB := setupTestDB(t)
+			defer testDB.Close()
+			ctx := context.Background()
+
+			message := &db.Message{To: "+905551111111", Content: "Hello"}
+			require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{message}))
+
+			cfg := &config.Cfg{
+				Messaging: config.Messaging{BatchSize: 1, MaxRetries: 2, RetryDelay: time.Millisecond},
+				Webhook:   config.Webhook{URL: server.URL},
+			}
+			NewScheduler(testDB, cfg).processBatch(ctx)
+			assert.Equal(t, tt.requests, requests, "a permanent failure is not retried")
+
+			stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+			require.NoError(t, err)
+			assert.Equal(t, db.MessageStatusFailed, stored.Status)
+			assert.Equal(t, string(tt.class), stored.FailureClass)
+			assert.Equal(t, fmt.Sprintf("webhook returned status: %d", tt.status), stored.FailureError)
+			assert.Equal(t, tt.deadLettered, stored.DeadLetteredAt != nil)
+		})
+	}
+}
+
+// settledQueue acks like a queue whose messages were already settled by another scheduler
+type settledQueue struct {
+	fakeQueue
+}
+
+func (q *settledQueue) Ack(_ context.Context, message *db.Message, _ queue.Delivery) error {
+	return &db.StaleStatusError{ID: message.ID, Status: db.MessageStatusSent, Current: db.MessageStatusFailed}
+}
+
+func TestScheduler_ProcessBatch_StaleStatus(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 1},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	q := &settledQueue{}
+	require.NoError(t, q.Enqueue(context.Background(), &db.Message{ID: 1, To: "+905551111111", Content: "Twice"}))
+	stale := testutil.ToFloat64(telemetry.StaleStatusUpdates.WithLabelValues("sent", "failed"))
+
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+	assert.Equal(t, stale+1, testutil.ToFloat64(telemetry.StaleStatusUpdates.WithLabelValues("sent", "failed")))
+}
+
+func TestScheduler_ProcessBatch_Throttling(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 3, Interval: 100 * time.Millisecond},
+		Webhook:   config.Webhook{URL: server.URL},
+		Throttling: config.Throttling{
+			Profiles:               []config.ThrottleProfile{{Name: "gentle", RateLimit: 1}},
+			DefaultCampaignProfile: "gentle",
+		},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(context.Background(),
+		&db.Message{ID: 1, To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 3, To: "+905553333333", Content: "Password reset"},
+	))
+
+	scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+	started := time.Now()
+	scheduler.processBatch(context.Background())
+
+	assert.Less(t, time.Since(started), 500*time.Millisecond, "the batch does not wait for the throttled campaign")
+	assert.Len(t, q.acked, 2)
+	assert.Contains(t, q.acked, int64(3), "transactional messages are not throttled")
+	// the newsletters are sent concurrently, whichever takes the slot first is sent
+	require.Len(t, q.deferred, 1, "the next newsletter slot is a second away")
+	assert.Contains(t, []int64{1, 2}, q.deferred[0])
+	assert.NotContains(t, q.acked, q.deferred[0])
+}
+
+func TestScheduler_ProcessBatch_SkipEvents(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	_, err := db.AddSuppression(context.Background(), testDB, &db.Suppression{Phone: "+905553333333", Source: db.SuppressionSourceInbound})
+	require.NoError(t, err)
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 3, Interval: 100 * time.Millisecond, SkipEvents: true},
+		Webhook:   config.Webhook{URL: server.URL},
+		Throttling: config.Throttling{
+			Profiles:               []config.ThrottleProfile{{Name: "gentle", RateLimit: 1}},
+			DefaultCampaignProfile: "gentle",
+		},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(context.Background(),
+		&db.Message{ID: 1, To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"},
+		&db.Message{ID: 3, To: "+905553333333", Content: "Password reset"},
+	))
+
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+	// the newsletters are sent concurrently, whichever takes the throttle slot first is sent
+	require.Len(t, q.deferred, 1)
+	deferred := q.deferred[0]
+	require.Contains(t, []int64{1, 2}, deferred)
+	sent := 3 - deferred
+
+	events, err := db.GetMessageEvents(context.Background(), testDB, sent)
+	require.NoError(t, err)
+	assert.Empty(t, events, "sent messages have no skips")
+
+	events, err = db.GetMessageEvents(context.Background(), testDB, deferred)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipThrottled, events[0].Reason)
+	assert.Contains(t, events[0].Detail, "deferred until")
+
+	events, err = db.GetMessageEvents(context.Background(), testDB, 3)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipSuppressed, events[0].Reason)
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg.Messaging.SkipEvents = false
+		q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+		require.NoError(t, q.Enqueue(context.Background(), &db.Message{ID: 4, To: "+905553333333", Content: "Password reset"}))
+
+		NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+		assert.Equal(t, []int64{4}, q.blocked)
+		events, err := db.GetMessageEvents(context.Background(), testDB, 4)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestScheduler_ProcessBatch_Expiry(t *testing.T) {
+	server := httptest.NewServer(webhook.NewMockHandler(webhook.MockOptions{}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 2, Interval: 100 * time.Millisecond, SkipEvents: true},
+		Webhook:   config.Webhook{URL: server.URL},
+	}
+	expired, later := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(context.Background(),
+		&db.Message{ID: 1, To: "+905551111111", Content: "Your code is 1234", ExpiresAt: &expired},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Your code is 5678", ExpiresAt: &later},
+	))
+
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+	assert.Equal(t, []int64{1}, q.expired)
+	assert.NotContains(t, q.acked, int64(1), "expired messages are not sent")
+	assert.Contains(t, q.acked, int64(2))
+
+	events, err := db.GetMessageEvents(context.Background(), testDB, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, db.SkipExpired, events[0].Reason)
+}
+
+func TestScheduler_ProcessBatch_Routing(t *testing.T) {
+	received := func(senders *sync.Map) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload webhook.MessagePayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			senders.Store(payload.To, payload.From)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"message": "Accepted", "messageId": "routed"}`))
+		}))
+	}
+	var webhookSenders, providerSenders sync.Map
+	webhookServer, providerServer := received(&webhookSenders), received(&providerSenders)
+	defer webhookServer.Close()
+	defer providerServer.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Turkey", Status: db.MessageStatusSending},
+		{To: "+491511111111", Content: "Germany", Status: db.MessageStatusSending},
+		{To: "+15551111111", Content: "Elsewhere", Status: db.MessageStatusSending},
+		// the sender of a message replaces the one of its route
+		{To: "+905552222222", Content: "Spring sale", From: "SPRINGSALE", Status: db.MessageStatusSending},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	for _, message := range messages {
+		_, err := testDB.NewInsert().Model(message).Exec(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, q.Enqueue(context.Background(), message))
+	}
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 4},
+		Webhook:   config.Webhook{URL: webhookServer.URL},
+		Routing: config.Routing{
+			Providers: []config.Provider{{Name: "provider-b", URL: providerServer.URL}},
+			Routes: []config.Route{
+				{Prefix: "+90", SenderID: "ACME"},
+				{Prefix: "+49", Provider: "provider-b", SenderID: "ACME-DE", UnitPrice: 0.075},
+			},
+		},
+	}
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(context.Background())
+
+	require.Len(t, q.acked, 4)
+	from, _ := webhookSenders.Load("+905551111111")
+	assert.Equal(t, "ACME", from)
+	from, _ = providerSenders.Load("+491511111111")
+	assert.Equal(t, "ACME-DE", from)
+	from, _ = webhookSenders.Load("+15551111111")
+	assert.Equal(t, "", from)
+	from, _ = webhookSenders.Load("+905552222222")
+	assert.Equal(t, "SPRINGSALE", from)
+
+	stored, err := db.GetMessageByID(context.Background(), testDB, messages[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "provider-b", stored.Provider)
+	assert.Equal(t, "ACME-DE", stored.SenderID)
+	assert.Equal(t, 0.075, stored.UnitPrice)
+	stored, err = db.GetMessageByID(context.Background(), testDB, messages[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, config.WebhookProvider, stored.Provider)
+	assert.Empty(t, stored.SenderID)
+	stored, err = db.GetMessageByID(context.Background(), testDB, messages[3].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "SPRINGSALE", stored.From)
+	assert.Equal(t, "SPRINGSALE", stored.SenderID)
+}
+
+type fakeReports chan *dto.DeliveryReportRequest
+
+func (f fakeReports) HandleDeliveryReport(_ context.Context, req *dto.DeliveryReportRequest) (*dto.DeliveryReportResponse, error) {
+	f <- req
+	return &dto.DeliveryReportResponse{}, nil
+}
+
+func TestScheduler_ProcessBatch_Sandbox(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Delivered", Status: db.MessageStatusSending},
+		{To: webhook.SandboxNumberFails, Content: "Fails", Status: db.MessageStatusSending},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	for _, message := range messages {
+		_, err := testDB.NewInsert().Model(message).Exec(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, q.Enqueue(context.Background(), message))
+	}
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 2},
+		Routing: config.Routing{
+			Default: config.Route{Provider: config.SandboxProvider},
+			Sandbox: config.Sandbox{ReportDelay: time.Millisecond},
+		},
+	}
+	reports := make(fakeReports, 1)
+	scheduler := NewSchedulerWithQueue(testDB, q, cfg)
+	scheduler.SetDeliveryReports(reports)
+	scheduler.processBatch(context.Background())
+
+	require.Len(t, q.acked, 1)
+	delivery := q.acked[messages[0].ID]
+	assert.Contains(t, delivery.MessageID, "sandbox-")
+	assert.Equal(t, []int64{messages[1].ID}, q.failed)
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, delivery.MessageID, report.MessageID)
+		assert.Equal(t, "delivered", report.Status)
+	case <-time.After(time.Second):
+		t.Fatal("no delivery report")
+	}
+}
+
+func TestScheduler_Handoff(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{
+			Interval:    time.Hour,
+			BatchSize:   1,
+			Enabled:     true,
+			LeaderLease: time.Minute,
+		},
+	}
+	leader := NewSchedulerWithQueue(testDB, &fakeQueue{}, cfg)
+	standby := NewSchedulerWithQueue(testDB, &fakeQueue{}, cfg)
+
+	assert.True(t, leader.lead(ctx))
+	assert.True(t, leader.lead(ctx), "the leader renews its lease")
+	assert.False(t, standby.lead(ctx))
+	assert.Equal(t, "leader", leader.GetStatus().Role)
+	assert.Equal(t, "standby", standby.GetStatus().Role)
+
+	_, err := leader.Start(ctx)
+	require.NoError(t, err)
+	require.NoError(t, leader.Handoff(ctx))
+	assert.False(t, leader.IsRunning())
+
+	assert.True(t, standby.lead(ctx), "the standby takes over without waiting for the lease to expire")
+	assert.False(t, leader.lead(ctx))
+}
+
+func TestScheduler_Handoff_PartialBatch(t *testing.T) {
+	received, release := make(chan string, 3), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.MessagePayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload.To
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "slow"}`))
+	}))
+	defer server.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	messages := []*db.Message{
+		{To: "+905551111111", Content: "Newsletter", Campaign: "newsletter"},
+		{To: "+905552222222", Content: "Newsletter", Campaign: "newsletter"},
+		{To: "+905553333333", Content: "Newsletter", Campaign: "newsletter"},
+	}
+	require.NoError(t, db.CreateMessages(ctx, testDB, messages))
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 3, Interval: time.Hour, ShutdownReapAfter: time.Minute},
+		Webhook:   config.Webhook{URL: server.URL},
+		Throttling: config.Throttling{
+			Profiles:               []config.ThrottleProfile{{Name: "gentle", RateLimit: 1}},
+			DefaultCampaignProfile: "gentle",
+		},
+	}
+	scheduler := NewScheduler(testDB, cfg)
+	_, err := scheduler.Start(ctx)
+	require.NoError(t, err)
+	scheduler.tick(ctx)
+	// the first message taking the send slot is in flight, the others wait for their slots
+	sending := <-received
+
+	handoffCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, scheduler.Handoff(handoffCtx), context.DeadlineExceeded)
+
+	var inFlight *db.Message
+	for _, message := range messages {
+		stored, err := db.GetMessageByID(ctx, testDB, message.ID)
+		require.NoError(t, err)
+		if message.To != sending {
+			assert.Equal(t, db.MessageStatusPending, stored.Status, "the messages not attempted are requeued right away")
+			assert.Nil(t, stored.ReapAfter)
+			continue
+		}
+		inFlight = message
+		assert.Equal(t, db.MessageStatusSending, stored.Status, "the send in flight is left to the stuck reaper")
+		require.NotNil(t, stored.ReapAfter)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), *stored.ReapAfter, 5*time.Second)
+	}
+
+	// the send in flight still finishes
+	close(release)
+	scheduler.Wait()
+	stored, err := db.GetMessageByID(ctx, testDB, inFlight.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.MessageStatusSent, stored.Status)
+	assert.Len(t, received, 0, "only the message in flight was sent")
+}
+
+func TestScheduler_LeaseExpiry(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	acquired, err := db.AcquireLease(ctx, testDB, db.SchedulerLeaseName, "crashed", -time.Second)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	cfg := &config.Cfg{Messaging: config.Messaging{LeaderLease: time.Minute}}
+	assert.True(t, NewSchedulerWithQueue(testDB, &fakeQueue{}, cfg).lead(ctx), "an expired lease is taken over")
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// DefaultSLAWindow is the window of an SLA report without bounds, ending now
+const DefaultSLAWindow = 30 * 24 * time.Hour
+
+var (
+	ErrProviderNotFound = errors.New("provider not found")
+	ErrInvalidSLAWindow = errors.New("invalid SLA window")
+)
+
+// SLAInterface defines the SLA reports of the providers
+type SLAInterface interface {
+	ProviderSLA(ctx context.Context, provider string, from, to *time.Time) (*dto.ProviderSLAResponse, error)
+}
+
+// SLAService reports how the providers served the attempts sent to them, for the reviews with the gateway
+// vendors
+type SLAService struct {
+	db      *bun.DB
+	routing config.Routing
+}
+
+func NewSLAService(database *bun.DB, routing config.Routing) *SLAService {
+	return &SLAService{
+		db:      database,
+		routing: routing,
+	}
+}
+
+// ProviderSLA returns the success rate, latencies and downtime of provider in [from, to). A nil to is now, a
+// nil from is DefaultSLAWindow before to. Downtime windows are runs of consecutive minutes in which every
+// attempt failed, minutes without attempts end a window.
+func (s *SLAService) ProviderSLA(ctx context.Context, provider string, from, to *time.Time) (*dto.ProviderSLAResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SLAService.ProviderSLA")
+	defer span.End()
+
+	end := time.Now().UTC()
+	if to != nil {
+		end = to.UTC()
+	}
+	start := end.Add(-DefaultSLAWindow)
+	if from != nil {
+		start = from.UTC()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidSLAWindow)
+	}
+
+	sla, err := db.GetProviderSLA(ctx, s.db, provider, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if sla.Attempts == 0 && !s.configured(provider) {
+		known, err := db.ProviderAttempted(ctx, s.db, provider)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, provider)
+		}
+	}
+
+	response := &dto.ProviderSLAResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Provider:     provider,
+		From:         start,
+		To:           end,
+		Attempts:     sla.Attempts,
+		Succeeded:    sla.Succeeded,
+		SuccessRate:  1,
+		AvgLatencyMs: sla.AvgLatencyMs,
+		P50LatencyMs: sla.P50LatencyMs,
+		P95LatencyMs: sla.P95LatencyMs,
+		P99LatencyMs: sla.P99LatencyMs,
+		Downtime:     downtimeWindows(sla.DownMinutes),
+		Availability: 1,
+	}
+	if sla.Attempts > 0 {
+		response.SuccessRate = float64(sla.Succeeded) / float64(sla.Attempts)
+	}
+	for _, window := range response.Downtime {
+		response.DowntimeSeconds += window.DurationSeconds
+	}
+	if response.DowntimeSeconds > 0 {
+		response.Availability = max(0, 1-float64(response.DowntimeSeconds)/end.Sub(start).Seconds())
+	}
+	return response, nil
+}
+
+// configured reports whether provider is the webhook, the sandbox or a provider of routing.providers
+func (s *SLAService) configured(provider string) bool {
+	if provider == config.WebhookProvider || provider == config.SandboxProvider {
+		return true
+	}
+	return slices.ContainsFunc(s.routing.Providers, func(p config.Provider) bool { return p.Name == provider })
+}
+
+// downtimeWindows merges the consecutive down minutes into windows
+func downtimeWindows(minutes []db.DownMinute) []dto.DowntimeWindow {
+	windows := []dto.DowntimeWindow{}
+	for _, minute := range minutes {
+		end := minute.Time.Add(time.Minute)
+		if n := len(windows); n > 0 && windows[n-1].End.Equal(minute.Time) {
+			windows[n-1].End = end
+			windows[n-1].Attempts += minute.Attempts
+		} else {
+			windows = append(windows, dto.DowntimeWindow{Start: minute.Time, End: end, Attempts: minute.Attempts})
+		}
+	}
+	for i := range windows {
+		windows[i].DurationSeconds = int64(windows[i].End.Sub(windows[i].Start).Seconds())
+	}
+	return windows
+}
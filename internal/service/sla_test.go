@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLAService_ProviderSLA(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	start := time.Date(2026, 9, 14, 2, 0, 0, 0, time.UTC)
+	attempt := func(minute, latencyMs int, failed bool) *db.SendAttempt {
+		started := start.Add(time.Duration(minute)*time.Minute + time.Second)
+		a := &db.SendAttempt{MessageID: 1, Provider: "twilio", StatusCode: 200, StartedAt: started,
+			FinishedAt: started.Add(time.Duration(latencyMs) * time.Millisecond)}
+		if failed {
+			a.StatusCode, a.Error = 503, "provider returned status 503"
+		}
+		return a
+	}
+	attempts := []*db.SendAttempt{
+		attempt(0, 100, false),
+		attempt(0, 200, false),
+		// minutes 1 and 2 are down, minute 3 has no attempts, minute 4 is down again
+		attempt(1, 300, true),
+		attempt(2, 400, true),
+		attempt(2, 500, true),
+		attempt(4, 600, true),
+		attempt(5, 700, true),
+		attempt(5, 800, false),
+		attempt(6, 900, false),
+		attempt(7, 1000, false),
+		{MessageID: 2, Provider: "vonage", StartedAt: start, FinishedAt: start.Add(time.Second)},
+	}
+	require.NoError(t, db.CreateSendAttempts(ctx, testDB, attempts))
+
+	service := NewSLAService(testDB, config.Routing{Providers: []config.Provider{{Name: "twilio"}, {Name: "idle"}}})
+	from, to := start, start.Add(10*time.Minute)
+
+	sla, err := service.ProviderSLA(ctx, "twilio", &from, &to)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), sla.Attempts)
+	assert.Equal(t, int64(5), sla.Succeeded)
+	assert.InDelta(t, 0.5, sla.SuccessRate, 1e-9)
+	assert.InDelta(t, 550, sla.AvgLatencyMs, 1)
+	assert.InDelta(t, 500, sla.P50LatencyMs, 1)
+	assert.InDelta(t, 1000, sla.P95LatencyMs, 1)
+	assert.InDelta(t, 1000, sla.P99LatencyMs, 1)
+
+	require.Len(t, sla.Downtime, 2)
+	assert.Equal(t, start.Add(time.Minute), sla.Downtime[0].Start)
+	assert.Equal(t, start.Add(3*time.Minute), sla.Downtime[0].End)
+	assert.Equal(t, int64(120), sla.Downtime[0].DurationSeconds)
+	assert.Equal(t, int64(3), sla.Downtime[0].Attempts)
+	assert.Equal(t, start.Add(4*time.Minute), sla.Downtime[1].Start)
+	assert.Equal(t, int64(1), sla.Downtime[1].Attempts)
+	assert.Equal(t, int64(180), sla.DowntimeSeconds)
+	assert.InDelta(t, 0.7, sla.Availability, 1e-9)
+
+	t.Run("without attempts", func(t *testing.T) {
+		sla, err := service.ProviderSLA(ctx, "idle", nil, nil)
+		require.NoError(t, err)
+		assert.Zero(t, sla.Attempts)
+		assert.Equal(t, 1.0, sla.SuccessRate)
+		assert.Empty(t, sla.Downtime)
+		assert.Equal(t, DefaultSLAWindow, sla.To.Sub(sla.From))
+
+		// vonage is no longer configured but was sent to
+		_, err = service.ProviderSLA(ctx, "vonage", &to, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		_, err := service.ProviderSLA(ctx, "unknown", nil, nil)
+		assert.True(t, errors.Is(err, ErrProviderNotFound))
+		_, err = service.ProviderSLA(ctx, "twilio", &to, &from)
+		assert.True(t, errors.Is(err, ErrInvalidSLAWindow))
+	})
+}
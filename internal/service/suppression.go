@@ -0,0 +1,446 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/uptrace/bun"
+)
+
+// Suppression errors
+var (
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrInvalidPhone        = errors.New("invalid phone number")
+	// ErrInvalidImport is an opt-out list that cannot be read, e.g. a CSV without a phone number column
+	ErrInvalidImport = errors.New("invalid opt-out list")
+)
+
+// Opt-out list imports
+const (
+	// SuppressionImportBatchSize is the number of numbers suppressed per statement
+	SuppressionImportBatchSize = 1000
+	// MaxSuppressionImportRejections is the number of invalid rows an import response lists
+	MaxSuppressionImportRejections = 100
+	// DefaultSuppressionImportReason is the reason of the imported numbers without a reason of their own
+	DefaultSuppressionImportReason = "Imported opt-out list"
+)
+
+// SuppressionImport configures the import of an opt-out list
+type SuppressionImport struct {
+	// Format is csv or text, see ingest.NewSuppressionReader
+	Format string
+	// Reason is stored on the numbers without a reason of their own, DefaultSuppressionImportReason when empty
+	Reason string
+	// DryRun reports what the import would do without suppressing any number
+	DryRun bool
+}
+
+// Inbound message actions
+const (
+	InboundActionSuppressed   = "suppressed"
+	InboundActionUnsuppressed = "unsuppressed"
+	InboundActionNone         = "none"
+)
+
+// SuppressionInterface defines suppression list operations
+type SuppressionInterface interface {
+	ListSuppressions(ctx context.Context, page, pageSize int) (*dto.SuppressionsListResponse, error)
+	AddSuppression(ctx context.Context, req *dto.CreateSuppressionRequest) (*dto.SingleSuppressionResponse, error)
+	RemoveSuppression(ctx context.Context, phone string) error
+	HandleInbound(ctx context.Context, req *dto.InboundMessageRequest) (*dto.InboundMessageResponse, error)
+	ImportSuppressions(ctx context.Context, r io.Reader, opts SuppressionImport) (*dto.SuppressionImportResponse, error)
+}
+
+type SuppressionService struct {
+	db  *bun.DB
+	cfg config.Suppression
+}
+
+func NewSuppressionService(database *bun.DB, cfg config.Suppression) *SuppressionService {
+	return &SuppressionService{
+		db:  database,
+		cfg: cfg,
+	}
+}
+
+// ListSuppressions retrieves paginated suppressions, newest first
+func (s *SuppressionService) ListSuppressions(ctx context.Context, page, pageSize int) (*dto.SuppressionsListResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.ListSuppressions")
+	defer span.End()
+
+	page, pageSize, err := normalizePagination(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressions, err := db.ListSuppressions(ctx, s.db, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := db.CountSuppressions(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.SuppressionResponse, len(suppressions))
+	for i, suppression := range suppressions {
+		responses[i] = convertToSuppressionResponse(suppression)
+	}
+
+	return &dto.SuppressionsListResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Suppressions: responses,
+		Total:        total,
+		Page:         page,
+		PageSize:     pageSize,
+	}, nil
+}
+
+// AddSuppression suppresses a recipient, suppressing an already suppressed one returns the stored suppression
+func (s *SuppressionService) AddSuppression(ctx context.Context, req *dto.CreateSuppressionRequest) (*dto.SingleSuppressionResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.AddSuppression")
+	defer span.End()
+
+	suppression := &db.Suppression{Phone: req.Phone, Reason: req.Reason, Source: db.SuppressionSourceAPI}
+	if _, err := db.AddSuppression(ctx, s.db, suppression); err != nil {
+		if errors.Is(err, db.ErrInvalidPhoneNumber) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPhone, err.Error())
+		}
+		return nil, err
+	}
+
+	stored, err := db.GetSuppression(ctx, s.db, req.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SingleSuppressionResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Suppression: convertToSuppressionResponse(stored),
+	}, nil
+}
+
+// RemoveSuppression lifts the suppression of a recipient, pending messages to them are sent again.
+// Messages blocked in the meantime stay blocked.
+func (s *SuppressionService) RemoveSuppression(ctx context.Context, phone string) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.RemoveSuppression")
+	defer span.End()
+
+	removed, err := db.RemoveSuppression(ctx, s.db, phone, "")
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// ImportSuppressions suppresses the numbers of the opt-out list read from r, e.g. the do-not-contact list of a
+// previous vendor. Numbers are normalized to E.164, repeated and already suppressed numbers are counted and
+// skipped, suppressed numbers keep their reason and source. Invalid rows do not stop the import.
+func (s *SuppressionService) ImportSuppressions(ctx context.Context, r io.Reader, opts SuppressionImport) (*dto.SuppressionImportResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.ImportSuppressions")
+	defer span.End()
+
+	reader, err := ingest.NewSuppressionReader(opts.Format, r)
+	if err != nil {
+		if errors.Is(err, ingest.ErrUnsupportedInput) || errors.Is(err, ingest.ErrMissingColumn) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+		}
+		return nil, err
+	}
+
+	response := &dto.SuppressionImportResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		DryRun: opts.DryRun,
+	}
+	reject := func(line int, value, reason string) {
+		response.Invalid++
+		if len(response.Rejections) < MaxSuppressionImportRejections {
+			response.Rejections = append(response.Rejections, dto.SuppressionImportRejection{Line: line, Value: value, Reason: reason})
+		}
+	}
+
+	seen := make(map[string]struct{})
+	batch := make([]*db.Suppression, 0, SuppressionImportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+
+		if opts.DryRun {
+			phones := make([]string, len(batch))
+			for i, suppression := range batch {
+				phones[i] = suppression.Phone
+			}
+			suppressed, err := db.SuppressedPhones(ctx, s.db, phones)
+			if err != nil {
+				return err
+			}
+			response.Existing += len(suppressed)
+			response.Imported += len(batch) - len(suppressed)
+			return nil
+		}
+
+		imported, err := db.AddSuppressions(ctx, s.db, batch)
+		if err != nil {
+			return err
+		}
+		response.Imported += imported
+		response.Existing += len(batch) - imported
+		return nil
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, ingest.ErrMalformedRow) {
+				response.Read++
+				reject(row.Line, "", err.Error())
+				continue
+			}
+			return nil, fmt.Errorf("failed to read the opt-out list after %d rows: %w", response.Read, err)
+		}
+
+		response.Read++
+		if row.Phone == "" {
+			reject(row.Line, row.Raw, db.ErrInvalidPhoneNumber.Error())
+			continue
+		}
+		if _, ok := seen[row.Phone]; ok {
+			response.Duplicates++
+			continue
+		}
+		seen[row.Phone] = struct{}{}
+
+		batch = append(batch, &db.Suppression{
+			Phone:  row.Phone,
+			Reason: cmp.Or(row.Reason, opts.Reason, DefaultSuppressionImportReason),
+			Source: db.SuppressionSourceImport,
+		})
+		if len(batch) == SuppressionImportBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	config.LogFrom(ctx).WithFields(map[string]any{
+		"read":       response.Read,
+		"imported":   response.Imported,
+		"existing":   response.Existing,
+		"duplicates": response.Duplicates,
+		"invalid":    response.Invalid,
+		"dry_run":    opts.DryRun,
+	}).Info("Imported opt-out list")
+	return response, nil
+}
+
+// PhoneNormalization controls how NormalizePhones rewrites suppressed numbers that are not E.164
+type PhoneNormalization struct {
+	// BatchSize is the number of suppressions scanned per batch, defaults to SuppressionImportBatchSize
+	BatchSize int
+	// Pause is waited between batches to limit the load on the database
+	Pause time.Duration
+	// After resumes a run that stopped, only the numbers sorted after it are scanned
+	After string
+	// DryRun reports what the run would do without changing any suppression
+	DryRun bool
+	// DeleteUnfixable deletes the suppressions of numbers that cannot be normalized, they never match a message
+	DeleteUnfixable bool
+	// OnBatch is called after every batch with the result so far
+	OnBatch func(*PhoneNormalizationResult)
+}
+
+// PhoneNormalizationResult is the outcome of NormalizePhones
+type PhoneNormalizationResult struct {
+	// Scanned is the number of suppressions scanned
+	Scanned int
+	// Normalized is the number of suppressions moved to their E.164 number
+	Normalized int
+	// Merged is the number of suppressions dropped because their E.164 number was suppressed already
+	Merged int
+	// Unfixable are the numbers that cannot be normalized, deleted with DeleteUnfixable
+	Unfixable []string
+	// Cursor is the last number scanned, pass it as After to resume
+	Cursor string
+}
+
+// NormalizePhones rewrites the suppressed numbers that are not E.164, e.g. legacy rows stored before numbers were
+// validated, the way opt-out lists are normalized. It scans the suppressions in batches of numbers in order, each
+// batch is applied in one transaction so a run that stopped resumes from its Cursor. Unfixable numbers are reported,
+// SuppressionPhoneConstraint can be validated once none are left.
+func (s *SuppressionService) NormalizePhones(ctx context.Context, opts PhoneNormalization) (*PhoneNormalizationResult, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.NormalizePhones")
+	defer span.End()
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = SuppressionImportBatchSize
+	}
+	result := &PhoneNormalizationResult{Cursor: opts.After}
+	// planned are the numbers a dry run would have moved suppressions to
+	planned := make(map[string]struct{})
+
+	for {
+		phones, err := db.ListSuppressionPhones(ctx, s.db, result.Cursor, opts.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(phones) == 0 {
+			break
+		}
+
+		var normalized, merged int
+		var unfixable []string
+		err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, phone := range phones {
+				if db.ValidatePhone(phone) == nil {
+					continue
+				}
+				to, ok := ingest.NormalizePhone(phone)
+				if !ok {
+					unfixable = append(unfixable, phone)
+					if opts.DeleteUnfixable && !opts.DryRun {
+						if _, err := db.RemoveSuppression(ctx, tx, phone, ""); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				normalized++
+				if opts.DryRun {
+					_, seen := planned[to]
+					suppressed, err := db.IsSuppressed(ctx, tx, to)
+					if err != nil {
+						return err
+					}
+					if seen || suppressed {
+						merged++
+					}
+					planned[to] = struct{}{}
+					continue
+				}
+				dropped, err := db.RenameSuppression(ctx, tx, phone, to)
+				if err != nil {
+					return err
+				}
+				if dropped {
+					merged++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result.Scanned += len(phones)
+		result.Normalized += normalized - merged
+		result.Merged += merged
+		result.Unfixable = append(result.Unfixable, unfixable...)
+		result.Cursor = phones[len(phones)-1]
+		if opts.OnBatch != nil {
+			opts.OnBatch(result)
+		}
+
+		if len(phones) < opts.BatchSize {
+			break
+		}
+		if opts.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(opts.Pause):
+			}
+		}
+	}
+
+	config.LogFrom(ctx).WithFields(map[string]any{
+		"scanned":    result.Scanned,
+		"normalized": result.Normalized,
+		"merged":     result.Merged,
+		"unfixable":  len(result.Unfixable),
+		"dry_run":    opts.DryRun,
+	}).Info("Normalized suppressed phone numbers")
+	return result, nil
+}
+
+// HandleInbound suppresses the sender of a stop keyword and lifts it again on a start keyword.
+// A start keyword only lifts suppressions the sender created themselves, not those added through the API.
+func (s *SuppressionService) HandleInbound(ctx context.Context, req *dto.InboundMessageRequest) (*dto.InboundMessageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "SuppressionService.HandleInbound")
+	defer span.End()
+
+	response := &dto.InboundMessageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Action: InboundActionNone,
+	}
+
+	keyword := strings.ToUpper(strings.TrimSpace(req.Content))
+	switch {
+	case slices.ContainsFunc(s.cfg.StopKeywords, func(k string) bool { return strings.EqualFold(k, keyword) }):
+		suppression := &db.Suppression{Phone: req.From, Reason: "Replied " + keyword, Source: db.SuppressionSourceInbound}
+		if _, err := db.AddSuppression(ctx, s.db, suppression); err != nil {
+			if errors.Is(err, db.ErrInvalidPhoneNumber) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidPhone, err.Error())
+			}
+			return nil, err
+		}
+		config.LogFrom(ctx).Info("Recipient opted out")
+		response.Action = InboundActionSuppressed
+
+	case slices.ContainsFunc(s.cfg.StartKeywords, func(k string) bool { return strings.EqualFold(k, keyword) }):
+		removed, err := db.RemoveSuppression(ctx, s.db, req.From, db.SuppressionSourceInbound)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			config.LogFrom(ctx).Info("Recipient opted back in")
+			response.Action = InboundActionUnsuppressed
+		}
+	}
+
+	return response, nil
+}
+
+func convertToSuppressionResponse(suppression *db.Suppression) dto.SuppressionResponse {
+	return dto.SuppressionResponse{
+		Phone:     suppression.Phone,
+		Reason:    suppression.Reason,
+		Source:    string(suppression.Source),
+		CreatedAt: suppression.CreatedAt,
+	}
+}
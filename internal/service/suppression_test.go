@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/ingest"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuppressionService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	service := NewSuppressionService(testDB, config.Suppression{
+		StopKeywords:  []string{"STOP"},
+		StartKeywords: []string{"START"},
+	})
+	inbound := func(from, content string) string {
+		response, err := service.HandleInbound(ctx, &dto.InboundMessageRequest{From: from, Content: content})
+		require.NoError(t, err)
+		return response.Action
+	}
+
+	t.Run("stop keyword suppresses the sender", func(t *testing.T) {
+		assert.Equal(t, InboundActionNone, inbound("+905551111111", "Thanks!"))
+		assert.Equal(t, InboundActionSuppressed, inbound("+905551111111", " stop "))
+
+		stored, err := db.GetSuppression(ctx, testDB, "+905551111111")
+		require.NoError(t, err)
+		assert.Equal(t, db.SuppressionSourceInbound, stored.Source)
+		assert.Equal(t, "Replied STOP", stored.Reason)
+	})
+
+	t.Run("messages to suppressed recipients are enqueued blocked", func(t *testing.T) {
+		blocked := &db.Message{To: "+905551111111", Content: "Blocked"}
+		allowed := &db.Message{To: "+905552222222", Content: "Allowed"}
+		require.NoError(t, db.CreateMessages(ctx, testDB, []*db.Message{blocked, allowed}))
+
+		assert.Equal(t, db.MessageStatusBlocked, blocked.Status)
+		assert.Equal(t, db.MessageStatusPending, allowed.Status)
+	})
+
+	t.Run("start keyword only lifts suppressions of the sender", func(t *testing.T) {
+		_, err := service.AddSuppression(ctx, &dto.CreateSuppressionRequest{Phone: "+905553333333", Reason: "Complaint"})
+		require.NoError(t, err)
+
+		assert.Equal(t, InboundActionNone, inbound("+905553333333", "START"))
+		assert.Equal(t, InboundActionUnsuppressed, inbound("+905551111111", "START"))
+
+		suppressed, err := db.IsSuppressed(ctx, testDB, "+905553333333")
+		require.NoError(t, err)
+		assert.True(t, suppressed)
+		suppressed, err = db.IsSuppressed(ctx, testDB, "+905551111111")
+		require.NoError(t, err)
+		assert.False(t, suppressed)
+	})
+
+	t.Run("add is idempotent and validates the number", func(t *testing.T) {
+		response, err := service.AddSuppression(ctx, &dto.CreateSuppressionRequest{Phone: "+905553333333", Reason: "Again"})
+		require.NoError(t, err)
+		assert.Equal(t, "Complaint", response.Suppression.Reason)
+
+		_, err = service.AddSuppression(ctx, &dto.CreateSuppressionRequest{Phone: "invalid"})
+		assert.ErrorIs(t, err, ErrInvalidPhone)
+	})
+
+	t.Run("list and remove", func(t *testing.T) {
+		response, err := service.ListSuppressions(ctx, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Total)
+		require.Len(t, response.Suppressions, 1)
+		assert.Equal(t, "api", response.Suppressions[0].Source)
+
+		require.NoError(t, service.RemoveSuppression(ctx, "+905553333333"))
+		assert.ErrorIs(t, service.RemoveSuppression(ctx, "+905553333333"), ErrSuppressionNotFound)
+	})
+}
+
+func TestSuppressionService_ImportSuppressions(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	service := NewSuppressionService(testDB, config.Suppression{})
+	_, err := service.AddSuppression(ctx, &dto.CreateSuppressionRequest{Phone: "+905551111111", Reason: "Complaint"})
+	require.NoError(t, err)
+
+	list := "phone,reason\n" +
+		"+905551111111,\n" +
+		"+905552222222,Replied STOP\n" +
+		"905552222222,\n" +
+		"+905553333333,\n" +
+		"unknown,\n"
+
+	t.Run("dry run reports without importing", func(t *testing.T) {
+		response, err := service.ImportSuppressions(ctx, strings.NewReader(list), SuppressionImport{Format: ingest.FormatCSV, DryRun: true})
+		require.NoError(t, err)
+		assert.True(t, response.DryRun)
+		assert.Equal(t, 5, response.Read)
+		assert.Equal(t, 2, response.Imported)
+		assert.Equal(t, 1, response.Existing)
+		assert.Equal(t, 1, response.Duplicates)
+		assert.Equal(t, 1, response.Invalid)
+		require.Len(t, response.Rejections, 1)
+		assert.Equal(t, dto.SuppressionImportRejection{Line: 6, Value: "unknown", Reason: db.ErrInvalidPhoneNumber.Error()}, response.Rejections[0])
+
+		suppressed, err := db.IsSuppressed(ctx, testDB, "+905552222222")
+		require.NoError(t, err)
+		assert.False(t, suppressed)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		response, err := service.ImportSuppressions(ctx, strings.NewReader(list), SuppressionImport{Format: ingest.FormatCSV, Reason: "Provider export"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Imported)
+		assert.Equal(t, 1, response.Existing)
+
+		stored, err := db.GetSuppression(ctx, testDB, "+905552222222")
+		require.NoError(t, err)
+		assert.Equal(t, db.SuppressionSourceImport, stored.Source)
+		assert.Equal(t, "Replied STOP", stored.Reason, "the reason of a row wins over the reason of the import")
+		stored, err = db.GetSuppression(ctx, testDB, "+905553333333")
+		require.NoError(t, err)
+		assert.Equal(t, "Provider export", stored.Reason)
+		stored, err = db.GetSuppression(ctx, testDB, "+905551111111")
+		require.NoError(t, err)
+		assert.Equal(t, "Complaint", stored.Reason, "existing suppressions are kept")
+	})
+
+	t.Run("invalid lists", func(t *testing.T) {
+		_, err := service.ImportSuppressions(ctx, strings.NewReader("name\n"), SuppressionImport{Format: ingest.FormatCSV})
+		assert.ErrorIs(t, err, ErrInvalidImport)
+		_, err = service.ImportSuppressions(ctx, strings.NewReader(""), SuppressionImport{Format: "xlsx"})
+		assert.ErrorIs(t, err, ErrInvalidImport)
+	})
+}
+
+func TestSuppressionService_NormalizePhones(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	ctx := context.Background()
+	service := NewSuppressionService(testDB, config.Suppression{})
+	legacy := []*db.Suppression{
+		{Phone: "+905551111111", Reason: "Complaint", Source: db.SuppressionSourceAPI},
+		{Phone: "00905551111111", Reason: "Legacy", Source: db.SuppressionSourceAPI},
+		{Phone: "0090 555 222 22 22", Reason: "Legacy", Source: db.SuppressionSourceInbound},
+		{Phone: "905553333333", Reason: "Legacy", Source: db.SuppressionSourceAPI},
+		{Phone: "n/a", Reason: "Legacy", Source: db.SuppressionSourceAPI},
+	}
+	// legacy rows were stored before numbers were validated, AddSuppressions does not validate them
+	_, err := db.AddSuppressions(ctx, testDB, legacy)
+	require.NoError(t, err)
+
+	t.Run("dry run reports without changing", func(t *testing.T) {
+		result, err := service.NormalizePhones(ctx, PhoneNormalization{BatchSize: 2, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, 5, result.Scanned)
+		assert.Equal(t, 2, result.Normalized)
+		assert.Equal(t, 1, result.Merged)
+		assert.Equal(t, []string{"n/a"}, result.Unfixable)
+
+		total, err := db.CountSuppressions(ctx, testDB)
+		require.NoError(t, err)
+		assert.Equal(t, 5, total)
+	})
+
+	t.Run("resumes after a cursor", func(t *testing.T) {
+		var batches int
+		result, err := service.NormalizePhones(ctx, PhoneNormalization{
+			BatchSize: 2,
+			After:     "0090 555 222 22 22",
+			OnBatch:   func(*PhoneNormalizationResult) { batches++ },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Scanned, "only the numbers after the cursor are scanned")
+		assert.Equal(t, 1, result.Normalized)
+		assert.Equal(t, 1, result.Merged)
+		assert.Equal(t, 2, batches)
+
+		suppressed, err := db.IsSuppressed(ctx, testDB, "+905552222222")
+		require.NoError(t, err)
+		assert.False(t, suppressed)
+	})
+
+	t.Run("normalizes", func(t *testing.T) {
+		result, err := service.NormalizePhones(ctx, PhoneNormalization{BatchSize: 2, DeleteUnfixable: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Normalized, "the numbers before the cursor are left")
+		assert.Equal(t, []string{"n/a"}, result.Unfixable)
+
+		stored, err := db.GetSuppression(ctx, testDB, "+905552222222")
+		require.NoError(t, err)
+		assert.Equal(t, db.SuppressionSourceInbound, stored.Source, "a moved suppression keeps its source")
+		stored, err = db.GetSuppression(ctx, testDB, "+905551111111")
+		require.NoError(t, err)
+		assert.Equal(t, "Complaint", stored.Reason, "a merged suppression keeps the E.164 one")
+
+		phones, err := db.ListSuppressionPhones(ctx, testDB, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"+905551111111", "+905552222222", "+905553333333"}, phones)
+	})
+}
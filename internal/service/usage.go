@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/quota"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// ErrQuotaExceeded is returned when creating a message would exceed a daily or monthly quota
+var ErrQuotaExceeded = quota.ErrExceeded
+
+// UsageInterface defines quota usage operations
+type UsageInterface interface {
+	GetUsage(ctx context.Context) (*dto.UsageResponse, error)
+	Limits(ctx context.Context) (*dto.LimitsResponse, error)
+	QuotaStatus(ctx context.Context) ([]quota.Status, error)
+}
+
+type UsageService struct {
+	quotas *quota.Quotas
+}
+
+func NewUsageService(quotas *quota.Quotas) *UsageService {
+	return &UsageService{
+		quotas: quotas,
+	}
+}
+
+// GetUsage returns the usage counters of the current UTC day and month with their limits and consumption
+func (s *UsageService) GetUsage(ctx context.Context) (*dto.UsageResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "UsageService.GetUsage")
+	defer span.End()
+
+	counters, err := s.quotas.Usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.UsageResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Counters: make([]dto.UsageCounter, 0, len(counters)),
+	}
+	for _, counter := range counters {
+		item := dto.UsageCounter{
+			Scope:       string(counter.Scope),
+			Subject:     counter.Subject,
+			Period:      string(counter.Period),
+			PeriodStart: counter.PeriodStart,
+			Count:       counter.Count,
+			Limit:       s.quotas.Limit(counter.Scope, counter.Subject, counter.Period),
+		}
+		if item.Limit > 0 {
+			remaining := max(item.Limit-item.Count, 0)
+			item.Remaining = &remaining
+			item.UsedRatio = float64(item.Count) / float64(item.Limit)
+			item.WarningThreshold = s.quotas.WarningThreshold(counter.Scope, counter.Subject, counter.Period, counter.Count)
+		}
+		response.Counters = append(response.Counters, item)
+	}
+
+	return response, nil
+}
+
+// QuotaStatus returns the quotas of the API key in ctx with their use, empty when it has none
+func (s *UsageService) QuotaStatus(ctx context.Context) ([]quota.Status, error) {
+	return s.quotas.APIKeyStatus(ctx, quota.APIKeyFrom(ctx))
+}
+
+// Limits describes the quotas of the API key in ctx, so clients can throttle themselves before they are refused
+func (s *UsageService) Limits(ctx context.Context) (*dto.LimitsResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "UsageService.Limits")
+	defer span.End()
+
+	statuses, err := s.QuotaStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.LimitsResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		APIKey: quota.APIKeyFrom(ctx),
+		Quotas: make([]dto.QuotaLimit, 0, len(statuses)),
+	}
+	for _, status := range statuses {
+		response.Quotas = append(response.Quotas, dto.QuotaLimit{
+			Period:    string(status.Period),
+			Limit:     status.Limit,
+			Used:      status.Used,
+			Remaining: status.Remaining(),
+			Reset:     status.Reset,
+		})
+	}
+	return response, nil
+}
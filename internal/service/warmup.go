@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+)
+
+// WarmupConnections is the number of database connections a warmup opens, the connections database/sql keeps
+// idle by default
+const WarmupConnections = 2
+
+// Warmup prepares a freshly started instance before it is added to the load balancer rotation: it opens the
+// database connections, loads the webhook overrides and connects to the webhook of every provider, so the first
+// requests and sends do not pay for them. The response status is "ok" only if every step succeeds.
+func (s *HealthService) Warmup(ctx context.Context) *dto.WarmupResponse {
+	ctx, span := telemetry.Tracer().Start(ctx, "HealthService.Warmup")
+	defer span.End()
+
+	started := time.Now()
+	checks := map[string]error{"database": s.warmupDatabase(ctx)}
+	if s.scheduler != nil {
+		checks["webhook_overrides"] = s.scheduler.loadOverrides(ctx)
+		connections := max(s.scheduler.cfg.Webhook.Connections.Prewarm, 1)
+		for provider, err := range s.scheduler.warmupProviders(ctx, connections) {
+			checks["provider:"+provider] = err
+		}
+	}
+
+	response := &dto.WarmupResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Checks: make(map[string]string, len(checks)),
+	}
+	for name, err := range checks {
+		if err != nil {
+			response.Status = "error"
+			response.Checks[name] = err.Error()
+			config.LogFrom(ctx).WithError(err).WithField("check", name).Warn("Warmup check failed")
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+	response.DurationMS = time.Since(started).Milliseconds()
+	return response
+}
+
+// warmupDatabase opens WarmupConnections database connections at once, bounded by the open connection limit,
+// and returns them to the pool
+func (s *HealthService) warmupDatabase(ctx context.Context) error {
+	cctx, cancel := context.WithTimeout(ctx, ReadinessCheckTimeout)
+	defer cancel()
+
+	n := WarmupConnections
+	if limit := s.db.Stats().MaxOpenConnections; limit > 0 && limit < n {
+		n = limit
+	}
+
+	// the connections are held until all are open, otherwise the pool would hand out the same one again
+	conns := make([]*sql.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for range n {
+		conn, err := s.db.Conn(cctx)
+		if err == nil {
+			err = conn.PingContext(cctx)
+			conns = append(conns, conn.Conn)
+		}
+		if s.availability != nil {
+			s.availability.set(err == nil, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadOverrides loads the webhook overrides the sends use until the next batch loads them again
+func (s *Scheduler) loadOverrides(ctx context.Context) error {
+	overrides, err := loadWebhookOverrides(ctx, s.db, s.box)
+	if err != nil {
+		return err
+	}
+	s.overrides.Store(overrides)
+	return nil
+}
+
+// warmupProviders opens connections to the webhook of every provider at once, keyed by provider name. The
+// sandbox provider is served in-process and providers without a URL are not used, both are skipped.
+func (s *Scheduler) warmupProviders(ctx context.Context, connections int) map[string]error {
+	urls := map[string]string{config.WebhookProvider: s.cfg.Webhook.URL}
+	for _, provider := range s.cfg.Routing.Providers {
+		urls[provider.Name] = provider.URL
+	}
+	delete(urls, config.SandboxProvider)
+	for provider, url := range urls {
+		if url == "" {
+			delete(urls, provider)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]error, len(urls))
+	for provider, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cctx, cancel := context.WithTimeout(ctx, ReadinessCheckTimeout)
+			defer cancel()
+			err := s.webhookClient.WithURL(url).Prewarm(cctx, connections)
+			mu.Lock()
+			results[provider] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
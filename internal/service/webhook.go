@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/secrets"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/uptrace/bun"
+)
+
+// Webhook override errors
+var (
+	ErrInvalidWebhookOverride  = errors.New("invalid webhook override")
+	ErrWebhookOverrideNotFound = errors.New("webhook override not found")
+	// ErrNoEncryptionKey is returned for overrides with credentials while webhook.encryption_key is not set
+	ErrNoEncryptionKey = errors.New("webhook.encryption_key is not set, credentials cannot be stored")
+)
+
+// WebhookOverrideInterface defines the webhook overrides of tenants and campaigns
+type WebhookOverrideInterface interface {
+	ListOverrides(ctx context.Context) (*dto.WebhookOverridesResponse, error)
+	PutOverride(ctx context.Context, scope, name string, req *dto.WebhookOverrideRequest) (*dto.SingleWebhookOverrideResponse, error)
+	DeleteOverride(ctx context.Context, scope, name string) error
+}
+
+// WebhookOverrideService stores the webhooks the messages of tenants and campaigns are sent to instead of
+// webhook.url, e.g. the gateways of white-label customers
+type WebhookOverrideService struct {
+	db  *bun.DB
+	box *secrets.Box
+}
+
+func NewWebhookOverrideService(database *bun.DB, cfg config.Webhook) *WebhookOverrideService {
+	return &WebhookOverrideService{
+		db:  database,
+		box: webhookBox(cfg),
+	}
+}
+
+// webhookBox returns the box the override credentials are encrypted with, nil without webhook.encryption_key.
+// The key is validated with the config.
+func webhookBox(cfg config.Webhook) *secrets.Box {
+	if cfg.EncryptionKey == "" {
+		return nil
+	}
+	box, err := secrets.NewBox(cfg.EncryptionKey)
+	if err != nil {
+		config.Log().Errorf("Invalid webhook.encryption_key, webhook override credentials are unavailable: %v", err)
+		return nil
+	}
+	return box
+}
+
+// ListOverrides returns every webhook override ordered by scope and name
+func (s *WebhookOverrideService) ListOverrides(ctx context.Context) (*dto.WebhookOverridesResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "WebhookOverrideService.ListOverrides")
+	defer span.End()
+
+	overrides, err := db.ListWebhookOverrides(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.WebhookOverrideResponse, len(overrides))
+	for i, override := range overrides {
+		responses[i] = convertWebhookOverride(override)
+	}
+	return &dto.WebhookOverridesResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Overrides: responses,
+	}, nil
+}
+
+// PutOverride sends the messages of a tenant or campaign to req.URL, replacing their existing override.
+// The credentials are encrypted with webhook.encryption_key before they are stored.
+func (s *WebhookOverrideService) PutOverride(ctx context.Context, scope, name string, req *dto.WebhookOverrideRequest) (*dto.SingleWebhookOverrideResponse, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "WebhookOverrideService.PutOverride")
+	defer span.End()
+
+	if scope != db.WebhookScopeTenant && scope != db.WebhookScopeCampaign {
+		return nil, fmt.Errorf("%w: scope must be tenant or campaign", ErrInvalidWebhookOverride)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidWebhookOverride)
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not a valid http(s) URL", ErrInvalidWebhookOverride, req.URL)
+	}
+
+	override := &db.WebhookOverride{Scope: scope, Name: name, URL: req.URL, Auth: db.WebhookAuthNone}
+	credentials := webhook.Credentials{Username: req.Username, Password: req.Password, Token: req.Token}
+	switch {
+	case credentials.Token != "" && (credentials.Username != "" || credentials.Password != ""):
+		return nil, fmt.Errorf("%w: token cannot be combined with username and password", ErrInvalidWebhookOverride)
+	case credentials.Password != "" && credentials.Username == "":
+		return nil, fmt.Errorf("%w: password requires a username", ErrInvalidWebhookOverride)
+	case credentials.Token != "":
+		override.Auth = db.WebhookAuthBearer
+	case credentials.Username != "":
+		override.Auth = db.WebhookAuthBasic
+	}
+	if override.Auth != db.WebhookAuthNone {
+		if s.box == nil {
+			return nil, ErrNoEncryptionKey
+		}
+		plaintext, err := json.Marshal(credentials)
+		if err != nil {
+			return nil, err
+		}
+		if override.Credentials, err = s.box.Seal(plaintext); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.PutWebhookOverride(ctx, s.db, override); err != nil {
+		return nil, err
+	}
+	config.LogFrom(ctx).WithField(scope, name).Infof("Stored webhook override with %s authentication", override.Auth)
+
+	return &dto.SingleWebhookOverrideResponse{
+		BaseResponse: dto.BaseResponse{
+			Status:    "ok",
+			Timestamp: time.Now().UTC(),
+		},
+		Override: convertWebhookOverride(override),
+	}, nil
+}
+
+// DeleteOverride removes the override of a tenant or campaign, their messages are sent to webhook.url again
+func (s *WebhookOverrideService) DeleteOverride(ctx context.Context, scope, name string) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "WebhookOverrideService.DeleteOverride")
+	defer span.End()
+
+	deleted, err := db.DeleteWebhookOverride(ctx, s.db, scope, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s %s", ErrWebhookOverrideNotFound, scope, name)
+	}
+	return nil
+}
+
+func convertWebhookOverride(override *db.WebhookOverride) dto.WebhookOverrideResponse {
+	return dto.WebhookOverrideResponse{
+		Scope:     override.Scope,
+		Name:      override.Name,
+		URL:       override.URL,
+		Auth:      override.Auth,
+		CreatedAt: override.CreatedAt,
+		UpdatedAt: override.UpdatedAt,
+	}
+}
+
+// webhookTarget is the webhook of an override with its decrypted credentials, err is set when the credentials
+// could not be decrypted
+type webhookTarget struct {
+	// override is the scope and name of the override, e.g. tenant:acme
+	override    string
+	url         string
+	credentials webhook.Credentials
+	err         error
+}
+
+// webhookOverrides are the overrides the scheduler sends a batch with
+type webhookOverrides struct {
+	tenants   map[string]*webhookTarget
+	campaigns map[string]*webhookTarget
+}
+
+// loadWebhookOverrides reads every override and decrypts its credentials with box
+func loadWebhookOverrides(ctx context.Context, database bun.IDB, box *secrets.Box) (*webhookOverrides, error) {
+	stored, err := db.ListWebhookOverrides(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &webhookOverrides{tenants: map[string]*webhookTarget{}, campaigns: map[string]*webhookTarget{}}
+	for _, override := range stored {
+		target := &webhookTarget{override: override.Scope + ":" + override.Name, url: override.URL}
+		if override.Auth != db.WebhookAuthNone {
+			target.err = ErrNoEncryptionKey
+			if box != nil {
+				var plaintext []byte
+				if plaintext, target.err = box.Open(override.Credentials); target.err == nil {
+					target.err = json.Unmarshal(plaintext, &target.credentials)
+				}
+			}
+		}
+
+		if override.Scope == db.WebhookScopeCampaign {
+			overrides.campaigns[override.Name] = target
+		} else {
+			overrides.tenants[override.Name] = target
+		}
+	}
+	return overrides, nil
+}
+
+// of returns the override of the message's campaign, or else of its tenant, nil when there is none
+func (o *webhookOverrides) of(message *db.Message) *webhookTarget {
+	if o == nil {
+		return nil
+	}
+	if target, ok := o.campaigns[message.Campaign]; ok && message.Campaign != "" {
+		return target
+	}
+	if target, ok := o.tenants[message.Tenant]; ok && message.Tenant != "" {
+		return target
+	}
+	return nil
+}
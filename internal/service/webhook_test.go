@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEncryptionKey(fill string) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(fill, 32)))
+}
+
+func TestWebhookOverrideService(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	service := NewWebhookOverrideService(testDB, config.Webhook{EncryptionKey: testEncryptionKey("k")})
+	ctx := context.Background()
+
+	t.Run("invalid overrides", func(t *testing.T) {
+		for _, tc := range []struct {
+			scope, name string
+			req         dto.WebhookOverrideRequest
+		}{
+			{"customer", "acme", dto.WebhookOverrideRequest{URL: "https://sms.acme.example"}},
+			{"tenant", "", dto.WebhookOverrideRequest{URL: "https://sms.acme.example"}},
+			{"tenant", "acme", dto.WebhookOverrideRequest{URL: "ftp://sms.acme.example"}},
+			{"tenant", "acme", dto.WebhookOverrideRequest{URL: "https://sms.acme.example", Token: "t", Username: "u"}},
+			{"tenant", "acme", dto.WebhookOverrideRequest{URL: "https://sms.acme.example", Password: "p"}},
+		} {
+			_, err := service.PutOverride(ctx, tc.scope, tc.name, &tc.req)
+			assert.True(t, errors.Is(err, ErrInvalidWebhookOverride), "%+v", tc)
+		}
+
+		_, err := NewWebhookOverrideService(testDB, config.Webhook{}).PutOverride(ctx, "tenant", "acme",
+			&dto.WebhookOverrideRequest{URL: "https://sms.acme.example", Token: "s3cret"})
+		assert.True(t, errors.Is(err, ErrNoEncryptionKey))
+	})
+
+	created, err := service.PutOverride(ctx, "tenant", "acme", &dto.WebhookOverrideRequest{URL: "https://old.acme.example"})
+	require.NoError(t, err)
+	assert.Equal(t, db.WebhookAuthNone, created.Override.Auth)
+
+	updated, err := service.PutOverride(ctx, "tenant", "acme", &dto.WebhookOverrideRequest{URL: "https://sms.acme.example", Token: "s3cret"})
+	require.NoError(t, err)
+	assert.Equal(t, db.WebhookAuthBearer, updated.Override.Auth)
+	_, err = service.PutOverride(ctx, "campaign", "promo", &dto.WebhookOverrideRequest{URL: "https://promo.example", Username: "acme", Password: "pass"})
+	require.NoError(t, err)
+
+	list, err := service.ListOverrides(ctx)
+	require.NoError(t, err)
+	require.Len(t, list.Overrides, 2)
+	assert.Equal(t, "promo", list.Overrides[0].Name)
+	assert.Equal(t, db.WebhookAuthBasic, list.Overrides[0].Auth)
+	assert.Equal(t, "https://sms.acme.example", list.Overrides[1].URL, "putting an override again replaces it")
+
+	stored, err := db.ListWebhookOverrides(ctx, testDB)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored[1].Credentials), "s3cret", "credentials are stored encrypted")
+
+	overrides, err := loadWebhookOverrides(ctx, testDB, service.box)
+	require.NoError(t, err)
+	target := overrides.of(&db.Message{Tenant: "acme", Campaign: "promo"})
+	require.NotNil(t, target)
+	assert.Equal(t, "campaign:promo", target.override, "a campaign's override wins over its tenant's")
+	assert.Equal(t, webhook.Credentials{Username: "acme", Password: "pass"}, target.credentials)
+	assert.Equal(t, webhook.Credentials{Token: "s3cret"}, overrides.of(&db.Message{Tenant: "acme"}).credentials)
+	assert.Nil(t, overrides.of(&db.Message{Campaign: "other"}))
+
+	require.NoError(t, service.DeleteOverride(ctx, "tenant", "acme"))
+	err = service.DeleteOverride(ctx, "tenant", "acme")
+	assert.True(t, errors.Is(err, ErrWebhookOverrideNotFound))
+}
+
+func TestScheduler_ProcessBatch_WebhookOverrides(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]string{}
+	gateway := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload webhook.MessagePayload
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			mu.Lock()
+			received[payload.To] = name + " " + r.Header.Get("Authorization")
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	global, tenant, campaign := gateway("global"), gateway("tenant"), gateway("campaign")
+	defer global.Close()
+	defer tenant.Close()
+	defer campaign.Close()
+
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	ctx := context.Background()
+	key := config.Webhook{EncryptionKey: testEncryptionKey("k")}
+	overrides := NewWebhookOverrideService(testDB, key)
+	_, err := overrides.PutOverride(ctx, "tenant", "acme", &dto.WebhookOverrideRequest{URL: tenant.URL, Token: "s3cret"})
+	require.NoError(t, err)
+	_, err = overrides.PutOverride(ctx, "campaign", "promo", &dto.WebhookOverrideRequest{URL: campaign.URL})
+	require.NoError(t, err)
+	// credentials encrypted with a key the scheduler does not have
+	_, err = NewWebhookOverrideService(testDB, config.Webhook{EncryptionKey: testEncryptionKey("o")}).PutOverride(ctx, "tenant", "rotated",
+		&dto.WebhookOverrideRequest{URL: tenant.URL, Token: "lost"})
+	require.NoError(t, err)
+
+	cfg := &config.Cfg{
+		Messaging: config.Messaging{BatchSize: 4},
+		Webhook:   config.Webhook{URL: global.URL, EncryptionKey: key.EncryptionKey},
+	}
+	q := &fakeQueue{acked: make(map[int64]queue.Delivery)}
+	require.NoError(t, q.Enqueue(ctx,
+		&db.Message{ID: 1, To: "+905551111111", Content: "Tenant", Tenant: "acme"},
+		&db.Message{ID: 2, To: "+905552222222", Content: "Campaign", Tenant: "acme", Campaign: "promo"},
+		&db.Message{ID: 3, To: "+905553333333", Content: "Global"},
+		&db.Message{ID: 4, To: "+905554444444", Content: "Undecryptable", Tenant: "rotated"},
+	))
+
+	NewSchedulerWithQueue(testDB, q, cfg).processBatch(ctx)
+
+	assert.Equal(t, map[string]string{
+		"+905551111111": "tenant Bearer s3cret",
+		"+905552222222": "campaign ",
+		"+905553333333": "global ",
+	}, received)
+	assert.Len(t, q.acked, 3)
+	require.Len(t, q.pending, 1, "messages of overrides that cannot be decrypted are not sent to webhook.url")
+	assert.Equal(t, int64(4), q.pending[0].ID)
+}
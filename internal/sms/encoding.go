@@ -0,0 +1,99 @@
+// Package sms implements the SMS encoding rules that decide how many segments a message is sent and billed as.
+package sms
+
+import "strings"
+
+// Encoding is the character encoding a message is sent with
+type Encoding string
+
+const (
+	// EncodingGSM7 packs 160 characters of the GSM 03.38 alphabet in a single segment
+	EncodingGSM7 Encoding = "gsm7"
+	// EncodingUCS2 is used as soon as a character is not in the GSM 03.38 alphabet, 70 characters fit in a segment
+	EncodingUCS2 Encoding = "ucs2"
+)
+
+// Segment sizes in septets for GSM-7 and UTF-16 code units for UCS-2. Concatenated segments are
+// shorter since their user data header takes up room.
+const (
+	GSM7SingleSegment    = 160
+	GSM7MultipartSegment = 153
+	UCS2SingleSegment    = 70
+	UCS2MultipartSegment = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet without the escape character, every character takes one septet
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension is the GSM 03.38 extension table, every character takes two septets (escape and character)
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Encode returns the encoding content is sent with, the number of segments it takes and its length
+// in the units of that encoding (septets or UTF-16 code units). Empty content takes no segments.
+func Encode(content string) (Encoding, int, int) {
+	if units, ok := gsm7Units(content); ok {
+		return EncodingGSM7, segments(units, GSM7SingleSegment, GSM7MultipartSegment), total(units)
+	}
+	units := ucs2Units(content)
+	return EncodingUCS2, segments(units, UCS2SingleSegment, UCS2MultipartSegment), total(units)
+}
+
+// gsm7Units returns the septets of every character, false when a character is not in the GSM 03.38 alphabet
+func gsm7Units(content string) ([]int, bool) {
+	units := make([]int, 0, len(content))
+	for _, r := range content {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			units = append(units, 1)
+		case strings.ContainsRune(gsm7Extension, r):
+			units = append(units, 2)
+		default:
+			return nil, false
+		}
+	}
+	return units, true
+}
+
+// ucs2Units returns the UTF-16 code units of every character, characters outside the BMP take a surrogate pair
+func ucs2Units(content string) []int {
+	units := make([]int, 0, len(content))
+	for _, r := range content {
+		if r > 0xFFFF {
+			units = append(units, 2)
+		} else {
+			units = append(units, 1)
+		}
+	}
+	return units
+}
+
+// segments counts the segments the characters are split into. Escape sequences and surrogate pairs
+// are never split, so a character that does not fit the rest of a segment starts the next one.
+func segments(units []int, single, multipart int) int {
+	length := total(units)
+	if length == 0 {
+		return 0
+	}
+	if length <= single {
+		return 1
+	}
+
+	count, used := 1, 0
+	for _, n := range units {
+		if used+n > multipart {
+			count++
+			used = 0
+		}
+		used += n
+	}
+	return count
+}
+
+func total(units []int) int {
+	var sum int
+	for _, n := range units {
+		sum += n
+	}
+	return sum
+}
@@ -0,0 +1,42 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		encoding Encoding
+		segments int
+		length   int
+	}{
+		{"empty", "", EncodingGSM7, 0, 0},
+		{"plain text", "Hello, your code is 1234", EncodingGSM7, 1, 24},
+		{"gsm accents", "Ça va? Äpfel für Müller", EncodingGSM7, 1, 23},
+		{"single gsm segment", strings.Repeat("a", 160), EncodingGSM7, 1, 160},
+		{"two gsm segments", strings.Repeat("a", 161), EncodingGSM7, 2, 161},
+		{"three gsm segments", strings.Repeat("a", 307), EncodingGSM7, 3, 307},
+		{"extension characters take two septets", strings.Repeat("€", 80), EncodingGSM7, 1, 160},
+		{"escape sequences are not split", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10), EncodingGSM7, 2, 164},
+		{"turkish letters", "Şubat ayı kampanyası başladı", EncodingUCS2, 1, 28},
+		{"backtick is not gsm", "`code`", EncodingUCS2, 1, 6},
+		{"single ucs2 segment", strings.Repeat("ş", 70), EncodingUCS2, 1, 70},
+		{"two ucs2 segments", strings.Repeat("ş", 71), EncodingUCS2, 2, 71},
+		{"emoji take a surrogate pair", strings.Repeat("😀", 35), EncodingUCS2, 1, 70},
+		{"surrogate pairs are not split", strings.Repeat("ş", 66) + "😀" + strings.Repeat("ş", 5), EncodingUCS2, 2, 73},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, segments, length := Encode(tt.content)
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.segments, segments)
+			assert.Equal(t, tt.length, length)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import "sync"
+
+const (
+	// DefaultMaxLabelValues is the number of distinct tenants and campaigns tracked per label
+	DefaultMaxLabelValues = 100
+
+	// labelNone is used for messages without a tenant or campaign
+	labelNone = "none"
+	// labelOther replaces values once a label reached its limit
+	labelOther = "other"
+)
+
+// MessageLabels are the tenant and campaign a metric is recorded for
+type MessageLabels struct {
+	Tenant   string
+	Campaign string
+}
+
+var (
+	tenantLabels   = newLabelGuard(DefaultMaxLabelValues)
+	campaignLabels = newLabelGuard(DefaultMaxLabelValues)
+)
+
+// SetMaxLabelValues limits the distinct tenant and campaign label values, values seen after the
+// limit is reached are recorded as "other" so a bulk import with unique campaigns can't explode the series
+func SetMaxLabelValues(max int) {
+	tenantLabels = newLabelGuard(max)
+	campaignLabels = newLabelGuard(max)
+}
+
+// guarded returns the label values to record for labels
+func (l MessageLabels) guarded() (tenant, campaign string) {
+	return tenantLabels.value(l.Tenant), campaignLabels.value(l.Campaign)
+}
+
+// labelGuard admits the first max distinct values of a label
+type labelGuard struct {
+	max  int
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newLabelGuard(max int) *labelGuard {
+	return &labelGuard{max: max, seen: make(map[string]struct{})}
+}
+
+func (g *labelGuard) value(v string) string {
+	if v == "" {
+		return labelNone
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[v]; ok {
+		return v
+	}
+	if len(g.seen) >= g.max {
+		return labelOther
+	}
+	g.seen[v] = struct{}{}
+	return v
+}
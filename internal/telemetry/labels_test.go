@@ -0,0 +1,18 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelGuard(t *testing.T) {
+	guard := newLabelGuard(2)
+
+	assert.Equal(t, "none", guard.value(""))
+	assert.Equal(t, "acme", guard.value("acme"))
+	assert.Equal(t, "globex", guard.value("globex"))
+	assert.Equal(t, "other", guard.value("initech"))
+	// values admitted before the limit keep their label
+	assert.Equal(t, "acme", guard.value("acme"))
+}
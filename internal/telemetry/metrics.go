@@ -0,0 +1,567 @@
+package telemetry
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "sendpulse"
+
+// Results of a consumed message create event
+const (
+	IngestEnqueued     = "enqueued"
+	IngestDeadLettered = "dead_lettered"
+)
+
+// Results of a received delivery report
+const (
+	DeliveryReportApplied = "applied"
+	DeliveryReportIgnored = "ignored"
+)
+
+var (
+	// PendingMessages is the number of messages waiting to be sent
+	PendingMessages = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_messages",
+		Help:      "Number of messages waiting to be sent.",
+	})
+
+	// OldestPendingAge is the age of the oldest pending message, 0 when nothing is pending
+	OldestPendingAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "oldest_pending_message_age_seconds",
+		Help:      "Age of the oldest pending message in seconds, 0 when nothing is pending.",
+	})
+
+	// InFlightSends is the number of webhook sends currently in progress
+	InFlightSends = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "inflight_sends",
+		Help:      "Number of webhook sends currently in progress.",
+	})
+
+	// DatabaseUp is 1 while the database is reachable and 0 during an outage
+	DatabaseUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "database_up",
+		Help:      "Whether the database is reachable, 1 when it is and 0 during an outage.",
+	})
+
+	// RegionActive is 1 while the region of the instance is active and 0 while it is passive, by region
+	RegionActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "region_active",
+		Help:      "Whether the region of the instance is active, 1 when its scheduler claims messages and 0 while it is passive.",
+	}, []string{"region"})
+
+	// MessagesProcessed counts the messages the scheduler finished, by resulting status, tenant and campaign
+	MessagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_processed_total",
+		Help:      "Number of messages processed by the scheduler, by resulting status, tenant and campaign.",
+	}, []string{"status", "tenant", "campaign"})
+
+	// WebhookSendDuration is the time a webhook send took including retries, by resulting status
+	WebhookSendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_send_duration_seconds",
+		Help:      "Time a webhook send took including retries, by resulting status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"status"})
+
+	// Panics counts recovered panics, by component
+	Panics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "panics_total",
+		Help:      "Number of recovered panics, by component.",
+	}, []string{"component"})
+
+	// IngestedEvents counts the message create events read from streams, by source and result
+	IngestedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "ingested_events_total",
+		Help:      "Number of message create events consumed from streams, by source and result.",
+	}, []string{"source", "result"})
+
+	// DeliveryReports counts the delivery reports received from the provider, by reported status and result
+	DeliveryReports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "delivery_reports_total",
+		Help:      "Number of delivery reports received, by reported status and result.",
+	}, []string{"status", "result"})
+
+	// UnconfirmedMessages counts the accepted messages no delivery report arrived for in time
+	UnconfirmedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "unconfirmed_messages_total",
+		Help:      "Number of accepted messages marked unconfirmed because no delivery report arrived in time.",
+	})
+
+	// PolicyViolations counts the content policy rules enqueued messages violated, by rule and action
+	PolicyViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "policy_violations_total",
+		Help:      "Number of content policy violations of enqueued messages, by rule and action.",
+	}, []string{"rule", "action"})
+
+	// LinkClicks counts the clicks on tracked short links
+	LinkClicks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "link_clicks_total",
+		Help:      "Number of clicks on tracked short links.",
+	})
+
+	// ReplayedMessages counts the clones enqueued by replays of sent messages
+	ReplayedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "replayed_messages_total",
+		Help:      "Number of sent messages cloned and enqueued again by replays.",
+	})
+
+	// BatchOverlaps counts the scheduler ticks that fired while a batch was still running, by what was done
+	BatchOverlaps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "batch_overlaps_total",
+		Help:      "Number of scheduler ticks that fired while a batch was still running, by action (skipped, queued, concurrent).",
+	}, []string{"action"})
+
+	// StaleStatusUpdates counts the status updates of claimed messages that found them no longer sending
+	StaleStatusUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "stale_status_updates_total",
+		Help:      "Number of status updates of claimed messages refused because they were no longer sending, a sign of double processing or manual changes, by the status they were to be moved to and the one they were in.",
+	}, []string{"status", "current"})
+
+	// LifecycleEvents counts the message lifecycle events published onto the event bus, by type
+	LifecycleEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "lifecycle_events_total",
+		Help:      "Number of message lifecycle events published onto the event bus, by type.",
+	}, []string{"type"})
+
+	// DroppedEvents counts the lifecycle events dropped for a subscriber of the event bus falling behind
+	DroppedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "dropped_events_total",
+		Help:      "Number of lifecycle events dropped for an event bus subscriber whose buffer was full, by subscriber.",
+	}, []string{"subscriber"})
+
+	// SendFailures counts the failed sends by failure class, see webhook.ClassifyError
+	SendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "send_failures_total",
+		Help:      "Number of messages failed after their retries, by failure class.",
+	}, []string{"class"})
+
+	// FailureRequeues counts the failed messages the failure_requeue job requeued or dead-lettered, by action
+	FailureRequeues = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "failure_requeues_total",
+		Help:      "Number of failed messages requeued or moved to the dead-letter queue by the failure_requeue job, by action.",
+	}, []string{"action"})
+
+	// DNSLookups counts the lookups of the provider hosts through the DNS cache, by result
+	DNSLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "dns_lookups_total",
+		Help:      "Number of provider host lookups through the DNS cache, by result (cached, resolved, stale or failed).",
+	}, []string{"result"})
+
+	// CampaignSteps counts the steps of the campaign approval workflow, by step
+	CampaignSteps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "campaign_steps_total",
+		Help:      "Number of steps taken in the campaign approval workflow, by step (created, submitted, approved, rejected or launched).",
+	}, []string{"step"})
+
+	// ProviderHealthScore is the health score of a provider, between routing.health.min_score and 1, by provider
+	ProviderHealthScore = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_health_score",
+		Help:      "Health score of a provider from the success rate and latency of its recent sends, 1 when it is healthy.",
+	}, []string{"provider"})
+
+	// RoutingDecisions counts the messages routed to a provider, by route prefix and provider
+	RoutingDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "routing_decisions_total",
+		Help:      "Number of messages routed to a provider, by route prefix (empty for the default route) and provider.",
+	}, []string{"route", "provider"})
+
+	// ContentTransforms counts the messages whose content was rewritten by a transform pipeline, by pipeline
+	ContentTransforms = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "content_transforms_total",
+		Help:      "Number of messages whose content was rewritten by a transform pipeline when claimed, by pipeline.",
+	}, []string{"pipeline"})
+
+	// CoalescedReads counts the reads that shared the result of an identical read in flight, by operation
+	CoalescedReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "coalesced_reads_total",
+		Help:      "Number of stats, list and time series reads served by an identical read in flight, by operation.",
+	}, []string{"operation"})
+
+	// MaintenanceRuns counts the runs of the maintenance jobs, by job and status
+	MaintenanceRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "maintenance_runs_total",
+		Help:      "Number of maintenance job runs, by job and status (succeeded or failed).",
+	}, []string{"job", "status"})
+
+	// MaintenanceRunDuration is the time a maintenance job run took, by job
+	MaintenanceRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "maintenance_run_duration_seconds",
+		Help:      "Time a maintenance job run took, by job.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	// RequestTimeouts counts the API requests answered with 504 because they exceeded their timeout, by route
+	RequestTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "request_timeouts_total",
+		Help:      "Number of API requests that exceeded their timeout, by route.",
+	}, []string{"route"})
+
+	// LoadShedding is 1 while the low-priority reads are shed and 0 otherwise
+	LoadShedding = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "load_shedding",
+		Help:      "Whether stats and export requests are shed because the database is under load, 1 when they are and 0 otherwise.",
+	})
+
+	// DatabasePingLatency is the latency of the last database ping of the load shedding check
+	DatabasePingLatency = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "database_ping_latency_seconds",
+		Help:      "Latency of the last database ping of the load shedding check in seconds.",
+	})
+
+	// DatabasePoolSaturation is the share of the database connection limit in use at the last load shedding check
+	DatabasePoolSaturation = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "database_pool_saturation",
+		Help:      "Share of the database connection limit in use at the last load shedding check, 0 without a limit.",
+	})
+
+	// ShedRequests counts the API requests answered with 503 while shedding load, by route
+	ShedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "shed_requests_total",
+		Help:      "Number of API requests answered with 503 while the database was under load, by route.",
+	}, []string{"route"})
+
+	// StatusNotices counts the status notices posted to the callback URLs of messages, by type and result
+	StatusNotices = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "status_notices_total",
+		Help:      "Number of status notices posted to the callback URLs of messages, by type and result (delivered or dropped).",
+	}, []string{"type", "result"})
+
+	// QuotaWarnings counts the quota warning thresholds reached by API keys and tenants, by scope and period
+	QuotaWarnings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "quota_warnings_total",
+		Help:      "Number of quota warning thresholds reached by API keys and tenants, by scope and period.",
+	}, []string{"scope", "period"})
+
+	// IngestionJobs counts the finished asynchronous ingestion jobs, by status
+	IngestionJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "ingestion_jobs_total",
+		Help:      "Number of finished asynchronous ingestion jobs, by status (succeeded or failed).",
+	}, []string{"status"})
+
+	// Jobs counts the finished background jobs, by kind and status
+	Jobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "jobs_total",
+		Help:      "Number of finished background jobs, by kind (e.g. export) and status (succeeded or failed).",
+	}, []string{"kind", "status"})
+
+	// ReplicaReads counts the reads sent to the read replica, by operation and the database that answered
+	ReplicaReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "replica_reads_total",
+		Help:      "Number of list reads sent to the read replica, by operation and the database answering them (replica or primary).",
+	}, []string{"operation", "source"})
+
+	inFlightSends atomic.Int64
+)
+
+// MetricsHandler serves the registered metrics in the Prometheus text format
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// SetQueueMetrics updates the pending count and oldest pending age gauges
+func SetQueueMetrics(pending int, oldestPendingAge time.Duration) {
+	PendingMessages.Set(float64(pending))
+	OldestPendingAge.Set(oldestPendingAge.Seconds())
+
+	emitGauge("pending_messages", float64(pending))
+	emitGauge("oldest_pending_message_age_seconds", oldestPendingAge.Seconds())
+}
+
+// AddInFlightSends adjusts the in-flight sends gauge by delta
+func AddInFlightSends(delta int64) {
+	current := inFlightSends.Add(delta)
+	InFlightSends.Set(float64(current))
+
+	emitGauge("inflight_sends", float64(current))
+}
+
+// SetDatabaseUp updates the database availability gauge
+func SetDatabaseUp(up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	DatabaseUp.Set(value)
+
+	emitGauge("database_up", value)
+}
+
+// SetRegionActive updates the region role gauge
+func SetRegionActive(region string, active bool) {
+	value := 0.0
+	if active {
+		value = 1
+	}
+	RegionActive.WithLabelValues(region).Set(value)
+
+	emitGauge("region_active", value, "region:"+region)
+}
+
+// ObserveSend records a finished webhook send and the status the message ended up in.
+// The send duration is not labeled by tenant and campaign to keep the number of histogram series low.
+func ObserveSend(status string, labels MessageLabels, duration time.Duration) {
+	tenant, campaign := labels.guarded()
+	MessagesProcessed.WithLabelValues(status, tenant, campaign).Inc()
+	WebhookSendDuration.WithLabelValues(status).Observe(duration.Seconds())
+
+	emitCount("messages_processed", 1, "status:"+status, "tenant:"+tenant, "campaign:"+campaign)
+	emitTiming("webhook_send_duration", duration, "status:"+status)
+}
+
+// RecordPanic counts a recovered panic in component
+func RecordPanic(component string) {
+	Panics.WithLabelValues(component).Inc()
+
+	emitCount("panics", 1, "component:"+component)
+}
+
+// ObserveIngest counts a consumed message create event
+func ObserveIngest(source, result string) {
+	IngestedEvents.WithLabelValues(source, result).Inc()
+
+	emitCount("ingested_events", 1, "source:"+source, "result:"+result)
+}
+
+// ObserveDeliveryReport counts a received delivery report
+func ObserveDeliveryReport(status, result string) {
+	DeliveryReports.WithLabelValues(status, result).Inc()
+
+	emitCount("delivery_reports", 1, "status:"+status, "result:"+result)
+}
+
+// AddUnconfirmedMessages counts messages marked unconfirmed
+func AddUnconfirmedMessages(count int) {
+	UnconfirmedMessages.Add(float64(count))
+
+	emitCount("unconfirmed_messages", int64(count))
+}
+
+// ObservePolicyViolation counts a content policy rule a message violated
+func ObservePolicyViolation(rule, action string) {
+	PolicyViolations.WithLabelValues(rule, action).Inc()
+
+	emitCount("policy_violations", 1, "rule:"+rule, "action:"+action)
+}
+
+// RecordLinkClick counts a click on a tracked short link
+func RecordLinkClick() {
+	LinkClicks.Inc()
+
+	emitCount("link_clicks", 1)
+}
+
+// AddReplayedMessages counts clones enqueued by a replay
+func AddReplayedMessages(count int) {
+	ReplayedMessages.Add(float64(count))
+
+	emitCount("replayed_messages", int64(count))
+}
+
+// RecordBatchOverlap counts a tick that fired while a batch was still running
+func RecordBatchOverlap(action string) {
+	BatchOverlaps.WithLabelValues(action).Inc()
+
+	emitCount("batch_overlaps", 1, "action:"+action)
+}
+
+// RecordStaleStatusUpdate counts a refused status update of a message no longer sending, current is empty when
+// the message no longer exists
+func RecordStaleStatusUpdate(status, current string) {
+	if current == "" {
+		current = "deleted"
+	}
+	StaleStatusUpdates.WithLabelValues(status, current).Inc()
+
+	emitCount("stale_status_updates", 1, "status:"+status, "current:"+current)
+}
+
+// RecordLifecycleEvent counts a lifecycle event published onto the event bus
+func RecordLifecycleEvent(eventType string) {
+	LifecycleEvents.WithLabelValues(eventType).Inc()
+
+	emitCount("lifecycle_events", 1, "type:"+eventType)
+}
+
+// RecordDroppedEvent counts a lifecycle event dropped for a subscriber falling behind
+func RecordDroppedEvent(subscriber string) {
+	DroppedEvents.WithLabelValues(subscriber).Inc()
+
+	emitCount("dropped_events", 1, "subscriber:"+subscriber)
+}
+
+// RecordSendFailure counts a message failed after its retries with the failure class
+func RecordSendFailure(class string) {
+	SendFailures.WithLabelValues(class).Inc()
+
+	emitCount("send_failures", 1, "class:"+class)
+}
+
+// RecordFailureRequeues counts the failed messages requeued or dead-lettered at once, action is requeued or
+// dead_lettered
+func RecordFailureRequeues(action string, count int) {
+	FailureRequeues.WithLabelValues(action).Add(float64(count))
+
+	emitCount("failure_requeues", int64(count), "action:"+action)
+}
+
+// RecordCampaignStep counts a step of the campaign approval workflow
+func RecordCampaignStep(step string) {
+	CampaignSteps.WithLabelValues(step).Inc()
+
+	emitCount("campaign_steps", 1, "step:"+step)
+}
+
+// SetProviderHealthScore updates the health score gauge of provider
+func SetProviderHealthScore(provider string, score float64) {
+	ProviderHealthScore.WithLabelValues(provider).Set(score)
+
+	emitGauge("provider_health_score", score, "provider:"+provider)
+}
+
+// RecordRoutingDecision counts a message routed to provider through the route of prefix
+func RecordRoutingDecision(prefix, provider string) {
+	RoutingDecisions.WithLabelValues(prefix, provider).Inc()
+
+	emitCount("routing_decisions", 1, "route:"+prefix, "provider:"+provider)
+}
+
+// RecordTransform counts a message whose content was rewritten by pipeline
+func RecordTransform(pipeline string) {
+	ContentTransforms.WithLabelValues(pipeline).Inc()
+
+	emitCount("content_transforms", 1, "pipeline:"+pipeline)
+}
+
+// RecordDNSLookup counts a provider host lookup, result is cached, resolved, stale or failed
+func RecordDNSLookup(result string) {
+	DNSLookups.WithLabelValues(result).Inc()
+
+	emitCount("dns_lookups", 1, "result:"+result)
+}
+
+// RecordCoalescedRead counts a read that shared the result of an identical read in flight
+func RecordCoalescedRead(operation string) {
+	CoalescedReads.WithLabelValues(operation).Inc()
+
+	emitCount("coalesced_reads", 1, "operation:"+operation)
+}
+
+// RecordMaintenanceRun records a finished maintenance job run
+func RecordMaintenanceRun(job string, succeeded bool, duration time.Duration) {
+	status := "succeeded"
+	if !succeeded {
+		status = "failed"
+	}
+	MaintenanceRuns.WithLabelValues(job, status).Inc()
+	MaintenanceRunDuration.WithLabelValues(job).Observe(duration.Seconds())
+
+	emitCount("maintenance_runs", 1, "job:"+job, "status:"+status)
+	emitTiming("maintenance_run_duration", duration, "job:"+job)
+}
+
+// RecordIngestionJob counts a finished ingestion job
+func RecordIngestionJob(status string) {
+	IngestionJobs.WithLabelValues(status).Inc()
+
+	emitCount("ingestion_jobs", 1, "status:"+status)
+}
+
+// RecordJob counts a finished background job
+func RecordJob(kind, status string) {
+	Jobs.WithLabelValues(kind, status).Inc()
+
+	emitCount("jobs", 1, "kind:"+kind, "status:"+status)
+}
+
+// RecordReplicaRead counts a read sent to the read replica, source is the database that answered it
+func RecordReplicaRead(operation, source string) {
+	ReplicaReads.WithLabelValues(operation, source).Inc()
+
+	emitCount("replica_reads", 1, "operation:"+operation, "source:"+source)
+}
+
+// RecordRequestTimeout counts a request of route that exceeded its timeout
+func RecordRequestTimeout(route string) {
+	RequestTimeouts.WithLabelValues(route).Inc()
+
+	emitCount("request_timeouts", 1, "route:"+route)
+}
+
+// SetLoadShedding updates the load shedding gauges with the state and the database measurements it is based on
+func SetLoadShedding(shedding bool, latency time.Duration, saturation float64) {
+	value := 0.0
+	if shedding {
+		value = 1
+	}
+	LoadShedding.Set(value)
+	DatabasePingLatency.Set(latency.Seconds())
+	DatabasePoolSaturation.Set(saturation)
+
+	emitGauge("load_shedding", value)
+	emitGauge("database_ping_latency_seconds", latency.Seconds())
+	emitGauge("database_pool_saturation", saturation)
+}
+
+// RecordShedRequest counts a request of route answered with 503 while shedding load
+func RecordShedRequest(route string) {
+	ShedRequests.WithLabelValues(route).Inc()
+
+	emitCount("shed_requests", 1, "route:"+route)
+}
+
+// RecordStatusNotice counts a status notice of noticeType delivered to or dropped by the callback URL of a message
+func RecordStatusNotice(noticeType, result string) {
+	StatusNotices.WithLabelValues(noticeType, result).Inc()
+
+	emitCount("status_notices", 1, "type:"+noticeType, "result:"+result)
+}
+
+// RecordQuotaWarning counts a quota warning threshold reached by an API key or tenant
+func RecordQuotaWarning(scope, period string) {
+	QuotaWarnings.WithLabelValues(scope, period).Inc()
+
+	emitCount("quota_warnings", 1, "scope:"+scope, "period:"+period)
+}
@@ -0,0 +1,165 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/getsentry/sentry-go"
+)
+
+const (
+	// sentryFlushTimeout is the maximum time to wait for buffered events on shutdown
+	sentryFlushTimeout = 2 * time.Second
+	// sentrySampleInterval is how often CaptureSampled reports the errors of a group
+	sentrySampleInterval = time.Minute
+)
+
+var (
+	// phoneNumberPattern matches E.164 numbers which are masked before events leave the process
+	phoneNumberPattern = regexp.MustCompile(`\+[1-9]\d{6,14}`)
+	// sensitiveHeaders are removed from the request of every event
+	sensitiveHeaders = []string{"Authorization", "X-Api-Key", "X-API-Key", "Cookie"}
+)
+
+// SetupSentry initializes Sentry when a DSN is configured, the returned function flushes buffered events.
+// Without a DSN every capture function is a no-op.
+func SetupSentry(cfg *config.Cfg) (func(), error) {
+	if cfg.Sentry.DSN == "" {
+		return func() {}, nil
+	}
+
+	environment := cfg.Sentry.Environment
+	if environment == "" {
+		environment = string(cfg.Server.Mode)
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.Sentry.DSN,
+		Environment: environment,
+		Release:     fmt.Sprintf("%s@%s", cfg.AppName, config.Version),
+		BeforeSend:  scrubEvent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initializing sentry: %w", err)
+	}
+
+	config.Log().Infof("Reporting errors to Sentry (environment: %s)", environment)
+	return func() { sentry.Flush(sentryFlushTimeout) }, nil
+}
+
+// CaptureError reports err to Sentry with the given tags, the trace ID of ctx is attached when present
+func CaptureError(ctx context.Context, err error, tags map[string]string) {
+	if err == nil {
+		return
+	}
+
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		if fields := LogFields(ctx); len(fields) > 0 {
+			scope.SetTag("trace_id", fmt.Sprint(fields["trace_id"]))
+		}
+		sentry.CaptureException(err)
+	})
+}
+
+// CaptureSampled reports err like CaptureError, grouped into a single Sentry issue by group and at most once per
+// sentrySampleInterval per group, so a provider outage failing every send does not flood Sentry with an event per
+// message. The errors of the group left out since the last report are attached as the skipped tag.
+func CaptureSampled(ctx context.Context, err error, group []string, tags map[string]string) {
+	if err == nil {
+		return
+	}
+	report, skipped := errorSamples.sample(strings.Join(group, "/"), time.Now())
+	if !report {
+		return
+	}
+
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		scope.SetTag("skipped", strconv.Itoa(skipped))
+		scope.SetFingerprint(group)
+		if fields := LogFields(ctx); len(fields) > 0 {
+			scope.SetTag("trace_id", fmt.Sprint(fields["trace_id"]))
+		}
+		sentry.CaptureException(err)
+	})
+}
+
+// errorSamples are the groups of CaptureSampled
+var errorSamples = &sampler{interval: sentrySampleInterval, groups: make(map[string]*sampledGroup)}
+
+// sampler lets an error of a group through once per interval and counts the ones left out in between
+type sampler struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	groups map[string]*sampledGroup
+}
+
+type sampledGroup struct {
+	reportedAt time.Time
+	skipped    int
+}
+
+// sample reports whether an error of group seen at now is reported and how many were left out before it
+func (s *sampler) sample(group string, now time.Time) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.groups[group]
+	if !ok {
+		s.groups[group] = &sampledGroup{reportedAt: now}
+		return true, 0
+	}
+	if now.Sub(g.reportedAt) < s.interval {
+		g.skipped++
+		return false, 0
+	}
+	skipped := g.skipped
+	g.reportedAt, g.skipped = now, 0
+	return true, skipped
+}
+
+// CapturePanic reports a recovered panic value to Sentry
+func CapturePanic(ctx context.Context, recovered any, tags map[string]string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		scope.SetTags(tags)
+		scope.SetLevel(sentry.LevelFatal)
+		if fields := LogFields(ctx); len(fields) > 0 {
+			scope.SetTag("trace_id", fmt.Sprint(fields["trace_id"]))
+		}
+		sentry.CurrentHub().Recover(recovered)
+	})
+}
+
+// scrubEvent removes credentials, request bodies and phone numbers from events
+func scrubEvent(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	if event.Request != nil {
+		for _, header := range sensitiveHeaders {
+			delete(event.Request.Headers, header)
+		}
+		event.Request.Cookies = ""
+		event.Request.Data = ""
+	}
+
+	event.Message = maskPhoneNumbers(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = maskPhoneNumbers(event.Exception[i].Value)
+	}
+	for _, breadcrumb := range event.Breadcrumbs {
+		breadcrumb.Message = maskPhoneNumbers(breadcrumb.Message)
+	}
+	event.User = sentry.User{}
+
+	return event
+}
+
+func maskPhoneNumbers(s string) string {
+	return phoneNumberPattern.ReplaceAllString(s, "[phone]")
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubEvent(t *testing.T) {
+	event := &sentry.Event{
+		Message: "Failed to send message to +905551234567",
+		Request: &sentry.Request{
+			Headers: map[string]string{
+				"Authorization": "Bearer secret",
+				"X-Api-Key":     "secret",
+				"Content-Type":  "application/json",
+			},
+			Cookies: "session=secret",
+			Data:    `{"to": "+905551234567", "content": "Your code is 1234"}`,
+		},
+		Exception: []sentry.Exception{
+			{Value: "invalid recipient +4915112345678"},
+		},
+		Breadcrumbs: []*sentry.Breadcrumb{
+			{Message: "claimed message for +905551234567"},
+		},
+		User: sentry.User{IPAddress: "10.0.0.1"},
+	}
+
+	scrubbed := scrubEvent(event, nil)
+
+	assert.Equal(t, "Failed to send message to [phone]", scrubbed.Message)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, scrubbed.Request.Headers)
+	assert.Empty(t, scrubbed.Request.Cookies)
+	assert.Empty(t, scrubbed.Request.Data)
+	assert.Equal(t, "invalid recipient [phone]", scrubbed.Exception[0].Value)
+	assert.Equal(t, "claimed message for [phone]", scrubbed.Breadcrumbs[0].Message)
+	assert.Empty(t, scrubbed.User.IPAddress)
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{interval: time.Minute, groups: make(map[string]*sampledGroup)}
+	now := time.Now()
+
+	report, skipped := s.sample("webhook/acme/provider_temporary", now)
+	assert.True(t, report, "the first error of a group is reported")
+	assert.Zero(t, skipped)
+
+	for i := 1; i <= 3; i++ {
+		report, _ = s.sample("webhook/acme/provider_temporary", now.Add(time.Duration(i)*time.Second))
+		assert.False(t, report, "errors of the group are left out within the interval")
+	}
+	report, _ = s.sample("webhook/other/provider_temporary", now.Add(time.Second))
+	assert.True(t, report, "groups are sampled on their own")
+
+	report, skipped = s.sample("webhook/acme/provider_temporary", now.Add(time.Minute))
+	assert.True(t, report)
+	assert.Equal(t, 3, skipped, "the errors left out are counted")
+}
@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+)
+
+// statsd is the active StatsD emitter, nil when no address is configured
+var statsd atomic.Pointer[statsdClient]
+
+// statsdClient pushes metrics over UDP in the DogStatsD format, plain StatsD servers ignore the tags
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+// SetupStatsD starts pushing every metric to the configured StatsD/DogStatsD address next to
+// the Prometheus endpoint, the returned function stops it. Without an address it is a no-op.
+func SetupStatsD(cfg *config.Cfg) (func(), error) {
+	if cfg.StatsD.Address == "" {
+		return func() {}, nil
+	}
+
+	conn, err := net.Dial("udp", cfg.StatsD.Address)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to statsd: %w", err)
+	}
+
+	statsd.Store(&statsdClient{conn: conn, prefix: cfg.StatsD.Prefix, tags: cfg.StatsD.Tags})
+	config.Log().Infof("Pushing metrics to StatsD at %s", cfg.StatsD.Address)
+
+	return func() {
+		statsd.Store(nil)
+		conn.Close()
+	}, nil
+}
+
+func emitGauge(name string, value float64, tags ...string) {
+	if client := statsd.Load(); client != nil {
+		client.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+	}
+}
+
+func emitCount(name string, value int64, tags ...string) {
+	if client := statsd.Load(); client != nil {
+		client.send(name, strconv.FormatInt(value, 10), "c", tags)
+	}
+}
+
+func emitTiming(name string, duration time.Duration, tags ...string) {
+	if client := statsd.Load(); client != nil {
+		client.send(name, strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+	}
+}
+
+// send writes a single metric, metrics are best effort so write errors are ignored
+func (s *statsdClient) send(name, value, kind string, tags []string) {
+	var line strings.Builder
+	line.WriteString(s.prefix)
+	line.WriteString(name)
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(kind)
+
+	if all := append(append([]string{}, s.tags...), tags...); len(all) > 0 {
+		line.WriteString("|#")
+		line.WriteString(strings.Join(all, ","))
+	}
+
+	_, _ = s.conn.Write([]byte(line.String()))
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsD(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	cfg := &config.Cfg{StatsD: config.StatsD{
+		Address: listener.LocalAddr().String(),
+		Prefix:  "sendpulse.",
+		Tags:    []string{"env:test"},
+	}}
+	stop, err := SetupStatsD(cfg)
+	require.NoError(t, err)
+	defer stop()
+
+	read := func() string {
+		buf := make([]byte, 512)
+		require.NoError(t, listener.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	SetQueueMetrics(12, 90*time.Second)
+	assert.Equal(t, "sendpulse.pending_messages:12|g|#env:test", read())
+	assert.Equal(t, "sendpulse.oldest_pending_message_age_seconds:90|g|#env:test", read())
+
+	ObserveSend("sent", MessageLabels{Tenant: "acme"}, 250*time.Millisecond)
+	assert.Equal(t, "sendpulse.messages_processed:1|c|#env:test,status:sent,tenant:acme,campaign:none", read())
+	assert.Equal(t, "sendpulse.webhook_send_duration:250|ms|#env:test,status:sent", read())
+}
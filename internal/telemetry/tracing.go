@@ -0,0 +1,88 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans created by sendpulse
+const TracerName = "github.com/boratanrikulu/sendpulse"
+
+// Tracer returns the sendpulse tracer of the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// SetupTracing installs the W3C trace context propagator and, when tracing is enabled,
+// a tracer provider exporting over OTLP/HTTP. The returned function flushes and stops the exporter.
+func SetupTracing(ctx context.Context, cfg *config.Cfg) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Tracing.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint)}
+	if cfg.Tracing.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.AppName),
+		semconv.ServiceVersion(config.Version),
+		semconv.DeploymentEnvironmentName(string(cfg.Server.Mode)),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	config.Log().Infof("Exporting traces to %s (sample ratio: %g)", cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// RecordError marks the span as failed, nil errors are ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// LogFields returns the trace and span IDs of the span in ctx as log fields, empty without a recording span
+func LogFields(ctx context.Context) logrus.Fields {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{
+		"trace_id": spanContext.TraceID().String(),
+		"span_id":  spanContext.SpanID().String(),
+	}
+}
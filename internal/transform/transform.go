@@ -0,0 +1,182 @@
+// Package transform renders the content of messages when they are claimed by the transform pipeline of their
+// campaign or tenant, e.g. stripping emoji so a message fits GSM-7 or appending an opt-out footer.
+package transform
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/links"
+)
+
+// Transformer is a single step of a pipeline. Transform returns content rewritten and the short links it
+// created, which are stored and attached to the message by the caller.
+type Transformer interface {
+	Transform(content string) (string, []*db.Link, error)
+}
+
+// TransformerFunc is a transformer creating no links
+type TransformerFunc func(content string) string
+
+func (f TransformerFunc) Transform(content string) (string, []*db.Link, error) {
+	return f(content), nil, nil
+}
+
+// Pipeline applies its transformers in order
+type Pipeline struct {
+	Name         string
+	transformers []Transformer
+}
+
+// NewPipeline creates a pipeline named name applying transformers in order
+func NewPipeline(name string, transformers ...Transformer) *Pipeline {
+	return &Pipeline{Name: name, transformers: transformers}
+}
+
+// Render returns content rendered by every transformer of the pipeline and the short links they created
+func (p *Pipeline) Render(content string) (string, []*db.Link, error) {
+	var created []*db.Link
+	for _, transformer := range p.transformers {
+		rendered, transformerLinks, err := transformer.Transform(content)
+		if err != nil {
+			return "", nil, err
+		}
+		content = rendered
+		created = append(created, transformerLinks...)
+	}
+	return content, created, nil
+}
+
+// Pipelines resolves the pipeline rendering the messages of a campaign or tenant
+type Pipelines struct {
+	campaigns map[string]*Pipeline
+	tenants   map[string]*Pipeline
+	// fallback renders the messages without an assignment, nil sends them as enqueued
+	fallback *Pipeline
+}
+
+// New builds the pipelines and assignments of cfg, short links are created under linkTracking.base_url. The
+// configuration is validated by config.Load, unknown step types are skipped.
+func New(cfg config.Transforms, linkTracking config.LinkTracking) *Pipelines {
+	named := make(map[string]*Pipeline, len(cfg.Pipelines))
+	for _, pipeline := range cfg.Pipelines {
+		compiled := NewPipeline(pipeline.Name)
+		for _, step := range pipeline.Steps {
+			if transformer := newTransformer(step, linkTracking); transformer != nil {
+				compiled.transformers = append(compiled.transformers, transformer)
+			}
+		}
+		named[pipeline.Name] = compiled
+	}
+
+	p := &Pipelines{
+		campaigns: make(map[string]*Pipeline),
+		tenants:   make(map[string]*Pipeline),
+		fallback:  named[cfg.DefaultPipeline],
+	}
+	for _, assignment := range cfg.Assignments {
+		if assignment.Campaign != "" {
+			p.campaigns[assignment.Campaign] = named[assignment.Pipeline]
+		} else if assignment.Tenant != "" {
+			p.tenants[assignment.Tenant] = named[assignment.Pipeline]
+		}
+	}
+	return p
+}
+
+// Of returns the pipeline rendering the messages of tenant and campaign, nil when they are sent as enqueued
+func (p *Pipelines) Of(tenant, campaign string) *Pipeline {
+	if campaign != "" {
+		if pipeline, ok := p.campaigns[campaign]; ok {
+			return pipeline
+		}
+	}
+	if tenant != "" {
+		if pipeline, ok := p.tenants[tenant]; ok {
+			return pipeline
+		}
+	}
+	return p.fallback
+}
+
+func newTransformer(step config.TransformStep, linkTracking config.LinkTracking) Transformer {
+	switch step.Type {
+	case config.TransformTrim:
+		return TransformerFunc(strings.TrimSpace)
+	case config.TransformStripEmoji:
+		return TransformerFunc(StripEmoji)
+	case config.TransformShortenLinks:
+		return shortenLinks{links.NewShortener(linkTracking)}
+	case config.TransformFooter:
+		return Footer(step.Text, step.Separator)
+	}
+	return nil
+}
+
+// shortenLinks replaces the links with tracked short links, links that already are short links are kept
+type shortenLinks struct {
+	shortener *links.Shortener
+}
+
+func (s shortenLinks) Transform(content string) (string, []*db.Link, error) {
+	return s.shortener.Shorten(content)
+}
+
+// spaceRun and lineEndSpaces match the spaces left in a row and at the end of a line by removed emoji
+var (
+	spaceRun      = regexp.MustCompile(` {2,}`)
+	lineEndSpaces = regexp.MustCompile(`(?m) +$`)
+)
+
+// StripEmoji removes the emoji of content with their variation selectors, skin tones and joiners, the spaces
+// they leave in a row or at the end of a line are collapsed. Content without emoji is returned as is.
+func StripEmoji(content string) string {
+	if strings.IndexFunc(content, isEmoji) < 0 {
+		return content
+	}
+	stripped := strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, content)
+	return lineEndSpaces.ReplaceAllString(spaceRun.ReplaceAllString(stripped, " "), "")
+}
+
+// emoji are the pictographic symbols, dingbats and their modifiers
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1}, // zero width joiner
+		{Lo: 0x20e3, Hi: 0x20e3, Stride: 1}, // combining enclosing keycap
+		{Lo: 0x2300, Hi: 0x23ff, Stride: 1}, // miscellaneous technical, e.g. watch and hourglass
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1}, // miscellaneous symbols and dingbats
+		{Lo: 0x2b00, Hi: 0x2bff, Stride: 1}, // arrows and stars
+		{Lo: 0xfe00, Hi: 0xfe0f, Stride: 1}, // variation selectors
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1}, // pictographs, emoticons, flags and skin tones
+		{Lo: 0xe0020, Hi: 0xe007f, Stride: 1}, // tags of subdivision flags
+	},
+}
+
+func isEmoji(r rune) bool {
+	return unicode.Is(emoji, r)
+}
+
+// Footer returns a transformer appending text after separator, a space when empty. Content already ending
+// with text, compared case-insensitively, is returned as is, so the footer is never appended twice.
+func Footer(text, separator string) Transformer {
+	if separator == "" {
+		separator = " "
+	}
+	suffix := strings.ToLower(strings.TrimSpace(text))
+	return TransformerFunc(func(content string) string {
+		if strings.HasSuffix(strings.ToLower(strings.TrimSpace(content)), suffix) {
+			return content
+		}
+		return content + separator + text
+	})
+}
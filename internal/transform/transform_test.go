@@ -0,0 +1,75 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/sms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no emoji", "Your code is 1234  ", "Your code is 1234  "},
+		{"between words", "Spring 🌸 sale", "Spring sale"},
+		{"at the end of lines", "Thanks! 🙏\nSee you 👋", "Thanks!\nSee you"},
+		{"sequences", "Family 👨‍👩‍👧 and thumbs 👍🏽 and heart ❤️", "Family and thumbs and heart"},
+		{"flags", "Shipping to 🇹🇷 today", "Shipping to today"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripEmoji(tt.content)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	encoding, _, _ := sms.Encode(StripEmoji("Spring sale 🌸"))
+	assert.Equal(t, sms.EncodingGSM7, encoding, "content sent as UCS-2 only for its emoji fits GSM-7")
+}
+
+func TestFooter(t *testing.T) {
+	footer := Footer("Reply STOP to opt out", "")
+
+	rendered, _, err := footer.Transform("Spring sale")
+	require.NoError(t, err)
+	assert.Equal(t, "Spring sale Reply STOP to opt out", rendered)
+
+	rendered, _, err = footer.Transform("Spring sale, reply stop to opt out ")
+	require.NoError(t, err)
+	assert.Equal(t, "Spring sale, reply stop to opt out ", rendered, "the footer is not appended twice")
+}
+
+func TestPipelines(t *testing.T) {
+	pipelines := New(config.Transforms{
+		Pipelines: []config.TransformPipeline{
+			{Name: "marketing", Steps: []config.TransformStep{
+				{Type: config.TransformTrim},
+				{Type: config.TransformShortenLinks},
+				{Type: config.TransformFooter, Text: "Reply STOP to opt out"},
+			}},
+			{Name: "signed", Steps: []config.TransformStep{{Type: config.TransformFooter, Text: "- Acme", Separator: "\n"}}},
+			{Name: "plain", Steps: []config.TransformStep{{Type: config.TransformTrim}}},
+		},
+		Assignments: []config.TransformAssignment{
+			{Campaign: "spring", Pipeline: "marketing"},
+			{Tenant: "acme", Pipeline: "signed"},
+		},
+		DefaultPipeline: "plain",
+	}, config.LinkTracking{BaseURL: "https://sp.example.com/l"})
+
+	assert.Equal(t, "marketing", pipelines.Of("acme", "spring").Name, "a campaign's assignment wins over its tenant's")
+	assert.Equal(t, "signed", pipelines.Of("acme", "autumn").Name)
+	assert.Equal(t, "plain", pipelines.Of("", "").Name)
+	assert.Nil(t, New(config.Transforms{}, config.LinkTracking{}).Of("acme", "spring"))
+
+	rendered, links, err := pipelines.Of("", "spring").Render(" Sale at https://shop.example.com/sale. ")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://shop.example.com/sale", links[0].URL)
+	assert.Equal(t, "Sale at https://sp.example.com/l/"+links[0].Code+". Reply STOP to opt out", rendered)
+}
@@ -0,0 +1,813 @@
+// Package client is a typed Go client for the SendPulse REST API.
+//
+//	c := client.New("https://sendpulse.example.com", client.WithAPIKey(os.Getenv("SENDPULSE_API_KEY")))
+//	response, err := c.CreateMessage(ctx, &client.CreateMessageRequest{To: "+905551234567", Content: "Hello"})
+//
+// Requests that fail with a network error, 429, 502, 503 or 504 are retried with exponential backoff.
+// Requests that are not safe to repeat, like creating a message, are only retried on 429 and 503, which the
+// server returns before doing anything.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader is the header the API key is sent in
+const APIKeyHeader = "X-API-Key"
+
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultMaxRetries = 2
+	DefaultRetryDelay = 500 * time.Millisecond
+	// maxRetryDelay caps the backoff and the Retry-After header
+	maxRetryDelay = 30 * time.Second
+)
+
+// APIError is returned for responses with a non-2xx status
+type APIError struct {
+	StatusCode int
+	// Message is the message of the error response, empty when the body was not one
+	Message string
+	// Code is the machine-readable code of the error response, e.g. dto.CodeMessageNotFound, or of a
+	// messaging control response
+	Code string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("api returned status: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("api returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// HasCode reports whether err is an APIError with the code, one of the dto.Code constants
+func HasCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// IsNotFound reports whether err is an APIError with status 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client calls the REST API of a SendPulse server, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	retryDelay time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey authenticates the requests with key (server.api_key or one of server.api_keys)
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient sends the requests with httpClient instead of a client with DefaultTimeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries retries a failed request up to maxRetries times, waiting delay before the first retry and
+// doubling it for every further one. 0 disables retries.
+func WithRetries(maxRetries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryDelay = delay
+	}
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		retryDelay: DefaultRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health checks whether the server is up, it does not require an API key
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	response := &HealthResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/health", nil, response)
+}
+
+// ErrorCodes returns the catalog of the codes of the error responses
+func (c *Client) ErrorCodes(ctx context.Context) (*ErrorCodesResponse, error) {
+	response := &ErrorCodesResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/errors", nil, response)
+}
+
+// Schemas lists the JSON Schemas of the request and response bodies, it does not require an API key
+func (c *Client) Schemas(ctx context.Context) (*SchemasResponse, error) {
+	response := &SchemasResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/schemas", nil, response)
+}
+
+// Schema returns the JSON Schema of the request or response named name, e.g. CreateMessageRequest
+func (c *Client) Schema(ctx context.Context, name string) (json.RawMessage, error) {
+	var schema json.RawMessage
+	return schema, c.Do(ctx, http.MethodGet, "/api/v1/schemas/"+url.PathEscape(name)+".json", nil, &schema)
+}
+
+// Stats returns the message counts per status, today's throughput and failure rate
+func (c *Client) Stats(ctx context.Context) (*StatsResponse, error) {
+	response := &StatsResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/stats", nil, response)
+}
+
+// Timeseries returns the messages created, sent and failed per bucket of 1m, 1h, 1d or 1w in [from, to),
+// empty values use the server's defaults
+func (c *Client) Timeseries(ctx context.Context, bucket string, from, to *time.Time) (*TimeseriesResponse, error) {
+	query := url.Values{}
+	if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	response := &TimeseriesResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/messages/stats/timeseries", query), nil, response)
+}
+
+// Duplicates reports the recipients given the same content more than once in [from, to), each message created
+// within window of the one before, examining at most limit recipient and content pairs. Zero values use the
+// server's defaults.
+func (c *Client) Duplicates(ctx context.Context, from, to *time.Time, window time.Duration, limit int) (*DuplicatesResponse, error) {
+	query := url.Values{}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	response := &DuplicatesResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/messages/duplicates", query), nil, response)
+}
+
+// RecipientStats summarizes the messages to phone: the counts by status, the last success and failure, the
+// failure streaks and whether the number is suppressed
+func (c *Client) RecipientStats(ctx context.Context, phone string) (*RecipientStatsResponse, error) {
+	response := &RecipientStatsResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/recipients/"+url.PathEscape(phone)+"/stats", nil, response)
+}
+
+// Usage returns the messages created per API key and tenant with their quotas
+func (c *Client) Usage(ctx context.Context) (*UsageResponse, error) {
+	response := &UsageResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/usage", nil, response)
+}
+
+// Limits returns the quotas of the client's API key with the messages remaining and when they reset
+func (c *Client) Limits(ctx context.Context) (*LimitsResponse, error) {
+	response := &LimitsResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/limits", nil, response)
+}
+
+// ListOptions filters and paginates ListMessages, the zero value lists the first page of sent messages
+type ListOptions struct {
+	// Status, From and To filter the messages, From and To by creation time
+	Status string
+	From   *time.Time
+	To     *time.Time
+	// Tag and Metadata match the metadata of the messages
+	Tag      string
+	Metadata map[string]string
+	// DeadLettered lists only the failed messages in the dead-letter queue
+	DeadLettered bool
+	// Acknowledged lists only the messages whose failure was acknowledged when true, the others when false
+	Acknowledged *bool
+	// Page and PageSize default to the server defaults when 0
+	Page     int
+	PageSize int
+	// Sort is -created_at (newest first, the default) or created_at, Cursor the NextCursor of the page before
+	Sort   string
+	Cursor string
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.Status != "" {
+		query.Set("status", o.Status)
+	}
+	setTime(query, "from", o.From)
+	setTime(query, "to", o.To)
+	if o.Tag != "" {
+		query.Set("tag", o.Tag)
+	}
+	for key, value := range o.Metadata {
+		query.Set("metadata."+key, value)
+	}
+	if o.DeadLettered {
+		query.Set("dead_lettered", "true")
+	}
+	if o.Acknowledged != nil {
+		query.Set("acknowledged", strconv.FormatBool(*o.Acknowledged))
+	}
+	if o.Page > 0 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if o.Cursor != "" {
+		query.Set("cursor", o.Cursor)
+	}
+	return query
+}
+
+// ListMessages returns a page of the messages matching opts, newest first unless sorted otherwise
+func (c *Client) ListMessages(ctx context.Context, opts ListOptions) (*MessagesListResponse, error) {
+	response := &MessagesListResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/messages", opts.query()), nil, response)
+}
+
+// GetMessage returns the message with id, IsNotFound reports a missing one
+func (c *Client) GetMessage(ctx context.Context, id int64) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/messages/%d", id), nil, response)
+}
+
+// GetMessageByPublicID returns the message with the ULID or UUID public ID id, IsNotFound reports a missing one
+func (c *Client) GetMessageByPublicID(ctx context.Context, id string) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/messages/"+url.PathEscape(id), nil, response)
+}
+
+// CreateMessage enqueues a message
+func (c *Client) CreateMessage(ctx context.Context, req *CreateMessageRequest) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messages", req, response)
+}
+
+// ValidateMessage validates a message without enqueueing it and returns its encoding and segments
+func (c *Client) ValidateMessage(ctx context.Context, req *CreateMessageRequest) (*ValidateMessageResponse, error) {
+	response := &ValidateMessageResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messages/validate", req, response)
+}
+
+// ReleaseMessage sends a message quarantined by the content policy
+func (c *Client) ReleaseMessage(ctx context.Context, id int64) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/messages/%d/release", id), nil, response)
+}
+
+// PrioritizeMessage moves a pending message to the front of the queue
+func (c *Client) PrioritizeMessage(ctx context.Context, id int64) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/messages/%d/prioritize", id), nil, response)
+}
+
+// CancelMessage cancels a pending message, or a sending one whose webhook call was not issued yet
+func (c *Client) CancelMessage(ctx context.Context, id int64) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/messages/%d/cancel", id), nil, response)
+}
+
+// TriageMessage sets the note of a failed message and acknowledges its failure, or withdraws the acknowledgment.
+// A message that is not failed is an APIError with status 409 and code dto.CodeNotFailed.
+func (c *Client) TriageMessage(ctx context.Context, id int64, req *TriageMessageRequest) (*SingleMessageResponse, error) {
+	response := &SingleMessageResponse{}
+	return response, c.Do(ctx, http.MethodPatch, fmt.Sprintf("/api/v1/messages/%d/triage", id), req, response)
+}
+
+// ReplayMessages clones and enqueues again the messages sent within a window, see ReplayRequest
+func (c *Client) ReplayMessages(ctx context.Context, req *ReplayRequest) (*ReplayResponse, error) {
+	response := &ReplayResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messages/replay", req, response)
+}
+
+// IngestMessages submits messages to be validated and enqueued in the background and returns the ingestion job,
+// follow it with IngestionJob
+func (c *Client) IngestMessages(ctx context.Context, messages []CreateMessageRequest) (*IngestionJobResponse, error) {
+	response := &IngestionJobResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messages/async", messages, response)
+}
+
+// IngestionJob returns an ingestion job and its progress
+func (c *Client) IngestionJob(ctx context.Context, id string) (*IngestionJobResponse, error) {
+	response := &IngestionJobResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/messages/async/"+url.PathEscape(id), nil, response)
+}
+
+// ExportMessages submits an export of the messages matching req to be written in the background and returns
+// the job writing it, follow it with Job and download the file with DownloadJobResult once it succeeded
+func (c *Client) ExportMessages(ctx context.Context, req *ExportRequest) (*JobResponse, error) {
+	response := &JobResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messages/export", req, response)
+}
+
+// Job returns a background job and its progress, exports and ingestions alike
+func (c *Client) Job(ctx context.Context, id string) (*JobResponse, error) {
+	response := &JobResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, response)
+}
+
+// DownloadJobResult copies the output of a succeeded background job, e.g. the file of an export, to w
+func (c *Client) DownloadJobResult(ctx context.Context, id string, w io.Writer) error {
+	return c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id)+"/result", nil, "", w)
+}
+
+// BulkUpdateStatus cancels pending or requeues failed messages in one transaction, with a result per ID
+func (c *Client) BulkUpdateStatus(ctx context.Context, req *BulkStatusRequest) (*BulkStatusResponse, error) {
+	response := &BulkStatusResponse{}
+	return response, c.Do(ctx, http.MethodPatch, "/api/v1/messages/status", req, response)
+}
+
+// MessageLinks returns the tracked links of a message with their clicks
+func (c *Client) MessageLinks(ctx context.Context, id int64) (*MessageLinksResponse, error) {
+	response := &MessageLinksResponse{}
+	return response, c.Do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/messages/%d/links", id), nil, response)
+}
+
+// MessageEvents returns why the scheduler skipped a message, oldest first
+func (c *Client) MessageEvents(ctx context.Context, id int64) (*MessageEventsResponse, error) {
+	response := &MessageEventsResponse{}
+	return response, c.Do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/messages/%d/events", id), nil, response)
+}
+
+// SendAttempts returns the webhook requests of the sends of a message with their timings, oldest first
+func (c *Client) SendAttempts(ctx context.Context, id int64) (*SendAttemptsResponse, error) {
+	response := &SendAttemptsResponse{}
+	return response, c.Do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/messages/%d/attempts", id), nil, response)
+}
+
+// CampaignClicks returns the link clicks per campaign, only of campaign when it is not empty
+func (c *Client) CampaignClicks(ctx context.Context, campaign string) (*CampaignClicksResponse, error) {
+	query := url.Values{}
+	if campaign != "" {
+		query.Set("campaign", campaign)
+	}
+	response := &CampaignClicksResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/clicks", query), nil, response)
+}
+
+// Costs returns the cost of the messages sent in [from, to) grouped by day, campaign or tenant,
+// nil bounds are open
+func (c *Client) Costs(ctx context.Context, groupBy string, from, to *time.Time) (*CostReportResponse, error) {
+	query := url.Values{}
+	if groupBy != "" {
+		query.Set("group_by", groupBy)
+	}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	response := &CostReportResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/costs", query), nil, response)
+}
+
+// DeadLetterTop returns the failure reasons, failure classes, recipient prefixes or providers with the most
+// messages dead-lettered in [from, to), zero limit and prefixDigits use the server defaults, nil bounds are open
+func (c *Client) DeadLetterTop(ctx context.Context, groupBy string, limit, prefixDigits int, from, to *time.Time) (*DeadLetterTopResponse, error) {
+	query := url.Values{}
+	query.Set("group_by", groupBy)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if prefixDigits > 0 {
+		query.Set("prefix_digits", strconv.Itoa(prefixDigits))
+	}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	response := &DeadLetterTopResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/dead-letters/top", query), nil, response)
+}
+
+// DeadLetterHeatmap returns the messages dead-lettered in [from, to) by UTC weekday and hour, nil bounds are open
+func (c *Client) DeadLetterHeatmap(ctx context.Context, from, to *time.Time) (*DeadLetterHeatmapResponse, error) {
+	query := url.Values{}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	response := &DeadLetterHeatmapResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/dead-letters/heatmap", query), nil, response)
+}
+
+// ProviderSLA returns the success rate, latencies and downtime of provider in [from, to), a nil to is now and
+// a nil from 30 days before to
+func (c *Client) ProviderSLA(ctx context.Context, provider string, from, to *time.Time) (*ProviderSLAResponse, error) {
+	query := url.Values{}
+	setTime(query, "from", from)
+	setTime(query, "to", to)
+	response := &ProviderSLAResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/providers/"+url.PathEscape(provider)+"/sla", query), nil, response)
+}
+
+// StartMessaging starts the scheduler of the server, starting a running one is an APIError with status 409
+// and code dto.ControlAlreadyRunning
+func (c *Client) StartMessaging(ctx context.Context) (*MessagingControlResponse, error) {
+	response := &MessagingControlResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messaging/start", nil, response)
+}
+
+// StopMessaging stops the scheduler of the server, stopping a stopped one is an APIError with status 409
+// and code dto.ControlNotRunning
+func (c *Client) StopMessaging(ctx context.Context) (*MessagingControlResponse, error) {
+	response := &MessagingControlResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messaging/stop", nil, response)
+}
+
+// MessagingStatus returns the scheduler status of the server
+func (c *Client) MessagingStatus(ctx context.Context) (*MessagingStatusResponse, error) {
+	response := &MessagingStatusResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/messaging/status", nil, response)
+}
+
+// ForecastOptions replace the messaging settings of Forecast, the zero value forecasts with the server's
+type ForecastOptions struct {
+	Interval  time.Duration
+	BatchSize int
+	// RateLimit limits every route to the messages per second, 0 leaves them unlimited and nil keeps the
+	// server's route rate limits
+	RateLimit *float64
+}
+
+// Forecast estimates when the pending messages of every campaign are sent with the messaging settings of opts
+func (c *Client) Forecast(ctx context.Context, opts ForecastOptions) (*ForecastResponse, error) {
+	query := url.Values{}
+	if opts.Interval > 0 {
+		query.Set("interval", opts.Interval.String())
+	}
+	if opts.BatchSize > 0 {
+		query.Set("batch_size", strconv.Itoa(opts.BatchSize))
+	}
+	if opts.RateLimit != nil {
+		query.Set("rate_limit", strconv.FormatFloat(*opts.RateLimit, 'f', -1, 64))
+	}
+	response := &ForecastResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/messaging/forecast", query), nil, response)
+}
+
+// Egress returns the addresses the server sends to providers from
+func (c *Client) Egress(ctx context.Context) (*EgressResponse, error) {
+	response := &EgressResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/admin/egress", nil, response)
+}
+
+// ReadOnly returns whether the server is read-only
+func (c *Client) ReadOnly(ctx context.Context) (*ReadOnlyResponse, error) {
+	response := &ReadOnlyResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/admin/read-only", nil, response)
+}
+
+// SetReadOnly enables or disables the read-only mode of the server, rejecting writes and pausing messaging
+func (c *Client) SetReadOnly(ctx context.Context, enabled bool) (*ReadOnlyResponse, error) {
+	response := &ReadOnlyResponse{}
+	return response, c.Do(ctx, http.MethodPut, "/api/v1/admin/read-only", &ReadOnlyRequest{Enabled: &enabled}, response)
+}
+
+// Region returns the region of the server and whether it is active or passive
+func (c *Client) Region(ctx context.Context) (*RegionResponse, error) {
+	response := &RegionResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/admin/region", nil, response)
+}
+
+// PromoteRegion makes the region of the server active so its scheduler claims messages
+func (c *Client) PromoteRegion(ctx context.Context) (*RegionResponse, error) {
+	response := &RegionResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/admin/region/promote", nil, response)
+}
+
+// DemoteRegion makes the region of the server passive so its scheduler stops claiming messages
+func (c *Client) DemoteRegion(ctx context.Context) (*RegionResponse, error) {
+	response := &RegionResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/admin/region/demote", nil, response)
+}
+
+// Runtime returns the build, runtime, scheduler internals and redacted configuration of the server
+func (c *Client) Runtime(ctx context.Context) (*RuntimeResponse, error) {
+	response := &RuntimeResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/admin/runtime", nil, response)
+}
+
+// Routing returns the routes of the server, the shares of their providers and the health scores they follow
+func (c *Client) Routing(ctx context.Context) (*RoutingResponse, error) {
+	response := &RoutingResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/admin/routing", nil, response)
+}
+
+// Warmup prepares the server before it takes traffic, opening its database and provider connections. A failed
+// step is an *APIError with status 503.
+func (c *Client) Warmup(ctx context.Context) (*WarmupResponse, error) {
+	response := &WarmupResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/admin/warmup", nil, response)
+}
+
+// ListSuppressions returns a page of the suppressed recipients, 0 uses the server defaults
+func (c *Client) ListSuppressions(ctx context.Context, page, pageSize int) (*SuppressionsListResponse, error) {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+	response := &SuppressionsListResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/suppressions", query), nil, response)
+}
+
+// CreateSuppression suppresses a recipient
+func (c *Client) CreateSuppression(ctx context.Context, req *CreateSuppressionRequest) (*SingleSuppressionResponse, error) {
+	response := &SingleSuppressionResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/suppressions", req, response)
+}
+
+// DeleteSuppression lifts the suppression of phone
+func (c *Client) DeleteSuppression(ctx context.Context, phone string) error {
+	return c.Do(ctx, http.MethodDelete, "/api/v1/suppressions/"+url.PathEscape(phone), nil, nil)
+}
+
+// ImportOptions configure the import of an opt-out list
+type ImportOptions struct {
+	// Format is csv (the default) or text, one number per line
+	Format string
+	// Reason is stored on the numbers without a reason of their own
+	Reason string
+	// DryRun reports what the import would do without suppressing any number
+	DryRun bool
+}
+
+// ImportSuppressions suppresses the numbers of the opt-out list in list, see SuppressionImportResponse. The import
+// can be run again, the numbers it suppressed are skipped then.
+func (c *Client) ImportSuppressions(ctx context.Context, list []byte, opts ImportOptions) (*SuppressionImportResponse, error) {
+	query := url.Values{}
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if opts.Reason != "" {
+		query.Set("reason", opts.Reason)
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	response := &SuppressionImportResponse{}
+	return response, c.do(ctx, http.MethodPost, withQuery("/api/v1/suppressions/import", query), list, "text/csv", response)
+}
+
+// Erase deletes or anonymizes the messages to a phone number, see ErasureRequest
+func (c *Client) Erase(ctx context.Context, req *ErasureRequest) (*ErasureResponse, error) {
+	response := &ErasureResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/erasures", req, response)
+}
+
+// ListErasures returns a page of the erasure audit records, phone limits them to the records of a number
+func (c *Client) ListErasures(ctx context.Context, phone string, page, pageSize int) (*ErasuresListResponse, error) {
+	query := url.Values{}
+	if phone != "" {
+		query.Set("phone", phone)
+	}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+	response := &ErasuresListResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/erasures", query), nil, response)
+}
+
+// ListWebhookOverrides returns the webhooks of tenants and campaigns, without their credentials
+func (c *Client) ListWebhookOverrides(ctx context.Context) (*WebhookOverridesResponse, error) {
+	response := &WebhookOverridesResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/webhooks/overrides", nil, response)
+}
+
+// PutWebhookOverride sends the messages of a tenant or campaign (scope) named name to their own webhook
+func (c *Client) PutWebhookOverride(ctx context.Context, scope, name string, req *WebhookOverrideRequest) (*SingleWebhookOverrideResponse, error) {
+	response := &SingleWebhookOverrideResponse{}
+	return response, c.Do(ctx, http.MethodPut, "/api/v1/webhooks/overrides/"+url.PathEscape(scope)+"/"+url.PathEscape(name), req, response)
+}
+
+// DeleteWebhookOverride sends the messages of a tenant or campaign to webhook.url again
+func (c *Client) DeleteWebhookOverride(ctx context.Context, scope, name string) error {
+	return c.Do(ctx, http.MethodDelete, "/api/v1/webhooks/overrides/"+url.PathEscape(scope)+"/"+url.PathEscape(name), nil, nil)
+}
+
+// ListPauses returns the active pauses of phone numbers and prefix ranges
+func (c *Client) ListPauses(ctx context.Context) (*RecipientPausesResponse, error) {
+	response := &RecipientPausesResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/messaging/pauses", nil, response)
+}
+
+// CreatePause pauses sending to a phone number or prefix range until the pause is deleted or expires
+func (c *Client) CreatePause(ctx context.Context, req *RecipientPauseRequest) (*SingleRecipientPauseResponse, error) {
+	response := &SingleRecipientPauseResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/messaging/pauses", req, response)
+}
+
+// DeletePause lifts the pause of prefix, its messages are sent again
+func (c *Client) DeletePause(ctx context.Context, prefix string) error {
+	return c.Do(ctx, http.MethodDelete, "/api/v1/messaging/pauses/"+url.PathEscape(prefix), nil, nil)
+}
+
+// ListCampaigns returns the campaigns of the approval workflow in status, every campaign when status is empty
+func (c *Client) ListCampaigns(ctx context.Context, status string) (*CampaignsResponse, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	response := &CampaignsResponse{}
+	return response, c.Do(ctx, http.MethodGet, withQuery("/api/v1/campaigns", query), nil, response)
+}
+
+// GetCampaign returns the campaign named name
+func (c *Client) GetCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	response := &SingleCampaignResponse{}
+	return response, c.Do(ctx, http.MethodGet, "/api/v1/campaigns/"+url.PathEscape(name), nil, response)
+}
+
+// CreateCampaign registers a campaign as a draft, its messages are held until it is approved and launched
+func (c *Client) CreateCampaign(ctx context.Context, req *CampaignRequest) (*SingleCampaignResponse, error) {
+	response := &SingleCampaignResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/campaigns", req, response)
+}
+
+// SubmitCampaign submits the draft campaign named name for approval
+func (c *Client) SubmitCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "submit", nil)
+}
+
+// ApproveCampaign approves the campaign named name, it must have been submitted by another API key
+func (c *Client) ApproveCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "approve", nil)
+}
+
+// RejectCampaign sends the campaign named name back to draft with the reason of the rejection
+func (c *Client) RejectCampaign(ctx context.Context, name string, req *CampaignReviewRequest) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "reject", req)
+}
+
+// LaunchCampaign launches the approved campaign named name, its held messages are sent
+func (c *Client) LaunchCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "launch", nil)
+}
+
+// PauseCampaign pauses the launched campaign named name, its pending messages are held
+func (c *Client) PauseCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "pause", nil)
+}
+
+// ResumeCampaign resumes the paused campaign named name, it skips the recipients it sent to already
+func (c *Client) ResumeCampaign(ctx context.Context, name string) (*SingleCampaignResponse, error) {
+	return c.campaignStep(ctx, name, "resume", nil)
+}
+
+func (c *Client) campaignStep(ctx context.Context, name, step string, body any) (*SingleCampaignResponse, error) {
+	response := &SingleCampaignResponse{}
+	return response, c.Do(ctx, http.MethodPost, "/api/v1/campaigns/"+url.PathEscape(name)+"/"+step, body, response)
+}
+
+// Do sends a request to path with body encoded as JSON and decodes a successful response into out,
+// for endpoints without a typed method. body and out may be nil.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+	return c.do(ctx, method, path, payload, "application/json", out)
+}
+
+// do sends payload of contentType to path, retrying as configured, and decodes a successful response into out,
+// or copies it when out is an io.Writer
+func (c *Client) do(ctx context.Context, method, path string, payload []byte, contentType string, out any) error {
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload, contentType)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil {
+				return nil
+			}
+			if w, ok := out.(io.Writer); ok {
+				if _, err := io.Copy(w, resp.Body); err != nil {
+					return fmt.Errorf("failed to read response: %w", err)
+				}
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+			return nil
+		}
+
+		wait := delay
+		if err == nil {
+			err = decodeError(resp)
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+		}
+		if attempt >= c.maxRetries || !retryable(ctx, method, resp) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(min(wait, maxRetryDelay)):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(APIKeyHeader, c.apiKey)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// retryable reports whether a failed request is retried, resp is nil for network errors
+func retryable(ctx context.Context, method string, resp *http.Response) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+	if resp == nil {
+		return idempotent
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// decodeError reads the error response of resp and closes its body
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+	var errResp ErrorResponse
+	_ = json.NewDecoder(resp.Body).Decode(&errResp)
+	return &APIError{StatusCode: resp.StatusCode, Message: errResp.Message, Code: errResp.Code}
+}
+
+// parseRetryAfter parses a Retry-After header in seconds
+func parseRetryAfter(value string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func setTime(query url.Values, name string, t *time.Time) {
+	if t != nil {
+		query.Set(name, t.Format(time.RFC3339))
+	}
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
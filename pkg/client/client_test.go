@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CreateMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/messages", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get(APIKeyHeader))
+
+		var req CreateMessageRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(SingleMessageResponse{Message: Message{ID: 42, To: req.To, Content: req.Content}})
+	}))
+	defer server.Close()
+
+	response, err := New(server.URL, WithAPIKey("secret")).CreateMessage(context.Background(), &CreateMessageRequest{To: "+905551234567", Content: "Hello"})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), response.Message.ID)
+	assert.Equal(t, "Hello", response.Message.Content)
+}
+
+func TestClient_ListMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "failed", r.URL.Query().Get("status"))
+		assert.Equal(t, "2026-10-01T00:00:00Z", r.URL.Query().Get("from"))
+		assert.Equal(t, "5", r.URL.Query().Get("page_size"))
+		assert.Empty(t, r.URL.Query().Get("page"))
+		json.NewEncoder(w).Encode(MessagesListResponse{Total: 7})
+	}))
+	defer server.Close()
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	response, err := New(server.URL).ListMessages(context.Background(), ListOptions{Status: "failed", From: &from, PageSize: 5})
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, response.Total)
+}
+
+func TestClient_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Code: dto.CodeMessageNotFound, Message: "Message not found"})
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).GetMessage(context.Background(), 42)
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Message not found", apiErr.Message)
+	assert.True(t, IsNotFound(err))
+	assert.True(t, HasCode(err, dto.CodeMessageNotFound))
+
+	conflict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(MessagingControlResponse{Code: dto.ControlAlreadyRunning, Message: "Messaging service is already running"})
+	}))
+	defer conflict.Close()
+
+	_, err = New(conflict.URL).StartMessaging(context.Background())
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	assert.Equal(t, dto.ControlAlreadyRunning, apiErr.Code)
+}
+
+func TestClient_Retries(t *testing.T) {
+	respond := func(statuses ...int) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := int(calls.Add(1)) - 1
+			w.WriteHeader(statuses[min(call, len(statuses)-1)])
+			w.Write([]byte(`{}`))
+		}))
+		return server, &calls
+	}
+
+	t.Run("retries reads on gateway errors", func(t *testing.T) {
+		server, calls := respond(http.StatusBadGateway, http.StatusOK)
+		defer server.Close()
+
+		_, err := New(server.URL, WithRetries(2, time.Millisecond)).Stats(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("does not repeat writes the server may have processed", func(t *testing.T) {
+		server, calls := respond(http.StatusBadGateway, http.StatusCreated)
+		defer server.Close()
+
+		_, err := New(server.URL, WithRetries(2, time.Millisecond)).CreateMessage(context.Background(), &CreateMessageRequest{})
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("retries writes the server refused", func(t *testing.T) {
+		server, calls := respond(http.StatusTooManyRequests, http.StatusCreated)
+		defer server.Close()
+
+		_, err := New(server.URL, WithRetries(2, time.Millisecond)).CreateMessage(context.Background(), &CreateMessageRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		server, calls := respond(http.StatusServiceUnavailable)
+		defer server.Close()
+
+		_, err := New(server.URL, WithRetries(2, time.Millisecond)).Stats(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+}
@@ -0,0 +1,68 @@
+package client
+
+import "github.com/boratanrikulu/sendpulse/pkg/dto"
+
+// Request and response types of the REST API
+type (
+	CreateMessageRequest     = dto.CreateMessageRequest
+	CreateSuppressionRequest = dto.CreateSuppressionRequest
+	ReplayRequest            = dto.ReplayRequest
+	BulkStatusRequest        = dto.BulkStatusRequest
+	ErasureRequest           = dto.ErasureRequest
+	WebhookOverrideRequest   = dto.WebhookOverrideRequest
+	ReadOnlyRequest          = dto.ReadOnlyRequest
+	RecipientPauseRequest    = dto.RecipientPauseRequest
+	CampaignRequest          = dto.CampaignRequest
+	CampaignReviewRequest    = dto.CampaignReviewRequest
+	TriageMessageRequest     = dto.TriageMessageRequest
+	ExportRequest            = dto.ExportRequest
+
+	ErrorResponse             = dto.ErrorResponse
+	ErrorCodesResponse        = dto.ErrorCodesResponse
+	SchemasResponse           = dto.SchemasResponse
+	SchemaRef                 = dto.SchemaRef
+	HealthResponse            = dto.HealthResponse
+	StatsResponse             = dto.StatsResponse
+	TimeseriesResponse        = dto.TimeseriesResponse
+	DuplicatesResponse        = dto.DuplicatesResponse
+	RecipientStatsResponse    = dto.RecipientStatsResponse
+	UsageResponse             = dto.UsageResponse
+	LimitsResponse            = dto.LimitsResponse
+	Message                   = dto.MessageResponse
+	MessagesListResponse      = dto.MessagesListResponse
+	SingleMessageResponse     = dto.SingleMessageResponse
+	ValidateMessageResponse   = dto.ValidateMessageResponse
+	BulkStatusResponse        = dto.BulkStatusResponse
+	ReplayResponse            = dto.ReplayResponse
+	IngestionJobResponse      = dto.IngestionJobResponse
+	JobResponse               = dto.JobResponse
+	MessageLinksResponse      = dto.MessageLinksResponse
+	MessageEventsResponse     = dto.MessageEventsResponse
+	SendAttemptsResponse      = dto.SendAttemptsResponse
+	CampaignClicksResponse    = dto.CampaignClicksResponse
+	CostReportResponse        = dto.CostReportResponse
+	DeadLetterTopResponse     = dto.DeadLetterTopResponse
+	DeadLetterHeatmapResponse = dto.DeadLetterHeatmapResponse
+	ProviderSLAResponse       = dto.ProviderSLAResponse
+	MessagingControlResponse  = dto.MessagingControlResponse
+	MessagingStatusResponse   = dto.MessagingStatusResponse
+	ForecastResponse          = dto.ForecastResponse
+	EgressResponse            = dto.EgressResponse
+	ReadOnlyResponse          = dto.ReadOnlyResponse
+	RegionResponse            = dto.RegionResponse
+	RuntimeResponse           = dto.RuntimeResponse
+	RoutingResponse           = dto.RoutingResponse
+	WarmupResponse            = dto.WarmupResponse
+	SuppressionsListResponse  = dto.SuppressionsListResponse
+	SingleSuppressionResponse = dto.SingleSuppressionResponse
+	SuppressionImportResponse = dto.SuppressionImportResponse
+	ErasureResponse           = dto.ErasureResponse
+	ErasuresListResponse      = dto.ErasuresListResponse
+
+	WebhookOverridesResponse      = dto.WebhookOverridesResponse
+	SingleWebhookOverrideResponse = dto.SingleWebhookOverrideResponse
+	RecipientPausesResponse       = dto.RecipientPausesResponse
+	SingleRecipientPauseResponse  = dto.SingleRecipientPauseResponse
+	CampaignsResponse             = dto.CampaignsResponse
+	SingleCampaignResponse        = dto.SingleCampaignResponse
+)
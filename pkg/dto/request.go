@@ -0,0 +1,149 @@
+package dto
+
+import "time"
+
+// CreateMessageRequest represents a new message to enqueue
+type CreateMessageRequest struct {
+	To       string     `json:"to"`
+	Content  string     `json:"content"`
+	Priority int        `json:"priority,omitempty"`
+	SendAt   *time.Time `json:"send_at,omitempty"`
+	// SendAtLocal is the send time in Timezone instead of SendAt: HH:MM is sent at its next occurrence,
+	// YYYY-MM-DDTHH:MM at that date. It is converted to UTC when the message is enqueued.
+	SendAtLocal string `json:"send_at_local,omitempty" example:"09:00"`
+	// Timezone is the IANA timezone of the recipient
+	Timezone string `json:"timezone,omitempty" example:"Europe/Istanbul"`
+	Tenant   string `json:"tenant,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	// From is the registered sender ID or number to send from instead of the sender ID of the route, at most
+	// 11 characters unless it is an E.164 number
+	From string `json:"from,omitempty" example:"ACME"`
+	// Metadata are string values like order_id or customer_id, "tags" is a list of tags
+	Metadata map[string]any `json:"metadata,omitempty" swaggertype:"object"`
+	// ExpiresAt is when the message stops being worth sending, e.g. the end of a one-time password's validity.
+	// A message claimed later is expired instead of sent.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2026-10-17T09:05:00Z"`
+	// CallbackURL receives a status notice once the message expires, is blocked or is dead-lettered, the
+	// callback_url of the API key when empty
+	CallbackURL string `json:"callback_url,omitempty" example:"https://app.example.com/sms/notices"`
+}
+
+// CreateSuppressionRequest represents a recipient to suppress
+type CreateSuppressionRequest struct {
+	Phone  string `json:"phone" example:"+905551234567"`
+	Reason string `json:"reason,omitempty" example:"Complaint"`
+}
+
+// InboundMessageRequest represents a message received from a recipient, posted by the SMS provider
+type InboundMessageRequest struct {
+	From    string `json:"from" example:"+905551234567"`
+	To      string `json:"to,omitempty"`
+	Content string `json:"content" example:"STOP"`
+}
+
+// DeliveryReportRequest represents the delivery state of a message, posted by the SMS provider
+type DeliveryReportRequest struct {
+	// MessageID is the message ID the webhook returned when it accepted the message
+	MessageID string `json:"message_id" example:"67f2f8a8-ea58-4ed0-a6f9-ff217df4d849"`
+	// Status is sent, delivered, failed or undelivered (same as failed)
+	Status string `json:"status" example:"delivered"`
+	Error  string `json:"error,omitempty" example:"Handset unreachable"`
+	// At is when the provider observed the state, the time the report was received when empty
+	At *time.Time `json:"at,omitempty"`
+}
+
+// ReplayRequest selects the messages sent within a window to clone and enqueue again
+type ReplayRequest struct {
+	// From and To bound the window the messages were sent in, To is exclusive
+	From time.Time `json:"from" example:"2026-10-16T09:00:00Z"`
+	To   time.Time `json:"to" example:"2026-10-16T11:00:00Z"`
+	// Provider, Tenant and Campaign narrow the replay when set
+	Provider string `json:"provider,omitempty" example:"provider-b"`
+	Tenant   string `json:"tenant,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	// DryRun only counts the messages that would be replayed
+	DryRun bool `json:"dry_run,omitempty"`
+	// ExpectedCount must be the count a dry run returned, the replay is refused when the count changed
+	ExpectedCount int `json:"expected_count,omitempty" example:"1200"`
+}
+
+// BulkStatusRequest changes the status of several messages at once
+type BulkStatusRequest struct {
+	IDs []int64 `json:"ids" example:"41,42"`
+	// Action is cancel (pending to cancelled) or requeue (failed to pending)
+	Action string `json:"action" example:"cancel"`
+}
+
+// TriageMessageRequest annotates a failed message, fields left out are kept as they are
+type TriageMessageRequest struct {
+	// Note replaces the operator note, empty clears it
+	Note *string `json:"note,omitempty" example:"Carrier outage, retry after 14:00 UTC"`
+	// Acknowledged marks the failure as investigated when true, false withdraws the acknowledgment
+	Acknowledged *bool `json:"acknowledged,omitempty" example:"true"`
+}
+
+// ExportRequest exports the messages matching the filters to a CSV or JSON lines file in the background
+type ExportRequest struct {
+	// Format is csv (the default) or jsonl
+	Format string `json:"format,omitempty" example:"csv"`
+	Status string `json:"status,omitempty" example:"failed"`
+	// From and To bound the creation time of the messages, as YYYY-MM-DD or RFC3339
+	From string `json:"from,omitempty" example:"2026-10-01"`
+	To   string `json:"to,omitempty" example:"2026-11-01"`
+	// Tag and Metadata match the metadata of the messages
+	Tag      string            `json:"tag,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// DeadLettered exports only the failed messages in the dead-letter queue
+	DeadLettered bool `json:"dead_lettered,omitempty"`
+	// Acknowledged exports only the messages whose failure was acknowledged when true, the others when false
+	Acknowledged *bool `json:"acknowledged,omitempty"`
+}
+
+// ErasureRequest erases the data of a phone number, e.g. for a right to be forgotten request
+type ErasureRequest struct {
+	Phone string `json:"phone" example:"+905551234567"`
+	// Mode is delete (the messages are deleted) or anonymize (the messages are kept for statistics without
+	// the number, content, provider responses and metadata)
+	Mode string `json:"mode" example:"anonymize"`
+	// IncludeSuppression also lifts the suppression of the number, by default the opt-out is kept so the
+	// number is not messaged again
+	IncludeSuppression bool `json:"include_suppression,omitempty"`
+	// Reference is stored with the audit record, e.g. the ticket of the request
+	Reference string `json:"reference,omitempty" example:"DSR-1042"`
+	// DryRun only counts the messages and links that would be erased
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// WebhookOverrideRequest sends the messages of a tenant or campaign to their own webhook. A token is sent as a
+// bearer token, a username and password with basic authentication, both are stored encrypted.
+type WebhookOverrideRequest struct {
+	URL      string `json:"url" example:"https://sms.acme.example/send"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// RecipientPauseRequest pauses the messages to a phone number or to every number starting with a prefix
+type RecipientPauseRequest struct {
+	Prefix string `json:"prefix" example:"+9055"`
+	Reason string `json:"reason,omitempty" example:"Carrier outage"`
+	// ExpiresAt lifts the pause by itself, without it the pause lasts until it is removed
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CampaignRequest registers a campaign for the approval workflow as a draft
+type CampaignRequest struct {
+	// Name is the campaign of the messages, at most 64 letters, digits, '.', '_' or '-'
+	Name        string `json:"name" example:"spring-sale"`
+	Description string `json:"description,omitempty" example:"Spring sale to every opted-in customer"`
+}
+
+// CampaignReviewRequest is the note of an approval or rejection, a rejection requires it
+type CampaignReviewRequest struct {
+	Reason string `json:"reason,omitempty" example:"Link points to the staging shop"`
+}
+
+// ReadOnlyRequest enables or disables the read-only mode of the server
+type ReadOnlyRequest struct {
+	Enabled *bool `json:"enabled" example:"true"`
+}
@@ -0,0 +1,1051 @@
+package dto
+
+import "time"
+
+// BaseResponse contains common response fields
+type BaseResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HealthResponse represents health check response
+type HealthResponse struct {
+	BaseResponse
+	Service string `json:"service"`
+	Version string `json:"version"`
+	Mode    string `json:"mode"`
+	// Database is up or down, the status is degraded while it is down
+	Database string `json:"database,omitempty" example:"up"`
+	// Listen are the addresses the server listens on
+	Listen []string `json:"listen,omitempty" example:":8080,[::]:8080"`
+}
+
+// ReadinessResponse represents readiness probe response with per dependency results
+type ReadinessResponse struct {
+	BaseResponse
+	Checks map[string]string `json:"checks"`
+}
+
+// WarmupResponse represents the result of a warmup with per step results
+type WarmupResponse struct {
+	BaseResponse
+	// Checks are database, webhook_overrides and provider:<name> per provider, "ok" or the error of the step
+	Checks     map[string]string `json:"checks"`
+	DurationMS int64             `json:"duration_ms" example:"84"`
+}
+
+// MessageResponse represents a single message
+type MessageResponse struct {
+	// ID is left out for messages with a public ID when database.id_strategy is ulid or uuid
+	ID int64 `json:"id,omitempty"`
+	// PublicID is the ULID or UUID of the message with database.id_strategy ulid or uuid, the ID to share
+	// with external clients and the only one the message is fetched by with those strategies.
+	PublicID string `json:"public_id,omitempty" example:"01J9ZQ4K8M3V6X2T5R7N0B1C4D"`
+	To       string `json:"to"`
+	Content  string `json:"content"`
+	// RenderedContent is the content sent after the transform pipeline of the campaign or tenant rewrote it,
+	// Encoding and Segments are the ones of the sent content then
+	RenderedContent string     `json:"rendered_content,omitempty" example:"Your order shipped https://sp.example.com/l/aB3xY9kQ Reply STOP to opt out"`
+	Status          string     `json:"status"`
+	Priority        int        `json:"priority"`
+	Tenant          string     `json:"tenant,omitempty"`
+	Campaign        string     `json:"campaign,omitempty"`
+	Encoding        string     `json:"encoding" example:"gsm7"`
+	Segments        int        `json:"segments" example:"1"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	// ExpiresAt is when the message stops being worth sending, it is expired instead of sent later
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// CallbackURL receives the status notices of the message
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Timezone is the IANA timezone of the recipient, set when the message was created
+	Timezone  string     `json:"timezone,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	MessageID *string    `json:"message_id,omitempty"`
+	// WebhookResponse is the stored webhook response. Its raw body is the body field, or referenced by payload_ref
+	// when stored outside the message and only read back by the single message endpoint.
+	WebhookResponse map[string]any `json:"webhook_response,omitempty"`
+	DeliveredAt     *time.Time     `json:"delivered_at,omitempty"`
+	DeliveryError   string         `json:"delivery_error,omitempty"`
+	// PolicyViolations are the content policy rules the message violated without being rejected
+	PolicyViolations []string `json:"policy_violations,omitempty"`
+	// From is the sender requested when the message was created
+	From string `json:"from,omitempty" example:"ACME"`
+	// Provider and SenderID are those of the route the message was last sent through, SenderID is From when set
+	Provider string `json:"provider,omitempty" example:"webhook"`
+	SenderID string `json:"sender_id,omitempty"`
+	// Cost is the segments times the unit price of the route, in routing.currency, once the message was sent
+	Cost float64 `json:"cost,omitempty"`
+	// ReplayOf is the ID of the message this one was cloned from by a replay, left out like ID
+	ReplayOf *int64 `json:"replay_of,omitempty"`
+	// Region is the region.name of the instance that last claimed the message
+	Region string `json:"region,omitempty" example:"eu-west"`
+	// FailureClass and FailureError are the class and error of the last failed send. The transient failures,
+	// network and provider_temporary, are requeued automatically until DeadLetteredAt is set.
+	FailureClass   string     `json:"failure_class,omitempty" example:"provider_temporary"`
+	FailureError   string     `json:"failure_error,omitempty"`
+	Requeues       int        `json:"requeues,omitempty"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+	// Note is the operator note of a failed message
+	Note string `json:"note,omitempty" example:"Carrier outage, retry after 14:00 UTC"`
+	// AcknowledgedAt and AcknowledgedBy, the API key name, are set once an operator acknowledged the failure.
+	// They are cleared when the message fails again.
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty" example:"oncall"`
+	// Metadata are the caller defined fields set when the message was created
+	Metadata  map[string]any `json:"metadata,omitempty" swaggertype:"object"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// MessagesListResponse represents paginated messages list
+type MessagesListResponse struct {
+	BaseResponse
+	Messages []MessageResponse `json:"messages"`
+	Total    int               `json:"total"`
+	// Page is 0 for a page continued from a cursor
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	// NextCursor continues the list after this page, set when the page is full on lists of any status
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// SingleMessageResponse represents single message response
+type SingleMessageResponse struct {
+	BaseResponse
+	Message MessageResponse `json:"message"`
+}
+
+// StatsResponse represents queue statistics, "today" starts at UTC midnight
+type StatsResponse struct {
+	BaseResponse
+	Counts      map[string]int `json:"counts"`
+	Total       int            `json:"total"`
+	SentToday   int            `json:"sent_today"`
+	FailedToday int            `json:"failed_today"`
+	// FailureRate is failed / (sent + failed) of today, between 0 and 1
+	FailureRate             float64    `json:"failure_rate"`
+	OldestPendingAt         *time.Time `json:"oldest_pending_at,omitempty"`
+	OldestPendingAgeSeconds int64      `json:"oldest_pending_age_seconds"`
+	// CountsCachedAt is when Counts and Total were counted when they are served from the count cache
+	CountsCachedAt *time.Time `json:"counts_cached_at,omitempty"`
+}
+
+// TimeseriesPoint are the messages created, sent and failed in the bucket starting at Time
+type TimeseriesPoint struct {
+	Time    time.Time `json:"time"`
+	Created int       `json:"created"`
+	Sent    int       `json:"sent"`
+	Failed  int       `json:"failed"`
+}
+
+// TimeseriesResponse represents message counts per time bucket, buckets without messages have zero counts
+type TimeseriesResponse struct {
+	BaseResponse
+	Bucket string            `json:"bucket" example:"1h"`
+	From   time.Time         `json:"from"`
+	To     time.Time         `json:"to"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// DuplicateMessage is a message of a duplicate group, Link is the API path of the message
+type DuplicateMessage struct {
+	// ID is left out like the one of MessageResponse
+	ID        int64      `json:"id,omitempty"`
+	PublicID  string     `json:"public_id,omitempty"`
+	Status    string     `json:"status" example:"sent"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	Link      string     `json:"link" example:"/api/v1/messages/42"`
+}
+
+// DuplicateGroup is a recipient given the same content more than once, each message within the window of the one before
+type DuplicateGroup struct {
+	To       string             `json:"to" example:"+905551234567"`
+	Content  string             `json:"content"`
+	Count    int                `json:"count" example:"2"`
+	FirstAt  time.Time          `json:"first_at"`
+	LastAt   time.Time          `json:"last_at"`
+	Messages []DuplicateMessage `json:"messages"`
+}
+
+// DuplicatesResponse reports likely double-sends, the groups created last come first
+type DuplicatesResponse struct {
+	BaseResponse
+	From          time.Time        `json:"from"`
+	To            time.Time        `json:"to"`
+	WindowSeconds int64            `json:"window_seconds" example:"600"`
+	Groups        []DuplicateGroup `json:"groups"`
+	// Duplicates counts the messages of the groups after their first one
+	Duplicates int `json:"duplicates"`
+	// Truncated is true when more recipient and content pairs repeated than the limit
+	Truncated bool `json:"truncated"`
+}
+
+// SuppressionResponse represents a suppressed recipient
+type SuppressionResponse struct {
+	Phone     string    `json:"phone"`
+	Reason    string    `json:"reason,omitempty"`
+	Source    string    `json:"source" example:"inbound"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SuppressionImportResponse represents the outcome of an opt-out list import
+type SuppressionImportResponse struct {
+	BaseResponse
+	// DryRun is true when no number was suppressed, the counts are what the import would do
+	DryRun bool `json:"dry_run"`
+	// Read is the number of rows of the list, blank lines and comments aside
+	Read int `json:"read" example:"200000"`
+	// Imported is the number of numbers the import suppressed
+	Imported int `json:"imported" example:"184211"`
+	// Existing is the number of numbers that were suppressed already, they keep their suppression
+	Existing int `json:"existing" example:"12408"`
+	// Duplicates is the number of rows repeating the number of an earlier row
+	Duplicates int `json:"duplicates" example:"3350"`
+	// Invalid is the number of rows without a phone number
+	Invalid int `json:"invalid" example:"31"`
+	// Rejections are the first invalid rows
+	Rejections []SuppressionImportRejection `json:"rejections,omitempty"`
+}
+
+// SuppressionImportRejection represents a row of an opt-out list that was not imported, Line is its position in
+// the list
+type SuppressionImportRejection struct {
+	Line   int    `json:"line" example:"17"`
+	Value  string `json:"value" example:"n/a"`
+	Reason string `json:"reason" example:"not an E.164 phone number"`
+}
+
+// SuppressionsListResponse represents paginated suppressions list
+type SuppressionsListResponse struct {
+	BaseResponse
+	Suppressions []SuppressionResponse `json:"suppressions"`
+	Total        int                   `json:"total"`
+	Page         int                   `json:"page"`
+	PageSize     int                   `json:"page_size"`
+}
+
+// SingleSuppressionResponse represents single suppression response
+type SingleSuppressionResponse struct {
+	BaseResponse
+	Suppression SuppressionResponse `json:"suppression"`
+}
+
+// RecipientFailure is the last failed message to a recipient
+type RecipientFailure struct {
+	// ID is left out like the one of MessageResponse
+	ID       int64  `json:"id,omitempty"`
+	PublicID string `json:"public_id,omitempty"`
+	// FailedAt is when the message was marked failed, Error the failure the provider reported if any
+	FailedAt time.Time `json:"failed_at"`
+	Error    string    `json:"error,omitempty" example:"absent subscriber"`
+	Link     string    `json:"link" example:"/api/v1/messages/42"`
+}
+
+// RecipientStatsResponse summarizes the messages to a phone number, e.g. to find out why a recipient gets none
+type RecipientStatsResponse struct {
+	BaseResponse
+	Phone  string         `json:"phone" example:"+905551234567"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+	// FirstMessageAt and LastMessageAt are the creation times of the first and last message to the recipient
+	FirstMessageAt *time.Time `json:"first_message_at,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	// LastSuccessAt is when the last message was delivered, or sent when no delivery report arrived for it
+	LastSuccessAt *time.Time        `json:"last_success_at,omitempty"`
+	LastFailure   *RecipientFailure `json:"last_failure,omitempty"`
+	// FailureStreak is the number of messages failed in a row since the last sent or delivered one,
+	// LongestFailureStreak the longest such run. Both count the latest outcomes only, see OutcomesTruncated.
+	FailureStreak        int `json:"failure_streak"`
+	LongestFailureStreak int `json:"longest_failure_streak"`
+	// OutcomesTruncated is true when the recipient has more sent, delivered and failed messages than the
+	// streaks are counted over
+	OutcomesTruncated bool `json:"outcomes_truncated,omitempty"`
+	// Suppressed is set while the recipient is suppressed and their messages are blocked
+	Suppressed  bool                 `json:"suppressed"`
+	Suppression *SuppressionResponse `json:"suppression,omitempty"`
+}
+
+// WebhookOverrideResponse represents the webhook of a tenant or campaign, its credentials are not returned
+type WebhookOverrideResponse struct {
+	Scope string `json:"scope" example:"tenant"`
+	Name  string `json:"name" example:"acme"`
+	URL   string `json:"url" example:"https://sms.acme.example/send"`
+	// Auth is none, basic or bearer
+	Auth      string    `json:"auth" example:"bearer"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookOverridesResponse represents every webhook override
+type WebhookOverridesResponse struct {
+	BaseResponse
+	Overrides []WebhookOverrideResponse `json:"overrides"`
+}
+
+// SingleWebhookOverrideResponse represents single webhook override response
+type SingleWebhookOverrideResponse struct {
+	BaseResponse
+	Override WebhookOverrideResponse `json:"override"`
+}
+
+// RecipientPauseResponse represents a paused phone number or prefix
+type RecipientPauseResponse struct {
+	Prefix    string     `json:"prefix" example:"+9055"`
+	Reason    string     `json:"reason,omitempty" example:"Carrier outage"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RecipientPausesResponse represents every active recipient pause
+type RecipientPausesResponse struct {
+	BaseResponse
+	Pauses []RecipientPauseResponse `json:"pauses"`
+}
+
+// SingleRecipientPauseResponse represents single recipient pause response
+type SingleRecipientPauseResponse struct {
+	BaseResponse
+	Pause RecipientPauseResponse `json:"pause"`
+	// Deferred is the number of pending messages the pause deferred right away
+	Deferred int `json:"deferred" example:"120"`
+}
+
+// CampaignResponse represents a campaign of the approval workflow. The actors are the API keys that took the steps.
+type CampaignResponse struct {
+	Name string `json:"name" example:"spring-sale"`
+	// Status is draft, pending_approval, approved, launched or paused, the messages of the campaign are held until
+	// launched and while paused
+	Status      string     `json:"status" example:"pending_approval"`
+	Description string     `json:"description,omitempty" example:"Spring sale to every opted-in customer"`
+	CreatedBy   string     `json:"created_by,omitempty" example:"marketing"`
+	SubmittedBy string     `json:"submitted_by,omitempty" example:"marketing"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	ApprovedBy  string     `json:"approved_by,omitempty" example:"ops"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	LaunchedBy  string     `json:"launched_by,omitempty" example:"marketing"`
+	LaunchedAt  *time.Time `json:"launched_at,omitempty"`
+	// PausedBy and ResumedBy are the last pause and resume of the launched campaign
+	PausedBy  string     `json:"paused_by,omitempty" example:"marketing"`
+	PausedAt  *time.Time `json:"paused_at,omitempty"`
+	ResumedBy string     `json:"resumed_by,omitempty" example:"marketing"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty"`
+	// RejectedBy and Rejection are the last rejection, which sent the campaign back to draft
+	RejectedBy string `json:"rejected_by,omitempty" example:"ops"`
+	Rejection  string `json:"rejection,omitempty" example:"Link points to the staging shop"`
+	// Pending is the number of pending messages of the campaign, the size of the blast while it is held
+	Pending int `json:"pending" example:"25000"`
+	// Cursor is how far the launched campaign got, omitted until it sent a message
+	Cursor    *CampaignCursor `json:"cursor,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CampaignCursor represents the progress of a campaign through its messages, a resumed campaign does not send
+// the messages it sent already again
+type CampaignCursor struct {
+	// Recipients is the number of recipients the campaign sent a message to, Messages the number of messages
+	Recipients    int        `json:"recipients" example:"12500"`
+	Messages      int        `json:"messages" example:"12800"`
+	LastMessageID int64      `json:"last_message_id" example:"48211"`
+	LastSentAt    *time.Time `json:"last_sent_at,omitempty"`
+}
+
+// CampaignsResponse represents the campaigns of the approval workflow ordered by name
+type CampaignsResponse struct {
+	BaseResponse
+	Campaigns []CampaignResponse `json:"campaigns"`
+}
+
+// SingleCampaignResponse represents single campaign response
+type SingleCampaignResponse struct {
+	BaseResponse
+	Campaign CampaignResponse `json:"campaign"`
+}
+
+// InboundMessageResponse represents the outcome of an inbound message
+type InboundMessageResponse struct {
+	BaseResponse
+	// Action is suppressed or unsuppressed when the message was a keyword, none otherwise
+	Action string `json:"action" example:"suppressed"`
+}
+
+// ValidateMessageResponse represents a valid message and how it would be sent, nothing is enqueued
+type ValidateMessageResponse struct {
+	BaseResponse
+	// Encoding is gsm7, or ucs2 when the content has a character outside the GSM 03.38 alphabet
+	Encoding string `json:"encoding" example:"gsm7"`
+	// Segments is the number of SMS the content is sent and billed as
+	Segments int `json:"segments" example:"1"`
+	// Length is the content length in septets (gsm7) or UTF-16 code units (ucs2)
+	Length int `json:"length" example:"42"`
+}
+
+// DeliveryReportResponse represents the outcome of a delivery report
+type DeliveryReportResponse struct {
+	BaseResponse
+	ID            int64  `json:"id"`
+	MessageStatus string `json:"message_status" example:"delivered"`
+	// Applied is false when the message already reached a later status, e.g. a sent report after delivered
+	Applied bool `json:"applied"`
+}
+
+// UsageCounter is the number of messages created by an API key or tenant in the current UTC day or month
+type UsageCounter struct {
+	Scope       string    `json:"scope" example:"api_key"`
+	Subject     string    `json:"subject" example:"reporting"`
+	Period      string    `json:"period" example:"day"`
+	PeriodStart time.Time `json:"period_start"`
+	Count       int64     `json:"count"`
+	// Limit and Remaining are omitted when the subject has no quota in the period
+	Limit     int64  `json:"limit,omitempty"`
+	Remaining *int64 `json:"remaining,omitempty"`
+	// UsedRatio is the share of the limit used, WarningThreshold the highest quotas.warning_thresholds share it
+	// reached. Both are omitted without a quota.
+	UsedRatio        float64 `json:"used_ratio,omitempty" example:"0.83"`
+	WarningThreshold float64 `json:"warning_threshold,omitempty" example:"0.8"`
+}
+
+// UsageResponse represents the quota usage of the current UTC day and month
+type UsageResponse struct {
+	BaseResponse
+	Counters []UsageCounter `json:"counters"`
+}
+
+// QuotaLimit is a quota of the calling API key with its use in the current UTC day or month
+type QuotaLimit struct {
+	Period    string `json:"period" example:"day"`
+	Limit     int64  `json:"limit" example:"1000"`
+	Used      int64  `json:"used" example:"250"`
+	Remaining int64  `json:"remaining" example:"750"`
+	// Reset is when the period ends and the quota is available again
+	Reset time.Time `json:"reset"`
+}
+
+// LimitsResponse represents the quotas of the calling API key, empty when it has none
+type LimitsResponse struct {
+	BaseResponse
+	APIKey string       `json:"api_key,omitempty" example:"reporting"`
+	Quotas []QuotaLimit `json:"quotas"`
+}
+
+// EgressResponse lists the addresses requests to providers are sent from, for provider allowlists
+type EgressResponse struct {
+	BaseResponse
+	// Interface is the network interface the webhook client is bound to
+	Interface string `json:"interface,omitempty" example:"eth1"`
+	// LocalAddresses are the addresses the webhook client is bound to, empty when the system picks them
+	LocalAddresses []string `json:"local_addresses"`
+	// EgressIPs are the public IPs providers see the requests from, as configured in webhook.egress_ips
+	EgressIPs []string `json:"egress_ips"`
+}
+
+// MaintenanceJobResponse is a maintenance job of the instance with its last run across the cluster
+type MaintenanceJobResponse struct {
+	Name     string `json:"name" example:"stuck_reaper"`
+	Schedule string `json:"schedule" example:"@every 1m"`
+	// NextRunAt is when the instance runs the job next, unless another instance takes the run
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	// LastStatus is running, succeeded or failed, empty when the job never ran
+	LastStatus     string     `json:"last_status,omitempty" example:"succeeded"`
+	LastResult     string     `json:"last_result,omitempty" example:"reset 3 stuck messages to pending"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastHolder     string     `json:"last_holder,omitempty"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	Runs           int64      `json:"runs"`
+	Failures       int64      `json:"failures"`
+}
+
+// MaintenanceJobsResponse lists the maintenance jobs
+type MaintenanceJobsResponse struct {
+	BaseResponse
+	Jobs []MaintenanceJobResponse `json:"jobs"`
+}
+
+// ReadOnlyResponse is the read-only state of the server, while it is read-only writes are answered with 503
+type ReadOnlyResponse struct {
+	BaseResponse
+	// Enabled is whether the read-only mode is enabled, messaging is paused while it is
+	Enabled bool `json:"enabled"`
+	// Since is when the mode was last enabled or disabled, ChangedBy the API key that did it or config
+	Since     *time.Time `json:"since,omitempty"`
+	ChangedBy string     `json:"changed_by,omitempty" example:"ops"`
+	// Schema is set when the server runs read-only because the database schema does not match the binary,
+	// disabling the mode does not lift it
+	Schema bool `json:"schema,omitempty"`
+}
+
+// RegionResponse is the role of the region of the instance, its scheduler claims no messages while it is passive
+type RegionResponse struct {
+	BaseResponse
+	// Region is the region.name of the instance
+	Region string `json:"region,omitempty" example:"eu-west"`
+	// Role is active or passive
+	Role string `json:"role" example:"passive"`
+	// Since is when the region got the role, ChangedBy the API key that promoted or demoted it or config
+	Since     time.Time `json:"since"`
+	ChangedBy string    `json:"changed_by" example:"ops"`
+}
+
+// RuntimeResponse is the runtime of the instance, reported for incident triage
+type RuntimeResponse struct {
+	BaseResponse
+	Build BuildInfo `json:"build"`
+	// Hostname and PID identify the instance among the ones sharing the database
+	Hostname   string `json:"hostname" example:"sendpulse-7d9f4c-x2k8q"`
+	PID        int    `json:"pid" example:"1"`
+	GOMAXPROCS int    `json:"gomaxprocs" example:"4"`
+	NumCPU     int    `json:"num_cpu" example:"4"`
+	Goroutines int    `json:"goroutines" example:"42"`
+	// HeapAllocBytes is the memory of the live heap objects, GCCycles the garbage collections since the start
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes" example:"12582912"`
+	GCCycles       uint32 `json:"gc_cycles" example:"17"`
+	// StartedAt is when the process started, UptimeSeconds how long it has been running since
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds" example:"86400"`
+	// Scheduler is the scheduler of the instance, omitted when it runs none
+	Scheduler *SchedulerRuntime `json:"scheduler,omitempty"`
+	// Config is the effective configuration keyed as in the config file, with its secrets redacted
+	Config map[string]any `json:"config" swaggertype:"object"`
+}
+
+// BuildInfo is the build of the binary, Commit, CommitTime and Modified are set when it was built from a checkout
+type BuildInfo struct {
+	Version    string `json:"version" example:"0.1.0"`
+	GoVersion  string `json:"go_version" example:"go1.24.0"`
+	Commit     string `json:"commit,omitempty" example:"019c44c7a3e1f5b2d8c6e4a9b0f1d2c3e4a5b6c7"`
+	CommitTime string `json:"commit_time,omitempty" example:"2026-10-17T09:30:00Z"`
+	// Modified is whether the checkout had uncommitted changes
+	Modified bool `json:"modified,omitempty"`
+}
+
+// SchedulerRuntime are the internals of the scheduler of the instance
+type SchedulerRuntime struct {
+	// Running is whether the scheduler processes batches, Following whether it follows the cluster control
+	Running   bool `json:"running"`
+	Following bool `json:"following"`
+	// Holder identifies the scheduler as the holder of the leader lease, Role is leader or standby while the
+	// lease is enabled
+	Holder string `json:"holder" example:"sendpulse-7d9f4c-x2k8q-1"`
+	Role   string `json:"role,omitempty" example:"leader"`
+	// Paused is whether claiming is paused, see MessagingStatusResponse
+	Paused     bool   `json:"paused"`
+	Region     string `json:"region,omitempty" example:"eu-west"`
+	RegionRole string `json:"region_role,omitempty" example:"active"`
+	// Batches is the number of running batches, BatchQueued whether a batch waits for them to finish
+	Batches     int  `json:"batches" example:"1"`
+	BatchQueued bool `json:"batch_queued"`
+	// InFlight is the number of messages claimed and not settled yet
+	InFlight int `json:"in_flight" example:"25"`
+}
+
+// RoutingResponse represents the routing decisions of the scheduler of the instance, the shares of the providers
+// of every route and the health scores they follow
+type RoutingResponse struct {
+	BaseResponse
+	// WindowSeconds is how far back the sends of a provider count towards its score
+	WindowSeconds int64 `json:"window_seconds" example:"300"`
+	// Routes are sorted by descending prefix length, the default route last
+	Routes []RouteRouting `json:"routes"`
+}
+
+// RouteRouting is a route and the providers its messages are split between
+type RouteRouting struct {
+	// Prefix is empty for the default route
+	Prefix    string          `json:"prefix" example:"+90"`
+	Providers []RouteProvider `json:"providers"`
+}
+
+// RouteProvider is a provider of a route, its health score and its share of the messages of the route
+type RouteProvider struct {
+	Provider string  `json:"provider" example:"provider-b"`
+	Weight   float64 `json:"weight" example:"3"`
+	// Score is the health score of the provider, from the success rate and latency of its sends within the window
+	Score float64 `json:"score" example:"0.92"`
+	// Share is the fraction of the messages of the route routed to the provider now, its weight times its score
+	// over the sum of the route
+	Share float64 `json:"share" example:"0.75"`
+	// Sends and Failures are the sends of the provider within the window, only transient failures count
+	Sends     int   `json:"sends" example:"1200"`
+	Failures  int   `json:"failures" example:"24"`
+	LatencyMS int64 `json:"latency_ms" example:"180"`
+	// Decisions is the number of messages routed to the provider through the route since the instance started
+	Decisions int64 `json:"decisions" example:"48211"`
+}
+
+// IngestionRejection represents a row of an ingestion job that was not enqueued, Line is its position in the payload
+type IngestionRejection struct {
+	Line   int    `json:"line" example:"17"`
+	Reason string `json:"reason" example:"duplicate of an earlier row"`
+}
+
+// IngestionJobResponse represents an asynchronous ingestion job and its progress
+type IngestionJobResponse struct {
+	BaseResponse
+	ID string `json:"id" example:"01J9ZQ4K8M3V6X2T5R7N0B1C4D"`
+	// State is queued, running, succeeded or failed
+	State string `json:"state" example:"running"`
+	// Total is the number of messages in the payload, Read the ones processed so far
+	Total      int `json:"total" example:"500000"`
+	Read       int `json:"read" example:"120000"`
+	Enqueued   int `json:"enqueued" example:"119950"`
+	Duplicates int `json:"duplicates"`
+	Rejected   int `json:"rejected" example:"50"`
+	// Rejections are the first 100 rejected rows
+	Rejections []IngestionRejection `json:"rejections,omitempty"`
+	// Error is why a failed job stopped, the messages enqueued before stay enqueued
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobResponse represents a background job and its progress
+type JobResponse struct {
+	BaseResponse
+	ID string `json:"id" example:"01J9ZQ4K8M3V6X2T5R7N0B1C4D"`
+	// Kind is export or ingestion
+	Kind string `json:"kind" example:"export"`
+	// State is queued, running, succeeded or failed
+	State string `json:"state" example:"running"`
+	// Progress is the share of the job done in percent, Done of Total units of work, e.g. messages
+	Progress int `json:"progress" example:"40"`
+	Total    int `json:"total" example:"50000"`
+	Done     int `json:"done" example:"20000"`
+	// Result summarizes what a succeeded job did
+	Result string `json:"result,omitempty" example:"Exported 50000 messages"`
+	// ResultURL downloads the output of a succeeded job until ExpiresAt
+	ResultURL string `json:"result_url,omitempty" example:"/api/v1/jobs/01J9ZQ4K8M3V6X2T5R7N0B1C4D/result"`
+	// Error is why a failed job stopped
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// ExpiresAt is when the job and its output are deleted
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// MessagingControlResponse represents messaging control operation response
+type MessagingControlResponse struct {
+	BaseResponse
+	// Code tells what the request did, see the Control codes
+	Code    string `json:"code" example:"started"`
+	Message string `json:"message"`
+}
+
+// Codes of the messaging control responses, starting a running scheduler or stopping a stopped one is a no-op
+const (
+	ControlStarted        = "started"
+	ControlStopped        = "stopped"
+	ControlAlreadyRunning = "already_running"
+	ControlNotRunning     = "not_running"
+)
+
+// MessagingStatusResponse represents messaging service status
+type MessagingStatusResponse struct {
+	BaseResponse
+	Enabled bool `json:"enabled"`
+	// ClusterEnabled is whether messaging is started across the cluster, set when messaging.control_interval is
+	ClusterEnabled *bool `json:"cluster_enabled,omitempty"`
+	// Role is leader or standby when messaging.leader_lease is set
+	Role string `json:"role,omitempty" example:"leader"`
+	// Paused is set while the database is unreachable, the server is read-only or its region is passive and no
+	// messages are claimed
+	Paused bool `json:"paused,omitempty"`
+	// Region and RegionRole are the region.name of the instance and whether the region is active or passive
+	Region     string `json:"region,omitempty" example:"eu-west"`
+	RegionRole string `json:"region_role,omitempty" example:"active"`
+	Interval   string `json:"interval"`
+	BatchSize  int    `json:"batch_size"`
+	MaxRetries int    `json:"max_retries"`
+	RetryDelay string `json:"retry_delay"`
+}
+
+// CampaignForecast is when the pending messages of a campaign are expected to be sent
+type CampaignForecast struct {
+	// Campaign is empty for the messages without a campaign
+	Campaign string `json:"campaign" example:"spring-sale"`
+	Pending  int    `json:"pending"`
+	// Scheduled counts the pending messages scheduled for later
+	Scheduled int `json:"scheduled"`
+	// DrainSeconds and DrainAt are when the last message is expected to be sent, unset when it is past the horizon
+	DrainSeconds *int64     `json:"drain_seconds,omitempty" example:"3600"`
+	DrainAt      *time.Time `json:"drain_at,omitempty"`
+}
+
+// ForecastResponse estimates when the pending messages are sent with the given messaging settings, assuming
+// messaging runs from now and no more messages arrive. The campaigns draining last come first.
+type ForecastResponse struct {
+	BaseResponse
+	Interval  string `json:"interval" example:"5s"`
+	BatchSize int    `json:"batch_size" example:"2"`
+	// RateLimit is the messages per second every route was limited to, unset for the configured route limits
+	RateLimit *float64 `json:"rate_limit,omitempty"`
+	Pending   int      `json:"pending"`
+	Scheduled int      `json:"scheduled"`
+	// MessagesPerSecond is the average send rate until the backlog is drained
+	MessagesPerSecond float64 `json:"messages_per_second" example:"0.4"`
+	// DrainSeconds and DrainAt are when the last pending message is expected to be sent, unset when it is past
+	// the horizon
+	DrainSeconds *int64             `json:"drain_seconds,omitempty" example:"3600"`
+	DrainAt      *time.Time         `json:"drain_at,omitempty"`
+	Horizon      time.Time          `json:"horizon"`
+	Campaigns    []CampaignForecast `json:"campaigns"`
+}
+
+// ErrorResponse represents error response
+type ErrorResponse struct {
+	BaseResponse
+	// Code is the stable code of the error from the ErrorCodes catalog, match it instead of the message
+	Code    string `json:"code,omitempty" example:"SP2004"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ErrorCode describes a code of the error responses
+type ErrorCode struct {
+	Code string `json:"code" example:"SP2004"`
+	// Name is a readable alias of the code, as stable as the code itself
+	Name        string `json:"name" example:"message_not_found"`
+	HTTPStatus  int    `json:"http_status" example:"404"`
+	Description string `json:"description" example:"No message has the ID"`
+}
+
+// ErrorCodesResponse lists every code of the error responses
+type ErrorCodesResponse struct {
+	BaseResponse
+	Errors []ErrorCode `json:"errors"`
+}
+
+// SchemaRef names the JSON Schema of a DTO and where it is served
+type SchemaRef struct {
+	Name string `json:"name" example:"CreateMessageRequest"`
+	URL  string `json:"url" example:"/api/v1/schemas/CreateMessageRequest.json"`
+}
+
+// SchemasResponse lists the JSON Schemas of the request and response DTOs of an API version
+type SchemasResponse struct {
+	BaseResponse
+	Version string      `json:"version" example:"v1"`
+	Schemas []SchemaRef `json:"schemas"`
+}
+
+// Codes of the error responses. The first digit groups them: 1 invalid requests, 2 missing resources and
+// conflicting states, 3 server and dependency failures, 4 authentication and limits. A released code never
+// changes its meaning, new errors get new codes.
+const (
+	CodeInvalidRequest       = "SP1000"
+	CodeInvalidPageSize      = "SP1001"
+	CodeInvalidBody          = "SP1002"
+	CodeInvalidMessageID     = "SP1003"
+	CodeInvalidMessage       = "SP1004"
+	CodeInvalidStatus        = "SP1005"
+	CodeInvalidPhone         = "SP1006"
+	CodeContentRejected      = "SP1007"
+	CodePayloadTooLarge      = "SP1008"
+	CodeReplayTooLarge       = "SP1009"
+	CodeExportTooLarge       = "SP1010"
+	CodeSuppressionNotFound  = "SP2001"
+	CodeLinkNotFound         = "SP2002"
+	CodeIngestionJobNotFound = "SP2003"
+	CodeMessageNotFound      = "SP2004"
+	CodeOverrideNotFound     = "SP2005"
+	CodeNotQuarantined       = "SP2006"
+	CodeNotPending           = "SP2007"
+	CodeReplayCountMismatch  = "SP2008"
+	CodeNotCancellable       = "SP2009"
+	CodeSendIssued           = "SP2010"
+	CodeSendNotLocal         = "SP2011"
+	CodePauseNotFound        = "SP2012"
+	CodeSchemaNotFound       = "SP2013"
+	CodeCampaignNotFound     = "SP2014"
+	CodeCampaignExists       = "SP2015"
+	CodeCampaignStatus       = "SP2016"
+	CodeNotFailed            = "SP2017"
+	CodeJobNotFound          = "SP2018"
+	CodeJobResultUnavailable = "SP2019"
+	CodeProviderNotFound     = "SP2020"
+	CodeInternal             = "SP3000"
+	CodeDatabaseUnavailable  = "SP3001"
+	CodeRequestTimeout       = "SP3002"
+	CodeReadOnly             = "SP3003"
+	CodeLoadShed             = "SP3004"
+	CodeUnauthorized         = "SP4001"
+	CodeMissingScope         = "SP4002"
+	CodeInvalidSignature     = "SP4003"
+	CodeQuotaExceeded        = "SP4004"
+	CodeIngestionBusy        = "SP4005"
+	CodeSelfApproval         = "SP4006"
+	CodeJobsBusy             = "SP4007"
+)
+
+// ErrorCodes is the catalog of the error codes, served by GET /api/v1/errors
+var ErrorCodes = []ErrorCode{
+	{CodeInvalidRequest, "invalid_request", 400, "A parameter or field of the request is invalid, the message tells which"},
+	{CodeInvalidPageSize, "invalid_page_size", 400, "The page size is negative"},
+	{CodeInvalidBody, "invalid_request_body", 400, "The request body is not valid JSON of the expected shape"},
+	{CodeInvalidMessageID, "invalid_message_id", 400, "The message ID is neither a number nor a public ID"},
+	{CodeInvalidMessage, "invalid_message", 400, "The message has an invalid recipient, content, schedule or metadata"},
+	{CodeInvalidStatus, "invalid_status", 400, "The status filter is not a message status"},
+	{CodeInvalidPhone, "invalid_phone", 400, "The phone number is not in E.164 format"},
+	{CodeContentRejected, "content_rejected", 422, "The content violates a reject rule of the content policy"},
+	{CodePayloadTooLarge, "payload_too_large", 413, "The ingestion payload has more than ingestion.max_messages messages"},
+	{CodeReplayTooLarge, "replay_too_large", 422, "The replay matches more messages than a replay may clone"},
+	{CodeExportTooLarge, "export_too_large", 422, "The export matches more messages than jobs.export.max_messages"},
+	{CodeSuppressionNotFound, "suppression_not_found", 404, "The phone number is not suppressed"},
+	{CodeLinkNotFound, "link_not_found", 404, "No tracked link has the code"},
+	{CodeIngestionJobNotFound, "ingestion_job_not_found", 404, "No ingestion job has the ID"},
+	{CodeMessageNotFound, "message_not_found", 404, "No message has the ID"},
+	{CodeOverrideNotFound, "webhook_override_not_found", 404, "The tenant or campaign has no webhook override"},
+	{CodeNotQuarantined, "message_not_quarantined", 409, "Only quarantined messages can be released"},
+	{CodeNotPending, "message_not_pending", 409, "Only pending messages can be prioritized"},
+	{CodeReplayCountMismatch, "replay_count_mismatch", 409, "The expected count of the replay differs from the messages it matches"},
+	{CodeNotCancellable, "message_not_cancellable", 409, "Only pending and sending messages can be cancelled"},
+	{CodeSendIssued, "send_already_issued", 409, "The webhook call of the message was already issued, it ends sent or failed as usual"},
+	{CodeSendNotLocal, "send_not_local", 409, "Another instance is sending the message, only the instance that claimed it can cancel the send"},
+	{CodePauseNotFound, "recipient_pause_not_found", 404, "The phone number or prefix is not paused"},
+	{CodeSchemaNotFound, "schema_not_found", 404, "No request or response has a schema of the name"},
+	{CodeCampaignNotFound, "campaign_not_found", 404, "No campaign of the name is registered for approval"},
+	{CodeCampaignExists, "campaign_exists", 409, "A campaign of the name is registered already"},
+	{CodeCampaignStatus, "campaign_status_conflict", 409, "The campaign is not in the status the step requires, e.g. only approved campaigns can be launched"},
+	{CodeNotFailed, "message_not_failed", 409, "Only failed messages can be annotated or acknowledged"},
+	{CodeJobNotFound, "job_not_found", 404, "No job has the ID, or it expired"},
+	{CodeJobResultUnavailable, "job_result_unavailable", 409, "The job did not succeed yet or left no output to download"},
+	{CodeProviderNotFound, "provider_not_found", 404, "No provider of the name is configured or was ever sent to"},
+	{CodeInternal, "internal_error", 500, "The server failed to handle the request, it is logged with the request ID"},
+	{CodeDatabaseUnavailable, "database_unavailable", 503, "The database is unreachable, retry after the Retry-After header"},
+	{CodeRequestTimeout, "request_timeout", 504, "The request exceeded the timeout of its route"},
+	{CodeReadOnly, "read_only", 503, "The server runs read-only, against a mismatching schema or in the read-only mode, and only answers reads"},
+	{CodeLoadShed, "load_shed", 503, "The database is under load, stats and exports are shed until it recovers, retry after the Retry-After header"},
+	{CodeUnauthorized, "unauthorized", 401, "The API key is missing or invalid"},
+	{CodeMissingScope, "missing_scope", 403, "The API key lacks the scope of the route"},
+	{CodeInvalidSignature, "invalid_signature", 401, "The callback signature or token is missing, invalid, stale or replayed"},
+	{CodeQuotaExceeded, "quota_exceeded", 429, "A quota of the API key is used up until its period resets"},
+	{CodeIngestionBusy, "ingestion_busy", 429, "Every ingestion worker is busy and ingestion.max_queued jobs are waiting"},
+	{CodeSelfApproval, "self_approval", 403, "The API key that submitted the campaign cannot approve it"},
+	{CodeJobsBusy, "jobs_busy", 429, "Every job worker is busy and jobs.max_queued jobs are waiting"},
+}
+
+// LookupErrorCode returns the catalog entry of code
+func LookupErrorCode(code string) (ErrorCode, bool) {
+	for _, entry := range ErrorCodes {
+		if entry.Code == code {
+			return entry, true
+		}
+	}
+	return ErrorCode{}, false
+}
+
+// LinkResponse represents a tracked short link in the content of a message
+type LinkResponse struct {
+	Code          string     `json:"code" example:"aZ3kP9qR"`
+	URL           string     `json:"url" example:"https://example.com/spring-sale"`
+	ShortURL      string     `json:"short_url" example:"https://sp.example.com/l/aZ3kP9qR"`
+	Clicks        int64      `json:"clicks"`
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+}
+
+// MessageLinksResponse represents the tracked links of a message and their clicks
+type MessageLinksResponse struct {
+	BaseResponse
+	MessageID int64          `json:"message_id,omitempty"`
+	Links     []LinkResponse `json:"links"`
+	Clicks    int64          `json:"clicks"`
+}
+
+// MessageEventResponse represents why the scheduler skipped a message instead of sending it
+type MessageEventResponse struct {
+	// Reason is suppressed, suppression_check_failed, throttled, rate_limited, route_failed, transform_failed, held or stopped
+	Reason    string    `json:"reason" example:"throttled"`
+	Detail    string    `json:"detail,omitempty" example:"deferred until 2026-10-16T09:30:00Z"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageEventsResponse represents the skips of a message, oldest first
+type MessageEventsResponse struct {
+	BaseResponse
+	MessageID int64                  `json:"message_id,omitempty"`
+	Events    []MessageEventResponse `json:"events"`
+}
+
+// SendAttemptResponse represents a webhook request of a message send
+type SendAttemptResponse struct {
+	// Attempt numbers the attempts of every send of the message, starting from 1
+	Attempt  int    `json:"attempt" example:"2"`
+	Provider string `json:"provider" example:"webhook"`
+	// StatusCode is the status of the provider response, omitted when none was received
+	StatusCode int       `json:"status_code,omitempty" example:"503"`
+	Error      string    `json:"error,omitempty" example:"webhook returned status: 503"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMS int64     `json:"duration_ms" example:"212"`
+}
+
+// SendAttemptsResponse represents the webhook requests of a message, oldest first
+type SendAttemptsResponse struct {
+	BaseResponse
+	MessageID int64                 `json:"message_id,omitempty"`
+	Attempts  []SendAttemptResponse `json:"attempts"`
+	// FirstAttemptAt and LastAttemptAt are when the first attempt started and the last one finished
+	FirstAttemptAt *time.Time `json:"first_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+}
+
+// CampaignClicks represents the clicks on the tracked links of a campaign, messages without a campaign have an empty one
+type CampaignClicks struct {
+	Campaign string `json:"campaign" example:"spring-sale"`
+	// Messages is the number of messages with at least one tracked link
+	Messages int64 `json:"messages"`
+	Links    int64 `json:"links"`
+	Clicks   int64 `json:"clicks"`
+	// ClickedMessages is the number of messages with at least one clicked link
+	ClickedMessages int64 `json:"clicked_messages"`
+	// ClickRate is clicked messages / messages, between 0 and 1
+	ClickRate float64 `json:"click_rate"`
+}
+
+// CampaignClicksResponse represents the link clicks per campaign, most clicked first
+type CampaignClicksResponse struct {
+	BaseResponse
+	Campaigns []CampaignClicks `json:"campaigns"`
+}
+
+// CostSummary represents the cost of the messages sent in a group
+type CostSummary struct {
+	// Key is the UTC day, campaign or tenant, empty for messages without a campaign or tenant
+	Key      string  `json:"key" example:"2026-10-16"`
+	Messages int64   `json:"messages"`
+	Segments int64   `json:"segments"`
+	Cost     float64 `json:"cost" example:"1.25"`
+}
+
+// CostReportResponse represents the cost of sent messages per day, campaign or tenant
+type CostReportResponse struct {
+	BaseResponse
+	GroupBy  string        `json:"group_by" example:"day"`
+	Currency string        `json:"currency" example:"USD"`
+	Costs    []CostSummary `json:"costs"`
+	Total    CostSummary   `json:"total"`
+}
+
+// DeadLetterCount represents the messages of the dead-letter queue sharing a failure reason, failure class,
+// recipient prefix or provider
+type DeadLetterCount struct {
+	// Key is the reason, class, prefix or provider, empty for messages without one
+	Key      string `json:"key" example:"provider returned status 503"`
+	Messages int64  `json:"messages" example:"412"`
+	// Share is Messages as a share of every dead-lettered message in the window, between 0 and 1
+	Share float64 `json:"share" example:"0.37"`
+}
+
+// DeadLetterTopResponse represents the groups with the most dead-lettered messages
+type DeadLetterTopResponse struct {
+	BaseResponse
+	GroupBy string `json:"group_by" example:"reason"`
+	// Total is the number of messages dead-lettered in the window, Groups only lists the top ones
+	Total  int64             `json:"total" example:"1113"`
+	Groups []DeadLetterCount `json:"groups"`
+}
+
+// DeadLetterHeatmapResponse represents when messages were dead-lettered, by UTC weekday and hour
+type DeadLetterHeatmapResponse struct {
+	BaseResponse
+	Total int64 `json:"total" example:"1113"`
+	// Counts are the messages dead-lettered by weekday (0 is Sunday) and hour, Counts[1][9] is Mondays 09:00-10:00 UTC
+	Counts [7][24]int64 `json:"counts"`
+	// PeakWeekday and PeakHour are the hour with the most dead-lettered messages
+	PeakWeekday int `json:"peak_weekday" example:"1"`
+	PeakHour    int `json:"peak_hour" example:"9"`
+}
+
+// DowntimeWindow represents consecutive minutes in which every attempt sent to a provider failed
+type DowntimeWindow struct {
+	Start time.Time `json:"start" example:"2026-09-14T02:10:00Z"`
+	// End is the end of the last down minute
+	End             time.Time `json:"end" example:"2026-09-14T02:24:00Z"`
+	DurationSeconds int64     `json:"duration_seconds" example:"840"`
+	Attempts        int64     `json:"attempts" example:"96"`
+}
+
+// ProviderSLAResponse represents how a provider served the attempts sent to it in a window
+type ProviderSLAResponse struct {
+	BaseResponse
+	Provider  string    `json:"provider" example:"twilio"`
+	From      time.Time `json:"from" example:"2026-08-18T00:00:00Z"`
+	To        time.Time `json:"to" example:"2026-09-17T00:00:00Z"`
+	Attempts  int64     `json:"attempts" example:"182340"`
+	Succeeded int64     `json:"succeeded" example:"181902"`
+	// SuccessRate is Succeeded as a share of Attempts between 0 and 1, 1 without attempts
+	SuccessRate float64 `json:"success_rate" example:"0.9976"`
+	// The latencies of the attempts in milliseconds, percentiles are nearest-rank
+	AvgLatencyMs float64 `json:"avg_latency_ms" example:"212.4"`
+	P50LatencyMs float64 `json:"p50_latency_ms" example:"180"`
+	P95LatencyMs float64 `json:"p95_latency_ms" example:"410"`
+	P99LatencyMs float64 `json:"p99_latency_ms" example:"1250"`
+	// Downtime are the windows in which every attempt failed, oldest first
+	Downtime        []DowntimeWindow `json:"downtime"`
+	DowntimeSeconds int64            `json:"downtime_seconds" example:"840"`
+	// Availability is the share of the window outside of Downtime, between 0 and 1
+	Availability float64 `json:"availability" example:"0.9997"`
+}
+
+// ReplayResponse represents the outcome of a replay
+type ReplayResponse struct {
+	BaseResponse
+	DryRun bool `json:"dry_run"`
+	// Matched is the number of messages sent within the window that were not replayed before
+	Matched int `json:"matched" example:"1200"`
+	// Replayed is the number of clones enqueued, 0 for a dry run
+	Replayed int `json:"replayed" example:"1200"`
+}
+
+// BulkStatusResult is the outcome of a bulk status change for one message
+type BulkStatusResult struct {
+	ID int64 `json:"id" example:"42"`
+	// Result is updated, skipped when the message is not in the status the action applies to, or not_found
+	Result string `json:"result" example:"updated"`
+	// Status is the status of the message after the change, empty when it was not found
+	Status string `json:"status,omitempty" example:"cancelled"`
+}
+
+// BulkStatusResponse represents the per message results of a bulk status change, in the order of the request
+type BulkStatusResponse struct {
+	BaseResponse
+	Action  string             `json:"action" example:"cancel"`
+	Updated int                `json:"updated" example:"1"`
+	Results []BulkStatusResult `json:"results"`
+}
+
+// ErasureRecord is the audit record of an erasure, it identifies the number by its SHA-256 only
+type ErasureRecord struct {
+	ID          int64     `json:"id" example:"7"`
+	PhoneHash   string    `json:"phone_hash"`
+	Mode        string    `json:"mode" example:"anonymize"`
+	Messages    int       `json:"messages" example:"12"`
+	Links       int       `json:"links" example:"3"`
+	Suppression bool      `json:"suppression"`
+	Reference   string    `json:"reference,omitempty" example:"DSR-1042"`
+	RequestedBy string    `json:"requested_by" example:"default"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ErasureResponse represents the outcome of an erasure
+type ErasureResponse struct {
+	BaseResponse
+	DryRun bool `json:"dry_run"`
+	// Messages and Links are the numbers of messages and tracked links that match, or that were erased
+	Messages int `json:"messages" example:"12"`
+	Links    int `json:"links" example:"3"`
+	// Suppressed is whether the number is suppressed, it stays suppressed unless include_suppression is set
+	Suppressed bool `json:"suppressed"`
+	// Erasure is the audit record, unset for a dry run
+	Erasure *ErasureRecord `json:"erasure,omitempty"`
+}
+
+// ErasuresListResponse represents the erasure audit records, newest first
+type ErasuresListResponse struct {
+	BaseResponse
+	Erasures []ErasureRecord `json:"erasures"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
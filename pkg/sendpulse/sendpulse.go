@@ -0,0 +1,216 @@
+// Package sendpulse embeds SendPulse in another binary. An Engine runs the scheduler and serves the message
+// services in-process, the way the worker command does, without the REST API.
+//
+//	cfg, err := sendpulse.LoadConfig("./configs/sendpulse.yaml")
+//	engine, err := sendpulse.New(ctx, cfg)
+//	defer engine.Close(context.Background())
+//
+//	if err := engine.Start(ctx); err != nil {
+//		return err
+//	}
+//	response, err := engine.Messages().CreateMessage(ctx, &dto.CreateMessageRequest{To: "+905551234567", Content: "Hello"})
+//
+// Requests and responses are the types of pkg/dto, the webhook client is pkg/webhook.
+package sendpulse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/events"
+	"github.com/boratanrikulu/sendpulse/internal/links"
+	"github.com/boratanrikulu/sendpulse/internal/policy"
+	"github.com/boratanrikulu/sendpulse/internal/queue"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/uptrace/bun"
+)
+
+type (
+	// Config is the configuration of SendPulse, see configs/sendpulse.yaml
+	Config = config.Cfg
+	// MessageFilter filters the messages listed by MessageInterface.ListMessages
+	MessageFilter = db.MessageFilter
+	// Event is a message lifecycle event, EventType its kind
+	Event     = events.Event
+	EventType = events.Type
+	// EventSubscription receives the lifecycle events, see Engine.Subscribe
+	EventSubscription = events.Subscription
+)
+
+// Service interfaces, the Engine returns their implementations
+type (
+	MessageInterface        = service.MessageInterface
+	SchedulerInterface      = service.SchedulerInterface
+	SuppressionInterface    = service.SuppressionInterface
+	DeliveryReportInterface = service.DeliveryReportInterface
+	ReplayInterface         = service.ReplayInterface
+	ErasureInterface        = service.ErasureInterface
+)
+
+// LoadConfig loads the config file at path with the SENDPULSE_ environment overrides and validates it, the
+// returned error joins every problem found like `sendpulse config validate` lists them
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := config.NewConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if problems := cfg.Validate(); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config: %w", errors.Join(problems...))
+	}
+	return cfg, nil
+}
+
+// Engine is an embedded SendPulse instance
+type Engine struct {
+	cfg       *config.Cfg
+	db        *bun.DB
+	ownsDB    bool
+	closers   []func()
+	messages  *service.MessageService
+	scheduler *service.Scheduler
+	reports   *service.DeliveryReportService
+	suppress  *service.SuppressionService
+	replays   *service.ReplayService
+	erasures  *service.ErasureService
+	bus       *events.Bus
+}
+
+// New connects to database.dsn, retrying for up to database.connect_timeout, and creates an engine,
+// messaging starts with Start
+func New(ctx context.Context, cfg *Config) (*Engine, error) {
+	dbc, err := db.ConnectWithRetry(ctx, cfg.Database.DSN, cfg.Database.ConnectTimeout, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := NewWithDB(ctx, cfg, dbc)
+	if err != nil {
+		dbc.Close()
+		return nil, err
+	}
+	engine.ownsDB = true
+	return engine, nil
+}
+
+// NewWithDB creates an engine on an open database connection, the migrations must be applied.
+// The connection is not closed by Close.
+func NewWithDB(ctx context.Context, cfg *Config, database *bun.DB) (*Engine, error) {
+	cfg.SetDB(database)
+	db.SetMaxSegments(cfg.Messaging.MaxSegments)
+	engine := &Engine{cfg: cfg, db: database}
+
+	// the lifecycle events are published onto the bus, the JetStream publisher subscribes to it when enabled
+	engine.bus = events.NewBus()
+	engine.closers = append(engine.closers, engine.bus.Close)
+	if cfg.NATS.Events.Enabled {
+		jetStream, err := events.NewJetStream(ctx, cfg.NATS.URL, cfg.NATS.Events)
+		if err != nil {
+			engine.close()
+			return nil, err
+		}
+		sub := engine.bus.SubscribeWaiting("nats", events.DefaultBuffer, events.DefaultWait)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			events.Forward(sub, jetStream)
+		}()
+		engine.closers = append(engine.closers, func() {
+			engine.bus.Close()
+			<-forwarded
+			jetStream.Close()
+		})
+	}
+
+	// messages are always enqueued to Postgres behind the content policy and link tracking,
+	// the scheduler claims from the configured backend
+	rules, err := policy.New(cfg.ContentPolicy)
+	if err != nil {
+		engine.close()
+		return nil, err
+	}
+	postgres := queue.NewPostgres(database)
+	postgres.SetIDStrategy(db.IDStrategy(cfg.Database.IDStrategy))
+	tracked := links.NewQueue(postgres, database, cfg.LinkTracking)
+	ingestQueue := events.NewQueue(policy.NewQueue(tracked, rules), engine.bus)
+
+	q, closeQueue, err := queue.New(ctx, cfg, database)
+	if err != nil {
+		engine.close()
+		return nil, err
+	}
+	engine.closers = append(engine.closers, closeQueue)
+
+	engine.messages = service.NewMessageServiceWithQueue(database, ingestQueue)
+	engine.reports = service.NewDeliveryReportService(database, cfg.DeliveryReports, engine.bus)
+	engine.scheduler = service.NewSchedulerWithQueue(database, events.NewQueue(q, engine.bus), cfg)
+	engine.scheduler.SetDeliveryReports(engine.reports)
+	engine.messages.SetSendCanceller(engine.scheduler)
+	engine.suppress = service.NewSuppressionService(database, cfg.Suppression)
+	engine.replays = service.NewReplayService(database, ingestQueue, cfg.Replay)
+	engine.erasures = service.NewErasureService(database)
+	return engine, nil
+}
+
+// Messages returns the message service
+func (e *Engine) Messages() MessageInterface {
+	return e.messages
+}
+
+// Scheduler returns the scheduler sending the pending messages
+func (e *Engine) Scheduler() SchedulerInterface {
+	return e.scheduler
+}
+
+// Suppressions returns the suppression list service
+func (e *Engine) Suppressions() SuppressionInterface {
+	return e.suppress
+}
+
+// DeliveryReports returns the service applying provider delivery reports
+func (e *Engine) DeliveryReports() DeliveryReportInterface {
+	return e.reports
+}
+
+// Replays returns the service replaying sent messages
+func (e *Engine) Replays() ReplayInterface {
+	return e.replays
+}
+
+// Erasures returns the service erasing the data of a phone number
+func (e *Engine) Erasures() ErasureInterface {
+	return e.erasures
+}
+
+// Subscribe receives the lifecycle events of the given types, or of every type, until the engine is closed.
+// Events are dropped for a subscriber falling more than 1024 events behind, it must keep up.
+func (e *Engine) Subscribe(name string, types ...EventType) *EventSubscription {
+	return e.bus.Subscribe(name, events.DefaultBuffer, types...)
+}
+
+// Start starts the scheduler. Cancelling ctx does not stop it, Close hands sending over once the
+// in-flight sends finished.
+func (e *Engine) Start(ctx context.Context) error {
+	if _, err := e.scheduler.Start(context.WithoutCancel(ctx)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close stops the scheduler, waiting for the in-flight sends until ctx is done, and releases the engine
+func (e *Engine) Close(ctx context.Context) error {
+	err := e.scheduler.Handoff(ctx)
+	e.close()
+	return err
+}
+
+func (e *Engine) close() {
+	for i := len(e.closers) - 1; i >= 0; i-- {
+		e.closers[i]()
+	}
+	if e.ownsDB {
+		e.db.Close()
+	}
+}
@@ -0,0 +1,67 @@
+package sendpulse
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestEngine(t *testing.T) {
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+	database := bun.NewDB(sqldb, sqlitedialect.New())
+	defer database.Close()
+
+	ctx := context.Background()
+	for _, model := range []any{(*db.Message)(nil), (*db.Suppression)(nil), (*db.Link)(nil), (*db.Lease)(nil)} {
+		_, err := database.NewCreateTable().Model(model).Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	cfg := &Config{Messaging: config.Messaging{Enabled: true, Interval: time.Hour, BatchSize: 2}}
+	engine, err := NewWithDB(ctx, cfg, database)
+	require.NoError(t, err)
+
+	created, err := engine.Messages().CreateMessage(ctx, &dto.CreateMessageRequest{To: "+905551111111", Content: "Hello"})
+	require.NoError(t, err)
+	assert.Equal(t, "pending", created.Message.Status)
+
+	require.NoError(t, engine.Start(ctx))
+	assert.True(t, engine.Scheduler().IsRunning())
+
+	require.NoError(t, engine.Close(ctx))
+	assert.False(t, engine.Scheduler().IsRunning())
+	require.NoError(t, database.PingContext(ctx), "a connection passed in is not closed")
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("../../configs/sendpulse.yaml")
+	require.NoError(t, err)
+	assert.NotNil(t, cfg)
+
+	path := filepath.Join(t.TempDir(), "sendpulse.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`server:
+  mode: dev
+  address: localhost
+database:
+  dsn: postgres://sendpulse@localhost:5432/sendpulse
+messaging:
+  max_segments: 0
+`), 0o600))
+	_, err = LoadConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.address")
+	assert.Contains(t, err.Error(), "messaging.max_segments must be at least 1")
+}
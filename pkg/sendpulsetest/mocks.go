@@ -0,0 +1,193 @@
+// Package sendpulsetest provides test doubles for services integrating with SendPulse: testify mocks of the
+// message and scheduler services and an in-memory fake of the webhook provider.
+//
+//	messages := &sendpulsetest.MockMessage{}
+//	messages.On("CreateMessage", mock.Anything, mock.Anything).Return(&dto.SingleMessageResponse{}, nil)
+//
+//	provider := sendpulsetest.NewWebhook()
+//	defer provider.Close()
+//	provider.FailFor("+905551234567", http.StatusInternalServerError)
+package sendpulsetest
+
+import (
+	"context"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/db"
+	"github.com/boratanrikulu/sendpulse/internal/query"
+	"github.com/boratanrikulu/sendpulse/internal/service"
+	"github.com/boratanrikulu/sendpulse/pkg/dto"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	_ service.MessageInterface   = (*MockMessage)(nil)
+	_ service.SchedulerInterface = (*MockScheduler)(nil)
+)
+
+// MockMessage is a testify mock of the message service
+type MockMessage struct {
+	mock.Mock
+}
+
+func (m *MockMessage) GetSentMessages(ctx context.Context, page, pageSize int) (*dto.MessagesListResponse, error) {
+	args := m.Called(ctx, page, pageSize)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.MessagesListResponse), args.Error(1)
+}
+
+func (m *MockMessage) ListMessages(ctx context.Context, filter db.MessageFilter, page query.Page) (*dto.MessagesListResponse, error) {
+	args := m.Called(ctx, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.MessagesListResponse), args.Error(1)
+}
+
+func (m *MockMessage) GetMessageByID(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) CreateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) ValidateMessage(ctx context.Context, req *dto.CreateMessageRequest) (*dto.ValidateMessageResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.ValidateMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) ReleaseMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) PrioritizeMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) TriageMessage(ctx context.Context, id string, req *dto.TriageMessageRequest) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, id, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) CancelMessage(ctx context.Context, id string) (*dto.SingleMessageResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SingleMessageResponse), args.Error(1)
+}
+
+func (m *MockMessage) MessageEvents(ctx context.Context, id string) (*dto.MessageEventsResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.MessageEventsResponse), args.Error(1)
+}
+
+func (m *MockMessage) SendAttempts(ctx context.Context, id string) (*dto.SendAttemptsResponse, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.SendAttemptsResponse), args.Error(1)
+}
+
+func (m *MockMessage) BulkUpdateStatus(ctx context.Context, req *dto.BulkStatusRequest) (*dto.BulkStatusResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.BulkStatusResponse), args.Error(1)
+}
+
+func (m *MockMessage) Stats(ctx context.Context) (*dto.StatsResponse, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.StatsResponse), args.Error(1)
+}
+
+func (m *MockMessage) Timeseries(ctx context.Context, bucket string, from, to *time.Time) (*dto.TimeseriesResponse, error) {
+	args := m.Called(ctx, bucket, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.TimeseriesResponse), args.Error(1)
+}
+
+func (m *MockMessage) Duplicates(ctx context.Context, from, to *time.Time, window time.Duration, limit int) (*dto.DuplicatesResponse, error) {
+	args := m.Called(ctx, from, to, window, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.DuplicatesResponse), args.Error(1)
+}
+
+func (m *MockMessage) Forecast(ctx context.Context, cfg *config.Cfg, opts service.ForecastOptions) (*dto.ForecastResponse, error) {
+	args := m.Called(ctx, cfg, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.ForecastResponse), args.Error(1)
+}
+
+func (m *MockMessage) RecipientStats(ctx context.Context, phone string) (*dto.RecipientStatsResponse, error) {
+	args := m.Called(ctx, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.RecipientStatsResponse), args.Error(1)
+}
+
+// MockScheduler is a testify mock of the scheduler
+type MockScheduler struct {
+	mock.Mock
+}
+
+func (m *MockScheduler) Start(ctx context.Context) (*dto.MessagingControlResponse, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*dto.MessagingControlResponse), args.Error(1)
+}
+
+func (m *MockScheduler) Stop(ctx context.Context) (*dto.MessagingControlResponse, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*dto.MessagingControlResponse), args.Error(1)
+}
+
+func (m *MockScheduler) GetStatus() *dto.MessagingStatusResponse {
+	args := m.Called()
+	return args.Get(0).(*dto.MessagingStatusResponse)
+}
+
+func (m *MockScheduler) IsRunning() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
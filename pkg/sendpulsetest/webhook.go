@@ -0,0 +1,100 @@
+package sendpulsetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+)
+
+// Message is a message received by the fake webhook
+type Message = webhook.MessagePayload
+
+// Webhook is an in-memory fake of the webhook provider. It accepts every message unless a failure is set for
+// its recipient and keeps the accepted messages for assertions. Point webhook.url, or the URL of a routing
+// provider, at Webhook.URL.
+type Webhook struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []Message
+	failures map[string]int
+}
+
+// NewWebhook starts a fake webhook, it must be closed with Close
+func NewWebhook() *Webhook {
+	w := &Webhook{failures: make(map[string]int)}
+	w.Server = httptest.NewServer(http.HandlerFunc(w.serve))
+	return w
+}
+
+// FailFor answers the messages to the recipient to with status, a 2xx status accepts them again
+func (w *Webhook) FailFor(to string, status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if status >= 200 && status < 300 {
+		delete(w.failures, to)
+		return
+	}
+	w.failures[to] = status
+}
+
+// Messages returns the accepted messages in the order they were received
+func (w *Webhook) Messages() []Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Message(nil), w.messages...)
+}
+
+// MessagesTo returns the accepted messages to the recipient to
+func (w *Webhook) MessagesTo(to string) []Message {
+	var messages []Message
+	for _, message := range w.Messages() {
+		if message.To == to {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Reset drops the accepted messages and the failures
+func (w *Webhook) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.messages = nil
+	w.failures = make(map[string]int)
+}
+
+func (w *Webhook) serve(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	var payload Message
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&payload) != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": "invalid payload"})
+		return
+	}
+
+	w.mu.Lock()
+	status, fail := w.failures[payload.To]
+	if !fail {
+		w.messages = append(w.messages, payload)
+	}
+	n := len(w.messages)
+	w.mu.Unlock()
+
+	if fail {
+		rw.WriteHeader(status)
+		json.NewEncoder(rw).Encode(map[string]string{"error": "fake failure"})
+		return
+	}
+
+	rw.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(rw).Encode(map[string]string{
+		"message":   "Accepted",
+		"messageId": fmt.Sprintf("fake-%d", n),
+	})
+}
@@ -0,0 +1,50 @@
+package sendpulsetest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	fake := NewWebhook()
+	defer fake.Close()
+
+	client := webhook.NewClient(&config.Cfg{Webhook: config.Webhook{URL: fake.URL}})
+	ctx := context.Background()
+
+	t.Run("accepts and records messages", func(t *testing.T) {
+		response, err := client.SendMessage(ctx, webhook.MessagePayload{To: "+905551111111", Content: "Hello"})
+		require.NoError(t, err)
+		assert.Equal(t, "fake-1", response.MessageID)
+
+		assert.Equal(t, []Message{{To: "+905551111111", Content: "Hello"}}, fake.Messages())
+	})
+
+	t.Run("fails for a recipient", func(t *testing.T) {
+		fake.FailFor("+905552222222", http.StatusInternalServerError)
+
+		_, err := client.SendMessage(ctx, webhook.MessagePayload{To: "+905552222222", Content: "Hello"})
+		assert.Error(t, err)
+		assert.Empty(t, fake.MessagesTo("+905552222222"))
+
+		fake.FailFor("+905552222222", http.StatusAccepted)
+		_, err = client.SendMessage(ctx, webhook.MessagePayload{To: "+905552222222", Content: "Hello"})
+		require.NoError(t, err)
+		assert.Len(t, fake.MessagesTo("+905552222222"), 1)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fake.FailFor("+905553333333", http.StatusBadGateway)
+		fake.Reset()
+
+		_, err := client.SendMessage(ctx, webhook.MessagePayload{To: "+905553333333", Content: "Hello"})
+		require.NoError(t, err)
+		assert.Len(t, fake.Messages(), 1)
+	})
+}
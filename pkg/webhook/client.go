@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+type MessagePayload struct {
+	To      string `json:"to"`
+	Content string `json:"content"`
+	// From is the sender ID of the route, the provider's default sender is used when empty
+	From string `json:"from,omitempty"`
+}
+
+type Response struct {
+	StatusCode int       `json:"status_code"`
+	Message    string    `json:"message"`
+	MessageID  string    `json:"message_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	// Truncated is set when the body was discarded for its size or the message or message ID were cut
+	// to webhook.max_stored_length
+	Truncated bool `json:"truncated,omitempty"`
+	// Problem tells why the body has no usable message ID, e.g. it is not JSON or the messageId is missing,
+	// empty when it has one
+	Problem string `json:"problem,omitempty"`
+	// Body is the raw response body, empty when it was not read. It is stored as configured by
+	// webhook.payloads.
+	Body string `json:"-"`
+}
+
+// Usable reports whether the provider returned a message ID, delivery reports are matched by it
+func (r *Response) Usable() bool {
+	return r.Problem == ""
+}
+
+// Credentials authenticate the requests to a webhook, a token is sent as a bearer token, a username and
+// password with basic authentication
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+type Client struct {
+	httpClient *http.Client
+	cfg        *config.Cfg
+	// url overrides webhook.url when set
+	url         string
+	credentials Credentials
+	// onAttempt is called after every request of SendMessageWithRetry
+	onAttempt func(Attempt)
+}
+
+// Attempt is a single request of SendMessageWithRetry, StatusCode is 0 when no response was received
+type Attempt struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	StatusCode int
+	Err        error
+}
+
+func NewClient(cfg *config.Cfg) *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// Creates a client span per call and sends the traceparent header to the webhook
+			Transport: otelhttp.NewTransport(transport(cfg.Webhook)),
+		},
+		cfg: cfg,
+	}
+}
+
+// WithURL returns a client sending to url instead of webhook.url, sharing the connections of c
+func (c *Client) WithURL(url string) *Client {
+	clone := *c
+	clone.url = url
+	return &clone
+}
+
+// WithCredentials returns a client authenticating its requests with credentials, sharing the connections of c
+func (c *Client) WithCredentials(credentials Credentials) *Client {
+	clone := *c
+	clone.credentials = credentials
+	return &clone
+}
+
+// WithAttempts returns a client calling fn after every request of SendMessageWithRetry, sharing the
+// connections of c
+func (c *Client) WithAttempts(fn func(Attempt)) *Client {
+	clone := *c
+	clone.onAttempt = fn
+	return &clone
+}
+
+// WithHandler returns a client serving its requests in-process with handler, e.g. a Sandbox.
+// Timeouts and retries apply as they do for requests over the network.
+func (c *Client) WithHandler(handler http.Handler) *Client {
+	clone := *c
+	clone.httpClient = &http.Client{
+		Timeout:   c.httpClient.Timeout,
+		Transport: otelhttp.NewTransport(handlerTransport{handler: handler}),
+	}
+	clone.url = "http://" + config.SandboxProvider
+	return &clone
+}
+
+func (c *Client) SendMessage(ctx context.Context, payload MessagePayload) (*Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	url := c.cfg.Webhook.URL
+	if c.url != "" {
+		url = c.url
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.credentials.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.credentials.Token)
+	} else if c.credentials.Username != "" {
+		req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	webhookResponse := c.readResponse(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return webhookResponse, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return webhookResponse, nil
+}
+
+// Warmup opens a connection to the webhook with a HEAD request, which the client keeps for the sends that
+// follow. Any response means the webhook is reachable, whatever its status.
+func (c *Client) Warmup(ctx context.Context) error {
+	url := c.cfg.Webhook.URL
+	if c.url != "" {
+		url = c.url
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	// the connection is only reused once the body was read
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// Prewarm opens n connections to the webhook at once with HEAD requests like Warmup, the transport keeps them idle
+// for the sends that follow. An HTTP/2 webhook is served by a single connection. It returns the first error.
+func (c *Client) Prewarm(ctx context.Context, n int) error {
+	errs := make(chan error, n)
+	for range n {
+		go func() {
+			errs <- c.Warmup(ctx)
+		}()
+	}
+
+	var err error
+	for range n {
+		if warmupErr := <-errs; warmupErr != nil && err == nil {
+			err = warmupErr
+		}
+	}
+	return err
+}
+
+// readResponse reads the message and message ID of a provider response within the webhook response guards,
+// the status decides whether the send succeeded whatever the body is. A body without a usable message ID has
+// its Problem set.
+func (c *Client) readResponse(resp *http.Response) *Response {
+	guards := c.cfg.Webhook
+	response := &Response{
+		StatusCode: resp.StatusCode,
+		Timestamp:  time.Now().UTC(),
+	}
+	unusable := func(problem string) *Response {
+		response.Message, response.Problem = problem, problem
+		return response
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); !acceptsContentType(guards.ResponseContentTypes, contentType) {
+		return unusable(fmt.Sprintf("unexpected response content type %q", contentType))
+	}
+
+	body := io.Reader(resp.Body)
+	if guards.MaxResponseSize > 0 {
+		body = io.LimitReader(resp.Body, guards.MaxResponseSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return unusable("failed to read response")
+	}
+	if guards.MaxResponseSize > 0 && int64(len(data)) > guards.MaxResponseSize {
+		response.Truncated = true
+		return unusable(fmt.Sprintf("response larger than %d bytes discarded", guards.MaxResponseSize))
+	}
+	response.Body = string(data)
+
+	// providers answering in snake case are read like the documented camel case, blanks around values dropped
+	var responseBody struct {
+		Message        string `json:"message"`
+		MessageID      string `json:"messageId"`
+		SnakeMessageID string `json:"message_id"`
+	}
+	if err := json.Unmarshal(data, &responseBody); err != nil {
+		return unusable("failed to decode response")
+	}
+	messageID := strings.TrimSpace(responseBody.MessageID)
+	if messageID == "" {
+		messageID = strings.TrimSpace(responseBody.SnakeMessageID)
+	}
+
+	var cut, cutID bool
+	response.Message, cut = truncate(strings.TrimSpace(responseBody.Message), guards.MaxStoredLength)
+	response.MessageID, cutID = truncate(messageID, guards.MaxStoredLength)
+	response.Truncated = cut || cutID
+	switch {
+	case messageID == "":
+		response.Problem = "response has no messageId"
+	case cutID:
+		// a cut message ID matches no delivery report
+		response.Problem = fmt.Sprintf("messageId longer than %d bytes", guards.MaxStoredLength)
+	}
+	return response
+}
+
+// acceptsContentType reports whether the media type of contentType is one of allowed, a missing content type
+// and an empty allowed list accept
+func acceptsContentType(allowed []string, contentType string) bool {
+	if len(allowed) == 0 || contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range allowed {
+		if strings.EqualFold(strings.TrimSpace(accepted), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts s to at most max bytes without splitting a character, 0 keeps it whole
+func truncate(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut], true
+}
+
+// SendMessageWithRetry sends the message, retrying transient failures up to messaging.max_retries times
+func (c *Client) SendMessageWithRetry(ctx context.Context, payload MessagePayload) (*Response, error) {
+	var lastErr error
+	var lastResponse *Response
+
+	maxRetries := c.cfg.Messaging.MaxRetries
+	retryDelay := c.cfg.Messaging.RetryDelay
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return lastResponse, ctx.Err()
+			case <-time.After(retryDelay):
+			}
+		}
+
+		startedAt := time.Now().UTC()
+		response, err := c.SendMessage(ctx, payload)
+		if c.onAttempt != nil {
+			attempt := Attempt{StartedAt: startedAt, FinishedAt: time.Now().UTC(), Err: err}
+			if response != nil {
+				attempt.StatusCode = response.StatusCode
+			}
+			c.onAttempt(attempt)
+		}
+		if err == nil {
+			return response, nil
+		}
+
+		lastErr = err
+		lastResponse = response
+		// a rejected message is rejected again
+		if !ClassifyError(err).Transient() {
+			break
+		}
+	}
+
+	return lastResponse, lastErr
+}
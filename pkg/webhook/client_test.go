@@ -0,0 +1,654 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupTestClient(serverURL string) *Client {
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{
+			URL: serverURL,
+		},
+	}
+	return NewClient(cfg)
+}
+
+func TestClient_SendMessage_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "test-123"}`))
+	}))
+	defer server.Close()
+
+	client := setupTestClient(server.URL)
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	response, err := client.SendMessage(context.Background(), payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Accepted", response.Message)
+	assert.Equal(t, "test-123", response.MessageID)
+}
+
+func TestClient_WithURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload MessagePayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "ACME", payload.From)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "routed-1"}`))
+	}))
+	defer server.Close()
+
+	client := setupTestClient("http://127.0.0.1:1/unused")
+	response, err := client.WithURL(server.URL).SendMessage(context.Background(), MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+		From:    "ACME",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "routed-1", response.MessageID)
+}
+
+func TestClient_WithCredentials(t *testing.T) {
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := setupTestClient(server.URL)
+	payload := MessagePayload{To: "+905551111111", Content: "Test message"}
+	for _, c := range []*Client{
+		client,
+		client.WithCredentials(Credentials{Token: "s3cret"}),
+		client.WithCredentials(Credentials{Username: "acme", Password: "pass"}),
+	} {
+		_, err := c.SendMessage(context.Background(), payload)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"", "Bearer s3cret", "Basic YWNtZTpwYXNz"}, authorizations)
+}
+
+func TestClient_Warmup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, setupTestClient(server.URL).Warmup(context.Background()), "any response means the webhook is reachable")
+	assert.Error(t, setupTestClient("http://127.0.0.1:1/unreachable").Warmup(context.Background()))
+}
+
+func TestClient_Prewarm(t *testing.T) {
+	var mu sync.Mutex
+	remotes := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		// the requests overlap, so each one opens its own connection
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{Webhook: config.Webhook{URL: server.URL, Connections: config.WebhookConnections{Prewarm: 4}}}
+	connections := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(remotes)
+	}
+	client := NewClient(cfg)
+	require.NoError(t, client.Prewarm(context.Background(), 4))
+	assert.Equal(t, 4, connections())
+
+	for range 4 {
+		_, err := client.SendMessage(context.Background(), MessagePayload{To: "+905551111111", Content: "Test message"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, connections(), "the sends take the prewarmed connections")
+}
+
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	lookups := 0
+	// the provider moved from 127.0.0.2, where nothing listens, to the test server, then its DNS failed
+	answers := [][]string{{"127.0.0.2"}, {"127.0.0.1"}, nil}
+	cache := NewDNSCache(time.Hour)
+	cache.lookup = func(_ context.Context, host string) ([]string, error) {
+		assert.Equal(t, "provider.test", host)
+		answer := answers[lookups]
+		lookups++
+		if answer == nil {
+			return nil, errors.New("no such host")
+		}
+		return answer, nil
+	}
+	ctx := context.Background()
+
+	addrs, err := cache.Lookup(ctx, "provider.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.2"}, addrs)
+	addrs, err = cache.Lookup(ctx, "provider.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.2"}, addrs)
+	assert.Equal(t, 1, lookups, "the addresses are cached for the TTL")
+
+	dial := cache.dialContext((&net.Dialer{Timeout: time.Second}).DialContext)
+	conn, err := dial(ctx, "tcp", net.JoinHostPort("provider.test", port))
+	require.NoError(t, err, "the host is resolved again when its cached addresses cannot be dialed")
+	conn.Close()
+	assert.Equal(t, 2, lookups)
+
+	cache.expire("provider.test")
+	addrs, err = cache.Lookup(ctx, "provider.test")
+	require.NoError(t, err, "a failed lookup serves the expired addresses")
+	assert.Equal(t, []string{"127.0.0.1"}, addrs)
+	assert.Equal(t, 3, lookups)
+
+	conn, err = dial(ctx, "tcp", server.Listener.Addr().String())
+	require.NoError(t, err, "IP addresses are dialed without a lookup")
+	conn.Close()
+	assert.Equal(t, 3, lookups)
+}
+
+func TestClient_SendMessage_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Server error"}`))
+	}))
+	defer server.Close()
+
+	client := setupTestClient(server.URL)
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	response, err := client.SendMessage(context.Background(), payload)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook returned status: 500")
+	assert.NotNil(t, response)
+	assert.Equal(t, 500, response.StatusCode)
+}
+
+func TestClient_SendMessage_InvalidJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`invalid json`))
+	}))
+	defer server.Close()
+
+	client := setupTestClient(server.URL)
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	response, err := client.SendMessage(context.Background(), payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "failed to decode response", response.Message)
+	assert.Empty(t, response.MessageID)
+	assert.Equal(t, "failed to decode response", response.Problem)
+	assert.False(t, response.Usable())
+}
+
+func TestClient_SendMessage_NormalizesResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		message   string
+		messageID string
+		problem   string
+	}{
+		{"camel case", `{"message": "Accepted", "messageId": "norm-1"}`, "Accepted", "norm-1", ""},
+		{"snake case", `{"message": "Accepted", "message_id": "norm-1"}`, "Accepted", "norm-1", ""},
+		{"blanks", `{"message": " Accepted\n", "messageId": " norm-1 "}`, "Accepted", "norm-1", ""},
+		{"camel case wins", `{"messageId": "norm-1", "message_id": "norm-2"}`, "", "norm-1", ""},
+		{"no message ID", `{"message": "Accepted"}`, "Accepted", "", "response has no messageId"},
+		{"blank message ID", `{"message": "Accepted", "messageId": "   "}`, "Accepted", "", "response has no messageId"},
+		{"message ID too long", `{"messageId": "norm-1234567890"}`, "", "norm-1234567", "messageId longer than 12 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(&config.Cfg{Webhook: config.Webhook{URL: server.URL, MaxStoredLength: 12}})
+			response, err := client.SendMessage(context.Background(), MessagePayload{To: "+905551111111", Content: "Test message"})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.message, response.Message)
+			assert.Equal(t, tt.messageID, response.MessageID)
+			assert.Equal(t, tt.problem, response.Problem)
+			assert.Equal(t, tt.problem == "", response.Usable())
+			assert.Equal(t, tt.body, response.Body, "the raw body is kept whole")
+		})
+	}
+}
+
+func TestClient_SendMessage_ResponseGuards(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		message     string
+		messageID   string
+		truncated   bool
+	}{
+		{"accepted", "application/json; charset=utf-8", `{"message": "Accepted", "messageId": "guard-1"}`, "Accepted", "guard-1", false},
+		{"unexpected content type", "text/html", `{"message": "Accepted", "messageId": "guard-1"}`, `unexpected response content type "text/html"`, "", false},
+		{"too large", "application/json", `{"message": "` + strings.Repeat("a", 100) + `"}`, "response larger than 64 bytes discarded", "", true},
+		{"long message", "application/json", `{"message": "Kampanya başladı", "messageId": "guard-1"}`, "Kampanya ba", "guard-1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(&config.Cfg{Webhook: config.Webhook{
+				URL:                  server.URL,
+				MaxResponseSize:      64,
+				ResponseContentTypes: []string{"application/json"},
+				MaxStoredLength:      12,
+			}})
+			response, err := client.SendMessage(context.Background(), MessagePayload{To: "+905551111111", Content: "Test message"})
+
+			assert.NoError(t, err, "the status decides whether the send succeeded")
+			assert.Equal(t, tt.message, response.Message)
+			assert.Equal(t, tt.messageID, response.MessageID)
+			assert.Equal(t, tt.truncated, response.Truncated)
+		})
+	}
+}
+
+func TestClient_SendMessageWithRetry_Success(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "retry-123"}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{
+			URL: server.URL,
+		},
+		Messaging: config.Messaging{
+			MaxRetries: 3,
+			RetryDelay: 10 * time.Millisecond,
+		},
+	}
+	var observed []Attempt
+	client := NewClient(cfg).WithAttempts(func(attempt Attempt) {
+		observed = append(observed, attempt)
+	})
+
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	response, err := client.SendMessageWithRetry(context.Background(), payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "Accepted", response.Message)
+	assert.Equal(t, "retry-123", response.MessageID)
+	assert.Equal(t, 3, attempts)
+
+	require.Len(t, observed, 3, "every request is observed")
+	for i, attempt := range observed {
+		assert.False(t, attempt.FinishedAt.Before(attempt.StartedAt))
+		if i < 2 {
+			assert.Equal(t, 500, attempt.StatusCode)
+			assert.Error(t, attempt.Err)
+			assert.False(t, observed[i+1].StartedAt.Before(attempt.FinishedAt.Add(10*time.Millisecond)), "retries wait for the retry delay")
+		}
+	}
+	assert.Equal(t, 200, observed[2].StatusCode)
+	assert.NoError(t, observed[2].Err)
+}
+
+func TestClient_SendMessageWithRetry_MaxRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{
+			URL: server.URL,
+		},
+		Messaging: config.Messaging{
+			MaxRetries: 2,
+			RetryDelay: 10 * time.Millisecond,
+		},
+	}
+	client := NewClient(cfg)
+
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	response, err := client.SendMessageWithRetry(context.Background(), payload)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook returned status: 500")
+	assert.NotNil(t, response)
+	assert.Equal(t, 3, attempts) // 1 initial + 2 retries
+}
+
+func TestClient_SendMessageWithRetry_PermanentFailure(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{
+		Webhook:   config.Webhook{URL: server.URL},
+		Messaging: config.Messaging{MaxRetries: 2, RetryDelay: 10 * time.Millisecond},
+	}
+	_, err := NewClient(cfg).SendMessageWithRetry(context.Background(), MessagePayload{To: "+905551111111", Content: "Test message"})
+
+	assert.Equal(t, FailureProviderPermanent, ClassifyError(err))
+	assert.Equal(t, 1, attempts, "a rejected message is not retried")
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected FailureClass
+	}{
+		{&StatusError{StatusCode: 500}, FailureProviderTemporary},
+		{fmt.Errorf("send: %w", &StatusError{StatusCode: 429}), FailureProviderTemporary},
+		{&StatusError{StatusCode: 408}, FailureProviderTemporary},
+		{&StatusError{StatusCode: 400}, FailureProviderPermanent},
+		{&StatusError{StatusCode: 401}, FailureProviderPermanent},
+		{fmt.Errorf("%w: unsupported value", ErrInvalidPayload), FailureValidation},
+		{context.DeadlineExceeded, FailureNetwork},
+		{errors.New("webhook request failed: connection refused"), FailureNetwork},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ClassifyError(tt.err), tt.err.Error())
+	}
+	assert.True(t, FailureNetwork.Transient())
+	assert.True(t, FailureProviderTemporary.Transient())
+	assert.False(t, FailureProviderPermanent.Transient())
+	assert.False(t, FailureValidation.Transient())
+}
+
+func TestClient_SendMessageWithRetry_ContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{
+		Webhook: config.Webhook{
+			URL: server.URL,
+		},
+		Messaging: config.Messaging{
+			MaxRetries: 5,
+			RetryDelay: 50 * time.Millisecond,
+		},
+	}
+	client := NewClient(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	payload := MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	}
+
+	_, err := client.SendMessageWithRetry(ctx, payload)
+
+	assert.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestMockHandler(t *testing.T) {
+	t.Run("accepts messages", func(t *testing.T) {
+		mock := NewMockHandler(MockOptions{})
+		server := httptest.NewServer(mock)
+		defer server.Close()
+
+		response, err := setupTestClient(server.URL).SendMessage(context.Background(), MessagePayload{
+			To:      "+905551111111",
+			Content: "Test message",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+		assert.Equal(t, "mock-1", response.MessageID)
+		assert.Equal(t, MockStats{Requests: 1}, mock.Stats())
+	})
+
+	t.Run("injects errors", func(t *testing.T) {
+		mock := NewMockHandler(MockOptions{ErrorRate: 1})
+		server := httptest.NewServer(mock)
+		defer server.Close()
+
+		_, err := setupTestClient(server.URL).SendMessage(context.Background(), MessagePayload{
+			To:      "+905551111111",
+			Content: "Test message",
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, MockStats{Requests: 1, Errors: 1}, mock.Stats())
+	})
+
+	t.Run("adds latency", func(t *testing.T) {
+		server := httptest.NewServer(NewMockHandler(MockOptions{Latency: 50 * time.Millisecond}))
+		defer server.Close()
+
+		start := time.Now()
+		_, err := setupTestClient(server.URL).SendMessage(context.Background(), MessagePayload{
+			To:      "+905551111111",
+			Content: "Test message",
+		})
+
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("reports deliveries", func(t *testing.T) {
+		reports := make(chan SandboxReport, 2)
+		mock := NewMockHandler(MockOptions{
+			ReportDelay: 10 * time.Millisecond,
+			Report: func(ctx context.Context, report SandboxReport) error {
+				reports <- report
+				return nil
+			},
+		})
+		server := httptest.NewServer(mock)
+		defer server.Close()
+
+		response, err := setupTestClient(server.URL).SendMessage(context.Background(), MessagePayload{
+			To:      "+905551111111",
+			Content: "Test message",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, SandboxReport{MessageID: response.MessageID, Status: "delivered"}, <-reports)
+
+		mock.opts.UndeliveredRate = 1
+		response, err = setupTestClient(server.URL).SendMessage(context.Background(), MessagePayload{
+			To:      "+905551111111",
+			Content: "Test message",
+		})
+		require.NoError(t, err)
+		report := <-reports
+		assert.Equal(t, response.MessageID, report.MessageID)
+		assert.Equal(t, "undelivered", report.Status)
+		assert.Equal(t, MockStats{Requests: 2, Reports: 2, Undelivered: 1}, mock.Stats())
+	})
+}
+
+func TestClient_SendMessage_PropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "Accepted", "messageId": "test-123"}`))
+	}))
+	defer server.Close()
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	_, err := setupTestClient(server.URL).SendMessage(ctx, MessagePayload{
+		To:      "+905551111111",
+		Content: "Test message",
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, traceparent, "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestSandbox(t *testing.T) {
+	reports := make(chan SandboxReport, 4)
+	sandbox := NewSandbox(SandboxOptions{
+		ReportDelay:        time.Millisecond,
+		DelayedReportDelay: 100 * time.Millisecond,
+		Report: func(ctx context.Context, report SandboxReport) error {
+			reports <- report
+			return nil
+		},
+	})
+	client := setupTestClient("").WithHandler(sandbox)
+	send := func(ctx context.Context, to string) (*Response, error) {
+		return client.SendMessage(ctx, MessagePayload{To: to, Content: "Test message"})
+	}
+
+	t.Run("accepts and reports delivered", func(t *testing.T) {
+		response, err := send(context.Background(), "+905551111111")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+		assert.Equal(t, SandboxReport{MessageID: response.MessageID, Status: "delivered"}, <-reports)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		response, err := send(context.Background(), SandboxNumberFails)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := send(ctx, SandboxNumberTimesOut)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("delays the report", func(t *testing.T) {
+		start := time.Now()
+		response, err := send(context.Background(), SandboxNumberDelayedReport)
+
+		assert.NoError(t, err)
+		assert.Equal(t, SandboxReport{MessageID: response.MessageID, Status: "delivered"}, <-reports)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("reports undelivered", func(t *testing.T) {
+		response, err := send(context.Background(), SandboxNumberUndelivered)
+
+		assert.NoError(t, err)
+		report := <-reports
+		assert.Equal(t, response.MessageID, report.MessageID)
+		assert.Equal(t, "undelivered", report.Status)
+	})
+}
+
+func TestClient_SendMessage_LocalAddress(t *testing.T) {
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote, _, _ = net.SplitHostPort(r.RemoteAddr)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Cfg{Webhook: config.Webhook{URL: server.URL, LocalAddress: "127.0.0.1"}}
+	_, err := NewClient(cfg).SendMessage(context.Background(), MessagePayload{To: "+905551111111", Content: "Test message"})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", remote)
+
+	ips, err := LocalAddrs(cfg.Webhook)
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("127.0.0.1")}, ips)
+
+	ips, err = LocalAddrs(config.Webhook{})
+	require.NoError(t, err)
+	assert.Empty(t, ips, "the system picks the address of an unbound client")
+
+	_, err = LocalAddrs(config.Webhook{Interface: "sendpulse-missing0"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+	"github.com/boratanrikulu/sendpulse/internal/telemetry"
+	"golang.org/x/sync/singleflight"
+)
+
+// dnsLookupTimeout bounds a lookup shared by the connections waiting for it
+const dnsLookupTimeout = 10 * time.Second
+
+// Results of the lookups through the DNS cache
+const (
+	dnsCached   = "cached"
+	dnsResolved = "resolved"
+	dnsStale    = "stale"
+	dnsFailed   = "failed"
+)
+
+// dialFunc dials a network address, like net.Dialer.DialContext
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DNSCache keeps the addresses of the provider hosts for a TTL, whatever the TTL of their records, so the
+// connections of a batch do not look the host up one by one. The connections missing a host share a single
+// lookup, a failed lookup serves the addresses of the expired entry.
+type DNSCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+	group  singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// NewDNSCache creates a cache keeping the addresses resolved by the system resolver for ttl
+func NewDNSCache(ttl time.Duration) *DNSCache {
+	return &DNSCache{ttl: ttl, lookup: net.DefaultResolver.LookupHost, entries: make(map[string]dnsEntry)}
+}
+
+// Lookup returns the addresses of host, resolving it when it is not cached or its entry expired
+func (c *DNSCache) Lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		telemetry.RecordDNSLookup(dnsCached)
+		return entry.addrs, nil
+	}
+
+	result, err, _ := c.group.Do(host, func() (any, error) {
+		// the lookup is shared, a cancelled connection does not fail the others waiting for it
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dnsLookupTimeout)
+		defer cancel()
+		return c.lookup(ctx, host)
+	})
+	if err != nil {
+		if ok {
+			telemetry.RecordDNSLookup(dnsStale)
+			config.LogFrom(ctx).WithError(err).WithField("host", host).Warn("Failed to resolve the provider host, using its cached addresses")
+			return entry.addrs, nil
+		}
+		telemetry.RecordDNSLookup(dnsFailed)
+		return nil, err
+	}
+
+	addrs := result.([]string)
+	telemetry.RecordDNSLookup(dnsResolved)
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// expire makes the next lookup of host resolve it again, its addresses are kept in case that fails
+func (c *DNSCache) expire(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[host]; ok {
+		entry.expires = time.Time{}
+		c.entries[host] = entry
+	}
+}
+
+// dialContext dials the addresses of hosts through the cache with dial. When none of the cached addresses can
+// be dialed the host is resolved again, e.g. the provider moved while its old addresses were cached.
+func (c *DNSCache) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+
+		addrs, err := c.Lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialAny(ctx, dial, network, addrs, port)
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+
+		c.expire(host)
+		fresh, lookupErr := c.Lookup(ctx, host)
+		if lookupErr != nil || slices.Equal(fresh, addrs) {
+			return nil, err
+		}
+		config.LogFrom(ctx).WithError(err).WithField("host", host).Warn("Failed to connect to the cached addresses of the provider host, resolved it again")
+		return dialAny(ctx, dial, network, fresh, port)
+	}
+}
+
+// dialAny dials the addresses in order and returns the first connection
+func dialAny(ctx context.Context, dial dialFunc, network string, addrs []string, port string) (net.Conn, error) {
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := dial(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/boratanrikulu/sendpulse/internal/config"
+)
+
+// LocalAddrs returns the addresses requests to providers are sent from, webhook.local_address or the addresses
+// of webhook.interface. It returns none when the client is not bound and the system picks the address.
+func LocalAddrs(cfg config.Webhook) ([]net.IP, error) {
+	if cfg.LocalAddress != "" {
+		ip := net.ParseIP(cfg.LocalAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("webhook.local_address %q is not an IP address", cfg.LocalAddress)
+		}
+		return []net.IP{ip}, nil
+	}
+	if cfg.Interface == "" {
+		return nil, nil
+	}
+
+	iface, err := net.InterfaceByName(cfg.Interface)
+	if err != nil {
+		return nil, fmt.Errorf("webhook.interface %q: %w", cfg.Interface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("webhook.interface %q: %w", cfg.Interface, err)
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if prefix, ok := addr.(*net.IPNet); ok && !prefix.IP.IsLinkLocalUnicast() {
+			ips = append(ips, prefix.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("webhook.interface %q has no addresses", cfg.Interface)
+	}
+	return ips, nil
+}
+
+// transport is the default transport dialing from the local addresses of cfg when the client is bound, through
+// a DNS cache with webhook.connections.dns_cache_ttl and keeping the connections of webhook.connections.prewarm
+// idle
+func transport(cfg config.Webhook) http.RoundTripper {
+	bound := cfg.LocalAddress != "" || cfg.Interface != ""
+	connections := cfg.Connections
+	if !bound && connections.Prewarm == 0 && connections.DNSCacheTTL == 0 {
+		return http.DefaultTransport
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dial := (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	if bound {
+		dial = boundDial(cfg)
+	}
+	if connections.DNSCacheTTL > 0 {
+		dial = NewDNSCache(connections.DNSCacheTTL).dialContext(dial)
+	}
+	t.DialContext = dial
+	// the prewarmed connections are kept idle until the sends take them
+	t.MaxIdleConnsPerHost = max(http.DefaultMaxIdleConnsPerHost, connections.Prewarm)
+	return t
+}
+
+// boundDial dials from the local addresses of cfg
+func boundDial(cfg config.Webhook) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		// the interface addresses are looked up per connection, they may change while the service runs
+		ips, err := LocalAddrs(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		var errs []error
+		for _, ip := range ips {
+			dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, LocalAddr: &net.TCPAddr{IP: ip}}
+			// the remote address has to be of the family of the local one
+			family := network
+			if network == "tcp" && ip.To4() != nil {
+				family = "tcp4"
+			} else if network == "tcp" {
+				family = "tcp6"
+			}
+			conn, err := dialer.DialContext(ctx, family, address)
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
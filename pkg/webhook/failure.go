@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FailureClass classifies why a send failed, transient classes may succeed when sent again later
+type FailureClass string
+
+// Failure classes
+const (
+	// FailureNetwork is a request that got no response: refused connections, DNS failures and timeouts
+	FailureNetwork FailureClass = "network"
+	// FailureProviderTemporary is a provider answering 408, 425, 429 or 5xx
+	FailureProviderTemporary FailureClass = "provider_temporary"
+	// FailureProviderPermanent is a provider rejecting the message with any other status
+	FailureProviderPermanent FailureClass = "provider_permanent"
+	// FailureValidation is a message that cannot be sent as it is, e.g. its payload cannot be encoded
+	FailureValidation FailureClass = "validation"
+)
+
+// TransientFailures are the failure classes that are sent again later
+var TransientFailures = []FailureClass{FailureNetwork, FailureProviderTemporary}
+
+// Transient reports whether a send failing with the class may succeed when sent again later
+func (c FailureClass) Transient() bool {
+	return c == FailureNetwork || c == FailureProviderTemporary
+}
+
+// ErrInvalidPayload is a payload that cannot be encoded as JSON
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// StatusError is a webhook answering with a status outside 2xx
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook returned status: %d", e.StatusCode)
+}
+
+// ClassifyError returns the failure class of an error of SendMessage, errors without a response are
+// network failures
+func ClassifyError(err error) FailureClass {
+	if errors.Is(err, ErrInvalidPayload) {
+		return FailureValidation
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusRequestTimeout, code == http.StatusTooEarly, code == http.StatusTooManyRequests, code >= 500:
+			return FailureProviderTemporary
+		default:
+			return FailureProviderPermanent
+		}
+	}
+	return FailureNetwork
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MockOptions controls the behaviour of the mock webhook
+type MockOptions struct {
+	// Latency is the base delay before every response
+	Latency time.Duration
+	// Jitter adds a random delay between 0 and Jitter on top of Latency
+	Jitter time.Duration
+	// ErrorRate is the share of requests answered with a 500, between 0 and 1
+	ErrorRate float64
+	// ReportDelay is how long after accepting a message its delivery report is sent
+	ReportDelay time.Duration
+	// UndeliveredRate is the share of accepted messages reported undelivered, between 0 and 1
+	UndeliveredRate float64
+	// Report receives the delivery reports of the accepted messages, none are sent when it is nil
+	Report func(ctx context.Context, report SandboxReport) error
+}
+
+// MockStats are the counters of a mock webhook
+type MockStats struct {
+	Requests int64
+	Errors   int64
+	// Reports counts the delivery reports scheduled, Undelivered the ones of them reporting undelivered
+	Reports     int64
+	Undelivered int64
+}
+
+// MockHandler is an http.Handler imitating the webhook provider, used for load tests and local development
+type MockHandler struct {
+	opts        MockOptions
+	requests    atomic.Int64
+	errors      atomic.Int64
+	reports     atomic.Int64
+	undelivered atomic.Int64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewMockHandler(opts MockOptions) *MockHandler {
+	return &MockHandler{
+		opts: opts,
+		rng:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (m *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := m.requests.Add(1)
+
+	m.mu.Lock()
+	delay := m.opts.Latency
+	if m.opts.Jitter > 0 {
+		delay += time.Duration(m.rng.Int63n(int64(m.opts.Jitter)))
+	}
+	fail := m.opts.ErrorRate > 0 && m.rng.Float64() < m.opts.ErrorRate
+	undelivered := m.opts.UndeliveredRate > 0 && m.rng.Float64() < m.opts.UndeliveredRate
+	m.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if fail {
+		m.errors.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "mock failure"})
+		return
+	}
+
+	var payload MessagePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid payload"})
+		return
+	}
+
+	messageID := fmt.Sprintf("mock-%d", n)
+	if m.opts.Report != nil {
+		report := SandboxReport{MessageID: messageID, Status: "delivered"}
+		if undelivered {
+			m.undelivered.Add(1)
+			report = SandboxReport{MessageID: messageID, Status: "undelivered", Error: "mock: handset unreachable"}
+		}
+		m.reports.Add(1)
+		sendReport(m.opts.Report, m.opts.ReportDelay, report)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":   "Accepted",
+		"messageId": messageID,
+	})
+}
+
+// Stats returns the number of requests handled, the injected errors and the delivery reports so far
+func (m *MockHandler) Stats() MockStats {
+	return MockStats{
+		Requests:    m.requests.Load(),
+		Errors:      m.errors.Load(),
+		Reports:     m.reports.Load(),
+		Undelivered: m.undelivered.Load(),
+	}
+}
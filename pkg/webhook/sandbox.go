@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+// Magic recipients of the sandbox provider, every other recipient is accepted and reported delivered
+const (
+	// SandboxNumberFails is always answered with a 500
+	SandboxNumberFails = "+905550000001"
+	// SandboxNumberTimesOut is never answered, the send runs into its timeout
+	SandboxNumberTimesOut = "+905550000002"
+	// SandboxNumberDelayedReport is accepted and reported delivered after SandboxOptions.DelayedReportDelay
+	SandboxNumberDelayedReport = "+905550000003"
+	// SandboxNumberUndelivered is accepted and reported undelivered
+	SandboxNumberUndelivered = "+905550000004"
+)
+
+// SandboxReport is a delivery report of the sandbox provider
+type SandboxReport struct {
+	MessageID string
+	Status    string
+	Error     string
+}
+
+// SandboxOptions controls the delivery reports of the sandbox provider
+type SandboxOptions struct {
+	// ReportDelay is how long after accepting a message its delivery report is sent
+	ReportDelay time.Duration
+	// DelayedReportDelay is the report delay of SandboxNumberDelayedReport
+	DelayedReportDelay time.Duration
+	// Report receives the delivery reports, they are dropped when it is nil
+	Report func(ctx context.Context, report SandboxReport) error
+}
+
+// Sandbox is an http.Handler imitating a provider with deterministic outcomes per recipient, so integration
+// tests and customer sandboxes behave predictably without a real gateway
+type Sandbox struct {
+	opts     SandboxOptions
+	accepted atomic.Int64
+}
+
+func NewSandbox(opts SandboxOptions) *Sandbox {
+	return &Sandbox{opts: opts}
+}
+
+func (s *Sandbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload MessagePayload
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&payload) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid payload"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch payload.To {
+	case SandboxNumberFails:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "sandbox failure"})
+		return
+	case SandboxNumberTimesOut:
+		<-r.Context().Done()
+		return
+	}
+
+	messageID := fmt.Sprintf("sandbox-%d", s.accepted.Add(1))
+	switch payload.To {
+	case SandboxNumberDelayedReport:
+		s.report(s.opts.DelayedReportDelay, SandboxReport{MessageID: messageID, Status: "delivered"})
+	case SandboxNumberUndelivered:
+		s.report(s.opts.ReportDelay, SandboxReport{MessageID: messageID, Status: "undelivered", Error: "sandbox: handset unreachable"})
+	default:
+		s.report(s.opts.ReportDelay, SandboxReport{MessageID: messageID, Status: "delivered"})
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":   "Accepted",
+		"messageId": messageID,
+	})
+}
+
+// sandboxReportAttempts bounds the retries of a report whose message is not stored as sent yet
+const sandboxReportAttempts = 5
+
+// report sends report after delay
+func (s *Sandbox) report(delay time.Duration, report SandboxReport) {
+	sendReport(s.opts.Report, delay, report)
+}
+
+// sendReport calls send with report after delay. The scheduler stores the message ID only after the send
+// returned, so a report arriving first is retried like a provider would.
+func sendReport(send func(ctx context.Context, report SandboxReport) error, delay time.Duration, report SandboxReport) {
+	if send == nil {
+		return
+	}
+
+	var attempt int
+	var try func()
+	try = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		attempt++
+		if err := send(ctx, report); err != nil && attempt < sandboxReportAttempts {
+			time.AfterFunc(time.Second, try)
+		}
+	}
+	time.AfterFunc(delay, try)
+}
+
+// handlerTransport serves requests in-process with an http.Handler
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return recorder.Result(), nil
+}